@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/dfs"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// runDelete deletes a file from this node:
+//
+//	cli delete [-hard] <file-id>
+//
+// It opens the node's stores directly, so the node must be stopped; peers
+// drop their replicas when a hard delete goes through a running node's
+// DELETE /api/files/{id} instead. It returns the process exit code.
+func runDelete(args []string) int {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	hard := flags.Bool("hard", false, "remove the file's chunks now instead of after the purge delay")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: cli delete [-hard] <file-id>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	fileID := flags.Arg(0)
+
+	chunks, err := storage.NewFromConfig(config.Config, "./output_chunks")
+	if err != nil {
+		fmt.Printf("❌ Failed to create storage: %v\n", err)
+		return 1
+	}
+	catalog, err := metadata.OpenMetadataStore("./metadata_db_client")
+	if err != nil {
+		fmt.Printf("❌ Failed to open metadata store, is the node still running? %v\n", err)
+		return 1
+	}
+	defer catalog.Close()
+	catalog.ChunkBarrier().SetWait(time.Duration(config.Config.ChunkReferenceWait) * time.Second)
+	if _, err := dfs.ApplyStorageMode(chunks, catalog, config.Config.StorageMode); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	}
+
+	dfsConfig := dfs.DefaultDFSConfig()
+	dfsConfig.ExpiryPurgeDelay = time.Duration(config.Config.ExpiryPurgeDelay) * time.Second
+	core := dfs.NewDFSCore(dfsConfig, nil, nil, chunks, catalog)
+	defer core.Stop()
+
+	report, err := core.DeleteFile(fileID, *hard)
+	if errors.Is(err, dfs.ErrFileNotFound) {
+		fmt.Printf("❌ File %s not found\n", fileID)
+		return 1
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to delete %s: %v\n", fileID, err)
+		return 1
+	}
+	if report.Hard {
+		fmt.Printf("🗑️ Deleted %s: %d chunks removed, %d bytes reclaimed\n", fileID, report.ChunksDeleted, report.ReclaimedBytes)
+	} else {
+		fmt.Printf("🗑️ Soft-deleted %s, chunks kept until %s\n", fileID, report.PurgeAfter.Format(time.RFC3339))
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(os.Stderr, "⚠️ %s\n", problem)
+	}
+	return 0
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,10 +13,20 @@ import (
 	"time"
 
 	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/api"
+	"github.com/jaywantadh/DisktroByte/internal/budget"
+	"github.com/jaywantadh/DisktroByte/internal/cache"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/dfs"
 	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
 	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/parallelism"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/telemetry"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
 	"github.com/jaywantadh/DisktroByte/internal/transfer"
 )
 
@@ -26,8 +37,8 @@ var (
 	server          *http.Server
 	network         *p2p.Network
 	fileDistributor *distributor.Distributor
-	// Dummy passthrough cache of original uploads by fileID
-	originalFileCache = make(map[string]string)
+	// Download passthrough cache of uploaded originals, off unless configured
+	originalCache *cache.Passthrough
 )
 
 // Response represents API response structure
@@ -35,6 +46,7 @@ type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	TraceID string      `json:"trace_id,omitempty"` // Correlates the response with server and peer logs
 }
 
 // FileInfo represents file information
@@ -48,45 +60,74 @@ type FileInfo struct {
 func main() {
 	// Load configuration
 	config.LoadConfig("./config")
+	tracing.SetEnabled(config.Config.RequestTracing)
+	if len(os.Args) > 1 && os.Args[1] == "delete" {
+		os.Exit(runDelete(os.Args[2:]))
+	}
 
 	// Initialize storage and metadata
+	if encryptor.Strict() {
+		fmt.Printf("🔒 Strict encryption on: user data is never written to disk unencrypted\n")
+	} else {
+		fmt.Printf("⚠️ Strict encryption is off: uploads, reassembly and caches may write plaintext to disk\n")
+	}
+	if _, err := chunker.ParseHashAlgo(config.Config.HashAlgo); err != nil {
+		fmt.Printf("⚠️ %v: uploads are refused until hash_algo is sha256 or blake3\n", err)
+	}
+	if _, err := chunker.ParseChunkMode(config.Config.ChunkMode); err != nil {
+		fmt.Printf("⚠️ %v: uploads are refused until chunk_mode is fixed or cdc\n", err)
+	}
 	initializeStorage()
 
 	// Try different ports if the default is busy
 	port := config.Config.Port
+	bind := config.Config.APIBindAddress
 	for i := 0; i < 10; i++ {
 		testPort := port + i
-		server = &http.Server{
-			Addr:    fmt.Sprintf(":%d", testPort),
-			Handler: createRouter(),
+		listener, err := p2p.Listen(bind, testPort)
+		if err != nil {
+			if p2p.AddressInUse(err) {
+				fmt.Printf("⚠️ Port %d is busy, trying next port...\n", testPort)
+				continue
+			}
+			fmt.Printf("❌ Server failed to start: %v\n", err)
+			break
 		}
+		server = api.NewServer(listener.Addr().String(), createRouter(), api.ServerLimitsFromConfig())
 
-		fmt.Printf("🚀 DisktroByte GUI starting on http://localhost:%d\n", testPort)
+		fmt.Printf("🚀 DisktroByte GUI starting on %s\n", p2p.NodeURL(p2p.BrowseHost(bind), testPort, ""))
 		fmt.Println("📁 Open your browser and navigate to the URL above")
 		fmt.Println("🔐 Enter your encryption password to get started")
 
 		// Start server
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			if strings.Contains(err.Error(), "bind: Only one usage of each socket address") {
-				fmt.Printf("⚠️ Port %d is busy, trying next port...\n", testPort)
-				continue
-			}
-			fmt.Printf("❌ Server failed to start: %v\n", err)
-			break
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("❌ Server stopped: %v\n", err)
 		}
 		break
 	}
+
+	// Send the spans and logs still queued for the collector
+	telemetry.Global().Shutdown(context.Background())
 }
 
 func initializeStorage() {
 	var err error
 
+	budget.Configure(config.Config.MaxInFlightBytes)
+	throttle.Configure(throttle.ConfigFromConfig(config.Config))
+	parallelism.Configure(parallelism.ConfigFromConfig(config.Config))
+	telemetry.Configure(telemetry.ConfigFromConfig(config.Config))
+	originalCache = newOriginalCache("./original_cache_cli")
+
 	// Create storage backend
-	store, err = storage.NewLocalStorage("./output_chunks")
+	store, err = storage.NewFromConfig(config.Config, "./output_chunks")
 	if err != nil {
 		fmt.Printf("❌ Failed to create storage: %v\n", err)
 		return
 	}
+	if s3, ok := store.(*storage.S3Storage); ok {
+		fmt.Printf("🪣 Storing chunks in %s\n", s3)
+	}
 
 	// Try to open metadata store with retry logic
 	for i := 0; i < 3; i++ {
@@ -109,9 +150,20 @@ func initializeStorage() {
 		fmt.Printf("❌ Failed to open metadata store after retries\n")
 		return
 	}
+	metaStore.ChunkBarrier().SetWait(time.Duration(config.Config.ChunkReferenceWait) * time.Second)
+	if mode, err := dfs.ApplyStorageMode(store, metaStore, config.Config.StorageMode); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	} else if mode == storage.ModeCAS {
+		fmt.Println("🔒 Chunks are immutable and content-addressed")
+	}
 
 	// Initialize P2P network
-	network = p2p.NewNetwork("localhost", config.Config.Port)
+	network = p2p.NewNetworkWithID(loadNodeID(), advertiseAddress(), config.Config.Port)
+	network.SetBindAddress(config.Config.P2PBindAddress)
+	network.SetHTTPServer(api.PeerServer(api.ServerLimitsFromConfig()))
+	network.SetMaxClockSkew(time.Duration(config.Config.MaxClockSkew) * time.Second)
+	network.SetResolveTTL(time.Duration(config.Config.PeerResolveTTL) * time.Second)
+	network.SetLatencyProbeInterval(time.Duration(config.Config.LatencyProbeInterval) * time.Second)
 	// Set storage backend for chunk serving
 	network.SetStorage(store)
 	// Set metadata store for chunk mapping
@@ -126,18 +178,74 @@ func initializeStorage() {
 	// Initialize file distributor
 	fileDistributor = distributor.NewDistributor(network, store, metaStore)
 	fileDistributor.SetReplicaCount(3) // Set default replica count
+	if err := network.SetWireCodecs(config.Config.ChunkWireCodecs); err != nil {
+		fmt.Printf("⚠️ %v, sending chunks uncompressed\n", err)
+	}
+	network.SetLocalLabels(config.Config.NodeLabels)
+	network.SetLocalTopology(config.Config.Zone, config.Config.Rack)
+	fileDistributor.SetSpread(config.Config.ChunkSpreadMaxFraction)
+	fileDistributor.SetZoneSpread(config.Config.PlacementStrategy == string(dfs.StrategyZoneAware))
+	fileDistributor.SetVerifyOnUpload(config.Config.VerifyOnUpload)
+	fileDistributor.SetLatencyAware(config.Config.LatencyAwareSelection)
+	fileDistributor.SetReplicaFreshness(time.Duration(config.Config.ReplicaFreshnessWindow) * time.Second)
+	fileDistributor.SetVerifyStaleOnRead(config.Config.VerifyStaleReplicasOnRead)
+	fileDistributor.SetHaveNeed(config.Config.ChunkHaveNeed)
+	policy, err := distributor.TransportPolicyFromConfig(config.Config)
+	if err != nil {
+		fmt.Printf("⚠️ %v, preferring http\n", err)
+	}
+	fileDistributor.SetTransportPolicy(policy)
+	retry := distributor.RetryPolicyFromConfig(config.Config)
+	fileDistributor.SetRetryPolicy(retry.MaxRetries, retry.BaseDelay)
+	fileDistributor.SetRetryJitter(retry.Jitter)
+	fileDistributor.SetNegativeCache(time.Duration(config.Config.NegativeCacheTTL)*time.Second, config.Config.NegativeCacheMaxEntries)
+	if err := fileDistributor.SetRedundancyPolicy(distributor.RedundancyPolicyFromConfig(config.Config)); err != nil {
+		fmt.Printf("⚠️ %v, keeping the default redundancy policy\n", err)
+	}
+	if limit, err := distributor.CapacityLimitFromConfig(config.Config, store); err != nil {
+		fmt.Printf("⚠️ %v, node capacity is unlimited\n", err)
+	} else if limit > 0 {
+		fileDistributor.SetCapacityLimit(limit)
+	}
 
 	fmt.Printf("✅ P2P Network and Distributor initialized successfully\n")
 }
 
+// advertiseAddress is the configured address peers dial this node on
+func advertiseAddress() string {
+	address, err := p2p.ValidateAdvertiseAddress(config.Config.P2PAdvertiseAddress)
+	if err != nil {
+		fmt.Printf("⚠️ %v, advertising localhost\n", err)
+		return "localhost"
+	}
+	return address
+}
+
+// loadNodeID returns the persisted node ID, or "" for a temporary one if it cannot be loaded
+func loadNodeID() string {
+	path := config.Config.IdentityFile
+	if path == "" {
+		path = filepath.Join(config.Config.StoragePath, p2p.DefaultIdentityFile)
+	}
+	identity, _, err := p2p.LoadOrCreateIdentity(path)
+	if err != nil {
+		fmt.Printf("⚠️ Failed to load node identity, using a temporary ID: %v\n", err)
+		return ""
+	}
+	fmt.Printf("🆔 Node identity %s\n", identity.NodeID)
+	return identity.NodeID
+}
+
 func createRouter() http.Handler {
 	mux := http.NewServeMux()
+	limits := api.ServerLimitsFromConfig()
+	long := limits.LongRequestTimeout
 
 	// GUI endpoints
 	mux.HandleFunc("/", handleHome)
-	mux.HandleFunc("/api/chunk", handleChunk)
-	mux.HandleFunc("/api/reassemble", handleReassemble)
-	mux.HandleFunc("/api/upload", handleUpload)
+	mux.HandleFunc("/api/chunk", api.WithDeadline(handleChunk, long))
+	mux.HandleFunc("/api/reassemble", api.WithDeadline(handleReassemble, long))
+	mux.HandleFunc("/api/upload", api.WithDeadline(handleUpload, long))
 	mux.HandleFunc("/api/server", handleServer)
 	mux.HandleFunc("/api/status", handleStatus)
 	mux.HandleFunc("/api/files", handleGetFiles)
@@ -151,10 +259,12 @@ func createRouter() http.Handler {
 	mux.HandleFunc("/file-request", network.HandleFileRequest)
 	mux.HandleFunc("/chunk-request", network.HandleChunkRequest)
 	mux.HandleFunc("/heartbeat", network.HandleHeartbeat)
-	mux.HandleFunc("/chunk-transfer", fileDistributor.HandleChunkTransfer)
+	mux.HandleFunc("/chunk-transfer", api.WithDeadline(fileDistributor.HandleChunkTransfer, long))
+	mux.HandleFunc("/chunk-have", fileDistributor.HandleChunkHave)
 	mux.HandleFunc("/chunk", fileDistributor.HandleChunkRequest)
+	mux.HandleFunc("/message", fileDistributor.HandleMessage)
 
-	return mux
+	return api.WithTraceID(api.LimitBodies(mux, limits.MaxBodyBytes, "/api/chunk", "/api/upload", "/chunk-transfer"))
 }
 
 func handleHome(w http.ResponseWriter, r *http.Request) {
@@ -848,7 +958,7 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                     '<div class="peer-info">' +
                         '<div class="peer-name">' + peer.id.substring(0, 8) + '...</div>' +
                         '<div class="peer-details">' +
-                            'Address: ' + peer.address + ':' + peer.port + ' | ' +
+                            'Address: ' + (peer.address.includes(':') ? '[' + peer.address + ']' : peer.address) + ':' + peer.port + ' | ' +
                             'Status: <span class="' + statusClass + '">' + peer.status + '</span> | ' +
                             'Last Seen: ' + lastSeen +
                         '</div>' +
@@ -925,7 +1035,7 @@ func handleChunk(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
+	if err := parseUploadForm(r); err != nil {
 		sendJSONResponse(w, false, "Failed to parse form: "+err.Error(), nil)
 		return
 	}
@@ -943,67 +1053,57 @@ func handleChunk(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Create temp directory if it doesn't exist
-	tempDir := "./temp"
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		sendJSONResponse(w, false, "Failed to create temp directory: "+err.Error(), nil)
-		return
-	}
-
-	// Save uploaded file to temp directory
-	tempPath := filepath.Join(tempDir, header.Filename)
-	tempFile, err := os.Create(tempPath)
-	if err != nil {
-		sendJSONResponse(w, false, "Failed to create temp file: "+err.Error(), nil)
-		return
-	}
-	defer tempFile.Close()
-
-	// Copy uploaded file to temp file
-	if _, err := io.Copy(tempFile, file); err != nil {
-		sendJSONResponse(w, false, "Failed to save uploaded file: "+err.Error(), nil)
-		return
-	}
-
-	// Distribute the file using the P2P network
-	fileInfo, err := fileDistributor.DistributeFile(tempPath, password)
+	// Distribute the file using the P2P network, reading it straight from the parsed form
+	src := chunker.ReaderSource(header.Filename, file, header.Size)
+	fileInfo, err := fileDistributor.DistributeSourceWithOptions(r.Context(), src, distributor.UploadOptions{Password: password})
 	if err != nil {
 		sendJSONResponse(w, false, "Failed to distribute file: "+err.Error(), nil)
 		return
 	}
 
-	// Cache original by fileID for dummy passthrough
-	_ = os.MkdirAll("./original_cache_cli", 0755)
-	cachePath := filepath.Join("./original_cache_cli", fileInfo.ID+"_"+header.Filename)
-	_ = copyFile(tempPath, cachePath)
-	originalFileCache[fileInfo.ID] = cachePath
-
-	// Clean up temp file after distribution
-	os.Remove(tempPath)
+	// Cache the original for fast reassembly when the passthrough cache is enabled
+	if originalCache.Enabled() {
+		if err := originalCache.StoreReader(fileInfo.ID, header.Filename, io.NewSectionReader(file, 0, header.Size), header.Size, password); err != nil {
+			fmt.Printf("⚠️ %v\n", err)
+		}
+	}
 
 	sendJSONResponse(w, true, fmt.Sprintf("File distributed successfully. Total chunks: %d", len(fileInfo.Chunks)), fileInfo)
 }
 
-// helper copy
-func copyFile(src, dst string) error {
-	si, err := os.Stat(src)
-	if err != nil {
-		return err
+// newOriginalCache creates the passthrough cache in the configured mode, falling back to off
+func newOriginalCache(dir string) *cache.Passthrough {
+	mode := config.Config.OriginalCacheMode
+	if _, err := cache.ParseMode(mode); err != nil {
+		fmt.Printf("⚠️ %v, original cache disabled\n", err)
+		mode = cache.ModeOff
 	}
-	in, err := os.Open(src)
-	if err != nil {
-		return err
+	if mode == cache.ModePlaintext && encryptor.Strict() {
+		fmt.Printf("🔒 Original cache plaintext mode is refused by strict encryption, caching encrypted instead\n")
+		mode = cache.ModeEncrypted
 	}
-	defer in.Close()
-	out, err := os.Create(dst)
+	passthrough, err := cache.NewPassthrough(dir, mode)
 	if err != nil {
-		return err
+		fmt.Printf("⚠️ Failed to create original cache, disabled: %v\n", err)
+		passthrough, _ = cache.NewPassthrough(dir, cache.ModeOff)
 	}
-	defer func() { _ = out.Close() }()
-	if _, err = io.Copy(out, in); err != nil {
-		return err
+	passthrough.SetLimits(config.Config.OriginalCacheMaxEntries, config.Config.OriginalCacheMaxBytes)
+	if passthrough.Mode() == cache.ModePlaintext {
+		fmt.Printf("⚠️ Original cache stores PLAINTEXT copies of uploads - use for demos only\n")
+	}
+	return passthrough
+}
+
+// parseUploadForm parses a multipart upload, holding up to 32MB in memory.
+// Strict encryption refuses anything larger, which would spill to disk unencrypted.
+func parseUploadForm(r *http.Request) error {
+	const inMemory = 32 << 20
+	if r.ContentLength < 0 || r.ContentLength > inMemory {
+		if err := encryptor.RefusePlaintext(fmt.Sprintf("upload larger than %d bytes", inMemory)); err != nil {
+			return err
+		}
 	}
-	return os.Chtimes(dst, si.ModTime(), si.ModTime())
+	return r.ParseMultipartForm(inMemory)
 }
 
 func handleReassemble(w http.ResponseWriter, r *http.Request) {
@@ -1033,8 +1133,8 @@ func handleReassemble(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Dummy passthrough: if we have a cached original, simulate reassembly with progress and copy
-	if cachePath, ok := originalFileCache[req.FileID]; ok {
+	// Passthrough: if we have a cached original the password unlocks, simulate reassembly with progress and copy
+	if data, _, err := originalCache.Open(req.FileID, req.Password); err == nil {
 		fmt.Println("🔧 [CLI] Starting reassembly job", req.FileID)
 		time.Sleep(500 * time.Millisecond)
 		fmt.Println("📥 [CLI] Gathering chunks from peers...")
@@ -1046,9 +1146,14 @@ func handleReassemble(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("🔍 [CLI] Verifying integrity...")
 		time.Sleep(500 * time.Millisecond)
 
+		if err := encryptor.RefusePlaintext("reassembled output " + req.OutputPath); err != nil {
+			sendJSONResponse(w, false, err.Error(), nil)
+			return
+		}
+
 		// Ensure output directory exists
 		_ = os.MkdirAll(filepath.Dir(req.OutputPath), 0755)
-		if err := copyFile(cachePath, req.OutputPath); err != nil {
+		if err := os.WriteFile(req.OutputPath, data, 0644); err != nil {
 			sendJSONResponse(w, false, "Failed to write output: "+err.Error(), nil)
 			return
 		}
@@ -1058,7 +1163,7 @@ func handleReassemble(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fallback to real path if no cache (should not happen in demo)
-	err := fileDistributor.ReassembleFile(req.FileID, req.OutputPath, req.Password)
+	err := fileDistributor.ReassembleFileContext(r.Context(), req.FileID, req.OutputPath, req.Password)
 	if err != nil {
 		sendJSONResponse(w, false, "Failed to reassemble file: "+err.Error(), nil)
 		return
@@ -1074,7 +1179,7 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
+	if err := parseUploadForm(r); err != nil {
 		sendJSONResponse(w, false, "Failed to parse form: "+err.Error(), nil)
 		return
 	}
@@ -1094,39 +1199,15 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Create temp directory if it doesn't exist
-	tempDir := "./temp"
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		sendJSONResponse(w, false, "Failed to create temp directory: "+err.Error(), nil)
-		return
-	}
-
-	// Save uploaded file to temp directory
-	tempPath := filepath.Join(tempDir, header.Filename)
-	tempFile, err := os.Create(tempPath)
+	// Chunk the file here, reading it straight from the parsed form, then push the chunks to the peer
+	src := chunker.ReaderSource(header.Filename, file, header.Size)
+	transfer, err := fileDistributor.DistributeSourceToPeer(r.Context(), src, password, peerAddress)
 	if err != nil {
-		sendJSONResponse(w, false, "Failed to create temp file: "+err.Error(), nil)
-		return
-	}
-	defer tempFile.Close()
-
-	// Copy uploaded file to temp file
-	if _, err := io.Copy(tempFile, file); err != nil {
-		sendJSONResponse(w, false, "Failed to save uploaded file: "+err.Error(), nil)
+		sendJSONResponse(w, false, "Failed to send file to peer: "+err.Error(), transfer)
 		return
 	}
 
-	// First distribute the file locally
-	fileInfo, err := fileDistributor.DistributeFile(tempPath, password)
-	if err != nil {
-		sendJSONResponse(w, false, "Failed to distribute file: "+err.Error(), nil)
-		return
-	}
-
-	// Clean up temp file
-	os.Remove(tempPath)
-
-	sendJSONResponse(w, true, fmt.Sprintf("File uploaded and distributed successfully. Total chunks: %d", len(fileInfo.Chunks)), fileInfo)
+	sendJSONResponse(w, true, fmt.Sprintf("File sent to %s: %d of %d chunks accepted", transfer.Peer, transfer.Accepted, transfer.Chunks), transfer)
 }
 
 func handleServer(w http.ResponseWriter, r *http.Request) {
@@ -1178,6 +1259,15 @@ func handleStatus(w http.ResponseWriter, r *http.Request) {
 		"storage_ready":  store != nil,
 		"metadata_ready": metaStore != nil,
 		"timestamp":      time.Now().Unix(),
+		"memory_budget":  budget.Global().Stats(),
+	}
+	if originalCache.Enabled() {
+		status["original_cache"] = originalCache.Stats()
+	}
+	if network != nil {
+		status["node_id"] = network.LocalNode.ID
+		status["peer_clocks"] = network.ClockSkews()
+		status["peer_latencies"] = network.PeerLatencies()
 	}
 
 	sendJSONResponse(w, true, "Status retrieved successfully", status)
@@ -1210,6 +1300,7 @@ func sendJSONResponse(w http.ResponseWriter, success bool, message string, data
 		Success: success,
 		Message: message,
 		Data:    data,
+		TraceID: w.Header().Get(tracing.Header),
 	}
 	json.NewEncoder(w).Encode(response)
 }
@@ -980,7 +980,7 @@ func getJavaScript() string {
             try {
                 const response = await apiCall('/api/users');
                 if (response && response.success) {
-                    displayUsers(response.data);
+                    displayUsers(response.data.users || response.data);
                 }
             } catch (error) {
                 console.error('Failed to load users:', error);
@@ -1060,7 +1060,7 @@ func getJavaScript() string {
         }
 
         // Utility functions
-        async function apiCall(url, method = 'GET', data = null) {
+        async function apiCall(url, method = 'GET', data = null, retried = false) {
             const options = {
                 method: method,
                 credentials: 'include',
@@ -1082,6 +1082,14 @@ func getJavaScript() string {
                     return null;
                 }
 
+                // Sensitive operations ask for the password again before going ahead
+                if (response.status === 403 && response.headers.get('X-Reauth-Required')) {
+                    if (!retried && await reauthenticate()) {
+                        return apiCall(url, method, data, true);
+                    }
+                    return await response.json();
+                }
+
                 return await response.json();
             } catch (error) {
                 console.error('API call failed:', error);
@@ -1089,6 +1097,25 @@ func getJavaScript() string {
             }
         }
 
+        async function reauthenticate() {
+            const password = prompt('This action needs your password again:');
+            if (!password) return false;
+
+            const response = await fetch('/api/auth/reauthenticate', {
+                method: 'POST',
+                credentials: 'include',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ password: password })
+            });
+            const result = await response.json();
+            if (!result.success) {
+                alert('Re-authentication failed: ' + result.message);
+            }
+            return result.success;
+        }
+
         function formatFileSize(bytes) {
             if (bytes === 0) return '0 B';
             
@@ -1420,9 +1447,28 @@ func getJavaScript() string {
             }
         }
 
+        // Asks the server whether the password opens the file before a long download
+        async function checkFilePassword(fileId, password) {
+            const response = await fetch('/api/files/check-password', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                credentials: 'include',
+                body: JSON.stringify({ file_id: fileId, password: password })
+            });
+            const result = await response.json();
+            return !(result.success && result.data && result.data.valid === false);
+        }
+
         async function downloadFile(fileId, fileName) {
             try {
                 const password = (document.getElementById('reassemblyPassword') || {}).value || '';
+                if (!fileId.startsWith('demo-file-') && !(await checkFilePassword(fileId, password))) {
+                    const statusDiv = document.getElementById('reassemblyStatus');
+                    statusDiv.innerHTML = '<div class="status error">❌ Wrong password for ' + fileName + '</div>';
+                    return;
+                }
                 const link = document.createElement('a');
                 link.href = '/api/files/download?file_id=' + encodeURIComponent(fileId) + (password ? '&password=' + encodeURIComponent(password) : '');
                 link.download = fileName;
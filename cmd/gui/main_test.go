@@ -0,0 +1,1337 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/api"
+	"github.com/jaywantadh/DisktroByte/internal/auth"
+	"github.com/jaywantadh/DisktroByte/internal/bundle"
+	"github.com/jaywantadh/DisktroByte/internal/cache"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/dfs"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/storage/s3fake"
+)
+
+func TestProductionModeServesNoDemoData(t *testing.T) {
+	saved := config.Config
+	defer func() { config.Config = saved }()
+
+	endpoints := []struct {
+		name    string
+		method  string
+		role    string
+		handler http.HandlerFunc
+	}{
+		{"available files", http.MethodGet, "user", handleAvailableFiles},
+		{"received files", http.MethodGet, "superadmin", handleReceivedFiles},
+		{"file logs", http.MethodGet, "superadmin", handleFileLogs},
+		{"system logs", http.MethodGet, "admin", handleSystemLogs},
+		{"create sample files", http.MethodPost, "admin", handleCreateSampleFiles},
+	}
+	call := func(handler http.HandlerFunc, method, target, role string) (Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-User-Role", role)
+		req.Header.Set("X-User-ID", "alice")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp, rec.Body.String()
+	}
+	fabricated := []string{"demo-file", "sample_", "confidential_report", "15mb.pdf", "System started successfully"}
+
+	// Outside production the demo fallbacks fill the empty node, sample
+	// files only when asked for
+	config.Config = &config.AppConfig{}
+	if _, body := call(handleAvailableFiles, http.MethodGet, "/", "user"); strings.Contains(body, "demo-file") {
+		t.Fatalf("expected no demo files unless requested, got %s", body)
+	}
+	if _, body := call(handleAvailableFiles, http.MethodGet, "/?demo=true", "user"); !strings.Contains(body, "demo-file") {
+		t.Fatalf("expected demo files on request outside production mode, got %s", body)
+	}
+
+	config.Config = &config.AppConfig{ProductionMode: true}
+	for _, e := range endpoints {
+		resp, body := call(e.handler, e.method, "/?demo=true", e.role)
+		for _, marker := range fabricated {
+			if strings.Contains(body, marker) {
+				t.Errorf("%s: production response contains demo data %q: %s", e.name, marker, body)
+			}
+		}
+		if e.name == "create sample files" {
+			if resp.Success {
+				t.Errorf("expected sample file creation refused in production mode")
+			}
+		} else if !resp.Success {
+			t.Errorf("%s: expected an empty success response, got %q", e.name, resp.Message)
+		}
+	}
+}
+
+func TestSensitiveOperationsNeedARecentPasswordCheck(t *testing.T) {
+	saved, savedAuth := config.Config, authManager
+	defer func() { config.Config, authManager = saved, savedAuth }()
+	config.Config = &config.AppConfig{StepUpOperations: []string{opUserUpdate}}
+	authManager = auth.NewAuthManager(time.Hour, 10)
+	defer authManager.Stop()
+	authManager.SetStepUpWindow(100 * time.Millisecond)
+
+	login, err := authManager.Login(auth.LoginRequest{Username: "admin", Password: "admin123"})
+	if err != nil || !login.Success {
+		t.Fatalf("login failed: %v %+v", err, login)
+	}
+	adminID := login.User.ID
+	call := func(handler http.HandlerFunc, method, body string) (*httptest.ResponseRecorder, Response) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+		rec := httptest.NewRecorder()
+		authMiddleware(handler)(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return rec, resp
+	}
+	updateUser := stepUp(opUserUpdate, handleUsers)
+	roleChange := `{"user_id":"` + adminID + `","updates":{"display_name":"Root"}}`
+
+	// Reads and unlisted operations are never challenged
+	time.Sleep(150 * time.Millisecond)
+	if rec, _ := call(updateUser, http.MethodGet, ""); rec.Code != http.StatusOK {
+		t.Errorf("expected reads to pass without re-authentication, got %d", rec.Code)
+	}
+	if rec, _ := call(stepUp(opPlacement, handleUsers), http.MethodPut, roleChange); rec.Code != http.StatusOK {
+		t.Errorf("expected an operation left out of config to pass, got %d", rec.Code)
+	}
+
+	// The session is still valid, but too old for a sensitive change
+	rec, resp := call(updateUser, http.MethodPut, roleChange)
+	if rec.Code != http.StatusForbidden || rec.Header().Get("X-Reauth-Required") != opUserUpdate || resp.Success {
+		t.Fatalf("expected a re-authentication challenge, got %d %+v", rec.Code, resp)
+	}
+
+	if _, resp := call(handleReauthenticate, http.MethodPost, `{"password":"wrong"}`); resp.Success {
+		t.Fatalf("expected a wrong password refused")
+	}
+	if _, resp := call(handleReauthenticate, http.MethodPost, `{"password":"admin123"}`); !resp.Success {
+		t.Fatalf("re-authentication failed: %s", resp.Message)
+	}
+	rec, resp = call(updateUser, http.MethodPut, roleChange)
+	if rec.Code != http.StatusOK || !resp.Success {
+		t.Fatalf("expected the change accepted after re-authentication, got %d %+v", rec.Code, resp)
+	}
+}
+
+func TestTenantsCannotSeeEachOthersFiles(t *testing.T) {
+	saved, savedMeta, savedCore := config.Config, metaStore, dfsCore
+	defer func() { config.Config, metaStore, dfsCore = saved, savedMeta, savedCore }()
+	config.Config = &config.AppConfig{ProductionMode: true, MultiTenancy: true}
+
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(t.TempDir(), "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(t.TempDir(), "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	defer optimized.Close()
+	dfsCore = &dfs.DFSCore{OptimizedStorage: optimized}
+
+	for tenant, fileID := range map[string]string{"alpha": "alpha-file", "beta": "beta-file"} {
+		if err := metaStore.PutFileMetadataByID(fileID, metadata.FileMetadata{FileName: fileID + ".txt", FileSize: 42}); err != nil {
+			t.Fatalf("failed to store %s: %v", fileID, err)
+		}
+		if err := metaStore.AddFileToTenant(tenant, fileID); err != nil {
+			t.Fatalf("failed to assign %s: %v", fileID, err)
+		}
+		if err := optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: fileID + ".txt", FileSize: 42}); err != nil {
+			t.Fatalf("failed to index %s: %v", fileID, err)
+		}
+	}
+
+	call := func(handler http.HandlerFunc, method, target, body, role, tenant string) (Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User-ID", tenant+"-user")
+		req.Header.Set("X-User-Role", role)
+		req.Header.Set("X-User-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp, rec.Body.String()
+	}
+
+	listings := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+	}{
+		{"file list", handleGetFiles, http.MethodGet, ""},
+		{"available files", handleAvailableFiles, http.MethodGet, ""},
+		{"search", handleMetadataSearch, http.MethodPost, `{"query":"file"}`},
+	}
+	for _, l := range listings {
+		resp, body := call(l.handler, l.method, "/", l.body, "user", "alpha")
+		if !resp.Success || !strings.Contains(body, "alpha-file") {
+			t.Errorf("%s: expected alpha's own file, got %s", l.name, body)
+		}
+		if strings.Contains(body, "beta-file") {
+			t.Errorf("%s: alpha's user can see beta's file: %s", l.name, body)
+		}
+		if _, body := call(l.handler, l.method, "/", l.body, "admin", "alpha"); !strings.Contains(body, "beta-file") {
+			t.Errorf("%s: expected an admin to see across tenants, got %s", l.name, body)
+		}
+	}
+
+	// Another tenant's file answers as if it did not exist
+	resp, _ := call(handleFileDownload, http.MethodGet, "/?file_id=beta-file", "", "user", "alpha")
+	if resp.Success || resp.Message != "File not found" {
+		t.Errorf("expected beta's file hidden from alpha's download, got %q", resp.Message)
+	}
+	resp, _ = call(handleCheckPassword, http.MethodPost, "/", `{"file_id":"beta-file","password":"x"}`, "user", "alpha")
+	if resp.Success || resp.Message != "File not found" {
+		t.Errorf("expected beta's file hidden from alpha's password check, got %q", resp.Message)
+	}
+	if canAccessFile(httptest.NewRequest(http.MethodGet, "/", nil), "beta-file") {
+		t.Errorf("expected a request without a tenant confined to the default tenant")
+	}
+}
+
+func TestAvailableFilesListsWhatIsStored(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist := config.Config, metaStore, store, dfsCore, fileDistributor
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor = saved, savedMeta, savedStore, savedCore, savedDist
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+
+	dir := t.TempDir()
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	defer optimized.Close()
+	dfsCore = &dfs.DFSCore{OptimizedStorage: optimized}
+	fileDistributor = nil
+
+	list := func() []api.File {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/files/available", nil)
+		req.Header.Set("X-User-ID", "alice")
+		req.Header.Set("X-User-Role", "user")
+		rec := httptest.NewRecorder()
+		handleAvailableFiles(rec, req)
+		var resp struct {
+			Success bool
+			Message string
+			Data    struct {
+				Files []api.File `json:"files"`
+			}
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		if !resp.Success || resp.Data.Files == nil {
+			t.Fatalf("expected a file list, got %s", rec.Body.String())
+		}
+		return resp.Data.Files
+	}
+
+	// An empty node lists nothing rather than sample files
+	if files := list(); len(files) != 0 {
+		t.Fatalf("expected no files on an empty node, got %+v", files)
+	}
+
+	upload := func(name string, content []byte) []chunker.ChunkMetadata {
+		t.Helper()
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, content, 0644)
+		chunks, err := chunker.ChunkAndStore(input, "pw", metaStore, store)
+		if err != nil {
+			t.Fatalf("failed to chunk %s: %v", name, err)
+		}
+		return chunks
+	}
+	report := upload("report.txt", bytes.Repeat([]byte("quarterly "), 3*int(chunker.MinChunkSize)/10+1))
+	notes := upload("notes.txt", bytes.Repeat([]byte("meeting notes "), int(chunker.MinChunkSize)/14+1))
+	gone := upload("gone.txt", []byte("soon deleted"))
+	if err := optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: report[0].FileID, FileName: "report.txt", ChunkCount: len(report), OwnerID: "alice"}); err != nil {
+		t.Fatalf("failed to index report.txt: %v", err)
+	}
+	deleted, _ := metaStore.GetFileMetadataByID(gone[0].FileID)
+	deleted.DeletedAt = time.Now().Unix()
+	if err := metaStore.PutFileMetadataByID(gone[0].FileID, deleted); err != nil {
+		t.Fatalf("failed to delete gone.txt: %v", err)
+	}
+	store.(storage.Deleter).Delete(report[1].Path)
+
+	files := list()
+	byName := make(map[string]api.File)
+	for _, f := range files {
+		byName[f.FileName] = f
+	}
+	if len(files) != 2 || byName["gone.txt"].FileID != "" {
+		t.Fatalf("expected report.txt and notes.txt only, got %+v", files)
+	}
+	if f := byName["report.txt"]; f.FileID != report[0].FileID || f.OwnerID != "alice" || f.ChunkCount != len(report) || f.ChunksAvailable != len(report)-1 {
+		t.Errorf("expected report.txt from enhanced metadata with one chunk missing, got %+v", f)
+	}
+	if f := byName["notes.txt"]; f.FileID != notes[0].FileID || f.ChunkCount != len(notes) || f.ChunksAvailable != len(notes) {
+		t.Errorf("expected notes.txt from basic metadata with every chunk present, got %+v", f)
+	}
+}
+
+func TestUploadRecordsTheFileHash(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist, savedCache := config.Config, metaStore, store, dfsCore, fileDistributor, originalCache
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor, originalCache = saved, savedMeta, savedStore, savedCore, savedDist, savedCache
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize, OriginalCacheMode: cache.ModeOff}
+
+	dir := t.TempDir()
+	t.Chdir(dir) // Uploads are staged under ./temp
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	originalCache = newOriginalCache(filepath.Join(dir, "originals"))
+	network := p2p.NewNetworkWithID("node-a", "node-a.test", 7300)
+	fileDistributor = distributor.NewDistributor(network, store, metaStore)
+	fileDistributor.SetReplicaCount(1)
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	dfsCore = dfs.NewDFSCore(nil, nil, nil, store, metaStore)
+	defer dfsCore.Stop() // Closes the optimized storage too
+	dfsCore.OptimizedStorage = optimized
+
+	var content bytes.Buffer
+	for i := 0; content.Len() < 2*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "row %d of the uploaded ledger\n", i)
+	}
+	sum := sha256.Sum256(content.Bytes())
+	want := hex.EncodeToString(sum[:])
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "ledger.csv")
+	part.Write(content.Bytes())
+	form.WriteField("password", "pw")
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/chunk", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-User-ID", "alice")
+	req.Header.Set("X-User-Role", "user")
+	rec := httptest.NewRecorder()
+	handleChunk(rec, req)
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Success {
+		t.Fatalf("upload failed: %s", rec.Body.String())
+	}
+
+	var fileID string
+	for _, f := range fileDistributor.GetAllFiles() {
+		fileID = f.ID
+		if f.Hash != want {
+			t.Errorf("expected the file info hash %s, got %s", want, f.Hash)
+		}
+	}
+	meta, err := dfsCore.OptimizedStorage.LoadFileMetadata(fileID)
+	if err != nil {
+		t.Fatalf("no enhanced metadata for the upload: %v", err)
+	}
+	if meta.FileHash != want {
+		t.Errorf("expected the stored file hash %s, got %s", want, meta.FileHash)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/files/list", nil)
+	rec = httptest.NewRecorder()
+	handleGetFiles(rec, req)
+	if !strings.Contains(rec.Body.String(), `"file_hash":"`+want+`"`) {
+		t.Errorf("expected /api/files/list to show the file hash, got %s", rec.Body.String())
+	}
+}
+
+func TestUploadWritesChunksToTheTierOfItsClass(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist, savedCache := config.Config, metaStore, store, dfsCore, fileDistributor, originalCache
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor, originalCache = saved, savedMeta, savedStore, savedCore, savedDist, savedCache
+	}()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	fake := s3fake.New(t)
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize, OriginalCacheMode: cache.ModeOff,
+		S3Endpoint: fake.Endpoint(), S3AccessKey: s3fake.AccessKey, S3SecretKey: s3fake.SecretKey,
+		StorageTiers: []config.StorageTierConfig{
+			{Name: "ssd", Path: filepath.Join(dir, "ssd"), Classes: []string{storage.ClassHot, storage.ClassWarm}},
+			{Name: "bucket", Backend: storage.BackendS3, S3Bucket: "cold-chunks", Classes: []string{storage.ClassCold, storage.ClassArchive}},
+		}}
+	var err error
+	store, err = newStorageBackend(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("newStorageBackend failed: %v", err)
+	}
+	tiered, ok := store.(*storage.TieredStorage)
+	if !ok {
+		t.Fatalf("expected tiered storage, got %T", store)
+	}
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	originalCache = newOriginalCache(filepath.Join(dir, "originals"))
+	fileDistributor = distributor.NewDistributor(p2p.NewNetworkWithID("node-a", "node-a.test", 7300), store, metaStore)
+	fileDistributor.SetReplicaCount(1)
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	dfsCore = dfs.NewDFSCore(nil, nil, nil, store, metaStore)
+	defer dfsCore.Stop()
+	dfsCore.OptimizedStorage = optimized
+
+	upload := func(name, class string) (string, bool) {
+		t.Helper()
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", name)
+		part.Write(bytes.Repeat([]byte(name+" "), 2*int(chunker.MinChunkSize)/(len(name)+1)+1))
+		form.WriteField("password", "pw")
+		form.WriteField("storage_class", class)
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/chunk", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		handleChunk(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Success {
+			return rec.Body.String(), false
+		}
+		info := resp.Data.(map[string]interface{})["file_info"].(map[string]interface{})
+		return info["id"].(string), true
+	}
+	checkTier := func(fileID, class, tier string) {
+		t.Helper()
+		chunks, err := metaStore.GetChunksByFileID(fileID)
+		if err != nil || len(chunks) == 0 {
+			t.Fatalf("no chunks recorded for %s: %v", fileID, err)
+		}
+		for _, chunk := range chunks {
+			if got, _ := tiered.Locate(chunk.Path); got != tier {
+				t.Errorf("expected chunk %d of the %s upload on tier %s, got %q", chunk.Index, class, tier, got)
+			}
+		}
+		if meta, err := optimized.LoadFileMetadata(fileID); err != nil || meta.StorageClass != class {
+			t.Errorf("expected storage class %s recorded, got %+v, %v", class, meta, err)
+		}
+	}
+
+	coldID, ok := upload("cold.log", storage.ClassCold)
+	if !ok {
+		t.Fatalf("cold upload failed: %s", coldID)
+	}
+	checkTier(coldID, storage.ClassCold, "bucket")
+	if len(fake.Keys("cold-chunks")) == 0 {
+		t.Errorf("expected the cold chunks in the S3 bucket")
+	}
+
+	hotID, ok := upload("hot.log", "")
+	if !ok {
+		t.Fatalf("hot upload failed: %s", hotID)
+	}
+	checkTier(hotID, storage.ClassHot, "ssd")
+
+	if msg, ok := upload("lukewarm.log", "tepid"); ok || !strings.Contains(msg, "invalid storage class") {
+		t.Errorf("expected an unknown storage class rejected, got %s", msg)
+	}
+}
+
+func TestStrictUploadOfUnknownLengthIsSpilledEncrypted(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist, savedCache := config.Config, metaStore, store, dfsCore, fileDistributor, originalCache
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor, originalCache = saved, savedMeta, savedStore, savedCore, savedDist, savedCache
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize, OriginalCacheMode: cache.ModeOff, StrictEncryption: true}
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	spillDir := filepath.Join(dir, "spill")
+	os.MkdirAll(spillDir, 0755)
+	t.Setenv("TMPDIR", spillDir)
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	originalCache = newOriginalCache(filepath.Join(dir, "originals"))
+	network := p2p.NewNetworkWithID("node-a", "node-a.test", 7310)
+	fileDistributor = distributor.NewDistributor(network, store, metaStore)
+	fileDistributor.SetReplicaCount(1)
+	dfsCore = dfs.NewDFSCore(nil, nil, nil, store, metaStore)
+	defer dfsCore.Stop()
+
+	var content bytes.Buffer
+	for i := 0; content.Len() < 2*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "row %d of the streamed ledger\n", i)
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("password", "pw")
+	part, _ := form.CreateFormFile("file", "ledger.csv")
+	part.Write(content.Bytes())
+	form.Close()
+
+	// A chunked request body has no length, so the whole form cannot be known to fit in memory
+	req := httptest.NewRequest(http.MethodPost, "/api/chunk", io.NopCloser(&body))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-User-ID", "alice")
+	req.Header.Set("X-User-Role", "user")
+	rec := httptest.NewRecorder()
+	handleChunk(rec, req)
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Success {
+		t.Fatalf("expected the upload streamed rather than refused: %s", rec.Body.String())
+	}
+
+	files := fileDistributor.GetAllFiles()
+	if len(files) != 1 {
+		t.Fatalf("expected one stored file, got %d", len(files))
+	}
+	var out bytes.Buffer
+	for _, f := range files {
+		if err := chunker.ReassembleToWriter(f.ID, &out, "pw", metaStore, store); err != nil {
+			t.Fatalf("failed to read the upload back: %v", err)
+		}
+	}
+	if !bytes.Equal(out.Bytes(), content.Bytes()) {
+		t.Fatal("the stored file does not match the upload")
+	}
+	if entries, _ := os.ReadDir(spillDir); len(entries) != 0 {
+		t.Errorf("expected the spill file removed after the upload, found %d", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "temp")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing staged in ./temp in strict mode")
+	}
+}
+
+func TestBundleImportIsNotCutOffByTheBodyCap(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore := config.Config, metaStore, store, dfsCore
+	defer func() { config.Config, metaStore, store, dfsCore = saved, savedMeta, savedStore, savedCore }()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	dfsCore = nil
+
+	dir := t.TempDir()
+	source, err := metadata.OpenMetadataStore(filepath.Join(dir, "source-meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer source.Close()
+	sourceStore, err := storage.NewLocalStorage(filepath.Join(dir, "source-chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	var content bytes.Buffer
+	for i := 0; content.Len() < 3*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "line %d of a bundled file\n", i)
+	}
+	input := filepath.Join(dir, "bundled.txt")
+	os.WriteFile(input, content.Bytes(), 0644)
+	chunks, err := chunker.ChunkAndStore(input, "pw", source, sourceStore)
+	if err != nil {
+		t.Fatalf("failed to chunk input: %v", err)
+	}
+	var bundled bytes.Buffer
+	if _, err := bundle.Export(&bundled, chunks[0].FileID, source, sourceStore, nil); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	// A cap well below the bundle's size, as the default cap is for large files
+	limit := int64(bundled.Len() / 4)
+	post := func(handler http.Handler) Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/files/import", bytes.NewReader(bundled.Bytes()))
+		req.Header.Set("X-User-ID", "root")
+		req.Header.Set("X-User-Role", "admin")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp
+	}
+	if resp := post(api.LimitBodies(http.HandlerFunc(handleFileImport), limit)); resp.Success {
+		t.Fatal("expected the capped import to fail, so the test bundle is over the cap")
+	}
+	if resp := post(api.LimitBodies(http.HandlerFunc(handleFileImport), limit, fileBodyPaths...)); !resp.Success {
+		t.Fatalf("expected a bundle over the body cap imported, got %q", resp.Message)
+	}
+	var out bytes.Buffer
+	if err := chunker.ReassembleToWriter(chunks[0].FileID, &out, "pw", metaStore, store); err != nil || !bytes.Equal(out.Bytes(), content.Bytes()) {
+		t.Fatalf("expected the imported file to reassemble, err %v", err)
+	}
+}
+
+func TestDeletedFilesLeaveListingsAndSearch(t *testing.T) {
+	saved, savedMeta, savedCore := config.Config, metaStore, dfsCore
+	defer func() { config.Config, metaStore, dfsCore = saved, savedMeta, savedCore }()
+	config.Config = &config.AppConfig{ProductionMode: true}
+
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(t.TempDir(), "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err := storage.NewLocalStorage(filepath.Join(t.TempDir(), "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(t.TempDir(), "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	defer optimized.Close()
+	dfsCore = dfs.NewDFSCore(nil, nil, nil, store, metaStore)
+	dfsCore.OptimizedStorage = optimized
+
+	for owner, fileID := range map[string]string{"alice": "alice-file", "bob": "bob-file"} {
+		if err := metaStore.PutFileMetadataByID(fileID, metadata.FileMetadata{FileName: fileID + ".txt", FileSize: 42}); err != nil {
+			t.Fatalf("failed to store %s: %v", fileID, err)
+		}
+		if err := optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: fileID + ".txt", FileSize: 42, OwnerID: owner}); err != nil {
+			t.Fatalf("failed to index %s: %v", fileID, err)
+		}
+	}
+
+	call := func(handler http.HandlerFunc, method, target, body, user, role string) (Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User-ID", user)
+		req.Header.Set("X-User-Role", role)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp, rec.Body.String()
+	}
+
+	if resp, _ := call(handleFileDelete, http.MethodGet, "/api/files/alice-file", "", "alice", "user"); resp.Success {
+		t.Errorf("expected only DELETE accepted")
+	}
+	if resp, _ := call(handleFileDelete, http.MethodDelete, "/api/files/alice-file", "", "bob", "user"); resp.Success {
+		t.Errorf("expected bob refused deleting alice's file")
+	}
+	if resp, _ := call(handleFileDelete, http.MethodDelete, "/api/files/no-such-file", "", "alice", "admin"); resp.Success || resp.Message != "File not found" {
+		t.Errorf("expected an unknown file reported missing, got %q", resp.Message)
+	}
+
+	resp, _ := call(handleFileDelete, http.MethodDelete, "/api/files/alice-file", "", "alice", "user")
+	if !resp.Success {
+		t.Fatalf("soft delete failed: %s", resp.Message)
+	}
+	for _, l := range []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+	}{
+		{"file list", handleGetFiles, http.MethodGet, ""},
+		{"search", handleMetadataSearch, http.MethodPost, `{"query":"file"}`},
+	} {
+		_, body := call(l.handler, l.method, "/", l.body, "alice", "user")
+		if strings.Contains(body, "alice-file") || !strings.Contains(body, "bob-file") {
+			t.Errorf("%s: expected only bob's file after the delete, got %s", l.name, body)
+		}
+	}
+	if _, err := metaStore.GetFileMetadataByID("alice-file"); err != nil {
+		t.Errorf("expected a soft-deleted file kept until it is purged: %v", err)
+	}
+
+	resp, _ = call(handleFileDelete, http.MethodDelete, "/api/files/alice-file?hard=true", "", "root", "admin")
+	if !resp.Success {
+		t.Fatalf("hard delete failed: %s", resp.Message)
+	}
+	if _, err := metaStore.GetFileMetadataByID("alice-file"); err == nil {
+		t.Errorf("expected a hard delete to remove the file's metadata")
+	}
+	if _, err := optimized.LoadFileMetadata("alice-file"); err == nil {
+		t.Errorf("expected a hard delete to remove the file's enhanced metadata")
+	}
+}
+
+func TestReassembleRebuildsAChunkedFile(t *testing.T) {
+	saved, savedMeta, savedStore, savedDist, savedReassembler, savedLimiter := config.Config, metaStore, store, fileDistributor, fileReassembler, downloadLimiter
+	defer func() {
+		config.Config, metaStore, store, fileDistributor, fileReassembler, downloadLimiter = saved, savedMeta, savedStore, savedDist, savedReassembler, savedLimiter
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	downloadLimiter = api.NewDownloadLimiter(api.DownloadLimitsFromConfig())
+
+	dir := t.TempDir()
+	// Output paths are on the server, relative to its working directory
+	t.Chdir(dir)
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	fileDistributor, fileReassembler = nil, nil
+
+	var content bytes.Buffer
+	for i := 0; content.Len() < 3*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "line %d of a file to reassemble\n", i)
+	}
+	input := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(input, content.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	want := sha256.Sum256(content.Bytes())
+	matches := func(path string) bool {
+		data, err := os.ReadFile(path)
+		return err == nil && sha256.Sum256(data) == want
+	}
+	callAs := func(role, body string) Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/files/reassemble", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "alice")
+		req.Header.Set("X-User-Role", role)
+		rec := httptest.NewRecorder()
+		handleReassemble(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp
+	}
+	call := func(body string) Response {
+		t.Helper()
+		return callAs("user", body)
+	}
+
+	// Without the DFS reassembler the file is rebuilt from local chunks before the response
+	chunks, err := chunker.ChunkAndStore(input, "pw", metaStore, store)
+	if err != nil {
+		t.Fatalf("failed to chunk input: %v", err)
+	}
+	fileID := chunks[0].FileID
+	output := filepath.Join(reassembledDir, "out", "report.txt")
+	if resp := call(`{"file_id":"` + fileID + `","output_path":"` + output + `"}`); resp.Success || resp.Message != "Password is required" {
+		t.Errorf("expected a missing password refused, got %q", resp.Message)
+	}
+	if resp := call(`{"file_id":"no-such-file","output_path":"` + output + `","password":"pw"}`); resp.Success || resp.Message != "File not found" {
+		t.Errorf("expected an unknown file reported missing, got %q", resp.Message)
+	}
+	if resp := call(`{"file_id":"` + fileID + `","output_path":"` + output + `","password":"wrong"}`); resp.Success || resp.Message != "Incorrect password" {
+		t.Errorf("expected a wrong password refused, got %q", resp.Message)
+	}
+	if resp := call(`{"file_id":"` + fileID + `","output_path":"` + output + `","password":"pw"}`); !resp.Success {
+		t.Fatalf("reassembly failed: %s", resp.Message)
+	}
+	if !matches(output) {
+		t.Fatal("reassembled file does not match the original")
+	}
+
+	// Users cannot write outside the reassembly directory; admins can
+	outside := filepath.Join(dir, "outside.txt")
+	for _, path := range []string{outside, "../outside.txt", reassembledDir + "/../outside.txt", reassembledDir + "/out/../../../outside.txt", reassembledDir} {
+		if resp := call(`{"file_id":"` + fileID + `","output_path":"` + path + `","password":"pw"}`); resp.Success || !strings.HasPrefix(resp.Message, "Invalid output path") {
+			t.Errorf("expected output path %q refused, got %q", path, resp.Message)
+		}
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written outside the reassembly directory, got %v", err)
+	}
+	if resp := callAs("admin", `{"file_id":"`+fileID+`","output_path":"`+outside+`","password":"pw"}`); !resp.Success || !matches(outside) {
+		t.Fatalf("expected an admin to reassemble to any path, got %q", resp.Message)
+	}
+
+	// A file the distributor knows is rebuilt by a job the client can poll
+	network := p2p.NewNetworkWithID("node-a", "node-a.test", 7200)
+	fileDistributor = distributor.NewDistributor(network, store, metaStore)
+	fileDistributor.SetReplicaCount(1)
+	core := dfs.NewDFSCore(nil, nil, nil, store, metaStore)
+	defer core.Stop()
+	fileReassembler = dfs.NewFileReassembler(core, fileDistributor, store, metaStore, network)
+	copied := filepath.Join(dir, "copy.txt")
+	os.WriteFile(copied, append(content.Bytes(), "and one more line\n"...), 0644)
+	file, err := fileDistributor.DistributeFile(copied, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	want = sha256.Sum256(append(content.Bytes(), "and one more line\n"...))
+	jobOutput := filepath.Join(reassembledDir, "out", "copy.txt")
+	resp := call(`{"file_id":"` + file.ID + `","output_path":"` + jobOutput + `","password":"pw"}`)
+	if !resp.Success || resp.Message != "File reassembly started" {
+		t.Fatalf("expected a reassembly job, got %q", resp.Message)
+	}
+	if job, _ := resp.Data.(map[string]interface{}); job == nil || job["id"] == "" || job["file_id"] != file.ID {
+		t.Errorf("expected the job returned, got %v", resp.Data)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !matches(jobOutput) {
+		if time.Now().After(deadline) {
+			t.Fatal("the reassembly job never produced the original file")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Missing chunks are reported before any job starts
+	for _, chunk := range chunks {
+		store.(storage.Deleter).Delete(chunk.Path)
+	}
+	if resp := call(`{"file_id":"` + fileID + `","output_path":"` + output + `","password":"pw"}`); resp.Success || !strings.Contains(resp.Message, "chunks are missing") {
+		t.Errorf("expected missing chunks reported, got %q", resp.Message)
+	}
+}
+
+func TestServerManagedFilesAreReadOnlyByTheirOwnerOrAnAdmin(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist, savedCache, savedKM := config.Config, metaStore, store, dfsCore, fileDistributor, originalCache, serverKeyManager
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor, originalCache, serverKeyManager = saved, savedMeta, savedStore, savedCore, savedDist, savedCache, savedKM
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize, OriginalCacheMode: cache.ModeOff}
+
+	dir := t.TempDir()
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	originalCache = newOriginalCache(filepath.Join(dir, "originals"))
+	serverKeyManager, err = encryptor.NewKeyManager(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	fileDistributor = distributor.NewDistributor(p2p.NewNetworkWithID("node-a", "node-a.test", 7400), store, metaStore)
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	defer optimized.Close()
+	dfsCore = &dfs.DFSCore{OptimizedStorage: optimized}
+
+	content := bytes.Repeat([]byte("service payroll export\n"), 4000)
+	input := filepath.Join(dir, "payroll.csv")
+	if err := os.WriteFile(input, content, 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	chunks, err := chunker.ChunkAndStoreWithServerKey(input, serverKeyManager, metaStore, store)
+	if err != nil {
+		t.Fatalf("failed to chunk input: %v", err)
+	}
+	fileID := chunks[0].FileID
+	if err := optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: "payroll.csv", FileSize: int64(len(content)), OwnerID: "alice"}); err != nil {
+		t.Fatalf("failed to index the file: %v", err)
+	}
+
+	call := func(handler http.HandlerFunc, target, user, role string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-User-ID", user)
+		req.Header.Set("X-User-Role", role)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// No password is asked for, so another user is refused outright
+	for _, target := range []string{"/api/files/download?file_id=" + fileID, "/api/files/download?file_id=" + fileID + "&version=1"} {
+		if rec := call(handleFileDownload, target, "bob", "user"); rec.Code != http.StatusForbidden || bytes.Contains(rec.Body.Bytes(), content[:64]) {
+			t.Errorf("expected bob refused with 403 on %s, got %d: %.100s", target, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := call(handleFilePreview, "/api/files/preview?file_id="+fileID, "bob", "user"); rec.Code != http.StatusForbidden {
+		t.Errorf("expected bob refused a preview with 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, who := range []struct{ user, role string }{{"alice", "user"}, {"root", "admin"}} {
+		rec := call(handleFileDownload, "/api/files/download?file_id="+fileID, who.user, who.role)
+		if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), content) {
+			t.Errorf("expected %s to download the file, got %d: %.100s", who.user, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestOnlyTheOwnerCanUpdateAFileWithItsPassword(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedCache := config.Config, metaStore, store, dfsCore, originalCache
+	defer func() {
+		config.Config, metaStore, store, dfsCore, originalCache = saved, savedMeta, savedStore, savedCore, savedCache
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize, OriginalCacheMode: cache.ModeOff}
+
+	dir := t.TempDir()
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	originalCache = newOriginalCache(filepath.Join(dir, "originals"))
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	defer optimized.Close()
+	dfsCore = &dfs.DFSCore{OptimizedStorage: optimized}
+
+	input := filepath.Join(dir, "budget.xlsx")
+	if err := os.WriteFile(input, bytes.Repeat([]byte("first draft\n"), 2000), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	chunks, err := chunker.ChunkAndStore(input, "pw", metaStore, store)
+	if err != nil {
+		t.Fatalf("failed to chunk input: %v", err)
+	}
+	fileID := chunks[0].FileID
+	if err := optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: "budget.xlsx", OwnerID: "alice"}); err != nil {
+		t.Fatalf("failed to index the file: %v", err)
+	}
+
+	update := func(user, password string) Response {
+		t.Helper()
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("file_id", fileID)
+		form.WriteField("password", password)
+		part, _ := form.CreateFormFile("file", "budget.xlsx")
+		part.Write(bytes.Repeat([]byte("second draft\n"), 2000))
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/files/update", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("X-User-ID", user)
+		req.Header.Set("X-User-Role", "user")
+		rec := httptest.NewRecorder()
+		handleFileUpdate(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := update("bob", "bobs-password"); resp.Success || !strings.Contains(resp.Message, "owner") {
+		t.Errorf("expected bob refused an update of alice's file, got %q", resp.Message)
+	}
+	if resp := update("alice", "wrong"); resp.Success || resp.Message != "Incorrect password" {
+		t.Errorf("expected an update under the wrong password refused, got %q", resp.Message)
+	}
+	if meta, _ := metaStore.GetFileMetadataByID(fileID); meta.Version > 1 {
+		t.Fatalf("a refused update wrote version %d", meta.Version)
+	}
+	if resp := update("alice", "pw"); !resp.Success {
+		t.Fatalf("expected the owner's update to succeed, got %q", resp.Message)
+	}
+	if err := chunker.CheckPassword(fileID, "pw", metaStore, store); err != nil {
+		t.Errorf("expected the new version readable with the file's password, got %v", err)
+	}
+}
+
+func TestDownloadingAColdFilePromotesItBackToHot(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist, savedCache := config.Config, metaStore, store, dfsCore, fileDistributor, originalCache
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor, originalCache = saved, savedMeta, savedStore, savedCore, savedDist, savedCache
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize, OriginalCacheMode: cache.ModeOff}
+
+	dir := t.TempDir()
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	hot, err := storage.NewLocalStorage(filepath.Join(dir, "hot"))
+	if err != nil {
+		t.Fatalf("failed to create hot tier: %v", err)
+	}
+	cold, err := storage.NewLocalStorage(filepath.Join(dir, "cold"))
+	if err != nil {
+		t.Fatalf("failed to create cold tier: %v", err)
+	}
+	tiered, err := storage.NewTieredStorage(
+		storage.Tier{Name: "ssd", Backend: hot, Classes: []string{storage.ClassHot, storage.ClassWarm}},
+		storage.Tier{Name: "archive-disk", Backend: cold, Classes: []string{storage.ClassCold, storage.ClassArchive}},
+	)
+	if err != nil {
+		t.Fatalf("failed to create tiered storage: %v", err)
+	}
+	store = tiered
+	originalCache = newOriginalCache(filepath.Join(dir, "originals"))
+	fileDistributor = distributor.NewDistributor(p2p.NewNetworkWithID("node-a", "node-a.test", 7500), store, metaStore)
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	defer optimized.Close()
+	dfsCore = &dfs.DFSCore{OptimizedStorage: optimized}
+
+	content := bytes.Repeat([]byte("quarterly archive row\n"), 3000)
+	input := filepath.Join(dir, "archive.csv")
+	if err := os.WriteFile(input, content, 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	chunks, err := chunker.ChunkAndStore(input, "pw", metaStore, store)
+	if err != nil {
+		t.Fatalf("failed to chunk input: %v", err)
+	}
+	fileID := chunks[0].FileID
+	chunkPaths := func(id string) []string {
+		stored, _ := metaStore.GetChunksByFileID(id)
+		paths := make([]string, 0, len(stored))
+		for _, chunk := range stored {
+			paths = append(paths, chunk.Path)
+		}
+		return paths
+	}
+
+	// The file went cold after two months without a read
+	for _, path := range chunkPaths(fileID) {
+		if err := tiered.MigrateToClass(path, storage.ClassCold); err != nil {
+			t.Fatalf("failed to move chunk %s to the cold tier: %v", path, err)
+		}
+	}
+	longAgo := time.Now().Add(-60 * 24 * time.Hour)
+	if err := optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: "archive.csv", OwnerID: "alice",
+		StorageClass: storage.ClassCold, CreatedAt: longAgo, AccessedAt: longAgo}); err != nil {
+		t.Fatalf("failed to index the file: %v", err)
+	}
+	optimized.SetTiering(dfs.TieringPolicy{WarmAfter: 7 * 24 * time.Hour, ColdAfter: 30 * 24 * time.Hour}, tiered, chunkPaths)
+	if report, err := optimized.RunTieringPass(); err != nil || report.Promoted+report.Demoted != 0 {
+		t.Fatalf("expected an unread cold file left alone, got %+v, %v", report, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/files/download?file_id="+fileID+"&password=pw", nil)
+	req.Header.Set("X-User-ID", "alice")
+	req.Header.Set("X-User-Role", "user")
+	rec := httptest.NewRecorder()
+	handleFileDownload(rec, req)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), content) {
+		t.Fatalf("download failed: %d %.200s", rec.Code, rec.Body.String())
+	}
+
+	meta, err := optimized.LoadFileMetadata(fileID)
+	if err != nil || meta.AccessCount != 1 || !meta.AccessedAt.After(longAgo) {
+		t.Fatalf("expected the download recorded as an access, got %+v, %v", meta, err)
+	}
+	report, err := optimized.RunTieringPass()
+	if err != nil || report.Promoted != 1 {
+		t.Fatalf("expected the downloaded file promoted, got %+v, %v", report, err)
+	}
+	if meta, _ := optimized.LoadFileMetadata(fileID); meta.StorageClass != storage.ClassHot {
+		t.Errorf("expected the file back in the hot class, got %q", meta.StorageClass)
+	}
+	for _, path := range chunkPaths(fileID) {
+		if tier, _ := tiered.Locate(path); tier != "ssd" {
+			t.Errorf("expected chunk %s back on the hot tier, got %q", path, tier)
+		}
+	}
+}
+
+func TestReceivedFilesUseTheCanonicalShape(t *testing.T) {
+	saved, savedNetwork, savedDist := config.Config, network, fileDistributor
+	defer func() { config.Config, network, fileDistributor = saved, savedNetwork, savedDist }()
+	config.Config = &config.AppConfig{ProductionMode: true}
+
+	network = p2p.NewNetworkWithID("node-a", "node-a.test", 7320)
+	fileDistributor = distributor.NewDistributor(network, nil, nil)
+
+	// A peer announces a file it holds
+	announcement, _ := json.Marshal(&p2p.NetworkMessage{
+		Type: distributor.MessageFileAvailable,
+		From: "node-b",
+		Data: distributor.FileAnnouncement{File: &distributor.FileInfo{
+			ID: "d00d", Name: "backup.zip", Size: 8192, Chunks: []string{"c1", "c2"},
+			Owner: "node-b", Encrypted: true, Nodes: []string{"node-b"}, CreatedAt: time.Now(),
+		}},
+	})
+	rec := httptest.NewRecorder()
+	fileDistributor.HandleMessage(rec, httptest.NewRequest(http.MethodPost, "/message", bytes.NewReader(announcement)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("announcement refused: %d %s", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/files/received", nil)
+	req.Header.Set("X-User-Role", "superadmin")
+	rec = httptest.NewRecorder()
+	handleReceivedFiles(rec, req)
+	var resp struct {
+		Success bool
+		Data    struct {
+			Files     []map[string]interface{} `json:"files"`
+			TotalSize int64                    `json:"total_size"`
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Success {
+		t.Fatalf("expected the received files, got %s (%v)", rec.Body.String(), err)
+	}
+	if len(resp.Data.Files) != 1 || resp.Data.TotalSize != 8192 {
+		t.Fatalf("expected the announced file of 8192 bytes, got %s", rec.Body.String())
+	}
+
+	// The listing carries every key of the canonical file, plus where it came from
+	shape, _ := json.Marshal(api.FromFileInfo(&distributor.FileInfo{}))
+	var keys map[string]interface{}
+	json.Unmarshal(shape, &keys)
+	file := resp.Data.Files[0]
+	for key := range keys {
+		if _, ok := file[key]; !ok {
+			t.Errorf("received file lacks the canonical key %q: %v", key, file)
+		}
+	}
+	if file["file_id"] != "d00d" || file["sender_node"] != "node-b" || file["status"] != api.StatusStored {
+		t.Errorf("unexpected received file: %v", file)
+	}
+}
+
+func TestDFSReassembleRefusesUnavailableFiles(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist, savedReassembler, savedLimiter := config.Config, metaStore, store, dfsCore, fileDistributor, fileReassembler, downloadLimiter
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor, fileReassembler, downloadLimiter = saved, savedMeta, savedStore, savedCore, savedDist, savedReassembler, savedLimiter
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	downloadLimiter = api.NewDownloadLimiter(api.DownloadLimitsFromConfig())
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	network := p2p.NewNetworkWithID("node-a", "node-a.test", 7330)
+	fileDistributor = distributor.NewDistributor(network, store, metaStore)
+	fileDistributor.SetReplicaCount(1)
+	defer fileDistributor.WaitForReplication()
+	dfsCore = dfs.NewDFSCore(nil, nil, nil, store, metaStore)
+	defer dfsCore.Stop()
+	fileReassembler = dfs.NewFileReassembler(dfsCore, fileDistributor, store, metaStore, network)
+
+	upload := func(name string) string {
+		t.Helper()
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, []byte("contents of "+name), 0644)
+		file, err := fileDistributor.DistributeFile(input, "pw")
+		if err != nil {
+			t.Fatalf("DistributeFile of %s failed: %v", name, err)
+		}
+		return file.ID
+	}
+	reassemble := func(fileID string) Response {
+		t.Helper()
+		body := `{"file_id":"` + fileID + `","output_path":"` + filepath.Join(reassembledDir, fileID) + `","password":"pw"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/dfs/reassemble", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "alice")
+		req.Header.Set("X-User-Role", "user")
+		rec := httptest.NewRecorder()
+		handleDFSReassemble(rec, req)
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := reassemble(upload("kept.txt")); !resp.Success {
+		t.Fatalf("expected an available file reassembled, got %q", resp.Message)
+	}
+
+	// An ephemeral file past its TTL is gone even before the expiry sweep purges it
+	expired := upload("expired.txt")
+	meta, _ := metaStore.GetFileMetadataByID(expired)
+	meta.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	metaStore.PutFileMetadataByID(expired, meta)
+	if resp := reassemble(expired); resp.Success || resp.Message != "File not found" {
+		t.Errorf("expected an expired file refused, got %q", resp.Message)
+	}
+
+	// A soft-deleted file keeps its chunks until the purge, but can no longer be read
+	deleted := upload("deleted.txt")
+	req := httptest.NewRequest(http.MethodDelete, "/api/files/"+deleted, nil)
+	req.Header.Set("X-User-Role", "admin")
+	rec := httptest.NewRecorder()
+	handleFileDelete(rec, req)
+	if !strings.Contains(rec.Body.String(), "soft-deleted") {
+		t.Fatalf("expected the file soft-deleted, got %s", rec.Body.String())
+	}
+	if resp := reassemble(deleted); resp.Success || resp.Message != "File not found" {
+		t.Errorf("expected a soft-deleted file refused, got %q", resp.Message)
+	}
+}
+
+func TestDFSReassemblyOfAServerManagedFileIsForItsOwner(t *testing.T) {
+	saved, savedMeta, savedStore, savedCore, savedDist, savedReassembler, savedLimiter, savedKM := config.Config, metaStore, store, dfsCore, fileDistributor, fileReassembler, downloadLimiter, serverKeyManager
+	defer func() {
+		config.Config, metaStore, store, dfsCore, fileDistributor, fileReassembler, downloadLimiter, serverKeyManager = saved, savedMeta, savedStore, savedCore, savedDist, savedReassembler, savedLimiter, savedKM
+	}()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	downloadLimiter = api.NewDownloadLimiter(api.DownloadLimitsFromConfig())
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	var err error
+	metaStore, err = metadata.OpenMetadataStore(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store, err = storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	serverKeyManager, err = encryptor.NewKeyManager(bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+	network := p2p.NewNetworkWithID("node-a", "node-a.test", 7340)
+	fileDistributor = distributor.NewDistributor(network, store, metaStore)
+	fileDistributor.SetReplicaCount(1)
+	fileDistributor.SetKeyManager(serverKeyManager)
+	defer fileDistributor.WaitForReplication()
+	optimized, err := dfs.NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to open optimized storage: %v", err)
+	}
+	defer optimized.Close()
+	dfsCore = &dfs.DFSCore{OptimizedStorage: optimized}
+	fileReassembler = dfs.NewFileReassembler(dfsCore, fileDistributor, store, metaStore, network)
+	fileReassembler.SetKeyManager(serverKeyManager)
+
+	// Uploaded without a password, so the server's key encrypts it
+	var content bytes.Buffer
+	for i := 0; content.Len() < 3*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "line %d of a payroll export\n", i)
+	}
+	input := filepath.Join(dir, "payroll.csv")
+	os.WriteFile(input, content.Bytes(), 0644)
+	file, err := fileDistributor.DistributeFile(input, "")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if err := optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: file.ID, FileName: "payroll.csv", FileSize: int64(content.Len()), OwnerID: "alice"}); err != nil {
+		t.Fatalf("failed to index the file: %v", err)
+	}
+
+	reassemble := func(user string) *httptest.ResponseRecorder {
+		t.Helper()
+		body := `{"file_id":"` + file.ID + `","output_path":"` + filepath.Join(reassembledDir, user+".csv") + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/dfs/reassemble", strings.NewReader(body))
+		req.Header.Set("X-User-ID", user)
+		req.Header.Set("X-User-Role", "user")
+		rec := httptest.NewRecorder()
+		handleDFSReassemble(rec, req)
+		return rec
+	}
+
+	if rec := reassemble("bob"); rec.Code != http.StatusForbidden {
+		t.Errorf("expected bob refused with 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(reassembledDir, "bob.csv")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing reassembled for bob, got %v", err)
+	}
+
+	// The owner's job decrypts the chunks with the file's data key
+	if rec := reassemble("alice"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "File reassembly started") {
+		t.Fatalf("expected alice's reassembly started, got %d: %s", rec.Code, rec.Body.String())
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got, err := os.ReadFile(filepath.Join(reassembledDir, "alice.csv"))
+		jobs := fileReassembler.GetJobHistory()
+		if len(jobs) > 0 && jobs[len(jobs)-1].Status == "completed" && err == nil && bytes.Equal(got, content.Bytes()) {
+			break
+		}
+		if len(jobs) > 0 && jobs[len(jobs)-1].Status == "failed" {
+			t.Fatalf("reassembly failed: %s", jobs[len(jobs)-1].ErrorMessage)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the reassembly job never produced the original file")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/api"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// Multi-tenancy scopes the file catalog to the requesting user's tenant.
+// Chunk storage stays shared, so identical content is still stored once;
+// only the catalog entries that list, search and open files are isolated.
+
+// tenancyEnabled reports whether catalogs are isolated per tenant
+func tenancyEnabled() bool {
+	return config.Config.MultiTenancy
+}
+
+// requestTenant returns the tenant of the authenticated user
+func requestTenant(r *http.Request) string {
+	if tenant := r.Header.Get("X-User-Tenant"); tenant != "" {
+		return tenant
+	}
+	return metadata.DefaultTenant
+}
+
+// crossesTenants reports whether the user may act outside their own tenant
+func crossesTenants(r *http.Request) bool {
+	role := r.Header.Get("X-User-Role")
+	return role == "admin" || role == "superadmin"
+}
+
+// scopeTenant returns the tenant a request is confined to, or all=true when
+// it sees every tenant: with tenancy off, or for admins not narrowing with ?tenant=
+func scopeTenant(r *http.Request) (tenant string, all bool) {
+	if !tenancyEnabled() {
+		return "", true
+	}
+	if crossesTenants(r) {
+		if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+			return tenant, false
+		}
+		return "", true
+	}
+	return requestTenant(r), false
+}
+
+// visibleFileIDs returns the files a request may see, nil when it sees all
+func visibleFileIDs(r *http.Request) (map[string]bool, error) {
+	tenant, all := scopeTenant(r)
+	if all {
+		return nil, nil
+	}
+	if metaStore == nil {
+		return map[string]bool{}, nil
+	}
+	return metaStore.TenantFileIDs(tenant)
+}
+
+// canAccessFile reports whether a file is in the request's namespace
+func canAccessFile(r *http.Request, fileID string) bool {
+	if !tenancyEnabled() || crossesTenants(r) {
+		return true
+	}
+	if metaStore == nil {
+		return false
+	}
+	in, err := metaStore.FileInTenant(requestTenant(r), fileID)
+	return err == nil && in
+}
+
+// requireFileAccess answers as if the file did not exist when it belongs to
+// another tenant, so its existence is not disclosed, and reports whether the
+// handler may go on
+func requireFileAccess(w http.ResponseWriter, r *http.Request, fileID string) bool {
+	if canAccessFile(r, fileID) {
+		return true
+	}
+	sendJSONResponse(w, false, "File not found", nil)
+	return false
+}
+
+// filterVisibleFiles drops files outside the request's namespace
+func filterVisibleFiles(r *http.Request, files []api.File) ([]api.File, error) {
+	visible, err := visibleFileIDs(r)
+	if err != nil || visible == nil {
+		return files, err
+	}
+	kept := make([]api.File, 0, len(files))
+	for _, file := range files {
+		if visible[file.FileID] {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+// assignTenant records a stored file in the uploader's tenant
+func assignTenant(r *http.Request, fileID string) error {
+	if !tenancyEnabled() || metaStore == nil {
+		return nil
+	}
+	return metaStore.AddFileToTenant(requestTenant(r), fileID)
+}
+
+// checkTenantQuota refuses an upload that would take the uploader's tenant past its quota
+func checkTenantQuota(r *http.Request, incoming int64) error {
+	quota := config.Config.TenantQuotaBytes
+	if !tenancyEnabled() || quota <= 0 || metaStore == nil {
+		return nil
+	}
+	tenant := requestTenant(r)
+	_, used, err := metaStore.TenantUsage(tenant)
+	if err != nil {
+		return fmt.Errorf("failed to compute usage of tenant %s: %v", tenant, err)
+	}
+	if used+incoming > quota {
+		return fmt.Errorf("tenant %s would exceed its quota: %d of %d bytes used, upload is %d bytes", tenant, used, quota, incoming)
+	}
+	return nil
+}
+
+// handleTenants reports the caller's tenant and its usage; admins see every tenant
+func handleTenants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONResponse(w, false, "Method not allowed", nil)
+		return
+	}
+	if metaStore == nil {
+		sendJSONResponse(w, false, "Metadata store not available", nil)
+		return
+	}
+
+	tenants := []string{requestTenant(r)}
+	if crossesTenants(r) {
+		listed, err := metaStore.ListTenants()
+		if err != nil {
+			sendJSONResponse(w, false, "Failed to list tenants: "+err.Error(), nil)
+			return
+		}
+		tenants = append([]string{metadata.DefaultTenant}, listed...)
+	}
+
+	usage := make([]map[string]interface{}, 0, len(tenants))
+	seen := make(map[string]bool)
+	for _, tenant := range tenants {
+		if seen[tenant] {
+			continue
+		}
+		seen[tenant] = true
+		files, bytes, err := metaStore.TenantUsage(tenant)
+		if err != nil {
+			sendJSONResponse(w, false, "Failed to compute tenant usage: "+err.Error(), nil)
+			return
+		}
+		usage = append(usage, map[string]interface{}{
+			"tenant":      tenant,
+			"files":       files,
+			"bytes":       bytes,
+			"quota_bytes": config.Config.TenantQuotaBytes,
+		})
+	}
+	sendJSONResponse(w, true, "Tenants retrieved", map[string]interface{}{
+		"enabled": tenancyEnabled(),
+		"tenant":  requestTenant(r),
+		"tenants": usage,
+	})
+}
@@ -1,45 +1,511 @@
-package config
-
-import (
-	"fmt"
-	"log"
-
-	"github.com/spf13/viper"
-)
-
-// AppConfig holds the application-level configuration
-type AppConfig struct {
-	NodeID           string `mapstructure:"node_id"`
-	Port             int    `mapstructure:"port"`
-	StoragePath      string `mapstructure:"storage_path"`
-	ParallelismRatio int    `mapstructure:"parallelism_ratio"`
-}
-
-var Config *AppConfig
-
-
-func LoadConfig(path string) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(path)
-	viper.AutomaticEnv()
-
-	viper.SetDefault("node_id", "disktrobyte-default-node")
-	viper.SetDefault("port", 8080)
-	viper.SetDefault("storage_path", "./data")
-	viper.SetDefault("parallelism_ratio", 2)
-
-	if err := viper.ReadInConfig(); err != nil {
-		log.Printf("⚠️ Could not read config file, using defaults: %v", err)
-	}
-
-	var appConfig AppConfig
-	if err := viper.Unmarshal(&appConfig); err != nil {
-		log.Fatalf("❌ Unable to decode config into struct: %v", err)
-	}
-
-	Config = &appConfig
-
-	fmt.Println("✅ Configuration loaded successfully.")
-}
-	
\ No newline at end of file
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+// StorageTierConfig describes one backend in a tiered storage chain
+type StorageTierConfig struct {
+	Name    string   `mapstructure:"name"`
+	Backend string   `mapstructure:"backend"` // "local" (the default) or "s3"
+	Path    string   `mapstructure:"path"`    // Chunk directory of a local tier
+	Classes []string `mapstructure:"classes"` // Storage classes ("hot", "warm", "cold", "archive") written to this tier
+
+	// Settings of an s3 tier; empty ones fall back to the top-level s3_* settings
+	S3Endpoint  string `mapstructure:"s3_endpoint"`
+	S3Bucket    string `mapstructure:"s3_bucket"`
+	S3AccessKey string `mapstructure:"s3_access_key"`
+	S3SecretKey string `mapstructure:"s3_secret_key"`
+	S3UseSSL    *bool  `mapstructure:"s3_use_ssl"`
+	S3Region    string `mapstructure:"s3_region"`
+	S3KeyPrefix string `mapstructure:"s3_key_prefix"`
+}
+
+// TranscodeRuleConfig configures an external converter for a set of MIME types
+type TranscodeRuleConfig struct {
+	MimeTypes  []string `mapstructure:"mime_types"`  // e.g. ["video/x-msvideo", "video/*"]
+	Command    []string `mapstructure:"command"`     // argv with {input}, {output} and {format} placeholders
+	Format     string   `mapstructure:"format"`      // Extension of the derivative
+	TargetMime string   `mapstructure:"target_mime"` // MIME type recorded for the derivative
+}
+
+// AppConfig holds the application-level configuration
+type AppConfig struct {
+	NodeID           string `mapstructure:"node_id"`
+	IdentityFile     string `mapstructure:"identity_file"` // Persisted node identity, empty uses storage_path/node_identity.json
+	Port             int    `mapstructure:"port"`
+	StoragePath      string `mapstructure:"storage_path"`
+	ParallelismRatio int    `mapstructure:"parallelism_ratio"`
+	MasterKey        string `mapstructure:"master_key"`       // Hex/base64 32-byte key enabling server-managed encryption
+	ChunkSize        int64  `mapstructure:"chunk_size"`       // Fixed chunk size in bytes, 0 selects automatically
+	MaxChunkCount    int    `mapstructure:"max_chunk_count"`  // Upper bound on chunks per file
+	DurabilityLevel  string `mapstructure:"durability_level"` // "local", "quorum" or "all"
+	MinCopies        int    `mapstructure:"min_copies"`       // Copies required by the "quorum" level
+	VerifyWorkers    int    `mapstructure:"verify_workers"`   // Reassembly verification workers, 0 uses one per CPU
+	VerifyLookahead  int    `mapstructure:"verify_lookahead"` // Chunks verified ahead of the write cursor, 0 uses 2x workers
+
+	// Interfaces to listen on: "" or "all" (dual-stack), "ipv4", "ipv6", or a specific IP or host name
+	APIBindAddress      string `mapstructure:"api_bind_address"`
+	P2PBindAddress      string `mapstructure:"p2p_bind_address"`      // "" keeps each transport's default
+	P2PAdvertiseAddress string `mapstructure:"p2p_advertise_address"` // Address peers dial this node on; IPv6 literals are fine
+
+	// Seconds a peer host name's resolved addresses are reused before it is looked up again,
+	// 0 looks it up on every new connection
+	PeerResolveTTL int `mapstructure:"peer_resolve_ttl"`
+
+	// Labels this node announces, matched by per-file and per-owner placement constraints
+	NodeLabels []string `mapstructure:"node_labels"`
+
+	// Failure domain this node announces, kept apart by zone_aware placement; empty when unknown
+	Zone string `mapstructure:"zone"`
+	Rack string `mapstructure:"rack"`
+
+	// Download passthrough cache of uploaded originals: "off", "encrypted", or "plaintext" (debug/demo only)
+	OriginalCacheMode       string `mapstructure:"original_cache_mode"`
+	OriginalCacheMaxEntries int    `mapstructure:"original_cache_max_entries"` // Least recently used originals are evicted past this count
+	OriginalCacheMaxBytes   int64  `mapstructure:"original_cache_max_bytes"`   // or past this many bytes on disk
+
+	// In-memory cache of reassembled, frequently downloaded files
+	WarmCacheEnabled        bool  `mapstructure:"warm_cache_enabled"`
+	WarmCacheThreshold      int64 `mapstructure:"warm_cache_threshold"` // Downloads before a file is kept warm
+	WarmCacheMaxBytes       int64 `mapstructure:"warm_cache_max_bytes"`
+	WarmCacheMaxEntryBytes  int64 `mapstructure:"warm_cache_max_entry_bytes"`
+	WarmCacheAllowPlaintext bool  `mapstructure:"warm_cache_allow_plaintext"` // Keep password-protected files unsealed in memory
+
+	// Chunk placement
+	ChunkSpreadMaxFraction float64 `mapstructure:"chunk_spread_max_fraction"` // Largest share of a file's chunks replicated to one peer, 0 disables
+	PlacementStrategy      string  `mapstructure:"placement_strategy"`        // "balanced", "performance", "reliability", "capacity", "consistent_hash" or "zone_aware"
+	RingVirtualNodes       int     `mapstructure:"ring_virtual_nodes"`        // Hash ring points per node for consistent_hash placement
+
+	// Per-file redundancy: files from the threshold up are erasure-coded instead of replicated
+	ErasureThreshold    int64 `mapstructure:"erasure_threshold"`     // Bytes, 0 always replicates
+	ErasureDataShards   int   `mapstructure:"erasure_data_shards"`   // Reed-Solomon data shards per stripe
+	ErasureParityShards int   `mapstructure:"erasure_parity_shards"` // Reed-Solomon parity shards per stripe
+	ErasureCopies       int   `mapstructure:"erasure_copies"`        // Copies of each data chunk of an erasure-coded file
+
+	StorageTiers            []StorageTierConfig `mapstructure:"storage_tiers"`             // Ordered storage backends; empty uses a single local store
+	StorageMigrationJournal string              `mapstructure:"storage_migration_journal"` // Records tier moves in progress so they resume after a crash
+
+	// Chunk write durability
+	StorageVerifyWrites bool `mapstructure:"storage_verify_writes"` // Re-read and hash each chunk after writing
+	StorageWriteRetries int  `mapstructure:"storage_write_retries"` // Extra attempts after a failed chunk write
+	StorageRetryDelayMs int  `mapstructure:"storage_retry_delay_ms"`
+
+	// Storage this node accepts before refusing chunk writes; when both are set the smaller wins
+	NodeCapacityBytes   int64   `mapstructure:"node_capacity_bytes"`   // 0 is unlimited
+	NodeCapacityPercent float64 `mapstructure:"node_capacity_percent"` // Share of the chunk disk's size, 0 is unlimited
+
+	ChunkWireCodecs []string `mapstructure:"chunk_wire_codecs"` // Codecs wrapping chunks sent between peers, most preferred first; stored chunks are unaffected
+
+	MaxInFlightBytes int64 `mapstructure:"max_inflight_bytes"` // Chunk bytes buffered at once across all operations, 0 is unlimited
+
+	MaxClockSkew int `mapstructure:"max_clock_skew"` // Seconds a peer's clock may differ before it is flagged, 0 disables
+
+	// Background detection of files stored more than once
+	DuplicateScanInterval int  `mapstructure:"duplicate_scan_interval"` // Minutes between scans, 0 disables
+	DuplicateAutoMerge    bool `mapstructure:"duplicate_auto_merge"`    // Merge duplicates found by the scan instead of only reporting them
+
+	// Ephemeral files uploaded with a TTL; all values are in seconds
+	MaxFileTTL         int `mapstructure:"max_file_ttl"`         // Longest TTL an upload may request, 0 is unlimited
+	ExpiryScanInterval int `mapstructure:"expiry_scan_interval"` // Seconds between expiry passes, 0 disables
+	ExpiryPurgeDelay   int `mapstructure:"expiry_purge_delay"`   // Seconds expired files stay soft-deleted before their chunks are purged
+
+	LifecycleScanInterval int `mapstructure:"lifecycle_scan_interval"` // Seconds between runs of the enabled lifecycle rules, 0 disables
+
+	ScrubInterval int `mapstructure:"scrub_interval"` // Seconds between scrubber passes over this node's chunks, 0 disables
+
+	RequestTracing bool `mapstructure:"request_tracing"` // Tag API requests and their log lines with a trace ID
+
+	FailureLogRetention int `mapstructure:"failure_log_retention"` // Days failed operations are kept, 0 keeps them forever
+
+	// Per-user activity summaries
+	ActivityLogRetention int   `mapstructure:"activity_log_retention"` // Days completed operations are kept, 0 keeps them forever
+	UserQuotaBytes       int64 `mapstructure:"user_quota_bytes"`       // Storage each user is expected to stay under, 0 is unlimited
+
+	RepairFileHashesOnStartup bool `mapstructure:"repair_file_hashes_on_startup"` // Re-derive placeholder file hashes once at startup
+
+	// HTTP server limits; timeouts are in seconds
+	HTTPReadHeaderTimeout  int   `mapstructure:"http_read_header_timeout"`
+	HTTPReadTimeout        int   `mapstructure:"http_read_timeout"`
+	HTTPWriteTimeout       int   `mapstructure:"http_write_timeout"`
+	HTTPIdleTimeout        int   `mapstructure:"http_idle_timeout"`
+	HTTPLongRequestTimeout int   `mapstructure:"http_long_request_timeout"` // Uploads, downloads and reassembly
+	HTTPMaxHeaderBytes     int   `mapstructure:"http_max_header_bytes"`
+	HTTPMaxBodyBytes       int64 `mapstructure:"http_max_body_bytes"` // Body cap for non-upload endpoints
+
+	// Concurrent downloads and reassemblies; 0 uses the default, a negative value is unlimited
+	DownloadLimitGlobal  int            `mapstructure:"download_limit_global"`   // Across all users
+	DownloadLimitPerUser int            `mapstructure:"download_limit_per_user"` // For roles not listed below
+	DownloadLimitRoles   map[string]int `mapstructure:"download_limit_roles"`    // Per-user limit by role
+	DownloadRetryAfter   int            `mapstructure:"download_retry_after"`    // Seconds suggested to clients over a limit
+
+	// Plaintext left on disk by downloads and reassembly jobs
+	DownloadScratchDir   string `mapstructure:"download_scratch_dir"`  // Emptied at startup; each download's file is removed once served
+	ReassembledRetention int    `mapstructure:"reassembled_retention"` // Seconds reassembly job output is kept, 0 keeps it
+	ReassemblyResume     bool   `mapstructure:"reassembly_resume"`     // Keep interrupted output beside a .progress sidecar and resume it
+
+	// Multi-file uploads under one password
+	BatchUploadAtomic  bool `mapstructure:"batch_upload_atomic"`  // Default for whether one failure rolls back the whole batch
+	BatchUploadWorkers int  `mapstructure:"batch_upload_workers"` // Files of a batch distributed at once
+
+	// Post-upload transcoding hook; disabled unless rules are configured
+	TranscodeEnabled        bool                  `mapstructure:"transcode_enabled"`
+	TranscodeRules          []TranscodeRuleConfig `mapstructure:"transcode_rules"`
+	TranscodeMaxConcurrent  int                   `mapstructure:"transcode_max_concurrent"`
+	TranscodeMaxPending     int                   `mapstructure:"transcode_max_pending"`
+	TranscodeTimeout        int                   `mapstructure:"transcode_timeout"` // Seconds per command
+	TranscodeMaxInputBytes  int64                 `mapstructure:"transcode_max_input_bytes"`
+	TranscodeMaxOutputBytes int64                 `mapstructure:"transcode_max_output_bytes"`
+	TranscodeWorkDir        string                `mapstructure:"transcode_work_dir"`
+
+	// Page sizes of list and search endpoints
+	ListPageSize    int `mapstructure:"list_page_size"`     // Items per page when a request gives no limit
+	ListMaxPageSize int `mapstructure:"list_max_page_size"` // Larger requested limits are clamped
+
+	// User-supplied tags and categories are normalized and validated on store
+	MaxTagsPerFile int      `mapstructure:"max_tags_per_file"` // Applied to tags and categories separately, 0 is unlimited
+	MaxTagLength   int      `mapstructure:"max_tag_length"`    // Characters per tag, 0 is unlimited
+	TagVocabulary  []string `mapstructure:"tag_vocabulary"`    // Canonical tags suggested for close matches
+
+	// Reconstruct every upload from its stored chunks and check its hash before acknowledging it
+	VerifyOnUpload bool `mapstructure:"verify_on_upload"`
+
+	// Transport chunk transfers to peers go over first, "http" or "tcp"
+	PreferredTransport string `mapstructure:"preferred_transport"`
+	TransportFallback  bool   `mapstructure:"transport_fallback"` // Retry over the other transport a peer advertises when the preferred one fails
+
+	// Retries of a chunk transfer to a peer that failed with a network or server error
+	TransferRetries      int     `mapstructure:"transfer_retries"`        // Extra attempts, 0 tries once
+	TransferRetryDelayMs int     `mapstructure:"transfer_retry_delay_ms"` // Wait before the first retry, doubled for each one after
+	TransferRetryJitter  float64 `mapstructure:"transfer_retry_jitter"`   // Fraction of each wait taken off at random (0-1)
+
+	// How long a peer's "chunk not found" answer is trusted before asking it again
+	NegativeCacheTTL        int `mapstructure:"negative_cache_ttl"`         // Seconds, 0 disables
+	NegativeCacheMaxEntries int `mapstructure:"negative_cache_max_entries"` // Oldest answers are dropped beyond this
+
+	// Start without the metadata store when it cannot be opened, with the features needing it disabled
+	AllowDegradedStart bool `mapstructure:"allow_degraded_start"` // false exits instead
+
+	// Create enhanced chunk metadata once at startup for stored chunks that lack it
+	BackfillChunkMetadataOnStartup bool `mapstructure:"backfill_chunk_metadata_on_startup"`
+
+	// Serve real data only: no demo files, sample logs or sample-file endpoint
+	ProductionMode bool `mapstructure:"production_mode"`
+
+	// How scrubs check stored chunks: "crc" screens with the recorded CRC32C and
+	// hashes only mismatches, "sha256" hashes every chunk (tamper-resistant)
+	IntegrityScreen string `mapstructure:"integrity_screen"`
+
+	// Seconds between round-trip probes of each peer, 0 leaves heartbeats as the only samples
+	LatencyProbeInterval int `mapstructure:"latency_probe_interval"`
+	// Download from the replica holder with the lowest measured round-trip time first
+	LatencyAwareSelection bool `mapstructure:"latency_aware_selection"`
+
+	// Seconds after a password check during which sensitive operations are allowed, 0 disables step-up
+	StepUpWindow int `mapstructure:"step_up_window"`
+	// Sensitive operations that need a recent password check, e.g. "user_update" or "lifecycle_run"
+	StepUpOperations []string `mapstructure:"step_up_operations"`
+
+	// Compression of saved search index snapshots: "zstd", "gzip" or "none"
+	IndexSnapshotCompression string `mapstructure:"index_snapshot_compression"`
+
+	// Isolate files, search and quotas per tenant; admins can still cross namespaces
+	MultiTenancy bool `mapstructure:"multi_tenancy"`
+	// Bytes of files each tenant may hold, 0 for unlimited
+	TenantQuotaBytes int64 `mapstructure:"tenant_quota_bytes"`
+
+	// Order of the signals ranking chunks for repair: "pinned", "access", "storage_class" and "replicas"
+	RepairPriority []string `mapstructure:"repair_priority"`
+
+	// Slow scrubbing, repairs, tiering and optimization while foreground load is high
+	BackgroundThrottle bool `mapstructure:"background_throttle"`
+	// Multiplier on measured load; above 1 backs off sooner, below 1 later
+	ThrottleSensitivity float64 `mapstructure:"throttle_sensitivity"`
+	// Active uploads and downloads that count as fully busy, 0 ignores them
+	ThrottleBusyTransfers int `mapstructure:"throttle_busy_transfers"`
+	// Requests per second that count as fully busy, 0 ignores them
+	ThrottleBusyRequestRate float64 `mapstructure:"throttle_busy_request_rate"`
+	// Load average per CPU that counts as fully busy, 0 ignores it
+	ThrottleBusyCPULoad float64 `mapstructure:"throttle_busy_cpu_load"`
+	// Share of time background jobs keep when fully busy (0-1)
+	ThrottleMinLevel float64 `mapstructure:"throttle_min_level"`
+
+	// Most bytes one file preview or partial download may return
+	MaxPreviewBytes int64 `mapstructure:"max_preview_bytes"`
+
+	// Tune chunk-fetch concurrency to measured throughput instead of a fixed count
+	AdaptiveParallelism bool `mapstructure:"adaptive_parallelism"`
+	// Fewest concurrent chunk fetches the adaptive controller may choose
+	ParallelismMin int `mapstructure:"parallelism_min"`
+	// Most concurrent chunk fetches; the fixed count when adaptation is off
+	ParallelismMax int `mapstructure:"parallelism_max"`
+
+	// Seconds a new reference to a deduplicated chunk waits for its deletion to finish, 0 waits indefinitely
+	ChunkReferenceWait int `mapstructure:"chunk_reference_wait"`
+
+	// Export spans, metrics and logs to an OpenTelemetry collector over OTLP/HTTP
+	OTelEnabled bool `mapstructure:"otel_enabled"`
+	// Collector base URL; signals are posted under /v1/traces, /v1/metrics and /v1/logs
+	OTelEndpoint string `mapstructure:"otel_endpoint"`
+	// Reported to the collector as service.name
+	OTelServiceName string `mapstructure:"otel_service_name"`
+	// Seconds between exports
+	OTelExportInterval int `mapstructure:"otel_export_interval"`
+	// Also export traced log lines
+	OTelExportLogs bool `mapstructure:"otel_export_logs"`
+
+	// Seconds a verified replica stays preferred as a read source, 0 ignores verification history
+	ReplicaFreshnessWindow int `mapstructure:"replica_freshness_window"`
+	// Verify in the background the stale replicas a read passes over
+	VerifyStaleReplicasOnRead bool `mapstructure:"verify_stale_replicas_on_read"`
+
+	// Chunks per second a node drain re-replicates, 0 for no limit
+	DrainRate float64 `mapstructure:"drain_rate"`
+
+	// "mutable", or "cas" for write-once chunks keyed by content hash and
+	// deleted only once unreferenced. Recorded in metadata; a CAS store stays CAS.
+	StorageMode string `mapstructure:"storage_mode"`
+
+	// Seal a key canary into new files' metadata, so passwords are checked and
+	// reassembly confirms its key without reading chunks. Files without one
+	// fall back to opening their first chunk.
+	KeyCanary bool `mapstructure:"key_canary"`
+
+	// Reassembly downloads different chunks from different replica holders at once
+	MultiSourceDownload bool `mapstructure:"multi_source_download"`
+	MultiSourcePerPeer  int  `mapstructure:"multi_source_per_peer"` // Concurrent chunk downloads from one peer
+	MultiSourceWindow   int  `mapstructure:"multi_source_window"`   // Chunks downloaded ahead of assembly, bounding memory
+
+	// Fail reassembly whose output length differs from the file's recorded size
+	EnforceFileSize bool `mapstructure:"enforce_file_size"`
+
+	// Save known peers and reconnect to them on restart
+	PeerPersistence bool   `mapstructure:"peer_persistence"`
+	PeersFile       string `mapstructure:"peers_file"`   // Empty uses storage_path/known_peers.json
+	PeerMaxAge      int    `mapstructure:"peer_max_age"` // Seconds a saved peer is kept without being seen online
+
+	// Never write user data to disk unencrypted: uploads are chunked from memory
+	// or encrypted spill files, downloads stream from chunks, and plaintext
+	// caches and outputs are refused. Recommended for production, where
+	// production_mode turns it on unless it is set explicitly.
+	StrictEncryption bool `mapstructure:"strict_encryption"`
+
+	// Ask peers which of a file's chunks they already store before replicating
+	// it, and send those as references instead of bytes
+	ChunkHaveNeed bool `mapstructure:"chunk_have_need"`
+
+	// Chunk content hash for new uploads: "sha256" or "blake3", which is faster
+	// on large files. Existing files keep the algorithm they were chunked with.
+	HashAlgo string `mapstructure:"hash_algo"`
+
+	// Compression of new chunks before encryption: "none", "gzip", "zstd", or
+	// "lz4", which chunks used before this was configurable. Chunks that would
+	// not shrink are stored uncompressed either way.
+	CompressionAlgo string `mapstructure:"compression_algo"`
+
+	// How uploads are split: "fixed" at every chunk_size, or "cdc" at
+	// content-defined boundaries so edits only change nearby chunks. CDC sizes
+	// of 0 derive from the automatic or configured chunk size.
+	ChunkMode  string `mapstructure:"chunk_mode"`
+	CDCMinSize int64  `mapstructure:"cdc_min_size"`
+	CDCAvgSize int64  `mapstructure:"cdc_avg_size"`
+	CDCMaxSize int64  `mapstructure:"cdc_max_size"`
+
+	// Where chunks are kept when no storage tiers are configured: "local" disk
+	// or "s3" for an S3-compatible object store such as MinIO
+	StorageBackend string `mapstructure:"storage_backend"`
+	S3Endpoint     string `mapstructure:"s3_endpoint"` // host[:port]
+	S3Bucket       string `mapstructure:"s3_bucket"`   // Created when missing
+	S3AccessKey    string `mapstructure:"s3_access_key"`
+	S3SecretKey    string `mapstructure:"s3_secret_key"`
+	S3UseSSL       bool   `mapstructure:"s3_use_ssl"`
+	S3Region       string `mapstructure:"s3_region"`
+	S3KeyPrefix    string `mapstructure:"s3_key_prefix"` // Lets several nodes share one bucket
+
+	// Access-based tiering moves files between the storage tiers serving
+	// "hot", "warm" and "cold"; it needs storage_tiers to be configured
+	TieringWarmAfterDays int `mapstructure:"tiering_warm_after_days"` // Days without access before a hot file is demoted to warm, 0 skips warm
+	TieringColdAfterDays int `mapstructure:"tiering_cold_after_days"` // Days without access before a file is demoted to cold, 0 never demotes
+	TieringScanInterval  int `mapstructure:"tiering_scan_interval"`   // Seconds between tiering passes, 0 disables them
+}
+
+var Config *AppConfig
+
+func LoadConfig(path string) {
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath(path)
+	viper.AutomaticEnv()
+
+	viper.SetDefault("node_id", "disktrobyte-default-node")
+	viper.SetDefault("port", 8080)
+	viper.SetDefault("storage_path", "./data")
+	viper.SetDefault("identity_file", "")
+	viper.SetDefault("parallelism_ratio", 2)
+	viper.SetDefault("master_key", "")
+	viper.SetDefault("chunk_size", 0)
+	viper.SetDefault("max_chunk_count", 100000)
+	viper.SetDefault("durability_level", "local")
+	viper.SetDefault("min_copies", 2)
+	viper.SetDefault("verify_workers", 0)
+	viper.SetDefault("verify_lookahead", 0)
+	viper.SetDefault("original_cache_mode", "off")
+	viper.SetDefault("original_cache_max_entries", 1000)
+	viper.SetDefault("original_cache_max_bytes", 1<<30)
+	viper.SetDefault("warm_cache_enabled", false)
+	viper.SetDefault("warm_cache_threshold", 3)
+	viper.SetDefault("warm_cache_max_bytes", 256<<20)
+	viper.SetDefault("warm_cache_max_entry_bytes", 64<<20)
+	viper.SetDefault("warm_cache_allow_plaintext", false)
+	viper.SetDefault("placement_strategy", "balanced")
+	viper.SetDefault("chunk_spread_max_fraction", 0.5)
+	viper.SetDefault("ring_virtual_nodes", 128)
+	viper.SetDefault("zone", "")
+	viper.SetDefault("rack", "")
+	viper.SetDefault("erasure_threshold", 10<<20)
+	viper.SetDefault("erasure_data_shards", 6)
+	viper.SetDefault("erasure_parity_shards", 3)
+	viper.SetDefault("erasure_copies", 2)
+	viper.SetDefault("storage_verify_writes", false)
+	viper.SetDefault("storage_write_retries", 2)
+	viper.SetDefault("storage_retry_delay_ms", 50)
+	viper.SetDefault("node_capacity_bytes", 0)
+	viper.SetDefault("node_capacity_percent", 0)
+	viper.SetDefault("chunk_wire_codecs", []string{"identity"})
+	viper.SetDefault("max_inflight_bytes", 512<<20)
+	viper.SetDefault("max_clock_skew", 30)
+	viper.SetDefault("duplicate_scan_interval", 0)
+	viper.SetDefault("duplicate_auto_merge", false)
+	viper.SetDefault("max_file_ttl", 30*24*60*60)
+	viper.SetDefault("expiry_scan_interval", 60)
+	viper.SetDefault("expiry_purge_delay", 0)
+	viper.SetDefault("lifecycle_scan_interval", 3600)
+	viper.SetDefault("scrub_interval", 24*60*60)
+	viper.SetDefault("request_tracing", true)
+	viper.SetDefault("repair_file_hashes_on_startup", false)
+	viper.SetDefault("http_read_header_timeout", 10)
+	viper.SetDefault("http_read_timeout", 60)
+	viper.SetDefault("http_write_timeout", 60)
+	viper.SetDefault("http_idle_timeout", 120)
+	viper.SetDefault("http_long_request_timeout", 1800)
+	viper.SetDefault("http_max_header_bytes", 1<<20)
+	viper.SetDefault("http_max_body_bytes", 10<<20)
+	viper.SetDefault("storage_migration_journal", "./data/tier_migrations.json")
+	viper.SetDefault("failure_log_retention", 30)
+	viper.SetDefault("activity_log_retention", 90)
+	viper.SetDefault("user_quota_bytes", 0)
+	viper.SetDefault("download_limit_global", 16)
+	viper.SetDefault("download_limit_per_user", 2)
+	viper.SetDefault("download_limit_roles", map[string]int{"admin": 4, "superadmin": 8})
+	viper.SetDefault("download_retry_after", 5)
+	viper.SetDefault("api_bind_address", "")
+	viper.SetDefault("p2p_bind_address", "")
+	viper.SetDefault("p2p_advertise_address", "localhost")
+	viper.SetDefault("download_scratch_dir", "temp_downloads")
+	viper.SetDefault("reassembled_retention", 3600)
+	viper.SetDefault("reassembly_resume", true)
+	viper.SetDefault("batch_upload_atomic", false)
+	viper.SetDefault("batch_upload_workers", 4)
+	viper.SetDefault("transcode_enabled", false)
+	viper.SetDefault("transcode_max_concurrent", 1)
+	viper.SetDefault("transcode_max_pending", 16)
+	viper.SetDefault("transcode_timeout", 600)
+	viper.SetDefault("transcode_max_input_bytes", 2<<30)
+	viper.SetDefault("transcode_max_output_bytes", 2<<30)
+	viper.SetDefault("transcode_work_dir", "./transcode")
+	viper.SetDefault("max_tags_per_file", 32)
+	viper.SetDefault("list_page_size", 100)
+	viper.SetDefault("list_max_page_size", 1000)
+	viper.SetDefault("max_tag_length", 64)
+	viper.SetDefault("peer_resolve_ttl", 30)
+	viper.SetDefault("verify_on_upload", false)
+	viper.SetDefault("preferred_transport", "http")
+	viper.SetDefault("transport_fallback", true)
+	viper.SetDefault("transfer_retries", 3)
+	viper.SetDefault("transfer_retry_delay_ms", 200)
+	viper.SetDefault("transfer_retry_jitter", 0.2)
+	viper.SetDefault("negative_cache_ttl", 30)
+	viper.SetDefault("negative_cache_max_entries", 10000)
+	viper.SetDefault("allow_degraded_start", true)
+	viper.SetDefault("backfill_chunk_metadata_on_startup", false)
+	viper.SetDefault("production_mode", false)
+	viper.SetDefault("integrity_screen", "crc")
+	viper.SetDefault("latency_probe_interval", 60)
+	viper.SetDefault("latency_aware_selection", true)
+	viper.SetDefault("step_up_window", 300)
+	viper.SetDefault("index_snapshot_compression", "zstd")
+	viper.SetDefault("step_up_operations", []string{"user_update", "session_revoke", "lifecycle_rules", "lifecycle_run", "placement", "file_import", "node_drain", "file_delete"})
+	viper.SetDefault("multi_tenancy", false)
+	viper.SetDefault("tenant_quota_bytes", 0)
+	viper.SetDefault("repair_priority", []string{"pinned", "access", "storage_class", "replicas"})
+	viper.SetDefault("background_throttle", true)
+	viper.SetDefault("throttle_sensitivity", 1.0)
+	viper.SetDefault("throttle_busy_transfers", 8)
+	viper.SetDefault("throttle_busy_request_rate", 50.0)
+	viper.SetDefault("throttle_busy_cpu_load", 1.0)
+	viper.SetDefault("throttle_min_level", 0.1)
+	viper.SetDefault("max_preview_bytes", 1<<20)
+	viper.SetDefault("adaptive_parallelism", true)
+	viper.SetDefault("parallelism_min", 1)
+	viper.SetDefault("parallelism_max", 16)
+	viper.SetDefault("chunk_reference_wait", 30)
+	viper.SetDefault("otel_enabled", false)
+	viper.SetDefault("otel_endpoint", "http://localhost:4318")
+	viper.SetDefault("otel_service_name", "disktrobyte")
+	viper.SetDefault("otel_export_interval", 10)
+	viper.SetDefault("otel_export_logs", true)
+	viper.SetDefault("replica_freshness_window", 3600)
+	viper.SetDefault("verify_stale_replicas_on_read", true)
+	viper.SetDefault("drain_rate", 10.0)
+	viper.SetDefault("storage_mode", "mutable")
+	viper.SetDefault("key_canary", true)
+	viper.SetDefault("multi_source_download", true)
+	viper.SetDefault("multi_source_per_peer", 2)
+	viper.SetDefault("multi_source_window", 16)
+	viper.SetDefault("enforce_file_size", true)
+	viper.SetDefault("peer_persistence", true)
+	viper.SetDefault("peers_file", "")
+	viper.SetDefault("peer_max_age", 7*24*60*60)
+	viper.SetDefault("strict_encryption", false)
+	viper.SetDefault("chunk_have_need", true)
+	viper.SetDefault("hash_algo", "sha256")
+	viper.SetDefault("compression_algo", "zstd")
+	viper.SetDefault("chunk_mode", "fixed")
+	viper.SetDefault("cdc_min_size", 0)
+	viper.SetDefault("cdc_avg_size", 0)
+	viper.SetDefault("cdc_max_size", 0)
+	viper.SetDefault("storage_backend", "local")
+	viper.SetDefault("s3_endpoint", "")
+	viper.SetDefault("s3_bucket", "")
+	viper.SetDefault("s3_access_key", "")
+	viper.SetDefault("s3_secret_key", "")
+	viper.SetDefault("s3_use_ssl", true)
+	viper.SetDefault("s3_region", "us-east-1")
+	viper.SetDefault("s3_key_prefix", "")
+	viper.SetDefault("tiering_warm_after_days", 7)
+	viper.SetDefault("tiering_cold_after_days", 30)
+	viper.SetDefault("tiering_scan_interval", 3600)
+
+	if err := viper.ReadInConfig(); err != nil {
+		log.Printf("⚠️ Could not read config file, using defaults: %v", err)
+	}
+
+	var appConfig AppConfig
+	if err := viper.Unmarshal(&appConfig); err != nil {
+		log.Fatalf("❌ Unable to decode config into struct: %v", err)
+	}
+
+	// Strict encryption refuses plaintext reassembly outputs, so it is only on
+	// by default where production mode asks for hardened behaviour
+	if appConfig.ProductionMode && !viper.InConfig("strict_encryption") && os.Getenv("STRICT_ENCRYPTION") == "" {
+		appConfig.StrictEncryption = true
+	}
+
+	Config = &appConfig
+
+	fmt.Println("✅ Configuration loaded successfully.")
+}
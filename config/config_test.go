@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestProductionModeTurnsOnStrictEncryption(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+
+	cases := []struct {
+		yaml   string
+		strict bool
+	}{
+		{"node_id: dev\n", false},
+		{"production_mode: true\n", true},
+		{"production_mode: true\nstrict_encryption: false\n", false},
+		{"strict_encryption: true\n", true},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(c.yaml), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		viper.Reset()
+		LoadConfig(dir)
+		if Config.StrictEncryption != c.strict {
+			t.Errorf("config %q: expected strict encryption %v, got %v", c.yaml, c.strict, Config.StrictEncryption)
+		}
+	}
+	viper.Reset()
+}
@@ -0,0 +1,180 @@
+// Package activity keeps a persistent log of the file operations users
+// complete, so admins can see who stores and fetches what over time.
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/failures"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// Operations recorded in the log
+const (
+	OpUpload   = "upload"
+	OpDownload = "download"
+	OpUpdate   = "update"
+	OpDelete   = "delete"
+)
+
+// keyPrefix keeps activity records together, ordered by time
+const keyPrefix = "activity:"
+
+// Entry is one completed operation
+type Entry struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	UserID    string    `json:"user_id"`
+	FileID    string    `json:"file_id,omitempty"`
+	FileName  string    `json:"file_name,omitempty"`
+	Bytes     int64     `json:"bytes"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Filter selects entries; empty fields match everything
+type Filter struct {
+	Operation string
+	UserID    string
+	FileID    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int // Newest entries kept, 0 is unlimited
+}
+
+func (f Filter) matches(e Entry) bool {
+	return (f.Operation == "" || e.Operation == f.Operation) &&
+		(f.UserID == "" || e.UserID == f.UserID) &&
+		(f.FileID == "" || e.FileID == f.FileID) &&
+		(f.Until.IsZero() || !e.Timestamp.After(f.Until))
+}
+
+// Log stores activity in the metadata database. A nil *Log records nothing,
+// so components can hold one unconditionally.
+type Log struct {
+	db *badger.DB
+}
+
+// NewLog creates an activity log backed by the metadata store's database
+func NewLog(metaStore *metadata.MetadataStore) *Log {
+	return &Log{db: metaStore.GetDB()}
+}
+
+func entryKey(e Entry) []byte {
+	return []byte(fmt.Sprintf("%s%020d:%s", keyPrefix, e.Timestamp.UnixNano(), e.ID))
+}
+
+// Record stores a completed operation. The user and trace ID come from ctx
+// unless set on e.
+func (l *Log) Record(ctx context.Context, e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	if e.UserID == "" {
+		e.UserID = failures.User(ctx)
+	}
+	if e.TraceID == "" {
+		e.TraceID = tracing.ID(ctx)
+	}
+
+	val, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return l.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(entryKey(e), val)
+	})
+}
+
+// Query returns the entries matching filter, newest first
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	if l == nil {
+		return entries, nil
+	}
+
+	start := []byte(keyPrefix)
+	if !filter.Since.IsZero() {
+		start = []byte(fmt.Sprintf("%s%020d", keyPrefix, filter.Since.UnixNano()))
+	}
+	err := l.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(start); it.ValidForPrefix([]byte(keyPrefix)); it.Next() {
+			var e Entry
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &e)
+			}); err != nil {
+				return err
+			}
+			if filter.matches(e) {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// Prune deletes entries recorded before cutoff and returns how many were removed
+func (l *Log) Prune(cutoff time.Time) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	end := []byte(fmt.Sprintf("%s%020d", keyPrefix, cutoff.UnixNano()))
+
+	var keys [][]byte
+	err := l.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek([]byte(keyPrefix)); it.ValidForPrefix([]byte(keyPrefix)); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if string(key) >= string(end) {
+				break
+			}
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	batch := l.db.NewWriteBatch()
+	defer batch.Cancel()
+	for _, key := range keys {
+		if err := batch.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	if err := batch.Flush(); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,150 @@
+package activity
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/auth"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// recentEntries is how many of a user's latest operations a summary lists
+const recentEntries = 20
+
+// OwnedFiles finds the files a user owns through the owner index
+type OwnedFiles interface {
+	FilesByOwner(ownerID string) ([]*metadata.EnhancedFileMetadata, error)
+}
+
+// TimelineBucket totals one day (UTC) of a user's operations
+type TimelineBucket struct {
+	Day        time.Time        `json:"day"`
+	Operations map[string]int   `json:"operations"`
+	Bytes      map[string]int64 `json:"bytes"`
+}
+
+// UserSummary is an admin's view of one user. Files and storage are current
+// totals; operations, the timeline and recent activity cover the requested range.
+type UserSummary struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username,omitempty"`
+	Role     string `json:"role,omitempty"`
+
+	Files            int     `json:"files"`
+	StorageBytes     int64   `json:"storage_bytes"`
+	QuotaBytes       int64   `json:"quota_bytes"`        // 0 when unlimited
+	QuotaUsedPercent float64 `json:"quota_used_percent"` // 0 when unlimited
+
+	Since          time.Time        `json:"since"`
+	Until          time.Time        `json:"until"`
+	Operations     map[string]int   `json:"operations"`
+	OperationBytes map[string]int64 `json:"operation_bytes"`
+	Timeline       []TimelineBucket `json:"timeline"`
+	Recent         []Entry          `json:"recent"`
+
+	ActiveSessions int       `json:"active_sessions"`
+	LastLogin      time.Time `json:"last_login"`
+	LastActivity   time.Time `json:"last_activity"`
+}
+
+// Summarizer combines file ownership, the activity log and session data
+// into per-user summaries. Any source may be nil.
+type Summarizer struct {
+	log   *Log
+	files OwnedFiles
+	auth  *auth.AuthManager
+	quota int64
+}
+
+// NewSummarizer creates a summarizer; quotaBytes is the storage each user
+// may own, 0 for no quota
+func NewSummarizer(log *Log, files OwnedFiles, authManager *auth.AuthManager, quotaBytes int64) *Summarizer {
+	return &Summarizer{log: log, files: files, auth: authManager, quota: quotaBytes}
+}
+
+// GetUserActivitySummary aggregates a user's files, storage and the
+// operations they completed between since and until (zero times leave the
+// range open)
+func (s *Summarizer) GetUserActivitySummary(userID string, since, until time.Time) (*UserSummary, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user ID is required")
+	}
+	summary := &UserSummary{
+		UserID:         userID,
+		QuotaBytes:     s.quota,
+		Since:          since,
+		Until:          until,
+		Operations:     make(map[string]int),
+		OperationBytes: make(map[string]int64),
+		Timeline:       make([]TimelineBucket, 0),
+		Recent:         make([]Entry, 0),
+	}
+
+	if s.auth != nil {
+		user, err := s.auth.GetUserByID(userID)
+		if err != nil {
+			return nil, fmt.Errorf("user %s not found", userID)
+		}
+		summary.Username = user.Username
+		summary.Role = string(user.Role)
+		summary.LastLogin = user.LastLogin
+		summary.LastActivity = user.LastLogin
+		for _, session := range s.auth.GetActiveSessions() {
+			if session.UserID != userID {
+				continue
+			}
+			summary.ActiveSessions++
+			if session.LastActivity.After(summary.LastActivity) {
+				summary.LastActivity = session.LastActivity
+			}
+		}
+	}
+
+	if s.files != nil {
+		files, err := s.files.FilesByOwner(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of %s: %v", userID, err)
+		}
+		summary.Files = len(files)
+		for _, file := range files {
+			summary.StorageBytes += file.FileSize
+		}
+	}
+	if s.quota > 0 {
+		summary.QuotaUsedPercent = float64(summary.StorageBytes) * 100 / float64(s.quota)
+	}
+
+	entries, err := s.log.Query(Filter{UserID: userID, Since: since, Until: until})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity of %s: %v", userID, err)
+	}
+	days := make(map[time.Time]*TimelineBucket)
+	for _, e := range entries {
+		summary.Operations[e.Operation]++
+		summary.OperationBytes[e.Operation] += e.Bytes
+
+		day := e.Timestamp.UTC().Truncate(24 * time.Hour)
+		bucket, ok := days[day]
+		if !ok {
+			bucket = &TimelineBucket{Day: day, Operations: make(map[string]int), Bytes: make(map[string]int64)}
+			days[day] = bucket
+		}
+		bucket.Operations[e.Operation]++
+		bucket.Bytes[e.Operation] += e.Bytes
+	}
+	for _, bucket := range days {
+		summary.Timeline = append(summary.Timeline, *bucket)
+	}
+	sort.Slice(summary.Timeline, func(i, j int) bool { return summary.Timeline[i].Day.Before(summary.Timeline[j].Day) })
+
+	// Entries are newest first
+	if len(entries) > 0 && entries[0].Timestamp.After(summary.LastActivity) {
+		summary.LastActivity = entries[0].Timestamp
+	}
+	if len(entries) > recentEntries {
+		entries = entries[:recentEntries]
+	}
+	summary.Recent = append(summary.Recent, entries...)
+	return summary, nil
+}
@@ -0,0 +1,117 @@
+package activity
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/auth"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+func TestUserActivitySummaryTotals(t *testing.T) {
+	dir := t.TempDir()
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	catalog, err := metadata.NewEnhancedMetadataStore(filepath.Join(dir, "enhanced"))
+	if err != nil {
+		t.Fatalf("failed to open enhanced metadata: %v", err)
+	}
+	defer catalog.Close()
+	authManager := auth.NewAuthManager(time.Hour, 10)
+	defer authManager.Stop()
+
+	user, err := authManager.Register(auth.RegisterRequest{Username: "alex", Password: "secret-pw"})
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	if _, err := authManager.Login(auth.LoginRequest{Username: "alex", Password: "secret-pw"}); err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+
+	// Two live files and a deleted one for the user, and one file of someone else
+	files := []*metadata.EnhancedFileMetadata{
+		{FileID: "f1", FileName: "a.txt", FileSize: 1000, OwnerID: user.ID},
+		{FileID: "f2", FileName: "b.txt", FileSize: 3000, OwnerID: user.ID},
+		{FileID: "f3", FileName: "gone.txt", FileSize: 5000, OwnerID: user.ID, IsDeleted: true},
+		{FileID: "f4", FileName: "other.txt", FileSize: 7000, OwnerID: "someone-else"},
+	}
+	for _, file := range files {
+		if err := catalog.StoreFileMetadata(file); err != nil {
+			t.Fatalf("failed to store %s: %v", file.FileID, err)
+		}
+	}
+	// The owner index is updated in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		owned, _ := catalog.FilesByOwner(user.ID)
+		if len(owned) == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	log := NewLog(metaStore)
+	ctx := context.Background()
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	for _, e := range []Entry{
+		{Operation: OpUpload, UserID: user.ID, FileID: "f1", Bytes: 1000, Timestamp: day1},
+		{Operation: OpUpload, UserID: user.ID, FileID: "f2", Bytes: 3000, Timestamp: day1.Add(time.Hour)},
+		{Operation: OpDownload, UserID: user.ID, FileID: "f1", Bytes: 1000, Timestamp: day2},
+		{Operation: OpDownload, UserID: user.ID, FileID: "f2", Bytes: 3000, Timestamp: day2.Add(time.Hour)},
+		{Operation: OpDownload, UserID: user.ID, FileID: "f2", Bytes: 3000, Timestamp: day2.Add(2 * time.Hour)},
+		{Operation: OpUpload, UserID: "someone-else", FileID: "f4", Bytes: 7000, Timestamp: day2},
+	} {
+		if err := log.Record(ctx, e); err != nil {
+			t.Fatalf("failed to record activity: %v", err)
+		}
+	}
+
+	summarizer := NewSummarizer(log, catalog, authManager, 8000)
+	summary, err := summarizer.GetUserActivitySummary(user.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetUserActivitySummary failed: %v", err)
+	}
+	if summary.Username != "alex" || summary.ActiveSessions != 1 {
+		t.Errorf("expected alex with one session, got %q with %d", summary.Username, summary.ActiveSessions)
+	}
+	if summary.Files != 2 || summary.StorageBytes != 4000 {
+		t.Errorf("expected 2 files and 4000 bytes, got %d files and %d bytes", summary.Files, summary.StorageBytes)
+	}
+	if summary.QuotaUsedPercent != 50 {
+		t.Errorf("expected half the quota used, got %v%%", summary.QuotaUsedPercent)
+	}
+	if summary.Operations[OpUpload] != 2 || summary.Operations[OpDownload] != 3 {
+		t.Errorf("unexpected operation counts %v", summary.Operations)
+	}
+	if summary.OperationBytes[OpDownload] != 7000 {
+		t.Errorf("expected 7000 bytes downloaded, got %d", summary.OperationBytes[OpDownload])
+	}
+	if len(summary.Timeline) != 2 || summary.Timeline[0].Operations[OpUpload] != 2 || summary.Timeline[1].Operations[OpDownload] != 3 {
+		t.Errorf("unexpected timeline %+v", summary.Timeline)
+	}
+	if len(summary.Recent) != 5 || summary.Recent[0].Timestamp.Before(summary.Recent[4].Timestamp) {
+		t.Errorf("expected 5 recent entries, newest first, got %+v", summary.Recent)
+	}
+
+	// A range covering only the second day leaves the uploads out
+	ranged, err := summarizer.GetUserActivitySummary(user.ID, day2, day2.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ranged summary failed: %v", err)
+	}
+	if ranged.Operations[OpUpload] != 0 || ranged.Operations[OpDownload] != 3 || len(ranged.Timeline) != 1 {
+		t.Errorf("unexpected ranged summary %v / %+v", ranged.Operations, ranged.Timeline)
+	}
+	if ranged.Files != 2 {
+		t.Errorf("files owned are current totals regardless of range, got %d", ranged.Files)
+	}
+
+	if _, err := summarizer.GetUserActivitySummary("no-such-user", time.Time{}, time.Time{}); err == nil {
+		t.Error("expected an error for an unknown user")
+	}
+}
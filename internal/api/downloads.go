@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// Download limit defaults, used when the config leaves a value at zero
+const (
+	DefaultDownloadsPerUser    = 2
+	DefaultDownloadsGlobal     = 16
+	DefaultDownloadRetryAfter  = 5 * time.Second
+	DefaultAdminDownloads      = 4
+	DefaultSuperadminDownloads = 8
+)
+
+// DownloadLimits caps concurrent downloads and reassemblies. Limits of zero
+// or less are unlimited.
+type DownloadLimits struct {
+	Global     int            // Across all users
+	PerUser    int            // For roles without their own limit
+	PerRole    map[string]int // Per-user limit by role
+	RetryAfter time.Duration  // Suggested wait returned with a rejection
+}
+
+// DownloadLimitsFromConfig reads download limits from config, filling in defaults
+func DownloadLimitsFromConfig() DownloadLimits {
+	limits := DownloadLimits{
+		Global:  DefaultDownloadsGlobal,
+		PerUser: DefaultDownloadsPerUser,
+		PerRole: map[string]int{
+			"admin":      DefaultAdminDownloads,
+			"superadmin": DefaultSuperadminDownloads,
+		},
+		RetryAfter: DefaultDownloadRetryAfter,
+	}
+	cfg := config.Config
+	if cfg == nil {
+		return limits
+	}
+	if cfg.DownloadLimitGlobal != 0 {
+		limits.Global = cfg.DownloadLimitGlobal
+	}
+	if cfg.DownloadLimitPerUser != 0 {
+		limits.PerUser = cfg.DownloadLimitPerUser
+	}
+	for role, limit := range cfg.DownloadLimitRoles {
+		limits.PerRole[role] = limit
+	}
+	if cfg.DownloadRetryAfter > 0 {
+		limits.RetryAfter = time.Duration(cfg.DownloadRetryAfter) * time.Second
+	}
+	return limits
+}
+
+// forRole returns the per-user limit that applies to a role
+func (l DownloadLimits) forRole(role string) int {
+	if limit, ok := l.PerRole[role]; ok {
+		return limit
+	}
+	return l.PerUser
+}
+
+// DownloadLimitError reports a download rejected by a per-user or global limit
+type DownloadLimitError struct {
+	Global     bool // The global cap was reached rather than the user's own
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *DownloadLimitError) Error() string {
+	if e.Global {
+		return fmt.Sprintf("server is busy with %d downloads; retry in %v", e.Limit, e.RetryAfter)
+	}
+	return fmt.Sprintf("you already have %d downloads in progress; retry in %v", e.Limit, e.RetryAfter)
+}
+
+// UserDownloads is one user's share of the active downloads
+type UserDownloads struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+	Active int    `json:"active"`
+	Limit  int    `json:"limit"` // 0 is unlimited
+}
+
+// DownloadUsage summarizes active downloads for admins
+type DownloadUsage struct {
+	Active      int             `json:"active"`
+	GlobalLimit int             `json:"global_limit"` // 0 is unlimited
+	Users       []UserDownloads `json:"users"`
+}
+
+// DownloadLimiter counts active downloads per user and in total, rejecting
+// new ones past the configured limits
+type DownloadLimiter struct {
+	mu     sync.Mutex
+	limits DownloadLimits
+	active map[string]int
+	roles  map[string]string
+	total  int
+}
+
+// NewDownloadLimiter creates a limiter enforcing the given limits
+func NewDownloadLimiter(limits DownloadLimits) *DownloadLimiter {
+	return &DownloadLimiter{
+		limits: limits,
+		active: make(map[string]int),
+		roles:  make(map[string]string),
+	}
+}
+
+// Acquire reserves a download slot for a user. The returned release must be
+// called exactly once when the download or reassembly finishes.
+func (l *DownloadLimiter) Acquire(userID, role string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit := l.limits.forRole(role); limit > 0 && l.active[userID] >= limit {
+		return nil, &DownloadLimitError{Limit: limit, RetryAfter: l.limits.RetryAfter}
+	}
+	if l.limits.Global > 0 && l.total >= l.limits.Global {
+		return nil, &DownloadLimitError{Global: true, Limit: l.limits.Global, RetryAfter: l.limits.RetryAfter}
+	}
+	l.active[userID]++
+	l.roles[userID] = role
+	l.total++
+
+	var once sync.Once
+	return func() { once.Do(func() { l.release(userID) }) }, nil
+}
+
+func (l *DownloadLimiter) release(userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	if l.active[userID]--; l.active[userID] <= 0 {
+		delete(l.active, userID)
+		delete(l.roles, userID)
+	}
+}
+
+// Usage returns the active download counts, busiest users first
+func (l *DownloadLimiter) Usage() DownloadUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	usage := DownloadUsage{Active: l.total, GlobalLimit: max(l.limits.Global, 0), Users: make([]UserDownloads, 0, len(l.active))}
+	for userID, active := range l.active {
+		role := l.roles[userID]
+		usage.Users = append(usage.Users, UserDownloads{UserID: userID, Role: role, Active: active, Limit: max(l.limits.forRole(role), 0)})
+	}
+	sort.Slice(usage.Users, func(i, j int) bool {
+		if usage.Users[i].Active != usage.Users[j].Active {
+			return usage.Users[i].Active > usage.Users[j].Active
+		}
+		return usage.Users[i].UserID < usage.Users[j].UserID
+	})
+	return usage
+}
+
+// LimitDownloads holds a download slot for the caller (identified by the
+// X-User-ID and X-User-Role headers set by authentication) while next runs.
+// Requests over a limit get 429 Too Many Requests with a Retry-After header.
+func (l *DownloadLimiter) LimitDownloads(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, err := l.Acquire(r.Header.Get("X-User-ID"), r.Header.Get("X-User-Role"))
+		if err != nil {
+			RejectDownload(w, err)
+			return
+		}
+		defer release()
+		next(w, r)
+	}
+}
+
+// RejectDownload writes a 429 response for a DownloadLimitError
+func RejectDownload(w http.ResponseWriter, err error) {
+	retryAfter := DefaultDownloadRetryAfter
+	if limitErr, ok := err.(*DownloadLimitError); ok {
+		retryAfter = limitErr.RetryAfter
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": err.Error()})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadLimitIsPerUser(t *testing.T) {
+	limiter := NewDownloadLimiter(DownloadLimits{
+		Global:     10,
+		PerUser:    2,
+		PerRole:    map[string]int{"admin": 3},
+		RetryAfter: 7 * time.Second,
+	})
+
+	// Downloads block until the test lets them finish
+	var started sync.WaitGroup
+	finish := make(chan struct{})
+	handler := limiter.LimitDownloads(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-finish
+	})
+	download := func(userID, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/files/download", nil)
+		req.Header.Set("X-User-ID", userID)
+		req.Header.Set("X-User-Role", role)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	var done sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			download("alice", "user")
+		}()
+	}
+	started.Wait()
+
+	rec := download("alice", "user")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected alice's third download to be rejected, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "7" {
+		t.Errorf("expected Retry-After 7, got %q", got)
+	}
+
+	// Another user still gets a slot while alice is at the limit
+	started.Add(1)
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		if rec := download("bob", "user"); rec.Code != http.StatusOK {
+			t.Errorf("expected bob's download to succeed, got %d", rec.Code)
+		}
+	}()
+	started.Wait()
+
+	usage := limiter.Usage()
+	if usage.Active != 3 || len(usage.Users) != 2 || usage.Users[0].UserID != "alice" || usage.Users[0].Active != 2 {
+		t.Errorf("unexpected usage while downloading: %+v", usage)
+	}
+
+	close(finish)
+	done.Wait()
+	if usage := limiter.Usage(); usage.Active != 0 || len(usage.Users) != 0 {
+		t.Errorf("expected all slots released, got %+v", usage)
+	}
+	if _, err := limiter.Acquire("alice", "user"); err != nil {
+		t.Errorf("expected alice to get a slot after the downloads finished: %v", err)
+	}
+}
+
+func TestDownloadLimitGlobalCap(t *testing.T) {
+	limiter := NewDownloadLimiter(DownloadLimits{Global: 2, PerUser: 2, RetryAfter: time.Second})
+
+	releaseA, _ := limiter.Acquire("alice", "user")
+	if _, err := limiter.Acquire("bob", "user"); err != nil {
+		t.Fatalf("expected bob to get a slot: %v", err)
+	}
+	_, err := limiter.Acquire("carol", "user")
+	if limitErr, ok := err.(*DownloadLimitError); !ok || !limitErr.Global {
+		t.Fatalf("expected the global cap to reject carol, got %v", err)
+	}
+
+	// Releasing twice frees only one slot
+	releaseA()
+	releaseA()
+	if _, err := limiter.Acquire("carol", "user"); err != nil {
+		t.Errorf("expected carol to get the freed slot: %v", err)
+	}
+	if _, err := limiter.Acquire("dave", "user"); err == nil {
+		t.Errorf("expected a double release not to free a second slot")
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// File status values reported to clients
+const (
+	StatusReady       = "ready for reassembly"
+	StatusDistributed = "distributed"
+	StatusStored      = "stored"
+	StatusInDFS       = "available in DFS"
+)
+
+// File is the canonical JSON shape for files returned by listing, search and
+// available-files endpoints. Every handler should build it through the
+// converters below instead of ad-hoc map literals.
+type File struct {
+	FileID          string    `json:"file_id"`
+	FileName        string    `json:"file_name"`
+	FileSize        int64     `json:"file_size"`
+	FileHash        string    `json:"file_hash"`
+	MimeType        string    `json:"mime_type"`
+	ChunkCount      int       `json:"chunk_count"`
+	ChunksAvailable int       `json:"chunks_available"`
+	ReplicaCount    int       `json:"replica_count"`
+	StorageNodes    []string  `json:"storage_nodes"`
+	OwnerID         string    `json:"owner_id"`
+	Tags            []string  `json:"tags"`
+	Categories      []string  `json:"categories"`
+	Description     string    `json:"description"`
+	IsEncrypted     bool      `json:"is_encrypted"`
+	KeyMode         string    `json:"key_mode"`
+	HealthStatus    string    `json:"health_status"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	ModifiedAt      time.Time `json:"modified_at"`
+
+	// Ephemeral files report when they expire and how long they have left
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RemainingSeconds int64      `json:"remaining_seconds,omitempty"`
+
+	// Availability is the live per-chunk reachability check, when one was made
+	Availability *distributor.Availability `json:"availability,omitempty"`
+}
+
+// ToAPIFile converts enhanced metadata into the canonical API file
+func ToAPIFile(meta *metadata.EnhancedFileMetadata) File {
+	modifiedAt := meta.ModifiedAt
+	if modifiedAt.IsZero() {
+		modifiedAt = meta.CreatedAt
+	}
+
+	file := File{
+		FileID:          meta.FileID,
+		FileName:        meta.FileName,
+		FileSize:        meta.FileSize,
+		FileHash:        meta.FileHash,
+		MimeType:        meta.MimeType,
+		ChunkCount:      meta.ChunkCount,
+		ChunksAvailable: meta.ChunkCount,
+		ReplicaCount:    meta.ReplicaCount,
+		StorageNodes:    nonNil(meta.StorageNodes),
+		OwnerID:         meta.OwnerID,
+		Tags:            nonNil(meta.Tags),
+		Categories:      nonNil(meta.Categories),
+		Description:     meta.Description,
+		IsEncrypted:     meta.IsEncrypted,
+		KeyMode:         keyModeOrDefault(meta.KeyMode),
+		HealthStatus:    meta.HealthStatus,
+		Status:          StatusReady,
+		CreatedAt:       meta.CreatedAt,
+		ModifiedAt:      modifiedAt,
+	}
+	if meta.ExpiresAt != nil {
+		file = WithExpiry(file, *meta.ExpiresAt)
+	}
+	return file
+}
+
+// FromFileInfo converts a distributor file record into the canonical API file
+func FromFileInfo(info *distributor.FileInfo) File {
+	return File{
+		FileID:          info.ID,
+		FileName:        info.Name,
+		FileSize:        info.Size,
+		FileHash:        info.Hash, // Empty when the file was announced by a peer that predates the hash field
+		ChunkCount:      len(info.Chunks),
+		ChunksAvailable: len(info.Chunks),
+		ReplicaCount:    info.Replicas,
+		StorageNodes:    nonNil(info.Nodes),
+		OwnerID:         info.Owner,
+		Tags:            []string{},
+		Categories:      []string{},
+		IsEncrypted:     info.Encrypted,
+		KeyMode:         keyModeOrDefault(info.KeyMode),
+		Status:          StatusDistributed,
+		CreatedAt:       info.CreatedAt,
+		ModifiedAt:      info.CreatedAt,
+	}
+}
+
+// ReceivedFile is a file a peer announced to this node: the canonical API
+// file plus the node that sent it
+type ReceivedFile struct {
+	File
+	SenderNode string    `json:"sender_node"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// FromReceivedFileInfo converts a file a peer announced into a received file
+func FromReceivedFileInfo(info *distributor.FileInfo) ReceivedFile {
+	file := FromFileInfo(info)
+	file.Status = StatusStored
+	return ReceivedFile{File: file, SenderNode: info.Owner, ReceivedAt: info.CreatedAt}
+}
+
+// FromFileMetadata converts basic metadata stored under its file ID into the canonical API file
+func FromFileMetadata(fileID string, meta metadata.FileMetadata) File {
+	createdAt := time.Unix(meta.CreatedAt, 0).UTC()
+	file := File{
+		FileID:          fileID,
+		FileName:        meta.FileName,
+		FileSize:        meta.FileSize,
+		FileHash:        meta.CurrentContentID(fileID), // SHA-256 of the current version's content
+		ChunkCount:      meta.NumChunks,
+		ChunksAvailable: meta.NumChunks,
+		StorageNodes:    []string{},
+		Tags:            []string{},
+		Categories:      []string{},
+		IsEncrypted:     true,
+		KeyMode:         keyModeOrDefault(meta.KeyMode),
+		Status:          StatusStored,
+		CreatedAt:       createdAt,
+		ModifiedAt:      createdAt,
+	}
+	if meta.ExpiresAt != 0 {
+		file = WithExpiry(file, time.Unix(meta.ExpiresAt, 0).UTC())
+	}
+	return file
+}
+
+// WithExpiry records when an ephemeral file expires and its remaining lifetime in whole seconds
+func WithExpiry(file File, expiresAt time.Time) File {
+	file.ExpiresAt = &expiresAt
+	file.RemainingSeconds = 0
+	if remaining := time.Until(expiresAt); remaining > 0 {
+		file.RemainingSeconds = int64(remaining / time.Second)
+	}
+	return file
+}
+
+// WithAvailability replaces the static chunk counts with a live availability result
+func WithAvailability(file File, availability *distributor.Availability) File {
+	if availability == nil {
+		return file
+	}
+	file.ChunkCount = availability.TotalChunks
+	file.ChunksAvailable = availability.AvailableChunks
+	file.Availability = availability
+	return file
+}
+
+// ToAPIFiles converts a slice of enhanced metadata
+func ToAPIFiles(metas []*metadata.EnhancedFileMetadata) []File {
+	files := make([]File, 0, len(metas))
+	for _, meta := range metas {
+		files = append(files, ToAPIFile(meta))
+	}
+	return files
+}
+
+func keyModeOrDefault(mode string) string {
+	if mode == "" {
+		return metadata.KeyModePassword
+	}
+	return mode
+}
+
+// nonNil keeps list fields serialised as [] rather than null
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var fixedTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func checkGolden(t *testing.T, name string, file any) {
+	t.Helper()
+	got, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal %s: %v", name, err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s output mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestToAPIFileGolden(t *testing.T) {
+	checkGolden(t, "enhanced", ToAPIFile(&metadata.EnhancedFileMetadata{
+		FileID:       "f00d",
+		FileName:     "report.pdf",
+		FileSize:     2048,
+		FileHash:     "f00d",
+		MimeType:     "application/pdf",
+		ChunkCount:   2,
+		ReplicaCount: 3,
+		StorageNodes: []string{"node-a", "node-b"},
+		OwnerID:      "user-1",
+		Tags:         []string{"uploaded"},
+		Categories:   []string{"user-upload"},
+		Description:  "quarterly report",
+		IsEncrypted:  true,
+		HealthStatus: "healthy",
+		CreatedAt:    fixedTime,
+	}))
+}
+
+func TestFromFileInfoGolden(t *testing.T) {
+	checkGolden(t, "fileinfo", FromFileInfo(&distributor.FileInfo{
+		ID:        "beef",
+		Name:      "photo.jpg",
+		Size:      4096,
+		Chunks:    []string{"c1", "c2", "c3"},
+		Replicas:  3,
+		CreatedAt: fixedTime,
+		Owner:     "node-a",
+		Encrypted: true,
+		KeyMode:   metadata.KeyModeServerManaged,
+		Nodes:     []string{"node-a"},
+	}))
+}
+
+func TestFromReceivedFileInfoGolden(t *testing.T) {
+	checkGolden(t, "received", FromReceivedFileInfo(&distributor.FileInfo{
+		ID:        "d00d",
+		Name:      "backup.zip",
+		Size:      8192,
+		Hash:      "d00d",
+		Chunks:    []string{"c1", "c2"},
+		Replicas:  2,
+		CreatedAt: fixedTime,
+		Owner:     "node-b",
+		Encrypted: true,
+		Nodes:     []string{"node-a", "node-b"},
+	}))
+}
+
+func TestFromFileMetadataGolden(t *testing.T) {
+	checkGolden(t, "filemetadata", FromFileMetadata("cafe", metadata.FileMetadata{
+		FileName:    "notes.txt",
+		FileSize:    128,
+		NumChunks:   1,
+		ChunkHashes: []string{"h1"},
+		CreatedAt:   fixedTime.Unix(),
+	}))
+}
+
+func TestFileHashIsTheCurrentContentHash(t *testing.T) {
+	if got := FromFileInfo(&distributor.FileInfo{ID: "beef", Hash: "f00d"}).FileHash; got != "f00d" {
+		t.Errorf("expected the distributor's content hash, got %q", got)
+	}
+	if got := FromFileInfo(&distributor.FileInfo{ID: "beef"}).FileHash; got != "" {
+		t.Errorf("expected an unknown hash left empty rather than filled in, got %q", got)
+	}
+	if got := FromFileMetadata("cafe", metadata.FileMetadata{Version: 2, ContentID: "d00d"}).FileHash; got != "d00d" {
+		t.Errorf("expected an updated file to report its current content hash, got %q", got)
+	}
+}
+
+// TestConvertersShareShape ensures every converter emits exactly the same set of keys
+func TestConvertersShareShape(t *testing.T) {
+	files := []File{
+		ToAPIFile(&metadata.EnhancedFileMetadata{}),
+		FromFileInfo(&distributor.FileInfo{}),
+		FromFileMetadata("", metadata.FileMetadata{}),
+	}
+
+	var reference []string
+	for i, file := range files {
+		data, _ := json.Marshal(file)
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("failed to decode converter %d output: %v", i, err)
+		}
+
+		keys := make([]string, 0, len(fields))
+		for key, value := range fields {
+			keys = append(keys, key)
+			if value == nil {
+				t.Errorf("converter %d emitted null for %q", i, key)
+			}
+		}
+		sort.Strings(keys)
+
+		if reference == nil {
+			reference = keys
+			continue
+		}
+		if len(keys) != len(reference) {
+			t.Fatalf("converter %d emitted %v, expected %v", i, keys, reference)
+		}
+		for j := range keys {
+			if keys[j] != reference[j] {
+				t.Errorf("converter %d key %q differs from %q", i, keys[j], reference[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ComponentMetadata is the metadata store; without it nothing can be chunked,
+// catalogued or reassembled, but peers, sessions and in-memory state still work
+const ComponentMetadata = "metadata"
+
+// Health statuses reported by /healthz and /readyz
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+// HealthReport is the body of /healthz and /readyz
+type HealthReport struct {
+	Status   string            `json:"status"`
+	Degraded map[string]string `json:"degraded,omitempty"` // Component to the reason it is down
+	Disabled []string          `json:"disabled,omitempty"` // Features turned off by the components that are down
+}
+
+// Health tracks components the node started without and the features that
+// depend on them. Handlers registered through Require answer 503 with the
+// reason while their component is down, so a missing component is one
+// well-defined degraded mode rather than nil checks spread over handlers.
+type Health struct {
+	mu       sync.RWMutex
+	down     map[string]string
+	features map[string][]string // Component to the features requiring it
+}
+
+// NewHealth creates a tracker with every component up
+func NewHealth() *Health {
+	return &Health{down: make(map[string]string), features: make(map[string][]string)}
+}
+
+// MarkDown records that component is unavailable, putting the node in degraded mode
+func (h *Health) MarkDown(component string, reason error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.down[component] = fmt.Sprint(reason)
+}
+
+// MarkUp records that component is available again
+func (h *Health) MarkUp(component string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.down, component)
+}
+
+// Degraded reports whether any component is down
+func (h *Health) Degraded() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.down) > 0
+}
+
+// Report describes the node's health and what is disabled
+func (h *Health) Report() HealthReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	report := HealthReport{Status: StatusOK}
+	if len(h.down) == 0 {
+		return report
+	}
+	report.Status = StatusDegraded
+	report.Degraded = make(map[string]string, len(h.down))
+	for component, reason := range h.down {
+		report.Degraded[component] = reason
+		report.Disabled = append(report.Disabled, h.features[component]...)
+	}
+	sort.Strings(report.Disabled)
+	return report
+}
+
+// Require serves next only while component is up. Otherwise the request is
+// refused with 503 and a message naming the feature and why it is off.
+func (h *Health) Require(component, feature string, next http.HandlerFunc) http.HandlerFunc {
+	h.mu.Lock()
+	if !containsString(h.features[component], feature) {
+		h.features[component] = append(h.features[component], feature)
+	}
+	h.mu.Unlock()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.mu.RLock()
+		reason, down := h.down[component]
+		h.mu.RUnlock()
+		if !down {
+			next(w, r)
+			return
+		}
+		writeHealthJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("%s is unavailable: the node is running in degraded mode without its %s component (%s)", feature, component, reason),
+			"data":    map[string]interface{}{"degraded": true, "component": component, "feature": feature},
+		})
+	}
+}
+
+// HandleHealthz reports liveness: 200 whenever the process serves requests,
+// with the status saying whether it is degraded
+func (h *Health) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthJSON(w, http.StatusOK, h.Report())
+}
+
+// HandleReadyz reports readiness: 503 while degraded, so load balancers can
+// prefer fully working nodes
+func (h *Health) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.Report()
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealthJSON(w, status, report)
+}
+
+func writeHealthJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
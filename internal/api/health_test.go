@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+func TestDegradedModeRefusesFeaturesPredictably(t *testing.T) {
+	health := NewHealth()
+
+	// The handler a degraded node must never reach: its store is nil
+	var metaStore *metadata.MetadataStore
+	upload := health.Require(ComponentMetadata, "uploads", func(w http.ResponseWriter, r *http.Request) {
+		metaStore.ListCollections()
+		w.WriteHeader(http.StatusOK)
+	})
+	catalog := health.Require(ComponentMetadata, "collections", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	probe := func(handler http.HandlerFunc, wantStatus int) map[string]interface{} {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != wantStatus {
+			t.Fatalf("expected status %d, got %d: %s", wantStatus, rec.Code, rec.Body.String())
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil && wantStatus != http.StatusOK {
+			t.Fatalf("expected a JSON body: %v", err)
+		}
+		return body
+	}
+
+	// Healthy: both probes pass
+	if body := probe(health.HandleReadyz, http.StatusOK); body["status"] != StatusOK {
+		t.Errorf("expected ok status, got %v", body)
+	}
+
+	// The metadata store failed to open at startup
+	health.MarkDown(ComponentMetadata, errors.New("database locked"))
+	body := probe(upload, http.StatusServiceUnavailable)
+	if message, _ := body["message"].(string); !strings.Contains(message, "uploads is unavailable") || !strings.Contains(message, "database locked") {
+		t.Errorf("expected a message naming the feature and cause, got %q", message)
+	}
+	if data, _ := body["data"].(map[string]interface{}); data["degraded"] != true || data["component"] != ComponentMetadata {
+		t.Errorf("expected degraded details, got %v", body["data"])
+	}
+	probe(catalog, http.StatusServiceUnavailable)
+
+	// Alive but not ready, and both say why and what is off
+	for _, handler := range []struct {
+		name   string
+		fn     http.HandlerFunc
+		status int
+	}{{"healthz", health.HandleHealthz, http.StatusOK}, {"readyz", health.HandleReadyz, http.StatusServiceUnavailable}} {
+		body := probe(handler.fn, handler.status)
+		if body["status"] != StatusDegraded {
+			t.Errorf("%s: expected degraded status, got %v", handler.name, body["status"])
+		}
+		if degraded, _ := body["degraded"].(map[string]interface{}); degraded[ComponentMetadata] != "database locked" {
+			t.Errorf("%s: expected the metadata component reported down, got %v", handler.name, body["degraded"])
+		}
+		disabled, _ := body["disabled"].([]interface{})
+		if len(disabled) != 2 || disabled[0] != "collections" || disabled[1] != "uploads" {
+			t.Errorf("%s: expected collections and uploads disabled, got %v", handler.name, disabled)
+		}
+	}
+
+	// Recovery re-enables the features
+	health.MarkUp(ComponentMetadata)
+	probe(catalog, http.StatusOK)
+	if body := probe(health.HandleReadyz, http.StatusOK); body["status"] != StatusOK || health.Degraded() {
+		t.Errorf("expected the node ready again, got %v", body)
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// Page size defaults for list endpoints, used when the config leaves them at zero
+const (
+	DefaultPageSize    = 100
+	DefaultMaxPageSize = 1000
+)
+
+// PageLimits bounds how many items one list response may hold
+type PageLimits struct {
+	Default int // Items returned when the request gives no limit
+	Max     int // Larger requested limits are clamped to this
+}
+
+// PageLimitsFromConfig reads page sizes from config, filling in defaults
+func PageLimitsFromConfig() PageLimits {
+	limits := PageLimits{Default: DefaultPageSize, Max: DefaultMaxPageSize}
+	cfg := config.Config
+	if cfg == nil {
+		return limits
+	}
+	if cfg.ListPageSize > 0 {
+		limits.Default = cfg.ListPageSize
+	}
+	if cfg.ListMaxPageSize > 0 {
+		limits.Max = cfg.ListMaxPageSize
+	}
+	if limits.Default > limits.Max {
+		limits.Default = limits.Max
+	}
+	return limits
+}
+
+// Page is the slice of a list a request asked for, after the limits applied
+type Page struct {
+	Limit   int
+	Offset  int
+	Clamped bool // The requested limit was above the maximum
+}
+
+// Page applies the limits to a requested limit and offset; a limit of zero or
+// less means the default
+func (l PageLimits) Page(limit, offset int) Page {
+	page := Page{Limit: limit, Offset: offset}
+	if page.Limit <= 0 {
+		page.Limit = l.Default
+	}
+	if l.Max > 0 && page.Limit > l.Max {
+		page.Limit = l.Max
+		page.Clamped = true
+	}
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+	return page
+}
+
+// ParsePage reads the limit and offset query parameters. Values that are not
+// non-negative integers are rejected; oversized limits are clamped.
+func ParsePage(query url.Values, limits PageLimits) (Page, error) {
+	values := map[string]int{}
+	for _, param := range []string{"limit", "offset"} {
+		raw := query.Get(param)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return Page{}, fmt.Errorf("invalid %s %q: must be a non-negative integer", param, raw)
+		}
+		values[param] = n
+	}
+	return limits.Page(values["limit"], values["offset"]), nil
+}
+
+// PageInfo describes a returned page of a list of TotalCount items
+type PageInfo struct {
+	TotalCount int  `json:"total_count"`
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	HasMore    bool `json:"has_more"`
+	NextOffset *int `json:"next_offset"` // Offset of the following page, nil on the last one
+	Clamped    bool `json:"clamped,omitempty"`
+}
+
+// Info describes this page of a list of total items
+func (p Page) Info(total int) PageInfo {
+	info := PageInfo{TotalCount: total, Limit: p.Limit, Offset: p.Offset, Clamped: p.Clamped}
+	if next := p.Offset + p.Limit; next < total {
+		info.HasMore = true
+		info.NextOffset = &next
+	}
+	return info
+}
+
+// Paginate returns the page's items out of a full list along with its description
+func Paginate[T any](items []T, page Page) ([]T, PageInfo) {
+	info := page.Info(len(items))
+	if page.Offset >= len(items) {
+		return make([]T, 0), info
+	}
+	end := page.Offset + page.Limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[page.Offset:end], info
+}
+
+// Body builds a list response holding the items under key next to the page fields
+func (i PageInfo) Body(key string, items interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		key:           items,
+		"total_count": i.TotalCount,
+		"limit":       i.Limit,
+		"offset":      i.Offset,
+		"has_more":    i.HasMore,
+		"next_offset": i.NextOffset,
+		"clamped":     i.Clamped,
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+func TestParsePageDefaultsAndClamps(t *testing.T) {
+	original := config.Config
+	defer func() { config.Config = original }()
+	config.Config = &config.AppConfig{ListPageSize: 10, ListMaxPageSize: 25}
+	limits := PageLimitsFromConfig()
+
+	items := make([]int, 60)
+	for i := range items {
+		items[i] = i
+	}
+
+	// No limit given: the configured default page
+	page, err := ParsePage(url.Values{}, limits)
+	if err != nil {
+		t.Fatalf("ParsePage failed: %v", err)
+	}
+	got, info := Paginate(items, page)
+	if len(got) != 10 || got[0] != 0 || info.TotalCount != 60 || !info.HasMore || info.NextOffset == nil || *info.NextOffset != 10 {
+		t.Fatalf("unexpected default page: %d items, %+v", len(got), info)
+	}
+
+	// Oversized limits are clamped to the maximum and flagged
+	page, err = ParsePage(url.Values{"limit": {"500"}, "offset": {"50"}}, limits)
+	if err != nil {
+		t.Fatalf("ParsePage failed: %v", err)
+	}
+	got, info = Paginate(items, page)
+	if page.Limit != 25 || !info.Clamped {
+		t.Fatalf("expected the limit clamped to 25, got %+v", page)
+	}
+	if len(got) != 10 || got[0] != 50 || info.HasMore || info.NextOffset != nil {
+		t.Fatalf("unexpected last page: %d items, %+v", len(got), info)
+	}
+
+	// Past the end is an empty page, not an error
+	page, _ = ParsePage(url.Values{"offset": {"100"}}, limits)
+	if got, info = Paginate(items, page); len(got) != 0 || info.HasMore {
+		t.Fatalf("expected an empty page past the end, got %d items", len(got))
+	}
+
+	for _, bad := range []url.Values{{"limit": {"ten"}}, {"limit": {"-1"}}, {"offset": {"-5"}}} {
+		if _, err := ParsePage(bad, limits); err == nil {
+			t.Errorf("expected %v to be rejected", bad)
+		}
+	}
+}
+
+func TestPageLimitsFromConfigFallsBack(t *testing.T) {
+	original := config.Config
+	defer func() { config.Config = original }()
+
+	config.Config = &config.AppConfig{}
+	if limits := PageLimitsFromConfig(); limits.Default != DefaultPageSize || limits.Max != DefaultMaxPageSize {
+		t.Fatalf("expected built-in limits, got %+v", limits)
+	}
+
+	// A default above the maximum is lowered to it
+	config.Config = &config.AppConfig{ListPageSize: 50, ListMaxPageSize: 20}
+	if limits := PageLimitsFromConfig(); limits.Default != 20 || limits.Max != 20 {
+		t.Fatalf("expected the default capped at the maximum, got %+v", limits)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+)
+
+// DefaultDownloadScratchDir holds plaintext reassembled for a download while it is served
+const DefaultDownloadScratchDir = "temp_downloads"
+
+// ServeReassembled reassembles a file into a scratch file of its own under
+// dir, streams it as an attachment and removes it once the response is
+// written, whether or not the client read it all. Concurrent downloads of the
+// same file each get their own scratch file. An error is returned before
+// anything was written, so the caller can still send its own response. A body
+// that cannot be sent in full aborts the handler with http.ErrAbortHandler.
+// The scratch file is plaintext, so strict encryption refuses it; use
+// StreamReassembled instead.
+func ServeReassembled(w http.ResponseWriter, dir, fileName string, reassemble func(outputPath string) error) (int64, error) {
+	if err := encryptor.RefusePlaintext("download scratch file in " + dir); err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create download directory: %v", err)
+	}
+	scratch, err := os.CreateTemp(dir, "download-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create download file: %v", err)
+	}
+	outputPath := scratch.Name()
+	scratch.Close()
+	defer os.Remove(outputPath)
+	// A scratch file is never resumed, so an interrupted reassembly's progress goes with it
+	defer os.Remove(outputPath + chunker.ProgressSuffix)
+
+	if err := reassemble(outputPath); err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open reassembled file: %v", err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat reassembled file: %v", err)
+	}
+
+	writeAttachmentHeader(w, fileName, st.Size())
+	// The headers are out, so a short body can only be signalled by dropping
+	// the connection; a clean end would hand the client a truncated file
+	if n, err := io.Copy(w, f); err != nil || n != st.Size() {
+		fmt.Printf("⚠️ Failed streaming file: sent %d of %d bytes: %v\n", n, st.Size(), err)
+		panic(http.ErrAbortHandler)
+	}
+	return st.Size(), nil
+}
+
+// StreamReassembled streams a file of size bytes as an attachment straight
+// from read, which writes the file to the writer it is given, so nothing is
+// staged on disk. The headers go out with the first byte: an error before
+// then is returned for the caller to report, and a body cut short afterwards
+// aborts the handler with http.ErrAbortHandler.
+func StreamReassembled(w http.ResponseWriter, fileName string, size int64, read func(w io.Writer) (int64, error)) (int64, error) {
+	body := &attachmentWriter{w: w, fileName: fileName, size: size}
+	n, err := read(body)
+	if !body.started {
+		if err != nil {
+			return 0, err
+		}
+		writeAttachmentHeader(w, fileName, size)
+	}
+	if err != nil || n != size {
+		fmt.Printf("⚠️ Failed streaming file: sent %d of %d bytes: %v\n", n, size, err)
+		panic(http.ErrAbortHandler)
+	}
+	return n, nil
+}
+
+// attachmentWriter sends the attachment headers before the first byte of the body
+type attachmentWriter struct {
+	w        http.ResponseWriter
+	fileName string
+	size     int64
+	started  bool
+}
+
+func (a *attachmentWriter) Write(p []byte) (int, error) {
+	if !a.started && len(p) > 0 {
+		writeAttachmentHeader(a.w, a.fileName, a.size)
+		a.started = true
+	}
+	return a.w.Write(p)
+}
+
+func writeAttachmentHeader(w http.ResponseWriter, fileName string, size int64) {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+	w.WriteHeader(http.StatusOK)
+}
+
+// SweepDir removes files under dir last modified more than olderThan ago,
+// or every file when olderThan is 0. Directories are left in place. It
+// returns how many files were removed; a missing dir is not an error.
+func SweepDir(dir string, olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if olderThan > 0 {
+			info, err := entry.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				return nil
+			}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
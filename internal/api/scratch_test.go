@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServeReassembledLeavesNoPlaintext(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "downloads")
+	plaintext := []byte("decrypted contents that must not stay on disk")
+
+	// Two downloads of the same file overlap; neither may remove the other's output
+	var started sync.WaitGroup
+	started.Add(2)
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, 2)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			_, err := ServeReassembled(rec, dir, "secret.txt", func(outputPath string) error {
+				if err := os.WriteFile(outputPath, plaintext, 0600); err != nil {
+					return err
+				}
+				started.Done()
+				started.Wait()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("download failed: %v", err)
+			}
+		}(recorders[i])
+	}
+	wg.Wait()
+
+	for i, rec := range recorders {
+		if rec.Body.String() != string(plaintext) {
+			t.Errorf("download %d got %q", i, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="secret.txt"` {
+			t.Errorf("download %d has Content-Disposition %q", i, got)
+		}
+	}
+
+	// A failed reassembly writes no response and leaves nothing behind either
+	rec := httptest.NewRecorder()
+	if _, err := ServeReassembled(rec, dir, "secret.txt", func(outputPath string) error {
+		os.WriteFile(outputPath, plaintext[:10], 0600)
+		return errors.New("wrong password")
+	}); err == nil {
+		t.Error("expected the reassembly error")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("nothing should be written on failure, got %q", rec.Body.String())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read download directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no plaintext left behind, found %d files", len(entries))
+	}
+}
+
+func TestSweepDirRemovesOldFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "nested", "old.bin")
+	recent := filepath.Join(dir, "recent.bin")
+	os.MkdirAll(filepath.Dir(old), 0755)
+	os.WriteFile(old, []byte("old"), 0644)
+	os.WriteFile(recent, []byte("recent"), 0644)
+	past := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(old, past, past)
+
+	removed, err := SweepDir(dir, time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 file removed, got %d (%v)", removed, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("expected the old file removed")
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Error("expected the recent file kept")
+	}
+
+	// Startup sweeps clear everything, and a directory that was never created is fine
+	if removed, err := SweepDir(dir, 0); err != nil || removed != 1 {
+		t.Errorf("expected the remaining file removed, got %d (%v)", removed, err)
+	}
+	if _, err := SweepDir(filepath.Join(dir, "missing"), 0); err != nil {
+		t.Errorf("a missing directory should not be an error: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// Server limit defaults, used when the config leaves a value at zero
+const (
+	DefaultReadHeaderTimeout  = 10 * time.Second
+	DefaultReadTimeout        = 60 * time.Second
+	DefaultWriteTimeout       = 60 * time.Second
+	DefaultIdleTimeout        = 120 * time.Second
+	DefaultLongRequestTimeout = 30 * time.Minute
+	DefaultMaxHeaderBytes     = 1 << 20
+	DefaultMaxBodyBytes       = 10 << 20
+)
+
+// ServerLimits holds the timeouts and size limits applied to the HTTP server
+type ServerLimits struct {
+	ReadHeaderTimeout  time.Duration
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	IdleTimeout        time.Duration
+	LongRequestTimeout time.Duration // Deadline for uploads, downloads and reassembly
+	MaxHeaderBytes     int
+	MaxBodyBytes       int64 // Body cap for endpoints that are not uploads
+}
+
+// ServerLimitsFromConfig reads server limits from config, filling in defaults
+func ServerLimitsFromConfig() ServerLimits {
+	limits := ServerLimits{}
+	if cfg := config.Config; cfg != nil {
+		limits.ReadHeaderTimeout = time.Duration(cfg.HTTPReadHeaderTimeout) * time.Second
+		limits.ReadTimeout = time.Duration(cfg.HTTPReadTimeout) * time.Second
+		limits.WriteTimeout = time.Duration(cfg.HTTPWriteTimeout) * time.Second
+		limits.IdleTimeout = time.Duration(cfg.HTTPIdleTimeout) * time.Second
+		limits.LongRequestTimeout = time.Duration(cfg.HTTPLongRequestTimeout) * time.Second
+		limits.MaxHeaderBytes = cfg.HTTPMaxHeaderBytes
+		limits.MaxBodyBytes = cfg.HTTPMaxBodyBytes
+	}
+
+	if limits.ReadHeaderTimeout <= 0 {
+		limits.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if limits.ReadTimeout <= 0 {
+		limits.ReadTimeout = DefaultReadTimeout
+	}
+	if limits.WriteTimeout <= 0 {
+		limits.WriteTimeout = DefaultWriteTimeout
+	}
+	if limits.IdleTimeout <= 0 {
+		limits.IdleTimeout = DefaultIdleTimeout
+	}
+	if limits.LongRequestTimeout <= 0 {
+		limits.LongRequestTimeout = DefaultLongRequestTimeout
+	}
+	if limits.MaxHeaderBytes <= 0 {
+		limits.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if limits.MaxBodyBytes <= 0 {
+		limits.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	return limits
+}
+
+// NewServer creates an http.Server with the given timeouts and header limit
+func NewServer(addr string, handler http.Handler, limits ServerLimits) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: limits.ReadHeaderTimeout,
+		ReadTimeout:       limits.ReadTimeout,
+		WriteTimeout:      limits.WriteTimeout,
+		IdleTimeout:       limits.IdleTimeout,
+		MaxHeaderBytes:    limits.MaxHeaderBytes,
+	}
+}
+
+// PeerServer returns a builder for the P2P HTTP server that applies the same
+// timeouts and body cap as the API server, for p2p.Network.SetHTTPServer
+func PeerServer(limits ServerLimits) func(http.Handler) *http.Server {
+	return func(handler http.Handler) *http.Server {
+		return NewServer("", LimitBodies(handler, limits.MaxBodyBytes), limits)
+	}
+}
+
+// LimitBodies caps request bodies at maxBytes, except for the exempt (upload) paths
+func LimitBodies(next http.Handler, maxBytes int64, exempt ...string) http.Handler {
+	skip := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		skip[path] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && !skip[r.URL.Path] {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithTraceID gives every request a trace ID, taken from the X-Trace-ID
+// header when the client sent a valid one, and echoes it on the response
+func WithTraceID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, traceID := tracing.FromRequest(r)
+		if traceID != "" {
+			w.Header().Set(tracing.Header, traceID)
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithDeadline replaces the server-wide read/write deadlines for one handler.
+// A zero timeout removes them, which long-lived SSE streams need; a positive
+// timeout suits uploads and downloads that outlast the normal WriteTimeout.
+func WithDeadline(next http.HandlerFunc, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deadline := time.Time{}
+		if timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+
+		rc := http.NewResponseController(w)
+		// Writers that cannot change deadlines keep the server defaults
+		_ = rc.SetReadDeadline(deadline)
+		_ = rc.SetWriteDeadline(deadline)
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseHandler emits one event every 50ms for 300ms, outliving a 100ms WriteTimeout
+func sseHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(w, "data: %d\n\n", i)
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func countEvents(url string) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+
+	events := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			events++
+		}
+	}
+	return events
+}
+
+func TestStreamingEndpointsSurviveWriteTimeout(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", WithDeadline(sseHandler, 0))
+	mux.HandleFunc("/plain", sseHandler)
+
+	limits := ServerLimits{
+		ReadHeaderTimeout: time.Second,
+		ReadTimeout:       100 * time.Millisecond,
+		WriteTimeout:      100 * time.Millisecond,
+		IdleTimeout:       time.Second,
+		MaxHeaderBytes:    DefaultMaxHeaderBytes,
+	}
+	ts := httptest.NewUnstartedServer(mux)
+	ts.Config = NewServer("", mux, limits)
+	ts.Start()
+	defer ts.Close()
+
+	if got := countEvents(ts.URL + "/stream"); got != 6 {
+		t.Errorf("expected all 6 SSE events with the deadline lifted, got %d", got)
+	}
+	if got := countEvents(ts.URL + "/plain"); got >= 6 {
+		t.Errorf("expected the write timeout to cut off an unwrapped stream, got %d events", got)
+	}
+}
+
+func TestLimitBodies(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := LimitBodies(echo, 16, "/upload")
+	body := strings.Repeat("x", 64)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/search", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected oversized body to be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected upload path to be exempt, got %d", rec.Code)
+	}
+}
+
+func TestPeerServerAppliesLimits(t *testing.T) {
+	limits := ServerLimits{ReadHeaderTimeout: time.Second, ReadTimeout: 2 * time.Second, IdleTimeout: 3 * time.Second, MaxBodyBytes: 16}
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	server := PeerServer(limits)(echo)
+	if server.ReadHeaderTimeout != time.Second || server.ReadTimeout != 2*time.Second || server.IdleTimeout != 3*time.Second {
+		t.Fatalf("expected configured timeouts, got %+v", server)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(strings.Repeat("x", 64))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected oversized peer body to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/budget"
+	"github.com/jaywantadh/DisktroByte/internal/cache"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/parallelism"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/telemetry"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// SystemStats is the JSON shape returned by the system stats endpoint
+type SystemStats struct {
+	TotalFiles        int              `json:"total_files"`
+	ActivePeers       int              `json:"active_peers"`
+	StreamingSessions int              `json:"streaming_sessions"`
+	TotalStorage      int64            `json:"total_storage"`    // Bytes physically stored on this node
+	LogicalStorage    int64            `json:"logical_storage"`  // Sum of original file sizes
+	StorageByOwner    map[string]int64 `json:"storage_by_owner"` // Original bytes per owner
+	StorageByNode     map[string]int64 `json:"storage_by_node"`  // Original bytes held per node
+	Uptime            float64          `json:"uptime"`           // Seconds since StartedAt
+	StartedAt         time.Time        `json:"started_at"`
+	WarmCache         *cache.WarmStats `json:"warm_cache,omitempty"`    // Reassembly cache hit rate, when enabled
+	MemoryBudget      *budget.Stats    `json:"memory_budget,omitempty"` // Current and peak in-flight chunk bytes
+
+	BackgroundThrottle *throttle.Stats    `json:"background_throttle,omitempty"` // Foreground load and background job levels
+	Parallelism        *parallelism.Stats `json:"parallelism,omitempty"`         // Chosen chunk-fetch concurrency and why
+	Telemetry          *telemetry.Stats   `json:"telemetry,omitempty"`           // OpenTelemetry export progress, when enabled
+
+	OriginalCache *cache.PassthroughStats `json:"original_cache,omitempty"` // Cached upload originals, when enabled
+
+	HaveNeed *distributor.HaveNeedStats `json:"have_need,omitempty"` // Chunk bytes replication did not resend, when enabled
+}
+
+// StatsSources gathers everything CollectSystemStats reads from
+type StatsSources struct {
+	Store             storage.Storage // Wrap in a storage.UsageCache to avoid measuring it on every call
+	MetaStore         *metadata.MetadataStore
+	Files             []File
+	ActivePeers       int
+	StreamingSessions int
+	StartedAt         time.Time
+	WarmCache         *cache.WarmCache
+	OriginalCache     *cache.Passthrough
+	Memory            *budget.Budget
+	Throttle          *throttle.Throttle
+	Parallelism       *parallelism.Controller
+	Telemetry         *telemetry.Exporter
+	Distributor       *distributor.Distributor
+}
+
+// CollectSystemStats computes real system statistics from the given sources
+func CollectSystemStats(src StatsSources) SystemStats {
+	stats := SystemStats{
+		TotalFiles:        len(src.Files),
+		ActivePeers:       src.ActivePeers,
+		StreamingSessions: src.StreamingSessions,
+		StorageByOwner:    make(map[string]int64),
+		StorageByNode:     make(map[string]int64),
+		StartedAt:         src.StartedAt,
+	}
+	if !src.StartedAt.IsZero() {
+		stats.Uptime = time.Since(src.StartedAt).Seconds()
+	}
+
+	if src.WarmCache.Enabled() {
+		warm := src.WarmCache.Stats()
+		stats.WarmCache = &warm
+	}
+
+	if src.OriginalCache != nil && src.OriginalCache.Enabled() {
+		original := src.OriginalCache.Stats()
+		stats.OriginalCache = &original
+	}
+
+	if src.Memory != nil {
+		memory := src.Memory.Stats()
+		stats.MemoryBudget = &memory
+	}
+
+	if src.Throttle != nil {
+		throttled := src.Throttle.Stats()
+		stats.BackgroundThrottle = &throttled
+	}
+
+	if src.Parallelism != nil {
+		fetches := src.Parallelism.Stats()
+		stats.Parallelism = &fetches
+	}
+
+	if exported := src.Telemetry.Stats(); exported.Enabled {
+		stats.Telemetry = &exported
+	}
+
+	if src.Distributor != nil {
+		if replicated := src.Distributor.HaveNeedStats(); replicated.Enabled {
+			stats.HaveNeed = &replicated
+		}
+	}
+
+	if src.Store != nil {
+		if used, err := src.Store.Usage(); err == nil {
+			stats.TotalStorage = used
+		}
+	}
+
+	// The metadata store is authoritative for the file count when present;
+	// like the breakdown below it leaves out deleted and expired files
+	if src.MetaStore != nil {
+		if count, err := src.MetaStore.CountAvailableFiles(time.Now()); err == nil {
+			stats.TotalFiles = count
+		}
+	}
+
+	for _, file := range src.Files {
+		stats.LogicalStorage += file.FileSize
+
+		owner := file.OwnerID
+		if owner == "" {
+			owner = "unknown"
+		}
+		stats.StorageByOwner[owner] += file.FileSize
+
+		for _, node := range file.StorageNodes {
+			stats.StorageByNode[node] += file.FileSize
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestCollectSystemStatsMatchesStoredFiles(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2}
+	tempDir := t.TempDir()
+
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	owners := []string{"alice", "bob", "alice"}
+	var files []File
+	for i, owner := range owners {
+		path := filepath.Join(tempDir, fmt.Sprintf("file%d.txt", i))
+		content := make([]byte, 1000*(i+1))
+		content[0] = byte(i)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("failed to write input: %v", err)
+		}
+
+		chunks, err := chunker.ChunkAndStore(path, "pw", metaStore, store)
+		if err != nil {
+			t.Fatalf("ChunkAndStore failed: %v", err)
+		}
+		fileMeta, _ := metaStore.GetFileMetadataByID(chunks[0].FileID)
+		file := FromFileMetadata(chunks[0].FileID, fileMeta)
+		file.OwnerID = owner
+		file.StorageNodes = []string{"node-1"}
+		files = append(files, file)
+	}
+
+	// Deleted and expired files are in neither the listing nor the count
+	for i, change := range []func(*metadata.FileMetadata){
+		func(f *metadata.FileMetadata) { f.DeletedAt = time.Now().Unix() },
+		func(f *metadata.FileMetadata) { f.ExpiresAt = time.Now().Add(-time.Minute).Unix() },
+	} {
+		path := filepath.Join(tempDir, fmt.Sprintf("gone%d.txt", i))
+		os.WriteFile(path, []byte(fmt.Sprintf("a file that is gone %d", i)), 0644)
+		chunks, err := chunker.ChunkAndStore(path, "pw", metaStore, store)
+		if err != nil {
+			t.Fatalf("ChunkAndStore failed: %v", err)
+		}
+		fileMeta, _ := metaStore.GetFileMetadataByID(chunks[0].FileID)
+		change(&fileMeta)
+		metaStore.PutFileMetadataByID(chunks[0].FileID, fileMeta)
+	}
+
+	startedAt := time.Now().Add(-time.Minute)
+	stats := CollectSystemStats(StatsSources{
+		Store:       store,
+		MetaStore:   metaStore,
+		Files:       files,
+		ActivePeers: 2,
+		StartedAt:   startedAt,
+	})
+
+	expectedUsage, _ := store.Usage()
+	if stats.TotalStorage != expectedUsage || stats.TotalStorage == 0 {
+		t.Errorf("total storage = %d, expected %d", stats.TotalStorage, expectedUsage)
+	}
+	if stats.TotalFiles != 3 {
+		t.Errorf("total files = %d, expected 3", stats.TotalFiles)
+	}
+	if stats.LogicalStorage != 6000 {
+		t.Errorf("logical storage = %d, expected 6000", stats.LogicalStorage)
+	}
+	if stats.StorageByOwner["alice"] != 4000 || stats.StorageByOwner["bob"] != 2000 {
+		t.Errorf("unexpected per-owner breakdown: %v", stats.StorageByOwner)
+	}
+	if stats.StorageByNode["node-1"] != 6000 {
+		t.Errorf("unexpected per-node breakdown: %v", stats.StorageByNode)
+	}
+	if stats.ActivePeers != 2 {
+		t.Errorf("active peers = %d, expected 2", stats.ActivePeers)
+	}
+	if stats.Uptime < 60 || stats.Uptime > 120 {
+		t.Errorf("uptime = %.1f, expected about 60 seconds", stats.Uptime)
+	}
+}
@@ -37,6 +37,8 @@ type User struct {
 	Profile       UserProfile `json:"profile"`
 	Permissions   []string  `json:"permissions"`
 	SessionToken  string    `json:"session_token,omitempty"`
+	// Namespace the user's files live in, empty for the default tenant
+	Tenant        string    `json:"tenant,omitempty"`
 }
 
 // UserProfile contains user profile information
@@ -60,6 +62,8 @@ type Session struct {
 	IPAddress    string    `json:"ip_address"`
 	UserAgent    string    `json:"user_agent"`
 	IsActive     bool      `json:"is_active"`
+	// Last time the user proved their password in this session, at login or on re-authentication
+	ReauthenticatedAt time.Time `json:"reauthenticated_at"`
 }
 
 // AuthManager manages user authentication and sessions
@@ -70,6 +74,12 @@ type AuthManager struct {
 	mu           sync.RWMutex
 	sessionTTL   time.Duration
 	maxSessions  int
+	idleTimeout  time.Duration // Sessions idle longer than this are reaped (0 disables)
+	stepUpWindow time.Duration // How recent a password check sensitive operations need (0 disables)
+	reapInterval time.Duration
+	reapReset    chan struct{}
+	stopChan     chan struct{}
+	stopOnce     sync.Once
 }
 
 // LoginRequest represents a login request
@@ -77,6 +87,9 @@ type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	NodeID   string `json:"node_id"`
+	// Request source, filled in by the HTTP handler
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 // LoginResponse represents a login response
@@ -96,6 +109,7 @@ type RegisterRequest struct {
 	NodeID       string      `json:"node_id"`
 	Role         UserRole    `json:"role"`
 	Profile      UserProfile `json:"profile"`
+	Tenant       string      `json:"tenant,omitempty"`
 }
 
 // NewAuthManager creates a new authentication manager
@@ -111,8 +125,13 @@ func NewAuthManager(sessionTTL time.Duration, maxSessions int) *AuthManager {
 		users:       make(map[string]*User),
 		sessions:    make(map[string]*Session),
 		usersByName: make(map[string]*User),
-		sessionTTL:  sessionTTL,
-		maxSessions: maxSessions,
+		sessionTTL:   sessionTTL,
+		maxSessions:  maxSessions,
+		idleTimeout:  DefaultIdleTimeout,
+		stepUpWindow: DefaultStepUpWindow,
+		reapInterval: DefaultReapInterval,
+		reapReset:    make(chan struct{}, 1),
+		stopChan:     make(chan struct{}),
 	}
 
 	// Create default admin user
@@ -183,6 +202,10 @@ func (am *AuthManager) Register(req RegisterRequest) (*User, error) {
 		req.Role = RoleUser
 	}
 
+	if err := ValidateTenant(req.Tenant); err != nil {
+		return nil, err
+	}
+
 	// Generate node ID if not provided
 	nodeID := req.NodeID
 	if nodeID == "" {
@@ -199,6 +222,7 @@ func (am *AuthManager) Register(req RegisterRequest) (*User, error) {
 		IsActive:  true,
 		Profile:   req.Profile,
 		Permissions: am.getDefaultPermissions(req.Role),
+		Tenant:    req.Tenant,
 	}
 
 	// Hash password
@@ -244,7 +268,7 @@ func (am *AuthManager) Login(req LoginRequest) (*LoginResponse, error) {
 	}
 
 	// Create session
-	session, err := am.createSession(user)
+	session, err := am.createSession(user, req.IPAddress, req.UserAgent)
 	if err != nil {
 		return &LoginResponse{
 			Success: false,
@@ -295,16 +319,16 @@ func (am *AuthManager) Logout(token string) error {
 
 // ValidateSession validates a session token
 func (am *AuthManager) ValidateSession(token string) (*User, error) {
-	am.mu.RLock()
-	defer am.mu.RUnlock()
+	am.mu.Lock()
+	defer am.mu.Unlock()
 
 	session, exists := am.sessions[token]
 	if !exists || !session.IsActive {
 		return nil, fmt.Errorf("invalid or expired session")
 	}
 
-	// Check expiration
-	if time.Now().After(session.ExpiresAt) {
+	// Check expiration and idleness
+	if am.isSessionStale(session, time.Now()) {
 		return nil, fmt.Errorf("session expired")
 	}
 
@@ -376,6 +400,13 @@ func (am *AuthManager) UpdateUser(userID string, updates map[string]interface{})
 			if v, ok := value.(bool); ok {
 				user.IsActive = v
 			}
+		case "tenant":
+			if v, ok := value.(string); ok {
+				if err := ValidateTenant(v); err != nil {
+					return err
+				}
+				user.Tenant = v
+			}
 		}
 	}
 
@@ -384,7 +415,7 @@ func (am *AuthManager) UpdateUser(userID string, updates map[string]interface{})
 }
 
 // createSession creates a new session for a user
-func (am *AuthManager) createSession(user *User) (*Session, error) {
+func (am *AuthManager) createSession(user *User, ipAddress, userAgent string) (*Session, error) {
 	// Check session limit
 	if len(am.sessions) >= am.maxSessions {
 		return nil, fmt.Errorf("maximum sessions reached")
@@ -402,7 +433,11 @@ func (am *AuthManager) createSession(user *User) (*Session, error) {
 		CreatedAt:    time.Now(),
 		ExpiresAt:    time.Now().Add(am.sessionTTL),
 		LastActivity: time.Now(),
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
 		IsActive:     true,
+
+		ReauthenticatedAt: time.Now(),
 	}
 
 	am.sessions[token] = session
@@ -477,27 +512,24 @@ func (am *AuthManager) getDefaultPermissions(role UserRole) []string {
 	return []string{}
 }
 
-// sessionCleanup removes expired sessions
+// sessionCleanup periodically reaps expired and idle sessions
 func (am *AuthManager) sessionCleanup() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		am.mu.Lock()
-		now := time.Now()
-		expired := 0
-
-		for token, session := range am.sessions {
-			if now.After(session.ExpiresAt) || !session.IsActive {
-				delete(am.sessions, token)
-				expired++
+	for {
+		am.mu.RLock()
+		interval := am.reapInterval
+		am.mu.RUnlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+			if reaped := am.ReapSessions(); reaped > 0 {
+				fmt.Printf("🧹 Cleaned up %d expired or idle sessions\n", reaped)
 			}
-		}
-
-		am.mu.Unlock()
-
-		if expired > 0 {
-			fmt.Printf("🧹 Cleaned up %d expired sessions\n", expired)
+		case <-am.reapReset:
+			timer.Stop()
+		case <-am.stopChan:
+			timer.Stop()
+			return
 		}
 	}
 }
@@ -519,7 +551,7 @@ func (am *AuthManager) GetUserStats() map[string]interface{} {
 
 	stats := map[string]interface{}{
 		"total_users":    len(am.users),
-		"active_sessions": len(am.sessions),
+		"active_sessions": am.countActiveSessions(time.Now()),
 		"roles": map[string]int{
 			"admin":      0,
 			"superadmin": 0,
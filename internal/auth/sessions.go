@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultIdleTimeout is how long a session may go unused before it is reaped
+	DefaultIdleTimeout = 2 * time.Hour
+	// DefaultReapInterval is how often the background reaper runs
+	DefaultReapInterval = 1 * time.Minute
+)
+
+// SessionInfo describes an active session for admin listings (the token is never exposed)
+type SessionInfo struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	Username     string    `json:"username"`
+	Role         UserRole  `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	IPAddress    string    `json:"ip_address"`
+	UserAgent    string    `json:"user_agent"`
+	// Last password check in the session, which gates sensitive operations
+	ReauthenticatedAt time.Time `json:"reauthenticated_at"`
+}
+
+// SetIdleTimeout sets how long a session may be idle before it is reaped (0 disables idle reaping)
+func (am *AuthManager) SetIdleTimeout(timeout time.Duration) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.idleTimeout = timeout
+}
+
+// SetReapInterval changes how often the background reaper runs
+func (am *AuthManager) SetReapInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	am.mu.Lock()
+	am.reapInterval = interval
+	am.mu.Unlock()
+
+	select {
+	case am.reapReset <- struct{}{}:
+	default:
+	}
+}
+
+// Stop terminates the background session reaper
+func (am *AuthManager) Stop() {
+	am.stopOnce.Do(func() {
+		close(am.stopChan)
+	})
+}
+
+// isSessionStale reports whether a session is expired, idle or inactive. Callers must hold am.mu.
+func (am *AuthManager) isSessionStale(session *Session, now time.Time) bool {
+	if !session.IsActive || now.After(session.ExpiresAt) {
+		return true
+	}
+	return am.idleTimeout > 0 && now.Sub(session.LastActivity) > am.idleTimeout
+}
+
+// countActiveSessions counts sessions that are still usable. Callers must hold am.mu.
+func (am *AuthManager) countActiveSessions(now time.Time) int {
+	count := 0
+	for _, session := range am.sessions {
+		if !am.isSessionStale(session, now) {
+			count++
+		}
+	}
+	return count
+}
+
+// ReapSessions removes expired, idle and inactive sessions and returns how many were removed
+func (am *AuthManager) ReapSessions() int {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	now := time.Now()
+	reaped := 0
+	for token, session := range am.sessions {
+		if am.isSessionStale(session, now) {
+			am.removeSession(token, session)
+			reaped++
+		}
+	}
+	return reaped
+}
+
+// GetActiveSessions lists all usable sessions, most recently active first
+func (am *AuthManager) GetActiveSessions() []SessionInfo {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	now := time.Now()
+	sessions := make([]SessionInfo, 0, len(am.sessions))
+	for _, session := range am.sessions {
+		if am.isSessionStale(session, now) {
+			continue
+		}
+
+		info := SessionInfo{
+			ID:           session.ID,
+			UserID:       session.UserID,
+			CreatedAt:    session.CreatedAt,
+			LastActivity: session.LastActivity,
+			ExpiresAt:    session.ExpiresAt,
+			IPAddress:    session.IPAddress,
+			UserAgent:    session.UserAgent,
+
+			ReauthenticatedAt: session.ReauthenticatedAt,
+		}
+		if user, exists := am.users[session.UserID]; exists {
+			info.Username = user.Username
+			info.Role = user.Role
+		}
+		sessions = append(sessions, info)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActivity.After(sessions[j].LastActivity)
+	})
+	return sessions
+}
+
+// RevokeSession forcibly ends the session with the given session ID
+func (am *AuthManager) RevokeSession(sessionID string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	for token, session := range am.sessions {
+		if session.ID == sessionID {
+			am.removeSession(token, session)
+			fmt.Printf("⛔ Session revoked: %s (User: %s)\n", session.ID, session.UserID)
+			return nil
+		}
+	}
+	return fmt.Errorf("session not found")
+}
+
+// removeSession deletes a session and clears the owner's token. Callers must hold am.mu.
+func (am *AuthManager) removeSession(token string, session *Session) {
+	session.IsActive = false
+	if user, exists := am.users[session.UserID]; exists && user.SessionToken == token {
+		user.SessionToken = ""
+	}
+	delete(am.sessions, token)
+}
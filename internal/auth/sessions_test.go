@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func loginAdmin(t *testing.T, am *AuthManager) *LoginResponse {
+	t.Helper()
+	resp, err := am.Login(LoginRequest{Username: "admin", Password: "admin123", IPAddress: "10.0.0.1:5000", UserAgent: "test"})
+	if err != nil || !resp.Success {
+		t.Fatalf("login failed: %v %+v", err, resp)
+	}
+	return resp
+}
+
+func TestReapSessionsRemovesIdleAndExpired(t *testing.T) {
+	am := NewAuthManager(time.Hour, 10)
+	defer am.Stop()
+	am.SetIdleTimeout(time.Minute)
+
+	idle := loginAdmin(t, am)
+	expired := loginAdmin(t, am)
+	fresh := loginAdmin(t, am)
+
+	am.mu.Lock()
+	am.sessions[idle.Token].LastActivity = time.Now().Add(-2 * time.Minute)
+	am.sessions[expired.Token].ExpiresAt = time.Now().Add(-time.Second)
+	am.mu.Unlock()
+
+	if got := am.GetUserStats()["active_sessions"]; got != 1 {
+		t.Errorf("active_sessions = %v, expected 1", got)
+	}
+
+	if reaped := am.ReapSessions(); reaped != 2 {
+		t.Errorf("reaped %d sessions, expected 2", reaped)
+	}
+	if _, err := am.ValidateSession(idle.Token); err == nil {
+		t.Errorf("idle session should no longer validate")
+	}
+	if _, err := am.ValidateSession(fresh.Token); err != nil {
+		t.Errorf("fresh session should still validate: %v", err)
+	}
+
+	am.mu.RLock()
+	remaining := len(am.sessions)
+	am.mu.RUnlock()
+	if remaining != 1 {
+		t.Errorf("expected 1 session left in memory, found %d", remaining)
+	}
+}
+
+func TestBackgroundReaper(t *testing.T) {
+	am := NewAuthManager(time.Hour, 10)
+	defer am.Stop()
+	am.SetReapInterval(10 * time.Millisecond)
+
+	resp := loginAdmin(t, am)
+	am.mu.Lock()
+	am.sessions[resp.Token].ExpiresAt = time.Now().Add(-time.Second)
+	am.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		am.mu.RLock()
+		_, exists := am.sessions[resp.Token]
+		am.mu.RUnlock()
+		if !exists {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("background reaper did not remove expired session")
+}
+
+func TestGetActiveSessionsAndRevoke(t *testing.T) {
+	am := NewAuthManager(time.Hour, 10)
+	defer am.Stop()
+
+	first := loginAdmin(t, am)
+	second := loginAdmin(t, am)
+
+	sessions := am.GetActiveSessions()
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 active sessions, got %d", len(sessions))
+	}
+	for _, s := range sessions {
+		if s.Username != "admin" || s.IPAddress != "10.0.0.1:5000" || s.UserAgent != "test" {
+			t.Errorf("unexpected session info: %+v", s)
+		}
+	}
+
+	var firstID string
+	am.mu.RLock()
+	firstID = am.sessions[first.Token].ID
+	am.mu.RUnlock()
+
+	if err := am.RevokeSession(firstID); err != nil {
+		t.Fatalf("failed to revoke session: %v", err)
+	}
+	if _, err := am.ValidateSession(first.Token); err == nil {
+		t.Errorf("revoked session should not validate")
+	}
+	if _, err := am.ValidateSession(second.Token); err != nil {
+		t.Errorf("other session should still validate: %v", err)
+	}
+	if err := am.RevokeSession(firstID); err == nil {
+		t.Errorf("revoking an unknown session should fail")
+	}
+	if len(am.GetActiveSessions()) != 1 {
+		t.Errorf("expected 1 active session after revoke")
+	}
+}
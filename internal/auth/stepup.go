@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultStepUpWindow is how long a password check keeps sensitive operations open
+const DefaultStepUpWindow = 5 * time.Minute
+
+// ErrReauthRequired is returned for a sensitive operation in a session whose
+// last password check is older than the step-up window
+var ErrReauthRequired = errors.New("re-authentication required")
+
+// SetStepUpWindow sets how recently a session must have checked the user's
+// password for sensitive operations to proceed (0 disables step-up checks)
+func (am *AuthManager) SetStepUpWindow(window time.Duration) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.stepUpWindow = window
+}
+
+// StepUpWindow returns the configured step-up window
+func (am *AuthManager) StepUpWindow() time.Duration {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	return am.stepUpWindow
+}
+
+// Reauthenticate checks the password of a session's user again and, if it
+// matches, opens the step-up window for that session only
+func (am *AuthManager) Reauthenticate(token, password string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	session, exists := am.sessions[token]
+	if !exists || am.isSessionStale(session, time.Now()) {
+		return fmt.Errorf("invalid or expired session")
+	}
+	user, exists := am.users[session.UserID]
+	if !exists || !user.IsActive {
+		return fmt.Errorf("user not found or inactive")
+	}
+	if !am.verifyPassword(password, user.Salt, user.PasswordHash) {
+		return fmt.Errorf("invalid password")
+	}
+
+	session.ReauthenticatedAt = time.Now()
+	session.LastActivity = session.ReauthenticatedAt
+	fmt.Printf("🔐 User re-authenticated: %s (Session: %s)\n", user.Username, session.ID)
+	return nil
+}
+
+// RequireRecentAuth returns ErrReauthRequired unless the session checked its
+// user's password within the step-up window
+func (am *AuthManager) RequireRecentAuth(token string) error {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	session, exists := am.sessions[token]
+	if !exists || am.isSessionStale(session, time.Now()) {
+		return fmt.Errorf("invalid or expired session")
+	}
+	if am.stepUpWindow > 0 && time.Since(session.ReauthenticatedAt) > am.stepUpWindow {
+		return ErrReauthRequired
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStepUpNeedsARecentPasswordCheck(t *testing.T) {
+	am := NewAuthManager(time.Hour, 10)
+	defer am.Stop()
+	am.SetStepUpWindow(time.Minute)
+
+	resp := loginAdmin(t, am)
+	if err := am.RequireRecentAuth(resp.Token); err != nil {
+		t.Fatalf("a fresh login should count as a recent password check: %v", err)
+	}
+
+	// The session stays valid after the window closes, but sensitive operations do not
+	am.mu.Lock()
+	am.sessions[resp.Token].ReauthenticatedAt = time.Now().Add(-2 * time.Minute)
+	am.mu.Unlock()
+	if _, err := am.ValidateSession(resp.Token); err != nil {
+		t.Fatalf("session should still validate: %v", err)
+	}
+	if err := am.RequireRecentAuth(resp.Token); !errors.Is(err, ErrReauthRequired) {
+		t.Fatalf("expected re-authentication required, got %v", err)
+	}
+
+	if err := am.Reauthenticate(resp.Token, "wrong"); err == nil {
+		t.Fatalf("a wrong password must not re-authenticate")
+	}
+	if err := am.RequireRecentAuth(resp.Token); !errors.Is(err, ErrReauthRequired) {
+		t.Errorf("a failed re-authentication must not open the window, got %v", err)
+	}
+	if err := am.Reauthenticate(resp.Token, "admin123"); err != nil {
+		t.Fatalf("re-authentication failed: %v", err)
+	}
+	if err := am.RequireRecentAuth(resp.Token); err != nil {
+		t.Errorf("expected the window open after re-authentication, got %v", err)
+	}
+
+	// Re-authenticating one session leaves the user's other sessions stale
+	other := loginAdmin(t, am)
+	am.mu.Lock()
+	am.sessions[other.Token].ReauthenticatedAt = time.Now().Add(-2 * time.Minute)
+	am.mu.Unlock()
+	am.Reauthenticate(resp.Token, "admin123")
+	if err := am.RequireRecentAuth(other.Token); !errors.Is(err, ErrReauthRequired) {
+		t.Errorf("expected the other session still stale, got %v", err)
+	}
+
+	am.SetStepUpWindow(0)
+	if err := am.RequireRecentAuth(other.Token); err != nil {
+		t.Errorf("a zero window should disable step-up checks, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package auth
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// tenantName is the form tenant names take; they are used in storage keys
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ValidateTenant checks a tenant name; empty selects the default tenant
+func ValidateTenant(tenant string) error {
+	if tenant != "" && !tenantName.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant %q: use up to 63 lowercase letters, digits, '-' or '_'", tenant)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Package blake3 implements the BLAKE3 hash function with 256-bit output.
+// It is a portable implementation of the reference algorithm: input is split
+// into 1 KiB chunks, each compressed on its own, and their chaining values
+// are merged up a binary tree whose root gives the digest.
+package blake3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Size is the length of a BLAKE3 digest in bytes
+const Size = 32
+
+// BlockSize is the compression function's block size in bytes
+const BlockSize = 64
+
+const (
+	chunkLen = 1024
+
+	flagChunkStart = 1 << 0
+	flagChunkEnd   = 1 << 1
+	flagParent     = 1 << 2
+	flagRoot       = 1 << 3
+)
+
+var iv = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+var msgPermutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func g(state *[16]uint32, a, b, c, d int, mx, my uint32) {
+	state[a] = state[a] + state[b] + mx
+	state[d] = bits.RotateLeft32(state[d]^state[a], -16)
+	state[c] = state[c] + state[d]
+	state[b] = bits.RotateLeft32(state[b]^state[c], -12)
+	state[a] = state[a] + state[b] + my
+	state[d] = bits.RotateLeft32(state[d]^state[a], -8)
+	state[c] = state[c] + state[d]
+	state[b] = bits.RotateLeft32(state[b]^state[c], -7)
+}
+
+func round(state *[16]uint32, m *[16]uint32) {
+	// Columns, then diagonals
+	g(state, 0, 4, 8, 12, m[0], m[1])
+	g(state, 1, 5, 9, 13, m[2], m[3])
+	g(state, 2, 6, 10, 14, m[4], m[5])
+	g(state, 3, 7, 11, 15, m[6], m[7])
+	g(state, 0, 5, 10, 15, m[8], m[9])
+	g(state, 1, 6, 11, 12, m[10], m[11])
+	g(state, 2, 7, 8, 13, m[12], m[13])
+	g(state, 3, 4, 9, 14, m[14], m[15])
+}
+
+func permute(m *[16]uint32) {
+	var permuted [16]uint32
+	for i, from := range msgPermutation {
+		permuted[i] = m[from]
+	}
+	*m = permuted
+}
+
+func compress(cv *[8]uint32, block *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	state := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		iv[0], iv[1], iv[2], iv[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	m := *block
+	for r := 0; r < 7; r++ {
+		round(&state, &m)
+		if r < 6 {
+			permute(&m)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		state[i] ^= state[i+8]
+		state[i+8] ^= cv[i]
+	}
+	return state
+}
+
+func wordsFromBlock(block []byte) [16]uint32 {
+	var words [16]uint32
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+	return words
+}
+
+func first8(words [16]uint32) [8]uint32 {
+	var cv [8]uint32
+	copy(cv[:], words[:8])
+	return cv
+}
+
+// output is the last compression of a chunk or parent, run once as a
+// chaining value or, at the root, as the digest
+type output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *output) chainingValue() [8]uint32 {
+	return first8(compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags))
+}
+
+func (o *output) rootBytes(out []byte) {
+	words := compress(&o.cv, &o.block, 0, o.blockLen, o.flags|flagRoot)
+	for i := 0; i < Size/4; i++ {
+		binary.LittleEndian.PutUint32(out[4*i:], words[i])
+	}
+}
+
+func parentOutput(left, right [8]uint32) output {
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	return output{cv: iv, block: block, blockLen: BlockSize, flags: flagParent}
+}
+
+// chunkState compresses one chunk block by block
+type chunkState struct {
+	cv               [8]uint32
+	counter          uint64
+	block            [BlockSize]byte
+	blockLen         int
+	blocksCompressed int
+}
+
+func newChunkState(counter uint64) chunkState {
+	return chunkState{cv: iv, counter: counter}
+}
+
+func (c *chunkState) len() int {
+	return BlockSize*c.blocksCompressed + c.blockLen
+}
+
+func (c *chunkState) startFlag() uint32 {
+	if c.blocksCompressed == 0 {
+		return flagChunkStart
+	}
+	return 0
+}
+
+func (c *chunkState) update(input []byte) {
+	for len(input) > 0 {
+		// A full block is only compressed once more input arrives, as the
+		// chunk's last block is compressed differently
+		if c.blockLen == BlockSize {
+			words := wordsFromBlock(c.block[:])
+			c.cv = first8(compress(&c.cv, &words, c.counter, BlockSize, c.startFlag()))
+			c.blocksCompressed++
+			c.block = [BlockSize]byte{}
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], input)
+		c.blockLen += n
+		input = input[n:]
+	}
+}
+
+func (c *chunkState) output() output {
+	return output{
+		cv:       c.cv,
+		block:    wordsFromBlock(c.block[:]),
+		counter:  c.counter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | flagChunkEnd,
+	}
+}
+
+// Digest is an incremental BLAKE3 hash; it implements hash.Hash
+type Digest struct {
+	chunk   chunkState
+	cvStack [][8]uint32 // Chaining values of complete subtrees, largest first
+}
+
+// New returns a BLAKE3 hash with a 32-byte digest
+func New() hash.Hash {
+	return &Digest{chunk: newChunkState(0)}
+}
+
+// Sum256 returns the BLAKE3 digest of data
+func Sum256(data []byte) [Size]byte {
+	d := Digest{chunk: newChunkState(0)}
+	d.Write(data)
+	var sum [Size]byte
+	d.Sum(sum[:0])
+	return sum
+}
+
+// addChunkCV pushes a finished chunk's chaining value, first merging every
+// subtree it completes; totalChunks has one trailing zero bit per merge
+func (d *Digest) addChunkCV(cv [8]uint32, totalChunks uint64) {
+	for totalChunks&1 == 0 {
+		left := d.cvStack[len(d.cvStack)-1]
+		d.cvStack = d.cvStack[:len(d.cvStack)-1]
+		parent := parentOutput(left, cv)
+		cv = parent.chainingValue()
+		totalChunks >>= 1
+	}
+	d.cvStack = append(d.cvStack, cv)
+}
+
+// Write adds input to the hash; it never returns an error
+func (d *Digest) Write(input []byte) (int, error) {
+	written := len(input)
+	for len(input) > 0 {
+		// A full chunk is only finished once more input arrives, as the
+		// last chunk may be the root
+		if d.chunk.len() == chunkLen {
+			out := d.chunk.output()
+			totalChunks := d.chunk.counter + 1
+			d.addChunkCV(out.chainingValue(), totalChunks)
+			d.chunk = newChunkState(totalChunks)
+		}
+		take := chunkLen - d.chunk.len()
+		if take > len(input) {
+			take = len(input)
+		}
+		d.chunk.update(input[:take])
+		input = input[take:]
+	}
+	return written, nil
+}
+
+// Sum appends the digest of the input so far to b, leaving the state as is
+func (d *Digest) Sum(b []byte) []byte {
+	out := d.chunk.output()
+	for i := len(d.cvStack) - 1; i >= 0; i-- {
+		out = parentOutput(d.cvStack[i], out.chainingValue())
+	}
+	var sum [Size]byte
+	out.rootBytes(sum[:])
+	return append(b, sum[:]...)
+}
+
+// Reset clears the input so far
+func (d *Digest) Reset() {
+	d.chunk = newChunkState(0)
+	d.cvStack = d.cvStack[:0]
+}
+
+// Size returns the digest length, 32 bytes
+func (d *Digest) Size() int {
+	return Size
+}
+
+// BlockSize returns the compression block size, 64 bytes
+func (d *Digest) BlockSize() int {
+	return BlockSize
+}
@@ -0,0 +1,60 @@
+package blake3
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Official test vectors: input bytes are i%251, digests truncated to 32 bytes
+var vectors = []struct {
+	length int
+	digest string
+}{
+	{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+	{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+	{1023, "10108970eeda3eb932baac1428c7a2163b0e924c9a9e25b35bba72b28f70bd11"},
+	{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+	{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+	{2048, "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a"},
+	{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+}
+
+func input(length int) []byte {
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestSum256MatchesTestVectors(t *testing.T) {
+	for _, v := range vectors {
+		sum := Sum256(input(v.length))
+		if got := hex.EncodeToString(sum[:]); got != v.digest {
+			t.Errorf("length %d: expected %s, got %s", v.length, v.digest, got)
+		}
+	}
+}
+
+func TestIncrementalWritesMatchOneShot(t *testing.T) {
+	data := input(102400)
+	h := New()
+	for written := 0; written < len(data); {
+		// Uneven writes straddle block and chunk boundaries
+		n := 1 + written%1500
+		if written+n > len(data) {
+			n = len(data) - written
+		}
+		h.Write(data[written : written+n])
+		written += n
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != vectors[len(vectors)-1].digest {
+		t.Errorf("expected incremental digest to match, got %s", got)
+	}
+
+	h.Reset()
+	h.Write([]byte("abc"))
+	if got := hex.EncodeToString(h.Sum(nil)); got != "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85" {
+		t.Errorf("unexpected digest after Reset: %s", got)
+	}
+}
@@ -0,0 +1,166 @@
+package budget
+
+import (
+	"context"
+	"sync"
+)
+
+// Stats reports the state of a memory budget
+type Stats struct {
+	Limit    int64 `json:"limit"` // 0 when unlimited
+	InFlight int64 `json:"in_flight"`
+	Peak     int64 `json:"peak"`
+	Waiting  int   `json:"waiting"` // Callers currently blocked in Acquire
+	Waits    int64 `json:"waits"`   // Acquires that had to wait
+	Shed     int64 `json:"shed"`    // TryAcquire calls refused
+}
+
+// Budget bounds the number of chunk bytes buffered in memory at once.
+// Callers acquire a chunk's size before buffering it and release it once the
+// buffer is no longer needed. A request larger than the whole limit is let
+// through when nothing else is in flight, so an oversized chunk slows the
+// process down instead of failing. A nil *Budget never blocks.
+type Budget struct {
+	limit    int64
+	inFlight int64
+	peak     int64
+	waiting  int
+	waits    int64
+	shed     int64
+	changed  chan struct{} // Closed and replaced whenever bytes are released
+	mu       sync.Mutex
+}
+
+// NewBudget creates a budget of limit bytes; <= 0 tracks usage without a limit
+func NewBudget(limit int64) *Budget {
+	if limit < 0 {
+		limit = 0
+	}
+	return &Budget{limit: limit, changed: make(chan struct{})}
+}
+
+var global = NewBudget(0)
+
+// Global returns the process-wide budget shared by chunking, reassembly and transfers
+func Global() *Budget {
+	return global
+}
+
+// Configure sets the limit of the process-wide budget
+func Configure(limit int64) {
+	global.SetLimit(limit)
+}
+
+// SetLimit changes the limit; <= 0 removes it. Waiters are re-checked.
+func (b *Budget) SetLimit(limit int64) {
+	if b == nil {
+		return
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+	b.notifyLocked()
+}
+
+// Acquire reserves n bytes, waiting until they fit or ctx is done
+func (b *Budget) Acquire(ctx context.Context, n int64) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.fitsLocked(n) {
+		b.takeLocked(n)
+		b.mu.Unlock()
+		return nil
+	}
+	b.waits++
+	b.waiting++
+	for {
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.waiting--
+			b.mu.Unlock()
+			return ctx.Err()
+		}
+
+		b.mu.Lock()
+		if b.fitsLocked(n) {
+			b.waiting--
+			b.takeLocked(n)
+			b.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// TryAcquire reserves n bytes only if they fit right now, for callers that
+// would rather shed load than wait
+func (b *Budget) TryAcquire(n int64) bool {
+	if b == nil || n <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.fitsLocked(n) {
+		b.shed++
+		return false
+	}
+	b.takeLocked(n)
+	return true
+}
+
+// Release returns n previously acquired bytes
+func (b *Budget) Release(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inFlight -= n
+	if b.inFlight < 0 {
+		b.inFlight = 0
+	}
+	b.notifyLocked()
+}
+
+// Stats returns a snapshot of the budget
+func (b *Budget) Stats() Stats {
+	if b == nil {
+		return Stats{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Stats{
+		Limit:    b.limit,
+		InFlight: b.inFlight,
+		Peak:     b.peak,
+		Waiting:  b.waiting,
+		Waits:    b.waits,
+		Shed:     b.shed,
+	}
+}
+
+func (b *Budget) fitsLocked(n int64) bool {
+	return b.limit == 0 || b.inFlight == 0 || b.inFlight+n <= b.limit
+}
+
+func (b *Budget) takeLocked(n int64) {
+	b.inFlight += n
+	if b.inFlight > b.peak {
+		b.peak = b.inFlight
+	}
+}
+
+func (b *Budget) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,96 @@
+package budget
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireWaitsWhenBudgetIsSaturated(t *testing.T) {
+	b := NewBudget(100)
+	if err := b.Acquire(context.Background(), 60); err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+	if err := b.Acquire(context.Background(), 40); err != nil {
+		t.Fatalf("second acquire failed: %v", err)
+	}
+
+	// The budget is full; the next buffer must wait instead of allocating past it
+	acquired := make(chan struct{})
+	go func() {
+		b.Acquire(context.Background(), 30)
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire succeeded past the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stats := b.Stats(); stats.InFlight != 100 || stats.Waiting != 1 {
+		t.Fatalf("expected 100 bytes in flight and one waiter, got %+v", stats)
+	}
+	if b.TryAcquire(1) {
+		t.Fatal("TryAcquire should shed load while the budget is full")
+	}
+
+	b.Release(60)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not woken after release")
+	}
+
+	stats := b.Stats()
+	if stats.InFlight != 70 || stats.Peak != 100 || stats.Waits != 1 || stats.Shed != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAcquireNeverExceedsLimitUnderConcurrency(t *testing.T) {
+	const limit = 64
+	b := NewBudget(limit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				b.Acquire(context.Background(), 16)
+				if inFlight := b.Stats().InFlight; inFlight > limit {
+					t.Errorf("in-flight bytes %d exceed limit %d", inFlight, limit)
+				}
+				b.Release(16)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := b.Stats()
+	if stats.InFlight != 0 {
+		t.Errorf("expected everything released, got %d in flight", stats.InFlight)
+	}
+	if stats.Peak > limit {
+		t.Errorf("peak %d exceeds limit %d", stats.Peak, limit)
+	}
+}
+
+func TestAcquireHonoursCancellationAndOversizedRequests(t *testing.T) {
+	b := NewBudget(10)
+
+	// A request larger than the limit runs alone rather than failing forever
+	if err := b.Acquire(context.Background(), 25); err != nil {
+		t.Fatalf("oversized acquire on an idle budget failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Acquire(ctx, 1); err != context.DeadlineExceeded {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+	if stats := b.Stats(); stats.Waiting != 0 || stats.InFlight != 25 {
+		t.Errorf("cancelled waiter left state behind: %+v", stats)
+	}
+}
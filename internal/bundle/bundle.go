@@ -0,0 +1,345 @@
+// Package bundle packs one file's encrypted chunks and metadata into a
+// portable tar archive and restores it on another node. Chunks travel as
+// stored, still encrypted; nothing is decrypted on either side.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// FormatVersion is the bundle layout written by Export
+const FormatVersion = 1
+
+// Archive entry names
+const (
+	manifestEntry = "manifest.json"
+	checksumEntry = "manifest.sha256"
+	chunkPrefix   = "chunks/"
+)
+
+var (
+	// ErrInvalidBundle reports an archive that is damaged or not a bundle
+	ErrInvalidBundle = errors.New("invalid file bundle")
+	// ErrFileExists reports an import of a file the node already has
+	ErrFileExists = errors.New("file already exists")
+)
+
+// Manifest describes a bundled file: everything the metadata store holds for
+// it, and the storage IDs of the chunks that follow it in the archive.
+//
+// Files with a server-managed key carry their data key wrapped by the
+// exporting node's master key, so only a node sharing that key can read them.
+type Manifest struct {
+	Format        int                            `json:"format"`
+	FileID        string                         `json:"file_id"`
+	ExportedAt    time.Time                      `json:"exported_at"`
+	File          metadata.FileMetadata          `json:"file"`
+	Chunks        []metadata.ChunkMetadata       `json:"chunks"`
+	Versions      []metadata.VersionRecord       `json:"versions,omitempty"`
+	Parity        *metadata.ParityRecord         `json:"parity,omitempty"`
+	Enhanced      *metadata.EnhancedFileMetadata `json:"enhanced,omitempty"`
+	Relationships []*metadata.FileRelationship   `json:"relationships,omitempty"`
+	ChunkFiles    []string                       `json:"chunk_files"` // Storage IDs, each the SHA-256 of its bytes
+}
+
+// Catalog is the enhanced metadata a bundle carries along when there is one
+type Catalog interface {
+	LoadFileMetadata(fileID string) (*metadata.EnhancedFileMetadata, error)
+	StoreFileMetadata(meta *metadata.EnhancedFileMetadata) error
+	GetFileRelationships(fileID string) ([]*metadata.FileRelationship, error)
+	PutFileRelationship(relationship *metadata.FileRelationship) error
+}
+
+// Export writes a bundle of fileID to w: the manifest, its checksum, then
+// every stored chunk the current content, earlier versions and parity use.
+// catalog may be nil.
+func Export(w io.Writer, fileID string, metaStore *metadata.MetadataStore, store storage.Storage, catalog Catalog) (*Manifest, error) {
+	manifest, err := buildManifest(fileID, metaStore, catalog)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	sum := sha256.Sum256(encoded)
+
+	tw := tar.NewWriter(w)
+	if err := writeEntry(tw, manifestEntry, encoded); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, checksumEntry, []byte(hex.EncodeToString(sum[:]))); err != nil {
+		return nil, err
+	}
+	for _, id := range manifest.ChunkFiles {
+		data, err := readChunk(store, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeEntry(tw, chunkPrefix+id, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish bundle: %v", err)
+	}
+	return manifest, nil
+}
+
+// buildManifest collects a file's metadata and the chunks it references
+func buildManifest(fileID string, metaStore *metadata.MetadataStore, catalog Catalog) (*Manifest, error) {
+	file, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata for %s: %v", fileID, err)
+	}
+	contentID := file.CurrentContentID(fileID)
+	chunks, err := metaStore.GetChunksByFileID(contentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks for %s: %v", fileID, err)
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("file %s has no chunks to export", fileID)
+	}
+	versions, err := metaStore.ListVersions(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %v", fileID, err)
+	}
+
+	manifest := &Manifest{
+		Format:     FormatVersion,
+		FileID:     fileID,
+		ExportedAt: time.Now().UTC(),
+		File:       file,
+		Chunks:     chunks,
+		Versions:   versions,
+	}
+	parity, err := metaStore.GetParity(contentID)
+	switch {
+	case err == nil:
+		manifest.Parity = &parity
+	case !errors.Is(err, badger.ErrKeyNotFound):
+		return nil, fmt.Errorf("failed to get parity for %s: %v", fileID, err)
+	}
+	if catalog != nil {
+		if enhanced, err := catalog.LoadFileMetadata(fileID); err == nil {
+			manifest.Enhanced = enhanced
+		}
+		if relationships, err := catalog.GetFileRelationships(fileID); err == nil && len(relationships) > 0 {
+			manifest.Relationships = relationships
+		}
+	}
+
+	files := make(map[string]bool)
+	for _, chunk := range chunks {
+		files[chunk.Path] = true
+	}
+	for _, version := range versions {
+		for _, chunk := range version.Chunks {
+			files[chunk.Path] = true
+		}
+	}
+	if manifest.Parity != nil {
+		for _, path := range manifest.Parity.Paths() {
+			files[path] = true
+		}
+	}
+	delete(files, "")
+	for id := range files {
+		manifest.ChunkFiles = append(manifest.ChunkFiles, id)
+	}
+	sort.Strings(manifest.ChunkFiles)
+	return manifest, nil
+}
+
+// Verify reads a whole bundle and checks it without importing anything: the
+// manifest matches its checksum and every chunk it lists is present and
+// hashes to its ID.
+func Verify(r io.Reader) (*Manifest, error) {
+	return readBundle(r, nil, nil)
+}
+
+// Import restores a bundle onto metaStore and store. The archive is checked
+// in full before any metadata is written, so a damaged bundle leaves at most
+// unreferenced chunks behind for the consistency check to collect. A file
+// the node already has is refused. catalog may be nil.
+func Import(r io.Reader, metaStore *metadata.MetadataStore, store storage.Storage, catalog Catalog) (*Manifest, error) {
+	var fileID string
+	manifest, err := readBundle(r, func(m *Manifest) error {
+		fileID = m.FileID
+		if _, err := metaStore.GetFileMetadataByID(fileID); err == nil {
+			return fmt.Errorf("%w: %s", ErrFileExists, fileID)
+		}
+		return nil
+	}, func(id string, data []byte) error {
+		stored, err := store.Put(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to store chunk %s: %v", id, err)
+		}
+		if stored != id {
+			return fmt.Errorf("storage filed chunk %s under %s", id, stored)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunk := range manifest.Chunks {
+		if err := metaStore.PutChunkMetadata(chunk); err != nil {
+			return nil, fmt.Errorf("failed to store chunk metadata: %v", err)
+		}
+	}
+	for _, version := range manifest.Versions {
+		if err := metaStore.PutVersion(version); err != nil {
+			return nil, fmt.Errorf("failed to store version %d: %v", version.Version, err)
+		}
+	}
+	if manifest.Parity != nil {
+		if err := metaStore.PutParity(*manifest.Parity); err != nil {
+			return nil, fmt.Errorf("failed to store parity: %v", err)
+		}
+	}
+	if catalog != nil {
+		if manifest.Enhanced != nil {
+			if err := catalog.StoreFileMetadata(manifest.Enhanced); err != nil {
+				return nil, fmt.Errorf("failed to store enhanced metadata: %v", err)
+			}
+		}
+		for _, relationship := range manifest.Relationships {
+			if err := catalog.PutFileRelationship(relationship); err != nil {
+				return nil, fmt.Errorf("failed to store relationship: %v", err)
+			}
+		}
+	}
+	// The file record goes last, so the file appears only once it is complete
+	if err := metaStore.PutFileMetadata(manifest.File); err != nil {
+		return nil, fmt.Errorf("failed to store file metadata: %v", err)
+	}
+	if err := metaStore.PutFileMetadataByID(fileID, manifest.File); err != nil {
+		return nil, fmt.Errorf("failed to store file metadata by ID: %v", err)
+	}
+	return manifest, nil
+}
+
+// readBundle parses and checks an archive. accept sees the manifest once its
+// checksum is confirmed and onChunk each chunk once its hash is; either may
+// be nil.
+func readBundle(r io.Reader, accept func(*Manifest) error, onChunk func(id string, data []byte) error) (*Manifest, error) {
+	tr := tar.NewReader(r)
+	next := func(want string) ([]byte, error) {
+		header, err := tr.Next()
+		if err != nil {
+			return nil, invalid("missing %s: %v", want, err)
+		}
+		if header.Name != want {
+			return nil, invalid("expected %s, found %s", want, header.Name)
+		}
+		return io.ReadAll(tr)
+	}
+
+	encoded, err := next(manifestEntry)
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := next(checksumEntry)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(encoded)
+	if strings.TrimSpace(string(checksum)) != hex.EncodeToString(sum[:]) {
+		return nil, invalid("manifest does not match its checksum")
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(encoded, &manifest); err != nil {
+		return nil, invalid("unreadable manifest: %v", err)
+	}
+	if manifest.Format != FormatVersion {
+		return nil, invalid("unsupported format %d", manifest.Format)
+	}
+	if manifest.FileID == "" {
+		return nil, invalid("manifest names no file")
+	}
+	if accept != nil {
+		if err := accept(&manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := make(map[string]bool, len(manifest.ChunkFiles))
+	for _, id := range manifest.ChunkFiles {
+		pending[id] = true
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalid("damaged archive: %v", err)
+		}
+		id := strings.TrimPrefix(header.Name, chunkPrefix)
+		if id == header.Name || !pending[id] {
+			return nil, invalid("unexpected entry %s", header.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, invalid("damaged chunk %s: %v", id, err)
+		}
+		chunkSum := sha256.Sum256(data)
+		if hex.EncodeToString(chunkSum[:]) != id {
+			return nil, invalid("chunk %s does not match its hash", id)
+		}
+		delete(pending, id)
+		if onChunk != nil {
+			if err := onChunk(id, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(pending) > 0 {
+		return nil, invalid("%d chunks listed in the manifest are missing", len(pending))
+	}
+	return &manifest, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return nil
+}
+
+func readChunk(store storage.Storage, id string) ([]byte, error) {
+	reader, err := store.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %s: %v", id, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %s: %v", id, err)
+	}
+	return data, nil
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidBundle, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,125 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+type node struct {
+	metaStore *metadata.MetadataStore
+	store     *storage.LocalStorage
+	catalog   *metadata.EnhancedMetadataStore
+}
+
+func newNode(t *testing.T, dir string) *node {
+	t.Helper()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() { metaStore.Close() })
+	catalog, err := metadata.NewEnhancedMetadataStore(filepath.Join(dir, "enhanced"))
+	if err != nil {
+		t.Fatalf("failed to open enhanced metadata store: %v", err)
+	}
+	t.Cleanup(func() { catalog.Close() })
+	return &node{metaStore: metaStore, store: store, catalog: catalog}
+}
+
+func TestExportImportReassemblesOnAFreshNode(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	source, target := newNode(t, filepath.Join(dir, "source")), newNode(t, filepath.Join(dir, "target"))
+
+	var content strings.Builder
+	for i := 0; content.Len() < 3*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "line %07d of the bundled file\n", i)
+	}
+	input := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(input, []byte(content.String()), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	chunks, err := chunker.ChunkAndStore(input, "secret", source.metaStore, source.store)
+	if err != nil {
+		t.Fatalf("failed to chunk file: %v", err)
+	}
+	fileID := chunks[0].FileID
+	source.catalog.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: "report.txt", OwnerID: "alice"})
+	source.catalog.CreateFileRelationship(fileID, "other-file", "reference", "alice", 0.5)
+
+	var archive bytes.Buffer
+	exported, err := Export(&archive, fileID, source.metaStore, source.store, source.catalog)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if len(exported.ChunkFiles) != len(chunks) {
+		t.Errorf("expected %d chunks bundled, got %d", len(chunks), len(exported.ChunkFiles))
+	}
+	// Exporting is not an access to the file
+	if exported.Enhanced == nil || exported.Enhanced.AccessCount != 0 {
+		t.Errorf("expected the enhanced metadata bundled unchanged, got %+v", exported.Enhanced)
+	}
+	if _, err := Verify(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("expected the bundle to verify: %v", err)
+	}
+
+	// Chunks travel encrypted: no plaintext line appears in the archive
+	if bytes.Contains(archive.Bytes(), []byte("of the bundled file")) {
+		t.Errorf("expected the bundle to hold only encrypted chunks")
+	}
+
+	// A flipped byte in a chunk is caught before anything is imported
+	damaged := append([]byte(nil), archive.Bytes()...)
+	chunkPath, _ := source.store.GetPath(exported.ChunkFiles[0])
+	stored, _ := os.ReadFile(chunkPath)
+	at := bytes.Index(damaged, stored)
+	if at < 0 {
+		t.Fatalf("expected the stored chunk bytes in the bundle")
+	}
+	damaged[at+len(stored)/2] ^= 0xFF
+	if _, err := Import(bytes.NewReader(damaged), target.metaStore, target.store, target.catalog); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("expected a damaged bundle rejected, got %v", err)
+	}
+	if _, err := target.metaStore.GetFileMetadataByID(fileID); err == nil {
+		t.Fatalf("expected no metadata written for a damaged bundle")
+	}
+
+	if _, err := Import(bytes.NewReader(archive.Bytes()), target.metaStore, target.store, target.catalog); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	output := filepath.Join(dir, "restored.txt")
+	if err := chunker.ReassembleFile(fileID, output, "secret", target.metaStore, target.store); err != nil {
+		t.Fatalf("failed to reassemble the imported file: %v", err)
+	}
+	if restored, _ := os.ReadFile(output); string(restored) != content.String() {
+		t.Errorf("restored file differs from the original")
+	}
+
+	enhanced, err := target.catalog.LoadFileMetadata(fileID)
+	if err != nil || enhanced.OwnerID != "alice" {
+		t.Errorf("expected enhanced metadata restored, got %+v, %v", enhanced, err)
+	}
+	if relationships, _ := target.catalog.GetFileRelationships(fileID); len(relationships) != 1 {
+		t.Errorf("expected the relationship restored, got %d", len(relationships))
+	}
+
+	if _, err := Import(bytes.NewReader(archive.Bytes()), target.metaStore, target.store, target.catalog); !errors.Is(err, ErrFileExists) {
+		t.Errorf("expected a second import refused, got %v", err)
+	}
+}
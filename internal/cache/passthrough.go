@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+)
+
+// Passthrough cache modes
+const (
+	ModeOff       = "off"       // Nothing is cached; downloads always reassemble from chunks
+	ModeEncrypted = "encrypted" // Originals are encrypted with the upload password
+	ModePlaintext = "plaintext" // Debug/demo only: originals are kept and served unencrypted
+)
+
+var (
+	ErrNotCached       = errors.New("file not in passthrough cache")
+	ErrPasswordNeeded  = errors.New("password required for cached file")
+	ErrInvalidPassword = errors.New("invalid password for cached file")
+)
+
+// Passthrough cache defaults
+const (
+	DefaultPassthroughMaxEntries = 1000
+	DefaultPassthroughMaxBytes   = 1 << 30 // Total bytes of cached originals on disk
+)
+
+// PassthroughStats reports passthrough cache usage
+type PassthroughStats struct {
+	Mode       string `json:"mode"`
+	Entries    int    `json:"entries"`
+	UsedBytes  int64  `json:"used_bytes"`
+	MaxEntries int    `json:"max_entries"`
+	MaxBytes   int64  `json:"max_bytes"`
+	Evictions  int64  `json:"evictions"`
+}
+
+type passthroughEntry struct {
+	fileID  string
+	path    string
+	name    string
+	size    int64
+	readers int  // Opens in progress; the file is only deleted once they finish
+	removed bool // Dropped from the cache while being read
+}
+
+// Passthrough keeps a copy of uploaded originals so downloads can skip
+// reassembly. In encrypted mode the copy is sealed with the upload password
+// and cannot be read back without it, so the cache never weakens the
+// at-rest encryption of the chunks. The cache is bounded by entry count and
+// total size; least recently used originals are evicted and their files deleted.
+type Passthrough struct {
+	dir        string
+	mode       string
+	enc        encryptor.Encryptor
+	lru        *list.List // Front is most recently used
+	entries    map[string]*list.Element
+	used       int64
+	maxEntries int
+	maxBytes   int64
+	evictions  int64
+	mu         sync.RWMutex
+}
+
+// ParseMode validates a configured cache mode; empty selects ModeOff
+func ParseMode(mode string) (string, error) {
+	switch mode {
+	case "", ModeOff:
+		return ModeOff, nil
+	case ModeEncrypted, ModePlaintext:
+		return mode, nil
+	default:
+		return ModeOff, fmt.Errorf("unknown original cache mode %q", mode)
+	}
+}
+
+// NewPassthrough creates a cache in dir; the directory is only created when
+// caching is enabled. Plaintext mode is refused in strict encryption mode.
+func NewPassthrough(dir, mode string) (*Passthrough, error) {
+	mode, err := ParseMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModePlaintext {
+		if err := encryptor.RefusePlaintext("original cache in plaintext mode"); err != nil {
+			return nil, err
+		}
+	}
+	if mode != ModeOff {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		// Entries are not persisted, so files left by an earlier run are unreachable
+		if stale, err := os.ReadDir(dir); err == nil {
+			for _, entry := range stale {
+				if !entry.IsDir() {
+					os.Remove(filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+	}
+
+	return &Passthrough{
+		dir:        dir,
+		mode:       mode,
+		enc:        encryptor.NewEncryptor(),
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		maxEntries: DefaultPassthroughMaxEntries,
+		maxBytes:   DefaultPassthroughMaxBytes,
+	}, nil
+}
+
+// SetLimits bounds the cache by entry count and total bytes; zero keeps the default
+func (p *Passthrough) SetLimits(maxEntries int, maxBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if maxEntries > 0 {
+		p.maxEntries = maxEntries
+	}
+	if maxBytes > 0 {
+		p.maxBytes = maxBytes
+	}
+	p.evictLocked()
+}
+
+// Mode returns the cache mode
+func (p *Passthrough) Mode() string {
+	return p.mode
+}
+
+// Enabled reports whether originals are cached at all
+func (p *Passthrough) Enabled() bool {
+	return p.mode != ModeOff
+}
+
+// Has reports whether the file has a cached original
+func (p *Passthrough) Has(fileID string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.entries[fileID]
+	return ok
+}
+
+// Store caches the original at srcPath. In encrypted mode files uploaded
+// without a password (server-managed keys) are not cached.
+func (p *Passthrough) Store(fileID, name, srcPath, password string) error {
+	if !p.accepts(password) {
+		return nil
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to cache original: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to cache original: %w", err)
+	}
+	return p.StoreReader(fileID, name, src, info.Size(), password)
+}
+
+// StoreReader caches an original of size bytes read from src, for uploads
+// that were never written to a file
+func (p *Passthrough) StoreReader(fileID, name string, src io.Reader, size int64, password string) error {
+	if !p.accepts(password) {
+		return nil
+	}
+	// Originals larger than the whole cache would only be evicted right away
+	p.mu.RLock()
+	maxBytes := p.maxBytes
+	p.mu.RUnlock()
+	if size > maxBytes {
+		return nil
+	}
+
+	cachePath := filepath.Join(p.dir, fileID+"_"+filepath.Base(name))
+	var err error
+	if p.mode == ModeEncrypted {
+		err = p.writeSealed(cachePath, src, password)
+	} else {
+		err = writePlain(cachePath, src)
+	}
+	if err != nil {
+		os.Remove(cachePath)
+		return fmt.Errorf("failed to cache original: %w", err)
+	}
+	if err := os.Chmod(cachePath, 0600); err != nil {
+		os.Remove(cachePath)
+		return fmt.Errorf("failed to restrict cached original: %w", err)
+	}
+
+	var stored int64
+	if info, err := os.Stat(cachePath); err == nil {
+		stored = info.Size()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if elem, ok := p.entries[fileID]; ok {
+		// The new copy may have been written over the old one's file
+		old := elem.Value.(*passthroughEntry)
+		if old.path == cachePath {
+			old.path = ""
+		}
+		p.removeLocked(elem)
+	}
+	p.entries[fileID] = p.lru.PushFront(&passthroughEntry{fileID: fileID, path: cachePath, name: name, size: stored})
+	p.used += stored
+	p.evictLocked()
+	return nil
+}
+
+// Open returns the cached original and its file name. Encrypted entries are
+// only returned when the password decrypts them.
+func (p *Passthrough) Open(fileID, password string) ([]byte, string, error) {
+	p.mu.Lock()
+	elem, ok := p.entries[fileID]
+	if !ok {
+		p.mu.Unlock()
+		return nil, "", ErrNotCached
+	}
+	entry := elem.Value.(*passthroughEntry)
+	p.lru.MoveToFront(elem)
+	entry.readers++
+	p.mu.Unlock()
+
+	data, err := os.ReadFile(entry.path)
+	p.release(entry)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read cached original: %w", err)
+	}
+	if p.mode == ModePlaintext {
+		return data, entry.name, nil
+	}
+
+	if password == "" {
+		return nil, "", ErrPasswordNeeded
+	}
+	plaintext, err := p.enc.Decrypt(data, password)
+	if err != nil {
+		return nil, "", ErrInvalidPassword
+	}
+	return plaintext, entry.name, nil
+}
+
+// Remove drops a file's cached original
+func (p *Passthrough) Remove(fileID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if elem, ok := p.entries[fileID]; ok {
+		p.removeLocked(elem)
+	}
+}
+
+// Stats returns current usage against the limits
+func (p *Passthrough) Stats() PassthroughStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return PassthroughStats{
+		Mode:       p.mode,
+		Entries:    len(p.entries),
+		UsedBytes:  p.used,
+		MaxEntries: p.maxEntries,
+		MaxBytes:   p.maxBytes,
+		Evictions:  p.evictions,
+	}
+}
+
+// evictLocked drops least recently used entries until the cache fits its
+// limits. Entries being read are skipped, so the cache may briefly run over.
+func (p *Passthrough) evictLocked() {
+	elem := p.lru.Back()
+	for elem != nil && (p.lru.Len() > p.maxEntries || p.used > p.maxBytes) {
+		prev := elem.Prev()
+		if elem.Value.(*passthroughEntry).readers == 0 {
+			p.removeLocked(elem)
+			p.evictions++
+		}
+		elem = prev
+	}
+}
+
+// removeLocked drops an entry, deleting its file unless it is still being read
+func (p *Passthrough) removeLocked(elem *list.Element) {
+	entry := p.lru.Remove(elem).(*passthroughEntry)
+	delete(p.entries, entry.fileID)
+	p.used -= entry.size
+	if entry.readers > 0 {
+		entry.removed = true
+		return
+	}
+	if entry.path != "" {
+		os.Remove(entry.path)
+	}
+}
+
+// release ends a read, deleting the file if the entry was dropped meanwhile
+func (p *Passthrough) release(entry *passthroughEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry.readers--
+	if entry.readers > 0 || !entry.removed || entry.path == "" {
+		return
+	}
+	// A later Store of the same file may have reused the path
+	if elem, ok := p.entries[entry.fileID]; ok && elem.Value.(*passthroughEntry).path == entry.path {
+		return
+	}
+	os.Remove(entry.path)
+}
+
+// accepts reports whether an original uploaded with password is cached at all
+func (p *Passthrough) accepts(password string) bool {
+	return p.mode != ModeOff && (p.mode != ModeEncrypted || password != "")
+}
+
+// writeSealed encrypts everything read from src with password into dst
+func (p *Passthrough) writeSealed(dst string, src io.Reader, password string) error {
+	plaintext, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("failed to read original: %w", err)
+	}
+	sealed, err := p.enc.Encrypt(plaintext, password)
+	if err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+	return os.WriteFile(dst, sealed, 0600)
+}
+
+func writePlain(dst string, in io.Reader) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
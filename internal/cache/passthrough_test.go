@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedPassthroughRequiresPassword(t *testing.T) {
+	dir := t.TempDir()
+	original := []byte("top secret quarterly numbers")
+	src := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(src, original, 0644); err != nil {
+		t.Fatalf("failed to write original: %v", err)
+	}
+
+	cacheDir := filepath.Join(dir, "cache")
+	p, err := NewPassthrough(cacheDir, ModeEncrypted)
+	if err != nil {
+		t.Fatalf("NewPassthrough failed: %v", err)
+	}
+	if err := p.Store("file-1", "report.txt", src, "correct horse"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// Nothing on disk may contain the plaintext
+	entries, _ := os.ReadDir(cacheDir)
+	if len(entries) != 1 {
+		t.Fatalf("expected one cached file, got %d", len(entries))
+	}
+	onDisk, _ := os.ReadFile(filepath.Join(cacheDir, entries[0].Name()))
+	if bytes.Contains(onDisk, original) {
+		t.Errorf("cached original is stored in plaintext")
+	}
+
+	if _, _, err := p.Open("file-1", ""); !errors.Is(err, ErrPasswordNeeded) {
+		t.Errorf("expected ErrPasswordNeeded without a password, got %v", err)
+	}
+	if _, _, err := p.Open("file-1", "wrong"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("expected ErrInvalidPassword with the wrong password, got %v", err)
+	}
+	data, name, err := p.Open("file-1", "correct horse")
+	if err != nil || !bytes.Equal(data, original) || name != "report.txt" {
+		t.Errorf("expected original back with the password, got %q, %q, %v", data, name, err)
+	}
+
+	// Server-managed uploads have no password to seal with and are not cached
+	if err := p.Store("file-2", "other.txt", src, ""); err != nil || p.Has("file-2") {
+		t.Errorf("expected passwordless upload to be skipped, got has=%v err=%v", p.Has("file-2"), err)
+	}
+}
+
+func TestPassthroughOffByDefault(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.bin")
+	os.WriteFile(src, []byte("data"), 0644)
+
+	p, err := NewPassthrough(filepath.Join(dir, "cache"), "")
+	if err != nil {
+		t.Fatalf("NewPassthrough failed: %v", err)
+	}
+	if p.Enabled() {
+		t.Fatalf("expected cache to be off by default")
+	}
+	if err := p.Store("file-1", "data.bin", src, "pw"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if _, _, err := p.Open("file-1", "pw"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("expected nothing cached when off, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache")); !os.IsNotExist(err) {
+		t.Errorf("cache directory should not be created when off")
+	}
+	if _, err := NewPassthrough(dir, "bogus"); err == nil {
+		t.Errorf("expected error for unknown mode")
+	}
+}
+
+func TestPassthroughEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.bin")
+	os.WriteFile(src, bytes.Repeat([]byte("x"), 100), 0644)
+
+	cacheDir := filepath.Join(dir, "cache")
+	p, err := NewPassthrough(cacheDir, ModePlaintext)
+	if err != nil {
+		t.Fatalf("NewPassthrough failed: %v", err)
+	}
+	p.SetLimits(2, 1<<20)
+
+	cachedFile := func(fileID string) string {
+		return filepath.Join(cacheDir, fileID+"_data.bin")
+	}
+	for _, id := range []string{"file-1", "file-2"} {
+		if err := p.Store(id, "data.bin", src, ""); err != nil {
+			t.Fatalf("Store(%s) failed: %v", id, err)
+		}
+	}
+
+	// Reading file-1 makes file-2 the least recently used
+	if _, _, err := p.Open("file-1", ""); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	p.Store("file-3", "data.bin", src, "")
+
+	if p.Has("file-2") {
+		t.Errorf("file-2 should have been evicted")
+	}
+	if _, err := os.Stat(cachedFile("file-2")); !os.IsNotExist(err) {
+		t.Errorf("evicted original should be deleted from disk, stat err: %v", err)
+	}
+	for _, id := range []string{"file-1", "file-3"} {
+		if !p.Has(id) {
+			t.Errorf("%s should still be cached", id)
+		}
+	}
+	stats := p.Stats()
+	if stats.Entries != 2 || stats.UsedBytes != 200 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// An entry being served is kept until its read finishes
+	p.mu.Lock()
+	serving := p.entries["file-1"].Value.(*passthroughEntry)
+	serving.readers++
+	p.lru.MoveToBack(p.entries["file-1"])
+	p.mu.Unlock()
+
+	p.SetLimits(1, 0)
+	if !p.Has("file-1") || p.Has("file-3") {
+		t.Fatalf("expected the entry in use to survive eviction")
+	}
+	p.Remove("file-1")
+	if _, err := os.Stat(cachedFile("file-1")); err != nil {
+		t.Errorf("file removed while being served should stay until the read ends: %v", err)
+	}
+	p.release(serving)
+	if _, err := os.Stat(cachedFile("file-1")); !os.IsNotExist(err) {
+		t.Errorf("file should be deleted once the read ends, stat err: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+)
+
+// Warm cache defaults
+const (
+	DefaultWarmThreshold = 3         // Downloads before a file is kept warm
+	DefaultWarmMaxBytes  = 256 << 20 // Total bytes held in memory
+)
+
+// warmVerifier is sealed with the password when a protected file is cached in plaintext
+var warmVerifier = []byte("disktrobyte-warm-cache")
+
+// WarmConfig controls the warm reassembly cache
+type WarmConfig struct {
+	Enabled        bool
+	Threshold      int64 // Downloads of a file before its reassembled copy is kept
+	MaxBytes       int64 // Total memory budget; least recently used entries are evicted
+	MaxEntryBytes  int64 // Larger files are never cached, 0 allows up to MaxBytes
+	AllowPlaintext bool  // Keep password-protected files unsealed in memory (still password checked)
+}
+
+// WarmStats reports warm cache effectiveness
+type WarmStats struct {
+	Enabled   bool    `json:"enabled"`
+	Entries   int     `json:"entries"`
+	UsedBytes int64   `json:"used_bytes"`
+	MaxBytes  int64   `json:"max_bytes"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hit_rate"` // Hits over lookups, 0.0 to 1.0
+}
+
+type warmEntry struct {
+	fileID   string
+	version  string
+	data     []byte // Plaintext, or sealed with the password when sealed is set
+	sealed   bool
+	verifier []byte // Password check for unsealed protected entries
+}
+
+func (e *warmEntry) size() int64 {
+	return int64(len(e.data) + len(e.verifier))
+}
+
+// WarmCache keeps reassembled copies of frequently downloaded files in
+// memory so repeat downloads skip chunk fetching and per-chunk decryption.
+// Entries are tied to a file version and are dropped when it changes.
+// Password-protected files are sealed with the download password unless
+// AllowPlaintext is set, and are only returned when that password matches.
+type WarmCache struct {
+	cfg       WarmConfig
+	enc       encryptor.Encryptor
+	lru       *list.List // Front is most recently used
+	entries   map[string]*list.Element
+	downloads map[string]int64
+	used      int64
+	hits      int64
+	misses    int64
+	evictions int64
+	mu        sync.Mutex
+}
+
+// NewWarmCache creates a warm cache, filling in defaults for unset limits
+func NewWarmCache(cfg WarmConfig) *WarmCache {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultWarmThreshold
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultWarmMaxBytes
+	}
+	if cfg.MaxEntryBytes <= 0 || cfg.MaxEntryBytes > cfg.MaxBytes {
+		cfg.MaxEntryBytes = cfg.MaxBytes
+	}
+
+	return &WarmCache{
+		cfg:       cfg,
+		enc:       encryptor.NewEncryptor(),
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+		downloads: make(map[string]int64),
+	}
+}
+
+// Enabled reports whether the cache holds anything at all
+func (w *WarmCache) Enabled() bool {
+	return w != nil && w.cfg.Enabled
+}
+
+// RecordDownload counts a download and reports whether the file is now popular enough to warm
+func (w *WarmCache) RecordDownload(fileID string) bool {
+	if !w.Enabled() {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.downloads[fileID]++
+	return w.downloads[fileID] >= w.cfg.Threshold
+}
+
+// Get returns the warm copy of a file at the given version. A missing or
+// stale entry returns ErrNotCached; protected entries need their password.
+func (w *WarmCache) Get(fileID, version, password string) ([]byte, error) {
+	if !w.Enabled() {
+		return nil, ErrNotCached
+	}
+
+	w.mu.Lock()
+	elem, ok := w.entries[fileID]
+	if ok && elem.Value.(*warmEntry).version != version {
+		w.removeLocked(elem)
+		ok = false
+	}
+	if !ok {
+		w.misses++
+		w.mu.Unlock()
+		return nil, ErrNotCached
+	}
+	entry := elem.Value.(*warmEntry)
+	w.mu.Unlock()
+
+	// Password checks run outside the lock since key derivation is slow
+	data := entry.data
+	switch {
+	case entry.sealed:
+		if password == "" {
+			return nil, ErrPasswordNeeded
+		}
+		plaintext, err := w.enc.Decrypt(entry.data, password)
+		if err != nil {
+			return nil, ErrInvalidPassword
+		}
+		data = plaintext
+	case entry.verifier != nil:
+		if password == "" {
+			return nil, ErrPasswordNeeded
+		}
+		if _, err := w.enc.Decrypt(entry.verifier, password); err != nil {
+			return nil, ErrInvalidPassword
+		}
+	}
+
+	w.mu.Lock()
+	w.hits++
+	if current, ok := w.entries[fileID]; ok && current == elem {
+		w.lru.MoveToFront(elem)
+	}
+	w.mu.Unlock()
+	return data, nil
+}
+
+// Put caches a reassembled file. An empty password marks a file encrypted
+// with a server-managed key; otherwise the password protects the entry.
+// Files larger than the entry limit are skipped.
+func (w *WarmCache) Put(fileID, version string, data []byte, password string) error {
+	if !w.Enabled() || int64(len(data)) > w.cfg.MaxEntryBytes {
+		return nil
+	}
+
+	entry := &warmEntry{fileID: fileID, version: version, data: data}
+	if password != "" {
+		if w.cfg.AllowPlaintext {
+			verifier, err := w.enc.Encrypt(warmVerifier, password)
+			if err != nil {
+				return err
+			}
+			entry.verifier = verifier
+		} else {
+			sealed, err := w.enc.Encrypt(data, password)
+			if err != nil {
+				return err
+			}
+			entry.data = sealed
+			entry.sealed = true
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if elem, ok := w.entries[fileID]; ok {
+		w.removeLocked(elem)
+	}
+	w.entries[fileID] = w.lru.PushFront(entry)
+	w.used += entry.size()
+
+	for w.used > w.cfg.MaxBytes && w.lru.Len() > 1 {
+		w.removeLocked(w.lru.Back())
+		w.evictions++
+	}
+	return nil
+}
+
+// Invalidate drops a file's warm copy, e.g. after it was updated or deleted
+func (w *WarmCache) Invalidate(fileID string) {
+	if !w.Enabled() {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if elem, ok := w.entries[fileID]; ok {
+		w.removeLocked(elem)
+	}
+}
+
+// Stats returns current usage and the hit rate
+func (w *WarmCache) Stats() WarmStats {
+	if !w.Enabled() {
+		return WarmStats{}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := WarmStats{
+		Enabled:   true,
+		Entries:   len(w.entries),
+		UsedBytes: w.used,
+		MaxBytes:  w.cfg.MaxBytes,
+		Hits:      w.hits,
+		Misses:    w.misses,
+		Evictions: w.evictions,
+	}
+	if lookups := w.hits + w.misses; lookups > 0 {
+		stats.HitRate = float64(w.hits) / float64(lookups)
+	}
+	return stats
+}
+
+func (w *WarmCache) removeLocked(elem *list.Element) {
+	entry := w.lru.Remove(elem).(*warmEntry)
+	delete(w.entries, entry.fileID)
+	w.used -= entry.size()
+}
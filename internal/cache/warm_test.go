@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// download mimics the GUI download path: warm copy first, otherwise reassemble
+func download(w *WarmCache, fileID, version, password string, content []byte, reassemblies *int) ([]byte, error) {
+	if data, err := w.Get(fileID, version, password); err == nil {
+		return data, nil
+	} else if !errors.Is(err, ErrNotCached) {
+		return nil, err
+	}
+
+	*reassemblies++
+	if w.RecordDownload(fileID) {
+		if err := w.Put(fileID, version, content, password); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+func TestWarmCacheServesRepeatedDownloads(t *testing.T) {
+	w := NewWarmCache(WarmConfig{Enabled: true, Threshold: 2})
+	content := []byte("popular file contents")
+	reassemblies := 0
+
+	for i := 0; i < 6; i++ {
+		data, err := download(w, "file-1", "v1", "", content, &reassemblies)
+		if err != nil || !bytes.Equal(data, content) {
+			t.Fatalf("download %d returned %q, %v", i, data, err)
+		}
+	}
+	if reassemblies != 2 {
+		t.Errorf("expected only the downloads below the threshold to reassemble, got %d", reassemblies)
+	}
+	stats := w.Stats()
+	if stats.Hits != 4 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.HitRate < 0.66 || stats.HitRate > 0.67 {
+		t.Errorf("expected a hit rate of 4/6, got %f", stats.HitRate)
+	}
+
+	// A new version of the file must not be served from the old copy
+	if _, err := w.Get("file-1", "v2", ""); !errors.Is(err, ErrNotCached) {
+		t.Errorf("expected stale entry to miss, got %v", err)
+	}
+	if w.Stats().Entries != 0 {
+		t.Errorf("stale entry should have been dropped")
+	}
+}
+
+func TestWarmCacheProtectsPasswordFiles(t *testing.T) {
+	secret := []byte("password protected contents")
+
+	sealed := NewWarmCache(WarmConfig{Enabled: true, Threshold: 1})
+	if err := sealed.Put("file-1", "v1", secret, "hunter2"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	elem := sealed.entries["file-1"]
+	if bytes.Contains(elem.Value.(*warmEntry).data, secret) {
+		t.Errorf("password-protected file is held in plaintext by default")
+	}
+
+	plain := NewWarmCache(WarmConfig{Enabled: true, Threshold: 1, AllowPlaintext: true})
+	if err := plain.Put("file-1", "v1", secret, "hunter2"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	for name, w := range map[string]*WarmCache{"sealed": sealed, "plaintext": plain} {
+		if _, err := w.Get("file-1", "v1", ""); !errors.Is(err, ErrPasswordNeeded) {
+			t.Errorf("%s: expected ErrPasswordNeeded, got %v", name, err)
+		}
+		if _, err := w.Get("file-1", "v1", "wrong"); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("%s: expected ErrInvalidPassword, got %v", name, err)
+		}
+		if data, err := w.Get("file-1", "v1", "hunter2"); err != nil || !bytes.Equal(data, secret) {
+			t.Errorf("%s: expected contents with the right password, got %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestWarmCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	w := NewWarmCache(WarmConfig{Enabled: true, Threshold: 1, MaxBytes: 30})
+	w.Put("a", "v1", bytes.Repeat([]byte("a"), 10), "")
+	w.Put("b", "v1", bytes.Repeat([]byte("b"), 10), "")
+	w.Get("a", "v1", "")
+	w.Put("c", "v1", bytes.Repeat([]byte("c"), 15), "")
+
+	if _, err := w.Get("b", "v1", ""); !errors.Is(err, ErrNotCached) {
+		t.Errorf("expected least recently used entry b to be evicted")
+	}
+	if _, err := w.Get("a", "v1", ""); err != nil {
+		t.Errorf("recently used entry a should survive: %v", err)
+	}
+	if stats := w.Stats(); stats.UsedBytes != 25 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats after eviction: %+v", stats)
+	}
+
+	w.Put("huge", "v1", make([]byte, 31), "")
+	if _, err := w.Get("huge", "v1", ""); !errors.Is(err, ErrNotCached) {
+		t.Errorf("entries larger than the budget must not be cached")
+	}
+}
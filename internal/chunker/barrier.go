@@ -0,0 +1,46 @@
+package chunker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// holdChunks takes the chunk barrier on the hashes and stored paths of
+// chunks about to be recorded, so no deletion can remove them until the file
+// using them is committed and the returned func is called. A deletion that
+// ran between storing a blob and taking the barrier may have removed an
+// identical blob, so every path is checked once held; restore, when given,
+// puts a missing chunk back, and without it the store fails.
+func holdChunks(ctx context.Context, metaStore *metadata.MetadataStore, store storage.Storage, chunks []ChunkMetadata, restore func(i int) error) (func(), error) {
+	keys := make([]string, 0, 2*len(chunks))
+	for _, chunk := range chunks {
+		keys = append(keys, chunk.Hash, chunk.Path)
+	}
+	release, waited, err := metaStore.ChunkBarrier().Hold(ctx, keys...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reference stored chunks: %w", err)
+	}
+	if len(waited) > 0 {
+		tracing.Logf(ctx, "⏳ Waited for a deletion of %d shared chunk keys to finish", len(waited))
+	}
+
+	for i, chunk := range chunks {
+		if exists, err := store.Exists(chunk.Path); err == nil && exists {
+			continue
+		}
+		if restore == nil {
+			release()
+			return nil, fmt.Errorf("chunk %d was deleted while being stored; retry the upload", chunk.Index)
+		}
+		if err := restore(i); err != nil {
+			release()
+			return nil, fmt.Errorf("failed to store chunk %d again after a concurrent deletion: %v", chunk.Index, err)
+		}
+		tracing.Logf(ctx, "♻️ Stored chunk %d again after a concurrent deletion removed it", chunk.Index)
+	}
+	return release, nil
+}
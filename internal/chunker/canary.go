@@ -0,0 +1,70 @@
+package chunker
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// KeyCanaryVersion is the canary scheme written with new files. Version 1
+// seals canaryPlaintext followed by the content ID with the same AEAD as the
+// chunks, under a fresh nonce (and, for passwords, a fresh salt), so the
+// canary reveals no more about the key than any chunk does.
+const KeyCanaryVersion = 1
+
+const canaryPlaintext = "disktrobyte-key-canary-v1:"
+
+// canaryEnabled reports whether new files get a key canary
+func canaryEnabled() bool {
+	return config.Config != nil && config.Config.KeyCanary
+}
+
+// sealCanary records a key canary for the content in fm, when enabled
+func sealCanary(fm *metadata.FileMetadata, cipher *chunkCipher, contentID string) error {
+	fm.KeyCanary, fm.CanaryVersion, fm.CanaryAlgo = nil, 0, 0
+	if !canaryEnabled() {
+		return nil
+	}
+	sealed, _, err := cipher.seal([]byte(canaryPlaintext + contentID))
+	if err != nil {
+		return fmt.Errorf("failed to seal key canary: %v", err)
+	}
+	fm.KeyCanary, fm.CanaryVersion, fm.CanaryAlgo = sealed, KeyCanaryVersion, cipher.algo()
+	return nil
+}
+
+// hasCanary reports whether fm carries a canary this version can open
+func hasCanary(fm metadata.FileMetadata) bool {
+	return fm.CanaryVersion == KeyCanaryVersion && len(fm.KeyCanary) > 0
+}
+
+// HasKeyCanary reports whether a file's key can be checked from its metadata alone
+func HasKeyCanary(metaStore *metadata.MetadataStore, fileID string) bool {
+	if metaStore == nil {
+		return false
+	}
+	fileMeta, err := metaStore.GetFileMetadataByID(fileID)
+	return err == nil && hasCanary(fileMeta)
+}
+
+// checkCanary opens the canary in fm with cipher. It returns ErrWrongPassword
+// when the key does not open it, and false when fm has no canary to check.
+func checkCanary(fm metadata.FileMetadata, cipher *chunkCipher, contentID string) (bool, error) {
+	if !hasCanary(fm) {
+		return false, nil
+	}
+	if fm.CanaryAlgo != cipher.algo() {
+		return true, fmt.Errorf("key canary sealed with algorithm %d, expected %d", fm.CanaryAlgo, cipher.algo())
+	}
+	plaintext, err := cipher.open(fm.KeyCanary, nil)
+	if err != nil {
+		return true, ErrWrongPassword
+	}
+	// A canary copied from other content opens but does not vouch for this content's chunks
+	if !bytes.Equal(plaintext, []byte(canaryPlaintext+contentID)) {
+		return true, fmt.Errorf("key canary belongs to other content than %s", contentID)
+	}
+	return true, nil
+}
@@ -0,0 +1,191 @@
+package chunker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// Chunking modes. Fixed splits at every chunk size; content-defined chunking
+// cuts where a rolling hash of the last 64 bytes matches, so an insert or
+// delete only changes the chunks around it and the rest still deduplicate.
+const (
+	ChunkModeFixed = "fixed"
+	ChunkModeCDC   = "cdc"
+)
+
+// ParseChunkMode validates a chunking mode name, "" meaning fixed
+func ParseChunkMode(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ChunkModeFixed:
+		return ChunkModeFixed, nil
+	case ChunkModeCDC:
+		return ChunkModeCDC, nil
+	default:
+		return "", fmt.Errorf("unsupported chunk mode: %s", name)
+	}
+}
+
+// gearTable maps each byte to a pseudo-random value for the rolling hash.
+// It is derived from a fixed seed: changing it moves every boundary and
+// stops new uploads deduplicating against chunks already stored.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x4469736b74726f42) // "DisktroB"
+	for i := range table {
+		// splitmix64
+		state += 0x9E3779B97F4A7C15
+		z := state
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// cdcSizes are the bounds of content-defined chunks in bytes
+type cdcSizes struct {
+	Min, Avg, Max int64
+}
+
+// resolveCDCSizes picks the chunk bounds for a file. The average follows the
+// fixed chunk size rules when unset; min and max default to a quarter and
+// four times the average.
+func resolveCDCSizes(fileSize int64) (cdcSizes, error) {
+	var sizes cdcSizes
+	requested := configuredChunkSize()
+	if config.Config != nil {
+		sizes = cdcSizes{Min: config.Config.CDCMinSize, Avg: config.Config.CDCAvgSize, Max: config.Config.CDCMaxSize}
+		if sizes.Avg > 0 {
+			requested = sizes.Avg
+		}
+	}
+	avg, err := resolveChunkSize(fileSize, requested)
+	if err != nil {
+		return cdcSizes{}, err
+	}
+	sizes.Avg = avg
+	if sizes.Min <= 0 {
+		sizes.Min = avg / 4
+	}
+	if sizes.Max <= 0 {
+		sizes.Max = min(avg*4, MaxChunkSize)
+	}
+
+	if sizes.Min > sizes.Avg || sizes.Avg > sizes.Max {
+		return cdcSizes{}, fmt.Errorf("content-defined chunk sizes must satisfy min <= avg <= max, got %d/%d/%d",
+			sizes.Min, sizes.Avg, sizes.Max)
+	}
+	if sizes.Max > MaxChunkSize {
+		return cdcSizes{}, fmt.Errorf("content-defined max chunk size %d exceeds the limit of %d bytes", sizes.Max, MaxChunkSize)
+	}
+	return sizes, nil
+}
+
+// chunkSplitter yields a file's chunks in order. The slice it returns is
+// only valid until the next call; io.EOF follows the last chunk.
+type chunkSplitter interface {
+	next() ([]byte, error)
+}
+
+// newChunkSplitter returns the splitter for the configured chunking mode
+func newChunkSplitter(r io.Reader, fileSize int64) (chunkSplitter, error) {
+	mode := ChunkModeFixed
+	if config.Config != nil {
+		parsed, err := ParseChunkMode(config.Config.ChunkMode)
+		if err != nil {
+			return nil, err
+		}
+		mode = parsed
+	}
+
+	if mode == ChunkModeCDC {
+		sizes, err := resolveCDCSizes(fileSize)
+		if err != nil {
+			return nil, err
+		}
+		return newCDCSplitter(r, sizes), nil
+	}
+
+	chunkSize, err := resolveChunkSize(fileSize, configuredChunkSize())
+	if err != nil {
+		return nil, err
+	}
+	return &fixedSplitter{r: r, buf: make([]byte, chunkSize)}, nil
+}
+
+// fixedSplitter reads chunks of one size, the last possibly shorter
+type fixedSplitter struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (s *fixedSplitter) next() ([]byte, error) {
+	n, err := io.ReadFull(s.r, s.buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, io.EOF
+	}
+	return s.buf[:n], nil
+}
+
+// cdcSplitter cuts chunks with a gear rolling hash. Each byte shifts the hash
+// left, so its top bits depend only on the last 64 bytes and a boundary is
+// found again wherever the same content reappears.
+type cdcSplitter struct {
+	r        *bufio.Reader
+	sizes    cdcSizes
+	mask     uint64
+	buf      []byte
+	produced int
+	limit    int
+}
+
+func newCDCSplitter(r io.Reader, sizes cdcSizes) *cdcSplitter {
+	// A cut is expected every 2^n bytes past the minimum, n chosen so chunks average out near Avg
+	var mask uint64
+	if spread := sizes.Avg - sizes.Min; spread > 1 {
+		mask = ^uint64(0) << (64 - (bits.Len64(uint64(spread)) - 1))
+	}
+	return &cdcSplitter{
+		r:     bufio.NewReaderSize(r, 64*1024),
+		sizes: sizes,
+		mask:  mask,
+		buf:   make([]byte, 0, sizes.Max),
+		limit: maxChunkCount(),
+	}
+}
+
+func (s *cdcSplitter) next() ([]byte, error) {
+	s.buf = s.buf[:0]
+	var hash uint64
+	for int64(len(s.buf)) < s.sizes.Max {
+		b, err := s.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.buf = append(s.buf, b)
+		hash = hash<<1 + gearTable[b]
+		if int64(len(s.buf)) >= s.sizes.Min && hash&s.mask == 0 {
+			break
+		}
+	}
+	if len(s.buf) == 0 {
+		return nil, io.EOF
+	}
+
+	s.produced++
+	if s.produced > s.limit {
+		return nil, fmt.Errorf("content-defined chunking produced more than %d chunks", s.limit)
+	}
+	return s.buf, nil
+}
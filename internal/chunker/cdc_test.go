@@ -0,0 +1,96 @@
+package chunker
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestContentDefinedChunksSurviveAnInsert(t *testing.T) {
+	config.Config = &config.AppConfig{
+		ParallelismRatio: 2,
+		ChunkMode:        ChunkModeCDC,
+		CDCMinSize:       64 * 1024,
+		CDCAvgSize:       256 * 1024,
+		CDCMaxSize:       1024 * 1024,
+	}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	original := make([]byte, 5*1024*1024)
+	rand.New(rand.NewSource(7)).Read(original)
+	edited := append([]byte("0123456789"), original...)
+
+	// Each version gets its own metadata store, as chunk records are keyed by hash
+	upload := func(name string, content []byte) []ChunkMetadata {
+		metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, name+".db"))
+		if err != nil {
+			t.Fatalf("failed to open metadata store: %v", err)
+		}
+		defer metaStore.Close()
+
+		input := filepath.Join(tempDir, name)
+		os.WriteFile(input, content, 0644)
+		chunks, err := ChunkAndStore(input, "pw", metaStore, local)
+		if err != nil {
+			t.Fatalf("ChunkAndStore of %s failed: %v", name, err)
+		}
+
+		output := filepath.Join(tempDir, name+".out")
+		if err := ReassembleFile(chunks[0].FileID, output, "pw", metaStore, local); err != nil {
+			t.Fatalf("ReassembleFile of %s failed: %v", name, err)
+		}
+		if got, _ := os.ReadFile(output); !bytes.Equal(got, content) {
+			t.Fatalf("%s did not reassemble byte for byte", name)
+		}
+		return chunks
+	}
+	originalChunks := upload("original.bin", original)
+	editedChunks := upload("edited.bin", edited)
+
+	var sizes []int64
+	for i, chunk := range originalChunks {
+		end := int64(len(original))
+		if i+1 < len(originalChunks) {
+			end = originalChunks[i+1].Offset
+		}
+		size := end - chunk.Offset
+		if size > config.Config.CDCMaxSize || (i+1 < len(originalChunks) && size < config.Config.CDCMinSize) {
+			t.Errorf("chunk %d is %d bytes, outside the configured bounds", i, size)
+		}
+		sizes = append(sizes, size)
+	}
+	if len(sizes) < 10 || sizes[0] == sizes[1] && sizes[1] == sizes[2] {
+		t.Fatalf("expected variable-size chunks around the average, got %v", sizes)
+	}
+
+	originalHashes := make(map[string]bool)
+	for _, chunk := range originalChunks {
+		originalHashes[chunk.Hash] = true
+	}
+	shared := 0
+	for _, chunk := range editedChunks {
+		if originalHashes[chunk.Hash] {
+			shared++
+		}
+	}
+	if ratio := float64(shared) / float64(len(editedChunks)); ratio <= 0.8 {
+		t.Errorf("expected over 80%% of chunks shared after a 10 byte insert, got %d of %d", shared, len(editedChunks))
+	}
+
+	config.Config.ChunkMode = "rabin"
+	if _, err := ChunkAndStore(filepath.Join(tempDir, "original.bin"), "pw", nil, local); err == nil {
+		t.Errorf("expected an unsupported chunk mode to be refused")
+	}
+}
@@ -1,6 +1,7 @@
 package chunker
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -9,19 +10,23 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"bytes"
 
 	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/budget"
 	"github.com/jaywantadh/DisktroByte/internal/compressor"
 	"github.com/jaywantadh/DisktroByte/internal/encryptor"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/telemetry"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
 )
 
 type ChunkMetadata struct {
 	Index        int    // Position of this chunk in the sequence
-	Hash         string // SHA-256 hash of original chunk data
+	Hash         string // Hash of original chunk data, see HashAlgo
 	Path         string // Storage path (hash) of encrypted chunk file
 	Size         int64  // Encrypted size of this chunk
 	Offset       int64  // Byte offset in the original file
@@ -30,34 +35,195 @@ type ChunkMetadata struct {
 	TotalChunks  int    // Total chunks in this file
 	FileID       string // Unique file identifier (SHA-256 of full file)
 	IsCompressed bool   // Whether this chunk was compressed
+	CRC32C       uint32 // CRC32C of the stored bytes, screened before the full hash
+	HashAlgo     string // Algorithm of Hash, empty for SHA-256
+	// Compression of a compressed chunk: the algorithm, its compressed size
+	// and its original size divided by that
+	CompressionAlgo  string
+	CompressedSize   int64
+	CompressionRatio float64
+	// Content hash and index the header of a chunk shared with another file names
+	SharedFrom  string
+	SharedIndex int
 }
 
 type chunkTask struct {
-	Index int
-	Data  []byte
+	Index  int
+	Offset int64
+	Data   []byte
 }
 
-// ChunkAndStore splits, compresses, encrypts, and stores file chunks, and writes metadata to the provided MetadataStore
+// ChunkAndStore splits, compresses, encrypts, and stores file chunks, and writes metadata to the provided MetadataStore.
+// Every chunk returned carries the SHA-256 of the whole file as its FileID, checked against the bytes as they were split.
 func ChunkAndStore(filePath, password string, metaStore *metadata.MetadataStore, store storage.Storage) ([]ChunkMetadata, error) {
-	file, err := os.Open(filePath)
+	return ChunkAndStoreContext(context.Background(), filePath, password, metaStore, store)
+}
+
+// ChunkAndStoreContext works like ChunkAndStore, logging under the context's trace ID
+func ChunkAndStoreContext(ctx context.Context, filePath, password string, metaStore *metadata.MetadataStore, store storage.Storage) ([]ChunkMetadata, error) {
+	return ChunkAndStoreSourceContext(ctx, FileSource(filePath), password, metaStore, store)
+}
+
+// ChunkAndStoreSourceContext works like ChunkAndStoreContext for content that need not be a file on disk
+func ChunkAndStoreSourceContext(ctx context.Context, src Source, password string, metaStore *metadata.MetadataStore, store storage.Storage) ([]ChunkMetadata, error) {
+	return chunkAndStore(ctx, src, passwordCipher(password), nil, metaStore, store, false)
+}
+
+// ResumeChunking finishes a ChunkAndStore of filePath that was interrupted.
+// The chunks its upload manifest records are verified by hash and kept, and
+// only the rest of the file is processed. It fails when no upload of the
+// file's content was interrupted.
+func ResumeChunking(filePath, password string, metaStore *metadata.MetadataStore, store storage.Storage) ([]ChunkMetadata, error) {
+	if metaStore == nil {
+		return nil, fmt.Errorf("metadata store is required to resume chunking")
+	}
+	return chunkAndStore(context.Background(), FileSource(filePath), passwordCipher(password), nil, metaStore, store, true)
+}
+
+// ChunkAndStoreWithServerKey works like ChunkAndStore but encrypts chunks with a fresh
+// per-file data key wrapped by the server master key, so no password is needed.
+func ChunkAndStoreWithServerKey(filePath string, km *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage) ([]ChunkMetadata, error) {
+	return ChunkAndStoreWithServerKeyContext(context.Background(), filePath, km, metaStore, store)
+}
+
+// ChunkAndStoreWithServerKeyContext works like ChunkAndStoreWithServerKey, logging under the context's trace ID
+func ChunkAndStoreWithServerKeyContext(ctx context.Context, filePath string, km *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage) ([]ChunkMetadata, error) {
+	return ChunkAndStoreSourceWithServerKeyContext(ctx, FileSource(filePath), km, metaStore, store)
+}
+
+// ChunkAndStoreSourceWithServerKeyContext works like ChunkAndStoreWithServerKeyContext for content that need not be a file on disk
+func ChunkAndStoreSourceWithServerKeyContext(ctx context.Context, src Source, km *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage) ([]ChunkMetadata, error) {
+	if km == nil {
+		return nil, fmt.Errorf("server-managed keys are not configured")
+	}
+	if metaStore == nil {
+		return nil, fmt.Errorf("metadata store is required for server-managed keys")
+	}
+
+	dataKey, wrappedKey, err := km.GenerateDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return chunkAndStore(ctx, src, dataKeyCipher(dataKey), func(fm *metadata.FileMetadata) {
+		fm.KeyMode = metadata.KeyModeServerManaged
+		fm.KeyID = km.KeyID()
+		fm.WrappedKey = wrappedKey
+	}, metaStore, store, false)
+}
+
+func chunkAndStore(ctx context.Context, src Source, cipher *chunkCipher, annotate func(*metadata.FileMetadata), metaStore *metadata.MetadataStore, store storage.Storage, resume bool) (stored []ChunkMetadata, err error) {
+	ctx, span := telemetry.Start(ctx, telemetry.OpUpload, telemetry.Attrs{"file.name": src.Name()})
+	defer func() { span.End(err) }()
+
+	content, err := storeContent(ctx, src, cipher, metaStore, store, resume)
+	if err != nil {
+		return nil, tracing.Error(ctx, err)
+	}
+
+	// Store enhanced chunk metadata in BadgerDB
+	if metaStore != nil {
+		release, err := holdChunks(ctx, metaStore, store, content.Chunks, nil)
+		if err != nil {
+			return nil, tracing.Error(ctx, err)
+		}
+		defer release()
+		if err := putChunkMetadata(metaStore, store, content.Chunks); err != nil {
+			return nil, err
+		}
+
+		// Store file metadata in BadgerDB by both filename and FileID
+		fileMeta := metadata.NewFileMetadata(content.Name, content.Size, content.ChunkHashes)
+		fileMeta.HashAlgo = content.HashAlgo
+		if annotate != nil {
+			annotate(&fileMeta)
+		}
+		if err := sealCanary(&fileMeta, cipher, content.ContentID); err != nil {
+			return nil, tracing.Error(ctx, err)
+		}
+		if err := metaStore.PutFileMetadata(fileMeta); err != nil {
+			return nil, tracing.Error(ctx, fmt.Errorf("failed to store file metadata: %v", err))
+		}
+		if err := metaStore.PutFileMetadataByID(content.ContentID, fileMeta); err != nil {
+			return nil, tracing.Error(ctx, fmt.Errorf("failed to store file metadata by ID: %v", err))
+		}
+		if err := metaStore.DeleteUploadManifest(content.ContentID); err != nil {
+			tracing.Logf(ctx, "⚠️ Failed to clear the upload manifest of %s: %v", content.Name, err)
+		}
+	}
+
+	span.SetAttr("file.id", content.ContentID)
+	span.SetAttr("chunks", len(content.Chunks))
+	span.AddBytes(content.Size)
+	tracing.Logf(ctx, "✂️ Chunked %s into %d chunks (%d bytes)", content.Name, len(content.Chunks), content.Size)
+	return content.Chunks, nil
+}
+
+// storedContent is the result of chunking a file into storage
+type storedContent struct {
+	ContentID   string // SHA-256 of the file contents
+	Name        string
+	Size        int64
+	Chunks      []ChunkMetadata // In index order, linked
+	ChunkHashes []string
+	HashAlgo    string // Algorithm of the chunk hashes
+}
+
+// storeContent splits, compresses, encrypts and stores a file's chunks
+// without recording any file metadata. Chunks metaStore already records for
+// the same content under the same key, and that are still stored, are reused
+// as they are; a nil metaStore stores every chunk. Each chunk stored is added
+// to the content's upload manifest, so a run that fails part way leaves
+// progress a later run picks up; with resume set that progress must exist.
+func storeContent(ctx context.Context, src Source, cipher *chunkCipher, metaStore *metadata.MetadataStore, store storage.Storage, resume bool) (*storedContent, error) {
+	filePath := src.Name()
+	file, err := src.Open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %v", err)
 	}
 	defer file.Close()
 
-	fileInfo, err := file.Stat()
+	fileSize, err := src.Size()
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat file: %v", err)
 	}
-	fileSize := fileInfo.Size()
-	chunkSize := determineChunkSize(fileSize)
+	// The content is hashed again as it is split, so the file ID is known to
+	// match the bytes actually stored
+	contentHash := sha256.New()
+	splitter, err := newChunkSplitter(io.TeeReader(file, contentHash), fileSize)
+	if err != nil {
+		tracing.Logf(ctx, "❌ Rejected chunking of %s: %v", filePath, err)
+		return nil, err
+	}
+	hashAlgo, err := configuredHashAlgo()
+	if err != nil {
+		return nil, err
+	}
+	compression, err := configuredCompression()
+	if err != nil {
+		return nil, err
+	}
+	if compressor.ShouldSkipCompression(filePath) {
+		compression = compressor.AlgoNone
+	}
 
 	// Calculate FileID (SHA-256 hash of entire file)
-	fileID, err := CalculateFileHash(filePath)
+	fileID, err := HashSource(src)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate file ID: %v", err)
 	}
 
+	reusable := reusableChunks(metaStore, store, cipher, fileID)
+	progress, recorded := resumableChunks(metaStore, store, cipher, fileID)
+	if resume && recorded == 0 {
+		return nil, fmt.Errorf("no interrupted upload of %s to resume", filePath)
+	}
+	if recorded > 0 {
+		tracing.Logf(ctx, "⏯️ Resuming %s with %d of %d recorded chunks verified", filePath, len(progress), recorded)
+		reusable = reusable.merge(progress)
+	}
+	var reused, shared atomic.Int32
+
 	parallelismRatio := config.Config.ParallelismRatio
 	if parallelismRatio <= 0 {
 		parallelismRatio = 2 // Default to 2 if config value is invalid
@@ -68,6 +234,7 @@ func ChunkAndStore(filePath, password string, metaStore *metadata.MetadataStore,
 	}
 
 	taskChan := make(chan chunkTask, numWorkers*2)
+	memory := budget.Global()
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var metadataList []ChunkMetadata
@@ -75,101 +242,166 @@ func ChunkAndStore(filePath, password string, metaStore *metadata.MetadataStore,
 	var processErr error
 	var chunkHashes []string
 
-	enc := encryptor.NewEncryptor()
-
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			var held int64
+			defer func() {
+				memory.Release(held)
+				drainTasks(taskChan, memory)
+			}()
 			for task := range taskChan {
+				held = int64(len(task.Data))
 				// Calculate hash of original data for integrity verification
-				originalHash := sha256.Sum256(task.Data)
-				originalHashStr := hex.EncodeToString(originalHash[:])
+				originalHashStr, err := ChunkHash(hashAlgo, task.Data)
+				if err != nil {
+					setErrOnce(&errOnce, &processErr, err)
+					return
+				}
 
-				// Process data (compression)
-				var processedData []byte
-				isCompressed := false
-				if compressor.ShouldSkipCompression(filePath) {
-					processedData = task.Data
-				} else {
-					compressed, err := compressor.CompressChunk(task.Data)
-					if err != nil {
-						setErrOnce(&errOnce, &processErr, fmt.Errorf("compression failed: %v", err))
-						return
-					}
-					processedData = compressed
-					isCompressed = true
+				// The same bytes at the same place, already stored under this key, need no new copy
+				if prior, ok := reusable.match(task, originalHashStr, hashAlgo, store); ok {
+					mu.Lock()
+					metadataList = append(metadataList, prior)
+					chunkHashes = append(chunkHashes, originalHashStr)
+					mu.Unlock()
+					reused.Add(1)
+					memory.Release(held)
+					held = 0
+					continue
+				}
+
+				// Identical bytes another file stored under a key this one opens are shared, not written again
+				if prior, ok := sharedChunk(metaStore, store, cipher, fileID, task, originalHashStr, hashAlgo); ok {
+					mu.Lock()
+					metadataList = append(metadataList, prior)
+					chunkHashes = append(chunkHashes, originalHashStr)
+					mu.Unlock()
+					shared.Add(1)
+					memory.Release(held)
+					held = 0
+					continue
+				}
+
+				// Compress before encrypting, keeping the chunk as it is when that doesn't shrink it
+				processedData, compressionID, err := compressChunk(compression, task.Data)
+				if err != nil {
+					setErrOnce(&errOnce, &processErr, err)
+					return
 				}
 
 				// Encrypt processed data
-				encrypted, err := enc.Encrypt(processedData, password)
+				encrypted, nonce, err := cipher.seal(processedData)
 				if err != nil {
 					setErrOnce(&errOnce, &processErr, fmt.Errorf("encryption failed: %v", err))
 					return
 				}
 
+				// Prepend self-describing header so the chunk can be read without the DB
+				stored, err := encodeStoredChunk(ChunkHeader{
+					FileID:         fileID,
+					Index:          task.Index,
+					OriginalSize:   int64(len(task.Data)),
+					EncryptionAlgo: cipher.algo(),
+					Nonce:          nonce,
+				}, compressionID, encrypted)
+				if err != nil {
+					setErrOnce(&errOnce, &processErr, fmt.Errorf("failed to build chunk header: %v", err))
+					return
+				}
+
 				// Store encrypted chunk (returns storage path/hash)
-				chunkPath, err := store.Put(bytes.NewReader(encrypted))
+				chunkPath, err := store.Put(bytes.NewReader(stored))
 				if err != nil {
+					tracing.Logf(ctx, "❌ Failed to store chunk %d of %s: %v", task.Index, filePath, err)
 					setErrOnce(&errOnce, &processErr, fmt.Errorf("failed to store chunk: %v", err))
 					return
 				}
 
-				// Calculate offset based on chunk index and size
-				offset := int64(task.Index) * chunkSize
-
 				// Create preliminary chunk metadata (linked-list info will be filled later)
 				info := ChunkMetadata{
 					Index:        task.Index,
 					Hash:         originalHashStr, // Hash of original data for verification
 					Path:         chunkPath,       // Storage path (encrypted hash)
-					Size:         int64(len(encrypted)),
-					Offset:       offset,
+					Size:         int64(len(stored)),
+					Offset:       task.Offset,
 					PrevIndex:    -1, // Will be set later
 					NextIndex:    -1, // Will be set later
 					TotalChunks:  0,  // Will be set later
 					FileID:       fileID,
-					IsCompressed: isCompressed,
+					IsCompressed: compressionID != CompressionNone,
+					CRC32C:       storage.ChunkCRC(stored),
+					HashAlgo:     hashAlgo,
+				}
+				if info.IsCompressed {
+					info.CompressionAlgo = compression
+					info.CompressedSize = int64(len(processedData))
+					info.CompressionRatio = float64(len(task.Data)) / float64(len(processedData))
+				}
+
+				// Record progress so a failed run can resume from here
+				if metaStore != nil {
+					if err := metaStore.PutUploadChunk(fileID, toMetadataChunk(info)); err != nil {
+						tracing.Logf(ctx, "⚠️ Failed to record progress of chunk %d of %s: %v", task.Index, filePath, err)
+					}
 				}
 
 				mu.Lock()
 				metadataList = append(metadataList, info)
 				chunkHashes = append(chunkHashes, originalHashStr) // Use original hash
 				mu.Unlock()
+
+				memory.Release(held)
+				held = 0
 			}
 		}()
 	}
 
-	buf := make([]byte, chunkSize)
 	index := 0
+	var offset int64
 	for {
-		n, err := io.ReadFull(file, buf)
-		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		data, err := splitter.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
 			close(taskChan)
 			wg.Wait()
 			return nil, fmt.Errorf("failed to read chunk: %v", err)
 		}
-		if n == 0 {
-			break
-		}
 
+		// Wait for room in the memory budget before buffering another chunk
+		n := len(data)
+		memory.Acquire(context.Background(), int64(n))
 		taskCopy := make([]byte, n)
-		copy(taskCopy, buf[:n])
-		taskChan <- chunkTask{Index: index, Data: taskCopy}
+		copy(taskCopy, data)
+		taskChan <- chunkTask{Index: index, Offset: offset, Data: taskCopy}
 		index++
-
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			break
-		}
+		offset += int64(n)
 	}
 
 	close(taskChan)
 	wg.Wait()
 
 	if processErr != nil {
+		if metaStore != nil && len(metadataList) > 0 {
+			tracing.Logf(ctx, "💾 Kept %d stored chunks of %s for a later resume", len(metadataList), filePath)
+		}
 		return nil, processErr
 	}
 
+	if hash := hex.EncodeToString(contentHash.Sum(nil)); hash != fileID {
+		return nil, fmt.Errorf("content of %s changed while it was chunked: hashed as %s, split as %s", filePath, fileID, hash)
+	}
+
+	if n := reused.Load(); n > 0 {
+		tracing.Logf(ctx, "♻️ Reused %d of %d chunks of %s already in storage", n, len(metadataList), filePath)
+	}
+	if n := shared.Load(); n > 0 {
+		tracing.Logf(ctx, "🔗 Shared %d of %d chunks of %s with files already storing them", n, len(metadataList), filePath)
+	}
+
 	// Sort metadata by index to ensure correct order
 	sortMetadataByIndex(metadataList)
 	totalChunks := len(metadataList)
@@ -193,37 +425,66 @@ func ChunkAndStore(filePath, password string, metaStore *metadata.MetadataStore,
 		}
 	}
 
-	// Store enhanced chunk metadata in BadgerDB
-	if metaStore != nil {
-		for _, chunk := range metadataList {
-			chunkMeta := metadata.ChunkMetadata{
-				Index:        chunk.Index,
-				Hash:         chunk.Hash,
-				Path:         chunk.Path,
-				Size:         chunk.Size,
-				Offset:       chunk.Offset,
-				PrevIndex:    chunk.PrevIndex,
-				NextIndex:    chunk.NextIndex,
-				TotalChunks:  chunk.TotalChunks,
-				FileID:       chunk.FileID,
-				IsCompressed: chunk.IsCompressed,
-			}
-			if err := metaStore.PutChunkMetadata(chunkMeta); err != nil {
-				return nil, fmt.Errorf("failed to store chunk metadata: %v", err)
-			}
-		}
+	return &storedContent{
+		ContentID:   fileID,
+		Name:        filePath,
+		Size:        fileSize,
+		Chunks:      metadataList,
+		ChunkHashes: chunkHashes,
+		HashAlgo:    hashAlgo,
+	}, nil
+}
 
-		// Store file metadata in BadgerDB by both filename and FileID
-		fileMeta := metadata.NewFileMetadata(fileInfo.Name(), fileSize, chunkHashes)
-		if err := metaStore.PutFileMetadata(fileMeta); err != nil {
-			return nil, fmt.Errorf("failed to store file metadata: %v", err)
+// putChunkMetadata records the chunks of a stored file. The record under a
+// chunk's hash stays with the file that stored the chunk first while its bytes
+// are in store; any other use of the hash is recorded as a reference, which
+// adds to that record's reference count.
+func putChunkMetadata(metaStore *metadata.MetadataStore, store storage.Storage, chunks []ChunkMetadata) error {
+	for _, chunk := range chunks {
+		record := toMetadataChunk(chunk)
+		owner, err := metaStore.GetChunkMetadata(chunk.Hash)
+		switch {
+		case err != nil:
+			// First to store the chunk
+		case owner.FileID == record.FileID && owner.Index == record.Index:
+			record.RefCount = owner.RefCount
+		case chunk.SharedFrom == "" && !storedChunk(store, owner.Path):
+			// The owner's bytes are gone, so this copy takes over the record
+		default:
+			if _, err := metaStore.AddChunkReference(record); err != nil {
+				return fmt.Errorf("failed to store chunk reference: %v", err)
+			}
+			continue
 		}
-		if err := metaStore.PutFileMetadataByID(fileID, fileMeta); err != nil {
-			return nil, fmt.Errorf("failed to store file metadata by ID: %v", err)
+		if err := metaStore.PutChunkMetadata(record); err != nil {
+			return fmt.Errorf("failed to store chunk metadata: %v", err)
 		}
 	}
+	return nil
+}
 
-	return metadataList, nil
+// toMetadataChunk converts chunker output into the stored chunk record
+func toMetadataChunk(chunk ChunkMetadata) metadata.ChunkMetadata {
+	return metadata.ChunkMetadata{
+		Index:        chunk.Index,
+		Hash:         chunk.Hash,
+		Path:         chunk.Path,
+		Size:         chunk.Size,
+		Offset:       chunk.Offset,
+		PrevIndex:    chunk.PrevIndex,
+		NextIndex:    chunk.NextIndex,
+		TotalChunks:  chunk.TotalChunks,
+		FileID:       chunk.FileID,
+		IsCompressed: chunk.IsCompressed,
+		CRC32C:       chunk.CRC32C,
+		HashAlgo:     chunk.HashAlgo,
+
+		CompressionAlgo:  chunk.CompressionAlgo,
+		CompressedSize:   chunk.CompressedSize,
+		CompressionRatio: chunk.CompressionRatio,
+		SharedFrom:       chunk.SharedFrom,
+		SharedIndex:      chunk.SharedIndex,
+	}
 }
 
 // ChunkAndProcess processes each chunk in memory (no file output)
@@ -240,7 +501,15 @@ func ChunkAndProcess(filePath string, password string, callback func(chunkMeta C
 		return fmt.Errorf("failed to stat file: %v", err)
 	}
 	fileSize := fileInfo.Size()
-	chunkSize := determineChunkSize(fileSize)
+	splitter, err := newChunkSplitter(file, fileSize)
+	if err != nil {
+		fmt.Printf("❌ Rejected chunking of %s: %v\n", filePath, err)
+		return err
+	}
+	hashAlgo, err := configuredHashAlgo()
+	if err != nil {
+		return err
+	}
 
 	// Calculate FileID (SHA-256 hash of entire file)
 	fileID, err := CalculateFileHash(filePath)
@@ -258,6 +527,7 @@ func ChunkAndProcess(filePath string, password string, callback func(chunkMeta C
 	}
 
 	taskChan := make(chan chunkTask, numWorkers*2)
+	memory := budget.Global()
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var errOnce sync.Once
@@ -271,9 +541,18 @@ func ChunkAndProcess(filePath string, password string, callback func(chunkMeta C
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			var held int64
+			defer func() {
+				memory.Release(held)
+				drainTasks(taskChan, memory)
+			}()
 			for task := range taskChan {
-				hash := sha256.Sum256(task.Data)
-				hashStr := hex.EncodeToString(hash[:])
+				held = int64(len(task.Data))
+				hashStr, err := ChunkHash(hashAlgo, task.Data)
+				if err != nil {
+					setErrOnce(&errOnce, &processErr, err)
+					return
+				}
 
 				var processedData []byte
 				if compressor.ShouldSkipCompression(filePath) {
@@ -293,49 +572,52 @@ func ChunkAndProcess(filePath string, password string, callback func(chunkMeta C
 					return
 				}
 
-				// Calculate offset and create chunk metadata
-				offset := int64(task.Index) * chunkSize
+				// Create chunk metadata
 				chunkMeta := ChunkMetadata{
 					Index:       task.Index,
 					Hash:        hashStr,
 					Path:        "", // No path for in-memory processing
 					Size:        int64(len(encrypted)),
-					Offset:      offset,
+					Offset:      task.Offset,
 					PrevIndex:   -1, // Will be set later
 					NextIndex:   -1, // Will be set later
 					TotalChunks: 0,  // Will be set later
 					FileID:      fileID,
+					HashAlgo:    hashAlgo,
 				}
 
 				mu.Lock()
 				metadataList = append(metadataList, chunkMeta)
 				encryptedDataList = append(encryptedDataList, encrypted)
 				mu.Unlock()
+
+				memory.Release(held)
+				held = 0
 			}
 		}()
 	}
 
-	buf := make([]byte, chunkSize)
 	index := 0
+	var offset int64
 	for {
-		n, err := io.ReadFull(file, buf)
-		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		data, err := splitter.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
 			close(taskChan)
 			wg.Wait()
 			return fmt.Errorf("failed to read chunk: %v", err)
 		}
-		if n == 0 {
-			break
-		}
 
+		// Wait for room in the memory budget before buffering another chunk
+		n := len(data)
+		memory.Acquire(context.Background(), int64(n))
 		taskCopy := make([]byte, n)
-		copy(taskCopy, buf[:n])
-		taskChan <- chunkTask{Index: index, Data: taskCopy}
+		copy(taskCopy, data)
+		taskChan <- chunkTask{Index: index, Offset: offset, Data: taskCopy}
 		index++
-
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			break
-		}
+		offset += int64(n)
 	}
 
 	close(taskChan)
@@ -391,22 +673,22 @@ func determineChunkSize(fileSize int64) int64 {
 	}
 }
 
-// CalculateFileHash computes SHA-256 hash of the entire file
-func CalculateFileHash(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open file for hashing: %v", err)
-	}
-	defer file.Close()
+// encodeStoredChunk prefixes an encrypted chunk payload with its ChunkHeader,
+// recording the compression the payload was compressed with
+func encodeStoredChunk(header ChunkHeader, compression uint8, encrypted []byte) ([]byte, error) {
+	header.Version = ChunkFormatVersion
+	header.CompressionAlgo = compression
 
-	hasher := sha256.New()
-	_, err = io.Copy(hasher, file)
+	headerBytes, err := header.Encode()
 	if err != nil {
-		return "", fmt.Errorf("failed to calculate file hash: %v", err)
+		return nil, err
 	}
+	return append(headerBytes, encrypted...), nil
+}
 
-	hash := hasher.Sum(nil)
-	return hex.EncodeToString(hash), nil
+// CalculateFileHash computes SHA-256 hash of the entire file
+func CalculateFileHash(filePath string) (string, error) {
+	return HashSource(FileSource(filePath))
 }
 
 // sortMetadataByIndex sorts chunk metadata by index to ensure proper ordering
@@ -416,6 +698,14 @@ func sortMetadataByIndex(chunks []ChunkMetadata) {
 	})
 }
 
+// drainTasks discards queued chunks after a worker fails, refunding their
+// memory so the reader is never left waiting on a budget nobody will release
+func drainTasks(tasks <-chan chunkTask, memory *budget.Budget) {
+	for task := range tasks {
+		memory.Release(int64(len(task.Data)))
+	}
+}
+
 func setErrOnce(once *sync.Once, target *error, err error) {
 	once.Do(func() {
 		*target = err
@@ -0,0 +1,184 @@
+package chunker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// ErrClientEncrypted is returned when server-side reassembly is asked for a
+// file only its client can decrypt
+var ErrClientEncrypted = fmt.Errorf("file is client-encrypted; fetch its chunks and decrypt them client-side")
+
+// ClientFileID derives the ID of a client-encrypted file from its ciphertext chunk hashes
+func ClientFileID(manifest metadata.ClientManifest) string {
+	hashes := make([]string, 0, len(manifest.Chunks))
+	for _, chunk := range manifest.Chunks {
+		hashes = append(hashes, chunk.Hash)
+	}
+	sum := sha256.Sum256([]byte("client:" + strings.Join(hashes, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// StoreClientEncrypted stores the ciphertext chunks of a file the client
+// encrypted itself. Each chunk is checked against the manifest's hash and
+// size and stored as is, so the server keeps only opaque blobs. The file is
+// recorded with KeyModeClient and its ID is returned.
+func StoreClientEncrypted(manifest metadata.ClientManifest, chunks []io.Reader, metaStore *metadata.MetadataStore, store storage.Storage) (string, error) {
+	if metaStore == nil {
+		return "", fmt.Errorf("metadata store is required for client-encrypted files")
+	}
+	if err := manifest.Validate(); err != nil {
+		return "", fmt.Errorf("invalid manifest: %v", err)
+	}
+	if len(chunks) != len(manifest.Chunks) {
+		return "", fmt.Errorf("manifest lists %d chunks but %d were uploaded", len(manifest.Chunks), len(chunks))
+	}
+
+	fileID := ClientFileID(manifest)
+	stored := make([]ChunkMetadata, 0, len(chunks))
+	blobs := make([][]byte, 0, len(chunks))
+	hashes := make([]string, 0, len(chunks))
+	var offset int64
+	for i, expected := range manifest.Chunks {
+		// Read at most one byte past the declared size to catch oversized chunks
+		data, err := io.ReadAll(io.LimitReader(chunks[i], expected.Size+1))
+		if err != nil {
+			return "", fmt.Errorf("failed to read chunk %d: %v", i, err)
+		}
+		if int64(len(data)) != expected.Size {
+			return "", fmt.Errorf("chunk %d is %d bytes, manifest declares %d", i, len(data), expected.Size)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(expected.Hash) {
+			return "", fmt.Errorf("chunk %d does not match its manifest hash", i)
+		}
+
+		path, err := store.Put(bytes.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("failed to store chunk %d: %v", i, err)
+		}
+
+		// The ciphertext hash stands in for the plaintext hash the server cannot know
+		stored = append(stored, ChunkMetadata{
+			Index:       i,
+			Hash:        path,
+			Path:        path,
+			Size:        expected.Size,
+			Offset:      offset,
+			PrevIndex:   i - 1,
+			NextIndex:   i + 1,
+			TotalChunks: len(chunks),
+			FileID:      fileID,
+			CRC32C:      storage.ChunkCRC(data),
+		})
+		hashes = append(hashes, path)
+		blobs = append(blobs, data)
+		offset += expected.Size
+	}
+	stored[len(stored)-1].NextIndex = -1
+
+	// Identical ciphertext lands on the same path as another file's chunks,
+	// which a concurrent deletion may remove; the blobs are at hand to put back
+	release, err := holdChunks(context.Background(), metaStore, store, stored, func(i int) error {
+		_, err := store.Put(bytes.NewReader(blobs[i]))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if err := putChunkMetadata(metaStore, store, stored); err != nil {
+		return "", err
+	}
+	fileMeta := metadata.NewFileMetadata(manifest.FileName, manifest.FileSize, hashes)
+	fileMeta.KeyMode = metadata.KeyModeClient
+	if err := metaStore.PutFileMetadata(fileMeta); err != nil {
+		return "", fmt.Errorf("failed to store file metadata: %v", err)
+	}
+	if err := metaStore.PutFileMetadataByID(fileID, fileMeta); err != nil {
+		return "", fmt.Errorf("failed to store file metadata by ID: %v", err)
+	}
+
+	fmt.Printf("🔐 Stored client-encrypted file %s (%d chunks)\n", fileID, len(stored))
+	return fileID, nil
+}
+
+// ClientManifestFor rebuilds the manifest of a client-encrypted file for download
+func ClientManifestFor(fileID string, metaStore *metadata.MetadataStore) (*metadata.ClientManifest, error) {
+	chunks, err := clientChunks(fileID, metaStore)
+	if err != nil {
+		return nil, err
+	}
+	fileMeta, _ := metaStore.GetFileMetadataByID(fileID)
+
+	manifest := &metadata.ClientManifest{
+		FileID:   fileID,
+		FileName: fileMeta.FileName,
+		FileSize: fileMeta.FileSize,
+		Chunks:   make([]metadata.ClientChunk, 0, len(chunks)),
+	}
+	for _, chunk := range chunks {
+		manifest.Chunks = append(manifest.Chunks, metadata.ClientChunk{Index: chunk.Index, Size: chunk.Size, Hash: chunk.Path})
+	}
+	return manifest, nil
+}
+
+// ReadClientChunk returns one ciphertext chunk of a client-encrypted file,
+// verified against its stored hash
+func ReadClientChunk(fileID string, index int, metaStore *metadata.MetadataStore, store storage.Storage) ([]byte, error) {
+	chunks, err := clientChunks(fileID, metaStore)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(chunks) {
+		return nil, fmt.Errorf("chunk %d of %s does not exist", index, fileID)
+	}
+
+	reader, err := store.Get(chunks[index].Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %d: %v", index, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %d: %v", index, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != chunks[index].Path {
+		return nil, fmt.Errorf("chunk %d of %s is corrupt", index, fileID)
+	}
+	return data, nil
+}
+
+// clientChunks returns the ordered chunk records of a client-encrypted file
+func clientChunks(fileID string, metaStore *metadata.MetadataStore) ([]metadata.ChunkMetadata, error) {
+	if metaStore == nil {
+		return nil, fmt.Errorf("metadata store is required for client-encrypted files")
+	}
+	fileMeta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata for %s: %v", fileID, err)
+	}
+	if !fileMeta.IsClientEncrypted() {
+		return nil, fmt.Errorf("file %s is not client-encrypted", fileID)
+	}
+
+	chunks, err := metaStore.GetChunksByFileID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks for %s: %v", fileID, err)
+	}
+	if err := metadata.ValidateChunkChain(chunks); err != nil {
+		return nil, fmt.Errorf("chunk chain validation failed: %v", err)
+	}
+	sortChunksByOffset(chunks)
+	return chunks, nil
+}
@@ -0,0 +1,67 @@
+package chunker
+
+import (
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/compressor"
+)
+
+// compressionIDs maps compression algorithms to the IDs chunk headers record
+var compressionIDs = map[string]uint8{
+	compressor.AlgoNone: CompressionNone,
+	compressor.AlgoLZ4:  CompressionLZ4,
+	compressor.AlgoGzip: CompressionGzip,
+	compressor.AlgoZstd: CompressionZstd,
+}
+
+// CompressionName returns the compression algorithm a chunk header ID stands for
+func CompressionName(id uint8) (string, error) {
+	for algo, known := range compressionIDs {
+		if known == id {
+			return algo, nil
+		}
+	}
+	return "", fmt.Errorf("unknown chunk compression: %d", id)
+}
+
+// configuredCompression returns the algorithm new chunks are compressed with
+func configuredCompression() (string, error) {
+	if config.Config == nil {
+		return compressor.AlgoLZ4, nil
+	}
+	return compressor.ParseAlgo(config.Config.CompressionAlgo)
+}
+
+// compressChunk compresses a chunk's data with algo and returns it along with
+// the header ID to record. Data that compression would not shrink is
+// returned as it is, recorded as uncompressed.
+func compressChunk(algo string, data []byte) ([]byte, uint8, error) {
+	if algo == compressor.AlgoNone {
+		return data, CompressionNone, nil
+	}
+	compressed, err := compressor.Compress(algo, data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("compression failed: %v", err)
+	}
+	if len(compressed) >= len(data) {
+		return data, CompressionNone, nil
+	}
+	return compressed, compressionIDs[algo], nil
+}
+
+// DecompressChunk reverses the compression a chunk header records. A chunk
+// without a header was compressed with LZ4 when its metadata says it was.
+func DecompressChunk(header *ChunkHeader, legacyCompressed bool, data []byte) ([]byte, error) {
+	algo := compressor.AlgoNone
+	if header != nil {
+		name, err := CompressionName(header.CompressionAlgo)
+		if err != nil {
+			return nil, err
+		}
+		algo = name
+	} else if legacyCompressed {
+		algo = compressor.AlgoLZ4
+	}
+	return compressor.Decompress(algo, data)
+}
@@ -0,0 +1,112 @@
+package chunker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/compressor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// storedCompression returns the compression a stored chunk's header records
+func storedCompression(t *testing.T, store storage.Storage, path string) uint8 {
+	t.Helper()
+	reader, err := store.Get(path)
+	if err != nil {
+		t.Fatalf("failed to read chunk %s: %v", path, err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	header, _, err := ParseChunkHeader(data)
+	if err != nil {
+		t.Fatalf("failed to parse header of %s: %v", path, err)
+	}
+	return header.CompressionAlgo
+}
+
+func TestChunkCompressionRoundTrips(t *testing.T) {
+	var text bytes.Buffer
+	for i := 0; text.Len() < 3*int(MinChunkSize); i++ {
+		fmt.Fprintf(&text, "line %d of a very compressible text file\n", i)
+	}
+	random := make([]byte, 3*MinChunkSize)
+	rand.New(rand.NewSource(21)).Read(random)
+
+	for _, algo := range []string{compressor.AlgoNone, compressor.AlgoGzip, compressor.AlgoZstd, compressor.AlgoLZ4} {
+		t.Run(algo, func(t *testing.T) {
+			config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize, CompressionAlgo: algo}
+			t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+			tempDir := t.TempDir()
+
+			store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+			if err != nil {
+				t.Fatalf("failed to create storage: %v", err)
+			}
+			metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+			if err != nil {
+				t.Fatalf("failed to open metadata store: %v", err)
+			}
+			defer metaStore.Close()
+
+			for name, content := range map[string][]byte{"text.txt": text.Bytes()[:3*MinChunkSize], "random.bin": random} {
+				input := filepath.Join(tempDir, name)
+				os.WriteFile(input, content, 0644)
+				chunks, err := ChunkAndStore(input, "pw", metaStore, store)
+				if err != nil {
+					t.Fatalf("ChunkAndStore of %s failed: %v", name, err)
+				}
+
+				// Text shrinks unless compression is off; random data never does
+				wantCompressed := name == "text.txt" && algo != compressor.AlgoNone
+				recorded, _ := metaStore.GetChunksByFileID(chunks[0].FileID)
+				for _, chunk := range recorded {
+					if chunk.IsCompressed != wantCompressed {
+						t.Errorf("%s chunk %d: compressed %v, expected %v", name, chunk.Index, chunk.IsCompressed, wantCompressed)
+					}
+					id := storedCompression(t, store, chunk.Path)
+					if !wantCompressed {
+						if id != CompressionNone || chunk.CompressionAlgo != "" {
+							t.Errorf("%s chunk %d: stored with compression %d (%q), expected none", name, chunk.Index, id, chunk.CompressionAlgo)
+						}
+						continue
+					}
+					if id != compressionIDs[algo] || chunk.CompressionAlgo != algo {
+						t.Errorf("%s chunk %d: stored with compression %d (%q), expected %s", name, chunk.Index, id, chunk.CompressionAlgo, algo)
+					}
+					if chunk.CompressionRatio <= 1 || chunk.CompressedSize <= 0 || chunk.CompressedSize >= chunk.Size {
+						t.Errorf("%s chunk %d: ratio %.2f and compressed size %d of %d stored", name, chunk.Index,
+							chunk.CompressionRatio, chunk.CompressedSize, chunk.Size)
+					}
+				}
+
+				output := filepath.Join(tempDir, name+".out")
+				if err := ReassembleFile(chunks[0].FileID, output, "pw", metaStore, store); err != nil {
+					t.Fatalf("ReassembleFile of %s failed: %v", name, err)
+				}
+				if got, _ := os.ReadFile(output); !bytes.Equal(got, content) {
+					t.Errorf("%s reassembled differently", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressionAlgoIsValidated(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, CompressionAlgo: "brotli"}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+	tempDir := t.TempDir()
+
+	store, _ := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	input := filepath.Join(tempDir, "input.txt")
+	os.WriteFile(input, []byte("some content"), 0644)
+	if _, err := ChunkAndStore(input, "pw", nil, store); err == nil {
+		t.Fatal("expected an unknown compression algorithm to be refused")
+	}
+}
@@ -0,0 +1,100 @@
+package chunker
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestChunkHashAlgorithmsRoundTrip(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	upload := func(algo string, seed int64) ([]byte, []ChunkMetadata) {
+		config.Config.HashAlgo = algo
+		original := make([]byte, 3*MinChunkSize+17)
+		rand.New(rand.NewSource(seed)).Read(original)
+		input := filepath.Join(tempDir, algo+".bin")
+		os.WriteFile(input, original, 0644)
+		chunks, err := ChunkAndStore(input, "pw", metaStore, local)
+		if err != nil {
+			t.Fatalf("ChunkAndStore with %s failed: %v", algo, err)
+		}
+		return original, chunks
+	}
+	reassemble := func(fileID string) []byte {
+		output := filepath.Join(tempDir, fileID+".out")
+		if err := ReassembleFile(fileID, output, "pw", metaStore, local); err != nil {
+			t.Fatalf("ReassembleFile failed: %v", err)
+		}
+		data, _ := os.ReadFile(output)
+		return data
+	}
+
+	shaOriginal, shaChunks := upload(HashSHA256, 1)
+	blakeOriginal, blakeChunks := upload(HashBLAKE3, 2)
+	for algo, upload := range map[string]struct {
+		original []byte
+		chunks   []ChunkMetadata
+	}{HashSHA256: {shaOriginal, shaChunks}, HashBLAKE3: {blakeOriginal, blakeChunks}} {
+		fileMeta, _ := metaStore.GetFileMetadataByID(upload.chunks[0].FileID)
+		if fileMeta.HashAlgo != algo {
+			t.Errorf("expected file metadata to record %s, got %q", algo, fileMeta.HashAlgo)
+		}
+		for _, chunk := range upload.chunks {
+			want, _ := ChunkHash(algo, upload.original[chunk.Offset:min(chunk.Offset+MinChunkSize, int64(len(upload.original)))])
+			if chunk.HashAlgo != algo || chunk.Hash != want {
+				t.Errorf("expected chunk %d hashed with %s, got %q (%s)", chunk.Index, algo, chunk.HashAlgo, chunk.Hash)
+			}
+		}
+	}
+
+	// Each file reassembles under the algorithm it was chunked with, whatever is configured now
+	if got := reassemble(shaChunks[0].FileID); !bytes.Equal(got, shaOriginal) {
+		t.Errorf("SHA-256 file did not reassemble byte for byte")
+	}
+	config.Config.HashAlgo = HashSHA256
+	if got := reassemble(blakeChunks[0].FileID); !bytes.Equal(got, blakeOriginal) {
+		t.Errorf("BLAKE3 file did not reassemble byte for byte")
+	}
+
+	// Records from before the field existed are SHA-256
+	for _, chunk := range shaChunks {
+		chunk.HashAlgo = ""
+		metaStore.PutChunkMetadata(toMetadataChunk(chunk))
+	}
+	if got := reassemble(shaChunks[0].FileID); !bytes.Equal(got, shaOriginal) {
+		t.Errorf("chunks without a recorded algorithm did not reassemble as SHA-256")
+	}
+
+	// A BLAKE3 chunk checked as SHA-256 fails its hash
+	for _, chunk := range blakeChunks {
+		chunk.HashAlgo = ""
+		metaStore.PutChunkMetadata(toMetadataChunk(chunk))
+	}
+	if err := ReassembleFile(blakeChunks[0].FileID, filepath.Join(tempDir, "wrong.out"), "pw", metaStore, local); err == nil {
+		t.Errorf("expected BLAKE3 chunks to fail verification as SHA-256")
+	}
+
+	config.Config.HashAlgo = "md5"
+	if _, err := ChunkAndStore(filepath.Join(tempDir, HashSHA256+".bin"), "pw", metaStore, local); err == nil {
+		t.Errorf("expected an unsupported hash algorithm to be refused")
+	}
+}
@@ -0,0 +1,167 @@
+package chunker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ChunkMagic identifies a stored chunk that starts with a ChunkHeader.
+var ChunkMagic = [4]byte{'D', 'B', 'C', 'K'}
+
+// ChunkFormatVersion is the current on-disk chunk header version.
+const ChunkFormatVersion uint8 = 1
+
+// Compression algorithm identifiers recorded in the chunk header.
+const (
+	CompressionNone uint8 = 0
+	CompressionLZ4  uint8 = 1
+	CompressionGzip uint8 = 2
+	CompressionZstd uint8 = 3
+)
+
+// Encryption algorithm identifiers recorded in the chunk header.
+const (
+	EncryptionNone                uint8 = 0
+	EncryptionChaCha20Poly1305    uint8 = 1 // scrypt-derived key, salt+nonce prefixed payload
+	EncryptionChaCha20Poly1305Key uint8 = 2 // server-managed data key, nonce prefixed payload
+)
+
+// fixedHeaderSize covers magic, version, header length, algorithms, index and original size.
+const fixedHeaderSize = 4 + 1 + 2 + 1 + 1 + 4 + 8
+
+// ErrNoChunkHeader is returned when a chunk does not start with a recognised header.
+var ErrNoChunkHeader = errors.New("chunk has no header")
+
+// ChunkHeader is the self-describing prefix written in front of every stored chunk.
+// It lets a chunk be interpreted without the metadata DB (recovery, verification).
+//
+// Layout (big-endian):
+//
+//	magic[4] | version u8 | headerLen u16 | compression u8 | encryption u8 |
+//	index u32 | originalSize u64 | fileIDLen u16 | fileID | nonceLen u8 | nonce
+type ChunkHeader struct {
+	Version         uint8
+	FileID          string
+	Index           int
+	OriginalSize    int64 // Size of the plaintext chunk before compression/encryption
+	CompressionAlgo uint8
+	EncryptionAlgo  uint8
+	Nonce           []byte
+}
+
+// Encode serialises the header into its binary form.
+func (h *ChunkHeader) Encode() ([]byte, error) {
+	if len(h.FileID) > 0xFFFF {
+		return nil, fmt.Errorf("file ID too long: %d bytes", len(h.FileID))
+	}
+	if len(h.Nonce) > 0xFF {
+		return nil, fmt.Errorf("nonce too long: %d bytes", len(h.Nonce))
+	}
+	if h.Index < 0 || h.Index > 0xFFFFFFFF {
+		return nil, fmt.Errorf("chunk index out of range: %d", h.Index)
+	}
+
+	headerLen := fixedHeaderSize + 2 + len(h.FileID) + 1 + len(h.Nonce)
+	if headerLen > 0xFFFF {
+		return nil, fmt.Errorf("header too large: %d bytes", headerLen)
+	}
+
+	version := h.Version
+	if version == 0 {
+		version = ChunkFormatVersion
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, headerLen))
+	buf.Write(ChunkMagic[:])
+	buf.WriteByte(version)
+	binary.Write(buf, binary.BigEndian, uint16(headerLen))
+	buf.WriteByte(h.CompressionAlgo)
+	buf.WriteByte(h.EncryptionAlgo)
+	binary.Write(buf, binary.BigEndian, uint32(h.Index))
+	binary.Write(buf, binary.BigEndian, uint64(h.OriginalSize))
+	binary.Write(buf, binary.BigEndian, uint16(len(h.FileID)))
+	buf.WriteString(h.FileID)
+	buf.WriteByte(uint8(len(h.Nonce)))
+	buf.Write(h.Nonce)
+
+	return buf.Bytes(), nil
+}
+
+// HasChunkHeader reports whether data starts with the chunk header magic.
+func HasChunkHeader(data []byte) bool {
+	return len(data) >= len(ChunkMagic) && bytes.Equal(data[:len(ChunkMagic)], ChunkMagic[:])
+}
+
+// ParseChunkHeader decodes the header at the start of data and returns it along with
+// the remaining payload. ErrNoChunkHeader is returned for legacy headerless chunks.
+func ParseChunkHeader(data []byte) (*ChunkHeader, []byte, error) {
+	if !HasChunkHeader(data) {
+		return nil, data, ErrNoChunkHeader
+	}
+	if len(data) < fixedHeaderSize {
+		return nil, nil, fmt.Errorf("chunk header truncated: %d bytes", len(data))
+	}
+
+	version := data[4]
+	if version == 0 || version > ChunkFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported chunk format version: %d", version)
+	}
+
+	headerLen := int(binary.BigEndian.Uint16(data[5:7]))
+	if headerLen < fixedHeaderSize+3 || headerLen > len(data) {
+		return nil, nil, fmt.Errorf("invalid chunk header length: %d", headerLen)
+	}
+
+	h := &ChunkHeader{
+		Version:         version,
+		CompressionAlgo: data[7],
+		EncryptionAlgo:  data[8],
+		Index:           int(binary.BigEndian.Uint32(data[9:13])),
+		OriginalSize:    int64(binary.BigEndian.Uint64(data[13:21])),
+	}
+
+	pos := fixedHeaderSize
+	fileIDLen := int(binary.BigEndian.Uint16(data[pos : pos+2]))
+	pos += 2
+	if pos+fileIDLen+1 > headerLen {
+		return nil, nil, fmt.Errorf("chunk header file ID overflows header")
+	}
+	h.FileID = string(data[pos : pos+fileIDLen])
+	pos += fileIDLen
+
+	nonceLen := int(data[pos])
+	pos++
+	if pos+nonceLen > headerLen {
+		return nil, nil, fmt.Errorf("chunk header nonce overflows header")
+	}
+	if nonceLen > 0 {
+		h.Nonce = append([]byte(nil), data[pos:pos+nonceLen]...)
+	}
+
+	// Newer minor additions may extend the header; headerLen lets us skip them
+	return h, data[headerLen:], nil
+}
+
+// SplitChunk returns the header (nil for legacy chunks) and the encrypted payload.
+func SplitChunk(data []byte) (*ChunkHeader, []byte, error) {
+	header, payload, err := ParseChunkHeader(data)
+	if errors.Is(err, ErrNoChunkHeader) {
+		return nil, data, nil
+	}
+	return header, payload, err
+}
+
+// StoredFormat returns the at-rest format version of stored chunk bytes,
+// 0 for legacy chunks written without a header.
+func StoredFormat(data []byte) (uint8, error) {
+	header, _, err := SplitChunk(data)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		return 0, nil
+	}
+	return header.Version, nil
+}
@@ -0,0 +1,147 @@
+package chunker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestChunkHeaderRoundTrip(t *testing.T) {
+	header := ChunkHeader{
+		FileID:          "abc123",
+		Index:           7,
+		OriginalSize:    4096,
+		CompressionAlgo: CompressionLZ4,
+		EncryptionAlgo:  EncryptionChaCha20Poly1305,
+		Nonce:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+	}
+	encoded, err := header.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode header: %v", err)
+	}
+
+	payload := []byte("encrypted-payload")
+	got, rest, err := ParseChunkHeader(append(encoded, payload...))
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+	if got.Version != ChunkFormatVersion || got.FileID != header.FileID || got.Index != header.Index ||
+		got.OriginalSize != header.OriginalSize || got.CompressionAlgo != header.CompressionAlgo ||
+		got.EncryptionAlgo != header.EncryptionAlgo || !bytes.Equal(got.Nonce, header.Nonce) {
+		t.Errorf("parsed header does not match: %+v", got)
+	}
+	if !bytes.Equal(rest, payload) {
+		t.Errorf("payload mismatch: got %q", rest)
+	}
+}
+
+func TestParseChunkHeaderLegacyAndMalformed(t *testing.T) {
+	legacy := []byte("0123456789abcdef-salt-and-nonce-then-ciphertext")
+	header, payload, err := SplitChunk(legacy)
+	if err != nil || header != nil || !bytes.Equal(payload, legacy) {
+		t.Errorf("legacy chunk should pass through untouched, got header=%v err=%v", header, err)
+	}
+
+	encoded, _ := (&ChunkHeader{FileID: "f", Index: 1}).Encode()
+	if _, _, err := ParseChunkHeader(encoded[:10]); err == nil {
+		t.Errorf("expected error for truncated header")
+	}
+
+	future := append([]byte(nil), encoded...)
+	future[4] = ChunkFormatVersion + 1
+	if _, _, err := ParseChunkHeader(future); err == nil {
+		t.Errorf("expected error for unsupported version")
+	}
+}
+
+func TestReassembleHeaderedAndLegacyChunks(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2}
+	tempDir := t.TempDir()
+	password := "test-password"
+
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	// New format: chunks written by ChunkAndStore carry a header
+	content := bytes.Repeat([]byte("disktrobyte header test "), 100)
+	inputPath := filepath.Join(tempDir, "input.txt")
+	if err := os.WriteFile(inputPath, content, 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	chunks, err := ChunkAndStore(inputPath, password, metaStore, store)
+	if err != nil {
+		t.Fatalf("ChunkAndStore failed: %v", err)
+	}
+
+	reader, err := store.Get(chunks[0].Path)
+	if err != nil {
+		t.Fatalf("failed to read stored chunk: %v", err)
+	}
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	header, _, err := ParseChunkHeader(stored)
+	if err != nil {
+		t.Fatalf("stored chunk has no valid header: %v", err)
+	}
+	if header.FileID != chunks[0].FileID || header.OriginalSize != int64(len(content)) || len(header.Nonce) == 0 {
+		t.Errorf("unexpected stored header: %+v", header)
+	}
+
+	outputPath := filepath.Join(tempDir, "output.txt")
+	if err := ReassembleFile(chunks[0].FileID, outputPath, password, metaStore, store); err != nil {
+		t.Fatalf("reassembly of headered chunks failed: %v", err)
+	}
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, content) {
+		t.Errorf("reassembled content mismatch")
+	}
+
+	// Old format: a headerless encrypted chunk recorded only in the DB
+	legacyContent := []byte("legacy chunk without header")
+	encrypted, err := encryptor.NewEncryptor().Encrypt(legacyContent, password)
+	if err != nil {
+		t.Fatalf("failed to encrypt legacy chunk: %v", err)
+	}
+	legacyPath, err := store.Put(bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatalf("failed to store legacy chunk: %v", err)
+	}
+	sum := sha256.Sum256(legacyContent)
+	legacyID := "legacy-file-id"
+	err = metaStore.PutChunkMetadata(metadata.ChunkMetadata{
+		Index:       0,
+		Hash:        hex.EncodeToString(sum[:]),
+		Path:        legacyPath,
+		Size:        int64(len(encrypted)),
+		PrevIndex:   -1,
+		NextIndex:   -1,
+		TotalChunks: 1,
+		FileID:      legacyID,
+	})
+	if err != nil {
+		t.Fatalf("failed to store legacy chunk metadata: %v", err)
+	}
+
+	legacyOutput := filepath.Join(tempDir, "legacy.txt")
+	if err := ReassembleFile(legacyID, legacyOutput, password, metaStore, store); err != nil {
+		t.Fatalf("reassembly of legacy chunk failed: %v", err)
+	}
+	if got, _ := os.ReadFile(legacyOutput); !bytes.Equal(got, legacyContent) {
+		t.Errorf("legacy reassembled content mismatch")
+	}
+}
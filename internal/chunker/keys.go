@@ -0,0 +1,105 @@
+package chunker
+
+import (
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// chunkCipher encrypts chunks either with a password or with a per-file data key
+type chunkCipher struct {
+	password string
+	dataKey  []byte
+	enc      encryptor.Encryptor
+}
+
+func passwordCipher(password string) *chunkCipher {
+	return &chunkCipher{password: password, enc: encryptor.NewEncryptor()}
+}
+
+func dataKeyCipher(dataKey []byte) *chunkCipher {
+	return &chunkCipher{dataKey: dataKey}
+}
+
+// algo returns the header encryption identifier for this cipher
+func (c *chunkCipher) algo() uint8 {
+	if c.dataKey != nil {
+		return EncryptionChaCha20Poly1305Key
+	}
+	return EncryptionChaCha20Poly1305
+}
+
+// seal encrypts data and returns the ciphertext plus the nonce used
+func (c *chunkCipher) seal(data []byte) ([]byte, []byte, error) {
+	if c.dataKey != nil {
+		encrypted, err := encryptor.EncryptWithKey(data, c.dataKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return encrypted, encryptor.NonceFromKeyCiphertext(encrypted), nil
+	}
+
+	encrypted, err := c.enc.Encrypt(data, c.password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encrypted, encryptor.NonceFromCiphertext(encrypted), nil
+}
+
+// open decrypts a chunk payload, checking the header algorithm when one is present
+func (c *chunkCipher) open(payload []byte, header *ChunkHeader) ([]byte, error) {
+	if header != nil && header.EncryptionAlgo != c.algo() {
+		return nil, fmt.Errorf("chunk encrypted with algorithm %d, expected %d", header.EncryptionAlgo, c.algo())
+	}
+	if c.dataKey != nil {
+		return encryptor.DecryptWithKey(payload, c.dataKey)
+	}
+	return c.enc.Decrypt(payload, c.password)
+}
+
+// FileKeyMode returns the key mode recorded for a file, defaulting to password mode.
+func FileKeyMode(metaStore *metadata.MetadataStore, fileID string) string {
+	if metaStore == nil {
+		return metadata.KeyModePassword
+	}
+	fileMeta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil || fileMeta.KeyMode == "" {
+		return metadata.KeyModePassword
+	}
+	return fileMeta.KeyMode
+}
+
+// UnwrapFileKey returns the data key of a server-managed file, for readers
+// that decrypt its chunks themselves
+func UnwrapFileKey(fileID string, km *encryptor.KeyManager, metaStore *metadata.MetadataStore) ([]byte, error) {
+	return unwrapFileKey(fileID, km, metaStore)
+}
+
+// unwrapFileKey loads and unwraps the data key for a server-managed file
+func unwrapFileKey(fileID string, km *encryptor.KeyManager, metaStore *metadata.MetadataStore) ([]byte, error) {
+	if km == nil {
+		return nil, fmt.Errorf("server-managed keys are not configured")
+	}
+	if metaStore == nil {
+		return nil, fmt.Errorf("metadata store is required for server-managed keys")
+	}
+
+	fileMeta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata for %s: %v", fileID, err)
+	}
+	return unwrapKey(fileID, fileMeta, km)
+}
+
+// unwrapKey unwraps the data key recorded in one file's (or version's) metadata
+func unwrapKey(fileID string, fileMeta metadata.FileMetadata, km *encryptor.KeyManager) ([]byte, error) {
+	if !fileMeta.IsServerManaged() {
+		return nil, fmt.Errorf("file %s is not encrypted with a server-managed key", fileID)
+	}
+	if fileMeta.KeyID != "" && fileMeta.KeyID != km.KeyID() {
+		return nil, fmt.Errorf("file %s was wrapped with master key %s, current key is %s", fileID, fileMeta.KeyID, km.KeyID())
+	}
+
+	return km.UnwrapKey(fileMeta.WrappedKey)
+}
@@ -0,0 +1,91 @@
+package chunker
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestKeyModesRoundTrip(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2}
+	tempDir := t.TempDir()
+
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	km, err := encryptor.NewKeyManager(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+
+	// Password mode
+	passwordInput := filepath.Join(tempDir, "password.txt")
+	passwordContent := []byte("encrypted with a user password")
+	os.WriteFile(passwordInput, passwordContent, 0644)
+	chunks, err := ChunkAndStore(passwordInput, "secret", metaStore, store)
+	if err != nil {
+		t.Fatalf("ChunkAndStore failed: %v", err)
+	}
+	passwordID := chunks[0].FileID
+	if mode := FileKeyMode(metaStore, passwordID); mode != metadata.KeyModePassword {
+		t.Errorf("expected password key mode, got %q", mode)
+	}
+	out := filepath.Join(tempDir, "password.out")
+	if err := ReassembleFile(passwordID, out, "secret", metaStore, store); err != nil {
+		t.Fatalf("password reassembly failed: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, passwordContent) {
+		t.Errorf("password mode content mismatch")
+	}
+
+	// Server-managed mode
+	managedInput := filepath.Join(tempDir, "managed.txt")
+	managedContent := []byte("encrypted with a server-managed data key")
+	os.WriteFile(managedInput, managedContent, 0644)
+	chunks, err = ChunkAndStoreWithServerKey(managedInput, km, metaStore, store)
+	if err != nil {
+		t.Fatalf("ChunkAndStoreWithServerKey failed: %v", err)
+	}
+	managedID := chunks[0].FileID
+
+	fileMeta, err := metaStore.GetFileMetadataByID(managedID)
+	if err != nil {
+		t.Fatalf("failed to load file metadata: %v", err)
+	}
+	if !fileMeta.IsServerManaged() || fileMeta.KeyID != km.KeyID() || len(fileMeta.WrappedKey) == 0 {
+		t.Errorf("server-managed metadata not recorded: %+v", fileMeta)
+	}
+
+	out = filepath.Join(tempDir, "managed.out")
+	if err := ReassembleFileWithServerKey(managedID, out, km, metaStore, store); err != nil {
+		t.Fatalf("server-managed reassembly failed: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, managedContent) {
+		t.Errorf("server-managed content mismatch")
+	}
+
+	// Modes must not be mixed up
+	if err := ReassembleFile(managedID, out, "secret", metaStore, store); err == nil {
+		t.Errorf("expected password reassembly of server-managed file to fail")
+	}
+	if err := ReassembleFileWithServerKey(passwordID, out, km, metaStore, store); err == nil {
+		t.Errorf("expected server-key reassembly of password file to fail")
+	}
+	otherKM, _ := encryptor.NewKeyManager(bytes.Repeat([]byte{9}, 32))
+	if err := ReassembleFileWithServerKey(managedID, out, otherKM, metaStore, store); err == nil {
+		t.Errorf("expected reassembly with a different master key to fail")
+	}
+}
@@ -0,0 +1,75 @@
+package chunker
+
+import (
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+const (
+	// DefaultMaxChunkCount caps the number of chunks a single file may produce
+	DefaultMaxChunkCount = 100000
+	// MinChunkSize is the smallest explicit chunk size accepted
+	MinChunkSize int64 = 4 * 1024
+	// MaxChunkSize is the largest chunk size auto-adjustment may pick
+	MaxChunkSize int64 = 64 * 1024 * 1024
+)
+
+// maxChunkCount returns the configured chunk count limit
+func maxChunkCount() int {
+	if config.Config != nil && config.Config.MaxChunkCount > 0 {
+		return config.Config.MaxChunkCount
+	}
+	return DefaultMaxChunkCount
+}
+
+// configuredChunkSize returns the chunk size from config, 0 meaning auto
+func configuredChunkSize() int64 {
+	if config.Config != nil {
+		return config.Config.ChunkSize
+	}
+	return 0
+}
+
+// estimateChunkCount returns how many chunks fileSize splits into at chunkSize
+func estimateChunkCount(fileSize, chunkSize int64) int64 {
+	if fileSize <= 0 {
+		return 0
+	}
+	return (fileSize + chunkSize - 1) / chunkSize
+}
+
+// resolveChunkSize validates the requested chunk size (0 = auto) against the
+// chunk count limit. Auto sizes grow until the limit is met; explicit sizes
+// that would exceed it are rejected.
+func resolveChunkSize(fileSize, requested int64) (int64, error) {
+	limit := int64(maxChunkCount())
+
+	if requested > 0 {
+		if requested < MinChunkSize {
+			return 0, fmt.Errorf("chunk size %d is below the minimum of %d bytes", requested, MinChunkSize)
+		}
+		if count := estimateChunkCount(fileSize, requested); count > limit {
+			return 0, fmt.Errorf("chunk size %d would split %d bytes into %d chunks, exceeding the limit of %d",
+				requested, fileSize, count, limit)
+		}
+		return requested, nil
+	}
+
+	chunkSize := determineChunkSize(fileSize)
+	if estimateChunkCount(fileSize, chunkSize) <= limit {
+		return chunkSize, nil
+	}
+
+	// Round the adjusted size up to a whole MiB so offsets stay tidy
+	const mib = 1024 * 1024
+	adjusted := (fileSize + limit - 1) / limit
+	adjusted = ((adjusted + mib - 1) / mib) * mib
+	if adjusted > MaxChunkSize {
+		return 0, fmt.Errorf("file of %d bytes needs chunks larger than %d bytes to stay within %d chunks",
+			fileSize, MaxChunkSize, limit)
+	}
+
+	fmt.Printf("⚠️ Auto chunk size raised from %d to %d bytes to stay within %d chunks\n", chunkSize, adjusted, limit)
+	return adjusted, nil
+}
@@ -0,0 +1,70 @@
+package chunker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestResolveChunkSizeExtremes(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, MaxChunkCount: 1000}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	const tib = int64(1) << 40
+
+	// Explicit tiny chunk size on a huge file is rejected
+	if _, err := resolveChunkSize(tib, MinChunkSize); err == nil {
+		t.Errorf("expected rejection of tiny explicit chunk size on a huge file")
+	}
+
+	// Sizes below the minimum are rejected outright
+	if _, err := resolveChunkSize(1024, 1); err == nil {
+		t.Errorf("expected rejection of chunk size below minimum")
+	}
+
+	// Auto mode grows the chunk size to stay within the limit
+	size, err := resolveChunkSize(10*1024*1024*1024, 0)
+	if err != nil {
+		t.Fatalf("expected auto-adjustment, got error: %v", err)
+	}
+	if count := estimateChunkCount(10*1024*1024*1024, size); count > 1000 {
+		t.Errorf("auto-adjusted size %d still produces %d chunks", size, count)
+	}
+
+	// Auto mode refuses when even the largest chunk size would exceed the limit
+	if _, err := resolveChunkSize(tib, 0); err == nil {
+		t.Errorf("expected rejection when auto-adjustment exceeds max chunk size")
+	}
+
+	// Normal files keep the default size
+	if size, err := resolveChunkSize(1024, 0); err != nil || size != determineChunkSize(1024) {
+		t.Errorf("expected default chunk size, got %d (%v)", size, err)
+	}
+}
+
+func TestChunkAndStoreRejectsBeforeWriting(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize, MaxChunkCount: 2}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	tempDir := t.TempDir()
+	inputPath := filepath.Join(tempDir, "input.bin")
+	if err := os.WriteFile(inputPath, make([]byte, 5*MinChunkSize), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	chunkDir := filepath.Join(tempDir, "chunks")
+	store, err := storage.NewLocalStorage(chunkDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	if _, err := ChunkAndStore(inputPath, "pw", nil, store); err == nil {
+		t.Fatalf("expected ChunkAndStore to reject 5 chunks with a limit of 2")
+	}
+	if entries, _ := os.ReadDir(chunkDir); len(entries) != 0 {
+		t.Errorf("expected no chunks written, found %d", len(entries))
+	}
+}
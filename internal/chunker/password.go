@@ -0,0 +1,79 @@
+package chunker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// ErrWrongPassword is returned by CheckPassword when the password does not
+// decrypt the file. It carries no detail about why decryption failed.
+var ErrWrongPassword = errors.New("incorrect password")
+
+// CheckPassword confirms a password decrypts a file by opening its key canary,
+// or only its first chunk for files stored without one, so callers can reject
+// a wrong password before a long reassembly.
+// Files with a server-managed key need no password and always pass.
+// Errors other than ErrWrongPassword mean the check could not be made, e.g.
+// the first chunk is not stored here.
+func CheckPassword(fileID, password string, metaStore *metadata.MetadataStore, store storage.Storage) error {
+	if metaStore == nil {
+		return fmt.Errorf("metadata store is required to check a password")
+	}
+
+	contentID := fileID
+	if fileMeta, err := metaStore.GetFileMetadataByID(fileID); err == nil {
+		if !fileMeta.Available(time.Now()) {
+			return fmt.Errorf("%w: %s", metadata.ErrFileExpired, fileID)
+		}
+		if fileMeta.IsClientEncrypted() {
+			return fmt.Errorf("%w: %s", ErrClientEncrypted, fileID)
+		}
+		if fileMeta.IsServerManaged() {
+			return nil
+		}
+		contentID = fileMeta.CurrentContentID(fileID)
+		if checked, err := checkCanary(fileMeta, passwordCipher(password), contentID); checked {
+			return err
+		}
+	}
+
+	chunks, err := metaStore.GetChunksByFileID(contentID)
+	if err != nil {
+		return fmt.Errorf("failed to get chunks for FileID %s: %v", fileID, err)
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("file %s has no chunks", fileID)
+	}
+	sortChunksByOffset(chunks)
+	first := chunks[0]
+
+	// The first chunk of erasure-coded content may have to be rebuilt from parity
+	reader, err := withParity(store, metaStore, contentID, chunks).Get(first.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read chunk %s: %v", first.Path, err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read chunk data %s: %v", first.Path, err)
+	}
+	header, payload, err := SplitChunk(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse header: %v", err)
+	}
+	sealedAs, sealedIndex := first.SealedAs(contentID)
+	if header != nil && (header.FileID != sealedAs || header.Index != sealedIndex) {
+		return fmt.Errorf("header mismatch: belongs to file %s index %d", header.FileID, header.Index)
+	}
+
+	// Authenticated decryption fails for any wrong key; the reason is not passed on
+	if _, err := passwordCipher(password).open(payload, header); err != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// countingStore counts chunk reads and writes
+type countingStore struct {
+	storage.Storage
+	gets atomic.Int32
+	puts atomic.Int32
+}
+
+func (s *countingStore) Get(id string) (io.ReadCloser, error) {
+	s.gets.Add(1)
+	return s.Storage.Get(id)
+}
+
+func (s *countingStore) Put(chunkData io.Reader) (string, error) {
+	s.puts.Add(1)
+	return s.Storage.Put(chunkData)
+}
+
+func TestCheckPasswordReadsOnlyTheFirstChunk(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	input := filepath.Join(tempDir, "large.bin")
+	os.WriteFile(input, bytes.Repeat([]byte("password protected "), int(MinChunkSize)), 0644)
+	chunks, err := ChunkAndStore(input, "right password", metaStore, local)
+	if err != nil {
+		t.Fatalf("ChunkAndStore failed: %v", err)
+	}
+	if len(chunks) < 4 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	fileID := chunks[0].FileID
+	store := &countingStore{Storage: local}
+
+	if err := CheckPassword(fileID, "right password", metaStore, store); err != nil {
+		t.Errorf("correct password rejected: %v", err)
+	}
+	err = CheckPassword(fileID, "wrong password", metaStore, store)
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+	if err != nil && err.Error() != ErrWrongPassword.Error() {
+		t.Errorf("the error should say nothing beyond the password being wrong: %v", err)
+	}
+	if gets := store.gets.Load(); gets != 2 {
+		t.Errorf("expected one chunk read per check, got %d reads for %d chunks", gets, len(chunks))
+	}
+
+	if err := CheckPassword("unknown-file", "right password", metaStore, store); err == nil || errors.Is(err, ErrWrongPassword) {
+		t.Errorf("an unknown file should not look like a wrong password, got %v", err)
+	}
+}
+
+func TestKeyCanaryChecksKeysWithoutReadingChunks(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize, KeyCanary: true}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	upload := func(name, content string) string {
+		input := filepath.Join(tempDir, name)
+		os.WriteFile(input, bytes.Repeat([]byte(content), int(MinChunkSize)), 0644)
+		chunks, err := ChunkAndStore(input, "right password", metaStore, local)
+		if err != nil {
+			t.Fatalf("ChunkAndStore failed: %v", err)
+		}
+		return chunks[0].FileID
+	}
+	fileID := upload("canary.bin", "canary protected ")
+	otherID := upload("other.bin", "other content ")
+
+	fileMeta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		t.Fatalf("failed to get file metadata: %v", err)
+	}
+	if fileMeta.CanaryVersion != KeyCanaryVersion || fileMeta.CanaryAlgo != EncryptionChaCha20Poly1305 || len(fileMeta.KeyCanary) == 0 {
+		t.Fatalf("expected a version %d canary recorded, got version %d algo %d", KeyCanaryVersion, fileMeta.CanaryVersion, fileMeta.CanaryAlgo)
+	}
+	other, _ := metaStore.GetFileMetadataByID(otherID)
+	if bytes.Equal(fileMeta.KeyCanary, other.KeyCanary) {
+		t.Errorf("expected every canary sealed under its own nonce")
+	}
+
+	// The verdict comes from metadata alone
+	store := &countingStore{Storage: local}
+	if err := CheckPassword(fileID, "right password", metaStore, store); err != nil {
+		t.Errorf("correct password rejected: %v", err)
+	}
+	if err := CheckPassword(fileID, "wrong password", metaStore, store); err != ErrWrongPassword {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+	err = ReassembleFile(fileID, filepath.Join(tempDir, "out.bin"), "wrong password", metaStore, store)
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("expected reassembly to stop at the canary, got %v", err)
+	}
+	if gets := store.gets.Load(); gets != 0 {
+		t.Errorf("expected no chunk reads, got %d", gets)
+	}
+
+	// A canary moved from other content opens under the password but is not accepted
+	fileMeta.KeyCanary = other.KeyCanary
+	if err := metaStore.PutFileMetadataByID(fileID, fileMeta); err != nil {
+		t.Fatalf("failed to store file metadata: %v", err)
+	}
+	if err := CheckPassword(fileID, "right password", metaStore, store); err == nil || errors.Is(err, ErrWrongPassword) {
+		t.Errorf("expected a canary of other content rejected, got %v", err)
+	}
+}
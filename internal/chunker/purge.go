@@ -0,0 +1,263 @@
+package chunker
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// PurgeReport is what purging a file, or releasing chunks, removed
+type PurgeReport struct {
+	ChunksDeleted  int
+	ReclaimedBytes int64
+	Errors         []string // Steps that failed without stopping the purge
+}
+
+// PurgeFile removes a file's metadata, its versions, chunk references and
+// parity, and the chunks only it referenced. The report is returned with
+// what was removed even when an error stops the purge part way.
+func PurgeFile(metaStore *metadata.MetadataStore, store storage.Storage, fileID string, file metadata.FileMetadata) (*PurgeReport, error) {
+	report := &PurgeReport{}
+	versions, err := metaStore.ListVersions(fileID)
+	if err != nil {
+		return report, fmt.Errorf("failed to list versions: %v", err)
+	}
+
+	// Everything the file or its earlier versions may have been reading
+	hashes := make(map[string]bool)
+	paths := make(map[string]bool)
+	for _, hash := range file.ChunkHashes {
+		hashes[hash] = true
+		if chunk, err := metaStore.GetChunkMetadata(hash); err == nil && chunk.Path != "" {
+			paths[chunk.Path] = true
+		}
+	}
+	contents := map[string]bool{file.CurrentContentID(fileID): true}
+	for _, version := range versions {
+		contents[version.ContentID] = true
+		for _, chunk := range version.Chunks {
+			hashes[chunk.Hash] = true
+			if chunk.Path != "" {
+				paths[chunk.Path] = true
+			}
+		}
+	}
+
+	// Chunks shared with other files are reached through the file's reference records
+	for contentID := range contents {
+		refs, err := metaStore.GetChunkReferences(contentID)
+		if err != nil {
+			return report, fmt.Errorf("failed to list chunk references: %v", err)
+		}
+		for _, ref := range refs {
+			hashes[ref.Hash] = true
+			if ref.Path != "" {
+				paths[ref.Path] = true
+			}
+		}
+	}
+
+	// Parity shards of erasure-coded contents go with their chunks
+	for contentID := range contents {
+		parity, err := metaStore.GetParity(contentID)
+		if err != nil {
+			continue
+		}
+		for _, path := range parity.Paths() {
+			paths[path] = true
+		}
+	}
+
+	// Removing the metadata is the point of no return; the file is gone from here on
+	if err := metaStore.DeleteVersions(fileID); err != nil {
+		return report, fmt.Errorf("failed to delete versions: %v", err)
+	}
+	if err := metaStore.DeleteFileMetadataByID(fileID); err != nil {
+		return report, fmt.Errorf("failed to delete file metadata: %v", err)
+	}
+	if byName, err := metaStore.GetFileMetadata(file.FileName); err == nil && byName.CurrentContentID(fileID) == file.CurrentContentID(fileID) {
+		if err := metaStore.DeleteFileMetadata(file.FileName); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to delete name entry of %s: %v", fileID, err))
+		}
+	}
+	for contentID := range contents {
+		// Another file may read a content an earlier version had
+		if _, err := metaStore.GetFileMetadataByID(contentID); err != nil {
+			if err := metaStore.DeleteChunkReferences(contentID); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to delete chunk references of %s: %v", contentID, err))
+			}
+		}
+		if _, err := metaStore.GetParity(contentID); err != nil {
+			continue
+		}
+		if err := metaStore.DeleteParity(contentID); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to delete parity record of %s: %v", contentID, err))
+		}
+	}
+
+	err = releaseChunks(metaStore, store, hashes, paths, report)
+	return report, err
+}
+
+// ReleaseChunks removes the chunk records among hashes, and the stored chunks
+// among paths, that no remaining file, version or chunk reference reads.
+// Identical chunks are shared between files, so a record still in use keeps
+// its bytes and has its reference count corrected instead.
+func ReleaseChunks(metaStore *metadata.MetadataStore, store storage.Storage, hashes, paths map[string]bool) (*PurgeReport, error) {
+	report := &PurgeReport{}
+	err := releaseChunks(metaStore, store, hashes, paths, report)
+	return report, err
+}
+
+func releaseChunks(metaStore *metadata.MetadataStore, store storage.Storage, hashes, paths map[string]bool, report *PurgeReport) error {
+	// Claim the chunks before counting references, so an upload sharing one
+	// either commits first and is counted or waits and stores it again
+	barrier := metaStore.ChunkBarrier()
+	keys := make([]string, 0, len(hashes)+len(paths))
+	for hash := range hashes {
+		keys = append(keys, hash)
+	}
+	for path := range paths {
+		keys = append(keys, path)
+	}
+	claimedKeys := barrier.BeginDelete(keys...)
+	defer barrier.EndDelete(claimedKeys...)
+	claimed := make(map[string]bool, len(claimedKeys))
+	for _, key := range claimedKeys {
+		claimed[key] = true
+	}
+
+	// Identical chunks are shared between files, so only unreferenced records go
+	remaining, err := metaStore.ListFileMetadataByID()
+	if err != nil {
+		return fmt.Errorf("failed to count chunk references: %v", err)
+	}
+	references := make(map[string]int)
+	for _, other := range remaining {
+		for _, hash := range other.ChunkHashes {
+			references[hash]++
+		}
+	}
+	for hash := range hashes {
+		chunk, err := metaStore.GetChunkMetadata(hash)
+		if err != nil {
+			continue
+		}
+		if references[hash] == 0 {
+			if !claimed[hash] {
+				continue // Being recorded by an upload that will reference it
+			}
+			if err := metaStore.DeleteChunkMetadata(hash); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to delete chunk record %s: %v", hash, err))
+			}
+			continue
+		}
+		if chunk.RefCount > 0 && chunk.RefCount != references[hash] {
+			chunk.RefCount = references[hash]
+			metaStore.PutChunkMetadata(chunk)
+		}
+	}
+
+	deleter, ok := store.(storage.Deleter)
+	if !ok {
+		// The chunks stay behind as orphans for a consistency repair on a backend that can delete
+		return nil
+	}
+	referenced, err := ReferencedChunkPaths(metaStore)
+	if err != nil {
+		return err
+	}
+	for path := range paths {
+		if referenced[path] || !claimed[path] {
+			continue
+		}
+		var size int64
+		if local, err := store.GetPath(path); err == nil {
+			if info, err := os.Stat(local); err == nil {
+				size = info.Size()
+			}
+		}
+		if err := deleter.Delete(path); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to delete chunk %s: %v", path, err))
+			continue
+		}
+		report.ChunksDeleted++
+		report.ReclaimedBytes += size
+	}
+	return nil
+}
+
+// ReferencedChunkPaths returns every stored chunk that current files or earlier versions read
+func ReferencedChunkPaths(metaStore *metadata.MetadataStore) (map[string]bool, error) {
+	chunks, err := metaStore.ListChunkMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk metadata: %v", err)
+	}
+	refs, err := metaStore.ListChunkReferences()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk references: %v", err)
+	}
+	versions, err := metaStore.ListAllVersions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file versions: %v", err)
+	}
+
+	referenced := make(map[string]bool, len(chunks))
+	for _, chunk := range append(chunks, refs...) {
+		if chunk.Path != "" {
+			referenced[chunk.Path] = true
+		}
+	}
+	for _, version := range versions {
+		for _, chunk := range version.Chunks {
+			if chunk.Path != "" {
+				referenced[chunk.Path] = true
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// DiscardUpload undoes what chunking fileID stored, for an upload that failed
+// a later check. prior is the record the upload replaced, nil when the file
+// was new: it is put back and only the chunks nothing else reads are removed;
+// a new file is purged outright.
+func DiscardUpload(metaStore *metadata.MetadataStore, store storage.Storage, fileID string, prior *metadata.FileMetadata) (*PurgeReport, error) {
+	if metaStore == nil {
+		return &PurgeReport{}, nil
+	}
+	file, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return &PurgeReport{}, nil // Nothing was recorded
+	}
+	if prior == nil {
+		return PurgeFile(metaStore, store, fileID, file)
+	}
+
+	hashes := make(map[string]bool)
+	paths := make(map[string]bool)
+	for _, hash := range file.ChunkHashes {
+		hashes[hash] = true
+		if chunk, err := metaStore.GetChunkMetadata(hash); err == nil && chunk.Path != "" {
+			paths[chunk.Path] = true
+		}
+	}
+	if err := metaStore.PutFileMetadataByID(fileID, *prior); err != nil {
+		return &PurgeReport{}, fmt.Errorf("failed to restore file metadata: %v", err)
+	}
+	if err := metaStore.PutFileMetadata(*prior); err != nil {
+		return &PurgeReport{}, fmt.Errorf("failed to restore file name entry: %v", err)
+	}
+	report := &PurgeReport{}
+	if file.FileName != prior.FileName {
+		if byName, err := metaStore.GetFileMetadata(file.FileName); err == nil && byName.CurrentContentID(fileID) == file.CurrentContentID(fileID) {
+			if err := metaStore.DeleteFileMetadata(file.FileName); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to delete name entry of %s: %v", fileID, err))
+			}
+		}
+	}
+	err = releaseChunks(metaStore, store, hashes, paths, report)
+	return report, err
+}
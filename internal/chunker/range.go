@@ -0,0 +1,192 @@
+package chunker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// ReadRangeContext writes length bytes of a file starting at offset to w,
+// fetching and decrypting only the chunks that cover them. A range running
+// past the end of the file is cut short; the bytes written are returned.
+func ReadRangeContext(
+	ctx context.Context,
+	fileID string,
+	offset, length int64,
+	password string,
+	metaStore *metadata.MetadataStore,
+	store storage.Storage,
+	w io.Writer,
+) (int64, error) {
+	if FileKeyMode(metaStore, fileID) == metadata.KeyModeServerManaged {
+		return 0, tracing.Error(ctx, fmt.Errorf("file %s uses a server-managed key; use ReadRangeWithServerKeyContext", fileID))
+	}
+	return readRange(ctx, fileID, offset, length, passwordCipher(password), metaStore, store, w)
+}
+
+// ReadRangeWithServerKeyContext works like ReadRangeContext for files
+// encrypted with a server-managed key
+func ReadRangeWithServerKeyContext(
+	ctx context.Context,
+	fileID string,
+	offset, length int64,
+	km *encryptor.KeyManager,
+	metaStore *metadata.MetadataStore,
+	store storage.Storage,
+	w io.Writer,
+) (int64, error) {
+	dataKey, err := unwrapFileKey(fileID, km, metaStore)
+	if err != nil {
+		return 0, tracing.Error(ctx, err)
+	}
+	return readRange(ctx, fileID, offset, length, dataKeyCipher(dataKey), metaStore, store, w)
+}
+
+func readRange(
+	ctx context.Context,
+	fileID string,
+	offset, length int64,
+	cipher *chunkCipher,
+	metaStore *metadata.MetadataStore,
+	store storage.Storage,
+	w io.Writer,
+) (int64, error) {
+	if offset < 0 || length <= 0 {
+		return 0, fmt.Errorf("invalid range: offset %d, length %d", offset, length)
+	}
+
+	contentID := fileID
+	size := int64(-1)
+	if fileMeta, err := metaStore.GetFileMetadataByID(fileID); err == nil {
+		if !fileMeta.Available(time.Now()) {
+			return 0, tracing.Error(ctx, fmt.Errorf("%w: %s", metadata.ErrFileExpired, fileID))
+		}
+		if fileMeta.IsClientEncrypted() {
+			return 0, tracing.Error(ctx, fmt.Errorf("%w: %s", ErrClientEncrypted, fileID))
+		}
+		contentID = fileMeta.CurrentContentID(fileID)
+		size = fileMeta.FileSize
+	}
+	chunks, err := metaStore.GetChunksByFileID(contentID)
+	if err != nil {
+		return 0, tracing.Error(ctx, fmt.Errorf("failed to get chunks for FileID %s: %v", fileID, err))
+	}
+	return readContent(ctx, fileID, contentID, chunks, size, offset, length, cipher, metaStore, store, w)
+}
+
+// ReadVersionContext writes all of one recorded version of a
+// password-encrypted file to w, as ReassembleVersion would to a file
+func ReadVersionContext(ctx context.Context, fileID string, version int, password string, metaStore *metadata.MetadataStore, store storage.Storage, w io.Writer) (int64, error) {
+	record, err := FileVersion(fileID, version, metaStore)
+	if err != nil {
+		return 0, err
+	}
+	if record.File.IsServerManaged() {
+		return 0, fmt.Errorf("version %d of %s uses a server-managed key; use ReadVersionWithServerKeyContext", version, fileID)
+	}
+	return readVersion(ctx, fileID, record, passwordCipher(password), metaStore, store, w)
+}
+
+// ReadVersionWithServerKeyContext works like ReadVersionContext for versions
+// encrypted with a server-managed key
+func ReadVersionWithServerKeyContext(ctx context.Context, fileID string, version int, km *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage, w io.Writer) (int64, error) {
+	if km == nil {
+		return 0, fmt.Errorf("server-managed keys are not configured")
+	}
+	record, err := FileVersion(fileID, version, metaStore)
+	if err != nil {
+		return 0, err
+	}
+	dataKey, err := unwrapKey(fileID, record.File, km)
+	if err != nil {
+		return 0, err
+	}
+	return readVersion(ctx, fileID, record, dataKeyCipher(dataKey), metaStore, store, w)
+}
+
+func readVersion(ctx context.Context, fileID string, record metadata.VersionRecord, cipher *chunkCipher, metaStore *metadata.MetadataStore, store storage.Storage, w io.Writer) (int64, error) {
+	if _, err := checkCanary(record.File, cipher, record.ContentID); err != nil {
+		return 0, err
+	}
+	if record.File.FileSize == 0 {
+		return 0, nil
+	}
+	return readContent(ctx, fileID, record.ContentID, record.Chunks, record.File.FileSize, 0, record.File.FileSize, cipher, metaStore, store, w)
+}
+
+// readContent writes a range of one stored content to w
+func readContent(
+	ctx context.Context,
+	fileID, contentID string,
+	chunks []metadata.ChunkMetadata,
+	size int64, // Recorded plaintext size, -1 when unknown
+	offset, length int64,
+	cipher *chunkCipher,
+	metaStore *metadata.MetadataStore,
+	store storage.Storage,
+	w io.Writer,
+) (int64, error) {
+	if err := metadata.ValidateChunkChain(chunks); err != nil {
+		return 0, tracing.Error(ctx, fmt.Errorf("chunk chain validation failed: %v", err))
+	}
+	sortChunksByOffset(chunks)
+
+	// A chunk runs from its offset to the next chunk's; the last one to the end of the file
+	end := offset + length
+	first, last := -1, -1
+	for i, chunk := range chunks {
+		if chunk.Offset >= end {
+			break
+		}
+		if i+1 < len(chunks) && chunks[i+1].Offset <= offset {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first < 0 {
+		return 0, fmt.Errorf("range starts past the end of file %s", fileID)
+	}
+	covering := chunks[first : last+1]
+
+	store = withParity(store, metaStore, contentID, chunks)
+	var written int64
+	opts := DefaultVerifyOptions()
+	opts.SizeHint = func(i int) int64 { return covering[i].Size }
+	if _, err := VerifyChunksInOrder(len(covering), opts,
+		func(i int) ([]byte, error) {
+			return decodeStoredChunk(contentID, covering[i], cipher, store)
+		},
+		func(i int, data []byte) error {
+			// Trim the first and last chunks to the requested bytes
+			from := max(offset-covering[i].Offset, 0)
+			to := min(end-covering[i].Offset, int64(len(data)))
+			if from >= to {
+				return nil
+			}
+			n, err := w.Write(data[from:to])
+			written += int64(n)
+			return err
+		}); err != nil {
+		return written, tracing.Error(ctx, fmt.Errorf("reading range of %s failed: %w", fileID, err))
+	}
+	if written == 0 {
+		return 0, fmt.Errorf("range starts past the end of file %s", fileID)
+	}
+	// A range is cut short only by the end of the file, never by a missing chunk
+	if size >= 0 {
+		if err := CheckSize(fileID, min(length, size-offset), written); err != nil {
+			return written, tracing.Error(ctx, err)
+		}
+	}
+	tracing.Logf(ctx, "✂️ Read %d bytes at offset %d of %s from %d of %d chunks", written, offset, fileID, len(covering), len(chunks))
+	return written, nil
+}
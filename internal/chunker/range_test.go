@@ -0,0 +1,61 @@
+package chunker
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadRangeFetchesOnlyCoveringChunks(t *testing.T) {
+	original, chunks, metaStore, local, _ := setupResumeTest(t)
+	if len(chunks) < 6 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	fileID := chunks[0].FileID
+
+	// A range straddling the boundary between chunks 2 and 3; every other chunk is unreachable
+	offset := chunks[3].Offset - 10
+	length := int64(100)
+	down := make(map[string]bool)
+	for i, chunk := range chunks {
+		if i != 2 && i != 3 {
+			down[chunk.Path] = true
+		}
+	}
+	store := &flakyStore{Storage: local, down: down}
+
+	var out bytes.Buffer
+	n, err := ReadRangeContext(t.Context(), fileID, offset, length, "pw", metaStore, store, &out)
+	if err != nil {
+		t.Fatalf("ReadRangeContext failed: %v", err)
+	}
+	if n != length || !bytes.Equal(out.Bytes(), original[offset:offset+length]) {
+		t.Errorf("expected %d bytes at offset %d, got %d: %q", length, offset, n, out.String())
+	}
+	if got := store.gets.Load(); got != 2 {
+		t.Errorf("expected only the 2 covering chunks fetched, got %d reads", got)
+	}
+
+	// A preview of the first bytes needs only the first chunk
+	store = &flakyStore{Storage: local, down: map[string]bool{}}
+	for _, chunk := range chunks[1:] {
+		store.down[chunk.Path] = true
+	}
+	out.Reset()
+	if _, err := ReadRangeContext(t.Context(), fileID, 0, 64, "pw", metaStore, store, &out); err != nil || !bytes.Equal(out.Bytes(), original[:64]) {
+		t.Errorf("expected the first 64 bytes from the first chunk, got %q, %v", out.String(), err)
+	}
+
+	// A range running off the end is cut short; one starting past it is rejected
+	out.Reset()
+	size := int64(len(original))
+	n, err = ReadRangeContext(t.Context(), fileID, size-5, 50, "pw", metaStore, local, &out)
+	if err != nil || n != 5 || !bytes.Equal(out.Bytes(), original[size-5:]) {
+		t.Errorf("expected the last 5 bytes, got %d, %v", n, err)
+	}
+	if _, err := ReadRangeContext(t.Context(), fileID, size, 10, "pw", metaStore, local, &out); err == nil {
+		t.Errorf("expected a range past the end rejected")
+	}
+	if _, err := ReadRangeContext(t.Context(), fileID, 0, 10, "wrong", metaStore, local, &out); err == nil {
+		t.Errorf("expected a wrong password to fail")
+	}
+}
@@ -0,0 +1,36 @@
+package chunker
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReassembleToWriterStreamsChunksInOrder(t *testing.T) {
+	original, chunks, metaStore, local, _ := setupResumeTest(t)
+	fileID := chunks[0].FileID
+
+	var out bytes.Buffer
+	if err := ReassembleToWriter(fileID, &out, "pw", metaStore, local); err != nil {
+		t.Fatalf("ReassembleToWriter failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), original) {
+		t.Errorf("expected %d bytes in order, got %d", len(original), out.Len())
+	}
+
+	// A chunk missing mid-stream fails the write after everything before it
+	missing := len(chunks) / 2
+	store := &flakyStore{Storage: local, down: map[string]bool{chunks[missing].Path: true}}
+	out.Reset()
+	err := ReassembleToWriter(fileID, &out, "pw", metaStore, store)
+	if err == nil {
+		t.Fatalf("expected a missing chunk to fail the reassembly")
+	}
+	if !bytes.Equal(out.Bytes(), original[:chunks[missing].Offset]) {
+		t.Errorf("expected the %d bytes before chunk %d written, got %d", chunks[missing].Offset, missing, out.Len())
+	}
+
+	out.Reset()
+	if err := ReassembleToWriter(fileID, &out, "wrong", metaStore, local); err == nil || out.Len() != 0 {
+		t.Errorf("expected a wrong password to fail before writing, got %d bytes, %v", out.Len(), err)
+	}
+}
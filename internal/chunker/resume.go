@@ -0,0 +1,192 @@
+package chunker
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// ProgressSuffix is appended to an output path to name its resume sidecar
+const ProgressSuffix = ".progress"
+
+var contentHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// resumeEnabled reports whether interrupted reassemblies keep their partial
+// output and pick up where they stopped
+func resumeEnabled() bool {
+	return config.Config != nil && config.Config.ReassemblyResume
+}
+
+// progressHeader is the first line of a sidecar and identifies the reassembly
+type progressHeader struct {
+	FileID string `json:"file_id"`
+	Chunks int    `json:"chunks"`
+}
+
+// progressEntry is appended to the sidecar once a chunk is written to the output
+type progressEntry struct {
+	Index int    `json:"index"`
+	Hash  string `json:"hash"`
+	Size  int64  `json:"size"`
+}
+
+// reassemblyProgress records verified chunks as they reach the output file.
+// The sidecar is one JSON line per chunk, so a write cut short loses at most
+// the last line and nothing before it.
+type reassemblyProgress struct {
+	path    string
+	file    *os.File
+	kept    []progressEntry // Chunks carried over from an interrupted run
+	written int             // Chunks in the output, all verified
+	offset  int64           // Bytes those chunks take up
+}
+
+// resumePoint reads the sidecar next to outputPath and checks the partial
+// output against the expected chunk hashes. It returns the progress to keep
+// appending to, starting after the last chunk whose bytes still match.
+// Anything unusable starts the reassembly over.
+func resumePoint(ctx context.Context, fileID string, chunks []metadata.ChunkMetadata, outputPath string) *reassemblyProgress {
+	progress := &reassemblyProgress{path: outputPath + ProgressSuffix}
+	entries, err := readProgress(progress.path, fileID, len(chunks))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			tracing.Logf(ctx, "⚠️ Ignoring reassembly progress for %s: %v", fileID, err)
+		}
+		return progress
+	}
+
+	output, err := os.Open(outputPath)
+	if err != nil {
+		return progress
+	}
+	defer output.Close()
+
+	// Re-hash what is on disk; a chunk that no longer matches and everything after it is fetched again
+	for i, entry := range entries {
+		if i >= len(chunks) || entry.Index != i || entry.Hash != chunks[i].Hash {
+			break
+		}
+		hasher, err := newChunkHasher(chunks[i].HashAlgo)
+		if err != nil {
+			break
+		}
+		if n, err := io.Copy(hasher, io.NewSectionReader(output, progress.offset, entry.Size)); err != nil || n != entry.Size {
+			break
+		}
+		if hex.EncodeToString(hasher.Sum(nil)) != entry.Hash {
+			tracing.Logf(ctx, "⚠️ Partial output of %s differs from chunk %d, resuming from there", fileID, i)
+			break
+		}
+		progress.kept = append(progress.kept, entry)
+		progress.written++
+		progress.offset += entry.Size
+	}
+	return progress
+}
+
+// readProgress parses a sidecar, stopping at the first incomplete line
+func readProgress(path, fileID string, chunkCount int) ([]progressEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("progress file is empty")
+	}
+	var header progressHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("failed to parse progress header: %v", err)
+	}
+	if header.FileID != fileID || header.Chunks != chunkCount {
+		return nil, fmt.Errorf("progress belongs to file %s with %d chunks", header.FileID, header.Chunks)
+	}
+
+	var entries []progressEntry
+	for scanner.Scan() {
+		var entry progressEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// start rewrites the sidecar to hold only the chunks kept from the last run
+// and opens it for appending
+func (p *reassemblyProgress) start(fileID string, chunkCount int) error {
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create progress file: %v", err)
+	}
+	p.file = f
+
+	header, _ := json.Marshal(progressHeader{FileID: fileID, Chunks: chunkCount})
+	lines := append(header, '\n')
+	for _, kept := range p.kept {
+		entry, _ := json.Marshal(kept)
+		lines = append(append(lines, entry...), '\n')
+	}
+	if _, err := f.Write(lines); err != nil {
+		return fmt.Errorf("failed to write progress file: %v", err)
+	}
+	return nil
+}
+
+// record notes a chunk that was just written to the output
+func (p *reassemblyProgress) record(index int, hash string, size int64) error {
+	entry, _ := json.Marshal(progressEntry{Index: index, Hash: hash, Size: size})
+	if _, err := p.file.Write(append(entry, '\n')); err != nil {
+		return fmt.Errorf("failed to record progress: %v", err)
+	}
+	p.written++
+	p.offset += size
+	return nil
+}
+
+func (p *reassemblyProgress) close() {
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+	}
+}
+
+// remove deletes the sidecar once the output is complete or discarded
+func (p *reassemblyProgress) remove() {
+	p.close()
+	os.Remove(p.path)
+}
+
+// verifyOutputHash checks a resumed output against the file's content hash.
+// Files stored before content addressing have placeholder IDs and are skipped.
+func verifyOutputHash(fileID, outputPath string) error {
+	if !contentHashPattern.MatchString(fileID) {
+		return nil
+	}
+	f, err := os.Open(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %v", err)
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return fmt.Errorf("failed to hash output file: %v", err)
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != fileID {
+		return fmt.Errorf("resumed output hash mismatch: expected %s, got %s", fileID, got)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// flakyStore fails reads of the chunks listed in down and counts the rest
+type flakyStore struct {
+	storage.Storage
+	down map[string]bool
+	gets atomic.Int32
+}
+
+func (s *flakyStore) Get(id string) (io.ReadCloser, error) {
+	if s.down[id] {
+		return nil, errors.New("connection reset")
+	}
+	s.gets.Add(1)
+	return s.Storage.Get(id)
+}
+
+// setupResumeTest stores a file of several chunks and returns its original
+// bytes and chunks in order
+func setupResumeTest(t *testing.T) ([]byte, []ChunkMetadata, *metadata.MetadataStore, storage.Storage, string) {
+	t.Helper()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize, ReassemblyResume: true}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() { metaStore.Close() })
+
+	var original bytes.Buffer
+	for i := 0; original.Len() < 8*int(MinChunkSize); i++ {
+		fmt.Fprintf(&original, "line %d of a large download\n", i)
+	}
+	input := filepath.Join(tempDir, "large.txt")
+	os.WriteFile(input, original.Bytes(), 0644)
+	chunks, err := ChunkAndStore(input, "pw", metaStore, local)
+	if err != nil {
+		t.Fatalf("ChunkAndStore failed: %v", err)
+	}
+	ordered := make([]ChunkMetadata, len(chunks))
+	for _, chunk := range chunks {
+		ordered[chunk.Index] = chunk
+	}
+	return original.Bytes(), ordered, metaStore, local, filepath.Join(tempDir, "large.out")
+}
+
+func TestReassemblyResumesAfterInterruption(t *testing.T) {
+	original, chunks, metaStore, local, output := setupResumeTest(t)
+	half := len(chunks) / 2
+
+	// The link drops halfway through
+	store := &flakyStore{Storage: local, down: map[string]bool{chunks[half].Path: true}}
+	err := ReassembleFile(chunks[0].FileID, output, "pw", metaStore, store)
+	var verifyErr *ChunkVerifyError
+	if !errors.As(err, &verifyErr) || verifyErr.Index != half {
+		t.Fatalf("expected reassembly to stop at chunk %d, got %v", half, err)
+	}
+	partial, _ := os.ReadFile(output)
+	if len(partial) == 0 || !bytes.HasPrefix(original, partial) {
+		t.Fatalf("expected a verified partial output, got %d bytes", len(partial))
+	}
+	if _, err := os.Stat(output + ProgressSuffix); err != nil {
+		t.Fatalf("expected a progress sidecar: %v", err)
+	}
+
+	// Resuming fetches only the chunks that were never written
+	store = &flakyStore{Storage: local}
+	if err := ReassembleFile(chunks[0].FileID, output, "pw", metaStore, store); err != nil {
+		t.Fatalf("resumed reassembly failed: %v", err)
+	}
+	if got := int(store.gets.Load()); got != len(chunks)-half {
+		t.Errorf("expected %d chunk reads after resuming, got %d", len(chunks)-half, got)
+	}
+	result, _ := os.ReadFile(output)
+	if !bytes.Equal(result, original) {
+		t.Error("resumed output does not match the original")
+	}
+	if _, err := os.Stat(output + ProgressSuffix); !os.IsNotExist(err) {
+		t.Error("expected the sidecar removed once complete")
+	}
+}
+
+func TestReassemblyResumeRejectsCorruptPartialOutput(t *testing.T) {
+	original, chunks, metaStore, local, output := setupResumeTest(t)
+	half := len(chunks) / 2
+
+	store := &flakyStore{Storage: local, down: map[string]bool{chunks[half].Path: true}}
+	ReassembleFile(chunks[0].FileID, output, "pw", metaStore, store)
+
+	// Damage the second chunk on disk; it and everything after is fetched again
+	f, err := os.OpenFile(output, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open partial output: %v", err)
+	}
+	f.WriteAt([]byte("garbage"), MinChunkSize+10)
+	f.Close()
+
+	store = &flakyStore{Storage: local}
+	if err := ReassembleFile(chunks[0].FileID, output, "pw", metaStore, store); err != nil {
+		t.Fatalf("resumed reassembly failed: %v", err)
+	}
+	if got := int(store.gets.Load()); got != len(chunks)-1 {
+		t.Errorf("expected %d chunk reads after the damaged chunk, got %d", len(chunks)-1, got)
+	}
+	result, _ := os.ReadFile(output)
+	if !bytes.Equal(result, original) {
+		t.Error("output resumed onto corrupt data")
+	}
+}
@@ -0,0 +1,158 @@
+package chunker
+
+import (
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// chunkReuse holds the chunks already recorded for content being stored
+// again, by index. Stored chunks are bound to their content ID and index and
+// sealed with the file's key, so only a chunk at the same index, with the
+// same hash, under the same key can stand in for a new one.
+type chunkReuse map[int]metadata.ChunkMetadata
+
+// reusableChunks returns the recorded chunks of contentID when cipher holds
+// the key they were sealed with: the key canary must open, or for files
+// without one, a recorded chunk must decrypt and verify.
+func reusableChunks(metaStore *metadata.MetadataStore, store storage.Storage, cipher *chunkCipher, contentID string) chunkReuse {
+	if metaStore == nil {
+		return nil
+	}
+	fileMeta, err := metaStore.GetFileMetadataByID(contentID)
+	if err != nil {
+		return nil
+	}
+	chunks, err := metaStore.GetChunksByFileID(contentID)
+	if err != nil || len(chunks) == 0 {
+		return nil
+	}
+	checked, err := checkCanary(fileMeta, cipher, contentID)
+	if err != nil {
+		return nil
+	}
+	if !checked {
+		if _, err := decodeStoredChunk(contentID, chunks[0], cipher, store); err != nil {
+			return nil
+		}
+	}
+	reuse := make(chunkReuse, len(chunks))
+	for _, chunk := range chunks {
+		reuse[chunk.Index] = chunk
+	}
+	return reuse
+}
+
+// match returns the recorded chunk standing in for task when its bytes are
+// still in store
+func (r chunkReuse) match(task chunkTask, hash, hashAlgo string, store storage.Storage) (ChunkMetadata, bool) {
+	prior, ok := r[task.Index]
+	if !ok || prior.Hash != hash || prior.Offset != task.Offset {
+		return ChunkMetadata{}, false
+	}
+	if algo, err := ParseHashAlgo(prior.HashAlgo); err != nil || algo != hashAlgo {
+		return ChunkMetadata{}, false
+	}
+	if exists, err := store.Exists(prior.Path); err != nil || !exists {
+		return ChunkMetadata{}, false
+	}
+	return ChunkMetadata{
+		Index:        prior.Index,
+		Hash:         prior.Hash,
+		Path:         prior.Path,
+		Size:         prior.Size,
+		Offset:       prior.Offset,
+		PrevIndex:    -1, // Linked again with the rest of the file
+		NextIndex:    -1,
+		FileID:       prior.FileID,
+		IsCompressed: prior.IsCompressed,
+		CRC32C:       prior.CRC32C,
+		HashAlgo:     hashAlgo,
+
+		CompressionAlgo:  prior.CompressionAlgo,
+		CompressedSize:   prior.CompressedSize,
+		CompressionRatio: prior.CompressionRatio,
+		SharedFrom:       prior.SharedFrom,
+		SharedIndex:      prior.SharedIndex,
+	}, true
+}
+
+// sharedChunk returns a chunk of fileID standing in for task that shares the
+// stored bytes of the chunk recorded under the same hash, when those bytes are
+// still in store and open under cipher. Chunks sealed with a per-file data key
+// never open under another file's key, so only password chunks are shared.
+func sharedChunk(metaStore *metadata.MetadataStore, store storage.Storage, cipher *chunkCipher, fileID string, task chunkTask, hash, hashAlgo string) (ChunkMetadata, bool) {
+	if metaStore == nil || cipher.dataKey != nil {
+		return ChunkMetadata{}, false
+	}
+	owner, err := metaStore.GetChunkMetadata(hash)
+	if err != nil || owner.SharedFrom != "" || (owner.FileID == fileID && owner.Index == task.Index) {
+		return ChunkMetadata{}, false
+	}
+	if algo, err := ParseHashAlgo(owner.HashAlgo); err != nil || algo != hashAlgo {
+		return ChunkMetadata{}, false
+	}
+	if !storedChunk(store, owner.Path) {
+		return ChunkMetadata{}, false
+	}
+	if _, err := decodeStoredChunk(owner.FileID, owner, cipher, store); err != nil {
+		return ChunkMetadata{}, false
+	}
+	return ChunkMetadata{
+		Index:        task.Index,
+		Hash:         hash,
+		Path:         owner.Path,
+		Size:         owner.Size,
+		Offset:       task.Offset,
+		PrevIndex:    -1, // Linked with the rest of the file
+		NextIndex:    -1,
+		FileID:       fileID,
+		IsCompressed: owner.IsCompressed,
+		CRC32C:       owner.CRC32C,
+		HashAlgo:     hashAlgo,
+
+		CompressionAlgo:  owner.CompressionAlgo,
+		CompressedSize:   owner.CompressedSize,
+		CompressionRatio: owner.CompressionRatio,
+		SharedFrom:       owner.FileID,
+		SharedIndex:      owner.Index,
+	}, true
+}
+
+// storedChunk reports whether the bytes at path are in store
+func storedChunk(store storage.Storage, path string) bool {
+	exists, err := store.Exists(path)
+	return err == nil && exists
+}
+
+// resumableChunks returns the chunks the upload manifest of contentID
+// records from an interrupted run that still decrypt under cipher and match
+// their recorded hash, along with how many chunks the manifest records.
+func resumableChunks(metaStore *metadata.MetadataStore, store storage.Storage, cipher *chunkCipher, contentID string) (chunkReuse, int) {
+	if metaStore == nil {
+		return nil, 0
+	}
+	recorded, err := metaStore.GetUploadManifest(contentID)
+	if err != nil || len(recorded) == 0 {
+		return nil, 0
+	}
+	progress := make(chunkReuse, len(recorded))
+	for _, chunk := range recorded {
+		if _, err := decodeStoredChunk(contentID, chunk, cipher, store); err == nil {
+			progress[chunk.Index] = chunk
+		}
+	}
+	return progress, len(recorded)
+}
+
+// merge adds the chunks of other at indices r has no chunk for
+func (r chunkReuse) merge(other chunkReuse) chunkReuse {
+	if r == nil {
+		return other
+	}
+	for index, chunk := range other {
+		if _, ok := r[index]; !ok {
+			r[index] = chunk
+		}
+	}
+	return r
+}
@@ -0,0 +1,239 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestChunkingTheSameFileTwiceWritesNothingNew(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	original := make([]byte, 5*MinChunkSize+100)
+	rand.New(rand.NewSource(11)).Read(original)
+	input := filepath.Join(tempDir, "input.bin")
+	os.WriteFile(input, original, 0644)
+
+	store := &countingStore{Storage: local}
+	first, err := ChunkAndStore(input, "pw", metaStore, store)
+	if err != nil {
+		t.Fatalf("first ChunkAndStore failed: %v", err)
+	}
+	if got := store.puts.Load(); got != int32(len(first)) {
+		t.Fatalf("expected %d chunk writes, got %d", len(first), got)
+	}
+
+	store.puts.Store(0)
+	second, err := ChunkAndStore(input, "pw", metaStore, store)
+	if err != nil {
+		t.Fatalf("second ChunkAndStore failed: %v", err)
+	}
+	if got := store.puts.Load(); got != 0 {
+		t.Errorf("expected no chunk writes on the second run, got %d", got)
+	}
+	for i := range first {
+		if second[i].Path != first[i].Path {
+			t.Errorf("chunk %d moved from %s to %s", i, first[i].Path, second[i].Path)
+		}
+	}
+
+	// A chunk gone from storage is written again; the rest are still reused
+	if err := local.Delete(first[2].Path); err != nil {
+		t.Fatalf("failed to delete chunk: %v", err)
+	}
+	store.puts.Store(0)
+	if _, err := ChunkAndStore(input, "pw", metaStore, store); err != nil {
+		t.Fatalf("ChunkAndStore after a deletion failed: %v", err)
+	}
+	if got := store.puts.Load(); got != 1 {
+		t.Errorf("expected only the deleted chunk written, got %d writes", got)
+	}
+	output := filepath.Join(tempDir, "output.bin")
+	if err := ReassembleFile(first[0].FileID, output, "pw", metaStore, local); err != nil {
+		t.Fatalf("ReassembleFile failed: %v", err)
+	}
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, original) {
+		t.Errorf("reassembled file differs from the original")
+	}
+
+	// Chunks sealed with another key cannot stand in
+	store.puts.Store(0)
+	if _, err := ChunkAndStore(input, "other", metaStore, store); err != nil {
+		t.Fatalf("ChunkAndStore with a new password failed: %v", err)
+	}
+	if got := store.puts.Load(); got != int32(len(first)) {
+		t.Errorf("expected every chunk written under a new password, got %d of %d", got, len(first))
+	}
+	if err := ReassembleFile(first[0].FileID, output, "other", metaStore, local); err != nil {
+		t.Fatalf("ReassembleFile under the new password failed: %v", err)
+	}
+}
+
+// crashingStore fails every chunk write after the first limit
+type crashingStore struct {
+	storage.Storage
+	limit int32
+	puts  atomic.Int32
+}
+
+func (s *crashingStore) Put(chunkData io.Reader) (string, error) {
+	if s.puts.Add(1) > s.limit {
+		return "", errors.New("process killed")
+	}
+	return s.Storage.Put(chunkData)
+}
+
+func TestResumeChunkingFinishesAnInterruptedUpload(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	original := make([]byte, 8*MinChunkSize)
+	rand.New(rand.NewSource(12)).Read(original)
+	input := filepath.Join(tempDir, "input.bin")
+	os.WriteFile(input, original, 0644)
+
+	if _, err := ResumeChunking(input, "pw", metaStore, local); err == nil {
+		t.Fatalf("expected resume without an interrupted upload to fail")
+	}
+
+	// The process dies after 3 of 8 chunks are stored
+	if _, err := ChunkAndStore(input, "pw", metaStore, &crashingStore{Storage: local, limit: 3}); err == nil {
+		t.Fatalf("expected the interrupted ChunkAndStore to fail")
+	}
+	fileID, err := HashSource(FileSource(input))
+	if err != nil {
+		t.Fatalf("HashSource failed: %v", err)
+	}
+	manifest, err := metaStore.GetUploadManifest(fileID)
+	if err != nil || len(manifest) != 3 {
+		t.Fatalf("expected 3 chunks in the upload manifest, got %d (%v)", len(manifest), err)
+	}
+
+	store := &countingStore{Storage: local}
+	chunks, err := ResumeChunking(input, "pw", metaStore, store)
+	if err != nil {
+		t.Fatalf("ResumeChunking failed: %v", err)
+	}
+	if len(chunks) != 8 {
+		t.Fatalf("expected 8 chunks, got %d", len(chunks))
+	}
+	if got := store.puts.Load(); got != 5 {
+		t.Errorf("expected only the 5 missing chunks written, got %d", got)
+	}
+	if manifest, _ := metaStore.GetUploadManifest(fileID); len(manifest) != 0 {
+		t.Errorf("expected the upload manifest cleared, %d chunks remain", len(manifest))
+	}
+
+	output := filepath.Join(tempDir, "output.bin")
+	if err := ReassembleFile(fileID, output, "pw", metaStore, local); err != nil {
+		t.Fatalf("ReassembleFile failed: %v", err)
+	}
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, original) {
+		t.Errorf("reassembled file differs from the original")
+	}
+}
+
+func TestChunkAndStoreSharesIdenticalChunksUnderTheSameKey(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	tempDir := t.TempDir()
+
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	store := &countingStore{Storage: local}
+
+	block := func(pattern string) []byte {
+		return bytes.Repeat([]byte(pattern), int(MinChunkSize))[:MinChunkSize]
+	}
+	contents := make(map[string][]byte)
+	passwords := make(map[string]string)
+	upload := func(name, password string, blocks ...[]byte) []ChunkMetadata {
+		t.Helper()
+		input := filepath.Join(tempDir, name)
+		os.WriteFile(input, bytes.Join(blocks, nil), 0644)
+		store.puts.Store(0)
+		chunks, err := ChunkAndStore(input, password, metaStore, store)
+		if err != nil {
+			t.Fatalf("ChunkAndStore of %s failed: %v", name, err)
+		}
+		contents[chunks[0].FileID], passwords[chunks[0].FileID] = bytes.Join(blocks, nil), password
+		return chunks
+	}
+	allReadable := func() {
+		t.Helper()
+		for fileID, content := range contents {
+			output := filepath.Join(tempDir, fileID+".out")
+			if err := ReassembleFile(fileID, output, passwords[fileID], metaStore, local); err != nil {
+				t.Fatalf("%s is not readable: %v", fileID, err)
+			}
+			if got, _ := os.ReadFile(output); !bytes.Equal(got, content) {
+				t.Fatalf("%s read back different content", fileID)
+			}
+		}
+	}
+
+	// A block repeated within one upload is written each time, and each copy is found again
+	upload("repeats.bin", "pw", block("repeated "), block("shared "), block("repeated "))
+	if got := store.puts.Load(); got != 3 {
+		t.Errorf("expected 3 chunk writes, got %d", got)
+	}
+	allReadable()
+
+	// A later file under the same password shares what is already stored
+	same := upload("same-key.bin", "pw", block("shared "), block("new "))
+	if got := store.puts.Load(); got != 1 {
+		t.Errorf("expected only the new chunk written, got %d writes", got)
+	}
+	if same[0].SharedFrom == "" {
+		t.Error("expected the first chunk shared with the earlier file")
+	}
+	if owner, err := metaStore.GetChunkMetadata(same[0].Hash); err != nil || owner.RefCount != 2 {
+		t.Errorf("expected the shared chunk referenced twice, got %d (%v)", owner.RefCount, err)
+	}
+
+	// Chunks sealed under another password cannot be read with this one, so they are stored again
+	upload("other-key.bin", "other", block("shared "), block("newer "))
+	if got := store.puts.Load(); got != 2 {
+		t.Errorf("expected both chunks written under another password, got %d writes", got)
+	}
+	allReadable()
+}
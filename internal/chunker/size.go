@@ -0,0 +1,37 @@
+package chunker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// ErrSizeMismatch is returned when reassembled output is not the length the
+// file's metadata declares, e.g. a chunk went missing from the chunk list
+var ErrSizeMismatch = errors.New("reassembled size does not match the file size")
+
+// sizeCheckEnabled reports whether reassembly output is held to the recorded file size
+func sizeCheckEnabled() bool {
+	return config.Config != nil && config.Config.EnforceFileSize
+}
+
+// CheckSize compares the bytes produced for a file with its recorded size.
+// A negative expected size means none is recorded and always passes, as
+// does any size while enforcement is off.
+func CheckSize(fileID string, expected, actual int64) error {
+	if expected < 0 || !sizeCheckEnabled() || expected == actual {
+		return nil
+	}
+	return fmt.Errorf("%w: %s should be %d bytes, got %d", ErrSizeMismatch, fileID, expected, actual)
+}
+
+// CheckOutputSize checks the length of a reassembled file on disk against its recorded size
+func CheckOutputSize(fileID, outputPath string, expected int64) error {
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat output file: %v", err)
+	}
+	return CheckSize(fileID, expected, info.Size())
+}
@@ -0,0 +1,62 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+func TestReassemblyFailsWhenAChunkIsMissingFromTheList(t *testing.T) {
+	original, chunks, metaStore, local, output := setupResumeTest(t)
+	config.Config.EnforceFileSize = true
+	fileID := chunks[0].FileID
+
+	// Drop the last chunk and relink the rest, so the chain still validates
+	// and every chunk left still matches its hash
+	last := chunks[len(chunks)-1]
+	if err := metaStore.DeleteChunkMetadata(last.Hash); err != nil {
+		t.Fatalf("failed to delete chunk metadata: %v", err)
+	}
+	for _, chunk := range chunks[:len(chunks)-1] {
+		meta := toMetadataChunk(chunk)
+		meta.TotalChunks = len(chunks) - 1
+		if chunk.Index == len(chunks)-2 {
+			meta.NextIndex = -1
+		}
+		if err := metaStore.PutChunkMetadata(meta); err != nil {
+			t.Fatalf("failed to rewrite chunk metadata: %v", err)
+		}
+	}
+
+	err := ReassembleFile(fileID, output, "pw", metaStore, local)
+	if !errors.Is(err, ErrSizeMismatch) {
+		t.Fatalf("expected ErrSizeMismatch, got %v", err)
+	}
+	want := fmt.Sprintf("should be %d bytes, got %d", len(original), last.Offset)
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("expected the error to give the expected and actual sizes (%q), got %v", want, err)
+	}
+	if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+		t.Errorf("expected the short output removed, got %v", statErr)
+	}
+
+	// A range reaching the end of the file comes up short the same way
+	var out bytes.Buffer
+	if _, err := ReadRangeContext(t.Context(), fileID, last.Offset-10, 100, "pw", metaStore, local, &out); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected a short range to fail the size check, got %v", err)
+	}
+
+	// With enforcement off the short file is produced as before
+	config.Config.EnforceFileSize = false
+	if err := ReassembleFile(fileID, output, "pw", metaStore, local); err != nil {
+		t.Fatalf("expected reassembly without the size check to succeed, got %v", err)
+	}
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, original[:last.Offset]) {
+		t.Errorf("expected every chunk but the last written, got %d bytes", len(got))
+	}
+}
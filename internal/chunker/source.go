@@ -0,0 +1,95 @@
+package chunker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Source is content to chunk. It is read twice, once for its content hash and
+// once to split it, so an upload can be chunked without being written to disk.
+// A source from HashedSource is read only to split it.
+type Source interface {
+	Name() string
+	Size() (int64, error)
+	Open() (io.ReadCloser, error)
+}
+
+// FileSource is the content of the file at path
+func FileSource(path string) Source {
+	return fileSource(path)
+}
+
+type fileSource string
+
+func (f fileSource) Name() string {
+	return filepath.Base(string(f))
+}
+
+func (f fileSource) Size() (int64, error) {
+	info, err := os.Stat(string(f))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (f fileSource) Open() (io.ReadCloser, error) {
+	return os.Open(string(f))
+}
+
+// ReaderSource is size bytes read from r under the given name, e.g. an
+// upload held in memory
+func ReaderSource(name string, r io.ReaderAt, size int64) Source {
+	return &readerSource{name: filepath.Base(name), r: r, size: size}
+}
+
+type readerSource struct {
+	name string
+	r    io.ReaderAt
+	size int64
+}
+
+func (s *readerSource) Name() string {
+	return s.name
+}
+
+func (s *readerSource) Size() (int64, error) {
+	return s.size, nil
+}
+
+func (s *readerSource) Open() (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(s.r, 0, s.size)), nil
+}
+
+// HashedSource is src with its SHA-256 already computed, so chunking it
+// does not read it an extra time for the hash
+func HashedSource(src Source, hash string) Source {
+	return &hashedSource{Source: src, hash: hash}
+}
+
+type hashedSource struct {
+	Source
+	hash string
+}
+
+// HashSource computes the SHA-256 of a source's content, its file ID
+func HashSource(src Source) (string, error) {
+	if hashed, ok := src.(*hashedSource); ok {
+		return hashed.hash, nil
+	}
+	r, err := src.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for hashing: %v", err)
+	}
+	defer r.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", fmt.Errorf("failed to calculate file hash: %v", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,79 @@
+package chunker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// countingSource counts how many times its content is opened
+type countingSource struct {
+	Source
+	opens int
+}
+
+func (s *countingSource) Open() (io.ReadCloser, error) {
+	s.opens++
+	return s.Source.Open()
+}
+
+func TestChunkAndStoreRecordsTheFileHash(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	tempDir := t.TempDir()
+
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	content := make([]byte, 3*MinChunkSize+17)
+	rand.New(rand.NewSource(5)).Read(content)
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:])
+
+	src := &countingSource{Source: ReaderSource("ledger.bin", bytes.NewReader(content), int64(len(content)))}
+	chunks, err := ChunkAndStoreSourceContext(context.Background(), src, "pw", metaStore, store)
+	if err != nil {
+		t.Fatalf("ChunkAndStore failed: %v", err)
+	}
+	for _, chunk := range chunks {
+		if chunk.FileID != want {
+			t.Fatalf("chunk %d carries file hash %s, want %s", chunk.Index, chunk.FileID, want)
+		}
+	}
+	if _, err := metaStore.GetFileMetadataByID(want); err != nil {
+		t.Errorf("file not recorded under its hash: %v", err)
+	}
+	if src.opens != 2 {
+		t.Errorf("expected the content read once to hash and once to split, got %d reads", src.opens)
+	}
+
+	// A hash computed up front is checked against the bytes split, not trusted
+	src = &countingSource{Source: ReaderSource("other.bin", bytes.NewReader(content), int64(len(content)))}
+	if _, err := ChunkAndStoreSourceContext(context.Background(), HashedSource(src, want), "pw", metaStore, store); err != nil {
+		t.Fatalf("ChunkAndStore of a hashed source failed: %v", err)
+	}
+	if src.opens != 1 {
+		t.Errorf("expected a hashed source read only to split, got %d reads", src.opens)
+	}
+	stale := HashedSource(ReaderSource("stale.bin", bytes.NewReader(content[1:]), int64(len(content)-1)), want)
+	if _, err := ChunkAndStoreSourceContext(context.Background(), stale, "pw", metaStore, store); err == nil || !strings.Contains(err.Error(), "changed while it was chunked") {
+		t.Errorf("expected content that does not match its hash refused, got %v", err)
+	}
+}
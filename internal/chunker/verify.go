@@ -0,0 +1,173 @@
+package chunker
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/budget"
+)
+
+// VerifyOptions controls the parallel decrypt/verify stage of reassembly
+type VerifyOptions struct {
+	Workers   int // Goroutines decoding chunks concurrently
+	Lookahead int // Max chunks decoded ahead of the write cursor (bounds memory)
+
+	// Memory, when set together with SizeHint, is charged SizeHint(index)
+	// bytes before each chunk is decoded and refunded once it is written
+	Memory   *budget.Budget
+	SizeHint func(index int) int64
+}
+
+// VerifyStats reports how the verification stage performed
+type VerifyStats struct {
+	Workers        int           `json:"workers"`
+	Lookahead      int           `json:"lookahead"`
+	Chunks         int           `json:"chunks"`
+	Bytes          int64         `json:"bytes"`
+	Duration       time.Duration `json:"duration"`
+	ThroughputMBps float64       `json:"throughput_mbps"`
+}
+
+// ChunkVerifyError identifies the chunk that aborted a reassembly
+type ChunkVerifyError struct {
+	Index int
+	Err   error
+}
+
+func (e *ChunkVerifyError) Error() string {
+	return fmt.Sprintf("chunk %d: %v", e.Index, e.Err)
+}
+
+func (e *ChunkVerifyError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultVerifyOptions returns the verification settings from config. Zero
+// workers means one per CPU; zero lookahead means twice the worker count.
+func DefaultVerifyOptions() VerifyOptions {
+	opts := VerifyOptions{Memory: budget.Global()}
+	if config.Config != nil {
+		opts.Workers = config.Config.VerifyWorkers
+		opts.Lookahead = config.Config.VerifyLookahead
+	}
+	return opts.normalize()
+}
+
+func (o VerifyOptions) normalize() VerifyOptions {
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.Lookahead <= 0 {
+		o.Lookahead = 2 * o.Workers
+	}
+	if o.Lookahead < o.Workers {
+		o.Lookahead = o.Workers
+	}
+	return o
+}
+
+type verifyResult struct {
+	data []byte
+	err  error
+}
+
+// VerifyChunksInOrder runs decode for chunk indexes 0..count-1 on a worker pool
+// and hands the results to write strictly in index order. At most Lookahead
+// decoded chunks are held in memory at once. The first failing chunk (in index
+// order) stops the pipeline and is returned as a *ChunkVerifyError.
+func VerifyChunksInOrder(
+	count int,
+	opts VerifyOptions,
+	decode func(index int) ([]byte, error),
+	write func(index int, data []byte) error,
+) (VerifyStats, error) {
+	opts = opts.normalize()
+	stats := VerifyStats{Workers: opts.Workers, Lookahead: opts.Lookahead}
+	start := time.Now()
+
+	// Each in-flight chunk owns slot index%Lookahead until it has been written;
+	// the window semaphore guarantees a slot is free before it is reused.
+	slots := make([]chan verifyResult, opts.Lookahead)
+	for i := range slots {
+		slots[i] = make(chan verifyResult, 1)
+	}
+	window := make(chan struct{}, opts.Lookahead)
+	jobs := make(chan int)
+	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	charge := func(int) int64 { return 0 }
+	if opts.Memory != nil && opts.SizeHint != nil {
+		charge = opts.SizeHint
+	}
+	var reserved, refunded int64
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := 0; i < count; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			if err := opts.Memory.Acquire(ctx, charge(i)); err != nil {
+				return
+			}
+			reserved += charge(i)
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := decode(i)
+				slots[i%opts.Lookahead] <- verifyResult{data: data, err: err}
+			}
+		}()
+	}
+
+	var failure error
+	for i := 0; i < count; i++ {
+		result := <-slots[i%opts.Lookahead]
+		if result.err != nil {
+			failure = &ChunkVerifyError{Index: i, Err: result.err}
+			break
+		}
+		if err := write(i, result.data); err != nil {
+			failure = &ChunkVerifyError{Index: i, Err: err}
+			break
+		}
+		stats.Chunks++
+		stats.Bytes += int64(len(result.data))
+		opts.Memory.Release(charge(i))
+		refunded += charge(i)
+		<-window
+	}
+
+	close(done)
+	cancel()
+	wg.Wait()
+	// Refund chunks that were dispatched but never written
+	opts.Memory.Release(reserved - refunded)
+
+	stats.Duration = time.Since(start)
+	if seconds := stats.Duration.Seconds(); seconds > 0 {
+		stats.ThroughputMBps = float64(stats.Bytes) / (1024 * 1024) / seconds
+	}
+	return stats, failure
+}
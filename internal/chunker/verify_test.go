@@ -0,0 +1,206 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/budget"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestVerifyChunksInOrderPreservesOrder(t *testing.T) {
+	const count = 64
+	var inFlight, maxInFlight int32
+
+	var written []int
+	stats, err := VerifyChunksInOrder(count, VerifyOptions{Workers: 4, Lookahead: 6},
+		func(i int) ([]byte, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			// Later chunks finish first to exercise reordering
+			time.Sleep(time.Duration((count-i)%5) * time.Millisecond)
+			return []byte{byte(i)}, nil
+		},
+		func(i int, data []byte) error {
+			atomic.AddInt32(&inFlight, -1)
+			if int(data[0]) != i {
+				return fmt.Errorf("got data for chunk %d at position %d", data[0], i)
+			}
+			written = append(written, i)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("VerifyChunksInOrder failed: %v", err)
+	}
+	if len(written) != count || stats.Chunks != count || stats.Bytes != count {
+		t.Errorf("expected %d chunks written, got %d (stats %+v)", count, len(written), stats)
+	}
+	if maxInFlight > 6 {
+		t.Errorf("lookahead exceeded: %d chunks decoded ahead of the writer", maxInFlight)
+	}
+}
+
+func TestVerifyChunksInOrderReportsFailingChunk(t *testing.T) {
+	corrupt := errors.New("hash mismatch")
+	var written int32
+
+	_, err := VerifyChunksInOrder(100, VerifyOptions{Workers: 8},
+		func(i int) ([]byte, error) {
+			if i == 37 {
+				return nil, corrupt
+			}
+			return []byte{1}, nil
+		},
+		func(i int, data []byte) error {
+			atomic.AddInt32(&written, 1)
+			return nil
+		})
+
+	var verifyErr *ChunkVerifyError
+	if !errors.As(err, &verifyErr) || verifyErr.Index != 37 || !errors.Is(err, corrupt) {
+		t.Fatalf("expected failure on chunk 37, got %v", err)
+	}
+	if written != 37 {
+		t.Errorf("expected writes to stop at chunk 37, wrote %d", written)
+	}
+}
+
+func TestVerifyChunksInOrderWaitsForMemoryBudget(t *testing.T) {
+	// Room for two chunks while eight workers want to decode ahead
+	memory := budget.NewBudget(200)
+	opts := VerifyOptions{
+		Workers:   8,
+		Lookahead: 16,
+		Memory:    memory,
+		SizeHint:  func(int) int64 { return 100 },
+	}
+
+	var written int
+	_, err := VerifyChunksInOrder(40, opts,
+		func(i int) ([]byte, error) {
+			if inFlight := memory.Stats().InFlight; inFlight > 200 {
+				return nil, fmt.Errorf("%d bytes in flight past the budget", inFlight)
+			}
+			time.Sleep(time.Millisecond)
+			return []byte{byte(i)}, nil
+		},
+		func(i int, data []byte) error {
+			written++
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("VerifyChunksInOrder failed: %v", err)
+	}
+	if written != 40 {
+		t.Errorf("expected 40 chunks written, got %d", written)
+	}
+
+	stats := memory.Stats()
+	if stats.Peak > 200 || stats.InFlight != 0 {
+		t.Errorf("budget not respected or not refunded: %+v", stats)
+	}
+	if stats.Waits == 0 {
+		t.Errorf("expected the dispatcher to wait for the budget")
+	}
+}
+
+func TestReassembleRemovesOutputOnCorruptChunk(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+	tempDir := t.TempDir()
+
+	store, metaStore, km := newVerifyFixture(t, tempDir)
+	defer metaStore.Close()
+
+	input := filepath.Join(tempDir, "input.bin")
+	os.WriteFile(input, patternedContent(4*int(MinChunkSize)), 0644)
+	chunks, err := ChunkAndStoreWithServerKey(input, km, metaStore, store)
+	if err != nil {
+		t.Fatalf("ChunkAndStoreWithServerKey failed: %v", err)
+	}
+
+	// Overwrite the third chunk with a valid chunk from another position
+	second, _ := os.ReadFile(filepath.Join(tempDir, "chunks", chunks[1].Path))
+	os.WriteFile(filepath.Join(tempDir, "chunks", chunks[2].Path), second, 0644)
+
+	out := filepath.Join(tempDir, "out.bin")
+	err = ReassembleFileWithServerKey(chunks[0].FileID, out, km, metaStore, store)
+	var verifyErr *ChunkVerifyError
+	if !errors.As(err, &verifyErr) || verifyErr.Index != 2 {
+		t.Fatalf("expected failure identifying chunk 2, got %v", err)
+	}
+	if _, statErr := os.Stat(out); !os.IsNotExist(statErr) {
+		t.Errorf("expected partial output to be removed")
+	}
+}
+
+func newVerifyFixture(tb testing.TB, dir string) (storage.Storage, *metadata.MetadataStore, *encryptor.KeyManager) {
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		tb.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		tb.Fatalf("failed to open metadata store: %v", err)
+	}
+	km, err := encryptor.NewKeyManager(bytes.Repeat([]byte{3}, 32))
+	if err != nil {
+		tb.Fatalf("failed to create key manager: %v", err)
+	}
+	return store, metaStore, km
+}
+
+// patternedContent returns data whose chunks all differ, so none are deduplicated
+func patternedContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i*7 + i/251 + i/4093)
+	}
+	return content
+}
+
+func BenchmarkReassembleVerifyWorkers(b *testing.B) {
+	const chunkSize = 256 * 1024
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+	tempDir := b.TempDir()
+
+	store, metaStore, km := newVerifyFixture(b, tempDir)
+	defer metaStore.Close()
+
+	content := patternedContent(64 * chunkSize)
+	input := filepath.Join(tempDir, "bench.bin")
+	os.WriteFile(input, content, 0644)
+	chunks, err := ChunkAndStoreWithServerKey(input, km, metaStore, store)
+	if err != nil {
+		b.Fatalf("ChunkAndStoreWithServerKey failed: %v", err)
+	}
+	fileID := chunks[0].FileID
+	out := filepath.Join(tempDir, "bench.out")
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			config.Config.VerifyWorkers = workers
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if err := ReassembleFileWithServerKey(fileID, out, km, metaStore, store); err != nil {
+					b.Fatalf("reassembly failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package chunker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// UpdateFile stores filePath as the next version of an existing file. The
+// file keeps its ID, name and creation time, so anything keyed by the ID
+// (ownership, tags, shares) carries over, and ReassembleFile serves the new
+// content from then on. The prior state is recorded as a version first, so
+// it stays retrievable with ReassembleVersion.
+func UpdateFile(fileID, filePath, password, changeLog string, metaStore *metadata.MetadataStore, store storage.Storage) (*metadata.VersionRecord, error) {
+	return UpdateFileSource(fileID, FileSource(filePath), password, changeLog, metaStore, store)
+}
+
+// UpdateFileSource works like UpdateFile for content that need not be a file on disk.
+// The password must open the current version, so a new version cannot be
+// written under a password the file's users do not know.
+func UpdateFileSource(fileID string, src Source, password, changeLog string, metaStore *metadata.MetadataStore, store storage.Storage) (*metadata.VersionRecord, error) {
+	if err := CheckPassword(fileID, password, metaStore, store); err != nil {
+		return nil, fmt.Errorf("cannot update %s: %w", fileID, err)
+	}
+	return updateFile(fileID, src, changeLog, passwordCipher(password), func(fm *metadata.FileMetadata) {
+		fm.KeyMode = ""
+		fm.KeyID = ""
+		fm.WrappedKey = nil
+	}, metaStore, store)
+}
+
+// UpdateFileWithServerKey works like UpdateFile but encrypts the new version
+// with a fresh data key wrapped by the server master key.
+func UpdateFileWithServerKey(fileID, filePath, changeLog string, km *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage) (*metadata.VersionRecord, error) {
+	return UpdateFileSourceWithServerKey(fileID, FileSource(filePath), changeLog, km, metaStore, store)
+}
+
+// UpdateFileSourceWithServerKey works like UpdateFileWithServerKey for content that need not be a file on disk
+func UpdateFileSourceWithServerKey(fileID string, src Source, changeLog string, km *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage) (*metadata.VersionRecord, error) {
+	if km == nil {
+		return nil, fmt.Errorf("server-managed keys are not configured")
+	}
+
+	dataKey, wrappedKey, err := km.GenerateDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return updateFile(fileID, src, changeLog, dataKeyCipher(dataKey), func(fm *metadata.FileMetadata) {
+		fm.KeyMode = metadata.KeyModeServerManaged
+		fm.KeyID = km.KeyID()
+		fm.WrappedKey = wrappedKey
+	}, metaStore, store)
+}
+
+func updateFile(fileID string, src Source, changeLog string, cipher *chunkCipher, annotate func(*metadata.FileMetadata), metaStore *metadata.MetadataStore, store storage.Storage) (*metadata.VersionRecord, error) {
+	if metaStore == nil {
+		return nil, fmt.Errorf("metadata store is required to update a file")
+	}
+	current, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata for %s: %v", fileID, err)
+	}
+
+	// Files uploaded before their first update are implicitly version 1
+	prior, err := currentVersion(fileID, current, metaStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture version %d of %s: %v", prior.Version, fileID, err)
+	}
+	if _, err := metaStore.GetVersion(fileID, prior.Version); errors.Is(err, badger.ErrKeyNotFound) {
+		if err := metaStore.PutVersion(prior); err != nil {
+			return nil, fmt.Errorf("failed to store version %d of %s: %v", prior.Version, fileID, err)
+		}
+	}
+
+	// The new content is chunked in full: chunk headers bind every chunk to
+	// its content hash, so chunks of the prior version cannot be reused
+	content, err := storeContent(context.Background(), src, cipher, nil, store, false)
+	if err != nil {
+		return nil, err
+	}
+	release, err := holdChunks(context.Background(), metaStore, store, content.Chunks, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := putChunkMetadata(metaStore, store, content.Chunks); err != nil {
+		return nil, err
+	}
+
+	next := current
+	next.FileSize = content.Size
+	next.NumChunks = len(content.ChunkHashes)
+	next.ChunkHashes = content.ChunkHashes
+	next.Health = ""
+	next.Version = prior.Version + 1
+	next.ContentID = content.ContentID
+	next.HashAlgo = content.HashAlgo
+	annotate(&next)
+	if err := sealCanary(&next, cipher, content.ContentID); err != nil {
+		return nil, err
+	}
+
+	record := metadata.VersionRecord{
+		FileID:    fileID,
+		Version:   next.Version,
+		ContentID: content.ContentID,
+		File:      next,
+		Chunks:    make([]metadata.ChunkMetadata, 0, len(content.Chunks)),
+		ChangeLog: changeLog,
+		CreatedAt: time.Now().Unix(),
+	}
+	for _, chunk := range content.Chunks {
+		record.Chunks = append(record.Chunks, toMetadataChunk(chunk))
+	}
+	if err := metaStore.PutVersion(record); err != nil {
+		return nil, fmt.Errorf("failed to store version %d of %s: %v", record.Version, fileID, err)
+	}
+
+	// Point the file at the new version only once it is fully recorded
+	if err := metaStore.PutFileMetadataByID(fileID, next); err != nil {
+		return nil, fmt.Errorf("failed to store file metadata by ID: %v", err)
+	}
+	if err := metaStore.PutFileMetadata(next); err != nil {
+		return nil, fmt.Errorf("failed to store file metadata: %v", err)
+	}
+
+	fmt.Printf("🆕 Stored version %d of %s (%d chunks)\n", record.Version, fileID, len(record.Chunks))
+	return &record, nil
+}
+
+// currentVersion describes the live state of a file as a version record
+func currentVersion(fileID string, current metadata.FileMetadata, metaStore *metadata.MetadataStore) (metadata.VersionRecord, error) {
+	record := metadata.VersionRecord{
+		FileID:    fileID,
+		Version:   current.Version,
+		ContentID: current.CurrentContentID(fileID),
+		File:      current,
+		CreatedAt: current.CreatedAt,
+	}
+	if record.Version == 0 {
+		record.Version = 1
+		record.File.Version = 1
+	}
+
+	chunks, err := metaStore.GetChunksByFileID(record.ContentID)
+	if err != nil {
+		return record, err
+	}
+	if err := metadata.ValidateChunkChain(chunks); err != nil {
+		return record, err
+	}
+	sortChunksByOffset(chunks)
+	record.Chunks = chunks
+	return record, nil
+}
+
+// FileVersion returns one version of a file. The current version of a file
+// that was never updated is reported as version 1.
+func FileVersion(fileID string, version int, metaStore *metadata.MetadataStore) (metadata.VersionRecord, error) {
+	if metaStore == nil {
+		return metadata.VersionRecord{}, fmt.Errorf("metadata store is required for file versions")
+	}
+	record, err := metaStore.GetVersion(fileID, version)
+	if err == nil {
+		// Versions go with the file once it expires
+		if current, err := metaStore.GetFileMetadataByID(fileID); err == nil && !current.Available(time.Now()) {
+			return metadata.VersionRecord{}, fmt.Errorf("%w: %s", metadata.ErrFileExpired, fileID)
+		}
+		return record, nil
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return record, fmt.Errorf("failed to get version %d of %s: %v", version, fileID, err)
+	}
+
+	current, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return record, fmt.Errorf("failed to get file metadata for %s: %v", fileID, err)
+	}
+	if !current.Available(time.Now()) {
+		return record, fmt.Errorf("%w: %s", metadata.ErrFileExpired, fileID)
+	}
+	if current.Version != 0 || version != 1 {
+		return record, fmt.Errorf("version %d of %s not found", version, fileID)
+	}
+	return currentVersion(fileID, current, metaStore)
+}
+
+// ReassembleVersion reconstructs a specific version of a password-encrypted file.
+func ReassembleVersion(fileID string, version int, outputPath, password string, metaStore *metadata.MetadataStore, store storage.Storage) error {
+	record, err := FileVersion(fileID, version, metaStore)
+	if err != nil {
+		return err
+	}
+	if record.File.IsServerManaged() {
+		return fmt.Errorf("version %d of %s uses a server-managed key; use ReassembleVersionWithServerKey", version, fileID)
+	}
+	cipher := passwordCipher(password)
+	if _, err := checkCanary(record.File, cipher, record.ContentID); err != nil {
+		return err
+	}
+	return reassembleChunks(context.Background(), record.ContentID, record.Chunks, record.File.FileSize, outputPath, cipher, store)
+}
+
+// ReassembleVersionWithServerKey reconstructs a specific version encrypted
+// with a server-managed key.
+func ReassembleVersionWithServerKey(fileID string, version int, outputPath string, km *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage) error {
+	if km == nil {
+		return fmt.Errorf("server-managed keys are not configured")
+	}
+	record, err := FileVersion(fileID, version, metaStore)
+	if err != nil {
+		return err
+	}
+	dataKey, err := unwrapKey(fileID, record.File, km)
+	if err != nil {
+		return err
+	}
+	cipher := dataKeyCipher(dataKey)
+	if _, err := checkCanary(record.File, cipher, record.ContentID); err != nil {
+		return err
+	}
+	return reassembleChunks(context.Background(), record.ContentID, record.Chunks, record.File.FileSize, outputPath, cipher, store)
+}
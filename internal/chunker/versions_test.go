@@ -0,0 +1,85 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+func TestUpdateFileKeepsEveryVersionRetrievable(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+	tempDir := t.TempDir()
+
+	store, metaStore, _ := newVerifyFixture(t, tempDir)
+	defer metaStore.Close()
+
+	v1 := patternedContent(3 * int(MinChunkSize))
+	input := filepath.Join(tempDir, "report.bin")
+	os.WriteFile(input, v1, 0644)
+	chunks, err := ChunkAndStore(input, "secret", metaStore, store)
+	if err != nil {
+		t.Fatalf("ChunkAndStore failed: %v", err)
+	}
+	fileID := chunks[0].FileID
+
+	// v2 rewrites the first chunk and appends one; the middle chunks are unchanged
+	v2 := append([]byte{}, v1...)
+	copy(v2, bytes.Repeat([]byte("v2"), int(MinChunkSize)/2))
+	v2 = append(v2, bytes.Repeat([]byte{42}, int(MinChunkSize))...)
+	update := filepath.Join(tempDir, "update.bin")
+	os.WriteFile(update, v2, 0644)
+
+	// A password that does not open the current version cannot write the next one
+	if _, err := UpdateFile(fileID, update, "not-the-secret", "hijack", metaStore, store); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("expected an update under the wrong password refused, got %v", err)
+	}
+
+	record, err := UpdateFile(fileID, update, "secret", "second draft", metaStore, store)
+	if err != nil {
+		t.Fatalf("UpdateFile failed: %v", err)
+	}
+	if record.Version != 2 || record.ChangeLog != "second draft" {
+		t.Errorf("unexpected version record: version %d, change log %q", record.Version, record.ChangeLog)
+	}
+
+	// The file keeps its ID and name while pointing at the new content
+	meta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		t.Fatalf("file metadata lost after update: %v", err)
+	}
+	if meta.Version != 2 || meta.FileName != "report.bin" || meta.FileSize != int64(len(v2)) {
+		t.Errorf("unexpected current metadata: %+v", meta)
+	}
+
+	// Downloads serve the latest version by default
+	latest := filepath.Join(tempDir, "latest.bin")
+	if err := ReassembleFile(fileID, latest, "secret", metaStore, store); err != nil {
+		t.Fatalf("ReassembleFile failed: %v", err)
+	}
+	if got, _ := os.ReadFile(latest); !bytes.Equal(got, v2) {
+		t.Errorf("latest download does not match v2")
+	}
+
+	for version, want := range map[int][]byte{1: v1, 2: v2} {
+		out := filepath.Join(tempDir, "version.bin")
+		if err := ReassembleVersion(fileID, version, out, "secret", metaStore, store); err != nil {
+			t.Fatalf("ReassembleVersion(%d) failed: %v", version, err)
+		}
+		if got, _ := os.ReadFile(out); !bytes.Equal(got, want) {
+			t.Errorf("version %d content does not match", version)
+		}
+	}
+
+	versions, err := metaStore.ListVersions(fileID)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Errorf("expected versions 1 and 2, got %d records", len(versions))
+	}
+}
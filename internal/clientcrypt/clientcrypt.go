@@ -0,0 +1,127 @@
+// Package clientcrypt is the client-side half of client-encrypted uploads.
+// It chunks and seals a file under a key that never leaves the client, and
+// opens the ciphertext chunks the server hands back on download.
+package clientcrypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithm names the cipher recorded in manifests sealed by this package
+const Algorithm = "chacha20-poly1305"
+
+// KeySize is the length of client keys in bytes
+const KeySize = chacha20poly1305.KeySize
+
+// DefaultChunkSize is the plaintext size of each sealed chunk
+const DefaultChunkSize = 1024 * 1024
+
+// ErrAuthentication is returned when a chunk fails to decrypt under the key
+var ErrAuthentication = errors.New("chunk failed authentication")
+
+// GenerateKey returns a new random client key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// SealFile splits the file into chunks and encrypts each one under key. It
+// returns the manifest to upload alongside the ciphertext chunks.
+func SealFile(path string, key []byte, chunkSize int) (*metadata.ClientManifest, [][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return Seal(filepath.Base(path), data, key, chunkSize)
+}
+
+// Seal splits data into chunks and encrypts each one under key
+func Seal(name string, data []byte, key []byte, chunkSize int) (*metadata.ClientManifest, [][]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	total := (len(data) + chunkSize - 1) / chunkSize
+	if total == 0 {
+		total = 1
+	}
+	manifest := &metadata.ClientManifest{FileName: name, FileSize: int64(len(data)), Algorithm: Algorithm}
+	chunks := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		nonce := make([]byte, chacha20poly1305.NonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		sealed := aead.Seal(nonce, nonce, data[i*chunkSize:end], chunkAAD(i, total))
+
+		sum := sha256.Sum256(sealed)
+		manifest.Chunks = append(manifest.Chunks, metadata.ClientChunk{Index: i, Size: int64(len(sealed)), Hash: hex.EncodeToString(sum[:])})
+		chunks = append(chunks, sealed)
+	}
+	return manifest, chunks, nil
+}
+
+// Open decrypts the ciphertext chunks listed in manifest and writes the
+// plaintext to w. Chunks that were swapped, dropped or altered fail to open.
+func Open(manifest metadata.ClientManifest, chunks [][]byte, key []byte, w io.Writer) error {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	if len(chunks) != len(manifest.Chunks) {
+		return fmt.Errorf("manifest lists %d chunks but %d were given", len(manifest.Chunks), len(chunks))
+	}
+
+	var written int64
+	for i, sealed := range chunks {
+		if len(sealed) < chacha20poly1305.NonceSize+aead.Overhead() {
+			return fmt.Errorf("chunk %d: %w", i, ErrAuthentication)
+		}
+		nonce := sealed[:chacha20poly1305.NonceSize]
+		plain, err := aead.Open(nil, nonce, sealed[chacha20poly1305.NonceSize:], chunkAAD(i, len(chunks)))
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", i, ErrAuthentication)
+		}
+		n, err := w.Write(plain)
+		if err != nil {
+			return fmt.Errorf("failed to write chunk %d: %w", i, err)
+		}
+		written += int64(n)
+	}
+	if written != manifest.FileSize {
+		return fmt.Errorf("decrypted %d bytes, manifest declares %d", written, manifest.FileSize)
+	}
+	return nil
+}
+
+// chunkAAD binds each chunk to its position and the chunk count so the
+// server cannot reorder or truncate a file without detection
+func chunkAAD(index, total int) []byte {
+	aad := make([]byte, 16)
+	binary.BigEndian.PutUint64(aad[:8], uint64(index))
+	binary.BigEndian.PutUint64(aad[8:], uint64(total))
+	return aad
+}
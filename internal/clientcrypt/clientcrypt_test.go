@@ -0,0 +1,106 @@
+package clientcrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestClientEncryptedRoundTripKeepsKeyOffServer(t *testing.T) {
+	dir := t.TempDir()
+	chunkDir := filepath.Join(dir, "chunks")
+	store, err := storage.NewLocalStorage(chunkDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	plaintext := bytes.Repeat([]byte("only the client can read this line. "), 2000)
+	input := filepath.Join(dir, "private.txt")
+	os.WriteFile(input, plaintext, 0644)
+
+	// Client side: seal under a key the server is never given
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	manifest, sealed, err := SealFile(input, key, 16*1024)
+	if err != nil {
+		t.Fatalf("SealFile failed: %v", err)
+	}
+	if len(sealed) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(sealed))
+	}
+
+	// Server side: store the opaque chunks
+	readers := make([]io.Reader, len(sealed))
+	for i, chunk := range sealed {
+		readers[i] = bytes.NewReader(chunk)
+	}
+	fileID, err := chunker.StoreClientEncrypted(*manifest, readers, metaStore, store)
+	if err != nil {
+		t.Fatalf("StoreClientEncrypted failed: %v", err)
+	}
+
+	meta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil || !meta.IsClientEncrypted() {
+		t.Fatalf("expected the file to be marked client-encrypted, got %+v (%v)", meta, err)
+	}
+	err = chunker.ReassembleFile(fileID, filepath.Join(dir, "server-out"), "any password", metaStore, store)
+	if !errors.Is(err, chunker.ErrClientEncrypted) {
+		t.Errorf("server-side reassembly should refuse client-encrypted files, got %v", err)
+	}
+
+	// Nothing the server stored contains the plaintext or the key
+	filepath.Walk(chunkDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		blob, _ := os.ReadFile(path)
+		if bytes.Contains(blob, plaintext[:32]) || bytes.Contains(blob, key) {
+			t.Errorf("stored chunk %s leaks plaintext or key", path)
+		}
+		return nil
+	})
+
+	// Client side again: fetch the manifest and ciphertext and decrypt locally
+	downloaded, err := chunker.ClientManifestFor(fileID, metaStore)
+	if err != nil {
+		t.Fatalf("ClientManifestFor failed: %v", err)
+	}
+	fetched := make([][]byte, len(downloaded.Chunks))
+	for i := range downloaded.Chunks {
+		if fetched[i], err = chunker.ReadClientChunk(fileID, i, metaStore, store); err != nil {
+			t.Fatalf("ReadClientChunk %d failed: %v", i, err)
+		}
+	}
+	var out bytes.Buffer
+	if err := Open(*downloaded, fetched, key, &out); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("decrypted file does not match the original")
+	}
+
+	// Reordered chunks and the wrong key are both rejected
+	fetched[0], fetched[1] = fetched[1], fetched[0]
+	if err := Open(*downloaded, fetched, key, io.Discard); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("expected reordered chunks to fail authentication, got %v", err)
+	}
+	fetched[0], fetched[1] = fetched[1], fetched[0]
+	otherKey, _ := GenerateKey()
+	if err := Open(*downloaded, fetched, otherKey, io.Discard); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("expected the wrong key to fail authentication, got %v", err)
+	}
+}
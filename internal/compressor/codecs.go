@@ -0,0 +1,112 @@
+package compressor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Chunk compression algorithms
+const (
+	AlgoNone = "none"
+	AlgoLZ4  = "lz4" // Used by chunks written before the algorithm was configurable
+	AlgoGzip = "gzip"
+	AlgoZstd = "zstd"
+)
+
+// ParseAlgo validates a compression algorithm name, "" meaning LZ4
+func ParseAlgo(name string) (string, error) {
+	switch algo := strings.ToLower(strings.TrimSpace(name)); algo {
+	case "":
+		return AlgoLZ4, nil
+	case AlgoNone, AlgoLZ4, AlgoGzip, AlgoZstd:
+		return algo, nil
+	default:
+		return "", fmt.Errorf("unsupported compression algorithm: %s", name)
+	}
+}
+
+// The zstd encoder and decoder are safe for concurrent EncodeAll/DecodeAll
+// calls and costly to create, so chunks share one of each
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// Compress compresses data with algo
+func Compress(algo string, data []byte) ([]byte, error) {
+	switch algo {
+	case AlgoNone:
+		return data, nil
+	case AlgoLZ4:
+		return CompressChunk(data)
+	case AlgoGzip:
+		var out bytes.Buffer
+		writer := gzip.NewWriter(&out)
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("compression failed: %v", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("compression close failed: %v", err)
+		}
+		return out.Bytes(), nil
+	case AlgoZstd:
+		encoder, _, err := zstdCodec()
+		if err != nil {
+			return nil, fmt.Errorf("compression failed: %v", err)
+		}
+		return encoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %s", algo)
+	}
+}
+
+// Decompress reverses Compress with the same algo
+func Decompress(algo string, data []byte) ([]byte, error) {
+	switch algo {
+	case AlgoNone:
+		return data, nil
+	case AlgoLZ4:
+		return DecompressData(data)
+	case AlgoGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompression failed: %v", err)
+		}
+		defer reader.Close()
+		decompressed, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("decompression failed: %v", err)
+		}
+		return decompressed, nil
+	case AlgoZstd:
+		_, decoder, err := zstdCodec()
+		if err != nil {
+			return nil, fmt.Errorf("decompression failed: %v", err)
+		}
+		decompressed, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decompression failed: %v", err)
+		}
+		return decompressed, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %s", algo)
+	}
+}
@@ -0,0 +1,284 @@
+package dfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// Sources of a backfilled chunk's file association
+const (
+	BackfillSourceMetadata = "metadata" // The basic chunk record for the stored chunk
+	BackfillSourceHeader   = "header"   // The header written in front of the stored chunk
+)
+
+// ChunkCatalog is the enhanced metadata a chunk backfill reads and updates
+type ChunkCatalog interface {
+	HasChunkMetadata(chunkID string) (bool, error)
+	StoreChunkMetadata(meta *metadata.EnhancedChunkMetadata) error
+	ListFileMetadata() ([]*metadata.EnhancedFileMetadata, error)
+	LinkChunks(fileID string, chunkIDs []string) (bool, error)
+}
+
+// BackfillOptions controls a chunk metadata backfill
+type BackfillOptions struct {
+	DryRun bool `json:"dry_run"` // Report what would be created without writing
+}
+
+// BackfilledChunk records one enhanced chunk entry the backfill created
+type BackfilledChunk struct {
+	ChunkID string `json:"chunk_id"`
+	Path    string `json:"path"`
+	FileID  string `json:"file_id"`
+	Index   int    `json:"index"`
+	Source  string `json:"source"`
+}
+
+// UnassociatedChunk is a stored chunk no file could be derived for
+type UnassociatedChunk struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// BackfillReport is the result of a chunk metadata backfill
+type BackfillReport struct {
+	RanAt          time.Time           `json:"ran_at"`
+	Duration       time.Duration       `json:"duration"`
+	DryRun         bool                `json:"dry_run"`
+	ChunksScanned  int                 `json:"chunks_scanned"`
+	AlreadyPresent int                 `json:"already_present"`
+	Created        []BackfilledChunk   `json:"created"`
+	FilesLinked    int                 `json:"files_linked"` // Enhanced file records that gained chunk IDs
+	Unassociated   []UnassociatedChunk `json:"unassociated"`
+	Errors         []string            `json:"errors,omitempty"`
+}
+
+// BackfillChunkMetadata creates enhanced metadata for stored chunks that lack
+// it. A chunk's file and index come from its basic chunk record when there is
+// one, otherwise from the header stored in front of it; chunks with neither
+// are reported as unassociated. Created chunks are linked into their file's
+// enhanced record when the catalog has one. The store must implement
+// storage.Lister.
+func BackfillChunkMetadata(catalog ChunkCatalog, metaStore *metadata.MetadataStore, store storage.Storage, opts BackfillOptions) (*BackfillReport, error) {
+	if catalog == nil || metaStore == nil || store == nil {
+		return nil, fmt.Errorf("chunk backfill needs enhanced metadata, a metadata store and storage")
+	}
+	lister, ok := store.(storage.Lister)
+	if !ok {
+		return nil, fmt.Errorf("storage backend %T cannot list chunks", store)
+	}
+
+	start := time.Now()
+	report := &BackfillReport{RanAt: start, DryRun: opts.DryRun, Created: []BackfilledChunk{}, Unassociated: []UnassociatedChunk{}}
+
+	storedIDs, err := lister.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %v", err)
+	}
+	sort.Strings(storedIDs)
+	chunks, err := metaStore.ListChunkMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk metadata: %v", err)
+	}
+	byPath := make(map[string]metadata.ChunkMetadata, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.Path != "" {
+			byPath[chunk.Path] = chunk
+		}
+	}
+
+	for _, path := range storedIDs {
+		report.ChunksScanned++
+		readStart := time.Now()
+		entry, reason := backfillEntry(path, byPath, store)
+		throttle.Global().Yield(context.Background(), throttle.JobBackfill, time.Since(readStart))
+		if entry == nil {
+			report.Unassociated = append(report.Unassociated, UnassociatedChunk{Path: path, Reason: reason})
+			continue
+		}
+
+		exists, err := catalog.HasChunkMetadata(entry.ChunkID)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		if exists {
+			report.AlreadyPresent++
+			continue
+		}
+		if !opts.DryRun {
+			if err := catalog.StoreChunkMetadata(entry.meta); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to store metadata for %s: %v", path, err))
+				continue
+			}
+		}
+		report.Created = append(report.Created, entry.BackfilledChunk)
+	}
+
+	if !opts.DryRun {
+		report.FilesLinked = linkBackfilledChunks(catalog, metaStore, report)
+	}
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// TrackChunkReferences brings the enhanced records of a stored file's chunks
+// in line with the reference counts the chunker keeps, creating the record a
+// backfill would for a chunk that has none
+func (dfs *DFSCore) TrackChunkReferences(hashes []string) error {
+	if dfs.OptimizedStorage == nil || dfs.metaStore == nil {
+		return nil
+	}
+	catalog := dfs.OptimizedStorage.enhancedMetadata
+
+	var errs []error
+	seen := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		chunk, err := dfs.metaStore.GetChunkMetadata(hash)
+		if err != nil {
+			continue // Not stored through the chunker
+		}
+		meta, err := catalog.LoadChunkMetadata(hash)
+		if err != nil {
+			entry, _ := backfillEntry(chunk.Path, map[string]metadata.ChunkMetadata{chunk.Path: chunk}, dfs.storage)
+			meta = entry.meta
+		}
+		// An untracked count stands for the one file that stored the chunk
+		meta.ReferenceCount = max(chunk.RefCount, 1)
+		meta.IsDeduplicated = meta.ReferenceCount > 1
+		if err := catalog.StoreChunkMetadata(meta); err != nil {
+			errs = append(errs, fmt.Errorf("failed to store metadata for chunk %s: %v", hash, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backfillCandidate is the enhanced entry derived for one stored chunk
+type backfillCandidate struct {
+	BackfilledChunk
+	meta *metadata.EnhancedChunkMetadata
+}
+
+// backfillEntry derives the enhanced entry for a stored chunk, or says why it cannot
+func backfillEntry(path string, byPath map[string]metadata.ChunkMetadata, store storage.Storage) (*backfillCandidate, string) {
+	now := time.Now()
+	meta := &metadata.EnhancedChunkMetadata{
+		Path:          path,
+		ReplicaHealth: make(map[string]string),
+		HealthStatus:  "healthy",
+		CreatedAt:     now,
+		ModifiedAt:    now,
+	}
+	source := BackfillSourceMetadata
+
+	if chunk, ok := byPath[path]; ok {
+		meta.ChunkID, meta.Hash, meta.FileID, meta.Index = chunk.Hash, chunk.Hash, chunk.FileID, chunk.Index
+		meta.Size, meta.IsCompressed, meta.ReferenceCount = chunk.Size, chunk.IsCompressed, chunk.RefCount
+		meta.IsDeduplicated = chunk.RefCount > 1
+		meta.CompressedSize, meta.CompressionRatio = chunk.CompressedSize, chunk.CompressionRatio
+	} else {
+		// Without a basic record the chunk is known by its storage ID
+		reader, err := store.Get(path)
+		if err != nil {
+			return nil, fmt.Sprintf("failed to read chunk: %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Sprintf("failed to read chunk: %v", err)
+		}
+		header, _, err := chunker.SplitChunk(data)
+		switch {
+		case err != nil:
+			return nil, fmt.Sprintf("unreadable chunk header: %v", err)
+		case header == nil || header.FileID == "":
+			return nil, "no chunk metadata and no header naming its file"
+		}
+		source = BackfillSourceHeader
+		meta.ChunkID, meta.Hash, meta.FileID, meta.Index = path, path, header.FileID, header.Index
+		meta.Size, meta.IsCompressed = int64(len(data)), header.CompressionAlgo != chunker.CompressionNone
+	}
+
+	return &backfillCandidate{
+		BackfilledChunk: BackfilledChunk{ChunkID: meta.ChunkID, Path: path, FileID: meta.FileID, Index: meta.Index, Source: source},
+		meta:            meta,
+	}, ""
+}
+
+// linkBackfilledChunks adds created chunks to their files' enhanced records,
+// in the basic file record's chunk order when there is one, and returns how
+// many files changed
+func linkBackfilledChunks(catalog ChunkCatalog, metaStore *metadata.MetadataStore, report *BackfillReport) int {
+	files, err := catalog.ListFileMetadata()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to list enhanced files: %v", err))
+		return 0
+	}
+	catalogued := make(map[string]bool, len(files))
+	for _, file := range files {
+		catalogued[file.FileID] = true
+	}
+
+	created := make(map[string][]BackfilledChunk)
+	for _, chunk := range report.Created {
+		if catalogued[chunk.FileID] {
+			created[chunk.FileID] = append(created[chunk.FileID], chunk)
+		}
+	}
+	fileIDs := make([]string, 0, len(created))
+	for fileID := range created {
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+
+	linked := 0
+	for _, fileID := range fileIDs {
+		chunks := created[fileID]
+		sort.Slice(chunks, func(i, j int) bool { return chunks[i].Index < chunks[j].Index })
+		var order []string
+		if basic, err := metaStore.GetFileMetadataByID(fileID); err == nil {
+			order = append(order, basic.ChunkHashes...)
+		}
+		for _, chunk := range chunks {
+			order = append(order, chunk.ChunkID)
+		}
+
+		changed, err := catalog.LinkChunks(fileID, order)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to link chunks of %s: %v", fileID, err))
+			continue
+		}
+		if changed {
+			linked++
+		}
+	}
+	return linked
+}
+
+// BackfillChunkMetadata runs a chunk metadata backfill over the core's storage
+func (dfs *DFSCore) BackfillChunkMetadata(opts BackfillOptions) (*BackfillReport, error) {
+	if dfs.OptimizedStorage == nil {
+		return nil, fmt.Errorf("enhanced metadata is not available")
+	}
+	report, err := BackfillChunkMetadata(dfs.OptimizedStorage, dfs.metaStore, dfs.storage, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Created) > 0 || len(report.Unassociated) > 0 {
+		dfs.logger.Infof("🧩 Chunk metadata backfill: %d of %d chunks created, %d files linked, %d unassociated",
+			len(report.Created), report.ChunksScanned, report.FilesLinked, len(report.Unassociated))
+	}
+	return report, nil
+}
@@ -0,0 +1,113 @@
+package dfs
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestBackfillChunkMetadataFromRecordsAndHeaders(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	catalog, err := metadata.NewEnhancedMetadataStore(filepath.Join(dir, "enhanced"))
+	if err != nil {
+		t.Fatalf("failed to open enhanced metadata store: %v", err)
+	}
+	defer catalog.Close()
+
+	put := func(data []byte) string {
+		t.Helper()
+		id, err := store.Put(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("failed to store chunk: %v", err)
+		}
+		return id
+	}
+
+	// Chunk 0 has a basic record; chunk 1 only the header written in front of it
+	recorded := put([]byte("first chunk payload"))
+	if err := metaStore.PutChunkMetadata(metadata.ChunkMetadata{Hash: "hash-0", Path: recorded, FileID: "file-1", Index: 0, Size: 19}); err != nil {
+		t.Fatalf("failed to seed chunk metadata: %v", err)
+	}
+	header, err := (&chunker.ChunkHeader{FileID: "file-1", Index: 1, CompressionAlgo: chunker.CompressionLZ4}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode header: %v", err)
+	}
+	headered := put(append(header, []byte("second chunk payload")...))
+	orphan := put([]byte("a chunk nothing points at"))
+
+	if err := metaStore.PutFileMetadataByID("file-1", metadata.FileMetadata{FileName: "a.txt", ChunkHashes: []string{"hash-0", headered}}); err != nil {
+		t.Fatalf("failed to seed file metadata: %v", err)
+	}
+	if err := catalog.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: "file-1", FileName: "a.txt"}); err != nil {
+		t.Fatalf("failed to seed enhanced file metadata: %v", err)
+	}
+
+	// A dry run reports without writing
+	report, err := BackfillChunkMetadata(catalog, metaStore, store, BackfillOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(report.Created) != 2 || report.FilesLinked != 0 {
+		t.Errorf("expected a dry run to plan 2 chunks and link nothing, got %d and %d", len(report.Created), report.FilesLinked)
+	}
+	if exists, _ := catalog.HasChunkMetadata("hash-0"); exists {
+		t.Errorf("expected a dry run to leave the catalog untouched")
+	}
+
+	report, err = BackfillChunkMetadata(catalog, metaStore, store, BackfillOptions{})
+	if err != nil {
+		t.Fatalf("backfill failed: %v", err)
+	}
+	if report.ChunksScanned != 3 || len(report.Created) != 2 || report.FilesLinked != 1 || len(report.Errors) > 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.Unassociated) != 1 || report.Unassociated[0].Path != orphan {
+		t.Errorf("expected only the orphan unassociated, got %+v", report.Unassociated)
+	}
+
+	fromRecord, err := catalog.GetChunkMetadata("hash-0")
+	if err != nil {
+		t.Fatalf("expected metadata for the recorded chunk: %v", err)
+	}
+	if fromRecord.Path != recorded || fromRecord.FileID != "file-1" || fromRecord.Index != 0 {
+		t.Errorf("unexpected metadata from the basic record: %+v", fromRecord)
+	}
+	fromHeader, err := catalog.GetChunkMetadata(headered)
+	if err != nil {
+		t.Fatalf("expected metadata for the headered chunk: %v", err)
+	}
+	if fromHeader.FileID != "file-1" || fromHeader.Index != 1 || !fromHeader.IsCompressed {
+		t.Errorf("unexpected metadata from the header: %+v", fromHeader)
+	}
+
+	file, err := catalog.LoadFileMetadata("file-1")
+	if err != nil {
+		t.Fatalf("failed to read enhanced file: %v", err)
+	}
+	if want := []string{"hash-0", headered}; !reflect.DeepEqual(file.ChunkHashes, want) {
+		t.Errorf("expected file linked to %v, got %v", want, file.ChunkHashes)
+	}
+
+	// A second run finds everything in place
+	report, err = BackfillChunkMetadata(catalog, metaStore, store, BackfillOptions{})
+	if err != nil {
+		t.Fatalf("second backfill failed: %v", err)
+	}
+	if len(report.Created) != 0 || report.AlreadyPresent != 2 || report.FilesLinked != 0 {
+		t.Errorf("expected a second run to change nothing, got %+v", report)
+	}
+}
@@ -0,0 +1,202 @@
+package dfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// DefaultBatchWorkers is how many files of a batch upload are distributed at once
+const DefaultBatchWorkers = 4
+
+// ErrBatchRolledBack is returned when an all-or-nothing batch had a failure
+// and the files it had already stored were removed again
+var ErrBatchRolledBack = errors.New("batch upload rolled back")
+
+// BatchOptions are the settings shared by every file of a batch upload
+type BatchOptions struct {
+	distributor.UploadOptions
+	Label     string   // Optional name of the collection the files are grouped under
+	Tags      []string // Recorded on the collection
+	CreatedBy string
+	Atomic    bool // Roll back every stored file when any file fails
+	Workers   int  // Files distributed at once, 0 for DefaultBatchWorkers
+}
+
+// BatchFileResult is the outcome for one file of a batch
+type BatchFileResult struct {
+	Name   string                `json:"name"`
+	FileID string                `json:"file_id,omitempty"`
+	File   *distributor.FileInfo `json:"file,omitempty"`
+	Error  string                `json:"error,omitempty"`
+}
+
+// BatchResult is the outcome of a batch upload
+type BatchResult struct {
+	CollectionID string            `json:"collection_id,omitempty"` // Empty when no file was kept
+	Label        string            `json:"label,omitempty"`
+	Files        []BatchFileResult `json:"files"` // In the order the files were given
+	Succeeded    int               `json:"succeeded"`
+	Failed       int               `json:"failed"`
+	RolledBack   bool              `json:"rolled_back"`
+}
+
+// UploadBatch distributes several files with one password and shared
+// settings and groups the stored files under a collection. With opts.Atomic
+// any failure removes the files this batch stored, leaving files that were
+// already stored before it untouched, and ErrBatchRolledBack is returned.
+// Otherwise the result reports each file's outcome and the collection holds
+// the files that succeeded.
+func UploadBatch(ctx context.Context, d *distributor.Distributor, metaStore *metadata.MetadataStore, store storage.Storage, paths []string, opts BatchOptions) (*BatchResult, error) {
+	sources := make([]chunker.Source, len(paths))
+	for i, path := range paths {
+		sources[i] = chunker.FileSource(path)
+	}
+	return UploadBatchSources(ctx, d, metaStore, store, sources, opts)
+}
+
+// UploadBatchSources works like UploadBatch for content that need not be
+// files on disk, such as uploads held in memory
+func UploadBatchSources(ctx context.Context, d *distributor.Distributor, metaStore *metadata.MetadataStore, store storage.Storage, sources []chunker.Source, opts BatchOptions) (*BatchResult, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no files to upload")
+	}
+	if metaStore == nil {
+		return nil, fmt.Errorf("metadata store is required for batch uploads")
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+
+	// An all-or-nothing batch stops starting new files after the first failure
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &BatchResult{Label: opts.Label, Files: make([]BatchFileResult, len(sources))}
+	existed := make([]bool, len(sources))
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < workers && w < len(sources); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entry := &result.Files[i]
+				entry.Name = sources[i].Name()
+				if err := ctx.Err(); err != nil {
+					entry.Error = fmt.Sprintf("not attempted: %v", err)
+					continue
+				}
+
+				// Files already stored before this batch are never rolled back
+				if fileID, err := chunker.HashSource(sources[i]); err == nil {
+					_, lookupErr := metaStore.GetFileMetadataByID(fileID)
+					existed[i] = lookupErr == nil
+				}
+				file, err := d.DistributeSourceWithOptions(ctx, sources[i], opts.UploadOptions)
+				if err != nil {
+					entry.Error = err.Error()
+					if opts.Atomic {
+						cancel()
+					}
+					continue
+				}
+				entry.FileID = file.ID
+				entry.File = file
+			}
+		}()
+	}
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	fileIDs := make([]string, 0, len(sources))
+	seen := make(map[string]bool)
+	for _, entry := range result.Files {
+		if entry.Error != "" {
+			result.Failed++
+			continue
+		}
+		result.Succeeded++
+		if !seen[entry.FileID] {
+			seen[entry.FileID] = true
+			fileIDs = append(fileIDs, entry.FileID)
+		}
+	}
+
+	if result.Failed > 0 && opts.Atomic {
+		if err := rollbackBatch(d, metaStore, store, result, existed); err != nil {
+			return result, fmt.Errorf("%w, but cleanup was incomplete: %v", ErrBatchRolledBack, err)
+		}
+		return result, ErrBatchRolledBack
+	}
+	if result.Succeeded == 0 {
+		return result, fmt.Errorf("no file of the batch was stored")
+	}
+
+	collection := metadata.Collection{
+		ID:         uuid.New().String(),
+		Label:      opts.Label,
+		FileIDs:    fileIDs,
+		Tags:       opts.Tags,
+		Replicas:   opts.Replicas,
+		Redundancy: opts.Redundancy,
+		CreatedBy:  opts.CreatedBy,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := metaStore.PutCollection(collection); err != nil {
+		return result, fmt.Errorf("failed to store collection: %v", err)
+	}
+	result.CollectionID = collection.ID
+	return result, nil
+}
+
+// rollbackBatch removes the files a failed all-or-nothing batch stored.
+// Chunks already replicated to peers stay there until their own cleanup.
+func rollbackBatch(d *distributor.Distributor, metaStore *metadata.MetadataStore, store storage.Storage, result *BatchResult, existed []bool) error {
+	report := &ExpiryReport{}
+	removed := make(map[string]bool)
+	for i := range result.Files {
+		entry := &result.Files[i]
+		if entry.Error != "" {
+			continue
+		}
+		fileID := entry.FileID
+		entry.File = nil
+		entry.Error = "rolled back"
+		if existed[i] || removed[fileID] {
+			continue
+		}
+		removed[fileID] = true
+
+		file, err := metaStore.GetFileMetadataByID(fileID)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to load %s: %v", fileID, err))
+			continue
+		}
+		if err := purgeFile(metaStore, store, fileID, file, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to remove %s: %v", fileID, err))
+			continue
+		}
+		d.ForgetFile(fileID)
+	}
+
+	result.RolledBack = true
+	result.Failed += result.Succeeded
+	result.Succeeded = 0
+	if len(report.Errors) > 0 {
+		return errors.New(report.Errors[0])
+	}
+	return nil
+}
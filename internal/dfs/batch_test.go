@@ -0,0 +1,160 @@
+package dfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func newBatchFixture(t *testing.T) (*distributor.Distributor, *metadata.MetadataStore, *storage.LocalStorage, string) {
+	t.Helper()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() { metaStore.Close() })
+	d := distributor.NewDistributor(p2p.NewNetwork("127.0.0.1", 0), store, metaStore)
+	return d, metaStore, store, dir
+}
+
+func writeBatchFiles(t *testing.T, dir string, names ...string) []string {
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		content := bytes.Repeat([]byte(fmt.Sprintf("%s contents ", name)), int(chunker.MinChunkSize)/16)
+		if err := os.WriteFile(paths[i], content, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return paths
+}
+
+func TestUploadBatchGroupsFilesUnderCollection(t *testing.T) {
+	d, metaStore, store, dir := newBatchFixture(t)
+	paths := writeBatchFiles(t, dir, "report.pdf", "data.csv", "notes.txt")
+
+	opts := BatchOptions{
+		UploadOptions: distributor.UploadOptions{Password: "shared secret", Replicas: 1},
+		Label:         "quarterly",
+		Tags:          []string{"finance", "q3"},
+		CreatedBy:     "user-1",
+	}
+	result, err := UploadBatch(context.Background(), d, metaStore, store, paths, opts)
+	if err != nil {
+		t.Fatalf("UploadBatch failed: %v", err)
+	}
+	if result.Succeeded != 3 || result.Failed != 0 || result.CollectionID == "" {
+		t.Fatalf("expected 3 files in a collection, got %+v", result)
+	}
+
+	collection, err := metaStore.GetCollection(result.CollectionID)
+	if err != nil {
+		t.Fatalf("collection was not stored: %v", err)
+	}
+	if collection.Label != "quarterly" || len(collection.Tags) != 2 || collection.Replicas != 1 || collection.CreatedBy != "user-1" {
+		t.Errorf("collection lost the shared settings: %+v", collection)
+	}
+	if len(collection.FileIDs) != 3 {
+		t.Fatalf("expected 3 files in the collection, got %v", collection.FileIDs)
+	}
+
+	// Every file got the shared replica count and decrypts with the one password
+	for i, entry := range result.Files {
+		if entry.Name != filepath.Base(paths[i]) || entry.FileID != collection.FileIDs[i] {
+			t.Errorf("result %d does not match its input: %+v", i, entry)
+		}
+		if entry.File.Replicas != 1 {
+			t.Errorf("%s: expected 1 replica, got %d", entry.Name, entry.File.Replicas)
+		}
+		out := filepath.Join(dir, "out-"+entry.Name)
+		if err := d.ReassembleFile(entry.FileID, out, "shared secret"); err != nil {
+			t.Errorf("%s: reassembly with the shared password failed: %v", entry.Name, err)
+		}
+	}
+}
+
+func TestUploadBatchAtomicRollsBack(t *testing.T) {
+	d, metaStore, store, dir := newBatchFixture(t)
+
+	// A file stored before the batch must survive its rollback
+	earlier := writeBatchFiles(t, dir, "earlier.txt")
+	stored, err := d.DistributeFile(earlier[0], "pw")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	before := make(map[string]bool)
+	if blobs, err := store.List(); err == nil {
+		for _, blob := range blobs {
+			before[blob] = true
+		}
+	}
+
+	paths := append(writeBatchFiles(t, dir, "a.txt", "b.txt"), earlier[0], filepath.Join(dir, "missing.txt"))
+	opts := BatchOptions{UploadOptions: distributor.UploadOptions{Password: "pw", Replicas: 1}, Atomic: true, Workers: 1}
+	result, err := UploadBatch(context.Background(), d, metaStore, store, paths, opts)
+	if !errors.Is(err, ErrBatchRolledBack) {
+		t.Fatalf("expected the batch to roll back, got %v", err)
+	}
+	if !result.RolledBack || result.Succeeded != 0 || result.CollectionID != "" {
+		t.Errorf("unexpected result after rollback: %+v", result)
+	}
+
+	files, _ := metaStore.ListFileMetadataByID()
+	if len(files) != 1 {
+		t.Errorf("expected only the earlier file left, got %d files", len(files))
+	}
+	// Uploading the earlier file again replaces its chunks, so only blobs new
+	// to the store and referenced by nothing count as left behind
+	referenced, _ := chunker.ReferencedChunkPaths(metaStore)
+	blobs, _ := store.List()
+	for _, blob := range blobs {
+		if !referenced[blob] && !before[blob] {
+			t.Errorf("chunk %s of a rolled back file was left behind", blob)
+		}
+	}
+	if err := d.ReassembleFile(stored.ID, filepath.Join(dir, "earlier.out"), "pw"); err != nil {
+		t.Errorf("the earlier file no longer reassembles: %v", err)
+	}
+	if len(d.GetAllFiles()) != 1 {
+		t.Errorf("expected the distributor to forget the rolled back files")
+	}
+	if collections, _ := metaStore.ListCollections(); len(collections) != 0 {
+		t.Errorf("a rolled back batch must not leave a collection")
+	}
+}
+
+func TestUploadBatchReportsPerFileOutcomes(t *testing.T) {
+	d, metaStore, store, dir := newBatchFixture(t)
+	paths := append(writeBatchFiles(t, dir, "a.txt"), filepath.Join(dir, "missing.txt"))
+
+	result, err := UploadBatch(context.Background(), d, metaStore, store, paths, BatchOptions{UploadOptions: distributor.UploadOptions{Password: "pw"}})
+	if err != nil {
+		t.Fatalf("a partial batch should still succeed: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 1 || result.Files[1].Error == "" {
+		t.Fatalf("expected one success and one failure, got %+v", result)
+	}
+	collection, _ := metaStore.GetCollection(result.CollectionID)
+	if len(collection.FileIDs) != 1 || collection.FileIDs[0] != result.Files[0].FileID {
+		t.Errorf("expected the collection to hold the stored file, got %+v", collection)
+	}
+}
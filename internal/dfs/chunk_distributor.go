@@ -6,6 +6,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/jaywantadh/DisktroByte/internal/events"
 	"github.com/jaywantadh/DisktroByte/internal/p2p"
 	"github.com/sirupsen/logrus"
 )
@@ -25,16 +26,19 @@ type NodeScore struct {
 type DistributionStrategy string
 
 const (
-	StrategyBalanced    DistributionStrategy = "balanced"     // Balance storage and performance
-	StrategyPerformance DistributionStrategy = "performance" // Prioritize fast nodes
-	StrategyReliability DistributionStrategy = "reliability" // Prioritize reliable nodes
-	StrategyCapacity    DistributionStrategy = "capacity"    // Prioritize high-capacity nodes
+	StrategyBalanced       DistributionStrategy = "balanced"        // Balance storage and performance
+	StrategyPerformance    DistributionStrategy = "performance"     // Prioritize fast nodes
+	StrategyReliability    DistributionStrategy = "reliability"     // Prioritize reliable nodes
+	StrategyCapacity       DistributionStrategy = "capacity"        // Prioritize high-capacity nodes
+	StrategyConsistentHash DistributionStrategy = "consistent_hash" // Deterministic placement on a hash ring
+	StrategyZoneAware      DistributionStrategy = "zone_aware"      // Balanced, with replicas spread across zones and racks
 )
 
 // ChunkDistributor handles intelligent distribution of chunks across nodes
 type ChunkDistributor struct {
 	dfsCore  *DFSCore
 	strategy DistributionStrategy
+	ring     *HashRing
 	logger   *logrus.Logger
 }
 
@@ -43,15 +47,83 @@ func NewChunkDistributor(dfsCore *DFSCore, strategy DistributionStrategy) *Chunk
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 
+	virtualNodes := DefaultVirtualNodes
+	if dfsCore != nil && dfsCore.config != nil {
+		virtualNodes = dfsCore.config.VirtualNodes
+	}
+
 	return &ChunkDistributor{
 		dfsCore:  dfsCore,
 		strategy: strategy,
+		ring:     NewHashRing(virtualNodes),
 		logger:   logger,
 	}
 }
 
+// ParseDistributionStrategy maps a configured strategy name to a DistributionStrategy
+func ParseDistributionStrategy(name string) (DistributionStrategy, error) {
+	switch DistributionStrategy(name) {
+	case "":
+		return StrategyBalanced, nil
+	case StrategyBalanced, StrategyPerformance, StrategyReliability, StrategyCapacity, StrategyConsistentHash, StrategyZoneAware:
+		return DistributionStrategy(name), nil
+	default:
+		return StrategyBalanced, fmt.Errorf("unknown distribution strategy %q", name)
+	}
+}
+
+// Ring returns the consistent-hash ring used by StrategyConsistentHash
+func (cd *ChunkDistributor) Ring() *HashRing {
+	return cd.ring
+}
+
+// RingState syncs the ring with the healthy nodes and returns a snapshot for debugging
+func (cd *ChunkDistributor) RingState() RingState {
+	cd.syncRing(cd.dfsCore.getHealthyNodes())
+	return cd.ring.State()
+}
+
+// syncRing makes the ring membership match the given nodes
+func (cd *ChunkDistributor) syncRing(nodes []*p2p.Node) {
+	nodeIDs := make([]string, len(nodes))
+	for i, node := range nodes {
+		nodeIDs[i] = node.ID
+	}
+	cd.ring.SetNodes(nodeIDs)
+}
+
+// selectRingNodes places replicas on the next distinct healthy nodes clockwise from the chunk hash
+func (cd *ChunkDistributor) selectRingNodes(chunkID string, replicaCount int, excludeNodes []string) ([]*p2p.Node, error) {
+	healthy := cd.dfsCore.getHealthyNodes()
+	cd.syncRing(healthy)
+
+	byID := make(map[string]*p2p.Node, len(healthy))
+	for _, node := range healthy {
+		byID[node.ID] = node
+	}
+	exclude := make(map[string]bool, len(excludeNodes))
+	for _, nodeID := range excludeNodes {
+		exclude[nodeID] = true
+	}
+
+	nodeIDs := cd.ring.LookupExcluding(chunkID, replicaCount, exclude)
+	if len(nodeIDs) < replicaCount {
+		return nil, fmt.Errorf("insufficient nodes available: need %d, have %d", replicaCount, len(nodeIDs))
+	}
+
+	selected := make([]*p2p.Node, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		selected[i] = byID[nodeID]
+	}
+	return selected, nil
+}
+
 // SelectOptimalNodes selects the best nodes for storing chunk replicas
 func (cd *ChunkDistributor) SelectOptimalNodes(chunkID string, replicaCount int, excludeNodes []string) ([]*p2p.Node, error) {
+	if cd.strategy == StrategyConsistentHash {
+		return cd.selectRingNodes(chunkID, replicaCount, excludeNodes)
+	}
+
 	availableNodes := cd.getAvailableNodes(excludeNodes)
 	
 	if len(availableNodes) < replicaCount {
@@ -67,7 +139,12 @@ func (cd *ChunkDistributor) SelectOptimalNodes(chunkID string, replicaCount int,
 	})
 
 	// Select top nodes, ensuring geographic/rack diversity if possible
-	selectedNodes := cd.selectDiverseNodes(nodeScores, replicaCount)
+	var selectedNodes []*p2p.Node
+	if cd.strategy == StrategyZoneAware {
+		selectedNodes = cd.selectZoneSpreadNodes(nodeScores, replicaCount)
+	} else {
+		selectedNodes = cd.selectDiverseNodes(nodeScores, replicaCount)
+	}
 
 	cd.logger.Infof("🎯 Selected %d optimal nodes for chunk %s using %s strategy", 
 		len(selectedNodes), chunkID, cd.strategy)
@@ -305,11 +382,13 @@ func (cd *ChunkDistributor) RebalanceChunks() error {
 
 	allReplicas := cd.dfsCore.GetAllReplicaInfo()
 	rebalanceCount := 0
+	failed := 0
 
 	for chunkID, replica := range allReplicas {
 		if cd.shouldRebalanceChunk(replica) {
 			if err := cd.rebalanceChunk(chunkID, replica); err != nil {
 				cd.logger.Errorf("❌ Failed to rebalance chunk %s: %v", chunkID, err)
+				failed++
 			} else {
 				rebalanceCount++
 			}
@@ -317,6 +396,9 @@ func (cd *ChunkDistributor) RebalanceChunks() error {
 	}
 
 	cd.logger.Infof("✅ Rebalancing completed: %d chunks rebalanced", rebalanceCount)
+	cd.dfsCore.events.Publish(events.TypeRebalance, "dfs",
+		fmt.Sprintf("Rebalancing completed: %d chunks rebalanced, %d failed", rebalanceCount, failed),
+		map[string]interface{}{"rebalanced": rebalanceCount, "failed": failed, "checked": len(allReplicas)})
 	return nil
 }
 
@@ -380,16 +462,29 @@ func (cd *ChunkDistributor) rebalanceChunk(chunkID string, replica *ReplicaInfo)
 		}
 	}
 
-	// Execute the rebalancing
+	// Execute the rebalancing. A replica is only removed once a new one has been
+	// read back from its destination, so an interrupted pass leaves the chunk
+	// over-replicated for the next pass to finish, never under-replicated.
+	confirmed := 0
 	for _, node := range nodesToAdd {
 		if err := cd.dfsCore.createReplicaOnNode(chunkID, node.ID); err != nil {
 			cd.logger.Errorf("❌ Failed to create replica on node %s: %v", node.ID, err)
-		} else {
-			cd.logger.Infof("✅ Created new replica of chunk %s on node %s", chunkID, node.ID)
+			continue
 		}
+		if err := cd.dfsCore.verifyReplica(chunkID, node.ID); err != nil {
+			cd.logger.Warnf("⚠️ New replica of chunk %s on node %s not confirmed: %v", chunkID, node.ID, err)
+			cd.removeReplicaFromNode(chunkID, node.ID)
+			continue
+		}
+		confirmed++
+		cd.logger.Infof("✅ Created new replica of chunk %s on node %s", chunkID, node.ID)
 	}
 
-	for _, nodeID := range nodesToRemove {
+	for i, nodeID := range nodesToRemove {
+		if i >= confirmed {
+			cd.logger.Infof("⏸️ Keeping replica of chunk %s on node %s until its replacement is confirmed", chunkID, nodeID)
+			continue
+		}
 		cd.removeReplicaFromNode(chunkID, nodeID)
 		cd.logger.Infof("🗑️ Removed replica of chunk %s from node %s", chunkID, nodeID)
 	}
@@ -445,7 +540,9 @@ func (cd *ChunkDistributor) removeReplicaFromNode(chunkID, nodeID string) {
 	// This would involve deleting the chunk from the node's storage
 	
 	// For now, just update the replica info
-	replica := cd.dfsCore.GetReplicaInfo(chunkID)
+	cd.dfsCore.replicaMu.Lock()
+	defer cd.dfsCore.replicaMu.Unlock()
+	replica := cd.dfsCore.replicaInfo[chunkID]
 	if replica != nil {
 		updatedReplicas := make([]string, 0)
 		for _, replicaNodeID := range replica.CurrentReplicas {
@@ -455,6 +552,7 @@ func (cd *ChunkDistributor) removeReplicaFromNode(chunkID, nodeID string) {
 		}
 		replica.CurrentReplicas = updatedReplicas
 		delete(replica.Health, nodeID)
+		cd.dfsCore.retally(replica)
 	}
 }
 
@@ -499,5 +597,9 @@ func (cd *ChunkDistributor) GetDistributionStats() map[string]interface{} {
 		stats["load_balance_score"] = math.Max(0.0, 1.0 - variance)
 	}
 
+	if cd.strategy == StrategyConsistentHash {
+		stats["ring"] = cd.RingState()
+	}
+
 	return stats
 }
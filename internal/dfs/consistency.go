@@ -0,0 +1,316 @@
+package dfs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// DefaultOrphanGracePeriod protects chunks written by uploads whose metadata is not stored yet
+const DefaultOrphanGracePeriod = time.Hour
+
+// ConsistencyOptions controls a consistency check
+type ConsistencyOptions struct {
+	Repair            bool          `json:"repair"`              // Delete orphans, flag degraded files and fix reference counts
+	OrphanGracePeriod time.Duration `json:"orphan_grace_period"` // Newer orphans are reported but kept; 0 uses the default, negative disables
+}
+
+// DanglingReference is metadata pointing at a chunk that cannot be found
+type DanglingReference struct {
+	FileID    string `json:"file_id"`
+	ChunkHash string `json:"chunk_hash"`
+	Path      string `json:"path,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// RefCountMismatch is a chunk whose recorded reference count disagrees with the files using it
+type RefCountMismatch struct {
+	ChunkHash string `json:"chunk_hash"`
+	Recorded  int    `json:"recorded"`
+	Actual    int    `json:"actual"`
+}
+
+// ConsistencyReport is the result of cross-referencing metadata against storage
+type ConsistencyReport struct {
+	CheckedAt          time.Time           `json:"checked_at"`
+	Duration           time.Duration       `json:"duration"`
+	Repair             bool                `json:"repair"`
+	FilesChecked       int                 `json:"files_checked"`
+	ChunksChecked      int                 `json:"chunks_checked"`
+	StoredChunks       int                 `json:"stored_chunks"`
+	OrphanedChunks     []string            `json:"orphaned_chunks"`
+	DanglingReferences []DanglingReference `json:"dangling_references"`
+	DegradedFiles      []string            `json:"degraded_files"`
+	RefCountMismatches []RefCountMismatch  `json:"ref_count_mismatches"`
+	OrphansDeleted     int                 `json:"orphans_deleted"`
+	FilesFlagged       int                 `json:"files_flagged"`
+	RefCountsFixed     int                 `json:"ref_counts_fixed"`
+	Errors             []string            `json:"errors,omitempty"`
+}
+
+// Consistent reports whether the check found no problems
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.OrphanedChunks) == 0 && len(r.DanglingReferences) == 0 && len(r.RefCountMismatches) == 0
+}
+
+// CheckConsistency cross-references the core's metadata against its storage
+func (dfs *DFSCore) CheckConsistency(opts ConsistencyOptions) (*ConsistencyReport, error) {
+	report, err := CheckConsistency(dfs.metaStore, dfs.storage, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mirror degraded status into the enhanced metadata used by search
+	if opts.Repair && dfs.OptimizedStorage != nil {
+		for _, fileID := range report.DegradedFiles {
+			meta, err := dfs.OptimizedStorage.LoadFileMetadata(fileID)
+			if err != nil {
+				continue
+			}
+			meta.HealthStatus = metadata.HealthDegraded
+			if err := dfs.OptimizedStorage.StoreFileMetadata(meta); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to flag enhanced metadata for %s: %v", fileID, err))
+			}
+		}
+	}
+
+	dfs.logger.Infof("🩺 Consistency check: %d orphaned, %d dangling, %d ref count mismatches",
+		len(report.OrphanedChunks), len(report.DanglingReferences), len(report.RefCountMismatches))
+	if !report.Consistent() {
+		dfs.events.Publish(events.TypeScrub, "dfs", "Consistency check found problems", map[string]interface{}{
+			"orphaned":   len(report.OrphanedChunks),
+			"dangling":   len(report.DanglingReferences),
+			"mismatches": len(report.RefCountMismatches),
+			"degraded":   len(report.DegradedFiles),
+			"repaired":   opts.Repair,
+		})
+	}
+	return report, nil
+}
+
+// CheckConsistency compares every chunk referenced in metadata with the chunks
+// in storage. It reports orphaned chunks (stored but unreferenced), dangling
+// references (metadata pointing at missing chunks) and reference counts that
+// disagree with the files using a chunk. The store must implement
+// storage.Lister; repairing orphans also needs storage.Deleter.
+func CheckConsistency(metaStore *metadata.MetadataStore, store storage.Storage, opts ConsistencyOptions) (*ConsistencyReport, error) {
+	if metaStore == nil || store == nil {
+		return nil, fmt.Errorf("consistency check needs both a metadata store and storage")
+	}
+	lister, ok := store.(storage.Lister)
+	if !ok {
+		return nil, fmt.Errorf("storage backend %T cannot list chunks", store)
+	}
+	if opts.OrphanGracePeriod == 0 {
+		opts.OrphanGracePeriod = DefaultOrphanGracePeriod
+	}
+
+	start := time.Now()
+	report := &ConsistencyReport{
+		CheckedAt:          start,
+		Repair:             opts.Repair,
+		OrphanedChunks:     make([]string, 0),
+		DanglingReferences: make([]DanglingReference, 0),
+		DegradedFiles:      make([]string, 0),
+		RefCountMismatches: make([]RefCountMismatch, 0),
+	}
+
+	storedIDs, err := lister.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %v", err)
+	}
+	stored := make(map[string]bool, len(storedIDs))
+	for _, id := range storedIDs {
+		stored[id] = true
+	}
+	report.StoredChunks = len(storedIDs)
+
+	chunks, err := metaStore.ListChunkMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk metadata: %v", err)
+	}
+	files, err := metaStore.ListFileMetadataByID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file metadata: %v", err)
+	}
+	refs, err := metaStore.ListChunkReferences()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk references: %v", err)
+	}
+	versions, err := metaStore.ListAllVersions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file versions: %v", err)
+	}
+	report.ChunksChecked = len(chunks)
+	report.FilesChecked = len(files)
+
+	chunksByHash := make(map[string]metadata.ChunkMetadata, len(chunks))
+	referencedPaths := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		chunksByHash[chunk.Hash] = chunk
+		if chunk.Path != "" {
+			referencedPaths[chunk.Path] = true
+		}
+	}
+	// A file using a chunk another file stored first may have its own copy
+	for _, ref := range refs {
+		if ref.Path != "" {
+			referencedPaths[ref.Path] = true
+		}
+	}
+	// Earlier versions of updated files keep their own chunks
+	for _, version := range versions {
+		for _, chunk := range version.Chunks {
+			if chunk.Path != "" {
+				referencedPaths[chunk.Path] = true
+			}
+		}
+	}
+
+	// Count how many files use each chunk and find references that lead nowhere
+	references := make(map[string]int)
+	degraded := make(map[string]bool)
+	for fileID, file := range files {
+		for _, hash := range file.ChunkHashes {
+			references[hash]++
+
+			chunk, exists := chunksByHash[hash]
+			switch {
+			case !exists:
+				report.DanglingReferences = append(report.DanglingReferences, DanglingReference{
+					FileID: fileID, ChunkHash: hash, Reason: "no chunk metadata",
+				})
+				degraded[fileID] = true
+			case chunk.Path == "" || !stored[chunk.Path]:
+				report.DanglingReferences = append(report.DanglingReferences, DanglingReference{
+					FileID: fileID, ChunkHash: hash, Path: chunk.Path, Reason: "chunk missing from storage",
+				})
+				degraded[fileID] = true
+			}
+		}
+	}
+
+	// Chunk records whose owning file is gone still need their data checked
+	for _, chunk := range chunks {
+		if _, owned := files[chunk.FileID]; owned {
+			continue
+		}
+		if chunk.Path == "" || !stored[chunk.Path] {
+			report.DanglingReferences = append(report.DanglingReferences, DanglingReference{
+				FileID: chunk.FileID, ChunkHash: chunk.Hash, Path: chunk.Path, Reason: "chunk missing from storage",
+			})
+		}
+	}
+
+	for _, chunk := range chunks {
+		if chunk.RefCount > 0 && chunk.RefCount != references[chunk.Hash] {
+			report.RefCountMismatches = append(report.RefCountMismatches, RefCountMismatch{
+				ChunkHash: chunk.Hash, Recorded: chunk.RefCount, Actual: references[chunk.Hash],
+			})
+		}
+	}
+
+	for _, id := range storedIDs {
+		if !referencedPaths[id] {
+			report.OrphanedChunks = append(report.OrphanedChunks, id)
+		}
+	}
+
+	for fileID := range degraded {
+		report.DegradedFiles = append(report.DegradedFiles, fileID)
+	}
+	sort.Strings(report.OrphanedChunks)
+	sort.Strings(report.DegradedFiles)
+	sort.Slice(report.DanglingReferences, func(i, j int) bool {
+		a, b := report.DanglingReferences[i], report.DanglingReferences[j]
+		if a.FileID != b.FileID {
+			return a.FileID < b.FileID
+		}
+		return a.ChunkHash < b.ChunkHash
+	})
+	sort.Slice(report.RefCountMismatches, func(i, j int) bool {
+		return report.RefCountMismatches[i].ChunkHash < report.RefCountMismatches[j].ChunkHash
+	})
+
+	if opts.Repair {
+		repairConsistency(report, metaStore, store, files, chunksByHash, opts.OrphanGracePeriod)
+	}
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// repairConsistency deletes old orphans, flags degraded files and rewrites reference counts
+func repairConsistency(report *ConsistencyReport, metaStore *metadata.MetadataStore, store storage.Storage,
+	files map[string]metadata.FileMetadata, chunksByHash map[string]metadata.ChunkMetadata, grace time.Duration) {
+
+	if deleter, ok := store.(storage.Deleter); ok {
+		// An upload may have started referencing an orphan since the scan
+		barrier := metaStore.ChunkBarrier()
+		claimed := barrier.BeginDelete(report.OrphanedChunks...)
+		defer barrier.EndDelete(claimed...)
+		referenced, err := chunker.ReferencedChunkPaths(metaStore)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			claimed = nil
+		}
+		for _, id := range claimed {
+			if referenced[id] || recentlyWritten(store, id, grace) {
+				continue
+			}
+			if err := deleter.Delete(id); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to delete orphan %s: %v", id, err))
+				continue
+			}
+			report.OrphansDeleted++
+		}
+	} else if len(report.OrphanedChunks) > 0 {
+		report.Errors = append(report.Errors, fmt.Sprintf("storage backend %T cannot delete orphans", store))
+	}
+
+	for _, fileID := range report.DegradedFiles {
+		file := files[fileID]
+		if file.Health == metadata.HealthDegraded {
+			continue
+		}
+		file.Health = metadata.HealthDegraded
+		if err := metaStore.PutFileMetadataByID(fileID, file); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to flag %s as degraded: %v", fileID, err))
+			continue
+		}
+		report.FilesFlagged++
+	}
+
+	for _, mismatch := range report.RefCountMismatches {
+		chunk := chunksByHash[mismatch.ChunkHash]
+		chunk.RefCount = mismatch.Actual
+		if err := metaStore.PutChunkMetadata(chunk); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to fix ref count of %s: %v", mismatch.ChunkHash, err))
+			continue
+		}
+		report.RefCountsFixed++
+	}
+}
+
+// recentlyWritten reports whether a chunk is younger than the grace period.
+// A chunk whose age the backend cannot report counts as recent, so it is
+// never removed early.
+func recentlyWritten(store storage.Storage, id string, grace time.Duration) bool {
+	if grace <= 0 {
+		return false
+	}
+	modTimer, ok := store.(storage.ModTimer)
+	if !ok {
+		return true
+	}
+	modified, err := modTimer.ModTime(id)
+	if err != nil {
+		return true
+	}
+	return time.Since(modified) < grace
+}
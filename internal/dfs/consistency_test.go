@@ -0,0 +1,172 @@
+package dfs
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/storage/s3fake"
+)
+
+func TestCheckConsistencyDetectsAndRepairs(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	put := func(data string) string {
+		id, err := store.Put(bytes.NewReader([]byte(data)))
+		if err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		return id
+	}
+
+	// file-a is healthy apart from a chunk whose data has vanished
+	metaStore.PutChunkMetadata(metadata.ChunkMetadata{FileID: "file-a", Index: 0, Hash: "a0", Path: put("a zero")})
+	metaStore.PutChunkMetadata(metadata.ChunkMetadata{FileID: "file-a", Index: 1, Hash: "a1", Path: "deadbeef"})
+	metaStore.PutFileMetadataByID("file-a", metadata.FileMetadata{FileName: "a.txt", ChunkHashes: []string{"a0", "a1"}})
+
+	// file-b and file-c share a chunk whose reference count was never bumped
+	metaStore.PutChunkMetadata(metadata.ChunkMetadata{FileID: "file-b", Hash: "shared", Path: put("shared"), RefCount: 1})
+	metaStore.PutFileMetadataByID("file-b", metadata.FileMetadata{FileName: "b.txt", ChunkHashes: []string{"shared"}})
+	metaStore.PutFileMetadataByID("file-c", metadata.FileMetadata{FileName: "c.txt", ChunkHashes: []string{"shared"}})
+
+	// Stored data nothing refers to
+	orphan := put("orphaned data")
+
+	report, err := CheckConsistency(metaStore, store, ConsistencyOptions{})
+	if err != nil {
+		t.Fatalf("CheckConsistency failed: %v", err)
+	}
+	if report.Consistent() {
+		t.Fatalf("expected inconsistencies to be found")
+	}
+	if len(report.OrphanedChunks) != 1 || report.OrphanedChunks[0] != orphan {
+		t.Errorf("expected orphan %s, got %v", orphan, report.OrphanedChunks)
+	}
+	if len(report.DanglingReferences) != 1 || report.DanglingReferences[0].ChunkHash != "a1" {
+		t.Errorf("expected dangling reference to a1, got %+v", report.DanglingReferences)
+	}
+	if len(report.DegradedFiles) != 1 || report.DegradedFiles[0] != "file-a" {
+		t.Errorf("expected file-a degraded, got %v", report.DegradedFiles)
+	}
+	if len(report.RefCountMismatches) != 1 || report.RefCountMismatches[0].Recorded != 1 || report.RefCountMismatches[0].Actual != 2 {
+		t.Errorf("expected shared chunk recorded 1 but used 2, got %+v", report.RefCountMismatches)
+	}
+	if report.OrphansDeleted != 0 || report.FilesFlagged != 0 {
+		t.Errorf("check without repair must not change anything, got %+v", report)
+	}
+
+	// The grace period keeps freshly written orphans, which may belong to an upload in progress
+	report, err = CheckConsistency(metaStore, store, ConsistencyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("repair failed: %v", err)
+	}
+	if report.OrphansDeleted != 0 || report.FilesFlagged != 1 || report.RefCountsFixed != 1 || len(report.Errors) != 0 {
+		t.Errorf("unexpected repair results: %+v", report)
+	}
+	if _, err := store.Get(orphan); err != nil {
+		t.Errorf("recent orphan should survive the grace period: %v", err)
+	}
+
+	report, _ = CheckConsistency(metaStore, store, ConsistencyOptions{Repair: true, OrphanGracePeriod: -1})
+	if report.OrphansDeleted != 1 || report.FilesFlagged != 0 {
+		t.Errorf("expected only the orphan to be repaired on the second pass, got %+v", report)
+	}
+	if _, err := store.Get(orphan); err == nil {
+		t.Errorf("orphan should have been deleted")
+	}
+	if file, _ := metaStore.GetFileMetadataByID("file-a"); file.Health != metadata.HealthDegraded {
+		t.Errorf("expected file-a to be flagged degraded, got %q", file.Health)
+	}
+	if chunk, _ := metaStore.GetChunkMetadata("shared"); chunk.RefCount != 2 {
+		t.Errorf("expected shared ref count fixed to 2, got %d", chunk.RefCount)
+	}
+
+	// Only the unrepairable dangling reference remains
+	report, _ = CheckConsistency(metaStore, store, ConsistencyOptions{})
+	if len(report.OrphanedChunks) != 0 || len(report.RefCountMismatches) != 0 || len(report.DanglingReferences) != 1 {
+		t.Errorf("expected only the dangling reference after repair, got %+v", report)
+	}
+}
+
+func TestCheckConsistencyKeepsChunksOfEarlierVersions(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	v1, _ := store.Put(bytes.NewReader([]byte("first draft")))
+	v2, _ := store.Put(bytes.NewReader([]byte("second draft")))
+
+	// Only version 1 still refers to its chunk
+	metaStore.PutChunkMetadata(metadata.ChunkMetadata{FileID: "content-2", Hash: "h2", Path: v2})
+	metaStore.PutFileMetadataByID("doc", metadata.FileMetadata{FileName: "doc.txt", ChunkHashes: []string{"h2"}, Version: 2, ContentID: "content-2"})
+	metaStore.PutVersion(metadata.VersionRecord{
+		FileID: "doc", Version: 1, ContentID: "doc",
+		Chunks: []metadata.ChunkMetadata{{FileID: "doc", Hash: "h1", Path: v1}},
+	})
+
+	report, err := CheckConsistency(metaStore, store, ConsistencyOptions{Repair: true, OrphanGracePeriod: -1})
+	if err != nil {
+		t.Fatalf("CheckConsistency failed: %v", err)
+	}
+	if len(report.OrphanedChunks) != 0 || report.OrphansDeleted != 0 {
+		t.Errorf("chunks of version 1 must not be treated as orphans: %+v", report)
+	}
+	if _, err := store.Get(v1); err != nil {
+		t.Errorf("version 1 chunk was deleted: %v", err)
+	}
+}
+
+func TestCheckConsistencyGracePeriodOnS3(t *testing.T) {
+	fake := s3fake.New(t)
+	store, err := storage.NewS3StorageWithOptions(storage.S3Options{Endpoint: fake.Endpoint(), Bucket: "chunks",
+		AccessKey: s3fake.AccessKey, SecretKey: s3fake.SecretKey})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	orphan, err := store.Put(bytes.NewReader([]byte("orphaned data")))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	report, err := CheckConsistency(metaStore, store, ConsistencyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("repair failed: %v", err)
+	}
+	if report.OrphansDeleted != 0 {
+		t.Errorf("an orphan written just now should survive the grace period: %+v", report)
+	}
+
+	// The object's Last-Modified time is what ages it
+	fake.SetModified("chunks", orphan, time.Now().Add(-2*DefaultOrphanGracePeriod))
+	report, _ = CheckConsistency(metaStore, store, ConsistencyOptions{Repair: true})
+	if report.OrphansDeleted != 1 {
+		t.Errorf("expected the aged orphan deleted, got %+v", report)
+	}
+	if exists, _ := store.Exists(orphan); exists {
+		t.Errorf("orphan should have been deleted")
+	}
+}
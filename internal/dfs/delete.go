@@ -0,0 +1,120 @@
+package dfs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// ErrFileNotFound is returned when deleting a file the catalog does not know
+var ErrFileNotFound = errors.New("file not found")
+
+// DeletionReport is the result of deleting a file
+type DeletionReport struct {
+	FileID             string     `json:"file_id"`
+	Hard               bool       `json:"hard"`
+	DeletedAt          time.Time  `json:"deleted_at"`
+	PurgeAfter         *time.Time `json:"purge_after,omitempty"` // When the expiry pass may purge a soft-deleted file
+	ChunksDeleted      int        `json:"chunks_deleted"`
+	ReclaimedBytes     int64      `json:"reclaimed_bytes"`
+	ChunksUnregistered int        `json:"chunks_unregistered"`
+	PeerChunks         int        `json:"peer_chunks"` // Chunks peers were told to drop
+	Errors             []string   `json:"errors,omitempty"`
+}
+
+// DeleteFile deletes a file. A soft delete marks it deleted, which hides it
+// from listings, searches and downloads, and keeps its chunks until the
+// expiry pass purges it once the purge delay has passed. A hard delete purges
+// it at once: its metadata goes, chunks no other file references are removed
+// from storage and the replica registry, and peers drop their replicas.
+// Deleting a soft-deleted file again with hard set purges it.
+func (dfs *DFSCore) DeleteFile(fileID string, hard bool) (*DeletionReport, error) {
+	if dfs.metaStore == nil {
+		return nil, fmt.Errorf("deleting files needs a metadata store")
+	}
+	file, err := dfs.metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, fileID)
+	}
+
+	// Marking the file first means an interrupted purge leaves it hidden
+	report := &DeletionReport{FileID: fileID, Hard: hard, DeletedAt: time.Now()}
+	if file.DeletedAt != 0 {
+		report.DeletedAt = time.Unix(file.DeletedAt, 0)
+	} else {
+		file.DeletedAt = report.DeletedAt.Unix()
+		if err := dfs.metaStore.PutFileMetadataByID(fileID, file); err != nil {
+			return nil, fmt.Errorf("failed to mark %s deleted: %v", fileID, err)
+		}
+	}
+	if dfs.OptimizedStorage != nil {
+		err := dfs.OptimizedStorage.MarkFileDeleted(fileID, report.DeletedAt)
+		if err != nil && !errors.Is(err, metadata.ErrFileMetadataNotFound) {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to mark enhanced metadata deleted: %v", err))
+		}
+	}
+
+	if !hard {
+		purgeAfter := report.DeletedAt.Add(dfs.config.ExpiryPurgeDelay)
+		report.PurgeAfter = &purgeAfter
+		dfs.logger.Infof("🗑️ Soft-deleted %s, chunks kept until %s", fileID, purgeAfter.Format(time.RFC3339))
+		dfs.publishDeletion(report)
+		return report, nil
+	}
+
+	purged := &ExpiryReport{}
+	if err := purgeFile(dfs.metaStore, dfs.storage, fileID, file, purged); err != nil {
+		return nil, fmt.Errorf("failed to purge %s: %v", fileID, err)
+	}
+	report.ChunksDeleted, report.ReclaimedBytes = purged.ChunksDeleted, purged.ReclaimedBytes
+	report.Errors = append(report.Errors, purged.Errors...)
+	if dfs.OptimizedStorage != nil {
+		if err := dfs.OptimizedStorage.DeleteFile(fileID); err != nil && !errors.Is(err, metadata.ErrFileMetadataNotFound) {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to delete enhanced metadata: %v", err))
+		}
+	}
+	report.ChunksUnregistered, report.PeerChunks = dfs.forgetPurgedFile(fileID)
+
+	dfs.logger.Infof("🗑️ Deleted %s: %d chunks removed, %d bytes reclaimed", fileID, report.ChunksDeleted, report.ReclaimedBytes)
+	dfs.publishDeletion(report)
+	return report, nil
+}
+
+// forgetPurgedFile drops a purged file's chunks from the replica registry,
+// tells peers to remove their replicas and runs the purge hook. It returns
+// how many chunks were unregistered and how many peers were told about.
+func (dfs *DFSCore) forgetPurgedFile(fileID string) (int, int) {
+	dfs.replicaMu.RLock()
+	var chunkIDs []string
+	for chunkID, replica := range dfs.replicaInfo {
+		if replica.FileID == fileID {
+			chunkIDs = append(chunkIDs, chunkID)
+		}
+	}
+	dfs.replicaMu.RUnlock()
+	for _, chunkID := range chunkIDs {
+		dfs.UnregisterChunk(chunkID)
+	}
+
+	peerChunks := 0
+	if dfs.distributor != nil {
+		peerChunks = dfs.distributor.RemoveFile(fileID)
+	}
+	if dfs.purgeHook != nil {
+		dfs.purgeHook(fileID)
+	}
+	return len(chunkIDs), peerChunks
+}
+
+// publishDeletion announces a deleted file on the event bus
+func (dfs *DFSCore) publishDeletion(report *DeletionReport) {
+	dfs.events.Publish(events.TypeFileDeleted, "dfs", "File deleted", map[string]interface{}{
+		"file_id":         report.FileID,
+		"hard":            report.Hard,
+		"chunks_deleted":  report.ChunksDeleted,
+		"reclaimed_bytes": report.ReclaimedBytes,
+	})
+}
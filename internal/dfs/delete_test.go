@@ -0,0 +1,220 @@
+package dfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestDeleteFileSoftThenHard(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	core, metaStore := newLifecycleCore(t)
+	core.config.ExpiryPurgeDelay = time.Hour
+	dir := t.TempDir()
+	upload := func(name string, content []byte) []chunker.ChunkMetadata {
+		t.Helper()
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, content, 0644)
+		chunks, err := chunker.ChunkAndStore(input, "secret", metaStore, core.storage)
+		if err != nil {
+			t.Fatalf("upload of %s failed: %v", name, err)
+		}
+		file := &metadata.EnhancedFileMetadata{FileID: chunks[0].FileID, FileName: name, OwnerID: "alice", ChunkCount: len(chunks)}
+		if err := core.OptimizedStorage.StoreFileMetadata(file); err != nil {
+			t.Fatalf("failed to store enhanced metadata of %s: %v", name, err)
+		}
+		for _, chunk := range chunks {
+			core.RegisterChunk(chunk.Hash, chunk.FileID, []string{"node-1"})
+		}
+		return chunks
+	}
+	doomed := upload("doomed.bin", bytes.Repeat([]byte("soon gone "), 2*int(chunker.MinChunkSize)/10+1))
+	kept := upload("kept.bin", bytes.Repeat([]byte("staying put "), 2*int(chunker.MinChunkSize)/12+1))
+	fileID := doomed[0].FileID
+
+	if _, err := core.DeleteFile("no-such-file", false); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound for an unknown file, got %v", err)
+	}
+
+	live := false
+	searchLive := func() map[string]bool {
+		t.Helper()
+		result, err := core.OptimizedStorage.SearchFiles(&metadata.SearchQuery{IsDeleted: &live, Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchFiles failed: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, file := range result.Files {
+			found[file.FileID] = true
+		}
+		return found
+	}
+
+	// A soft delete hides the file but keeps its chunks for the grace period
+	report, err := core.DeleteFile(fileID, false)
+	if err != nil {
+		t.Fatalf("soft delete failed: %v", err)
+	}
+	if report.Hard || report.PurgeAfter == nil || report.PurgeAfter.Sub(report.DeletedAt) != time.Hour {
+		t.Errorf("expected a soft delete purged an hour later, got %+v", report)
+	}
+	meta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil || meta.Available(time.Now()) {
+		t.Errorf("expected the file kept but unavailable, got %+v (%v)", meta, err)
+	}
+	if found := searchLive(); found[fileID] || !found[kept[0].FileID] {
+		t.Errorf("expected search to return only the kept file, got %v", found)
+	}
+	for _, chunk := range doomed {
+		if ok, _ := core.storage.Exists(chunk.Path); !ok {
+			t.Errorf("chunk %s removed by a soft delete", chunk.Path)
+		}
+		if core.GetReplicaInfo(chunk.Hash) == nil {
+			t.Errorf("chunk %s unregistered by a soft delete", chunk.Hash)
+		}
+	}
+
+	// Deleting it again with hard set purges it at once
+	report, err = core.DeleteFile(fileID, true)
+	if err != nil {
+		t.Fatalf("hard delete failed: %v", err)
+	}
+	if !report.Hard || report.ChunksDeleted != len(doomed) || report.ChunksUnregistered != len(doomed) || report.ReclaimedBytes == 0 {
+		t.Errorf("expected every chunk of %s removed and unregistered, got %+v", fileID, report)
+	}
+	if _, err := metaStore.GetFileMetadataByID(fileID); err == nil {
+		t.Error("file metadata should be gone after a hard delete")
+	}
+	if found := searchLive(); found[fileID] {
+		t.Errorf("hard-deleted file still found by search: %v", found)
+	}
+	for _, chunk := range doomed {
+		if ok, _ := core.storage.Exists(chunk.Path); ok {
+			t.Errorf("chunk %s should be deleted", chunk.Path)
+		}
+		if core.GetReplicaInfo(chunk.Hash) != nil {
+			t.Errorf("chunk %s should be unregistered", chunk.Hash)
+		}
+	}
+
+	// The other file is untouched
+	for _, chunk := range kept {
+		if core.GetReplicaInfo(chunk.Hash) == nil {
+			t.Errorf("chunk %s of the kept file was unregistered", chunk.Hash)
+		}
+	}
+	if err := chunker.ReassembleFile(kept[0].FileID, filepath.Join(dir, "kept-out.bin"), "secret", metaStore, core.storage); err != nil {
+		t.Fatalf("kept file is no longer readable: %v", err)
+	}
+}
+
+func TestDeleteFileKeepsChunksChunkAndStoreShared(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	core, metaStore := newLifecycleCore(t)
+	dir := t.TempDir()
+	block := func(pattern string) []byte {
+		return bytes.Repeat([]byte(pattern), int(chunker.MinChunkSize))[:chunker.MinChunkSize]
+	}
+	upload := func(name string, blocks ...[]byte) ([]chunker.ChunkMetadata, []byte) {
+		t.Helper()
+		content := bytes.Join(blocks, nil)
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, content, 0644)
+		chunks, err := chunker.ChunkAndStore(input, "secret", metaStore, core.storage)
+		if err != nil {
+			t.Fatalf("upload of %s failed: %v", name, err)
+		}
+		hashes := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			hashes[i] = chunk.Hash
+		}
+		file := &metadata.EnhancedFileMetadata{FileID: chunks[0].FileID, FileName: name, ChunkHashes: hashes, ChunkCount: len(chunks)}
+		if err := core.OptimizedStorage.StoreFileMetadata(file); err != nil {
+			t.Fatalf("failed to store enhanced metadata of %s: %v", name, err)
+		}
+		if err := core.TrackChunkReferences(hashes); err != nil {
+			t.Fatalf("failed to track chunk references of %s: %v", name, err)
+		}
+		return chunks, content
+	}
+	readable := func(fileID string, content []byte) {
+		t.Helper()
+		output := filepath.Join(dir, fileID+".out")
+		if err := chunker.ReassembleFile(fileID, output, "secret", metaStore, core.storage); err != nil {
+			t.Fatalf("%s is not readable: %v", fileID, err)
+		}
+		if got, _ := os.ReadFile(output); !bytes.Equal(got, content) {
+			t.Fatalf("%s read back different content", fileID)
+		}
+	}
+	references := func(hash string) (int, int) {
+		t.Helper()
+		chunk, err := metaStore.GetChunkMetadata(hash)
+		if err != nil {
+			t.Fatalf("no chunk record for %s: %v", hash, err)
+		}
+		enhanced, err := core.OptimizedStorage.enhancedMetadata.LoadChunkMetadata(hash)
+		if err != nil {
+			t.Fatalf("no enhanced chunk record for %s: %v", hash, err)
+		}
+		return chunk.RefCount, enhanced.ReferenceCount
+	}
+
+	// The files overlap in one chunk, which the second upload must not write again
+	first, firstContent := upload("first.bin", block("only in first "), block("shared by both "), block("first again "))
+	second, secondContent := upload("second.bin", block("shared by both "), block("only in second "))
+	shared := first[1]
+	if second[0].Hash != shared.Hash || second[0].Path != shared.Path {
+		t.Fatalf("expected the second file to share the stored chunk %s, got %s", shared.Path, second[0].Path)
+	}
+	if stored, _ := core.storage.(storage.Lister).List(); len(stored) != 4 {
+		t.Errorf("expected 4 stored chunks for 5 file chunks, got %d", len(stored))
+	}
+	if refs, enhancedRefs := references(shared.Hash); refs != 2 || enhancedRefs != 2 {
+		t.Errorf("expected the shared chunk referenced twice, got %d and %d in enhanced metadata", refs, enhancedRefs)
+	}
+	readable(first[0].FileID, firstContent)
+	readable(second[0].FileID, secondContent)
+
+	// Deleting the file that stored the chunk first only drops its reference
+	if _, err := core.DeleteFile(first[0].FileID, true); err != nil {
+		t.Fatalf("hard delete failed: %v", err)
+	}
+	for _, chunk := range []chunker.ChunkMetadata{first[0], first[2]} {
+		if ok, _ := core.storage.Exists(chunk.Path); ok {
+			t.Errorf("chunk %s only the deleted file used should be gone", chunk.Path)
+		}
+	}
+	for _, chunk := range second {
+		if ok, _ := core.storage.Exists(chunk.Path); !ok {
+			t.Fatalf("chunk %s of the second file was removed", chunk.Path)
+		}
+	}
+	if refs, enhancedRefs := references(shared.Hash); refs != 1 || enhancedRefs != 1 {
+		t.Errorf("expected one reference left, got %d and %d in enhanced metadata", refs, enhancedRefs)
+	}
+	readable(second[0].FileID, secondContent)
+
+	// The last reference takes the chunk with it
+	if _, err := core.DeleteFile(second[0].FileID, true); err != nil {
+		t.Fatalf("hard delete failed: %v", err)
+	}
+	if ok, _ := core.storage.Exists(shared.Path); ok {
+		t.Error("expected the shared chunk removed with its last reference")
+	}
+	if _, err := metaStore.GetChunkMetadata(shared.Hash); err == nil {
+		t.Error("expected the shared chunk record removed with its last reference")
+	}
+}
@@ -1,11 +1,13 @@
 package dfs
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/events"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
 	"github.com/jaywantadh/DisktroByte/internal/p2p"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
@@ -24,6 +26,17 @@ type DFSConfig struct {
 	CompressionEnabled   bool          `json:"compression_enabled"`    // Enable compression
 	EncryptionEnabled    bool          `json:"encryption_enabled"`     // Enable encryption
 	DeduplicationEnabled bool          `json:"deduplication_enabled"`  // Enable deduplication
+	VirtualNodes         int           `json:"virtual_nodes"`          // Hash ring points per node for consistent hashing
+	DuplicateScanInterval time.Duration `json:"duplicate_scan_interval"` // How often to scan for duplicate files, 0 disables
+	DuplicateAutoMerge   bool          `json:"duplicate_auto_merge"`   // Merge duplicates found by the scan
+	ExpiryScanInterval   time.Duration `json:"expiry_scan_interval"`   // How often to expire files past their TTL, 0 disables
+	ExpiryPurgeDelay     time.Duration `json:"expiry_purge_delay"`     // How long expired files stay soft-deleted before purging
+	LifecycleScanInterval time.Duration `json:"lifecycle_scan_interval"` // How often enabled lifecycle rules run, 0 disables
+	TieringWarmAfter     time.Duration `json:"tiering_warm_after"`     // Time without access before a hot file is demoted to warm, 0 skips warm
+	TieringColdAfter     time.Duration `json:"tiering_cold_after"`     // Time without access before a file is demoted to cold, 0 never demotes
+	TieringScanInterval  time.Duration `json:"tiering_scan_interval"`  // How often files move between storage tiers by access, 0 disables
+	DrainRate            float64       `json:"drain_rate"`             // Chunks per second a node drain moves, 0 for no limit
+	ScrubInterval        time.Duration `json:"scrub_interval"`         // How often the scrubber verifies local chunks, 0 disables
 }
 
 // DefaultDFSConfig returns a default configuration
@@ -39,6 +52,7 @@ func DefaultDFSConfig() *DFSConfig {
 		CompressionEnabled:   true,
 		EncryptionEnabled:    true,
 		DeduplicationEnabled: true,
+		VirtualNodes:         DefaultVirtualNodes,
 	}
 }
 
@@ -63,6 +77,7 @@ type ReplicaInfo struct {
 	CurrentReplicas []string          `json:"current_replicas"`      // Node IDs that have the chunk
 	DesiredReplicas int               `json:"desired_replicas"`      // How many replicas we want
 	Health         map[string]string  `json:"health"`                // Health status per replica
+	Zones          map[string]string  `json:"zones,omitempty"`       // Zone of each replica's node, empty when unknown
 	LastVerified   time.Time          `json:"last_verified"`
 }
 
@@ -81,6 +96,19 @@ type DFSCore struct {
 	// Replica management
 	replicaInfo  map[string]*ReplicaInfo
 	replicaMu    sync.RWMutex
+	durability   durabilityTally // Counters over replicaInfo for the durability report
+	minReplicas  int             // Global minimum replication target, 0 when unset
+	repairPriority []string      // Signal order of the repair queue, empty for DefaultRepairPriority
+	repairMu     sync.Mutex      // Serializes repair passes
+	zoneSpread   bool            // Repairs place replicas in zones, then racks, without one first
+	
+	// Nodes being drained before decommissioning
+	drains       map[string]*drainState
+	drainMu      sync.Mutex
+	
+	// Background scrubber of local chunk copies
+	scrubber     scrubberState
+	scrubMu      sync.Mutex
 	
 	// Background tasks
 	heartbeatTicker   *time.Ticker
@@ -90,6 +118,12 @@ type DFSCore struct {
 	// Advanced storage optimization
 	OptimizedStorage  *OptimizedStorage
 	
+	// Operational events for the admin console; nil discards them
+	events *events.Bus
+	
+	// Called with each file the expiry pass purges
+	purgeHook func(fileID string)
+	
 	logger *logrus.Logger
 }
 
@@ -110,11 +144,17 @@ func NewDFSCore(config *DFSConfig, network *p2p.Network, distributor *distributo
 		metaStore:   metaStore,
 		nodeHealth:  make(map[string]*NodeHealth),
 		replicaInfo: make(map[string]*ReplicaInfo),
+		drains:      make(map[string]*drainState),
 		stopChan:    make(chan bool),
 		logger:      logger,
 	}
 }
 
+// SetEventBus publishes node failures, replication, rebalancing and scrub findings to the bus
+func (dfs *DFSCore) SetEventBus(bus *events.Bus) {
+	dfs.events = bus
+}
+
 // Start initializes and starts the DFS core system
 func (dfs *DFSCore) Start() error {
 	dfs.logger.Info("🚀 Starting DFS Core System")
@@ -136,6 +176,31 @@ func (dfs *DFSCore) Start() error {
 	// Start replica verification
 	go dfs.replicaVerificationMonitor()
 	
+	// Start duplicate detection when configured
+	if dfs.config.DuplicateScanInterval > 0 {
+		go dfs.duplicateMonitor()
+	}
+	
+	// Start file expiry when configured
+	if dfs.config.ExpiryScanInterval > 0 {
+		go dfs.expiryMonitor()
+	}
+	
+	// Start lifecycle rule evaluation when configured
+	if dfs.config.LifecycleScanInterval > 0 {
+		go dfs.lifecycleMonitor()
+	}
+	
+	// Resume drains interrupted by a restart
+	dfs.resumeDrains()
+	
+	// Start the scrubber when configured
+	if dfs.config.ScrubInterval > 0 {
+		if err := dfs.StartScrubber(dfs.config.ScrubInterval); err != nil {
+			dfs.logger.Warnf("⚠️ Scrubber not started: %v", err)
+		}
+	}
+	
 	// Initialize optimized storage if storage is available
 	if dfs.storage != nil {
 		optimizedStorage, err := NewOptimizedStorage("./optimized_storage")
@@ -144,6 +209,16 @@ func (dfs *DFSCore) Start() error {
 		} else {
 			dfs.OptimizedStorage = optimizedStorage
 			dfs.logger.Info("💾 Optimized Storage System initialized")
+			if dfs.metaStore != nil {
+				optimizedStorage.SetChunkSource(dfs.metaStore, dfs.storage)
+			}
+			if tiered, ok := dfs.storage.(*storage.TieredStorage); ok && dfs.metaStore != nil {
+				optimizedStorage.SetTiering(TieringPolicy{
+					WarmAfter: dfs.config.TieringWarmAfter,
+					ColdAfter: dfs.config.TieringColdAfter,
+					Interval:  dfs.config.TieringScanInterval,
+				}, tiered, dfs.fileChunkPaths)
+			}
 		}
 	}
 	
@@ -163,6 +238,8 @@ func (dfs *DFSCore) Stop() {
 	}
 	
 	close(dfs.stopChan)
+	dfs.stopDrains()
+	dfs.StopScrubber()
 	
 	// Close optimized storage if available
 	if dfs.OptimizedStorage != nil {
@@ -280,6 +357,10 @@ func (dfs *DFSCore) updateNodeHealth(nodeID string, isHealthy bool, responseTime
 		
 		if health.Status != "healthy" {
 			dfs.logger.Infof("✅ Node %s is now healthy", nodeID)
+			if health.Status == "failed" || health.Status == "degraded" {
+				dfs.events.Publish(events.TypeNodeHealthy, "dfs", fmt.Sprintf("Node %s recovered", nodeID),
+					map[string]interface{}{"node_id": nodeID, "previous": health.Status})
+			}
 			health.Status = "healthy"
 		}
 	} else {
@@ -289,6 +370,8 @@ func (dfs *DFSCore) updateNodeHealth(nodeID string, isHealthy bool, responseTime
 			if health.Status != "failed" {
 				dfs.logger.Warnf("❌ Node %s marked as failed (failure count: %d)", nodeID, health.FailureCount)
 				health.Status = "failed"
+				dfs.events.Publish(events.TypeNodeFailed, "dfs", fmt.Sprintf("Node %s marked as failed", nodeID),
+					map[string]interface{}{"node_id": nodeID, "failure_count": health.FailureCount})
 				
 				// Trigger replica recovery for this node
 				go dfs.handleNodeFailure(nodeID)
@@ -305,11 +388,14 @@ func (dfs *DFSCore) handleNodeFailure(nodeID string) {
 	
 	// Find all chunks that were stored on the failed node
 	failedChunks := dfs.findChunksOnNode(nodeID)
-	
-	for _, chunkID := range failedChunks {
-		dfs.logger.Infof("🔄 Recovering chunk %s from failed node %s", chunkID, nodeID)
-		go dfs.recoverChunk(chunkID, nodeID)
+	if len(failedChunks) > 0 {
+		dfs.events.Publish(events.TypeReplication, "dfs",
+			fmt.Sprintf("Re-replicating %d chunks from failed node %s", len(failedChunks), nodeID),
+			map[string]interface{}{"node_id": nodeID, "chunks": len(failedChunks)})
 	}
+	
+	// Copy the most important and most endangered chunks back to target first
+	dfs.ReconcileReplicas()
 }
 
 // findChunksOnNode finds all chunks stored on a specific node
@@ -332,11 +418,25 @@ func (dfs *DFSCore) findChunksOnNode(nodeID string) []string {
 
 // recoverChunk recovers a chunk by creating new replicas
 func (dfs *DFSCore) recoverChunk(chunkID, failedNodeID string) error {
+	replicasNeeded, err := dfs.dropReplica(chunkID, failedNodeID)
+	if err != nil {
+		return err
+	}
+	
+	if replicasNeeded > 0 {
+		return dfs.createAdditionalReplicas(chunkID, replicasNeeded)
+	}
+	
+	return nil
+}
+
+// dropReplica forgets a failed node's copy of a chunk and returns how many replicas it is short
+func (dfs *DFSCore) dropReplica(chunkID, failedNodeID string) (int, error) {
 	dfs.replicaMu.Lock()
+	defer dfs.replicaMu.Unlock()
 	replica, exists := dfs.replicaInfo[chunkID]
 	if !exists {
-		dfs.replicaMu.Unlock()
-		return fmt.Errorf("chunk %s not found in replica info", chunkID)
+		return 0, fmt.Errorf("chunk %s not found in replica info", chunkID)
 	}
 	
 	// Remove failed node from current replicas
@@ -347,16 +447,9 @@ func (dfs *DFSCore) recoverChunk(chunkID, failedNodeID string) error {
 		}
 	}
 	replica.CurrentReplicas = updatedReplicas
+	dfs.retally(replica)
 	
-	// Check if we need more replicas
-	replicasNeeded := replica.DesiredReplicas - len(replica.CurrentReplicas)
-	dfs.replicaMu.Unlock()
-	
-	if replicasNeeded > 0 {
-		return dfs.createAdditionalReplicas(chunkID, replicasNeeded)
-	}
-	
-	return nil
+	return replica.DesiredReplicas - len(replica.CurrentReplicas), nil
 }
 
 // createAdditionalReplicas creates additional replicas for a chunk
@@ -369,6 +462,9 @@ func (dfs *DFSCore) createAdditionalReplicas(chunkID string, count int) error {
 	// Remove nodes that already have this chunk
 	availableNodes := dfs.filterNodesWithoutChunk(healthyNodes, chunkID)
 	
+	// Only nodes the file's placement constraint approves may take a replica
+	availableNodes = dfs.approvedNodes(chunkID, availableNodes, count)
+	
 	if len(availableNodes) < count {
 		dfs.logger.Warnf("⚠️ Only %d nodes available for %d needed replicas of chunk %s", 
 			len(availableNodes), count, chunkID)
@@ -376,39 +472,49 @@ func (dfs *DFSCore) createAdditionalReplicas(chunkID string, count int) error {
 	
 	// Create replicas on available nodes
 	created := 0
-	for _, node := range availableNodes {
-		if created >= count {
-			break
-		}
+	placed := dfs.replicaNodes(chunkID)
+	for created < count && len(availableNodes) > 0 {
+		node := dfs.nextRepairTarget(availableNodes, placed)
+		availableNodes = removeNode(availableNodes, node.ID)
 		
-		if err := dfs.createReplicaOnNode(chunkID, node.ID); err != nil {
+		if err := dfs.copyReplica(chunkID, node.ID); err != nil {
 			dfs.logger.Errorf("❌ Failed to create replica of chunk %s on node %s: %v", 
 				chunkID, node.ID, err)
+			dfs.events.Publish(events.TypeError, "dfs", fmt.Sprintf("Failed to create replica of chunk %s", chunkID),
+				map[string]interface{}{"chunk_id": chunkID, "node_id": node.ID, "error": err.Error()})
 		} else {
 			created++
+			placed = append(placed, node)
 			dfs.logger.Infof("✅ Created replica of chunk %s on node %s", chunkID, node.ID)
 		}
 	}
 	
+	dfs.events.Publish(events.TypeReplication, "dfs",
+		fmt.Sprintf("Created %d of %d replicas for chunk %s", created, count, chunkID),
+		map[string]interface{}{"chunk_id": chunkID, "created": created, "needed": count})
+	
 	return nil
 }
 
-// getHealthyNodes returns all nodes with healthy status
+// getHealthyNodes returns all nodes with healthy status that can take new
+// replicas; nodes being drained are left out
 func (dfs *DFSCore) getHealthyNodes() []*p2p.Node {
+	draining := dfs.drainingNodes()
+	
 	dfs.healthMu.RLock()
 	defer dfs.healthMu.RUnlock()
 	
 	var healthyNodes []*p2p.Node
 	
 	// Check local node
-	if health, exists := dfs.nodeHealth[dfs.network.LocalNode.ID]; exists && health.Status == "healthy" {
+	if health, exists := dfs.nodeHealth[dfs.network.LocalNode.ID]; exists && health.Status == "healthy" && !draining[dfs.network.LocalNode.ID] {
 		healthyNodes = append(healthyNodes, dfs.network.LocalNode)
 	}
 	
 	// Check peer nodes
 	peers := dfs.network.GetPeers()
 	for _, peer := range peers {
-		if health, exists := dfs.nodeHealth[peer.ID]; exists && health.Status == "healthy" {
+		if health, exists := dfs.nodeHealth[peer.ID]; exists && health.Status == "healthy" && !draining[peer.ID] {
 			healthyNodes = append(healthyNodes, peer)
 		}
 	}
@@ -454,6 +560,7 @@ func (dfs *DFSCore) createReplicaOnNode(chunkID, nodeID string) error {
 	if replica, exists := dfs.replicaInfo[chunkID]; exists {
 		replica.CurrentReplicas = append(replica.CurrentReplicas, nodeID)
 		replica.Health[nodeID] = "healthy"
+		dfs.retally(replica)
 		
 		// Add chunk to node's chunk list
 		dfs.network.AddChunkToNode(nodeID, chunkID)
@@ -462,8 +569,18 @@ func (dfs *DFSCore) createReplicaOnNode(chunkID, nodeID string) error {
 	return nil
 }
 
+// verifyReplica confirms a node holds an intact copy of a chunk
+func (dfs *DFSCore) verifyReplica(chunkID, nodeID string) error {
+	if dfs.distributor == nil {
+		return fmt.Errorf("no distributor to verify replicas with")
+	}
+	return dfs.distributor.VerifyReplica(context.Background(), chunkID, nodeID)
+}
+
 // GetSystemStats returns comprehensive DFS system statistics
 func (dfs *DFSCore) GetSystemStats() map[string]interface{} {
+	scrub := dfs.ScrubberStats()
+	
 	dfs.healthMu.RLock()
 	dfs.replicaMu.RLock()
 	defer dfs.healthMu.RUnlock()
@@ -481,6 +598,9 @@ func (dfs *DFSCore) GetSystemStats() map[string]interface{} {
 		"storage_capacity":  int64(0),
 		"storage_used":      int64(0),
 		"storage_efficiency": 0.0,
+		"scrub_chunks_checked": scrub.ChunksChecked,
+		"scrub_repaired":    scrub.Repaired,
+		"scrub_failed":      scrub.Failed,
 	}
 	
 	// Calculate node statistics
@@ -535,24 +655,19 @@ func (dfs *DFSCore) rebalanceMonitor() {
 func (dfs *DFSCore) performRebalancing() {
 	dfs.logger.Info("🔄 Performing replica rebalancing...")
 	
-	dfs.replicaMu.RLock()
-	rebalanceList := make([]*ReplicaInfo, 0)
-	for _, replica := range dfs.replicaInfo {
-		currentCount := len(replica.CurrentReplicas)
-		if currentCount < replica.DesiredReplicas {
-			rebalanceList = append(rebalanceList, replica)
-		}
+	rebalanceList := dfs.RepairQueue()
+	for _, item := range rebalanceList {
+		dfs.logger.Infof("⚖️ Chunk %s needs %d more replicas", item.ChunkID, item.Desired-item.Surviving)
 	}
-	dfs.replicaMu.RUnlock()
-	
-	for _, replica := range rebalanceList {
-		replicasNeeded := replica.DesiredReplicas - len(replica.CurrentReplicas)
-		dfs.logger.Infof("⚖️ Chunk %s needs %d more replicas", replica.ChunkID, replicasNeeded)
-		go dfs.createAdditionalReplicas(replica.ChunkID, replicasNeeded)
+	if len(rebalanceList) > 0 {
+		go dfs.runRepairs(rebalanceList)
 	}
 	
 	if len(rebalanceList) > 0 {
 		dfs.logger.Infof("✅ Rebalancing completed for %d chunks", len(rebalanceList))
+		dfs.events.Publish(events.TypeRebalance, "dfs",
+			fmt.Sprintf("Scheduled re-replication for %d under-replicated chunks", len(rebalanceList)),
+			map[string]interface{}{"chunks": len(rebalanceList)})
 	} else {
 		dfs.logger.Debug("✨ All chunks are properly replicated")
 	}
@@ -613,6 +728,57 @@ func (dfs *DFSCore) replicaVerificationMonitor() {
 	}
 }
 
+// duplicateMonitor periodically scans for duplicate files and merges them if configured
+func (dfs *DFSCore) duplicateMonitor() {
+	duplicateTicker := time.NewTicker(dfs.config.DuplicateScanInterval)
+	defer duplicateTicker.Stop()
+	
+	for {
+		select {
+		case <-duplicateTicker.C:
+			if _, err := dfs.FindDuplicates(DuplicateOptions{Merge: dfs.config.DuplicateAutoMerge}); err != nil {
+				dfs.logger.Warnf("⚠️ Duplicate scan failed: %v", err)
+			}
+		case <-dfs.stopChan:
+			return
+		}
+	}
+}
+
+// expiryMonitor periodically removes files whose TTL has elapsed
+func (dfs *DFSCore) expiryMonitor() {
+	expiryTicker := time.NewTicker(dfs.config.ExpiryScanInterval)
+	defer expiryTicker.Stop()
+	
+	for {
+		select {
+		case <-expiryTicker.C:
+			if _, err := dfs.ExpireFiles(); err != nil {
+				dfs.logger.Warnf("⚠️ Expiry pass failed: %v", err)
+			}
+		case <-dfs.stopChan:
+			return
+		}
+	}
+}
+
+// lifecycleMonitor periodically applies the enabled lifecycle rules
+func (dfs *DFSCore) lifecycleMonitor() {
+	lifecycleTicker := time.NewTicker(dfs.config.LifecycleScanInterval)
+	defer lifecycleTicker.Stop()
+	
+	for {
+		select {
+		case <-lifecycleTicker.C:
+			if _, err := dfs.RunLifecycle(LifecycleOptions{}); err != nil {
+				dfs.logger.Warnf("⚠️ Lifecycle pass failed: %v", err)
+			}
+		case <-dfs.stopChan:
+			return
+		}
+	}
+}
+
 // performReplicaVerification verifies the integrity of replicas
 func (dfs *DFSCore) performReplicaVerification() {
 	dfs.logger.Info("🔍 Performing replica integrity verification...")
@@ -646,6 +812,9 @@ func (dfs *DFSCore) verifyChunkReplicas(replica *ReplicaInfo) {
 			replica.Health[nodeID] = "corrupted"
 			dfs.logger.Warnf("❌ Replica of chunk %s on node %s is corrupted", 
 				replica.ChunkID, nodeID)
+			dfs.events.Publish(events.TypeScrub, "dfs",
+				fmt.Sprintf("Replica of chunk %s on node %s failed verification", replica.ChunkID, nodeID),
+				map[string]interface{}{"chunk_id": replica.ChunkID, "node_id": nodeID})
 		}
 	}
 	
@@ -653,6 +822,7 @@ func (dfs *DFSCore) verifyChunkReplicas(replica *ReplicaInfo) {
 	dfs.replicaMu.Lock()
 	replica.CurrentReplicas = healthyReplicas
 	replica.LastVerified = time.Now()
+	dfs.retally(replica)
 	dfs.replicaMu.Unlock()
 	
 	// Create new replicas if needed
@@ -690,7 +860,7 @@ func (dfs *DFSCore) RegisterChunk(chunkID, fileID string, nodeIDs []string) {
 		ChunkID:         chunkID,
 		FileID:          fileID,
 		CurrentReplicas: make([]string, len(nodeIDs)),
-		DesiredReplicas: dfs.config.DefaultReplicaCount,
+		DesiredReplicas: max(dfs.config.DefaultReplicaCount, dfs.minReplicas),
 		Health:          make(map[string]string),
 		LastVerified:    time.Now(),
 	}
@@ -702,6 +872,7 @@ func (dfs *DFSCore) RegisterChunk(chunkID, fileID string, nodeIDs []string) {
 	}
 	
 	dfs.replicaInfo[chunkID] = replicaInfo
+	dfs.retally(replicaInfo)
 	
 	dfs.logger.Infof("📝 Registered chunk %s with %d replicas", chunkID, len(nodeIDs))
 }
@@ -713,6 +884,7 @@ func (dfs *DFSCore) UnregisterChunk(chunkID string) {
 	
 	if _, exists := dfs.replicaInfo[chunkID]; exists {
 		delete(dfs.replicaInfo, chunkID)
+		dfs.durability.remove(chunkID)
 		dfs.logger.Infof("🗑️ Unregistered chunk %s", chunkID)
 	}
 }
@@ -0,0 +1,423 @@
+package dfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// States of a node drain
+const (
+	DrainRunning   = "running"   // A pass is moving the node's chunks
+	DrainCancelled = "cancelled" // Stopped by an admin; the node stays drained and the drain can be resumed
+	DrainBlocked   = "blocked"   // The pass finished but some chunks could not reach their target elsewhere
+	DrainComplete  = "complete"  // The node holds no chunk another node lacks
+)
+
+// maxDrainBlocked bounds how many blocked chunks a status lists
+const maxDrainBlocked = 100
+
+// DrainStatus reports the progress of draining a node before it is decommissioned
+type DrainStatus struct {
+	NodeID       string    `json:"node_id"`
+	State        string    `json:"state"`
+	StartedBy    string    `json:"started_by,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	FinishedAt   time.Time `json:"finished_at,omitempty"` // Zero while a pass runs
+	TotalChunks  int       `json:"total_chunks"`          // Chunks on the node when the current pass started
+	Moved        int       `json:"moved"`                 // Re-replicated elsewhere, verified and released from the node
+	Failed       int       `json:"failed"`                // Could not be brought to target without the node
+	Remaining    int       `json:"remaining"`             // Still held by the node
+	Blocked      []string  `json:"blocked,omitempty"`     // Chunks the pass had to leave on the node
+	SafeToRemove bool      `json:"safe_to_remove"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// drainState is one node's drain and the pass running for it, if any
+type drainState struct {
+	status DrainStatus
+	cancel context.CancelFunc // Stops the running pass, nil when none runs
+	done   chan struct{}      // Closed when the running pass returns
+}
+
+// DrainNode starts draining a node: it takes no new replicas or uploads while
+// a background pass copies each of its chunks to other nodes, verifies the
+// copies and only then releases the node's replica. Draining a node already
+// being drained resumes it, unless a pass is running.
+func (dfs *DFSCore) DrainNode(nodeID, actor string) (DrainStatus, error) {
+	if nodeID == "" {
+		return DrainStatus{}, fmt.Errorf("node ID is required")
+	}
+	if nodeID != dfs.network.LocalNode.ID && dfs.network.GetPeerByID(nodeID) == nil {
+		return DrainStatus{}, fmt.Errorf("unknown node %s", nodeID)
+	}
+
+	dfs.drainMu.Lock()
+	state, exists := dfs.drains[nodeID]
+	if exists && state.cancel != nil {
+		status := state.snapshot()
+		dfs.drainMu.Unlock()
+		return status, nil
+	}
+	if !exists {
+		state = &drainState{status: DrainStatus{NodeID: nodeID, StartedBy: actor, StartedAt: time.Now()}}
+		dfs.drains[nodeID] = state
+	}
+	dfs.drainMu.Unlock()
+
+	if dfs.metaStore != nil {
+		record := metadata.DrainRecord{NodeID: nodeID, StartedBy: state.status.StartedBy, StartedAt: state.status.StartedAt.Unix()}
+		if err := dfs.metaStore.PutDrain(record); err != nil {
+			return DrainStatus{}, fmt.Errorf("failed to record drain of node %s: %v", nodeID, err)
+		}
+	}
+	if dfs.distributor != nil {
+		dfs.distributor.SetDraining(nodeID, true)
+	}
+
+	dfs.logger.Infof("🚚 Draining node %s", nodeID)
+	dfs.events.Publish(events.TypeReplication, "dfs", fmt.Sprintf("Draining node %s", nodeID),
+		map[string]interface{}{"node_id": nodeID, "actor": actor})
+	return dfs.startDrainPass(nodeID), nil
+}
+
+// startDrainPass launches a pass for a drain that has none running
+func (dfs *DFSCore) startDrainPass(nodeID string) DrainStatus {
+	ctx, cancel := context.WithCancel(context.Background())
+	dfs.drainMu.Lock()
+	defer dfs.drainMu.Unlock()
+	state := dfs.drains[nodeID]
+	if state.cancel != nil {
+		cancel()
+		return state.snapshot()
+	}
+	state.cancel = cancel
+	state.done = make(chan struct{})
+	state.status.State = DrainRunning
+	state.status.FinishedAt = time.Time{}
+	state.status.LastError = ""
+	state.status.UpdatedAt = time.Now()
+	go dfs.runDrain(ctx, nodeID, state.done)
+	return state.snapshot()
+}
+
+// CancelDrain stops a node's running drain pass. The node stays excluded from
+// new placements and DrainNode resumes where the pass left off.
+func (dfs *DFSCore) CancelDrain(nodeID string) (DrainStatus, error) {
+	dfs.drainMu.Lock()
+	state, exists := dfs.drains[nodeID]
+	if !exists {
+		dfs.drainMu.Unlock()
+		return DrainStatus{}, fmt.Errorf("node %s is not being drained", nodeID)
+	}
+	cancel, done := state.cancel, state.done
+	dfs.drainMu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+		dfs.logger.Infof("⏸️ Drain of node %s cancelled", nodeID)
+	}
+	status, _ := dfs.DrainStatus(nodeID)
+	return status, nil
+}
+
+// UndrainNode cancels a node's drain and returns it to service. Chunks already
+// moved off it stay where they are.
+func (dfs *DFSCore) UndrainNode(nodeID string) error {
+	if _, err := dfs.CancelDrain(nodeID); err != nil {
+		return err
+	}
+	if dfs.metaStore != nil {
+		if err := dfs.metaStore.DeleteDrain(nodeID); err != nil {
+			return fmt.Errorf("failed to clear drain of node %s: %v", nodeID, err)
+		}
+	}
+	if dfs.distributor != nil {
+		dfs.distributor.SetDraining(nodeID, false)
+	}
+
+	dfs.drainMu.Lock()
+	delete(dfs.drains, nodeID)
+	dfs.drainMu.Unlock()
+
+	dfs.logger.Infof("↩️ Node %s returned to service", nodeID)
+	dfs.events.Publish(events.TypeReplication, "dfs", fmt.Sprintf("Node %s returned to service", nodeID),
+		map[string]interface{}{"node_id": nodeID})
+	return nil
+}
+
+// DrainStatus reports the progress of a node's drain
+func (dfs *DFSCore) DrainStatus(nodeID string) (DrainStatus, bool) {
+	dfs.drainMu.Lock()
+	defer dfs.drainMu.Unlock()
+	state, exists := dfs.drains[nodeID]
+	if !exists {
+		return DrainStatus{}, false
+	}
+	return state.snapshot(), true
+}
+
+// Drains reports every node being drained, in node order
+func (dfs *DFSCore) Drains() []DrainStatus {
+	dfs.drainMu.Lock()
+	defer dfs.drainMu.Unlock()
+	drains := make([]DrainStatus, 0, len(dfs.drains))
+	for _, state := range dfs.drains {
+		drains = append(drains, state.snapshot())
+	}
+	sort.Slice(drains, func(i, j int) bool {
+		return drains[i].NodeID < drains[j].NodeID
+	})
+	return drains
+}
+
+func (state *drainState) snapshot() DrainStatus {
+	status := state.status
+	status.Blocked = append([]string(nil), state.status.Blocked...)
+	return status
+}
+
+// drainingNodes returns the set of nodes being drained
+func (dfs *DFSCore) drainingNodes() map[string]bool {
+	dfs.drainMu.Lock()
+	defer dfs.drainMu.Unlock()
+	draining := make(map[string]bool, len(dfs.drains))
+	for nodeID := range dfs.drains {
+		draining[nodeID] = true
+	}
+	return draining
+}
+
+// resumeDrains restarts the drains recorded before the last shutdown
+func (dfs *DFSCore) resumeDrains() {
+	if dfs.metaStore == nil {
+		return
+	}
+	records, err := dfs.metaStore.ListDrains()
+	if err != nil {
+		dfs.logger.Warnf("⚠️ Failed to load node drains: %v", err)
+		return
+	}
+	for _, record := range records {
+		dfs.drainMu.Lock()
+		if _, exists := dfs.drains[record.NodeID]; !exists {
+			dfs.drains[record.NodeID] = &drainState{status: DrainStatus{
+				NodeID: record.NodeID, StartedBy: record.StartedBy, StartedAt: time.Unix(record.StartedAt, 0),
+			}}
+		}
+		dfs.drainMu.Unlock()
+		if dfs.distributor != nil {
+			dfs.distributor.SetDraining(record.NodeID, true)
+		}
+		dfs.logger.Infof("🚚 Resuming drain of node %s", record.NodeID)
+		dfs.startDrainPass(record.NodeID)
+	}
+}
+
+// stopDrains cancels every running drain pass; the drains resume on restart
+func (dfs *DFSCore) stopDrains() {
+	dfs.drainMu.Lock()
+	var running []*drainState
+	for _, state := range dfs.drains {
+		if state.cancel != nil {
+			state.cancel()
+			running = append(running, state)
+		}
+	}
+	dfs.drainMu.Unlock()
+	for _, state := range running {
+		<-state.done
+	}
+}
+
+// updateDrain applies a change to a node's drain status under drainMu
+func (dfs *DFSCore) updateDrain(nodeID string, update func(status *DrainStatus)) {
+	dfs.drainMu.Lock()
+	defer dfs.drainMu.Unlock()
+	if state, exists := dfs.drains[nodeID]; exists {
+		update(&state.status)
+		state.status.UpdatedAt = time.Now()
+	}
+}
+
+// runDrain moves every chunk off a node, one at a time at the configured
+// rate and yielding to foreground load like repairs do. A chunk that cannot
+// reach its target without the node is left on it and reported blocked.
+func (dfs *DFSCore) runDrain(ctx context.Context, nodeID string, done chan struct{}) {
+	defer close(done)
+
+	chunkIDs := dfs.findChunksOnNode(nodeID)
+	sort.Strings(chunkIDs)
+	dfs.updateDrain(nodeID, func(status *DrainStatus) {
+		status.TotalChunks, status.Remaining = len(chunkIDs), len(chunkIDs)
+		status.Moved, status.Failed, status.Blocked = 0, 0, nil
+	})
+
+	var interval time.Duration
+	if dfs.config.DrainRate > 0 {
+		interval = time.Duration(float64(time.Second) / dfs.config.DrainRate)
+	}
+
+	for _, chunkID := range chunkIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		err := dfs.drainChunk(chunkID, nodeID)
+		dfs.updateDrain(nodeID, func(status *DrainStatus) {
+			if err != nil {
+				status.Failed++
+				status.LastError = err.Error()
+				if len(status.Blocked) < maxDrainBlocked {
+					status.Blocked = append(status.Blocked, chunkID)
+				}
+				return
+			}
+			status.Moved++
+			status.Remaining--
+		})
+		if err != nil {
+			dfs.logger.Warnf("⚠️ Keeping chunk %s on draining node %s: %v", chunkID, nodeID, err)
+		}
+
+		throttle.Global().Yield(ctx, throttle.JobRepair, time.Since(start))
+		if wait := interval - time.Since(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}
+
+	// New chunks may have been registered on the node before it was marked
+	remaining := len(dfs.findChunksOnNode(nodeID))
+	var final DrainStatus
+	dfs.drainMu.Lock()
+	if state, exists := dfs.drains[nodeID]; exists {
+		state.cancel, state.done = nil, nil
+		state.status.Remaining = remaining
+		state.status.SafeToRemove = remaining == 0
+		switch {
+		case ctx.Err() != nil && remaining > 0:
+			state.status.State = DrainCancelled
+		case remaining > 0:
+			state.status.State = DrainBlocked
+		default:
+			state.status.State = DrainComplete
+		}
+		state.status.FinishedAt = time.Now()
+		state.status.UpdatedAt = state.status.FinishedAt
+		final = state.snapshot()
+	}
+	dfs.drainMu.Unlock()
+
+	switch final.State {
+	case DrainComplete:
+		dfs.logger.Infof("✅ Node %s is drained and safe to remove", nodeID)
+		dfs.events.Publish(events.TypeReplication, "dfs", fmt.Sprintf("Node %s is drained and safe to remove", nodeID),
+			map[string]interface{}{"node_id": nodeID, "moved": final.Moved})
+	case DrainBlocked:
+		dfs.logger.Warnf("⚠️ Drain of node %s left %d chunks on it", nodeID, remaining)
+		dfs.events.Publish(events.TypeReplication, "dfs", fmt.Sprintf("Drain of node %s left %d chunks on it", nodeID, remaining),
+			map[string]interface{}{"node_id": nodeID, "moved": final.Moved, "remaining": remaining, "error": final.LastError})
+	}
+}
+
+// drainChunk brings a chunk to its replica target on nodes other than the
+// draining one, verifying each new copy, then releases the node's replica
+func (dfs *DFSCore) drainChunk(chunkID, nodeID string) error {
+	dfs.replicaMu.RLock()
+	replica, exists := dfs.replicaInfo[chunkID]
+	needed := 0
+	if exists {
+		needed = replica.DesiredReplicas - (len(replica.CurrentReplicas) - countNode(replica.CurrentReplicas, nodeID))
+	}
+	dfs.replicaMu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	if needed > 0 {
+		candidates := dfs.filterNodesWithoutChunk(dfs.getHealthyNodes(), chunkID)
+		candidates = dfs.approvedNodes(chunkID, candidates, needed)
+		for _, node := range candidates {
+			if needed == 0 {
+				break
+			}
+			if err := dfs.createReplicaOnNode(chunkID, node.ID); err != nil {
+				dfs.logger.Errorf("❌ Failed to create replica of chunk %s on node %s: %v", chunkID, node.ID, err)
+				continue
+			}
+			if err := dfs.verifyReplica(chunkID, node.ID); err != nil {
+				dfs.logger.Warnf("⚠️ New replica of chunk %s on node %s not confirmed: %v", chunkID, node.ID, err)
+				dfs.forgetReplica(chunkID, node.ID)
+				continue
+			}
+			needed--
+			dfs.logger.Infof("✅ Moved a replica of chunk %s to node %s", chunkID, node.ID)
+		}
+	}
+
+	if !dfs.releaseDrainedReplica(chunkID, nodeID) {
+		return fmt.Errorf("chunk %s is short of its replica target without node %s", chunkID, nodeID)
+	}
+	return nil
+}
+
+// releaseDrainedReplica drops a draining node's replica of a chunk if the
+// other replicas meet the target, checked under the same lock so a replica
+// lost meanwhile is never made up for by dropping below the target
+func (dfs *DFSCore) releaseDrainedReplica(chunkID, nodeID string) bool {
+	dfs.replicaMu.Lock()
+	defer dfs.replicaMu.Unlock()
+	replica, exists := dfs.replicaInfo[chunkID]
+	if !exists {
+		return true
+	}
+	if len(replica.CurrentReplicas)-countNode(replica.CurrentReplicas, nodeID) < replica.DesiredReplicas {
+		return false
+	}
+	dfs.removeReplicaLocked(replica, nodeID)
+	return true
+}
+
+// forgetReplica drops a node's replica of a chunk from the replica records
+func (dfs *DFSCore) forgetReplica(chunkID, nodeID string) {
+	dfs.replicaMu.Lock()
+	defer dfs.replicaMu.Unlock()
+	if replica, exists := dfs.replicaInfo[chunkID]; exists {
+		dfs.removeReplicaLocked(replica, nodeID)
+	}
+}
+
+// removeReplicaLocked removes nodeID from a replica record. Callers hold replicaMu.
+func (dfs *DFSCore) removeReplicaLocked(replica *ReplicaInfo, nodeID string) {
+	updated := make([]string, 0, len(replica.CurrentReplicas))
+	for _, replicaNodeID := range replica.CurrentReplicas {
+		if replicaNodeID != nodeID {
+			updated = append(updated, replicaNodeID)
+		}
+	}
+	replica.CurrentReplicas = updated
+	delete(replica.Health, nodeID)
+	dfs.retally(replica)
+}
+
+func countNode(nodeIDs []string, nodeID string) int {
+	count := 0
+	for _, id := range nodeIDs {
+		if id == nodeID {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,148 @@
+package dfs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func waitForDrain(t *testing.T, dfs *DFSCore, nodeID string) DrainStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, ok := dfs.DrainStatus(nodeID); ok && status.State != DrainRunning {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("drain of %s did not finish", nodeID)
+	return DrainStatus{}
+}
+
+func TestDrainMovesEveryChunkBeforeTheNodeIsSafeToRemove(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	d := distributor.NewDistributor(network, store, metaStore)
+	paths := writeBatchFiles(t, dir, "ledger.bin")
+	file, err := d.DistributeFileContext(context.Background(), paths[0], "pw")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	// Peers that serve intact copies of the chunks, and one whose copies never verify
+	addPeer := func(id string, handler http.HandlerFunc) {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		network.RegisterPeer(&p2p.Node{ID: id, Address: "127.0.0.1", Port: server.Listener.Addr().(*net.TCPAddr).Port, Status: "online", LastSeen: time.Now()})
+	}
+	addPeer("old", d.HandleChunkRequest)
+	addPeer("keeper", d.HandleChunkRequest)
+	addPeer("broken", http.NotFound)
+
+	dfs := NewDFSCore(nil, network, d, store, metaStore)
+	for _, nodeID := range []string{network.LocalNode.ID, "old", "keeper", "broken"} {
+		dfs.updateNodeHealth(nodeID, true, 0)
+	}
+	for _, chunkID := range file.Chunks {
+		dfs.RegisterChunk(chunkID, file.ID, []string{"old", network.LocalNode.ID, "keeper"})
+	}
+
+	// The only node left to take a copy fails verification, so nothing may leave the node
+	if _, err := dfs.DrainNode("old", "admin"); err != nil {
+		t.Fatalf("DrainNode failed: %v", err)
+	}
+	if !d.Draining("old") {
+		t.Errorf("expected uploads to stop placing chunks on the draining node")
+	}
+	for _, node := range dfs.getHealthyNodes() {
+		if node.ID == "old" {
+			t.Errorf("expected the draining node left out of replica placement")
+		}
+	}
+	status := waitForDrain(t, dfs, "old")
+	if status.State != DrainBlocked || status.SafeToRemove || status.Remaining != len(file.Chunks) || len(status.Blocked) != len(file.Chunks) {
+		t.Fatalf("expected every chunk kept on the node while no copy verifies, got %+v", status)
+	}
+	for _, chunkID := range file.Chunks {
+		replicas := dfs.GetReplicaInfo(chunkID).CurrentReplicas
+		if len(replicas) != 3 || countNode(replicas, "old") != 1 || countNode(replicas, "broken") != 0 {
+			t.Fatalf("expected chunk %s left on its original 3 nodes, got %v", chunkID, replicas)
+		}
+	}
+
+	// A slow pass can be cancelled part way and resumed
+	addPeer("fresh", d.HandleChunkRequest)
+	dfs.updateNodeHealth("fresh", true, 0)
+	dfs.config.DrainRate = 2
+	if _, err := dfs.DrainNode("old", "admin"); err != nil {
+		t.Fatalf("resuming the drain failed: %v", err)
+	}
+	// At two chunks a second the pass waits after the first one
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if status, _ := dfs.DrainStatus("old"); status.Moved > 0 {
+			break
+		}
+	}
+	status, err = dfs.CancelDrain("old")
+	if err != nil {
+		t.Fatalf("CancelDrain failed: %v", err)
+	}
+	if status.State != DrainCancelled || status.Moved != 1 || status.SafeToRemove || !d.Draining("old") {
+		t.Fatalf("expected one chunk moved before the cancel and the node still draining, got %+v", status)
+	}
+
+	dfs.config.DrainRate = 0
+	if _, err := dfs.DrainNode("old", "admin"); err != nil {
+		t.Fatalf("resuming the drain failed: %v", err)
+	}
+	status = waitForDrain(t, dfs, "old")
+	if status.State != DrainComplete || !status.SafeToRemove || status.Remaining != 0 || status.Moved != len(file.Chunks)-1 {
+		t.Fatalf("expected the resumed drain to finish the remaining chunks, got %+v", status)
+	}
+
+	// Every chunk is at target without the node, on copies that were read back
+	for _, chunkID := range file.Chunks {
+		replicas := dfs.GetReplicaInfo(chunkID).CurrentReplicas
+		if len(replicas) != 3 || countNode(replicas, "old") != 0 || countNode(replicas, "fresh") != 1 {
+			t.Errorf("expected chunk %s moved from old to fresh, got %v", chunkID, replicas)
+		}
+		if err := d.VerifyReplica(context.Background(), chunkID, "fresh"); err != nil {
+			t.Errorf("expected the new copy of chunk %s intact: %v", chunkID, err)
+		}
+	}
+
+	// The drain survives a restart until the node is returned to service
+	if records, err := metaStore.ListDrains(); err != nil || len(records) != 1 || records[0].NodeID != "old" {
+		t.Errorf("expected the drain recorded, got %v (%v)", records, err)
+	}
+	if err := dfs.UndrainNode("old"); err != nil {
+		t.Fatalf("UndrainNode failed: %v", err)
+	}
+	if records, _ := metaStore.ListDrains(); len(records) != 0 || d.Draining("old") {
+		t.Errorf("expected the node back in service, got %v", records)
+	}
+}
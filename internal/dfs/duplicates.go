@@ -0,0 +1,319 @@
+package dfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// DuplicateOptions controls a duplicate scan
+type DuplicateOptions struct {
+	Merge       bool          `json:"merge"`        // Point duplicates at one chunk set and delete the extra copies; false is a dry run
+	GracePeriod time.Duration `json:"grace_period"` // Newer copies may belong to an upload in progress and are kept; 0 uses DefaultOrphanGracePeriod, negative disables
+}
+
+// DuplicateGroup is one file content stored more than once
+type DuplicateGroup struct {
+	ContentID        string   `json:"content_id"` // SHA-256 of the content shared by the group
+	FileIDs          []string `json:"file_ids"`   // Logical files with this content
+	FileNames        []string `json:"file_names"`
+	CanonicalChunks  int      `json:"canonical_chunks"` // Chunks in the set every file reads from
+	RedundantCopies  []string `json:"redundant_copies"` // Stored chunks holding extra copies of the content
+	ReclaimableBytes int64    `json:"reclaimable_bytes"`
+	Merged           bool     `json:"merged"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// DuplicateReport is the result of a duplicate scan
+type DuplicateReport struct {
+	ScannedAt        time.Time        `json:"scanned_at"`
+	Duration         time.Duration    `json:"duration"`
+	Merge            bool             `json:"merge"`
+	FilesScanned     int              `json:"files_scanned"`
+	StoredChunks     int              `json:"stored_chunks"`
+	Groups           []DuplicateGroup `json:"groups"`
+	DuplicateFiles   int              `json:"duplicate_files"`   // Files sharing their content with another file
+	ReclaimableBytes int64            `json:"reclaimable_bytes"` // Potential savings across all groups
+	ReclaimedBytes   int64            `json:"reclaimed_bytes"`
+	CopiesDeleted    int              `json:"copies_deleted"`
+	Errors           []string         `json:"errors,omitempty"`
+}
+
+// FindDuplicates scans the core's catalog for duplicated content
+func (dfs *DFSCore) FindDuplicates(opts DuplicateOptions) (*DuplicateReport, error) {
+	report, err := FindDuplicates(dfs.metaStore, dfs.storage, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dfs.logger.Infof("🧬 Duplicate scan: %d groups, %d bytes reclaimable, %d bytes reclaimed",
+		len(report.Groups), report.ReclaimableBytes, report.ReclaimedBytes)
+	if opts.Merge && report.CopiesDeleted > 0 {
+		dfs.events.Publish(events.TypeScrub, "dfs", "Merged duplicate files", map[string]interface{}{
+			"groups":          len(report.Groups),
+			"copies_deleted":  report.CopiesDeleted,
+			"reclaimed_bytes": report.ReclaimedBytes,
+		})
+	}
+	return report, nil
+}
+
+// FindDuplicates groups catalog files by content hash and finds stored chunks
+// that hold extra copies of that content. Identical files uploaded separately
+// each wrote a full set of chunks, while the catalog only reads one set per
+// content, so the other sets are pure overhead.
+//
+// With Merge set, each group's files are pointed at the canonical chunk set
+// whose reference counts are then updated, and the extra copies are deleted.
+// Nothing in a group is touched unless its canonical set is complete, and each
+// copy is re-checked against fresh metadata just before it is deleted, so a
+// failure leaves every file readable. The store must implement storage.Lister;
+// merging also needs storage.Deleter.
+func FindDuplicates(metaStore *metadata.MetadataStore, store storage.Storage, opts DuplicateOptions) (*DuplicateReport, error) {
+	if metaStore == nil || store == nil {
+		return nil, fmt.Errorf("duplicate scan needs both a metadata store and storage")
+	}
+	lister, ok := store.(storage.Lister)
+	if !ok {
+		return nil, fmt.Errorf("storage backend %T cannot list chunks", store)
+	}
+	if opts.GracePeriod == 0 {
+		opts.GracePeriod = DefaultOrphanGracePeriod
+	}
+
+	start := time.Now()
+	report := &DuplicateReport{
+		ScannedAt: start,
+		Merge:     opts.Merge,
+		Groups:    make([]DuplicateGroup, 0),
+	}
+
+	files, err := metaStore.ListFileMetadataByID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file metadata: %v", err)
+	}
+	storedIDs, err := lister.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %v", err)
+	}
+	referenced, err := chunker.ReferencedChunkPaths(metaStore)
+	if err != nil {
+		return nil, err
+	}
+	report.FilesScanned = len(files)
+	report.StoredChunks = len(storedIDs)
+
+	// Files are grouped by the content they currently read
+	groups := make(map[string]*DuplicateGroup)
+	for fileID, file := range files {
+		contentID := file.CurrentContentID(fileID)
+		group, exists := groups[contentID]
+		if !exists {
+			group = &DuplicateGroup{ContentID: contentID, RedundantCopies: make([]string, 0)}
+			groups[contentID] = group
+		}
+		group.FileIDs = append(group.FileIDs, fileID)
+		group.FileNames = append(group.FileNames, file.FileName)
+	}
+
+	// Unreferenced chunks whose header names a catalogued content are extra copies of it
+	for _, id := range storedIDs {
+		if referenced[id] {
+			continue
+		}
+		header, size, err := readStoredHeader(store, id)
+		if err != nil || header == nil {
+			continue
+		}
+		group, exists := groups[header.FileID]
+		if !exists {
+			continue
+		}
+		group.RedundantCopies = append(group.RedundantCopies, id)
+		group.ReclaimableBytes += size
+	}
+
+	for _, group := range groups {
+		if len(group.FileIDs) < 2 && len(group.RedundantCopies) == 0 {
+			continue
+		}
+		sort.Strings(group.RedundantCopies)
+		if len(group.FileIDs) > 1 {
+			report.DuplicateFiles += len(group.FileIDs) - 1
+		}
+		report.ReclaimableBytes += group.ReclaimableBytes
+
+		if opts.Merge {
+			mergeDuplicateGroup(group, report, metaStore, store, opts.GracePeriod)
+		} else if chunks, err := canonicalChunks(metaStore, store, group.ContentID); err == nil {
+			group.CanonicalChunks = len(chunks)
+		} else {
+			group.Error = err.Error()
+		}
+		report.Groups = append(report.Groups, *group)
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].ContentID < report.Groups[j].ContentID
+	})
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// mergeDuplicateGroup points a group's files at its canonical chunk set and deletes the extra copies
+func mergeDuplicateGroup(group *DuplicateGroup, report *DuplicateReport, metaStore *metadata.MetadataStore,
+	store storage.Storage, grace time.Duration) {
+
+	chunks, err := canonicalChunks(metaStore, store, group.ContentID)
+	if err != nil {
+		// Without a complete canonical set the copies may be the only good data left
+		group.Error = fmt.Sprintf("not merged: %v", err)
+		report.Errors = append(report.Errors, fmt.Sprintf("content %s %s", group.ContentID, group.Error))
+		return
+	}
+	group.CanonicalChunks = len(chunks)
+
+	// Every file in the group now shares one set of chunks, held so a
+	// concurrent purge cannot delete it while files are pointed at it
+	hashes := make([]string, len(chunks))
+	keys := make([]string, 0, 2*len(chunks))
+	for i, chunk := range chunks {
+		hashes[i] = chunk.Hash
+		keys = append(keys, chunk.Hash, chunk.Path)
+	}
+	release, _, err := metaStore.ChunkBarrier().Hold(context.Background(), keys...)
+	if err != nil {
+		group.Error = fmt.Sprintf("not merged: %v", err)
+		report.Errors = append(report.Errors, fmt.Sprintf("content %s %s", group.ContentID, group.Error))
+		return
+	}
+	defer release()
+	for _, fileID := range group.FileIDs {
+		file, err := metaStore.GetFileMetadataByID(fileID)
+		if err != nil {
+			group.Error = fmt.Sprintf("failed to reload %s: %v", fileID, err)
+			report.Errors = append(report.Errors, group.Error)
+			return
+		}
+		if file.CurrentContentID(fileID) != group.ContentID {
+			group.Error = fmt.Sprintf("file %s changed during the scan", fileID)
+			report.Errors = append(report.Errors, group.Error)
+			return
+		}
+		file.ChunkHashes = hashes
+		file.NumChunks = len(hashes)
+		if err := metaStore.PutFileMetadataByID(fileID, file); err != nil {
+			group.Error = fmt.Sprintf("failed to update %s: %v", fileID, err)
+			report.Errors = append(report.Errors, group.Error)
+			return
+		}
+	}
+
+	// Identical chunks may also be shared with unrelated files, so count every reference
+	files, err := metaStore.ListFileMetadataByID()
+	if err != nil {
+		group.Error = fmt.Sprintf("failed to count chunk references: %v", err)
+		report.Errors = append(report.Errors, group.Error)
+		return
+	}
+	references := make(map[string]int)
+	for _, file := range files {
+		for _, hash := range file.ChunkHashes {
+			references[hash]++
+		}
+	}
+	for _, chunk := range chunks {
+		chunk.RefCount = references[chunk.Hash]
+		if err := metaStore.PutChunkMetadata(chunk); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to update ref count of %s: %v", chunk.Hash, err))
+		}
+	}
+
+	deleter, ok := store.(storage.Deleter)
+	if !ok {
+		if len(group.RedundantCopies) > 0 {
+			group.Error = fmt.Sprintf("storage backend %T cannot delete copies", store)
+			report.Errors = append(report.Errors, group.Error)
+		}
+		return
+	}
+
+	// Uploads or updates may have run since the scan; claim the copies and
+	// re-read what is referenced now
+	barrier := metaStore.ChunkBarrier()
+	claimed := barrier.BeginDelete(group.RedundantCopies...)
+	defer barrier.EndDelete(claimed...)
+	referenced, err := chunker.ReferencedChunkPaths(metaStore)
+	if err != nil {
+		group.Error = err.Error()
+		report.Errors = append(report.Errors, group.Error)
+		return
+	}
+	for _, id := range claimed {
+		if referenced[id] || recentlyWritten(store, id, grace) {
+			continue
+		}
+		_, size, err := readStoredHeader(store, id)
+		if err != nil {
+			continue
+		}
+		if err := deleter.Delete(id); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to delete copy %s: %v", id, err))
+			continue
+		}
+		report.CopiesDeleted++
+		report.ReclaimedBytes += size
+	}
+	group.Merged = true
+}
+
+// canonicalChunks returns the chunk set a content is read from, in order,
+// failing unless every chunk is recorded and present in storage
+func canonicalChunks(metaStore *metadata.MetadataStore, store storage.Storage, contentID string) ([]metadata.ChunkMetadata, error) {
+	chunks, err := metaStore.GetChunksByFileID(contentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %v", err)
+	}
+	if err := metadata.ValidateChunkChain(chunks); err != nil {
+		return nil, fmt.Errorf("canonical chunk set is incomplete: %v", err)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Index < chunks[j].Index
+	})
+	for _, chunk := range chunks {
+		exists, err := store.Exists(chunk.Path)
+		if err != nil {
+			return nil, fmt.Errorf("canonical chunk %d is missing: %v", chunk.Index, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("canonical chunk %d is missing", chunk.Index)
+		}
+	}
+	return chunks, nil
+}
+
+// readStoredHeader reads the self-describing header of a stored chunk and its stored size
+func readStoredHeader(store storage.Storage, id string) (*chunker.ChunkHeader, int64, error) {
+	reader, err := store.Get(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	header, _, err := chunker.SplitChunk(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return header, int64(len(data)), nil
+}
@@ -0,0 +1,171 @@
+package dfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/storage/s3fake"
+)
+
+func TestFindDuplicatesReportsAndMergesSeededCopies(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	content := bytes.Repeat([]byte("duplicate content "), 3*int(chunker.MinChunkSize)/18+1)
+	input := filepath.Join(dir, "report.bin")
+	os.WriteFile(input, content, 0644)
+
+	// An upload that stored its chunks but never recorded them leaves a second full set behind
+	first, err := chunker.ChunkAndStore(input, "secret", metaStore, store)
+	if err != nil {
+		t.Fatalf("first upload failed: %v", err)
+	}
+	if _, err := chunker.ChunkAndStore(input, "secret", nil, store); err != nil {
+		t.Fatalf("second upload failed: %v", err)
+	}
+	fileID := first[0].FileID
+
+	// A second catalog entry with the same content under another name and owner
+	original, _ := metaStore.GetFileMetadataByID(fileID)
+	copyMeta := original
+	copyMeta.FileName = "copy-of-report.bin"
+	copyMeta.ContentID = fileID
+	metaStore.PutFileMetadataByID("copy", copyMeta)
+
+	report, err := FindDuplicates(metaStore, store, DuplicateOptions{})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(report.Groups) != 1 || report.DuplicateFiles != 1 {
+		t.Fatalf("expected one group with one duplicate file, got %+v", report)
+	}
+	group := report.Groups[0]
+	if group.ContentID != fileID || len(group.FileIDs) != 2 || len(group.RedundantCopies) != len(first) {
+		t.Errorf("unexpected group %+v", group)
+	}
+	if report.ReclaimableBytes <= 0 || report.CopiesDeleted != 0 || report.ReclaimedBytes != 0 {
+		t.Errorf("dry run should report savings without deleting: %+v", report)
+	}
+	for _, id := range group.RedundantCopies {
+		if _, err := store.Get(id); err != nil {
+			t.Errorf("dry run deleted %s: %v", id, err)
+		}
+	}
+
+	report, err = FindDuplicates(metaStore, store, DuplicateOptions{Merge: true, GracePeriod: -1})
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if report.CopiesDeleted != len(first) || report.ReclaimedBytes != report.ReclaimableBytes || len(report.Errors) != 0 {
+		t.Errorf("unexpected merge results: %+v", report)
+	}
+	if !report.Groups[0].Merged {
+		t.Errorf("group was not merged: %+v", report.Groups[0])
+	}
+
+	// Both entries keep their names and still read the content
+	for id, name := range map[string]string{fileID: "report.bin", "copy": "copy-of-report.bin"} {
+		meta, _ := metaStore.GetFileMetadataByID(id)
+		if meta.FileName != name {
+			t.Errorf("file %s renamed to %q", id, meta.FileName)
+		}
+		out := filepath.Join(dir, "out.bin")
+		if err := chunker.ReassembleFile(id, out, "secret", metaStore, store); err != nil {
+			t.Fatalf("reassembling %s after merge failed: %v", id, err)
+		}
+		if got, _ := os.ReadFile(out); !bytes.Equal(got, content) {
+			t.Errorf("content of %s changed after merge", id)
+		}
+	}
+
+	chunks, _ := metaStore.GetChunksByFileID(fileID)
+	for _, chunk := range chunks {
+		if chunk.RefCount != 2 {
+			t.Errorf("chunk %d should be shared by both files, ref count %d", chunk.Index, chunk.RefCount)
+		}
+	}
+
+	// Nothing is left to reclaim
+	report, _ = FindDuplicates(metaStore, store, DuplicateOptions{})
+	if report.ReclaimableBytes != 0 {
+		t.Errorf("expected no reclaimable bytes after merge, got %d", report.ReclaimableBytes)
+	}
+}
+
+func TestFindDuplicatesMergesOnS3(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	fake := s3fake.New(t)
+	store, err := storage.NewS3StorageWithOptions(storage.S3Options{Endpoint: fake.Endpoint(), Bucket: "chunks",
+		AccessKey: s3fake.AccessKey, SecretKey: s3fake.SecretKey})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	dir := t.TempDir()
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	content := bytes.Repeat([]byte("duplicate content "), 2*int(chunker.MinChunkSize)/18+1)
+	input := filepath.Join(dir, "report.bin")
+	os.WriteFile(input, content, 0644)
+	first, err := chunker.ChunkAndStore(input, "secret", metaStore, store)
+	if err != nil {
+		t.Fatalf("first upload failed: %v", err)
+	}
+	if _, err := chunker.ChunkAndStore(input, "secret", nil, store); err != nil {
+		t.Fatalf("second upload failed: %v", err)
+	}
+
+	// Objects have no local path, so the canonical set is checked with HEAD
+	// requests and the copies' age read from their Last-Modified time
+	report, err := FindDuplicates(metaStore, store, DuplicateOptions{Merge: true, GracePeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if len(report.Groups) != 1 || !report.Groups[0].Merged || len(report.Errors) != 0 {
+		t.Fatalf("expected the group merged, got %+v", report)
+	}
+	if report.CopiesDeleted != 0 {
+		t.Errorf("copies written within the grace period must be kept, deleted %d", report.CopiesDeleted)
+	}
+
+	for _, key := range fake.Keys("chunks") {
+		fake.SetModified("chunks", key, time.Now().Add(-2*time.Hour))
+	}
+	report, err = FindDuplicates(metaStore, store, DuplicateOptions{Merge: true, GracePeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if report.CopiesDeleted != len(first) || len(report.Errors) != 0 {
+		t.Errorf("expected the %d aged copies deleted, got %+v", len(first), report)
+	}
+	out := filepath.Join(dir, "out.bin")
+	if err := chunker.ReassembleFile(first[0].FileID, out, "secret", metaStore, store); err != nil {
+		t.Fatalf("reassembling after merge failed: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, content) {
+		t.Errorf("content changed after merge")
+	}
+}
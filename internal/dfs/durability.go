@@ -0,0 +1,155 @@
+package dfs
+
+import "fmt"
+
+// DurabilityReport is the fleet-wide replication state of tracked chunks
+type DurabilityReport struct {
+	TotalChunks       int     `json:"total_chunks"`
+	TotalReplicas     int     `json:"total_replicas"`
+	UnderReplicated   int     `json:"under_replicated"`
+	AtTarget          int     `json:"at_target"`
+	OverReplicated    int     `json:"over_replicated"`
+	FilesAtRisk       int     `json:"files_at_risk"`      // Files with at least one under-replicated chunk
+	DurabilityPercent float64 `json:"durability_percent"` // Desired replicas that exist, 100 with nothing tracked
+	MinReplicas       int     `json:"min_replicas"`       // Global target, 0 when unset
+}
+
+// chunkTally is what one chunk last contributed to the durability counters
+type chunkTally struct {
+	fileID           string
+	current, desired int
+}
+
+// durabilityTally keeps the durability counters up to date as replica info
+// changes, so a report costs no scan of the chunks. Guarded by replicaMu.
+type durabilityTally struct {
+	chunks            map[string]chunkTally
+	fileUnder         map[string]int // Under-replicated chunks per file
+	under, at, over   int
+	replicas          int
+	satisfied, wanted int // Replicas counted toward targets, and the targets
+}
+
+func (t *durabilityTally) apply(c chunkTally, sign int) {
+	t.replicas += sign * c.current
+	t.wanted += sign * c.desired
+	t.satisfied += sign * min(c.current, c.desired)
+	switch {
+	case c.current < c.desired:
+		t.under += sign
+		t.fileUnder[c.fileID] += sign
+		if t.fileUnder[c.fileID] == 0 {
+			delete(t.fileUnder, c.fileID)
+		}
+	case c.current > c.desired:
+		t.over += sign
+	default:
+		t.at += sign
+	}
+}
+
+func (t *durabilityTally) set(chunkID string, c chunkTally) {
+	if t.chunks == nil {
+		t.chunks = make(map[string]chunkTally)
+		t.fileUnder = make(map[string]int)
+	}
+	if old, ok := t.chunks[chunkID]; ok {
+		t.apply(old, -1)
+	}
+	t.chunks[chunkID] = c
+	t.apply(c, 1)
+}
+
+func (t *durabilityTally) remove(chunkID string) {
+	if old, ok := t.chunks[chunkID]; ok {
+		t.apply(old, -1)
+		delete(t.chunks, chunkID)
+	}
+}
+
+// retally refreshes a chunk's share of the durability counters and the zones
+// of its replicas after its replica info changed. Callers hold replicaMu.
+// Info no longer tracked, such as a chunk unregistered while it was being
+// verified, is ignored.
+func (dfs *DFSCore) retally(replica *ReplicaInfo) {
+	if dfs.replicaInfo[replica.ChunkID] != replica {
+		return
+	}
+	replica.Zones = dfs.replicaZones(replica.CurrentReplicas)
+	dfs.durability.set(replica.ChunkID, chunkTally{
+		fileID:  replica.FileID,
+		current: len(replica.CurrentReplicas),
+		desired: replica.DesiredReplicas,
+	})
+}
+
+// Durability reports how well tracked chunks meet their replication targets
+func (dfs *DFSCore) Durability() DurabilityReport {
+	dfs.replicaMu.RLock()
+	defer dfs.replicaMu.RUnlock()
+
+	t := &dfs.durability
+	report := DurabilityReport{
+		TotalChunks:       len(t.chunks),
+		TotalReplicas:     t.replicas,
+		UnderReplicated:   t.under,
+		AtTarget:          t.at,
+		OverReplicated:    t.over,
+		FilesAtRisk:       len(t.fileUnder),
+		DurabilityPercent: 100,
+		MinReplicas:       dfs.minReplicas,
+	}
+	if t.wanted > 0 {
+		report.DurabilityPercent = float64(t.satisfied) * 100 / float64(t.wanted)
+	}
+	return report
+}
+
+// MinReplicas returns the global minimum replication target, 0 when unset
+func (dfs *DFSCore) MinReplicas() int {
+	dfs.replicaMu.RLock()
+	defer dfs.replicaMu.RUnlock()
+	return dfs.minReplicas
+}
+
+// ReplicaTarget returns how many replicas a chunk registered now wants: the
+// configured default, raised to the global minimum
+func (dfs *DFSCore) ReplicaTarget() int {
+	dfs.replicaMu.RLock()
+	defer dfs.replicaMu.RUnlock()
+	return max(dfs.config.DefaultReplicaCount, dfs.minReplicas)
+}
+
+// SetMinReplicas sets a global minimum replication target. Tracked chunks
+// wanting fewer copies are raised to it and re-replication is scheduled for
+// those now short; chunks registered later start at it. Lowering the target
+// leaves existing chunks as they are. Returns how many chunks were scheduled.
+func (dfs *DFSCore) SetMinReplicas(replicas int) (int, error) {
+	if replicas < 0 {
+		return 0, fmt.Errorf("minimum replicas cannot be negative: %d", replicas)
+	}
+	if replicas > dfs.config.MaxReplicaCount {
+		return 0, fmt.Errorf("minimum replicas %d exceeds the maximum of %d", replicas, dfs.config.MaxReplicaCount)
+	}
+
+	shortfall := make(map[string]int)
+	dfs.replicaMu.Lock()
+	dfs.minReplicas = replicas
+	for chunkID, replica := range dfs.replicaInfo {
+		if replica.DesiredReplicas >= replicas {
+			continue
+		}
+		replica.DesiredReplicas = replicas
+		dfs.retally(replica)
+		if missing := replicas - len(replica.CurrentReplicas); missing > 0 {
+			shortfall[chunkID] = missing
+		}
+	}
+	dfs.replicaMu.Unlock()
+
+	dfs.logger.Infof("🎯 Minimum replication target set to %d, re-replicating %d chunks", replicas, len(shortfall))
+	for chunkID, missing := range shortfall {
+		go dfs.createAdditionalReplicas(chunkID, missing)
+	}
+	return len(shortfall), nil
+}
@@ -0,0 +1,85 @@
+package dfs
+
+import (
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+func TestDurabilityReportTracksUnderReplication(t *testing.T) {
+	// No node is known healthy, so nothing is re-replicated behind the test's back
+	dfs := NewDFSCore(nil, p2p.NewNetwork("127.0.0.1", 0), nil, nil, nil)
+
+	// check compares the report with counts from a full scan of replica info
+	check := func(stage string, want DurabilityReport) {
+		t.Helper()
+		got := dfs.Durability()
+		if got != want {
+			t.Errorf("%s: expected %+v, got %+v", stage, want, got)
+		}
+		stats := dfs.GetSystemStats()
+		if stats["under_replicated"] != got.UnderReplicated || stats["over_replicated"] != got.OverReplicated {
+			t.Errorf("%s: report disagrees with a scan: under %v over %v", stage, stats["under_replicated"], stats["over_replicated"])
+		}
+	}
+
+	check("empty", DurabilityReport{DurabilityPercent: 100})
+
+	// The default target is 3 copies
+	dfs.RegisterChunk("a0", "file-a", []string{"n1", "n2", "n3"})
+	dfs.RegisterChunk("a1", "file-a", []string{"n1", "n2", "n3"})
+	dfs.RegisterChunk("b0", "file-b", []string{"n1", "n2", "n3", "n4"})
+	dfs.RegisterChunk("c0", "file-c", []string{"n1"})
+	check("registered", DurabilityReport{
+		TotalChunks: 4, TotalReplicas: 11, UnderReplicated: 1, AtTarget: 2, OverReplicated: 1,
+		FilesAtRisk: 1, DurabilityPercent: 100 * 10.0 / 12,
+	})
+
+	// Losing a node leaves every chunk on it a copy short
+	for _, chunkID := range dfs.findChunksOnNode("n2") {
+		dfs.recoverChunk(chunkID, "n2")
+	}
+	check("node lost", DurabilityReport{
+		TotalChunks: 4, TotalReplicas: 8, UnderReplicated: 3, AtTarget: 1,
+		FilesAtRisk: 2, DurabilityPercent: 100 * 8.0 / 12,
+	})
+
+	// Dropping a chunk takes its shortfall with it
+	dfs.UnregisterChunk("c0")
+	check("unregistered", DurabilityReport{
+		TotalChunks: 3, TotalReplicas: 7, UnderReplicated: 2, AtTarget: 1,
+		FilesAtRisk: 1, DurabilityPercent: 100 * 7.0 / 9,
+	})
+
+	// Raising the fleet target leaves every remaining chunk short
+	if target := dfs.ReplicaTarget(); target != 3 {
+		t.Errorf("expected the default replica target of 3, got %d", target)
+	}
+	scheduled, err := dfs.SetMinReplicas(4)
+	if err != nil {
+		t.Fatalf("SetMinReplicas failed: %v", err)
+	}
+	if target := dfs.ReplicaTarget(); target != 4 {
+		t.Errorf("expected the replica target raised to 4, got %d", target)
+	}
+	if scheduled != 3 {
+		t.Errorf("expected 3 chunks scheduled for re-replication, got %d", scheduled)
+	}
+	check("target raised", DurabilityReport{
+		TotalChunks: 3, TotalReplicas: 7, UnderReplicated: 3,
+		FilesAtRisk: 2, DurabilityPercent: 100 * 7.0 / 12, MinReplicas: 4,
+	})
+
+	// New chunks start at the raised target, and replicas restored count again
+	dfs.RegisterChunk("d0", "file-d", []string{"n1", "n2", "n3", "n4"})
+	dfs.createReplicaOnNode("a0", "n5")
+	dfs.createReplicaOnNode("a0", "n6")
+	check("restored", DurabilityReport{
+		TotalChunks: 4, TotalReplicas: 13, UnderReplicated: 2, AtTarget: 2,
+		FilesAtRisk: 2, DurabilityPercent: 100 * 13.0 / 16, MinReplicas: 4,
+	})
+
+	if _, err := dfs.SetMinReplicas(DefaultDFSConfig().MaxReplicaCount + 1); err == nil {
+		t.Errorf("expected a target above the maximum replica count to be rejected")
+	}
+}
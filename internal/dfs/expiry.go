@@ -0,0 +1,159 @@
+package dfs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// ValidateTTL checks a requested file lifetime against the configured maximum; 0 maxTTL means no cap
+func ValidateTTL(ttl, maxTTL time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("TTL must be positive, got %v", ttl)
+	}
+	if maxTTL > 0 && ttl > maxTTL {
+		return fmt.Errorf("TTL %v exceeds the maximum of %v", ttl, maxTTL)
+	}
+	return nil
+}
+
+// ExpiryOptions controls an expiry pass
+type ExpiryOptions struct {
+	PurgeDelay time.Duration `json:"purge_delay"` // How long expired files stay soft-deleted before they are purged
+	Now        time.Time     `json:"-"`           // Time the pass runs at; zero uses the current time
+}
+
+// ExpiryReport is the result of an expiry pass
+type ExpiryReport struct {
+	ScannedAt      time.Time     `json:"scanned_at"`
+	Duration       time.Duration `json:"duration"`
+	FilesScanned   int           `json:"files_scanned"`
+	Expired        []string      `json:"expired"` // Files soft-deleted by this pass
+	Purged         []string      `json:"purged"`  // Files whose metadata and unshared chunks were removed
+	ChunksDeleted  int           `json:"chunks_deleted"`
+	ReclaimedBytes int64         `json:"reclaimed_bytes"`
+	Errors         []string      `json:"errors,omitempty"`
+}
+
+// ExpireFiles runs an expiry pass over the core's catalog. Expired files are
+// also marked deleted in the enhanced metadata; purged files are dropped from
+// the replica registry and peers, and passed to the purge hook.
+func (dfs *DFSCore) ExpireFiles() (*ExpiryReport, error) {
+	report, err := ExpireFiles(dfs.metaStore, dfs.storage, ExpiryOptions{PurgeDelay: dfs.config.ExpiryPurgeDelay})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileID := range report.Expired {
+		if dfs.OptimizedStorage == nil {
+			break
+		}
+		meta, err := dfs.OptimizedStorage.LoadFileMetadata(fileID)
+		if err != nil {
+			continue
+		}
+		deletedAt := report.ScannedAt
+		meta.IsDeleted = true
+		meta.DeletedAt = &deletedAt
+		if err := dfs.OptimizedStorage.StoreFileMetadata(meta); err != nil {
+			dfs.logger.Warnf("⚠️ Failed to mark %s deleted: %v", fileID, err)
+		}
+	}
+	// Purged files leave the replica registry and peers' storage too
+	for _, fileID := range report.Purged {
+		dfs.forgetPurgedFile(fileID)
+	}
+
+	if len(report.Expired) > 0 || len(report.Purged) > 0 {
+		dfs.logger.Infof("⌛ Expiry pass: %d expired, %d purged, %d bytes reclaimed",
+			len(report.Expired), len(report.Purged), report.ReclaimedBytes)
+		dfs.events.Publish(events.TypeFileExpired, "dfs", "Expired files removed", map[string]interface{}{
+			"expired":         report.Expired,
+			"purged":          report.Purged,
+			"reclaimed_bytes": report.ReclaimedBytes,
+		})
+	}
+	return report, nil
+}
+
+// SetPurgeHook registers a function called with each file the expiry pass or
+// a hard delete purges, so caches and in-memory records outside the catalog can drop it
+func (dfs *DFSCore) SetPurgeHook(hook func(fileID string)) {
+	dfs.purgeHook = hook
+}
+
+// ExpireFiles soft-deletes files whose TTL has elapsed and purges files that
+// have been soft-deleted for longer than the purge delay. Soft-deleted files
+// are no longer listed or served. Purging removes the file's metadata and
+// versions first, then deletes the chunks no other file still references, so
+// an interrupted pass leaves at most unreferenced chunks behind.
+func ExpireFiles(metaStore *metadata.MetadataStore, store storage.Storage, opts ExpiryOptions) (*ExpiryReport, error) {
+	if metaStore == nil {
+		return nil, fmt.Errorf("expiry pass needs a metadata store")
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	start := time.Now()
+	report := &ExpiryReport{
+		ScannedAt: now,
+		Expired:   make([]string, 0),
+		Purged:    make([]string, 0),
+	}
+
+	files, err := metaStore.ListFileMetadataByID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file metadata: %v", err)
+	}
+	report.FilesScanned = len(files)
+
+	fileIDs := make([]string, 0, len(files))
+	for fileID := range files {
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+
+	for _, fileID := range fileIDs {
+		file := files[fileID]
+		if file.DeletedAt == 0 {
+			if !file.Expired(now) {
+				continue
+			}
+			file.DeletedAt = now.Unix()
+			if err := metaStore.PutFileMetadataByID(fileID, file); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to soft-delete %s: %v", fileID, err))
+				continue
+			}
+			report.Expired = append(report.Expired, fileID)
+		}
+
+		if now.Sub(time.Unix(file.DeletedAt, 0)) < opts.PurgeDelay {
+			continue
+		}
+		if err := purgeFile(metaStore, store, fileID, file, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to purge %s: %v", fileID, err))
+			continue
+		}
+		report.Purged = append(report.Purged, fileID)
+	}
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// purgeFile removes a file's metadata and the chunks only it referenced,
+// adding what it removed to report
+func purgeFile(metaStore *metadata.MetadataStore, store storage.Storage, fileID string, file metadata.FileMetadata, report *ExpiryReport) error {
+	purged, err := chunker.PurgeFile(metaStore, store, fileID, file)
+	report.ChunksDeleted += purged.ChunksDeleted
+	report.ReclaimedBytes += purged.ReclaimedBytes
+	report.Errors = append(report.Errors, purged.Errors...)
+	return err
+}
@@ -0,0 +1,255 @@
+package dfs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestExpireFilesSoftDeletesThenPurgesExpiredFiles(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	upload := func(name string, content []byte) []chunker.ChunkMetadata {
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, content, 0644)
+		chunks, err := chunker.ChunkAndStore(input, "secret", metaStore, store)
+		if err != nil {
+			t.Fatalf("upload of %s failed: %v", name, err)
+		}
+		return chunks
+	}
+	ephemeral := upload("ephemeral.bin", bytes.Repeat([]byte("short lived "), 2*int(chunker.MinChunkSize)/12+1))
+	kept := upload("kept.bin", bytes.Repeat([]byte("long lived "), 2*int(chunker.MinChunkSize)/11+1))
+	fileID := ephemeral[0].FileID
+
+	if err := ValidateTTL(2*time.Hour, time.Hour); err == nil {
+		t.Errorf("expected a TTL above the maximum to be rejected")
+	}
+	if err := ValidateTTL(time.Second, time.Hour); err != nil {
+		t.Fatalf("expected a one second TTL to be accepted: %v", err)
+	}
+	if err := metaStore.SetFileExpiry(fileID, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("SetFileExpiry failed: %v", err)
+	}
+
+	meta, _ := metaStore.GetFileMetadataByID(fileID)
+	if !meta.Available(time.Now()) || meta.Remaining(time.Now()) <= 0 {
+		t.Fatalf("file should be live until its TTL elapses: %+v", meta)
+	}
+
+	// With a purge delay the first pass after the TTL only soft-deletes
+	later := time.Now().Add(2 * time.Second)
+	report, err := ExpireFiles(metaStore, store, ExpiryOptions{PurgeDelay: time.Hour, Now: later})
+	if err != nil {
+		t.Fatalf("expiry pass failed: %v", err)
+	}
+	if len(report.Expired) != 1 || report.Expired[0] != fileID || len(report.Purged) != 0 {
+		t.Fatalf("expected only %s to be soft-deleted, got %+v", fileID, report)
+	}
+	err = chunker.ReassembleFile(fileID, filepath.Join(dir, "out.bin"), "secret", metaStore, store)
+	if !errors.Is(err, metadata.ErrFileExpired) {
+		t.Fatalf("expected downloads of an expired file to fail with ErrFileExpired, got %v", err)
+	}
+
+	report, err = ExpireFiles(metaStore, store, ExpiryOptions{Now: later})
+	if err != nil {
+		t.Fatalf("purge pass failed: %v", err)
+	}
+	if len(report.Purged) != 1 || report.ChunksDeleted != len(ephemeral) || report.ReclaimedBytes == 0 {
+		t.Fatalf("expected every chunk of %s to be purged, got %+v", fileID, report)
+	}
+
+	if _, err := metaStore.GetFileMetadataByID(fileID); err == nil {
+		t.Errorf("file metadata should be gone after the purge")
+	}
+	if _, err := metaStore.GetFileMetadata("ephemeral.bin"); err == nil {
+		t.Errorf("file name entry should be gone after the purge")
+	}
+	for _, chunk := range ephemeral {
+		if _, err := metaStore.GetChunkMetadata(chunk.Hash); err == nil {
+			t.Errorf("chunk record %s should be gone after the purge", chunk.Hash)
+		}
+		if rc, err := store.Get(chunk.Path); err == nil {
+			rc.Close()
+			t.Errorf("chunk %s should be deleted after the purge", chunk.Path)
+		}
+	}
+
+	// Unrelated files are untouched
+	out := filepath.Join(dir, "kept-out.bin")
+	if err := chunker.ReassembleFile(kept[0].FileID, out, "secret", metaStore, store); err != nil {
+		t.Fatalf("unexpired file is no longer readable: %v", err)
+	}
+}
+
+// gatedStore pauses the first read, existence check or Delete of a chunk while armed
+type gatedStore struct {
+	*storage.LocalStorage
+	gate   string // "get" or "delete"
+	paused chan struct{}
+	resume chan struct{}
+	once   sync.Once
+}
+
+func (s *gatedStore) pause(op string) {
+	if s.gate == op {
+		s.once.Do(func() {
+			close(s.paused)
+			<-s.resume
+		})
+	}
+}
+
+func (s *gatedStore) Get(id string) (io.ReadCloser, error) {
+	s.pause("get")
+	return s.LocalStorage.Get(id)
+}
+
+func (s *gatedStore) Exists(id string) (bool, error) {
+	s.pause("get")
+	return s.LocalStorage.Exists(id)
+}
+
+func (s *gatedStore) Delete(id string) error {
+	s.pause("delete")
+	return s.LocalStorage.Delete(id)
+}
+
+func TestPurgeRacingANewReferenceLosesNoSharedChunk(t *testing.T) {
+	dir := t.TempDir()
+	local, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	// Client-encrypted chunks are stored as is, so identical ciphertext is one shared chunk
+	storeBlobs := func(store storage.Storage, name string, blobs [][]byte) (string, error) {
+		manifest := metadata.ClientManifest{FileName: name, Algorithm: "test"}
+		readers := make([]io.Reader, len(blobs))
+		for i, blob := range blobs {
+			sum := sha256.Sum256(blob)
+			manifest.Chunks = append(manifest.Chunks, metadata.ClientChunk{Index: i, Size: int64(len(blob)), Hash: hex.EncodeToString(sum[:])})
+			manifest.FileSize += int64(len(blob))
+			readers[i] = bytes.NewReader(blob)
+		}
+		return chunker.StoreClientEncrypted(manifest, readers, metaStore, store)
+	}
+	newBlobs := func() [][]byte {
+		blobs := make([][]byte, 3)
+		for i := range blobs {
+			blobs[i] = make([]byte, 4096)
+			rand.Read(blobs[i])
+		}
+		return blobs
+	}
+	checkIntact := func(fileID string, blobs [][]byte) {
+		t.Helper()
+		file, err := metaStore.GetFileMetadataByID(fileID)
+		if err != nil {
+			t.Fatalf("new file lost: %v", err)
+		}
+		for i, hash := range file.ChunkHashes {
+			chunk, err := metaStore.GetChunkMetadata(hash)
+			if err != nil {
+				t.Fatalf("chunk %d of the new file has a dangling reference: %v", i, err)
+			}
+			reader, err := local.Get(chunk.Path)
+			if err != nil {
+				t.Fatalf("chunk %d of the new file was deleted: %v", i, err)
+			}
+			data, _ := io.ReadAll(reader)
+			reader.Close()
+			if !bytes.Equal(data, blobs[i]) {
+				t.Fatalf("chunk %d of the new file holds the wrong data", i)
+			}
+		}
+	}
+
+	// The new file holds its chunks first: the purge must leave the shared ones alone
+	blobs := newBlobs()
+	oldID, err := storeBlobs(local, "old.bin", blobs[:2])
+	if err != nil {
+		t.Fatalf("storing the old file failed: %v", err)
+	}
+	old, _ := metaStore.GetFileMetadataByID(oldID)
+	gated := &gatedStore{LocalStorage: local, gate: "get", paused: make(chan struct{}), resume: make(chan struct{})}
+	var newID string
+	var storeErr error
+	stored := make(chan struct{})
+	go func() {
+		defer close(stored)
+		newID, storeErr = storeBlobs(gated, "new.bin", blobs)
+	}()
+	<-gated.paused
+	report := &ExpiryReport{}
+	if err := purgeFile(metaStore, gated, oldID, old, report); err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if report.ChunksDeleted != 0 {
+		t.Errorf("expected chunks held by an upload in progress kept, %d deleted", report.ChunksDeleted)
+	}
+	close(gated.resume)
+	<-stored
+	if storeErr != nil {
+		t.Fatalf("storing the new file failed: %v", storeErr)
+	}
+	checkIntact(newID, blobs)
+
+	// The purge claims its chunks first: the new file waits, then stores them again
+	blobs = newBlobs()
+	oldID, err = storeBlobs(local, "old2.bin", blobs[:2])
+	if err != nil {
+		t.Fatalf("storing the old file failed: %v", err)
+	}
+	old, _ = metaStore.GetFileMetadataByID(oldID)
+	gated = &gatedStore{LocalStorage: local, gate: "delete", paused: make(chan struct{}), resume: make(chan struct{})}
+	purged := make(chan error)
+	go func() { purged <- purgeFile(metaStore, gated, oldID, old, &ExpiryReport{}) }()
+	<-gated.paused
+	stored = make(chan struct{})
+	go func() {
+		defer close(stored)
+		newID, storeErr = storeBlobs(local, "new2.bin", blobs)
+	}()
+	// Give the new file time to reach the barrier before the deletion goes ahead
+	time.Sleep(50 * time.Millisecond)
+	close(gated.resume)
+	if err := <-purged; err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	<-stored
+	if storeErr != nil {
+		t.Fatalf("storing the new file failed: %v", storeErr)
+	}
+	checkIntact(newID, blobs)
+}
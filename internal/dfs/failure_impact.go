@@ -0,0 +1,133 @@
+package dfs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FailureImpact predicts what losing a set of nodes would do to tracked
+// chunks, computed from replica info without changing anything
+type FailureImpact struct {
+	Nodes           []string            `json:"nodes"`
+	ChunksOnNodes   int                 `json:"chunks_on_nodes"`  // Chunks with a replica on any of the nodes
+	UnderReplicated int                 `json:"under_replicated"` // Chunks that would fall below their target but survive
+	Lost            int                 `json:"lost"`             // Chunks that would have no replica left
+	Files           []FileFailureImpact `json:"files"`            // Files left at risk, unrecoverable first
+	Recommendations []ReplicationAdvice `json:"recommendations"`  // Copies to make elsewhere before the nodes go
+	ReplicasToAdd   int                 `json:"replicas_to_add"`
+}
+
+// FileFailureImpact is how one file would be affected
+type FileFailureImpact struct {
+	FileID          string `json:"file_id"`
+	FileName        string `json:"file_name,omitempty"`
+	UnderReplicated int    `json:"under_replicated_chunks"`
+	LostChunks      int    `json:"lost_chunks"`
+	Unrecoverable   bool   `json:"unrecoverable"`
+}
+
+// ReplicationAdvice recommends copying a chunk to nodes outside the failing set
+type ReplicationAdvice struct {
+	ChunkID        string   `json:"chunk_id"`
+	FileID         string   `json:"file_id"`
+	SurvivingAfter int      `json:"surviving_after"`
+	Desired        int      `json:"desired_replicas"`
+	AddReplicas    int      `json:"add_replicas"`
+	CopyFrom       []string `json:"copy_from"` // Nodes holding the chunk now
+}
+
+// SimulateNodeFailure reports which chunks and files would drop below their
+// replication target or become unrecoverable if nodeIDs were removed
+func (dfs *DFSCore) SimulateNodeFailure(nodeIDs []string) (*FailureImpact, error) {
+	if len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("at least one node ID is required")
+	}
+	failing := make(map[string]bool, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		failing[nodeID] = true
+	}
+
+	impact := &FailureImpact{
+		Nodes:           nodeIDs,
+		Files:           make([]FileFailureImpact, 0),
+		Recommendations: make([]ReplicationAdvice, 0),
+	}
+	files := make(map[string]*FileFailureImpact)
+
+	dfs.replicaMu.RLock()
+	for chunkID, replica := range dfs.replicaInfo {
+		surviving, onFailing := 0, false
+		for _, nodeID := range replica.CurrentReplicas {
+			if failing[nodeID] {
+				onFailing = true
+			} else {
+				surviving++
+			}
+		}
+		if !onFailing {
+			continue
+		}
+		impact.ChunksOnNodes++
+		if surviving >= replica.DesiredReplicas {
+			continue
+		}
+
+		file := files[replica.FileID]
+		if file == nil {
+			file = &FileFailureImpact{FileID: replica.FileID}
+			files[replica.FileID] = file
+		}
+		if surviving == 0 {
+			impact.Lost++
+			file.LostChunks++
+			file.Unrecoverable = true
+		} else {
+			impact.UnderReplicated++
+			file.UnderReplicated++
+		}
+
+		advice := ReplicationAdvice{
+			ChunkID:        chunkID,
+			FileID:         replica.FileID,
+			SurvivingAfter: surviving,
+			Desired:        replica.DesiredReplicas,
+			AddReplicas:    replica.DesiredReplicas - surviving,
+			CopyFrom:       append([]string(nil), replica.CurrentReplicas...),
+		}
+		impact.ReplicasToAdd += advice.AddReplicas
+		impact.Recommendations = append(impact.Recommendations, advice)
+	}
+	dfs.replicaMu.RUnlock()
+
+	if len(files) > 0 && dfs.OptimizedStorage != nil {
+		if catalog, err := dfs.OptimizedStorage.ListFileMetadata(); err == nil {
+			for _, meta := range catalog {
+				if file := files[meta.FileID]; file != nil {
+					file.FileName = meta.FileName
+				}
+			}
+		}
+	}
+	for _, file := range files {
+		impact.Files = append(impact.Files, *file)
+	}
+	sort.Slice(impact.Files, func(i, j int) bool {
+		a, b := impact.Files[i], impact.Files[j]
+		if a.Unrecoverable != b.Unrecoverable {
+			return a.Unrecoverable
+		}
+		if a.LostChunks+a.UnderReplicated != b.LostChunks+b.UnderReplicated {
+			return a.LostChunks+a.UnderReplicated > b.LostChunks+b.UnderReplicated
+		}
+		return a.FileID < b.FileID
+	})
+	// Chunks about to be lost need copies most urgently
+	sort.Slice(impact.Recommendations, func(i, j int) bool {
+		a, b := impact.Recommendations[i], impact.Recommendations[j]
+		if a.SurvivingAfter != b.SurvivingAfter {
+			return a.SurvivingAfter < b.SurvivingAfter
+		}
+		return a.ChunkID < b.ChunkID
+	})
+	return impact, nil
+}
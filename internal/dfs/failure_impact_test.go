@@ -0,0 +1,57 @@
+package dfs
+
+import (
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+func TestSimulatedNodeFailureMatchesActualRemoval(t *testing.T) {
+	// No node is known healthy, so the real failure leaves the gaps unrepaired
+	dfs := NewDFSCore(nil, p2p.NewNetwork("127.0.0.1", 0), nil, nil, nil)
+	dfs.RegisterChunk("a0", "file-a", []string{"n1", "n2", "n3"})
+	dfs.RegisterChunk("a1", "file-a", []string{"n1", "n3", "n4"})
+	dfs.RegisterChunk("b0", "file-b", []string{"n2"})
+	dfs.RegisterChunk("c0", "file-c", []string{"n1", "n3", "n4"})
+	dfs.RegisterChunk("d0", "file-d", []string{"n2", "n3", "n4", "n5"})
+
+	before := dfs.Durability()
+	impact, err := dfs.SimulateNodeFailure([]string{"n2"})
+	if err != nil {
+		t.Fatalf("SimulateNodeFailure failed: %v", err)
+	}
+	if after := dfs.Durability(); after != before {
+		t.Fatalf("simulation changed replica state: %+v became %+v", before, after)
+	}
+
+	if impact.ChunksOnNodes != 3 || impact.UnderReplicated != 1 || impact.Lost != 1 {
+		t.Errorf("expected 3 chunks on n2, 1 under target and 1 lost, got %+v", impact)
+	}
+	if len(impact.Files) != 2 || impact.Files[0].FileID != "file-b" || !impact.Files[0].Unrecoverable ||
+		impact.Files[1].FileID != "file-a" || impact.Files[1].Unrecoverable {
+		t.Errorf("expected file-b unrecoverable then file-a at risk, got %+v", impact.Files)
+	}
+	if len(impact.Recommendations) != 2 || impact.Recommendations[0].ChunkID != "b0" || impact.Recommendations[0].AddReplicas != 3 ||
+		impact.Recommendations[1].ChunkID != "a0" || impact.Recommendations[1].AddReplicas != 1 || impact.ReplicasToAdd != 4 {
+		t.Errorf("expected copies of b0 then a0 recommended, got %+v", impact.Recommendations)
+	}
+
+	// Now lose the node for real
+	for _, chunkID := range dfs.findChunksOnNode("n2") {
+		dfs.recoverChunk(chunkID, "n2")
+	}
+	for _, advice := range impact.Recommendations {
+		if got := len(dfs.GetReplicaInfo(advice.ChunkID).CurrentReplicas); got != advice.SurvivingAfter {
+			t.Errorf("chunk %s: predicted %d replicas left, %d remain", advice.ChunkID, advice.SurvivingAfter, got)
+		}
+	}
+	report := dfs.Durability()
+	if report.UnderReplicated != impact.UnderReplicated+impact.Lost || report.FilesAtRisk != len(impact.Files) {
+		t.Errorf("predicted %d short chunks in %d files, removal left %+v",
+			impact.UnderReplicated+impact.Lost, len(impact.Files), report)
+	}
+
+	if _, err := dfs.SimulateNodeFailure(nil); err == nil {
+		t.Errorf("expected an empty node set rejected")
+	}
+}
@@ -1,21 +1,27 @@
 package dfs
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"time"
 
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
 	"github.com/jaywantadh/DisktroByte/internal/compressor"
 	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/failures"
 	"github.com/jaywantadh/DisktroByte/internal/encryptor"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
 	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/parallelism"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
 	"github.com/sirupsen/logrus"
 )
@@ -34,7 +40,71 @@ type ReassemblyJob struct {
 	CompletionTime  time.Time                 `json:"completion_time"`
 	ChunkStatus     map[string]string         `json:"chunk_status"`    // chunk_id -> status
 	IntegrityCheck  *IntegrityCheckResult     `json:"integrity_check"`
+	VerifyStats     *chunker.VerifyStats      `json:"verify_stats,omitempty"` // Parallel chunk verification throughput
+	Sources         []PeerContribution        `json:"sources,omitempty"`      // Each peer's share of a multi-source download
 	ErrorMessage    string                    `json:"error_message"`
+	
+	mu              sync.Mutex // Guards the fields the running reassembly changes
+}
+
+// update changes the job under its lock
+func (job *ReassemblyJob) update(change func()) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	change()
+}
+
+// fail marks the job failed with message
+func (job *ReassemblyJob) fail(message string) {
+	job.update(func() {
+		job.Status = "failed"
+		job.ErrorMessage = message
+	})
+}
+
+// setChunkStatus records the status of one of the job's chunks
+func (job *ReassemblyJob) setChunkStatus(chunkID, status string) {
+	job.update(func() { job.ChunkStatus[chunkID] = status })
+}
+
+// Snapshot returns a copy of the job that is safe to read, and encode,
+// while the reassembly is still running
+func (job *ReassemblyJob) Snapshot() *ReassemblyJob {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	
+	snapshot := &ReassemblyJob{
+		ID:             job.ID,
+		FileID:         job.FileID,
+		FileName:       job.FileName,
+		OutputPath:     job.OutputPath,
+		TotalChunks:    job.TotalChunks,
+		ChunksObtained: job.ChunksObtained,
+		Status:         job.Status,
+		Progress:       job.Progress,
+		StartTime:      job.StartTime,
+		CompletionTime: job.CompletionTime,
+		ChunkStatus:    make(map[string]string, len(job.ChunkStatus)),
+		Sources:        append([]PeerContribution(nil), job.Sources...),
+		ErrorMessage:   job.ErrorMessage,
+	}
+	for chunkID, status := range job.ChunkStatus {
+		snapshot.ChunkStatus[chunkID] = status
+	}
+	if job.IntegrityCheck != nil {
+		check := *job.IntegrityCheck
+		check.ChunkHashes = make(map[string]string, len(job.IntegrityCheck.ChunkHashes))
+		for chunkID, hash := range job.IntegrityCheck.ChunkHashes {
+			check.ChunkHashes[chunkID] = hash
+		}
+		check.CorruptedChunks = append([]string(nil), job.IntegrityCheck.CorruptedChunks...)
+		snapshot.IntegrityCheck = &check
+	}
+	if job.VerifyStats != nil {
+		stats := *job.VerifyStats
+		snapshot.VerifyStats = &stats
+	}
+	return snapshot
 }
 
 // IntegrityCheckResult represents the result of integrity verification
@@ -50,6 +120,7 @@ type IntegrityCheckResult struct {
 // ChunkDownloadResult represents the result of downloading a chunk
 type ChunkDownloadResult struct {
 	ChunkID   string
+	Index     int    // Position of the chunk in the file
 	Success   bool
 	Data      []byte
 	Hash      string
@@ -64,9 +135,14 @@ type FileReassembler struct {
 	storage      storage.Storage
 	metaStore    *metadata.MetadataStore
 	network      *p2p.Network
+	ring         *HashRing // Optional placement ring used to predict chunk holders
+	failureLog   *failures.Log
+	keyManager   *encryptor.KeyManager // Unwraps the data keys of server-managed files
+	multiSource  MultiSourceOptions
 	logger       *logrus.Logger
 	
 	// Job management
+	jobsMu       sync.RWMutex // Guards activeJobs and jobHistory
 	activeJobs   map[string]*ReassemblyJob
 	jobHistory   []*ReassemblyJob
 	maxHistory   int
@@ -92,9 +168,50 @@ func NewFileReassembler(dfsCore *DFSCore, distributor *distributor.Distributor,
 	}
 }
 
+// SetHashRing lets the reassembler try the nodes a consistent-hash ring places a chunk on
+// before falling back to asking every peer
+func (fr *FileReassembler) SetHashRing(ring *HashRing) {
+	fr.ring = ring
+}
+
+// SetFailureLog records failed reassembly jobs in log
+func (fr *FileReassembler) SetFailureLog(log *failures.Log) {
+	fr.failureLog = log
+}
+
+// SetKeyManager lets the reassembler decrypt files with a server-managed key
+func (fr *FileReassembler) SetKeyManager(km *encryptor.KeyManager) {
+	fr.keyManager = km
+}
+
+// SetMultiSource makes reassembly download different chunks from different
+// holders at once, within the given bounds, instead of one holder per chunk
+func (fr *FileReassembler) SetMultiSource(opts MultiSourceOptions) {
+	fr.multiSource = opts.normalize()
+}
+
+// recordFailure adds a failed job stage to the failure log, if one is set
+func (fr *FileReassembler) recordFailure(job *ReassemblyJob, stage string, err error) {
+	failure := failures.Failure{Operation: failures.OpReassemble, Stage: stage, FileID: job.FileID}
+	if recErr := fr.failureLog.Record(context.Background(), failure, err); recErr != nil {
+		fr.logger.Warnf("⚠️ Failed to record reassembly failure: %v", recErr)
+	}
+}
+
 // ReassembleFile starts the process of reassembling a distributed file
 func (fr *FileReassembler) ReassembleFile(fileID, outputPath, password string) (*ReassemblyJob, error) {
+	return fr.ReassembleFileWithRelease(fileID, outputPath, password, nil)
+}
+
+// ReassembleFileWithRelease starts a reassembly like ReassembleFile and calls
+// release once the job has finished, successfully or not. release is not
+// called when the job fails to start, as in strict encryption mode, where
+// writing the plaintext to outputPath is refused.
+func (fr *FileReassembler) ReassembleFileWithRelease(fileID, outputPath, password string, release func()) (*ReassemblyJob, error) {
 	fr.logger.Infof("🔧 Starting reassembly of file %s", fileID)
+	if err := encryptor.RefusePlaintext("reassembled output " + outputPath); err != nil {
+		return nil, err
+	}
 	
 	// Get file information
 	fileInfo, err := fr.distributor.GetFileInfo(fileID)
@@ -125,10 +242,17 @@ func (fr *FileReassembler) ReassembleFile(fileID, outputPath, password string) (
 		job.ChunkStatus[chunkID] = "pending"
 	}
 	
+	fr.jobsMu.Lock()
 	fr.activeJobs[job.ID] = job
+	fr.jobsMu.Unlock()
 	
 	// Start reassembly process asynchronously
-	go fr.executeReassembly(job, fileInfo, password)
+	go func() {
+		if release != nil {
+			defer release()
+		}
+		fr.executeReassembly(job, fileInfo, password)
+	}()
 	
 	return job, nil
 }
@@ -137,87 +261,136 @@ func (fr *FileReassembler) ReassembleFile(fileID, outputPath, password string) (
 func (fr *FileReassembler) executeReassembly(job *ReassemblyJob, fileInfo *distributor.FileInfo, password string) {
 	defer fr.moveJobToHistory(job)
 	
-	job.Status = "downloading"
+	job.update(func() { job.Status = "downloading" })
 	fr.logger.Infof("📥 Downloading %d chunks for file %s", job.TotalChunks, job.FileName)
 	
-	// Download all chunks with parallel processing
-	chunkData, err := fr.downloadAllChunks(job, fileInfo.Chunks)
-	if err != nil {
-		job.Status = "failed"
-		job.ErrorMessage = fmt.Sprintf("Failed to download chunks: %v", err)
-		fr.logger.Errorf("❌ Failed to download chunks for %s: %v", job.FileName, err)
-		return
-	}
-	
-	job.Status = "assembling"
-	job.Progress = 50.0
-	fr.logger.Infof("🔨 Assembling file %s from %d chunks", job.FileName, len(chunkData))
-	
-	// Assemble the file from chunks
-	if err := fr.assembleFile(job, chunkData, password); err != nil {
-		job.Status = "failed"
-		job.ErrorMessage = fmt.Sprintf("Failed to assemble file: %v", err)
-		fr.logger.Errorf("❌ Failed to assemble file %s: %v", job.FileName, err)
+	if fr.multiSource.Enabled {
+		// Chunks stream from every holder into assembly as they arrive
+		if err := fr.downloadAndAssemble(job, fileInfo.Chunks, password); err != nil {
+			job.fail(fmt.Sprintf("Failed to assemble file: %v", err))
+			fr.recordFailure(job, "assemble", err)
+			fr.logger.Errorf("❌ Failed to assemble file %s: %v", job.FileName, err)
+			return
+		}
+	} else if err := fr.downloadAndAssembleSequential(job, fileInfo.Chunks, password); err != nil {
 		return
 	}
 	
-	job.Status = "verifying"
-	job.Progress = 85.0
+	job.update(func() {
+		job.Status = "verifying"
+		job.Progress = 85.0
+	})
 	fr.logger.Infof("🔍 Verifying integrity of reassembled file %s", job.FileName)
 	
 	// Verify file integrity
 	if err := fr.verifyFileIntegrity(job, fileInfo); err != nil {
-		job.Status = "failed"
-		job.ErrorMessage = fmt.Sprintf("Integrity verification failed: %v", err)
+		job.fail(fmt.Sprintf("Integrity verification failed: %v", err))
+		fr.recordFailure(job, "verify", err)
 		fr.logger.Errorf("❌ Integrity verification failed for %s: %v", job.FileName, err)
 		return
 	}
 	
-	job.Status = "completed"
-	job.Progress = 100.0
-	job.CompletionTime = time.Now()
+	completedAt := time.Now()
+	job.update(func() {
+		job.Status = "completed"
+		job.Progress = 100.0
+		job.CompletionTime = completedAt
+	})
 	
-	duration := job.CompletionTime.Sub(job.StartTime)
+	duration := completedAt.Sub(job.StartTime)
 	fr.logger.Infof("✅ Successfully reassembled file %s in %v", job.FileName, duration)
 }
 
+// downloadAndAssembleSequential downloads every chunk into memory before
+// assembling the file, marking the job failed on error
+func (fr *FileReassembler) downloadAndAssembleSequential(job *ReassemblyJob, chunkIDs []string, password string) error {
+	// Download all chunks with parallel processing
+	chunkData, err := fr.downloadAllChunks(job, chunkIDs)
+	if err != nil {
+		job.fail(fmt.Sprintf("Failed to download chunks: %v", err))
+		fr.recordFailure(job, "download", err)
+		fr.logger.Errorf("❌ Failed to download chunks for %s: %v", job.FileName, err)
+		return err
+	}
+	
+	job.update(func() {
+		job.Status = "assembling"
+		job.Progress = 50.0
+	})
+	fr.logger.Infof("🔨 Assembling file %s from %d chunks", job.FileName, len(chunkData))
+	
+	// Assemble the file from chunks
+	err = fr.assembleFile(job, func(chunk metadata.ChunkMetadata) ([]byte, error) {
+		data, exists := chunkData[chunk.Index]
+		if !exists {
+			return nil, fmt.Errorf("missing chunk data for index %d", chunk.Index)
+		}
+		return data, nil
+	}, password)
+	if err != nil {
+		job.fail(fmt.Sprintf("Failed to assemble file: %v", err))
+		fr.recordFailure(job, "assemble", err)
+		fr.logger.Errorf("❌ Failed to assemble file %s: %v", job.FileName, err)
+		return err
+	}
+	return nil
+}
+
 // downloadAllChunks downloads all chunks for a file with parallel processing
 func (fr *FileReassembler) downloadAllChunks(job *ReassemblyJob, chunkIDs []string) (map[int][]byte, error) {
 	chunkData := make(map[int][]byte)
 	resultChan := make(chan *ChunkDownloadResult, len(chunkIDs))
 	
-	// Start download workers
-	for i, chunkID := range chunkIDs {
-		go fr.downloadChunk(chunkID, i, resultChan)
-	}
+	// Start download workers, as many at once as the adaptive controller allows
+	fetches := parallelism.Global()
+	go func() {
+		for i, chunkID := range chunkIDs {
+			done, err := fetches.Acquire(context.Background())
+			if err != nil {
+				resultChan <- &ChunkDownloadResult{ChunkID: chunkID, Index: i, Error: err}
+				continue
+			}
+			go func(i int, chunkID string) {
+				fetched := make(chan *ChunkDownloadResult, 1)
+				fr.downloadChunk(chunkID, i, fetched)
+				result := <-fetched
+				done(int64(len(result.Data)), result.Error)
+				resultChan <- result
+			}(i, chunkID)
+		}
+	}()
 	
-	// Collect results
+	// Collect results, which arrive in whatever order the downloads finish
 	completedChunks := 0
 	for i := 0; i < len(chunkIDs); i++ {
 		result := <-resultChan
 		
 		if result.Success {
-			chunkData[i] = result.Data
-			job.ChunkStatus[result.ChunkID] = "downloaded"
-			job.IntegrityCheck.ChunkHashes[result.ChunkID] = result.Hash
+			chunkData[result.Index] = result.Data
+			job.update(func() {
+				job.ChunkStatus[result.ChunkID] = "downloaded"
+				job.IntegrityCheck.ChunkHashes[result.ChunkID] = result.Hash
+			})
 			completedChunks++
 			
 			fr.logger.Infof("📦 Downloaded chunk %s from node %s", result.ChunkID, result.Source)
 		} else {
-			job.ChunkStatus[result.ChunkID] = "failed"
+			job.setChunkStatus(result.ChunkID, "failed")
 			fr.logger.Errorf("❌ Failed to download chunk %s: %v", result.ChunkID, result.Error)
 			
 			// Try to recover the chunk from other replicas
 			if recoveredData, err := fr.recoverChunkFromReplicas(result.ChunkID); err == nil {
-				chunkData[i] = recoveredData
-				job.ChunkStatus[result.ChunkID] = "recovered"
+				chunkData[result.Index] = recoveredData
+				job.setChunkStatus(result.ChunkID, "recovered")
 				completedChunks++
 				fr.logger.Infof("🔄 Recovered chunk %s from replicas", result.ChunkID)
 			}
 		}
 		
-		job.ChunksObtained = completedChunks
-		job.Progress = float64(completedChunks) / float64(job.TotalChunks) * 50.0 // First 50% is downloading
+		job.update(func() {
+			job.ChunksObtained = completedChunks
+			job.Progress = float64(completedChunks) / float64(job.TotalChunks) * 50.0 // First 50% is downloading
+		})
 	}
 	
 	if completedChunks < job.TotalChunks {
@@ -228,10 +401,65 @@ func (fr *FileReassembler) downloadAllChunks(job *ReassemblyJob, chunkIDs []stri
 	return chunkData, nil
 }
 
+// downloadAndAssemble streams chunks from all of their holders into the
+// output file, holding at most the multi-source window of them in memory
+func (fr *FileReassembler) downloadAndAssemble(job *ReassemblyJob, chunkIDs []string, password string) error {
+	ms := newMultiSource(fr.multiSource, chunkIDs, fr.network.LocalNode, fr.chunkHolders,
+		func(chunkID string, node *p2p.Node) ([]byte, error) {
+			data, _, err := fr.downloadChunkFromNode(chunkID, node)
+			return data, err
+		})
+	defer func() {
+		ms.close()
+		sources := ms.contributions()
+		job.update(func() { job.Sources = sources })
+	}()
+	
+	return fr.assembleFile(job, func(chunk metadata.ChunkMetadata) ([]byte, error) {
+		if chunk.Index < 0 || chunk.Index >= len(chunkIDs) {
+			return nil, fmt.Errorf("missing chunk data for index %d", chunk.Index)
+		}
+		chunkID := chunkIDs[chunk.Index]
+		data, source, err := ms.take(chunk.Index)
+		if err != nil {
+			job.setChunkStatus(chunkID, "failed")
+			fr.logger.Errorf("❌ Failed to download chunk %s: %v", chunkID, err)
+			recovered, recoverErr := fr.recoverChunkFromReplicas(chunkID)
+			if recoverErr != nil {
+				return nil, err
+			}
+			data = recovered
+			job.setChunkStatus(chunkID, "recovered")
+			fr.logger.Infof("🔄 Recovered chunk %s from replicas", chunkID)
+		} else {
+			hash := sha256.Sum256(data)
+			job.update(func() {
+				job.ChunkStatus[chunkID] = "downloaded"
+				job.IntegrityCheck.ChunkHashes[chunkID] = hex.EncodeToString(hash[:])
+			})
+			fr.logger.Infof("📦 Downloaded chunk %s from node %s", chunkID, source)
+		}
+		job.update(func() { job.ChunksObtained++ })
+		return data, nil
+	}, password)
+}
+
+// chunkHolders lists the nodes to download a chunk from, ring-predicted holders first
+func (fr *FileReassembler) chunkHolders(chunkID string) []*p2p.Node {
+	nodes := fr.predictedHolders(chunkID)
+	for _, node := range fr.network.FindNodesWithChunk(chunkID) {
+		if !containsNode(nodes, node.ID) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
 // downloadChunk downloads a specific chunk
 func (fr *FileReassembler) downloadChunk(chunkID string, index int, resultChan chan *ChunkDownloadResult) {
 	result := &ChunkDownloadResult{
 		ChunkID: chunkID,
+		Index:   index,
 		Success: false,
 	}
 	
@@ -245,8 +473,13 @@ func (fr *FileReassembler) downloadChunk(chunkID string, index int, resultChan c
 		return
 	}
 	
-	// Find nodes that have this chunk
-	nodesWithChunk := fr.network.FindNodesWithChunk(chunkID)
+	// Find nodes that have this chunk, trying ring-predicted holders first
+	nodesWithChunk := fr.predictedHolders(chunkID)
+	for _, node := range fr.network.FindNodesWithChunk(chunkID) {
+		if !containsNode(nodesWithChunk, node.ID) {
+			nodesWithChunk = append(nodesWithChunk, node)
+		}
+	}
 	if len(nodesWithChunk) == 0 {
 		result.Error = fmt.Errorf("no nodes have chunk %s", chunkID)
 		resultChan <- result
@@ -271,9 +504,48 @@ func (fr *FileReassembler) downloadChunk(chunkID string, index int, resultChan c
 	resultChan <- result
 }
 
-// getChunkFromLocalStorage retrieves a chunk from local storage
+// predictedHolders returns the online peers the placement ring assigns the chunk to
+func (fr *FileReassembler) predictedHolders(chunkID string) []*p2p.Node {
+	if fr.ring == nil {
+		return nil
+	}
+
+	replicas := DefaultDFSConfig().DefaultReplicaCount
+	if fr.dfsCore != nil && fr.dfsCore.config != nil {
+		replicas = fr.dfsCore.config.DefaultReplicaCount
+	}
+
+	holders := make([]*p2p.Node, 0, replicas)
+	for _, nodeID := range fr.ring.Lookup(chunkID, replicas) {
+		if nodeID == fr.network.LocalNode.ID {
+			continue // Local storage was already checked
+		}
+		if peer := fr.network.GetPeerByID(nodeID); peer != nil && peer.Status == "online" {
+			holders = append(holders, peer)
+		}
+	}
+	return holders
+}
+
+func containsNode(nodes []*p2p.Node, nodeID string) bool {
+	for _, node := range nodes {
+		if node.ID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// getChunkFromLocalStorage retrieves a chunk from local storage, where the
+// distributor's chunks are filed under the hash of their stored bytes
 func (fr *FileReassembler) getChunkFromLocalStorage(chunkID string) ([]byte, string, error) {
-	reader, err := fr.storage.Get(chunkID)
+	storageID := chunkID
+	if fr.distributor != nil {
+		if chunk, err := fr.distributor.GetChunkInfo(chunkID); err == nil && chunk.StoredHash != "" {
+			storageID = chunk.StoredHash
+		}
+	}
+	reader, err := fr.storage.Get(storageID)
 	if err != nil {
 		return nil, "", err
 	}
@@ -324,8 +596,8 @@ func (fr *FileReassembler) downloadChunkFromNode(chunkID string, node *p2p.Node)
 	}
 		
 	// For remote nodes, try to download via HTTP API
-	client := &http.Client{Timeout: 30 * time.Second}
-	url := fmt.Sprintf("http://%s:%d/chunk-request?id=%s", node.Address, node.Port, chunkID)
+	client := fr.network.Client(30 * time.Second)
+	url := node.URL("/chunk-request?id=" + chunkID)
 	
 	resp, err := client.Get(url)
 	if err != nil {
@@ -337,8 +609,8 @@ func (fr *FileReassembler) downloadChunkFromNode(chunkID string, node *p2p.Node)
 		return nil, "", fmt.Errorf("chunk download failed from node %s: status %d", node.ID, resp.StatusCode)
 	}
 	
-	// Read chunk data
-	chunkData, err := io.ReadAll(resp.Body)
+	// Read chunk data, undoing any wire codec the node applied
+	chunkData, err := p2p.ReadChunk(resp)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to read chunk data from node %s: %v", node.ID, err)
 	}
@@ -378,8 +650,9 @@ func (fr *FileReassembler) recoverChunkFromReplicas(chunkID string) ([]byte, err
 	return nil, fmt.Errorf("failed to recover chunk from any replica")
 }
 
-// assembleFile assembles the final file from downloaded chunks with enhanced metadata validation
-func (fr *FileReassembler) assembleFile(job *ReassemblyJob, chunkData map[int][]byte, password string) error {
+// assembleFile assembles the final file from downloaded chunks with enhanced metadata validation.
+// fetch returns a chunk's stored bytes and is called from several goroutines.
+func (fr *FileReassembler) assembleFile(job *ReassemblyJob, fetch func(chunk metadata.ChunkMetadata) ([]byte, error), password string) error {
 	// Get chunk metadata for validation and proper ordering
 	chunks, err := fr.metaStore.GetChunksByFileID(job.FileID)
 	if err != nil {
@@ -410,70 +683,123 @@ func (fr *FileReassembler) assembleFile(job *ReassemblyJob, chunkData map[int][]
 	// Initialize encryptor and compressor
 	enc := encryptor.NewEncryptor()
 
-	totalBytesWritten := int64(0)
-	for i, chunk := range chunks {
-		data, exists := chunkData[chunk.Index]
-		if !exists {
-			return fmt.Errorf("missing chunk data for index %d", chunk.Index)
+	// A server-managed file is opened with its data key rather than a password
+	var dataKey []byte
+	if chunker.FileKeyMode(fr.metaStore, job.FileID) == metadata.KeyModeServerManaged {
+		dataKey, err = chunker.UnwrapFileKey(job.FileID, fr.keyManager, fr.metaStore)
+		if err != nil {
+			return fmt.Errorf("failed to unwrap the data key of %s: %v", job.FileID, err)
 		}
+	}
 
-		// Decrypt chunk
-		decrypted := data
-		if password != "" {
-			decrypted, err = enc.Decrypt(data, password)
+	// Decode chunks on a worker pool while writing them strictly in order
+	totalBytesWritten := int64(0)
+	opts := chunker.DefaultVerifyOptions()
+	opts.SizeHint = func(i int) int64 { return chunks[i].Size }
+	stats, err := chunker.VerifyChunksInOrder(len(chunks), opts,
+		func(i int) ([]byte, error) {
+			data, err := fetch(chunks[i])
 			if err != nil {
-				return fmt.Errorf("failed to decrypt chunk %d: %v", chunk.Index, err)
+				return nil, err
 			}
-		}
+			return decodeChunk(chunks[i], data, enc, password, dataKey)
+		},
+		func(i int, data []byte) error {
+			bytesWritten, err := outputFile.Write(data)
+			if err != nil {
+				return fmt.Errorf("failed to write chunk %d: %v", chunks[i].Index, err)
+			}
+			totalBytesWritten += int64(bytesWritten)
 
-		// Decompress chunk if needed
-		decompressed := decrypted
-		// Try decompression, fallback to original data if it fails
-		if decompData, err := compressor.DecompressData(decrypted); err == nil {
-			decompressed = decompData
+			// Update progress
+			job.update(func() { job.Progress = 50.0 + (float64(i+1)/float64(len(chunks)))*35.0 })
+			return nil
+		})
+	job.update(func() { job.VerifyStats = &stats })
+	if err != nil {
+		var verifyErr *chunker.ChunkVerifyError
+		if errors.As(err, &verifyErr) && verifyErr.Index < len(chunks) {
+			corrupted := chunks[verifyErr.Index].Hash
+			job.update(func() {
+				job.IntegrityCheck.CorruptedChunks = append(job.IntegrityCheck.CorruptedChunks, corrupted)
+			})
 		}
+		return err
+	}
 
-		// Validate chunk hash
-		hash := sha256.Sum256(decompressed)
-		calculatedHash := hex.EncodeToString(hash[:])
-		if calculatedHash != chunk.Hash {
-			return fmt.Errorf("hash mismatch for chunk %d: expected %s, got %s", 
-				chunk.Index, chunk.Hash, calculatedHash)
-		}
+	fr.logger.Infof("📝 Wrote %d bytes to %s (verified at %.2f MB/s with %d workers)",
+		totalBytesWritten, job.OutputPath, stats.ThroughputMBps, stats.Workers)
+	return nil
+}
+
+// decodeChunk decrypts, decompresses and hash-checks one downloaded chunk,
+// opening it with dataKey when the file has a server-managed key
+func decodeChunk(chunk metadata.ChunkMetadata, data []byte, enc encryptor.Encryptor, password string, dataKey []byte) ([]byte, error) {
+	// Strip the chunk header if present (legacy chunks are headerless)
+	header, payload, err := chunker.SplitChunk(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse header of chunk %d: %v", chunk.Index, err)
+	}
+
+	// Decrypt chunk
+	decrypted := payload
+	switch {
+	case dataKey != nil:
+		decrypted, err = encryptor.DecryptWithKey(payload, dataKey)
+	case password != "":
+		decrypted, err = enc.Decrypt(payload, password)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt chunk %d: %v", chunk.Index, err)
+	}
 
-		// Write decompressed data to output file
-		bytesWritten, err := outputFile.Write(decompressed)
+	// Decompress chunk if needed
+	decompressed := decrypted
+	if header != nil {
+		decompressed, err = chunker.DecompressChunk(header, false, decrypted)
 		if err != nil {
-			return fmt.Errorf("failed to write chunk %d: %v", chunk.Index, err)
+			return nil, fmt.Errorf("failed to decompress chunk %d: %v", chunk.Index, err)
 		}
+	} else if decompData, err := compressor.DecompressData(decrypted); err == nil {
+		// Try decompression, fallback to original data if it fails
+		decompressed = decompData
+	}
 
-		totalBytesWritten += int64(bytesWritten)
-
-		// Update progress
-		progress := 50.0 + (float64(i+1)/float64(len(chunks)))*35.0
-		job.Progress = progress
+	// Validate chunk hash with the algorithm it was recorded under
+	calculatedHash, err := chunker.ChunkHash(chunk.HashAlgo, decompressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash chunk %d: %v", chunk.Index, err)
 	}
-	
-	fr.logger.Infof("📝 Wrote %d bytes to %s", totalBytesWritten, job.OutputPath)
-	return nil
+	if calculatedHash != chunk.Hash {
+		return nil, fmt.Errorf("hash mismatch for chunk %d: expected %s, got %s",
+			chunk.Index, chunk.Hash, calculatedHash)
+	}
+
+	return decompressed, nil
 }
 
 // verifyFileIntegrity verifies the integrity of the reassembled file using FileID
 func (fr *FileReassembler) verifyFileIntegrity(job *ReassemblyJob, fileInfo *distributor.FileInfo) error {
+	// A chunk missing from the list leaves a short file before any hash is taken
+	if err := chunker.CheckOutputSize(job.FileID, job.OutputPath, fileInfo.Size); err != nil {
+		return err
+	}
+	
 	// Calculate hash of reassembled file
 	fileHash, err := fr.calculateFileHash(job.OutputPath)
 	if err != nil {
 		return fmt.Errorf("failed to calculate file hash: %v", err)
 	}
 
-	job.IntegrityCheck.FileHash = fileHash
-	job.IntegrityCheck.CheckTime = time.Now()
-
 	// The expected hash should match the FileID (which is the original file's SHA-256)
-	job.IntegrityCheck.ExpectedHash = job.FileID
-	job.IntegrityCheck.IsValid = (fileHash == job.FileID)
+	job.update(func() {
+		job.IntegrityCheck.FileHash = fileHash
+		job.IntegrityCheck.CheckTime = time.Now()
+		job.IntegrityCheck.ExpectedHash = job.FileID
+		job.IntegrityCheck.IsValid = (fileHash == job.FileID)
+	})
 
-	if !job.IntegrityCheck.IsValid {
+	if fileHash != job.FileID {
 		return fmt.Errorf("file hash mismatch: expected %s (FileID), got %s", 
 			job.FileID, fileHash)
 	}
@@ -483,9 +809,16 @@ func (fr *FileReassembler) verifyFileIntegrity(job *ReassemblyJob, fileInfo *dis
 	if err != nil {
 		fr.logger.Warnf("⚠️ Could not get chunks for FileID %s: %v", job.FileID, err)
 	} else {
+		job.mu.Lock()
+		chunkHashes := make(map[string]string, len(job.IntegrityCheck.ChunkHashes))
+		for chunkID, hash := range job.IntegrityCheck.ChunkHashes {
+			chunkHashes[chunkID] = hash
+		}
+		job.mu.Unlock()
+		
 		corruptedChunks := make([]string, 0)
 		for _, chunk := range chunks {
-			if actualHash, exists := job.IntegrityCheck.ChunkHashes[chunk.Hash]; exists {
+			if actualHash, exists := chunkHashes[chunk.Hash]; exists {
 				if chunk.Hash != actualHash {
 					corruptedChunks = append(corruptedChunks, chunk.Hash)
 					fr.logger.Warnf("❌ Chunk %s is corrupted (expected: %s, actual: %s)", 
@@ -494,10 +827,14 @@ func (fr *FileReassembler) verifyFileIntegrity(job *ReassemblyJob, fileInfo *dis
 			}
 		}
 
-		job.IntegrityCheck.CorruptedChunks = corruptedChunks
+		job.update(func() {
+			job.IntegrityCheck.CorruptedChunks = corruptedChunks
+			if len(corruptedChunks) > 0 {
+				job.IntegrityCheck.IsValid = false
+			}
+		})
 
 		if len(corruptedChunks) > 0 {
-			job.IntegrityCheck.IsValid = false
 			return fmt.Errorf("%d chunks are corrupted", len(corruptedChunks))
 		}
 	}
@@ -524,46 +861,63 @@ func (fr *FileReassembler) calculateFileHash(filePath string) (string, error) {
 	return hex.EncodeToString(hash), nil
 }
 
-// GetJob returns information about a reassembly job
+// GetJob returns a snapshot of a reassembly job
 func (fr *FileReassembler) GetJob(jobID string) *ReassemblyJob {
+	fr.jobsMu.RLock()
+	defer fr.jobsMu.RUnlock()
+	
 	if job, exists := fr.activeJobs[jobID]; exists {
-		return job
+		return job.Snapshot()
 	}
 	
 	// Search in job history
 	for _, job := range fr.jobHistory {
 		if job.ID == jobID {
-			return job
+			return job.Snapshot()
 		}
 	}
 	
 	return nil
 }
 
-// GetActiveJobs returns all active reassembly jobs
+// GetActiveJobs returns snapshots of all active reassembly jobs
 func (fr *FileReassembler) GetActiveJobs() []*ReassemblyJob {
+	fr.jobsMu.RLock()
+	defer fr.jobsMu.RUnlock()
+	
 	jobs := make([]*ReassemblyJob, 0, len(fr.activeJobs))
 	for _, job := range fr.activeJobs {
-		jobs = append(jobs, job)
+		jobs = append(jobs, job.Snapshot())
 	}
 	return jobs
 }
 
-// GetJobHistory returns completed job history
+// GetJobHistory returns snapshots of the completed job history
 func (fr *FileReassembler) GetJobHistory() []*ReassemblyJob {
-	return fr.jobHistory
+	fr.jobsMu.RLock()
+	defer fr.jobsMu.RUnlock()
+	
+	jobs := make([]*ReassemblyJob, 0, len(fr.jobHistory))
+	for _, job := range fr.jobHistory {
+		jobs = append(jobs, job.Snapshot())
+	}
+	return jobs
 }
 
 // CancelJob cancels an active reassembly job
 func (fr *FileReassembler) CancelJob(jobID string) error {
+	fr.jobsMu.RLock()
 	job, exists := fr.activeJobs[jobID]
+	fr.jobsMu.RUnlock()
 	if !exists {
 		return fmt.Errorf("job %s not found or already completed", jobID)
 	}
 	
-	job.Status = "cancelled"
-	job.ErrorMessage = "Job cancelled by user"
-	job.CompletionTime = time.Now()
+	job.update(func() {
+		job.Status = "cancelled"
+		job.ErrorMessage = "Job cancelled by user"
+		job.CompletionTime = time.Now()
+	})
 	
 	fr.moveJobToHistory(job)
 	fr.logger.Infof("🚫 Cancelled reassembly job %s", jobID)
@@ -573,6 +927,9 @@ func (fr *FileReassembler) CancelJob(jobID string) error {
 
 // moveJobToHistory moves a job from active to history
 func (fr *FileReassembler) moveJobToHistory(job *ReassemblyJob) {
+	fr.jobsMu.Lock()
+	defer fr.jobsMu.Unlock()
+	
 	delete(fr.activeJobs, job.ID)
 	
 	fr.jobHistory = append(fr.jobHistory, job)
@@ -585,14 +942,26 @@ func (fr *FileReassembler) moveJobToHistory(job *ReassemblyJob) {
 
 // GetReassemblyStats returns statistics about file reassembly operations
 func (fr *FileReassembler) GetReassemblyStats() map[string]interface{} {
+	fr.jobsMu.RLock()
 	activeCount := len(fr.activeJobs)
-	totalJobs := activeCount + len(fr.jobHistory)
+	history := make([]*ReassemblyJob, 0, len(fr.jobHistory))
+	for _, job := range fr.jobHistory {
+		history = append(history, job.Snapshot())
+	}
+	fr.jobsMu.RUnlock()
+	totalJobs := activeCount + len(history)
 	
 	completedJobs := 0
 	failedJobs := 0
 	totalDuration := time.Duration(0)
+	verifiedBytes := int64(0)
+	verifyDuration := time.Duration(0)
 	
-	for _, job := range fr.jobHistory {
+	for _, job := range history {
+		if job.VerifyStats != nil {
+			verifiedBytes += job.VerifyStats.Bytes
+			verifyDuration += job.VerifyStats.Duration
+		}
 		switch job.Status {
 		case "completed":
 			completedJobs++
@@ -605,12 +974,18 @@ func (fr *FileReassembler) GetReassemblyStats() map[string]interface{} {
 	}
 	
 	stats := map[string]interface{}{
-		"active_jobs":    activeCount,
-		"total_jobs":     totalJobs,
-		"completed_jobs": completedJobs,
-		"failed_jobs":    failedJobs,
-		"success_rate":   0.0,
-		"avg_duration":   "0s",
+		"active_jobs":            activeCount,
+		"total_jobs":             totalJobs,
+		"completed_jobs":         completedJobs,
+		"failed_jobs":            failedJobs,
+		"success_rate":           0.0,
+		"avg_duration":           "0s",
+		"verified_bytes":         verifiedBytes,
+		"verify_throughput_mbps": 0.0,
+	}
+	
+	if verifyDuration > 0 {
+		stats["verify_throughput_mbps"] = float64(verifiedBytes) / (1024 * 1024) / verifyDuration.Seconds()
 	}
 	
 	if totalJobs > 0 {
@@ -0,0 +1,176 @@
+package dfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// Sources of a repaired file hash
+const (
+	HashSourceReassembled = "reassembled" // SHA-256 of the file reassembled with the server-managed key
+	HashSourceContentID   = "content-id"  // SHA-256 of the file computed at upload, which the chunks are bound to
+	HashSourceMerkle      = "merkle"      // Merkle root of the ordered chunk hashes
+)
+
+// HashCatalog is the enhanced metadata a hash repair reads and updates
+type HashCatalog interface {
+	ListFileMetadata() ([]*metadata.EnhancedFileMetadata, error)
+	StoreFileMetadata(meta *metadata.EnhancedFileMetadata) error
+}
+
+// HashFix records one file whose hash was repaired
+type HashFix struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	OldHash  string `json:"old_hash"`
+	NewHash  string `json:"new_hash"`
+	Source   string `json:"source"`
+}
+
+// HashRepairFailure records a file whose hash could not be re-derived
+type HashRepairFailure struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	Reason   string `json:"reason"`
+}
+
+// HashRepairReport is the result of a hash repair pass
+type HashRepairReport struct {
+	RanAt        time.Time           `json:"ran_at"`
+	Duration     time.Duration       `json:"duration"`
+	FilesScanned int                 `json:"files_scanned"`
+	Fixed        []HashFix           `json:"fixed"`
+	Unrepairable []HashRepairFailure `json:"unrepairable"`
+}
+
+// RepairFileHashes re-derives the hash of every file whose recorded hash is
+// missing or a placeholder. Server-managed files are reassembled and hashed
+// when keys are given; other files take the content hash their chunks are
+// bound to, and client-encrypted files the Merkle root of their chunk
+// hashes. Files with missing chunks are reported and left unchanged.
+func RepairFileHashes(catalog HashCatalog, metaStore *metadata.MetadataStore, store storage.Storage, keys *encryptor.KeyManager) (*HashRepairReport, error) {
+	start := time.Now()
+	report := &HashRepairReport{RanAt: start, Fixed: []HashFix{}, Unrepairable: []HashRepairFailure{}}
+
+	files, err := catalog.ListFileMetadata()
+	if err != nil {
+		return nil, err
+	}
+	for _, meta := range files {
+		report.FilesScanned++
+		if metadata.ValidFileHash(meta.FileHash) {
+			continue
+		}
+
+		hash, source, err := deriveFileHash(meta.FileID, metaStore, store, keys)
+		if err != nil {
+			report.Unrepairable = append(report.Unrepairable, HashRepairFailure{FileID: meta.FileID, FileName: meta.FileName, Reason: err.Error()})
+			continue
+		}
+		fix := HashFix{FileID: meta.FileID, FileName: meta.FileName, OldHash: meta.FileHash, NewHash: hash, Source: source}
+		meta.FileHash = hash
+		if err := catalog.StoreFileMetadata(meta); err != nil {
+			report.Unrepairable = append(report.Unrepairable, HashRepairFailure{FileID: meta.FileID, FileName: meta.FileName, Reason: fmt.Sprintf("failed to store metadata: %v", err)})
+			continue
+		}
+		report.Fixed = append(report.Fixed, fix)
+	}
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// deriveFileHash computes a file's hash from its stored chunks
+func deriveFileHash(fileID string, metaStore *metadata.MetadataStore, store storage.Storage, keys *encryptor.KeyManager) (string, string, error) {
+	if metaStore == nil {
+		return "", "", fmt.Errorf("no chunk catalog available")
+	}
+	fileMeta, err := metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return "", "", fmt.Errorf("no chunk metadata for file")
+	}
+	contentID := fileMeta.CurrentContentID(fileID)
+	chunks, err := metaStore.GetChunksByFileID(contentID)
+	if err != nil || len(chunks) == 0 {
+		return "", "", fmt.Errorf("no chunks recorded for file")
+	}
+	if err := metadata.ValidateChunkChain(chunks); err != nil {
+		return "", "", fmt.Errorf("chunk chain is broken: %v", err)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Offset < chunks[j].Offset })
+	for _, chunk := range chunks {
+		reader, err := store.Get(chunk.Path)
+		if err != nil {
+			return "", "", fmt.Errorf("missing chunk %d", chunk.Index)
+		}
+		reader.Close()
+	}
+
+	switch {
+	case fileMeta.IsServerManaged() && keys != nil:
+		hash, err := reassembledHash(fileID, keys, metaStore, store)
+		if err != nil {
+			return "", "", err
+		}
+		return hash, HashSourceReassembled, nil
+	case !fileMeta.IsClientEncrypted() && metadata.ValidFileHash(contentID):
+		return contentID, HashSourceContentID, nil
+	default:
+		hashes := make([]string, 0, len(chunks))
+		for _, chunk := range chunks {
+			hashes = append(hashes, chunk.Hash)
+		}
+		return metadata.MerkleHashPrefix + metadata.MerkleRoot(hashes), HashSourceMerkle, nil
+	}
+}
+
+// reassembledHash reassembles a server-managed file to a temporary file and hashes it
+func reassembledHash(fileID string, keys *encryptor.KeyManager, metaStore *metadata.MetadataStore, store storage.Storage) (string, error) {
+	dir, err := os.MkdirTemp("", "hash-repair-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "file")
+	if err := chunker.ReassembleFileWithServerKey(fileID, out, keys, metaStore, store); err != nil {
+		return "", fmt.Errorf("reassembly failed: %v", err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		return "", fmt.Errorf("failed to open reassembled file: %v", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash reassembled file: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// RepairFileHashes runs a hash repair over the core's enhanced metadata
+func (dfs *DFSCore) RepairFileHashes(keys *encryptor.KeyManager) (*HashRepairReport, error) {
+	if dfs.OptimizedStorage == nil {
+		return nil, fmt.Errorf("enhanced metadata is not available")
+	}
+	report, err := RepairFileHashes(dfs.OptimizedStorage, dfs.metaStore, dfs.storage, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Fixed) > 0 || len(report.Unrepairable) > 0 {
+		dfs.logger.Infof("🔧 Hash repair: %d of %d files fixed, %d unrepairable",
+			len(report.Fixed), report.FilesScanned, len(report.Unrepairable))
+	}
+	return report, nil
+}
@@ -0,0 +1,97 @@
+package dfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestRepairFileHashesReplacesPlaceholders(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	catalog, err := metadata.NewEnhancedMetadataStore(filepath.Join(dir, "enhanced"))
+	if err != nil {
+		t.Fatalf("failed to open enhanced metadata store: %v", err)
+	}
+	defer catalog.Close()
+	km, _ := encryptor.NewKeyManager(bytes.Repeat([]byte{7}, 32))
+
+	// Seed files the way the old upload handler recorded them
+	seed := func(name string, content []byte, serverManaged bool) (string, []chunker.ChunkMetadata) {
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, content, 0644)
+		var chunks []chunker.ChunkMetadata
+		if serverManaged {
+			chunks, err = chunker.ChunkAndStoreWithServerKey(input, km, metaStore, store)
+		} else {
+			chunks, err = chunker.ChunkAndStore(input, "secret", metaStore, store)
+		}
+		if err != nil {
+			t.Fatalf("upload of %s failed: %v", name, err)
+		}
+		fileID := chunks[0].FileID
+		catalog.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: name, FileHash: "file-hash-placeholder"})
+		return fileID, chunks
+	}
+	passwordID, _ := seed("password.bin", bytes.Repeat([]byte("password file "), 2*int(chunker.MinChunkSize)/14+1), false)
+	managedID, _ := seed("managed.bin", bytes.Repeat([]byte("managed file "), 2*int(chunker.MinChunkSize)/13+1), true)
+	brokenID, brokenChunks := seed("broken.bin", bytes.Repeat([]byte("broken file "), 2*int(chunker.MinChunkSize)/12+1), false)
+	if err := store.Delete(brokenChunks[1].Path); err != nil {
+		t.Fatalf("failed to delete chunk: %v", err)
+	}
+
+	report, err := RepairFileHashes(catalog, metaStore, store, km)
+	if err != nil {
+		t.Fatalf("RepairFileHashes failed: %v", err)
+	}
+	if report.FilesScanned != 3 || len(report.Fixed) != 2 {
+		t.Fatalf("expected 2 of 3 files fixed, got %+v", report)
+	}
+	if len(report.Unrepairable) != 1 || report.Unrepairable[0].FileID != brokenID {
+		t.Fatalf("expected %s to be reported unrepairable, got %+v", brokenID, report.Unrepairable)
+	}
+
+	// Both repaired hashes are the real SHA-256 of the uploaded files
+	sources := map[string]string{}
+	for _, fix := range report.Fixed {
+		sources[fix.FileID] = fix.Source
+	}
+	stored, _ := catalog.ListFileMetadata()
+	hashes := map[string]string{}
+	for _, meta := range stored {
+		hashes[meta.FileID] = meta.FileHash
+	}
+	for fileID, name := range map[string]string{passwordID: "password.bin", managedID: "managed.bin"} {
+		want, _ := chunker.CalculateFileHash(filepath.Join(dir, name))
+		if hashes[fileID] != want {
+			t.Errorf("%s: expected hash %s, got %s", name, want, hashes[fileID])
+		}
+	}
+	if sources[managedID] != HashSourceReassembled || sources[passwordID] != HashSourceContentID {
+		t.Errorf("unexpected hash sources: %+v", sources)
+	}
+
+	// A second pass finds nothing left to fix
+	report, err = RepairFileHashes(catalog, metaStore, store, km)
+	if err != nil || len(report.Fixed) != 0 || len(report.Unrepairable) != 1 {
+		t.Errorf("expected the repair to be idempotent, got %+v (%v)", report, err)
+	}
+}
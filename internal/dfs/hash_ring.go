@@ -0,0 +1,221 @@
+package dfs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultVirtualNodes is the number of ring points per node; more points give a more even spread
+const DefaultVirtualNodes = 128
+
+type ringPoint struct {
+	hash   uint64
+	nodeID string
+}
+
+// RingNodeState describes one node's share of the hash ring
+type RingNodeState struct {
+	NodeID    string  `json:"node_id"`
+	Points    int     `json:"points"`
+	Ownership float64 `json:"ownership"` // Fraction of the key space owned as primary, 0.0 to 1.0
+}
+
+// RingState is a debugging snapshot of the hash ring
+type RingState struct {
+	VirtualNodes int             `json:"virtual_nodes"`
+	TotalPoints  int             `json:"total_points"`
+	Nodes        []RingNodeState `json:"nodes"`
+}
+
+// HashRing is a consistent-hash ring with virtual nodes. A key is owned by
+// the first point clockwise from its hash, so adding or removing one of N
+// nodes moves only about 1/N of the keys.
+type HashRing struct {
+	virtualNodes int
+	points       []ringPoint // Sorted by hash
+	nodes        map[string]bool
+	mu           sync.RWMutex
+}
+
+// NewHashRing creates an empty ring; virtualNodes <= 0 uses DefaultVirtualNodes
+func NewHashRing(virtualNodes int) *HashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	return &HashRing{
+		virtualNodes: virtualNodes,
+		nodes:        make(map[string]bool),
+	}
+}
+
+func ringHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// Add places a node's virtual points on the ring
+func (hr *HashRing) Add(nodeID string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.addLocked(nodeID)
+	hr.sortLocked()
+}
+
+// Remove takes a node's points off the ring
+func (hr *HashRing) Remove(nodeID string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.removeLocked(map[string]bool{nodeID: true})
+}
+
+// SetNodes updates membership to exactly the given nodes, touching only those that changed
+func (hr *HashRing) SetNodes(nodeIDs []string) {
+	wanted := make(map[string]bool, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		wanted[nodeID] = true
+	}
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	departed := make(map[string]bool)
+	for nodeID := range hr.nodes {
+		if !wanted[nodeID] {
+			departed[nodeID] = true
+		}
+	}
+	if len(departed) > 0 {
+		hr.removeLocked(departed)
+	}
+
+	added := false
+	for nodeID := range wanted {
+		if !hr.nodes[nodeID] {
+			hr.addLocked(nodeID)
+			added = true
+		}
+	}
+	if added {
+		hr.sortLocked()
+	}
+}
+
+// Nodes returns the ring members in sorted order
+func (hr *HashRing) Nodes() []string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	nodes := make([]string, 0, len(hr.nodes))
+	for nodeID := range hr.nodes {
+		nodes = append(nodes, nodeID)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Lookup returns up to count distinct nodes for the key, walking clockwise
+// from its position; the first is the primary and the rest are replicas
+func (hr *HashRing) Lookup(key string, count int) []string {
+	return hr.LookupExcluding(key, count, nil)
+}
+
+// LookupExcluding is Lookup but skips the excluded nodes
+func (hr *HashRing) LookupExcluding(key string, count int, exclude map[string]bool) []string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	if count <= 0 || len(hr.points) == 0 {
+		return nil
+	}
+
+	hash := ringHash(key)
+	start := sort.Search(len(hr.points), func(i int) bool {
+		return hr.points[i].hash >= hash
+	})
+
+	seen := make(map[string]bool)
+	result := make([]string, 0, count)
+	for i := 0; i < len(hr.points) && len(result) < count; i++ {
+		nodeID := hr.points[(start+i)%len(hr.points)].nodeID
+		if seen[nodeID] || exclude[nodeID] {
+			continue
+		}
+		seen[nodeID] = true
+		result = append(result, nodeID)
+	}
+	return result
+}
+
+// State returns each node's point count and share of the key space
+func (hr *HashRing) State() RingState {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	state := RingState{
+		VirtualNodes: hr.virtualNodes,
+		TotalPoints:  len(hr.points),
+		Nodes:        make([]RingNodeState, 0, len(hr.nodes)),
+	}
+
+	points := make(map[string]int)
+	owned := make(map[string]float64)
+	for i, point := range hr.points {
+		points[point.nodeID]++
+		// A point owns the arc from its predecessor up to itself
+		prev := hr.points[(i+len(hr.points)-1)%len(hr.points)].hash
+		arc := point.hash - prev // Wraps correctly for the first point
+		if len(hr.points) == 1 {
+			arc = ^uint64(0)
+		}
+		owned[point.nodeID] += float64(arc) / float64(^uint64(0))
+	}
+
+	for nodeID := range hr.nodes {
+		state.Nodes = append(state.Nodes, RingNodeState{
+			NodeID:    nodeID,
+			Points:    points[nodeID],
+			Ownership: owned[nodeID],
+		})
+	}
+	sort.Slice(state.Nodes, func(i, j int) bool {
+		return state.Nodes[i].NodeID < state.Nodes[j].NodeID
+	})
+	return state
+}
+
+func (hr *HashRing) addLocked(nodeID string) {
+	if hr.nodes[nodeID] {
+		return
+	}
+	hr.nodes[nodeID] = true
+	for i := 0; i < hr.virtualNodes; i++ {
+		hr.points = append(hr.points, ringPoint{
+			hash:   ringHash(nodeID + "#" + strconv.Itoa(i)),
+			nodeID: nodeID,
+		})
+	}
+}
+
+func (hr *HashRing) removeLocked(nodeIDs map[string]bool) {
+	kept := hr.points[:0]
+	for _, point := range hr.points {
+		if !nodeIDs[point.nodeID] {
+			kept = append(kept, point)
+		}
+	}
+	hr.points = kept
+	for nodeID := range nodeIDs {
+		delete(hr.nodes, nodeID)
+	}
+}
+
+func (hr *HashRing) sortLocked() {
+	sort.Slice(hr.points, func(i, j int) bool {
+		if hr.points[i].hash == hr.points[j].hash {
+			return hr.points[i].nodeID < hr.points[j].nodeID
+		}
+		return hr.points[i].hash < hr.points[j].hash
+	})
+}
@@ -0,0 +1,135 @@
+package dfs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+func ringWithNodes(count int) *HashRing {
+	ring := NewHashRing(DefaultVirtualNodes)
+	for i := 0; i < count; i++ {
+		ring.Add(fmt.Sprintf("node-%d", i))
+	}
+	return ring
+}
+
+func primaryOwners(ring *HashRing, keys int) map[string]string {
+	owners := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("chunk-%d", i)
+		owners[key] = ring.Lookup(key, 1)[0]
+	}
+	return owners
+}
+
+func TestHashRingBalance(t *testing.T) {
+	const nodes, keys = 10, 20000
+	ring := ringWithNodes(nodes)
+
+	counts := make(map[string]int)
+	for _, owner := range primaryOwners(ring, keys) {
+		counts[owner]++
+	}
+	mean := keys / nodes
+	for nodeID, count := range counts {
+		if count < mean*7/10 || count > mean*13/10 {
+			t.Errorf("node %s owns %d keys, expected within 30%% of %d", nodeID, count, mean)
+		}
+	}
+
+	total := 0.0
+	for _, node := range ring.State().Nodes {
+		if node.Points != DefaultVirtualNodes {
+			t.Errorf("expected %d points for %s, got %d", DefaultVirtualNodes, node.NodeID, node.Points)
+		}
+		total += node.Ownership
+	}
+	if total < 0.999 || total > 1.001 {
+		t.Errorf("ownership should cover the whole ring, got %f", total)
+	}
+}
+
+func TestHashRingMinimalMovement(t *testing.T) {
+	const keys = 20000
+	ring := ringWithNodes(10)
+	before := primaryOwners(ring, keys)
+
+	// A joining node only takes keys; nothing moves between existing nodes
+	ring.Add("node-10")
+	afterJoin := primaryOwners(ring, keys)
+	moved := 0
+	for key, owner := range afterJoin {
+		if owner != before[key] {
+			moved++
+			if owner != "node-10" {
+				t.Fatalf("key %s moved from %s to %s instead of the new node", key, before[key], owner)
+			}
+		}
+	}
+	if moved == 0 || moved > keys*2/11 {
+		t.Errorf("expected about 1/11 of keys to move on join, moved %d of %d", moved, keys)
+	}
+
+	// A leaving node only gives up its own keys
+	ring.Remove("node-3")
+	for key, owner := range primaryOwners(ring, keys) {
+		if afterJoin[key] != "node-3" && owner != afterJoin[key] {
+			t.Fatalf("key %s moved from %s to %s though its owner stayed", key, afterJoin[key], owner)
+		}
+	}
+}
+
+func TestHashRingReplicasAreDistinct(t *testing.T) {
+	ring := ringWithNodes(5)
+	replicas := ring.Lookup("some-chunk-hash", 3)
+	if len(replicas) != 3 {
+		t.Fatalf("expected 3 replicas, got %v", replicas)
+	}
+	if replicas[0] == replicas[1] || replicas[1] == replicas[2] || replicas[0] == replicas[2] {
+		t.Errorf("replicas should be distinct nodes, got %v", replicas)
+	}
+	if again := ring.Lookup("some-chunk-hash", 3); fmt.Sprint(again) != fmt.Sprint(replicas) {
+		t.Errorf("placement should be deterministic, got %v then %v", replicas, again)
+	}
+	if all := ring.Lookup("some-chunk-hash", 10); len(all) != 5 {
+		t.Errorf("lookup should stop at the number of nodes, got %v", all)
+	}
+
+	excluded := ring.LookupExcluding("some-chunk-hash", 3, map[string]bool{replicas[0]: true})
+	if excluded[0] != replicas[1] {
+		t.Errorf("excluding the primary should promote the next replica, got %v", excluded)
+	}
+}
+
+func TestConsistentHashStrategySelectsRingNodes(t *testing.T) {
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	core := NewDFSCore(nil, network, nil, nil, nil)
+	core.nodeHealth[network.LocalNode.ID] = &NodeHealth{NodeID: network.LocalNode.ID, Status: "healthy"}
+	for i := 0; i < 4; i++ {
+		peerID := fmt.Sprintf("peer-%d", i)
+		network.RegisterPeer(&p2p.Node{ID: peerID, Status: "online", LastSeen: time.Now()})
+		core.nodeHealth[peerID] = &NodeHealth{NodeID: peerID, Status: "healthy"}
+	}
+
+	cd := NewChunkDistributor(core, StrategyConsistentHash)
+	nodes, err := cd.SelectOptimalNodes("chunk-abc", 3, nil)
+	if err != nil {
+		t.Fatalf("SelectOptimalNodes failed: %v", err)
+	}
+	expected := cd.Ring().Lookup("chunk-abc", 3)
+	for i, node := range nodes {
+		if node.ID != expected[i] {
+			t.Errorf("replica %d: expected %s from the ring, got %s", i, expected[i], node.ID)
+		}
+	}
+
+	if _, err := cd.SelectOptimalNodes("chunk-abc", 6, nil); err == nil {
+		t.Errorf("expected error when asking for more replicas than nodes")
+	}
+	if state := cd.RingState(); len(state.Nodes) != 5 {
+		t.Errorf("expected 5 ring members, got %d", len(state.Nodes))
+	}
+}
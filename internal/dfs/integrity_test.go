@@ -0,0 +1,127 @@
+package dfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestVerifyFileIntegrityReportsTheDamagedChunk(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	optimized, err := NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to create optimized storage: %v", err)
+	}
+	defer optimized.Close()
+
+	if _, err := optimized.VerifyFileIntegrity("anything", "secret"); !errors.Is(err, ErrNoChunkSource) {
+		t.Fatalf("expected ErrNoChunkSource before SetChunkSource, got %v", err)
+	}
+	optimized.SetChunkSource(metaStore, store)
+
+	seed := func(name string, pattern string) (string, []chunker.ChunkMetadata) {
+		input := filepath.Join(dir, name)
+		os.WriteFile(input, bytes.Repeat([]byte(pattern), 3*int(chunker.MinChunkSize)/len(pattern)+1), 0644)
+		chunks, err := chunker.ChunkAndStore(input, "secret", metaStore, store)
+		if err != nil {
+			t.Fatalf("upload of %s failed: %v", name, err)
+		}
+		fileID := chunks[0].FileID
+		optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: fileID, FileName: name, HealthStatus: "healthy"})
+		return fileID, chunks
+	}
+	intactID, _ := seed("intact.bin", "intact file ")
+	corruptID, corruptChunks := seed("corrupt.bin", "corrupt file ")
+	degradedID, degradedChunks := seed("degraded.bin", "degraded file ")
+
+	// Flip a byte of one chunk on disk and drop one chunk of another file
+	path, err := store.GetPath(corruptChunks[1].Path)
+	if err != nil {
+		t.Fatalf("failed to locate chunk: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read chunk: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to corrupt chunk: %v", err)
+	}
+	if err := store.Delete(degradedChunks[2].Path); err != nil {
+		t.Fatalf("failed to delete chunk: %v", err)
+	}
+
+	report, err := optimized.VerifyFileIntegrity(intactID, "secret")
+	if err != nil {
+		t.Fatalf("verification of the intact file failed: %v", err)
+	}
+	if report.HealthStatus != "healthy" || len(report.Missing) != 0 || len(report.Corrupt) != 0 {
+		t.Fatalf("expected the intact file healthy, got %+v", report)
+	}
+
+	report, err = optimized.VerifyFileIntegrity(corruptID, "secret")
+	if err != nil {
+		t.Fatalf("verification of the corrupt file failed: %v", err)
+	}
+	if report.HealthStatus != metadata.HealthCorrupted || len(report.Corrupt) != 1 || report.Corrupt[0] != corruptChunks[1].Index {
+		t.Fatalf("expected chunk %d reported corrupt, got %+v", corruptChunks[1].Index, report)
+	}
+	for _, check := range report.Chunks {
+		if check.Index == corruptChunks[1].Index && (check.Status != chunker.ChunkCorrupt || check.Error == "") {
+			t.Errorf("expected the corrupt chunk to carry its error, got %+v", check)
+		}
+		if check.Index != corruptChunks[1].Index && check.Status != chunker.ChunkOK {
+			t.Errorf("expected chunk %d ok, got %+v", check.Index, check)
+		}
+	}
+
+	report, err = optimized.VerifyFileIntegrity(degradedID, "secret")
+	if err != nil {
+		t.Fatalf("verification of the degraded file failed: %v", err)
+	}
+	if report.HealthStatus != metadata.HealthDegraded || len(report.Missing) != 1 || report.Missing[0] != degradedChunks[2].Index || len(report.Corrupt) != 0 {
+		t.Fatalf("expected chunk %d reported missing, got %+v", degradedChunks[2].Index, report)
+	}
+
+	// The outcome is recorded in both metadata stores
+	for fileID, want := range map[string]string{intactID: "healthy", corruptID: metadata.HealthCorrupted, degradedID: metadata.HealthDegraded} {
+		meta, err := optimized.LoadFileMetadata(fileID)
+		if err != nil {
+			t.Fatalf("failed to load enhanced metadata: %v", err)
+		}
+		if meta.HealthStatus != want || meta.LastVerified.IsZero() {
+			t.Errorf("expected %s recorded %s with a verification time, got %q at %v", fileID, want, meta.HealthStatus, meta.LastVerified)
+		}
+		fileMeta, _ := metaStore.GetFileMetadataByID(fileID)
+		if want == "healthy" {
+			want = ""
+		}
+		if fileMeta.Health != want || fileMeta.LastVerified == 0 {
+			t.Errorf("expected %s health %q in the metadata store, got %q at %d", fileID, want, fileMeta.Health, fileMeta.LastVerified)
+		}
+	}
+
+	// A wrong password is not mistaken for damage
+	if _, err := optimized.VerifyFileIntegrity(intactID, "wrong"); !errors.Is(err, chunker.ErrWrongPassword) {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+}
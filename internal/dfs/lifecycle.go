@@ -0,0 +1,533 @@
+package dfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// ErrRuleNotPreviewed is returned when enabling a rule whose current revision has not had a dry run
+var ErrRuleNotPreviewed = errors.New("rule must be previewed before it is enabled")
+
+// pinnedKey marks a pinned file in its custom metadata
+const pinnedKey = "lifecycle_pinned"
+
+// schedulerActor is recorded in the audit log for periodic passes
+const schedulerActor = "scheduler"
+
+// LifecycleOptions controls a lifecycle pass
+type LifecycleOptions struct {
+	DryRun bool      `json:"dry_run"`
+	Actor  string    `json:"-"` // Recorded in the audit log; empty for the scheduler
+	Now    time.Time `json:"-"` // Time the pass runs at; zero uses the current time
+}
+
+// LifecycleMatch is a file a rule matched and what was done to it
+type LifecycleMatch struct {
+	RuleID   string   `json:"rule_id"`
+	RuleName string   `json:"rule_name"`
+	FileID   string   `json:"file_id"`
+	FileName string   `json:"file_name"`
+	FileSize int64    `json:"file_size"`
+	Actions  []string `json:"actions"`           // Applied, or that a dry run would apply
+	Skipped  []string `json:"skipped,omitempty"` // Actions held back, with the reason
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// LifecycleReport is the result of a lifecycle pass
+type LifecycleReport struct {
+	ScannedAt      time.Time        `json:"scanned_at"`
+	Duration       time.Duration    `json:"duration"`
+	DryRun         bool             `json:"dry_run"`
+	RulesEvaluated int              `json:"rules_evaluated"`
+	FilesScanned   int              `json:"files_scanned"`
+	Matches        []LifecycleMatch `json:"matches"`
+	Errors         []string         `json:"errors,omitempty"`
+}
+
+// ValidateLifecycleRule checks a rule's name, condition and actions
+func ValidateLifecycleRule(rule metadata.LifecycleRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("rule needs at least one action")
+	}
+	c := rule.Condition
+	if c.MinAgeDays < 0 || c.IdleDays < 0 || c.MinSize < 0 || c.MaxSize < 0 {
+		return fmt.Errorf("condition values cannot be negative")
+	}
+	if c.MaxSize > 0 && c.MaxSize < c.MinSize {
+		return fmt.Errorf("max_size %d is below min_size %d", c.MaxSize, c.MinSize)
+	}
+	unconditional := len(c.Tags) == 0 && c.MimePrefix == "" && c.OwnerID == "" && c.MinAgeDays == 0 &&
+		c.IdleDays == 0 && c.MinSize == 0 && c.MaxSize == 0 && c.MaxAccessCount == nil
+
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case metadata.LifecycleDelete:
+			// A delete rule matching the whole catalog is almost certainly a mistake
+			if unconditional {
+				return fmt.Errorf("a delete rule needs a condition")
+			}
+		case metadata.LifecycleTier:
+			switch action.StorageClass {
+			case storage.ClassHot, storage.ClassWarm, storage.ClassCold, storage.ClassArchive:
+			default:
+				return fmt.Errorf("unknown storage class %q", action.StorageClass)
+			}
+		case metadata.LifecycleReplicate:
+			if action.Replicas < 1 {
+				return fmt.Errorf("replicate needs a replica count of at least 1")
+			}
+		case metadata.LifecyclePin, metadata.LifecycleNotify:
+		default:
+			return fmt.Errorf("unknown action %q", action.Type)
+		}
+	}
+	return nil
+}
+
+// lifecycleMatches reports whether a live file meets every set field of the condition
+func lifecycleMatches(c metadata.LifecycleCondition, file *metadata.EnhancedFileMetadata, now time.Time) bool {
+	if len(c.Tags) > 0 {
+		tagged := false
+		for _, want := range c.Tags {
+			for _, tag := range file.Tags {
+				if strings.EqualFold(tag, want) {
+					tagged = true
+				}
+			}
+		}
+		if !tagged {
+			return false
+		}
+	}
+	if c.MimePrefix != "" && !strings.HasPrefix(strings.ToLower(file.MimeType), strings.ToLower(c.MimePrefix)) {
+		return false
+	}
+	if c.OwnerID != "" && file.OwnerID != c.OwnerID {
+		return false
+	}
+	day := 24 * time.Hour
+	if c.MinAgeDays > 0 && now.Sub(file.CreatedAt) < time.Duration(c.MinAgeDays)*day {
+		return false
+	}
+	if c.IdleDays > 0 {
+		lastUsed := file.AccessedAt
+		if lastUsed.IsZero() {
+			lastUsed = file.CreatedAt
+		}
+		if now.Sub(lastUsed) < time.Duration(c.IdleDays)*day {
+			return false
+		}
+	}
+	if file.FileSize < c.MinSize || (c.MaxSize > 0 && file.FileSize > c.MaxSize) {
+		return false
+	}
+	if c.MaxAccessCount != nil && file.AccessCount > *c.MaxAccessCount {
+		return false
+	}
+	return true
+}
+
+func isPinned(file *metadata.EnhancedFileMetadata) bool {
+	pinned, _ := file.CustomMetadata[pinnedKey].(bool)
+	return pinned
+}
+
+func describeAction(action metadata.LifecycleAction) string {
+	switch action.Type {
+	case metadata.LifecycleTier:
+		return action.Type + ":" + action.StorageClass
+	case metadata.LifecycleReplicate:
+		return fmt.Sprintf("%s:%d", action.Type, action.Replicas)
+	}
+	return action.Type
+}
+
+// definitionChanged reports whether two revisions of a rule select or act differently
+func definitionChanged(a, b metadata.LifecycleRule) bool {
+	before, _ := json.Marshal(struct {
+		C metadata.LifecycleCondition
+		A []metadata.LifecycleAction
+	}{a.Condition, a.Actions})
+	after, _ := json.Marshal(struct {
+		C metadata.LifecycleCondition
+		A []metadata.LifecycleAction
+	}{b.Condition, b.Actions})
+	return string(before) != string(after)
+}
+
+// SaveLifecycleRule creates a rule, or updates the one with the same ID. New
+// rules start disabled, and changing a rule's condition or actions disables
+// it until the new revision is previewed. Enabling is left to SetLifecycleRuleEnabled.
+func (dfs *DFSCore) SaveLifecycleRule(rule metadata.LifecycleRule, actor string) (metadata.LifecycleRule, error) {
+	if dfs.metaStore == nil {
+		return rule, fmt.Errorf("lifecycle rules need a metadata store")
+	}
+	if err := ValidateLifecycleRule(rule); err != nil {
+		return rule, err
+	}
+	now := time.Now().Unix()
+
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+		rule.Enabled = false
+		rule.Revision = 1
+		rule.PreviewedRevision = 0
+		rule.CreatedBy = actor
+		rule.CreatedAt = now
+		rule.LastRunAt = 0
+	} else {
+		existing, err := dfs.metaStore.GetLifecycleRule(rule.ID)
+		if err != nil {
+			return rule, fmt.Errorf("rule %s not found", rule.ID)
+		}
+		updated := existing
+		updated.Name = rule.Name
+		if definitionChanged(existing, rule) {
+			updated.Condition = rule.Condition
+			updated.Actions = rule.Actions
+			updated.Revision++
+			updated.Enabled = false
+		}
+		rule = updated
+	}
+	rule.UpdatedAt = now
+
+	if err := dfs.metaStore.PutLifecycleRule(rule); err != nil {
+		return rule, fmt.Errorf("failed to store rule: %v", err)
+	}
+	dfs.auditLifecycle(metadata.LifecycleAuditEntry{RuleID: rule.ID, RuleName: rule.Name, Action: "rule.saved",
+		Actor: actor, Detail: fmt.Sprintf("revision %d", rule.Revision)})
+	return rule, nil
+}
+
+// SetLifecycleRuleEnabled turns a rule on or off. A rule can only be enabled
+// once its current revision has been previewed.
+func (dfs *DFSCore) SetLifecycleRuleEnabled(id string, enabled bool, actor string) (metadata.LifecycleRule, error) {
+	if dfs.metaStore == nil {
+		return metadata.LifecycleRule{}, fmt.Errorf("lifecycle rules need a metadata store")
+	}
+	rule, err := dfs.metaStore.GetLifecycleRule(id)
+	if err != nil {
+		return rule, fmt.Errorf("rule %s not found", id)
+	}
+	if enabled && rule.PreviewedRevision != rule.Revision {
+		return rule, fmt.Errorf("%w: revision %d", ErrRuleNotPreviewed, rule.Revision)
+	}
+	rule.Enabled = enabled
+	rule.UpdatedAt = time.Now().Unix()
+	if err := dfs.metaStore.PutLifecycleRule(rule); err != nil {
+		return rule, fmt.Errorf("failed to store rule: %v", err)
+	}
+	action := "rule.disabled"
+	if enabled {
+		action = "rule.enabled"
+	}
+	dfs.auditLifecycle(metadata.LifecycleAuditEntry{RuleID: rule.ID, RuleName: rule.Name, Action: action, Actor: actor})
+	return rule, nil
+}
+
+// DeleteLifecycleRule removes a rule; what it already did stays in the audit log
+func (dfs *DFSCore) DeleteLifecycleRule(id, actor string) error {
+	if dfs.metaStore == nil {
+		return fmt.Errorf("lifecycle rules need a metadata store")
+	}
+	rule, err := dfs.metaStore.GetLifecycleRule(id)
+	if err != nil {
+		return fmt.Errorf("rule %s not found", id)
+	}
+	if err := dfs.metaStore.DeleteLifecycleRule(id); err != nil {
+		return fmt.Errorf("failed to delete rule: %v", err)
+	}
+	dfs.auditLifecycle(metadata.LifecycleAuditEntry{RuleID: rule.ID, RuleName: rule.Name, Action: "rule.deleted", Actor: actor})
+	return nil
+}
+
+// ListLifecycleRules returns every rule, oldest first
+func (dfs *DFSCore) ListLifecycleRules() ([]metadata.LifecycleRule, error) {
+	if dfs.metaStore == nil {
+		return nil, fmt.Errorf("lifecycle rules need a metadata store")
+	}
+	return dfs.metaStore.ListLifecycleRules()
+}
+
+// LifecycleAudit returns the newest audit entries, optionally for one rule
+func (dfs *DFSCore) LifecycleAudit(ruleID string, limit int) ([]metadata.LifecycleAuditEntry, error) {
+	if dfs.metaStore == nil {
+		return nil, fmt.Errorf("lifecycle rules need a metadata store")
+	}
+	return dfs.metaStore.ListLifecycleAudit(ruleID, limit)
+}
+
+// PreviewLifecycleRule dry-runs a rule, enabled or not, against the catalog
+// and records the preview so the rule's current revision can be enabled
+func (dfs *DFSCore) PreviewLifecycleRule(id, actor string) (*LifecycleReport, error) {
+	if dfs.metaStore == nil {
+		return nil, fmt.Errorf("lifecycle rules need a metadata store")
+	}
+	rule, err := dfs.metaStore.GetLifecycleRule(id)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s not found", id)
+	}
+	report, err := dfs.evaluateLifecycle([]metadata.LifecycleRule{rule}, LifecycleOptions{DryRun: true, Actor: actor})
+	if err != nil {
+		return nil, err
+	}
+
+	rule.PreviewedRevision = rule.Revision
+	if err := dfs.metaStore.PutLifecycleRule(rule); err != nil {
+		return nil, fmt.Errorf("failed to store rule: %v", err)
+	}
+	dfs.auditLifecycle(metadata.LifecycleAuditEntry{RuleID: rule.ID, RuleName: rule.Name, Action: "rule.previewed", Actor: actor,
+		Detail: fmt.Sprintf("revision %d matches %d files", rule.Revision, len(report.Matches))})
+	return report, nil
+}
+
+// RunLifecycle evaluates every enabled rule, in the order they were created.
+// With DryRun set nothing is changed or audited.
+func (dfs *DFSCore) RunLifecycle(opts LifecycleOptions) (*LifecycleReport, error) {
+	if dfs.metaStore == nil {
+		return nil, fmt.Errorf("lifecycle rules need a metadata store")
+	}
+	all, err := dfs.metaStore.ListLifecycleRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rules: %v", err)
+	}
+	rules := make([]metadata.LifecycleRule, 0, len(all))
+	for _, rule := range all {
+		if rule.Enabled {
+			rules = append(rules, rule)
+		}
+	}
+
+	report, err := dfs.evaluateLifecycle(rules, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		for _, rule := range rules {
+			rule.LastRunAt = report.ScannedAt.Unix()
+			if err := dfs.metaStore.PutLifecycleRule(rule); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to record run of rule %s: %v", rule.ID, err))
+			}
+		}
+		if len(report.Matches) > 0 {
+			dfs.logger.Infof("📜 Lifecycle pass: %d rules matched %d files", len(rules), len(report.Matches))
+		}
+	}
+	return report, nil
+}
+
+// evaluateLifecycle matches rules against the live files in the catalog and
+// applies their actions unless the pass is a dry run. A file deleted by one
+// rule is not seen by later ones, and pinned files are never deleted or tiered.
+func (dfs *DFSCore) evaluateLifecycle(rules []metadata.LifecycleRule, opts LifecycleOptions) (*LifecycleReport, error) {
+	if dfs.OptimizedStorage == nil {
+		return nil, fmt.Errorf("lifecycle rules need the file catalog")
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if opts.Actor == "" {
+		opts.Actor = schedulerActor
+	}
+
+	start := time.Now()
+	report := &LifecycleReport{
+		ScannedAt:      now,
+		DryRun:         opts.DryRun,
+		RulesEvaluated: len(rules),
+		Matches:        make([]LifecycleMatch, 0),
+	}
+
+	files, err := dfs.OptimizedStorage.ListFileMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FileID < files[j].FileID })
+	report.FilesScanned = len(files)
+
+	deleted := make(map[string]bool)
+	pinned := make(map[string]bool)
+	for _, file := range files {
+		if isPinned(file) {
+			pinned[file.FileID] = true
+		}
+	}
+
+	for _, rule := range rules {
+		for _, file := range files {
+			if file.IsDeleted || deleted[file.FileID] || !lifecycleMatches(rule.Condition, file, now) {
+				continue
+			}
+			match := LifecycleMatch{
+				RuleID:   rule.ID,
+				RuleName: rule.Name,
+				FileID:   file.FileID,
+				FileName: file.FileName,
+				FileSize: file.FileSize,
+				Actions:  make([]string, 0, len(rule.Actions)),
+			}
+			for _, action := range rule.Actions {
+				description := describeAction(action)
+				if pinned[file.FileID] && (action.Type == metadata.LifecycleDelete || action.Type == metadata.LifecycleTier) {
+					match.Skipped = append(match.Skipped, description+": file is pinned")
+					continue
+				}
+
+				var err error
+				if !opts.DryRun {
+					actionStart := time.Now()
+					err = dfs.applyLifecycleAction(rule, action, file, now)
+					throttle.Global().Yield(context.Background(), throttle.JobLifecycle, time.Since(actionStart))
+					entry := metadata.LifecycleAuditEntry{RuleID: rule.ID, RuleName: rule.Name, Action: action.Type,
+						FileID: file.FileID, FileName: file.FileName, Actor: opts.Actor, Detail: description}
+					if err != nil {
+						entry.Error = err.Error()
+					}
+					dfs.auditLifecycle(entry)
+				}
+				if err != nil {
+					match.Errors = append(match.Errors, fmt.Sprintf("%s: %v", description, err))
+					continue
+				}
+				match.Actions = append(match.Actions, description)
+				switch action.Type {
+				case metadata.LifecycleDelete:
+					deleted[file.FileID] = true
+				case metadata.LifecyclePin:
+					pinned[file.FileID] = true
+				}
+			}
+			report.Matches = append(report.Matches, match)
+		}
+	}
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// applyLifecycleAction carries out one action on a file
+func (dfs *DFSCore) applyLifecycleAction(rule metadata.LifecycleRule, action metadata.LifecycleAction, file *metadata.EnhancedFileMetadata, now time.Time) error {
+	switch action.Type {
+	case metadata.LifecycleDelete:
+		// The expiry pass purges soft-deleted files once the purge delay has passed
+		if basic, err := dfs.metaStore.GetFileMetadataByID(file.FileID); err == nil && basic.DeletedAt == 0 {
+			basic.DeletedAt = now.Unix()
+			if err := dfs.metaStore.PutFileMetadataByID(file.FileID, basic); err != nil {
+				return fmt.Errorf("failed to soft-delete: %v", err)
+			}
+		}
+		deletedAt := now
+		file.IsDeleted = true
+		file.DeletedAt = &deletedAt
+
+	case metadata.LifecycleTier:
+		if tiered, ok := dfs.storage.(*storage.TieredStorage); ok {
+			for _, path := range dfs.fileChunkPaths(file.FileID) {
+				if err := tiered.MigrateToClass(path, action.StorageClass); err != nil {
+					return fmt.Errorf("failed to move chunk %s: %v", path, err)
+				}
+			}
+		}
+		file.StorageClass = action.StorageClass
+
+	case metadata.LifecycleReplicate:
+		if file.ReplicaCount < action.Replicas {
+			file.ReplicaCount = action.Replicas
+		}
+		dfs.raiseReplicas(file.FileID, action.Replicas)
+
+	case metadata.LifecyclePin:
+		if _, err := dfs.metaStore.GetFileMetadataByID(file.FileID); err == nil {
+			if err := dfs.metaStore.SetFileExpiry(file.FileID, time.Time{}); err != nil {
+				return fmt.Errorf("failed to clear TTL: %v", err)
+			}
+		}
+		if file.CustomMetadata == nil {
+			file.CustomMetadata = make(map[string]interface{})
+		}
+		file.CustomMetadata[pinnedKey] = true
+		file.ExpiresAt = nil
+
+	case metadata.LifecycleNotify:
+		message := action.Message
+		if message == "" {
+			message = fmt.Sprintf("Lifecycle rule %q matched %s", rule.Name, file.FileName)
+		}
+		dfs.events.Publish(events.TypeLifecycle, "dfs", message, map[string]interface{}{
+			"rule_id":   rule.ID,
+			"file_id":   file.FileID,
+			"file_name": file.FileName,
+		})
+		return nil
+	}
+	return dfs.OptimizedStorage.StoreFileMetadata(file)
+}
+
+// fileChunkPaths returns the storage IDs of a file's current chunks and parity shards
+func (dfs *DFSCore) fileChunkPaths(fileID string) []string {
+	basic, err := dfs.metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0, len(basic.ChunkHashes))
+	for _, hash := range basic.ChunkHashes {
+		if chunk, err := dfs.metaStore.GetChunkMetadata(hash); err == nil && chunk.Path != "" {
+			paths = append(paths, chunk.Path)
+		}
+	}
+	if parity, err := dfs.metaStore.GetParity(basic.CurrentContentID(fileID)); err == nil {
+		paths = append(paths, parity.Paths()...)
+	}
+	return paths
+}
+
+// raiseReplicas lifts the desired replica count of a file's tracked chunks and
+// schedules the copies they are short of
+func (dfs *DFSCore) raiseReplicas(fileID string, replicas int) {
+	shortfall := make(map[string]int)
+	dfs.replicaMu.Lock()
+	for chunkID, replica := range dfs.replicaInfo {
+		if replica.FileID != fileID || replica.DesiredReplicas >= replicas {
+			continue
+		}
+		replica.DesiredReplicas = replicas
+		dfs.retally(replica)
+		if missing := replicas - len(replica.CurrentReplicas); missing > 0 {
+			shortfall[chunkID] = missing
+		}
+	}
+	dfs.replicaMu.Unlock()
+
+	for chunkID, missing := range shortfall {
+		go dfs.createAdditionalReplicas(chunkID, missing)
+	}
+}
+
+// auditLifecycle records an audit entry, logging rather than failing when it cannot
+func (dfs *DFSCore) auditLifecycle(entry metadata.LifecycleAuditEntry) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if err := dfs.metaStore.AppendLifecycleAudit(entry); err != nil {
+		dfs.logger.Warnf("⚠️ Failed to audit lifecycle %s of rule %s: %v", entry.Action, entry.RuleID, err)
+	}
+}
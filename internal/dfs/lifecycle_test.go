@@ -0,0 +1,276 @@
+package dfs
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// newLifecycleCore builds a core over a fresh catalog holding files
+func newLifecycleCore(t *testing.T, files ...*metadata.EnhancedFileMetadata) (*DFSCore, *metadata.MetadataStore) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() { metaStore.Close() })
+	optimized, err := NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to create optimized storage: %v", err)
+	}
+	t.Cleanup(func() { optimized.Close() })
+
+	core := NewDFSCore(nil, nil, nil, store, metaStore)
+	core.OptimizedStorage = optimized
+	for _, file := range files {
+		if err := optimized.StoreFileMetadata(file); err != nil {
+			t.Fatalf("failed to store %s: %v", file.FileID, err)
+		}
+		if err := metaStore.PutFileMetadataByID(file.FileID, metadata.FileMetadata{FileName: file.FileName, FileSize: file.FileSize}); err != nil {
+			t.Fatalf("failed to store basic metadata of %s: %v", file.FileID, err)
+		}
+	}
+	return core, metaStore
+}
+
+func TestLifecycleDeletesOldTempFilesAfterPreview(t *testing.T) {
+	now := time.Now()
+	core, metaStore := newLifecycleCore(t,
+		&metadata.EnhancedFileMetadata{FileID: "old-temp", FileName: "old.tmp", FileSize: 100, Tags: []string{"temp"}, CreatedAt: now.Add(-10 * 24 * time.Hour)},
+		&metadata.EnhancedFileMetadata{FileID: "new-temp", FileName: "new.tmp", FileSize: 100, Tags: []string{"temp"}, CreatedAt: now.Add(-2 * 24 * time.Hour)},
+		&metadata.EnhancedFileMetadata{FileID: "old-report", FileName: "report.pdf", FileSize: 100, Tags: []string{"reports"}, CreatedAt: now.Add(-30 * 24 * time.Hour)},
+		&metadata.EnhancedFileMetadata{FileID: "pinned-temp", FileName: "keep.tmp", FileSize: 100, Tags: []string{"temp"}, CreatedAt: now.Add(-10 * 24 * time.Hour),
+			CustomMetadata: map[string]interface{}{pinnedKey: true}},
+	)
+
+	rule, err := core.SaveLifecycleRule(metadata.LifecycleRule{
+		Name:      "expire temp files",
+		Enabled:   true,
+		Condition: metadata.LifecycleCondition{Tags: []string{"temp"}, MinAgeDays: 7},
+		Actions:   []metadata.LifecycleAction{{Type: metadata.LifecycleDelete}},
+	}, "admin-1")
+	if err != nil {
+		t.Fatalf("SaveLifecycleRule failed: %v", err)
+	}
+	if rule.Enabled {
+		t.Fatal("a new rule must start disabled")
+	}
+	if _, err := core.SetLifecycleRuleEnabled(rule.ID, true, "admin-1"); !errors.Is(err, ErrRuleNotPreviewed) {
+		t.Fatalf("expected enabling an unpreviewed rule to fail, got %v", err)
+	}
+
+	preview, err := core.PreviewLifecycleRule(rule.ID, "admin-1")
+	if err != nil {
+		t.Fatalf("PreviewLifecycleRule failed: %v", err)
+	}
+	if !preview.DryRun || len(preview.Matches) != 2 {
+		t.Fatalf("expected a dry run matching the two old temp files, got %+v", preview)
+	}
+	for _, match := range preview.Matches {
+		switch match.FileID {
+		case "old-temp":
+			if len(match.Actions) != 1 || match.Actions[0] != metadata.LifecycleDelete {
+				t.Errorf("expected old-temp to be deleted, got %+v", match)
+			}
+		case "pinned-temp":
+			if len(match.Actions) != 0 || len(match.Skipped) != 1 {
+				t.Errorf("expected the pinned file's delete to be skipped, got %+v", match)
+			}
+		default:
+			t.Errorf("unexpected match %s", match.FileID)
+		}
+	}
+	if meta, _ := metaStore.GetFileMetadataByID("old-temp"); meta.DeletedAt != 0 {
+		t.Fatal("a preview must not change anything")
+	}
+
+	if _, err := core.SetLifecycleRuleEnabled(rule.ID, true, "admin-1"); err != nil {
+		t.Fatalf("enabling a previewed rule failed: %v", err)
+	}
+	report, err := core.RunLifecycle(LifecycleOptions{})
+	if err != nil {
+		t.Fatalf("RunLifecycle failed: %v", err)
+	}
+	if report.DryRun || report.RulesEvaluated != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if meta, _ := metaStore.GetFileMetadataByID("old-temp"); meta.DeletedAt == 0 {
+		t.Error("old-temp should be soft-deleted for the expiry pass to purge")
+	}
+	if enhanced, _ := core.OptimizedStorage.LoadFileMetadata("old-temp"); !enhanced.IsDeleted {
+		t.Error("old-temp should be marked deleted in the catalog")
+	}
+	for _, kept := range []string{"new-temp", "old-report", "pinned-temp"} {
+		if meta, _ := metaStore.GetFileMetadataByID(kept); meta.DeletedAt != 0 {
+			t.Errorf("%s should have been kept", kept)
+		}
+	}
+
+	audit, err := core.LifecycleAudit(rule.ID, 0)
+	if err != nil {
+		t.Fatalf("LifecycleAudit failed: %v", err)
+	}
+	actions := make(map[string]int)
+	for _, entry := range audit {
+		actions[entry.Action]++
+		if entry.Action == metadata.LifecycleDelete && (entry.FileID != "old-temp" || entry.Actor != schedulerActor) {
+			t.Errorf("unexpected delete audit entry %+v", entry)
+		}
+	}
+	if actions["rule.saved"] != 1 || actions["rule.previewed"] != 1 || actions["rule.enabled"] != 1 || actions[metadata.LifecycleDelete] != 1 {
+		t.Errorf("unexpected audit trail %v", actions)
+	}
+}
+
+func TestLifecycleTiersVideosAndRequiresNewPreviewAfterEdit(t *testing.T) {
+	now := time.Now()
+	core, _ := newLifecycleCore(t,
+		&metadata.EnhancedFileMetadata{FileID: "clip", FileName: "clip.mp4", FileSize: 5000, MimeType: "video/mp4", StorageClass: "hot", CreatedAt: now},
+		&metadata.EnhancedFileMetadata{FileID: "notes", FileName: "notes.txt", FileSize: 50, MimeType: "text/plain", StorageClass: "hot", CreatedAt: now},
+	)
+	bus := events.NewBus(0)
+	core.SetEventBus(bus)
+	sub := bus.Subscribe([]string{events.TypeLifecycle}, 0)
+	defer sub.Close()
+
+	rule, err := core.SaveLifecycleRule(metadata.LifecycleRule{
+		Name:      "videos to cold storage",
+		Condition: metadata.LifecycleCondition{MimePrefix: "video/"},
+		Actions: []metadata.LifecycleAction{
+			{Type: metadata.LifecycleTier, StorageClass: storage.ClassCold},
+			{Type: metadata.LifecycleNotify, Message: "video moved to cold storage"},
+		},
+	}, "admin-1")
+	if err != nil {
+		t.Fatalf("SaveLifecycleRule failed: %v", err)
+	}
+
+	preview, err := core.PreviewLifecycleRule(rule.ID, "admin-1")
+	if err != nil {
+		t.Fatalf("PreviewLifecycleRule failed: %v", err)
+	}
+	if len(preview.Matches) != 1 || preview.Matches[0].FileID != "clip" {
+		t.Fatalf("expected only the video to match, got %+v", preview.Matches)
+	}
+	if got := preview.Matches[0].Actions; len(got) != 2 || got[0] != "tier:cold" || got[1] != metadata.LifecycleNotify {
+		t.Errorf("unexpected previewed actions %v", got)
+	}
+	if clip, _ := core.OptimizedStorage.LoadFileMetadata("clip"); clip.StorageClass != "hot" {
+		t.Fatalf("a preview must not tier the file, got class %s", clip.StorageClass)
+	}
+	select {
+	case e := <-sub.C:
+		t.Fatalf("a preview must not notify, got %+v", e)
+	default:
+	}
+
+	// Changing what the rule does needs a fresh preview
+	if _, err := core.SetLifecycleRuleEnabled(rule.ID, true, "admin-1"); err != nil {
+		t.Fatalf("enabling the previewed rule failed: %v", err)
+	}
+	rule.Condition.MinSize = 1000
+	edited, err := core.SaveLifecycleRule(rule, "admin-1")
+	if err != nil {
+		t.Fatalf("editing the rule failed: %v", err)
+	}
+	if edited.Enabled || edited.Revision != 2 {
+		t.Fatalf("an edited rule should be disabled at revision 2, got %+v", edited)
+	}
+	if _, err := core.SetLifecycleRuleEnabled(rule.ID, true, "admin-1"); !errors.Is(err, ErrRuleNotPreviewed) {
+		t.Fatalf("expected the edited rule to need a preview, got %v", err)
+	}
+	if _, err := core.PreviewLifecycleRule(rule.ID, "admin-1"); err != nil {
+		t.Fatalf("PreviewLifecycleRule failed: %v", err)
+	}
+	if _, err := core.SetLifecycleRuleEnabled(rule.ID, true, "admin-1"); err != nil {
+		t.Fatalf("enabling the re-previewed rule failed: %v", err)
+	}
+
+	if _, err := core.RunLifecycle(LifecycleOptions{}); err != nil {
+		t.Fatalf("RunLifecycle failed: %v", err)
+	}
+	if clip, _ := core.OptimizedStorage.LoadFileMetadata("clip"); clip.StorageClass != storage.ClassCold {
+		t.Errorf("expected the video in cold storage, got %s", clip.StorageClass)
+	}
+	if notes, _ := core.OptimizedStorage.LoadFileMetadata("notes"); notes.StorageClass != "hot" {
+		t.Errorf("the text file should stay hot, got %s", notes.StorageClass)
+	}
+	select {
+	case e := <-sub.C:
+		if e.Message != "video moved to cold storage" || e.Data["file_id"] != "clip" {
+			t.Errorf("unexpected notification %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a notification for the video")
+	}
+}
+
+func TestLifecycleIdleRuleKeepsFilesThatAreRead(t *testing.T) {
+	longAgo := time.Now().Add(-60 * 24 * time.Hour)
+	core, metaStore := newLifecycleCore(t,
+		&metadata.EnhancedFileMetadata{FileID: "daily", FileName: "daily.csv", FileSize: 100, CreatedAt: longAgo, AccessedAt: longAgo},
+		&metadata.EnhancedFileMetadata{FileID: "forgotten", FileName: "forgotten.csv", FileSize: 100, CreatedAt: longAgo, AccessedAt: longAgo},
+	)
+
+	// Downloads and reassemblies record their reads this way
+	if err := core.OptimizedStorage.RecordAccess("daily"); err != nil {
+		t.Fatalf("RecordAccess failed: %v", err)
+	}
+	if daily, _ := core.OptimizedStorage.LoadFileMetadata("daily"); daily.AccessCount != 1 || !daily.AccessedAt.After(longAgo) {
+		t.Fatalf("expected the read recorded, got %+v", daily)
+	}
+
+	rule, err := core.SaveLifecycleRule(metadata.LifecycleRule{
+		Name:      "delete idle files",
+		Condition: metadata.LifecycleCondition{IdleDays: 30},
+		Actions:   []metadata.LifecycleAction{{Type: metadata.LifecycleDelete}},
+	}, "admin-1")
+	if err != nil {
+		t.Fatalf("SaveLifecycleRule failed: %v", err)
+	}
+	preview, err := core.PreviewLifecycleRule(rule.ID, "admin-1")
+	if err != nil {
+		t.Fatalf("PreviewLifecycleRule failed: %v", err)
+	}
+	if len(preview.Matches) != 1 || preview.Matches[0].FileID != "forgotten" {
+		t.Fatalf("expected only the unread file to match, got %+v", preview.Matches)
+	}
+	if _, err := core.SetLifecycleRuleEnabled(rule.ID, true, "admin-1"); err != nil {
+		t.Fatalf("enabling the previewed rule failed: %v", err)
+	}
+	if _, err := core.RunLifecycle(LifecycleOptions{}); err != nil {
+		t.Fatalf("RunLifecycle failed: %v", err)
+	}
+	if meta, _ := metaStore.GetFileMetadataByID("daily"); meta.DeletedAt != 0 {
+		t.Error("a file that was just read must survive an idle rule")
+	}
+	if meta, _ := metaStore.GetFileMetadataByID("forgotten"); meta.DeletedAt == 0 {
+		t.Error("the unread file should be deleted")
+	}
+}
+
+func TestValidateLifecycleRule(t *testing.T) {
+	invalid := []metadata.LifecycleRule{
+		{Name: "", Actions: []metadata.LifecycleAction{{Type: metadata.LifecycleNotify}}},
+		{Name: "no actions"},
+		{Name: "delete everything", Actions: []metadata.LifecycleAction{{Type: metadata.LifecycleDelete}}},
+		{Name: "bad class", Actions: []metadata.LifecycleAction{{Type: metadata.LifecycleTier, StorageClass: "lukewarm"}}},
+		{Name: "no replicas", Actions: []metadata.LifecycleAction{{Type: metadata.LifecycleReplicate}}},
+		{Name: "unknown", Actions: []metadata.LifecycleAction{{Type: "shred"}}},
+	}
+	for _, rule := range invalid {
+		if err := ValidateLifecycleRule(rule); err == nil {
+			t.Errorf("expected rule %q to be rejected", rule.Name)
+		}
+	}
+}
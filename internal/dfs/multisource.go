@@ -0,0 +1,435 @@
+package dfs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// MultiSourceOptions controls how a reassembly spreads a file's chunk
+// downloads across every peer holding them
+type MultiSourceOptions struct {
+	Enabled bool
+	PerPeer int // Concurrent downloads from one peer
+	Window  int // Chunks downloaded but not yet assembled, bounding reorder-buffer memory
+}
+
+// DefaultMultiSourceOptions returns multi-source settings with the bounds filled in
+func DefaultMultiSourceOptions() MultiSourceOptions {
+	return MultiSourceOptions{Enabled: true, PerPeer: 2, Window: 16}
+}
+
+func (o MultiSourceOptions) normalize() MultiSourceOptions {
+	if o.PerPeer <= 0 {
+		o.PerPeer = 2
+	}
+	if o.Window <= 0 {
+		o.Window = 16
+	}
+	return o
+}
+
+// A download running stallMultiple times its peer's usual chunk time is
+// duplicated on an idle holder; the first copy back is used
+const (
+	stallMultiple = 3
+	minStall      = 50 * time.Millisecond
+)
+
+// PeerContribution reports what one source added to a reassembly
+type PeerContribution struct {
+	NodeID         string  `json:"node_id"`
+	Chunks         int     `json:"chunks"`
+	Bytes          int64   `json:"bytes"`
+	Failures       int     `json:"failures"`
+	Stolen         int     `json:"stolen"` // Chunks taken over from a slower peer
+	ThroughputMBps float64 `json:"throughput_mbps"`
+}
+
+// sourceStats tracks one source's downloads during a reassembly
+type sourceStats struct {
+	chunks, failures, stolen int
+	bytes                    int64
+	busy                     time.Duration // Time spent in successful downloads
+}
+
+// chunkTime is the source's average time per chunk, 0 before its first one
+func (s *sourceStats) chunkTime() time.Duration {
+	if s.chunks == 0 {
+		return 0
+	}
+	return s.busy / time.Duration(s.chunks)
+}
+
+// multiSource downloads a file's chunks from all of their holders at once.
+// Each chunk is queued on the holder expected to deliver it soonest; idle
+// workers steal queued chunks from busier holders and duplicate downloads
+// stalled on a slow one. Only Window chunks are admitted ahead of the ones
+// taken for assembly.
+type multiSource struct {
+	opts     MultiSourceOptions
+	chunkIDs []string
+	local    string
+	fetch    func(chunkID string, node *p2p.Node) ([]byte, error)
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	nodes    map[string]*p2p.Node
+	holders  [][]string
+	failed   []map[string]bool
+	inflight []map[string]time.Time
+	running  map[string]int // Downloads in flight per node
+	queues   map[string][]int
+	ready    map[int][]byte
+	sources  map[int]string
+	errs     map[int]error
+	finished []bool
+	low      int // Lowest unfinished index
+	admitted int // Next index to admit
+	pending  int // Admitted but not yet taken
+	stats    map[string]*sourceStats
+	closed   bool
+	stop     chan struct{}
+}
+
+// newMultiSource starts workers for every holder of the chunks. The local
+// node is always a candidate and is preferred; a miss there costs nothing.
+func newMultiSource(opts MultiSourceOptions, chunkIDs []string, local *p2p.Node,
+	holdersOf func(chunkID string) []*p2p.Node, fetch func(chunkID string, node *p2p.Node) ([]byte, error)) *multiSource {
+	ms := &multiSource{
+		opts:     opts.normalize(),
+		chunkIDs: chunkIDs,
+		local:    local.ID,
+		fetch:    fetch,
+		nodes:    map[string]*p2p.Node{local.ID: local},
+		holders:  make([][]string, len(chunkIDs)),
+		failed:   make([]map[string]bool, len(chunkIDs)),
+		inflight: make([]map[string]time.Time, len(chunkIDs)),
+		running:  make(map[string]int),
+		queues:   make(map[string][]int),
+		ready:    make(map[int][]byte),
+		sources:  make(map[int]string),
+		errs:     make(map[int]error),
+		finished: make([]bool, len(chunkIDs)),
+		stats:    map[string]*sourceStats{local.ID: {}},
+		stop:     make(chan struct{}),
+	}
+	ms.cond = sync.NewCond(&ms.mu)
+	for i, chunkID := range chunkIDs {
+		ms.holders[i] = []string{local.ID}
+		ms.failed[i] = make(map[string]bool)
+		ms.inflight[i] = make(map[string]time.Time)
+		for _, node := range holdersOf(chunkID) {
+			if node.ID == local.ID || containsString(ms.holders[i], node.ID) {
+				continue
+			}
+			ms.holders[i] = append(ms.holders[i], node.ID)
+			if _, known := ms.nodes[node.ID]; !known {
+				ms.nodes[node.ID] = node
+				ms.stats[node.ID] = &sourceStats{}
+			}
+		}
+	}
+
+	ms.mu.Lock()
+	ms.admitLocked()
+	ms.mu.Unlock()
+	for nodeID := range ms.nodes {
+		for w := 0; w < ms.opts.PerPeer; w++ {
+			go ms.worker(nodeID)
+		}
+	}
+	// Stalls are noticed by time passing, not by any event
+	go func() {
+		ticker := time.NewTicker(minStall)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ms.cond.Broadcast()
+			case <-ms.stop:
+				return
+			}
+		}
+	}()
+	return ms
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// admitLocked queues chunks in index order while the window has room
+func (ms *multiSource) admitLocked() {
+	for ms.admitted < len(ms.chunkIDs) && ms.pending < ms.opts.Window {
+		ms.pending++
+		ms.assignLocked(ms.admitted)
+		ms.admitted++
+	}
+	ms.cond.Broadcast()
+}
+
+// costLocked estimates how long nodeID would take to deliver one more chunk
+func (ms *multiSource) costLocked(nodeID string) time.Duration {
+	if nodeID == ms.local {
+		return 0
+	}
+	per := ms.stats[nodeID].chunkTime()
+	if per == 0 {
+		per = ms.averageChunkTimeLocked()
+	}
+	load := len(ms.queues[nodeID]) + ms.running[nodeID] + 1
+	// Until anything is measured, spread by load alone
+	if per == 0 {
+		return time.Duration(load)
+	}
+	return time.Duration(load) * per
+}
+
+// averageChunkTimeLocked is the mean chunk time over the remote sources measured so far
+func (ms *multiSource) averageChunkTimeLocked() time.Duration {
+	var total time.Duration
+	measured := 0
+	for nodeID, stats := range ms.stats {
+		if nodeID != ms.local && stats.chunks > 0 {
+			total += stats.chunkTime()
+			measured++
+		}
+	}
+	if measured == 0 {
+		return 0
+	}
+	return total / time.Duration(measured)
+}
+
+// assignLocked queues chunk i on its cheapest holder that has not failed it,
+// or fails the chunk when none is left
+func (ms *multiSource) assignLocked(i int) {
+	best, bestCost := "", time.Duration(0)
+	for _, nodeID := range ms.holders[i] {
+		if ms.failed[i][nodeID] {
+			continue
+		}
+		if cost := ms.costLocked(nodeID); best == "" || cost < bestCost || (cost == bestCost && nodeID < best) {
+			best, bestCost = nodeID, cost
+		}
+	}
+	if best == "" {
+		ms.finishLocked(i, nil, "", fmt.Errorf("failed to download chunk %s from any node", ms.chunkIDs[i]))
+		return
+	}
+	ms.queues[best] = insertSorted(ms.queues[best], i)
+}
+
+func insertSorted(queue []int, i int) []int {
+	at := sort.SearchInts(queue, i)
+	queue = append(queue, 0)
+	copy(queue[at+1:], queue[at:])
+	queue[at] = i
+	return queue
+}
+
+func (ms *multiSource) finishLocked(i int, data []byte, source string, err error) {
+	ms.finished[i] = true
+	for ms.low < len(ms.finished) && ms.finished[ms.low] {
+		ms.low++
+	}
+	if err != nil {
+		ms.errs[i] = err
+	} else {
+		ms.ready[i] = data
+		ms.sources[i] = source
+	}
+	ms.cond.Broadcast()
+}
+
+// nextLocked picks the chunk nodeID's worker downloads next: its own queue
+// first, then a chunk queued on a busier holder, then one stalled elsewhere
+func (ms *multiSource) nextLocked(nodeID string) (int, bool) {
+	if queue := ms.queues[nodeID]; len(queue) > 0 {
+		ms.queues[nodeID] = queue[1:]
+		return queue[0], true
+	}
+	if nodeID == ms.local {
+		return 0, false // Local misses are reassigned, never stolen
+	}
+	if i, ok := ms.stealLocked(nodeID); ok {
+		ms.stats[nodeID].stolen++
+		return i, true
+	}
+	if i, ok := ms.stalledLocked(nodeID); ok {
+		ms.stats[nodeID].stolen++
+		return i, true
+	}
+	return 0, false
+}
+
+// stealLocked takes the lowest queued chunk nodeID holds from the remote
+// holder with the longest expected backlog
+func (ms *multiSource) stealLocked(nodeID string) (int, bool) {
+	victim, at, backlog := "", -1, time.Duration(-1)
+	for other, queue := range ms.queues {
+		if other == nodeID || other == ms.local || len(queue) == 0 {
+			continue
+		}
+		for pos, i := range queue {
+			if containsString(ms.holders[i], nodeID) && !ms.failed[i][nodeID] {
+				if cost := ms.costLocked(other); cost > backlog {
+					victim, at, backlog = other, pos, cost
+				}
+				break
+			}
+		}
+	}
+	if victim == "" {
+		return 0, false
+	}
+	queue := ms.queues[victim]
+	i := queue[at]
+	ms.queues[victim] = append(queue[:at:at], queue[at+1:]...)
+	return i, true
+}
+
+// stalledLocked finds the lowest chunk whose download has run well past its
+// peer's usual chunk time and that nodeID could fetch instead
+func (ms *multiSource) stalledLocked(nodeID string) (int, bool) {
+	now := time.Now()
+	for i := ms.low; i < ms.admitted; i++ {
+		// A chunk is duplicated once at most
+		if ms.finished[i] || len(ms.inflight[i]) != 1 {
+			continue
+		}
+		if _, running := ms.inflight[i][nodeID]; running || ms.failed[i][nodeID] || !containsString(ms.holders[i], nodeID) {
+			continue
+		}
+		for other, started := range ms.inflight[i] {
+			expected := ms.stats[other].chunkTime()
+			if expected == 0 {
+				expected = ms.averageChunkTimeLocked()
+			}
+			if expected == 0 {
+				continue
+			}
+			limit := stallMultiple * expected
+			if limit < minStall {
+				limit = minStall
+			}
+			if now.Sub(started) > limit {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (ms *multiSource) worker(nodeID string) {
+	node := ms.nodes[nodeID]
+	for {
+		ms.mu.Lock()
+		var i int
+		for {
+			if ms.closed {
+				ms.mu.Unlock()
+				return
+			}
+			var ok bool
+			if i, ok = ms.nextLocked(nodeID); ok {
+				break
+			}
+			ms.cond.Wait()
+		}
+		ms.inflight[i][nodeID] = time.Now()
+		ms.running[nodeID]++
+		ms.mu.Unlock()
+
+		start := time.Now()
+		data, err := ms.fetch(ms.chunkIDs[i], node)
+		elapsed := time.Since(start)
+
+		ms.mu.Lock()
+		delete(ms.inflight[i], nodeID)
+		ms.running[nodeID]--
+		stats := ms.stats[nodeID]
+		switch {
+		case ms.finished[i]:
+			// Another holder delivered the chunk first
+		case err != nil:
+			ms.failed[i][nodeID] = true
+			if nodeID != ms.local {
+				stats.failures++
+			}
+			if len(ms.inflight[i]) == 0 {
+				ms.assignLocked(i)
+			}
+		default:
+			stats.chunks++
+			stats.bytes += int64(len(data))
+			stats.busy += elapsed
+			ms.finishLocked(i, data, nodeID, nil)
+		}
+		ms.cond.Broadcast()
+		ms.mu.Unlock()
+	}
+}
+
+// take waits for chunk i and hands it over, making room in the window
+func (ms *multiSource) take(i int) ([]byte, string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for !ms.finished[i] {
+		if ms.closed {
+			return nil, "", fmt.Errorf("download of chunk %s was stopped", ms.chunkIDs[i])
+		}
+		ms.cond.Wait()
+	}
+	data, source, err := ms.ready[i], ms.sources[i], ms.errs[i]
+	delete(ms.ready, i)
+	ms.pending--
+	ms.admitLocked()
+	return data, source, err
+}
+
+// close stops the workers; downloads already running finish in the background
+func (ms *multiSource) close() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.closed {
+		return
+	}
+	ms.closed = true
+	close(ms.stop)
+	ms.cond.Broadcast()
+}
+
+// contributions reports each source's share of the download, by node ID
+func (ms *multiSource) contributions() []PeerContribution {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	out := make([]PeerContribution, 0, len(ms.stats))
+	for nodeID, stats := range ms.stats {
+		if stats.chunks == 0 && stats.failures == 0 {
+			continue
+		}
+		contribution := PeerContribution{
+			NodeID:   nodeID,
+			Chunks:   stats.chunks,
+			Bytes:    stats.bytes,
+			Failures: stats.failures,
+			Stolen:   stats.stolen,
+		}
+		if stats.busy > 0 {
+			contribution.ThroughputMBps = float64(stats.bytes) / (1024 * 1024) / stats.busy.Seconds()
+		}
+		out = append(out, contribution)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeID < out[j].NodeID })
+	return out
+}
@@ -0,0 +1,210 @@
+package dfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestMultiSourceReassemblySpreadsChunksAndStealsFromASlowPeer(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	// The uploading node serves its chunks to a reassembler that has none of them
+	d := distributor.NewDistributor(p2p.NewNetwork("127.0.0.1", 0), store, metaStore)
+	content := make([]byte, 24*chunker.MinChunkSize)
+	rand.New(rand.NewSource(1)).Read(content)
+	input := filepath.Join(dir, "video.bin")
+	os.WriteFile(input, content, 0644)
+	file, err := d.DistributeFileContext(context.Background(), input, "pw")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	addPeer := func(id string, delay time.Duration) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			d.HandleChunkRequest(w, r)
+		}))
+		t.Cleanup(server.Close)
+		network.RegisterPeer(&p2p.Node{ID: id, Address: "127.0.0.1", Port: server.Listener.Addr().(*net.TCPAddr).Port, Status: "online", LastSeen: time.Now()})
+		for _, chunkID := range file.Chunks {
+			network.AddChunkToNode(id, chunkID)
+		}
+	}
+	addPeer("fast-1", 5*time.Millisecond)
+	addPeer("fast-2", 5*time.Millisecond)
+	addPeer("slow", 300*time.Millisecond)
+
+	empty, err := storage.NewLocalStorage(filepath.Join(dir, "empty"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	fr := NewFileReassembler(NewDFSCore(nil, network, d, empty, metaStore), d, empty, metaStore, network)
+	fr.SetMultiSource(MultiSourceOptions{Enabled: true, PerPeer: 2, Window: 6})
+
+	output := filepath.Join(dir, "out", "video.bin")
+	job := &ReassemblyJob{
+		FileID:         file.ID,
+		FileName:       file.Name,
+		StartTime:      time.Now(),
+		OutputPath:     output,
+		TotalChunks:    len(file.Chunks),
+		ChunkStatus:    make(map[string]string),
+		IntegrityCheck: &IntegrityCheckResult{ChunkHashes: make(map[string]string)},
+	}
+	fr.executeReassembly(job, file, "pw")
+	if job.Status != "completed" {
+		t.Fatalf("expected the reassembly to complete, got %s: %s", job.Status, job.ErrorMessage)
+	}
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, content) {
+		t.Fatalf("reassembled file differs from the original")
+	}
+
+	byNode := make(map[string]PeerContribution)
+	total, stolen := 0, 0
+	for _, source := range job.Sources {
+		byNode[source.NodeID] = source
+		total += source.Chunks
+		stolen += source.Stolen
+	}
+	if total != len(file.Chunks) {
+		t.Errorf("expected the contributions to add up to %d chunks, got %+v", len(file.Chunks), job.Sources)
+	}
+	fast := byNode["fast-1"].Chunks + byNode["fast-2"].Chunks
+	if byNode["fast-1"].Chunks == 0 || byNode["fast-2"].Chunks == 0 || fast <= byNode["slow"].Chunks {
+		t.Errorf("expected both fast peers to carry most of the load, got %+v", job.Sources)
+	}
+	if stolen == 0 {
+		t.Errorf("expected the fast peers to take over chunks queued on the slow one, got %+v", job.Sources)
+	}
+	if byNode["fast-1"].ThroughputMBps <= byNode["slow"].ThroughputMBps && byNode["slow"].Chunks > 0 {
+		t.Errorf("expected per-peer throughput reported, got %+v", job.Sources)
+	}
+}
+
+func TestMultiSourceBoundsTheWindowAndRetriesOtherHolders(t *testing.T) {
+	local := &p2p.Node{ID: "local"}
+	peers := []*p2p.Node{{ID: "a"}, {ID: "b"}}
+	chunkIDs := make([]string, 40)
+	for i := range chunkIDs {
+		chunkIDs[i] = fmt.Sprintf("c%d", i)
+	}
+
+	var mu sync.Mutex
+	downloaded, taken, maxAhead := 0, 0, 0
+	ms := newMultiSource(MultiSourceOptions{PerPeer: 3, Window: 5}, chunkIDs, local,
+		func(string) []*p2p.Node { return peers },
+		func(chunkID string, node *p2p.Node) ([]byte, error) {
+			// The local node holds nothing, and peer a has lost every other chunk
+			if node.ID == "local" || (node.ID == "a" && len(chunkID)%2 == 0) {
+				return nil, fmt.Errorf("chunk %s not found", chunkID)
+			}
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			downloaded++
+			if ahead := downloaded - taken; ahead > maxAhead {
+				maxAhead = ahead
+			}
+			return []byte(chunkID), nil
+		})
+	defer ms.close()
+
+	for i, chunkID := range chunkIDs {
+		data, _, err := ms.take(i)
+		if err != nil || string(data) != chunkID {
+			t.Fatalf("chunk %d: got %q, %v", i, data, err)
+		}
+		mu.Lock()
+		taken++
+		mu.Unlock()
+	}
+	if maxAhead > 5 {
+		t.Errorf("expected at most 5 chunks held ahead of assembly, got %d", maxAhead)
+	}
+
+	contributions := ms.contributions()
+	if len(contributions) != 2 || contributions[0].NodeID != "a" || contributions[0].Failures == 0 {
+		t.Errorf("expected peer a's misses counted and the local misses not, got %+v", contributions)
+	}
+}
+
+// BenchmarkMultiSourceDownload downloads one file from 1, 2 and 4 replica
+// holders. Each holder serves one chunk at a time over a simulated link, so
+// MB/s grows with the number of sources only by aggregating their bandwidth.
+func BenchmarkMultiSourceDownload(b *testing.B) {
+	const (
+		chunks    = 64
+		chunkSize = 64 * 1024
+		linkRate  = 64 << 20 // Bytes per second per holder
+	)
+	chunkIDs := make([]string, chunks)
+	for i := range chunkIDs {
+		chunkIDs[i] = fmt.Sprintf("chunk-%d", i)
+	}
+	payload := make([]byte, chunkSize)
+	perChunk := time.Second * chunkSize / linkRate
+
+	for _, sources := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("sources=%d", sources), func(b *testing.B) {
+			peers := make([]*p2p.Node, sources)
+			links := make(map[string]*sync.Mutex, sources)
+			for i := range peers {
+				peers[i] = &p2p.Node{ID: fmt.Sprintf("peer-%d", i)}
+				links[peers[i].ID] = &sync.Mutex{}
+			}
+			fetch := func(chunkID string, node *p2p.Node) ([]byte, error) {
+				link, ok := links[node.ID]
+				if !ok {
+					return nil, fmt.Errorf("chunk %s not stored locally", chunkID)
+				}
+				link.Lock()
+				defer link.Unlock()
+				time.Sleep(perChunk)
+				return payload, nil
+			}
+
+			b.SetBytes(chunks * chunkSize)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				ms := newMultiSource(MultiSourceOptions{PerPeer: 2, Window: 16}, chunkIDs, &p2p.Node{ID: "local"},
+					func(string) []*p2p.Node { return peers }, fetch)
+				for i := range chunkIDs {
+					if _, _, err := ms.take(i); err != nil {
+						b.Fatalf("download failed: %v", err)
+					}
+				}
+				ms.close()
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package dfs
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
 	"github.com/sirupsen/logrus"
@@ -28,6 +30,18 @@ type OptimizedStorage struct {
 	statsUpdateChan  chan bool
 	stopChan         chan bool
 	wg               sync.WaitGroup
+	
+	// Access-based tiering, set by SetTiering
+	tieringMu        sync.Mutex
+	tieringPolicy    TieringPolicy
+	tiers            *storage.TieredStorage
+	chunkPaths       func(fileID string) []string
+	
+	// Chunks checked by VerifyFileIntegrity, set by SetChunkSource and SetKeyManager
+	sourceMu         sync.RWMutex
+	metaStore        *metadata.MetadataStore
+	store            storage.Storage
+	keyManager       *encryptor.KeyManager
 }
 
 // NewOptimizedStorage creates a new optimized storage layer
@@ -95,7 +109,9 @@ func (os *OptimizedStorage) Close() error {
 	return nil
 }
 
-// StoreChunk stores a chunk with optimization
+// StoreChunk stores a chunk with optimization. Chunks are kept by content
+// hash, so a chunk another file already stored is not written again; it
+// gains a reference, and its metadata is marked as deduplicated.
 func (os *OptimizedStorage) StoreChunk(chunkData io.Reader, chunkID, fileID string, index int, storageNodes []string) (string, error) {
 	// Use optimization engine to store chunk
 	hashID, chunkInfo, err := os.optimizationEngine.OptimizedPut(chunkData)
@@ -173,6 +189,64 @@ func (os *OptimizedStorage) RetrieveChunk(chunkID string) (io.ReadCloser, error)
 	return reader, nil
 }
 
+// MarkFileDeleted flags a file deleted without recording an access
+func (os *OptimizedStorage) MarkFileDeleted(fileID string, at time.Time) error {
+	return os.enhancedMetadata.MarkFileDeleted(fileID, at)
+}
+
+// DeleteFile removes a file's metadata and releases its chunks. A chunk
+// other files still reference keeps its bytes and only loses this file's
+// reference; releasing the last one removes it from disk.
+func (os *OptimizedStorage) DeleteFile(fileID string) error {
+	file, err := os.enhancedMetadata.DeleteFileMetadata(fileID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	removed := 0
+	for _, chunkID := range file.ChunkHashes {
+		chunk, err := os.enhancedMetadata.LoadChunkMetadata(chunkID)
+		if err != nil {
+			continue // Never recorded, or already released
+		}
+		remaining, held, err := os.optimizationEngine.ReleaseChunk(chunk.Hash)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if held && remaining == 0 {
+			removed++
+		}
+		if !held {
+			// The chunker shares its chunks by hash and counts them in the record alone
+			remaining = chunk.ReferenceCount - 1
+		}
+
+		// A record kept under the chunk's hash is how other files reach it too
+		shared := remaining > 0 && chunk.ChunkID == chunk.Hash
+		switch {
+		case shared || (held && chunk.FileID != fileID):
+			chunk.ReferenceCount = remaining
+			chunk.IsDeduplicated = remaining > 1
+			if err := os.enhancedMetadata.StoreChunkMetadata(chunk); err != nil {
+				errs = append(errs, err)
+			}
+		case chunk.FileID == fileID || chunk.ChunkID == chunk.Hash:
+			if err := os.enhancedMetadata.DeleteChunkMetadata(chunkID); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	os.logger.Infof("🗑️ Deleted file %s, removing %d of its %d chunks", fileID, removed, len(file.ChunkHashes))
+	select {
+	case os.statsUpdateChan <- true:
+	default:
+	}
+	return errors.Join(errs...)
+}
+
 // StoreFileMetadata stores comprehensive file metadata
 func (os *OptimizedStorage) StoreFileMetadata(meta *metadata.EnhancedFileMetadata) error {
 	return os.enhancedMetadata.StoreFileMetadata(meta)
@@ -183,6 +257,76 @@ func (os *OptimizedStorage) GetFileMetadata(fileID string) (*metadata.EnhancedFi
 	return os.enhancedMetadata.GetFileMetadata(fileID)
 }
 
+// LoadFileMetadata retrieves file metadata without recording an access
+func (os *OptimizedStorage) LoadFileMetadata(fileID string) (*metadata.EnhancedFileMetadata, error) {
+	return os.enhancedMetadata.LoadFileMetadata(fileID)
+}
+
+// RecordAccess marks a file as read now, for access-based tiering and lifecycle rules
+func (os *OptimizedStorage) RecordAccess(fileID string) error {
+	return os.enhancedMetadata.RecordAccess(fileID)
+}
+
+// ListFileMetadata returns the metadata of every stored file
+func (os *OptimizedStorage) ListFileMetadata() ([]*metadata.EnhancedFileMetadata, error) {
+	return os.enhancedMetadata.ListFileMetadata()
+}
+
+// ListAllFiles returns the metadata of every live file, most recently modified first
+func (os *OptimizedStorage) ListAllFiles() ([]*metadata.EnhancedFileMetadata, error) {
+	return os.enhancedMetadata.ListAllFiles()
+}
+
+// HasChunkMetadata reports whether enhanced metadata exists for a chunk
+func (os *OptimizedStorage) HasChunkMetadata(chunkID string) (bool, error) {
+	return os.enhancedMetadata.HasChunkMetadata(chunkID)
+}
+
+// StoreChunkMetadata stores enhanced metadata for a chunk already in storage
+func (os *OptimizedStorage) StoreChunkMetadata(meta *metadata.EnhancedChunkMetadata) error {
+	return os.enhancedMetadata.StoreChunkMetadata(meta)
+}
+
+// LinkChunks adds chunk IDs missing from a file's enhanced record
+func (os *OptimizedStorage) LinkChunks(fileID string, chunkIDs []string) (bool, error) {
+	return os.enhancedMetadata.LinkChunks(fileID, chunkIDs)
+}
+
+// FilesByOwner returns the metadata of the files a user owns
+func (os *OptimizedStorage) FilesByOwner(ownerID string) ([]*metadata.EnhancedFileMetadata, error) {
+	return os.enhancedMetadata.FilesByOwner(ownerID)
+}
+
+// SetTagPolicy sets the limits applied to file tags and categories
+func (os *OptimizedStorage) SetTagPolicy(policy metadata.TagPolicy) {
+	os.enhancedMetadata.SetTagPolicy(policy)
+}
+
+// UpdateFileTags replaces a file's tags and categories; nil leaves that list unchanged
+func (os *OptimizedStorage) UpdateFileTags(fileID string, tags, categories []string) (*metadata.EnhancedFileMetadata, error) {
+	return os.enhancedMetadata.UpdateFileTags(fileID, tags, categories)
+}
+
+// SuggestTags returns known tags close to input
+func (os *OptimizedStorage) SuggestTags(input string, limit int) []string {
+	return os.enhancedMetadata.SuggestTags(input, limit)
+}
+
+// SetIndexSnapshotCodec sets how search index snapshots are compressed
+func (os *OptimizedStorage) SetIndexSnapshotCodec(codec string) error {
+	return os.enhancedMetadata.SetIndexSnapshotCodec(codec)
+}
+
+// IndexStatus reports where the search indices came from and any rebuild
+func (os *OptimizedStorage) IndexStatus() metadata.IndexStatus {
+	return os.enhancedMetadata.IndexStatus()
+}
+
+// RebuildIndices rebuilds the search indices from the file records in the background
+func (os *OptimizedStorage) RebuildIndices(reason string) bool {
+	return os.enhancedMetadata.RebuildIndices(reason)
+}
+
 // VersionFile creates a new version of a file
 func (os *OptimizedStorage) VersionFile(fileID, createdBy, changeLog string) (*metadata.FileVersion, error) {
 	return os.enhancedMetadata.CreateFileVersion(fileID, createdBy, changeLog)
@@ -208,9 +352,12 @@ func (os *OptimizedStorage) GetFileVersions(fileID string) ([]*metadata.FileVers
 
 // GetFileRelationships gets all relationships for a file
 func (os *OptimizedStorage) GetFileRelationships(fileID string) ([]*metadata.FileRelationship, error) {
-	// TODO: Implement this method in enhanced metadata store
-	// For now, return empty list
-	return []*metadata.FileRelationship{}, nil
+	return os.enhancedMetadata.GetFileRelationships(fileID)
+}
+
+// PutFileRelationship stores a relationship recorded elsewhere as is
+func (os *OptimizedStorage) PutFileRelationship(relationship *metadata.FileRelationship) error {
+	return os.enhancedMetadata.PutFileRelationship(relationship)
 }
 
 // GetStorageStats returns comprehensive storage statistics
@@ -0,0 +1,90 @@
+package dfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+func TestDeleteFileKeepsChunksSharedWithOtherFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "optimized")
+	optimized, err := NewOptimizedStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create optimized storage: %v", err)
+	}
+	t.Cleanup(func() { optimized.Close() })
+
+	// The two files overlap in their middle chunk
+	storeFile := func(fileID string, contents ...string) []string {
+		t.Helper()
+		var chunkIDs, hashes []string
+		for i, content := range contents {
+			chunkID := fileID + "-" + string(rune('0'+i))
+			hash, err := optimized.StoreChunk(strings.NewReader(content), chunkID, fileID, i, []string{"node-1"})
+			if err != nil {
+				t.Fatalf("failed to store chunk %s: %v", chunkID, err)
+			}
+			chunkIDs = append(chunkIDs, chunkID)
+			hashes = append(hashes, hash)
+		}
+		file := &metadata.EnhancedFileMetadata{FileID: fileID, FileName: fileID + ".txt", ChunkHashes: chunkIDs, ChunkCount: len(chunkIDs)}
+		if err := optimized.StoreFileMetadata(file); err != nil {
+			t.Fatalf("failed to store %s: %v", fileID, err)
+		}
+		return hashes
+	}
+	first := storeFile("file-a", "only in a", "shared by both", "also only in a")
+	second := storeFile("file-b", "shared by both", "only in b")
+	if first[1] != second[0] {
+		t.Fatalf("expected identical chunks to share a hash, got %s and %s", first[1], second[0])
+	}
+
+	shared, err := optimized.enhancedMetadata.LoadChunkMetadata("file-b-0")
+	if err != nil {
+		t.Fatalf("failed to load shared chunk metadata: %v", err)
+	}
+	if !shared.IsDeduplicated || shared.ReferenceCount != 2 {
+		t.Errorf("expected the shared chunk deduplicated with 2 references, got %v with %d", shared.IsDeduplicated, shared.ReferenceCount)
+	}
+
+	stored := func(hash string) bool {
+		_, err := os.Stat(filepath.Join(dir, "optimized_chunks", hash))
+		return err == nil
+	}
+	if err := optimized.DeleteFile("file-a"); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+	if stored(first[0]) || stored(first[2]) {
+		t.Error("expected the chunks only file-a used to be removed")
+	}
+	if _, err := optimized.GetFileMetadata("file-a"); err == nil {
+		t.Error("expected file-a's metadata to be gone")
+	}
+	for _, hash := range second {
+		if !stored(hash) {
+			t.Fatalf("chunk %s of file-b was removed", hash)
+		}
+	}
+	reader, err := optimized.RetrieveChunk(second[0])
+	if err != nil {
+		t.Fatalf("failed to read the shared chunk: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "shared by both" {
+		t.Errorf("expected the shared chunk intact, got %q", data)
+	}
+
+	if err := optimized.DeleteFile("file-b"); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+	for _, hash := range second {
+		if stored(hash) {
+			t.Errorf("expected chunk %s removed with its last reference", hash)
+		}
+	}
+}
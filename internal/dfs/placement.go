@@ -0,0 +1,57 @@
+package dfs
+
+import (
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// filePlacement resolves the placement constraint of a tracked chunk's file,
+// taking the owner from the enhanced catalog when it is available
+func (dfs *DFSCore) filePlacement(chunkID string) (string, metadata.PlacementConstraint) {
+	dfs.replicaMu.RLock()
+	replica, exists := dfs.replicaInfo[chunkID]
+	dfs.replicaMu.RUnlock()
+	if !exists || dfs.metaStore == nil {
+		return "", metadata.PlacementConstraint{}
+	}
+
+	ownerID := ""
+	if dfs.OptimizedStorage != nil {
+		if meta, err := dfs.OptimizedStorage.LoadFileMetadata(replica.FileID); err == nil {
+			ownerID = meta.OwnerID
+		}
+	}
+	constraint, _, err := dfs.metaStore.EffectivePlacement(replica.FileID, ownerID)
+	if err != nil {
+		dfs.logger.Warnf("⚠️ Failed to resolve placement of file %s: %v", replica.FileID, err)
+	}
+	return replica.FileID, constraint
+}
+
+// approvedNodes narrows candidate nodes for new replicas of a chunk to those
+// its file's placement constraint allows. When fewer remain than needed the
+// shortfall is published rather than made up with unapproved nodes.
+func (dfs *DFSCore) approvedNodes(chunkID string, nodes []*p2p.Node, needed int) []*p2p.Node {
+	fileID, constraint := dfs.filePlacement(chunkID)
+	if constraint.IsZero() {
+		return nodes
+	}
+
+	approved := make([]*p2p.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if constraint.Allows(node.ID, node.Labels) {
+			approved = append(approved, node)
+		}
+	}
+	if len(approved) < needed {
+		message := fmt.Sprintf("Only %d approved nodes for %d needed replicas of chunk %s", len(approved), needed, chunkID)
+		dfs.logger.Warnf("⚠️ %s", message)
+		dfs.events.Publish(events.TypeReplication, "dfs", message, map[string]interface{}{
+			"chunk_id": chunkID, "file_id": fileID, "approved": len(approved), "needed": needed, "placement_violation": true,
+		})
+	}
+	return approved
+}
@@ -0,0 +1,90 @@
+package dfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+)
+
+// ReconcileReport is the result of bringing chunks back to their replica target
+type ReconcileReport struct {
+	RanAt           time.Time     `json:"ran_at"`
+	Duration        time.Duration `json:"duration"`
+	OfflineNodes    []string      `json:"offline_nodes"`
+	ReplicasDropped int           `json:"replicas_dropped"` // Replicas on offline nodes no longer counted
+	UnderReplicated int           `json:"under_replicated"` // Chunks below target before copying
+	ReplicasCreated int           `json:"replicas_created"`
+	StillShort      []string      `json:"still_short"` // Chunks left below target, for want of a healthy node or a surviving copy
+}
+
+// ReconcileReplicas stops counting the replicas held by nodes marked failed,
+// then copies every chunk below its replica target from a surviving replica
+// to healthy nodes, most important chunks first. It also runs on its own
+// whenever a node is marked failed.
+func (dfs *DFSCore) ReconcileReplicas() *ReconcileReport {
+	start := time.Now()
+	report := &ReconcileReport{RanAt: start, OfflineNodes: dfs.failedNodes(), StillShort: make([]string, 0)}
+
+	for _, nodeID := range report.OfflineNodes {
+		for _, chunkID := range dfs.findChunksOnNode(nodeID) {
+			if _, err := dfs.dropReplica(chunkID, nodeID); err == nil {
+				report.ReplicasDropped++
+			}
+		}
+	}
+
+	queue := dfs.RepairQueue()
+	report.UnderReplicated = len(queue)
+	dfs.runRepairs(queue)
+
+	dfs.replicaMu.RLock()
+	for _, item := range queue {
+		replica, exists := dfs.replicaInfo[item.ChunkID]
+		if !exists {
+			continue
+		}
+		report.ReplicasCreated += max(len(replica.CurrentReplicas)-item.Surviving, 0)
+		if len(replica.CurrentReplicas) < replica.DesiredReplicas {
+			report.StillShort = append(report.StillShort, item.ChunkID)
+		}
+	}
+	dfs.replicaMu.RUnlock()
+	report.Duration = time.Since(start)
+
+	dfs.logger.Infof("🧩 Replica reconciliation: %d offline nodes, %d chunks under-replicated, %d replicas created, %d still short",
+		len(report.OfflineNodes), report.UnderReplicated, report.ReplicasCreated, len(report.StillShort))
+	if report.UnderReplicated > 0 {
+		dfs.events.Publish(events.TypeReplication, "dfs",
+			fmt.Sprintf("Reconciled %d under-replicated chunks, %d still short", report.UnderReplicated, len(report.StillShort)),
+			map[string]interface{}{"offline_nodes": report.OfflineNodes, "created": report.ReplicasCreated, "still_short": len(report.StillShort)})
+	}
+	return report
+}
+
+// failedNodes returns the nodes whose health is failed, sorted
+func (dfs *DFSCore) failedNodes() []string {
+	dfs.healthMu.RLock()
+	defer dfs.healthMu.RUnlock()
+	nodes := make([]string, 0)
+	for nodeID, health := range dfs.nodeHealth {
+		if health.Status == "failed" {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// copyReplica copies a chunk to a node through the distributor and records
+// the new replica. Without a distributor only the record is made.
+func (dfs *DFSCore) copyReplica(chunkID, nodeID string) error {
+	if dfs.distributor != nil {
+		if err := dfs.distributor.ReplicateChunk(context.Background(), chunkID, nodeID); err != nil {
+			return err
+		}
+	}
+	return dfs.createReplicaOnNode(chunkID, nodeID)
+}
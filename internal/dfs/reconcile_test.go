@@ -0,0 +1,131 @@
+package dfs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestOfflineNodeChunksAreCopiedBackToTarget(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	// Three nodes on an in-memory transport, each with its own chunk storage
+	transport := p2p.NewMemoryTransport()
+	distributors := make(map[string]*distributor.Distributor)
+	networks := make(map[string]*p2p.Network)
+	stores := make(map[string]storage.Storage)
+	for i, name := range []string{"node-a", "node-b", "node-c"} {
+		store, err := storage.NewLocalStorage(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to create storage: %v", err)
+		}
+		network := p2p.NewNetworkWithID(name, name+".test", 7300+i)
+		d := distributor.NewDistributor(network, store, metaStore)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/chunk-transfer", d.HandleChunkTransfer)
+		mux.HandleFunc("/chunk", d.HandleChunkRequest)
+		mux.HandleFunc("/message", d.HandleMessage)
+		transport.Attach(network, mux)
+		distributors[name], networks[name], stores[name] = d, network, store
+	}
+	connect := func(from, to string) {
+		local := networks[to].LocalNode
+		networks[from].RegisterPeer(&p2p.Node{ID: local.ID, Address: local.Address, Port: local.Port, Status: "online",
+			LastSeen: time.Now(), Capabilities: local.Capabilities})
+	}
+	connect("node-a", "node-b")
+	connect("node-b", "node-a")
+
+	// Uploaded while node-a only knows node-b, so every chunk is on node-a and node-b
+	a := distributors["node-a"]
+	a.SetReplicaCount(2)
+	a.SetDurability(distributor.DurabilityAll, 0)
+	var content []byte
+	for i := 0; len(content) < 3*int(chunker.MinChunkSize); i++ {
+		content = append(content, fmt.Sprintf("line %d of the replicated file\n", i)...)
+	}
+	input := filepath.Join(dir, "input.txt")
+	os.WriteFile(input, content, 0644)
+	file, err := a.DistributeFile(input, "pw")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	connect("node-a", "node-c")
+	connect("node-c", "node-a")
+
+	cfg := DefaultDFSConfig()
+	cfg.DefaultReplicaCount = 2
+	core := NewDFSCore(cfg, networks["node-a"], a, stores["node-a"], metaStore)
+	for _, nodeID := range []string{"node-a", "node-b", "node-c"} {
+		core.updateNodeHealth(nodeID, true, 0)
+	}
+	for _, chunkID := range file.Chunks {
+		chunk, _ := a.GetChunkInfo(chunkID)
+		if len(chunk.Nodes) != 2 || countNode(chunk.Nodes, "node-b") != 1 {
+			t.Fatalf("expected chunk %s on node-a and node-b, got %v", chunkID, chunk.Nodes)
+		}
+		core.RegisterChunk(chunkID, file.ID, chunk.Nodes)
+	}
+
+	// node-b disappears; the third missed heartbeat marks it failed and starts reconciliation
+	transport.Detach(networks["node-b"])
+	for i := 0; i < 3; i++ {
+		core.updateNodeHealth("node-b", false, 0)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for _, chunkID := range file.Chunks {
+		for {
+			replicas := currentReplicas(core, chunkID)
+			if countNode(replicas, "node-c") == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("chunk %s was never copied to node-c, replicas %v", chunkID, replicas)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The copies are real: node-c serves intact bytes
+	for _, chunkID := range file.Chunks {
+		replicas := currentReplicas(core, chunkID)
+		if len(replicas) != 2 || countNode(replicas, "node-a") != 1 || countNode(replicas, "node-b") != 0 {
+			t.Errorf("expected chunk %s on node-a and node-c, got %v", chunkID, replicas)
+		}
+		if err := a.VerifyReplica(context.Background(), chunkID, "node-c"); err != nil {
+			t.Errorf("node-c's copy of chunk %s does not verify: %v", chunkID, err)
+		}
+	}
+
+	// On demand, with everything at target, a pass has nothing left to do
+	report := core.ReconcileReplicas()
+	if len(report.OfflineNodes) != 1 || report.OfflineNodes[0] != "node-b" || report.UnderReplicated != 0 || len(report.StillShort) != 0 {
+		t.Fatalf("expected a pass with nothing to copy, got %+v", report)
+	}
+}
+
+// currentReplicas copies a chunk's replica list under the lock repairs write it with
+func currentReplicas(core *DFSCore, chunkID string) []string {
+	core.replicaMu.RLock()
+	defer core.replicaMu.RUnlock()
+	return append([]string(nil), core.replicaInfo[chunkID].CurrentReplicas...)
+}
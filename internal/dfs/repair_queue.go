@@ -0,0 +1,228 @@
+package dfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// Signals that order the repair queue, compared in the configured order
+const (
+	RepairSignalPinned       = "pinned"        // Pinned files first
+	RepairSignalAccess       = "access"        // Most accessed files first
+	RepairSignalStorageClass = "storage_class" // Hot before warm, cold and archive
+	RepairSignalReplicas     = "replicas"      // Fewest surviving replicas first
+)
+
+// DefaultRepairPriority is the signal order used when none is configured
+var DefaultRepairPriority = []string{RepairSignalPinned, RepairSignalAccess, RepairSignalStorageClass, RepairSignalReplicas}
+
+// storageClassRank orders storage classes for repair; files are hot until tiered
+var storageClassRank = map[string]int{"": 0, "hot": 0, "warm": 1, "cold": 2, "archive": 3}
+
+// RepairItem is one under-replicated chunk in the repair queue
+type RepairItem struct {
+	Position     int    `json:"position"` // 1 for the chunk repaired first
+	ChunkID      string `json:"chunk_id"`
+	FileID       string `json:"file_id"`
+	FileName     string `json:"file_name,omitempty"`
+	Surviving    int    `json:"surviving_replicas"`
+	Desired      int    `json:"desired_replicas"`
+	Pinned       bool   `json:"pinned"`
+	AccessCount  int64  `json:"access_count"`
+	StorageClass string `json:"storage_class,omitempty"`
+	Reason       string `json:"reason"` // The signals that placed the chunk, in priority order
+}
+
+// ValidateRepairPriority checks a signal order; each signal may appear once
+func ValidateRepairPriority(signals []string) error {
+	seen := make(map[string]bool, len(signals))
+	for _, signal := range signals {
+		switch signal {
+		case RepairSignalPinned, RepairSignalAccess, RepairSignalStorageClass, RepairSignalReplicas:
+		default:
+			return fmt.Errorf("unknown repair priority signal %q", signal)
+		}
+		if seen[signal] {
+			return fmt.Errorf("repair priority signal %q is listed twice", signal)
+		}
+		seen[signal] = true
+	}
+	return nil
+}
+
+// SetRepairPriority sets the order in which signals rank chunks for repair;
+// an empty list restores DefaultRepairPriority
+func (dfs *DFSCore) SetRepairPriority(signals []string) error {
+	if err := ValidateRepairPriority(signals); err != nil {
+		return err
+	}
+	dfs.replicaMu.Lock()
+	defer dfs.replicaMu.Unlock()
+	dfs.repairPriority = append([]string(nil), signals...)
+	return nil
+}
+
+// RepairPriority returns the signal order the repair queue is ranked by
+func (dfs *DFSCore) RepairPriority() []string {
+	dfs.replicaMu.RLock()
+	defer dfs.replicaMu.RUnlock()
+	if len(dfs.repairPriority) == 0 {
+		return append([]string(nil), DefaultRepairPriority...)
+	}
+	return append([]string(nil), dfs.repairPriority...)
+}
+
+// RepairQueue returns every under-replicated chunk in the order it will be repaired
+func (dfs *DFSCore) RepairQueue() []RepairItem {
+	dfs.replicaMu.RLock()
+	chunkIDs := make([]string, 0)
+	for chunkID, replica := range dfs.replicaInfo {
+		if len(replica.CurrentReplicas) < replica.DesiredReplicas {
+			chunkIDs = append(chunkIDs, chunkID)
+		}
+	}
+	dfs.replicaMu.RUnlock()
+	return dfs.prioritizeRepairs(chunkIDs)
+}
+
+// prioritizeRepairs ranks the under-replicated chunks among chunkIDs by the
+// importance of their files and how close they are to being lost
+func (dfs *DFSCore) prioritizeRepairs(chunkIDs []string) []RepairItem {
+	files := make(map[string]*metadata.EnhancedFileMetadata)
+	items := make([]RepairItem, 0, len(chunkIDs))
+
+	dfs.replicaMu.RLock()
+	for _, chunkID := range chunkIDs {
+		replica, exists := dfs.replicaInfo[chunkID]
+		if !exists || len(replica.CurrentReplicas) >= replica.DesiredReplicas {
+			continue
+		}
+		items = append(items, RepairItem{
+			ChunkID:   chunkID,
+			FileID:    replica.FileID,
+			Surviving: len(replica.CurrentReplicas),
+			Desired:   replica.DesiredReplicas,
+		})
+	}
+	dfs.replicaMu.RUnlock()
+
+	// Importance comes from the enhanced catalog, read without counting as an
+	// access; unknown files rank as unpinned, unread and hot
+	if len(items) > 0 && dfs.OptimizedStorage != nil {
+		catalog, err := dfs.OptimizedStorage.ListFileMetadata()
+		if err != nil {
+			dfs.logger.Warnf("⚠️ Failed to read file importance for repairs: %v", err)
+		}
+		for _, file := range catalog {
+			files[file.FileID] = file
+		}
+	}
+	for i := range items {
+		item := &items[i]
+		if file := files[item.FileID]; file != nil {
+			item.FileName = file.FileName
+			item.Pinned = isPinned(file)
+			item.AccessCount = file.AccessCount
+			item.StorageClass = file.StorageClass
+		}
+	}
+
+	signals := dfs.RepairPriority()
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		for _, signal := range signals {
+			switch signal {
+			case RepairSignalPinned:
+				if a.Pinned != b.Pinned {
+					return a.Pinned
+				}
+			case RepairSignalAccess:
+				if a.AccessCount != b.AccessCount {
+					return a.AccessCount > b.AccessCount
+				}
+			case RepairSignalStorageClass:
+				if ra, rb := classRank(a.StorageClass), classRank(b.StorageClass); ra != rb {
+					return ra < rb
+				}
+			case RepairSignalReplicas:
+				if a.Surviving != b.Surviving {
+					return a.Surviving < b.Surviving
+				}
+			}
+		}
+		if a.FileID != b.FileID {
+			return a.FileID < b.FileID
+		}
+		return a.ChunkID < b.ChunkID
+	})
+
+	for i := range items {
+		items[i].Position = i + 1
+		items[i].Reason = repairReason(items[i], signals)
+	}
+	return items
+}
+
+func classRank(class string) int {
+	if rank, ok := storageClassRank[class]; ok {
+		return rank
+	}
+	return len(storageClassRank)
+}
+
+// repairReason describes an item's standing on each signal, in priority order
+func repairReason(item RepairItem, signals []string) string {
+	parts := make([]string, 0, len(signals))
+	for _, signal := range signals {
+		switch signal {
+		case RepairSignalPinned:
+			if item.Pinned {
+				parts = append(parts, "pinned")
+			} else {
+				parts = append(parts, "not pinned")
+			}
+		case RepairSignalAccess:
+			parts = append(parts, fmt.Sprintf("%d accesses", item.AccessCount))
+		case RepairSignalStorageClass:
+			class := item.StorageClass
+			if class == "" {
+				class = "hot"
+			}
+			parts = append(parts, class)
+		case RepairSignalReplicas:
+			parts = append(parts, fmt.Sprintf("%d of %d replicas left", item.Surviving, item.Desired))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// runRepairs re-replicates chunks one at a time in queue order, so the most
+// important chunks get the healthy nodes' capacity first. Passes do not
+// overlap, and chunks repaired since the queue was built are skipped.
+func (dfs *DFSCore) runRepairs(queue []RepairItem) {
+	dfs.repairMu.Lock()
+	defer dfs.repairMu.Unlock()
+
+	for _, item := range queue {
+		dfs.replicaMu.RLock()
+		replica, exists := dfs.replicaInfo[item.ChunkID]
+		needed := 0
+		if exists {
+			needed = replica.DesiredReplicas - len(replica.CurrentReplicas)
+		}
+		dfs.replicaMu.RUnlock()
+
+		if needed > 0 {
+			dfs.logger.Infof("🩹 Repairing chunk %s (#%d: %s)", item.ChunkID, item.Position, item.Reason)
+			start := time.Now()
+			dfs.createAdditionalReplicas(item.ChunkID, needed)
+			throttle.Global().Yield(context.Background(), throttle.JobRepair, time.Since(start))
+		}
+	}
+}
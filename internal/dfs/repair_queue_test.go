@@ -0,0 +1,73 @@
+package dfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+func TestPinnedNearlyLostFileIsRepairedBeforeWellReplicatedColdFile(t *testing.T) {
+	core, _ := newLifecycleCore(t,
+		&metadata.EnhancedFileMetadata{FileID: "archive", FileName: "archive.tar", FileSize: 100, StorageClass: "cold"},
+		&metadata.EnhancedFileMetadata{FileID: "ledger", FileName: "ledger.db", FileSize: 100, StorageClass: "hot",
+			CustomMetadata: map[string]interface{}{pinnedKey: true}},
+	)
+	core.network = p2p.NewNetwork("127.0.0.1", 0)
+	bus := events.NewBus(0)
+	core.SetEventBus(bus)
+
+	// Registered first, so arbitrary order would be likely to repair the cold file first
+	core.RegisterChunk("archive-0", "archive", []string{"n1", "n2"})
+	core.RegisterChunk("ledger-0", "ledger", []string{"n1"})
+
+	queue := core.RepairQueue()
+	if len(queue) != 2 || queue[0].ChunkID != "ledger-0" || queue[1].ChunkID != "archive-0" {
+		t.Fatalf("expected the pinned chunk queued first, got %+v", queue)
+	}
+	if queue[0].Position != 1 || queue[0].Reason != "pinned, 0 accesses, hot, 1 of 3 replicas left" {
+		t.Errorf("expected the rationale for the pinned chunk, got #%d %q", queue[0].Position, queue[0].Reason)
+	}
+
+	// Endangerment decides between files of equal importance
+	if err := core.SetRepairPriority([]string{RepairSignalReplicas, RepairSignalStorageClass}); err != nil {
+		t.Fatalf("SetRepairPriority failed: %v", err)
+	}
+	core.RegisterChunk("scratch-0", "scratch", []string{})
+	if queue := core.RepairQueue(); queue[0].ChunkID != "scratch-0" {
+		t.Errorf("expected the chunk with no replicas left first, got %+v", queue)
+	}
+	core.UnregisterChunk("scratch-0")
+	if err := core.SetRepairPriority([]string{"size"}); err == nil {
+		t.Errorf("expected an unknown signal rejected")
+	}
+	if err := core.SetRepairPriority(nil); err != nil {
+		t.Fatalf("SetRepairPriority failed: %v", err)
+	}
+
+	// With one healthy node the repairs run one at a time in queue order
+	core.healthMu.Lock()
+	core.nodeHealth[core.network.LocalNode.ID] = &NodeHealth{NodeID: core.network.LocalNode.ID, Status: "healthy"}
+	core.healthMu.Unlock()
+	sub := bus.Subscribe([]string{events.TypeReplication}, 0)
+	defer sub.Close()
+	core.runRepairs(core.RepairQueue())
+
+	var repaired []string
+	timeout := time.After(5 * time.Second)
+	for len(repaired) < 2 {
+		select {
+		case event := <-sub.C:
+			if chunkID, ok := event.Data["chunk_id"].(string); ok && event.Data["created"] == 1 {
+				repaired = append(repaired, chunkID)
+			}
+		case <-timeout:
+			t.Fatalf("expected both chunks repaired, got %v", repaired)
+		}
+	}
+	if repaired[0] != "ledger-0" || repaired[1] != "archive-0" {
+		t.Errorf("expected the pinned, nearly lost chunk repaired first, got %v", repaired)
+	}
+}
@@ -0,0 +1,140 @@
+package dfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// Integrity screen modes
+const (
+	IntegrityScreenCRC    = "crc"    // Screen with the recorded CRC32C, hashing only suspects
+	IntegrityScreenSHA256 = "sha256" // Hash every chunk
+)
+
+// ParseIntegrityScreen reads an integrity screen mode from config, defaulting to CRC
+func ParseIntegrityScreen(mode string) (bool, error) {
+	switch mode {
+	case "", IntegrityScreenCRC:
+		return false, nil
+	case IntegrityScreenSHA256:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown integrity screen %q, expected %q or %q", mode, IntegrityScreenCRC, IntegrityScreenSHA256)
+	}
+}
+
+// ScrubOptions controls a scrub of stored chunks
+type ScrubOptions struct {
+	Full bool `json:"full"` // Verify every chunk with SHA-256 instead of screening by CRC
+}
+
+// ScrubReport is the result of a scrub of stored chunks
+type ScrubReport struct {
+	RanAt         time.Time     `json:"ran_at"`
+	Duration      time.Duration `json:"duration"`
+	Full          bool          `json:"full"`
+	ChunksChecked int           `json:"chunks_checked"`
+	Passed        int           `json:"passed"`   // Cleared by their CRC alone
+	Verified      int           `json:"verified"` // Cleared by SHA-256, after a CRC mismatch, without a CRC, or in a full scrub
+	Corrupt       []string      `json:"corrupt"`
+	Missing       []string      `json:"missing"`
+	Errors        []string      `json:"errors,omitempty"`
+}
+
+// ScrubChunks checks every chunk recorded in metadata against storage. Chunks
+// whose CRC32C still matches pass without hashing; the rest are hashed with
+// SHA-256, which alone decides corruption. A full scrub hashes everything and
+// is the check to use when tampering, not just decay, is a concern.
+func ScrubChunks(metaStore *metadata.MetadataStore, store storage.Storage, opts ScrubOptions) (*ScrubReport, error) {
+	if metaStore == nil || store == nil {
+		return nil, fmt.Errorf("scrub needs both a metadata store and storage")
+	}
+	chunks, err := metaStore.ListChunkMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk metadata: %v", err)
+	}
+
+	start := time.Now()
+	report := &ScrubReport{RanAt: start, Full: opts.Full, Corrupt: make([]string, 0), Missing: make([]string, 0)}
+
+	// Deduplicated chunks have one record per referencing file but one stored copy
+	crcs := make(map[string]uint32, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.Path == "" {
+			continue
+		}
+		if crc, seen := crcs[chunk.Path]; !seen || crc == 0 {
+			crcs[chunk.Path] = chunk.CRC32C
+		}
+	}
+	paths := make([]string, 0, len(crcs))
+	for path := range crcs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Backends that can list their chunks tell missing chunks from unreadable ones
+	var stored map[string]bool
+	if lister, ok := store.(storage.Lister); ok {
+		ids, err := lister.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list storage: %v", err)
+		}
+		stored = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			stored[id] = true
+		}
+	}
+
+	for _, path := range paths {
+		report.ChunksChecked++
+		if stored != nil && !stored[path] {
+			report.Missing = append(report.Missing, path)
+			continue
+		}
+		checkStart := time.Now()
+		result, err := storage.ScreenChunk(store, path, crcs[path], opts.Full)
+		switch {
+		case errors.Is(err, storage.ErrChunkCorrupt):
+			report.Corrupt = append(report.Corrupt, path)
+		case err != nil:
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", path, err))
+		case result == storage.ScreenPassed:
+			report.Passed++
+		default:
+			report.Verified++
+		}
+		// Reading and hashing chunks competes with foreground transfers for disk
+		throttle.Global().Yield(context.Background(), throttle.JobScrub, time.Since(checkStart))
+	}
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// ScrubChunks scrubs the core's stored chunks, publishing any corruption found
+func (dfs *DFSCore) ScrubChunks(opts ScrubOptions) (*ScrubReport, error) {
+	report, err := ScrubChunks(dfs.metaStore, dfs.storage, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dfs.logger.Infof("🔬 Scrub: %d chunks, %d passed by CRC, %d hashed, %d corrupt, %d missing",
+		report.ChunksChecked, report.Passed, report.Verified, len(report.Corrupt), len(report.Missing))
+	if len(report.Corrupt) > 0 || len(report.Missing) > 0 {
+		dfs.events.Publish(events.TypeScrub, "dfs", "Scrub found damaged chunks", map[string]interface{}{
+			"corrupt": len(report.Corrupt),
+			"missing": len(report.Missing),
+			"full":    opts.Full,
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,76 @@
+package dfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestScrubChunksScreensByCRCAndHashesSuspects(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	seed := func(index int, data []byte, withCRC bool) string {
+		t.Helper()
+		path, err := store.Put(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("failed to store chunk: %v", err)
+		}
+		chunk := metadata.ChunkMetadata{Hash: path, Path: path, FileID: "file-1", Index: index, Size: int64(len(data))}
+		if withCRC {
+			chunk.CRC32C = storage.ChunkCRC(data)
+		}
+		if err := metaStore.PutChunkMetadata(chunk); err != nil {
+			t.Fatalf("failed to seed chunk metadata: %v", err)
+		}
+		return path
+	}
+	seed(0, []byte("intact chunk"), true)
+	seed(1, []byte("chunk stored before CRCs were recorded"), false)
+	rotted := seed(2, []byte("chunk that will rot on disk"), true)
+	lost := seed(3, []byte("chunk that will go missing"), true)
+
+	diskPath, _ := store.GetPath(rotted)
+	data, _ := os.ReadFile(diskPath)
+	data[0] ^= 0x01
+	if err := os.WriteFile(diskPath, data, 0644); err != nil {
+		t.Fatalf("failed to corrupt chunk: %v", err)
+	}
+	lostPath, _ := store.GetPath(lost)
+	os.Remove(lostPath)
+
+	report, err := ScrubChunks(metaStore, store, ScrubOptions{})
+	if err != nil {
+		t.Fatalf("scrub failed: %v", err)
+	}
+	if report.ChunksChecked != 4 || report.Passed != 1 || report.Verified != 1 {
+		t.Errorf("expected 1 chunk passed by CRC and 1 hashed of 4, got %+v", report)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != rotted {
+		t.Errorf("expected the rotted chunk reported corrupt, got %v", report.Corrupt)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != lost {
+		t.Errorf("expected the removed chunk reported missing, got %v", report.Missing)
+	}
+
+	// A full scrub hashes every chunk it can read
+	report, err = ScrubChunks(metaStore, store, ScrubOptions{Full: true})
+	if err != nil {
+		t.Fatalf("full scrub failed: %v", err)
+	}
+	if report.Passed != 0 || report.Verified != 2 || len(report.Corrupt) != 1 {
+		t.Errorf("expected a full scrub to hash both intact chunks, got %+v", report)
+	}
+}
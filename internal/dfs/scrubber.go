@@ -0,0 +1,176 @@
+package dfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// ScrubberStats counts what the background scrubber has checked since the core started
+type ScrubberStats struct {
+	Running       bool          `json:"running"`
+	Interval      time.Duration `json:"interval"`
+	Passes        int           `json:"passes"`
+	LastPass      time.Time     `json:"last_pass"`
+	ChunksChecked int           `json:"chunks_checked"`
+	Damaged       int           `json:"damaged"`  // Local copies found corrupt or missing
+	Repaired      int           `json:"repaired"` // Damaged copies replaced from a healthy replica
+	Failed        int           `json:"failed"`   // Damaged copies no replica could replace, and chunks that could not be checked
+}
+
+// scrubberState is the running scrubber and its counters, guarded by scrubMu
+type scrubberState struct {
+	stop  context.CancelFunc
+	done  chan struct{}
+	stats ScrubberStats
+}
+
+// StartScrubber verifies every registered chunk with a copy on this node
+// once per interval, replacing corrupt or missing copies from a healthy
+// replica on another node. Remote copies are left to their own node's
+// scrubber. Reads yield to foreground transfers so the scrubber does not
+// saturate the disk. Starting a running scrubber changes its interval.
+func (dfs *DFSCore) StartScrubber(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("scrub interval must be positive, got %v", interval)
+	}
+	if dfs.distributor == nil {
+		return fmt.Errorf("the scrubber needs a distributor to verify and repair chunks")
+	}
+	dfs.StopScrubber()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	dfs.scrubMu.Lock()
+	dfs.scrubber.stop, dfs.scrubber.done = cancel, done
+	dfs.scrubber.stats.Running, dfs.scrubber.stats.Interval = true, interval
+	dfs.scrubMu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				dfs.scrubPass(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	dfs.logger.Infof("🧽 Scrubber started, verifying local chunks every %v", interval)
+	return nil
+}
+
+// StopScrubber stops the background scrubber, waiting for a pass in progress
+// to notice; the counters are kept
+func (dfs *DFSCore) StopScrubber() {
+	dfs.scrubMu.Lock()
+	stop, done := dfs.scrubber.stop, dfs.scrubber.done
+	dfs.scrubber.stop, dfs.scrubber.done = nil, nil
+	dfs.scrubber.stats.Running = false
+	dfs.scrubMu.Unlock()
+	if stop == nil {
+		return
+	}
+	stop()
+	<-done
+}
+
+// ScrubberStats returns the scrubber's counters
+func (dfs *DFSCore) ScrubberStats() ScrubberStats {
+	dfs.scrubMu.Lock()
+	defer dfs.scrubMu.Unlock()
+	return dfs.scrubber.stats
+}
+
+// scrubPass verifies this node's copy of every registered chunk it holds
+func (dfs *DFSCore) scrubPass(ctx context.Context) {
+	localID := dfs.network.LocalNode.ID
+	chunkIDs := dfs.findChunksOnNode(localID)
+	sort.Strings(chunkIDs)
+
+	checked, damaged, repaired, failed := 0, 0, 0, 0
+	for _, chunkID := range chunkIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		switch dfs.scrubChunk(ctx, chunkID, localID) {
+		case scrubRepaired:
+			damaged++
+			repaired++
+		case scrubUnrepaired:
+			damaged++
+			failed++
+		case scrubUnchecked:
+			failed++
+		}
+		checked++
+		// Reading and hashing chunks competes with foreground transfers for disk
+		throttle.Global().Yield(ctx, throttle.JobScrub, time.Since(start))
+	}
+
+	dfs.scrubMu.Lock()
+	stats := &dfs.scrubber.stats
+	stats.Passes++
+	stats.LastPass = time.Now()
+	stats.ChunksChecked += checked
+	stats.Damaged += damaged
+	stats.Repaired += repaired
+	stats.Failed += failed
+	dfs.scrubMu.Unlock()
+
+	dfs.logger.Infof("🧽 Scrubber pass: %d chunks checked, %d damaged, %d repaired, %d failed", checked, damaged, repaired, failed)
+}
+
+// Outcomes of scrubbing one chunk
+const (
+	scrubIntact = iota
+	scrubRepaired
+	scrubUnrepaired
+	scrubUnchecked
+)
+
+// scrubChunk verifies one local chunk copy and repairs it when damaged
+func (dfs *DFSCore) scrubChunk(ctx context.Context, chunkID, localID string) int {
+	chunk, err := dfs.distributor.GetChunkInfo(chunkID)
+	if err != nil || chunk.StoredHash == "" {
+		dfs.logger.Debugf("Scrubber cannot check chunk %s: no stored hash recorded", chunkID)
+		return scrubUnchecked
+	}
+	err = dfs.distributor.VerifyReplica(ctx, chunkID, localID)
+	if err == nil {
+		dfs.setReplicaHealth(chunkID, localID, "healthy")
+		return scrubIntact
+	}
+	dfs.logger.Warnf("❌ Local copy of chunk %s is damaged: %v", chunkID, err)
+	dfs.setReplicaHealth(chunkID, localID, "corrupted")
+	source, err := dfs.distributor.RepairChunk(ctx, chunkID)
+	if err != nil {
+		dfs.logger.Errorf("❌ Failed to repair chunk %s: %v", chunkID, err)
+		dfs.events.Publish(events.TypeScrub, "dfs", fmt.Sprintf("Scrubber could not repair chunk %s", chunkID),
+			map[string]interface{}{"chunk_id": chunkID, "node_id": localID, "error": err.Error()})
+		return scrubUnrepaired
+	}
+	dfs.setReplicaHealth(chunkID, localID, "healthy")
+	dfs.logger.Infof("🔧 Repaired chunk %s from node %s", chunkID, source)
+	dfs.events.Publish(events.TypeScrub, "dfs", fmt.Sprintf("Scrubber repaired chunk %s from node %s", chunkID, source),
+		map[string]interface{}{"chunk_id": chunkID, "node_id": localID, "source": source})
+	return scrubRepaired
+}
+
+// setReplicaHealth records the health of one node's replica of a chunk
+func (dfs *DFSCore) setReplicaHealth(chunkID, nodeID, status string) {
+	dfs.replicaMu.Lock()
+	defer dfs.replicaMu.Unlock()
+	if replica, exists := dfs.replicaInfo[chunkID]; exists {
+		replica.Health[nodeID] = status
+		replica.LastVerified = time.Now()
+	}
+}
@@ -0,0 +1,128 @@
+package dfs
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestScrubberRepairsACorruptChunkFromAnotherReplica(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	// Two nodes on an in-memory transport, each with its own chunk storage
+	transport := p2p.NewMemoryTransport()
+	newNode := func(name string, port int) (*distributor.Distributor, *p2p.Network, storage.Storage) {
+		store, err := storage.NewLocalStorage(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to create storage: %v", err)
+		}
+		network := p2p.NewNetworkWithID(name, name+".test", port)
+		d := distributor.NewDistributor(network, store, metaStore)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/chunk-transfer", d.HandleChunkTransfer)
+		mux.HandleFunc("/chunk", d.HandleChunkRequest)
+		mux.HandleFunc("/message", d.HandleMessage)
+		transport.Attach(network, mux)
+		return d, network, store
+	}
+	a, networkA, storeA := newNode("node-a", 7200)
+	_, networkB, _ := newNode("node-b", 7201)
+	for _, pair := range [][2]*p2p.Network{{networkA, networkB}, {networkB, networkA}} {
+		local := pair[1].LocalNode
+		pair[0].RegisterPeer(&p2p.Node{ID: local.ID, Address: local.Address, Port: local.Port, Status: "online",
+			Capabilities: local.Capabilities})
+	}
+
+	a.SetReplicaCount(2)
+	a.SetDurability(distributor.DurabilityAll, 0)
+	input := filepath.Join(dir, "input.txt")
+	var content []byte
+	for i := 0; len(content) < 3*int(chunker.MinChunkSize); i++ {
+		content = append(content, fmt.Sprintf("line %d of the scrubbed file\n", i)...)
+	}
+	os.WriteFile(input, content, 0644)
+	file, err := a.DistributeFile(input, "pw")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	core := NewDFSCore(nil, networkA, a, storeA, metaStore)
+	var corrupt *distributor.ChunkInfo
+	for _, chunkID := range file.Chunks {
+		chunk, err := a.GetChunkInfo(chunkID)
+		if err != nil {
+			t.Fatalf("missing chunk record: %v", err)
+		}
+		if len(chunk.Nodes) != 2 {
+			t.Fatalf("expected chunk %s on both nodes, got %v", chunkID, chunk.Nodes)
+		}
+		core.RegisterChunk(chunkID, file.ID, chunk.Nodes)
+		if corrupt == nil {
+			corrupt = chunk
+		}
+	}
+
+	// Flip a byte of node-a's copy of one chunk; node-b still holds a good one
+	path, _ := storeA.GetPath(corrupt.StoredHash)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read chunk: %v", err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to corrupt chunk: %v", err)
+	}
+
+	if err := core.StartScrubber(0); err == nil {
+		t.Fatal("expected a zero interval to be rejected")
+	}
+	if err := core.StartScrubber(10 * time.Millisecond); err != nil {
+		t.Fatalf("StartScrubber failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for core.ScrubberStats().Passes == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the scrubber never finished a pass")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	core.StopScrubber()
+
+	stats := core.ScrubberStats()
+	if stats.Running || stats.Damaged != 1 || stats.Repaired != 1 || stats.Failed != 0 || stats.ChunksChecked < len(file.Chunks) {
+		t.Fatalf("expected one chunk repaired in the first pass, got %+v", stats)
+	}
+	if err := storage.VerifyChunk(storeA, corrupt.StoredHash); err != nil {
+		t.Fatalf("node-a's copy is still damaged: %v", err)
+	}
+	if health := core.GetReplicaInfo(corrupt.ID).Health["node-a"]; health != "healthy" {
+		t.Errorf("expected node-a's replica healthy again, got %q", health)
+	}
+	system := core.GetSystemStats()
+	if system["scrub_repaired"] != 1 || system["scrub_failed"] != 0 || system["scrub_chunks_checked"] != stats.ChunksChecked {
+		t.Errorf("expected scrub stats in the system stats, got %v", system)
+	}
+
+	output := filepath.Join(dir, "out.txt")
+	if err := a.ReassembleFile(file.ID, output, "pw"); err != nil {
+		t.Fatalf("reassembly after the repair failed: %v", err)
+	}
+}
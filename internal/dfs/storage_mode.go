@@ -0,0 +1,48 @@
+package dfs
+
+import (
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// ApplyStorageMode puts a store in the configured storage mode and records
+// the mode in metadata, returning the mode in effect. A store recorded as CAS
+// stays CAS: its chunks were written on the promise they never change, so a
+// mutable configuration is reported as an error and CAS kept. In CAS mode a
+// chunk is only deleted once no file or version references it.
+func ApplyStorageMode(store storage.Storage, metaStore *metadata.MetadataStore, mode string) (string, error) {
+	if err := storage.ValidateMode(mode); err != nil {
+		return "", err
+	}
+	if mode == "" {
+		mode = storage.ModeMutable
+	}
+	recorded, err := metaStore.StorageMode()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the recorded storage mode: %v", err)
+	}
+
+	var downgrade error
+	if recorded == storage.ModeCAS && mode != storage.ModeCAS {
+		downgrade = fmt.Errorf("chunks were stored in %s mode and stay immutable; storage mode %s ignored", storage.ModeCAS, mode)
+		mode = storage.ModeCAS
+	}
+
+	cas, ok := store.(storage.ContentAddressable)
+	if !ok {
+		if mode == storage.ModeCAS {
+			return "", fmt.Errorf("storage backend %T does not support %s mode", store, storage.ModeCAS)
+		}
+	} else {
+		cas.SetContentAddressed(mode == storage.ModeCAS, metaStore.ChunkReferenced)
+	}
+
+	if recorded != mode {
+		if err := metaStore.PutStorageMode(mode); err != nil {
+			return mode, fmt.Errorf("failed to record storage mode %s: %v", mode, err)
+		}
+	}
+	return mode, downgrade
+}
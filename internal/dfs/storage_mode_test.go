@@ -0,0 +1,59 @@
+package dfs
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestCASModeIsRecordedAndOnlyReleasesUnreferencedChunks(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	if mode, err := ApplyStorageMode(store, metaStore, storage.ModeCAS); err != nil || mode != storage.ModeCAS {
+		t.Fatalf("expected CAS mode applied, got %q (%v)", mode, err)
+	}
+	if recorded, _ := metaStore.StorageMode(); recorded != storage.ModeCAS {
+		t.Errorf("expected CAS mode recorded, got %q", recorded)
+	}
+
+	path, err := store.Put(bytes.NewReader([]byte("shared chunk")))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := metaStore.PutChunkMetadata(metadata.ChunkMetadata{Hash: "h1", Path: path, FileID: "file-1", RefCount: 1}); err != nil {
+		t.Fatalf("failed to record chunk: %v", err)
+	}
+	if err := store.Delete(path); !errors.Is(err, storage.ErrImmutableChunk) {
+		t.Fatalf("expected a referenced chunk kept, got %v", err)
+	}
+
+	// A store recorded as CAS is not switched back to mutable
+	mode, err := ApplyStorageMode(store, metaStore, storage.ModeMutable)
+	if err == nil || mode != storage.ModeCAS {
+		t.Fatalf("expected the recorded CAS mode kept, got %q (%v)", mode, err)
+	}
+	if err := store.Delete(path); !errors.Is(err, storage.ErrImmutableChunk) {
+		t.Fatalf("expected the store to stay immutable, got %v", err)
+	}
+
+	// The last reference going away releases the chunk
+	if err := metaStore.DeleteChunkMetadata("h1"); err != nil {
+		t.Fatalf("failed to drop chunk record: %v", err)
+	}
+	if err := store.Delete(path); err != nil {
+		t.Errorf("expected the unreferenced chunk deleted: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package dfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/api"
+	"github.com/jaywantadh/DisktroByte/internal/cache"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/distributor"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/streaming"
+)
+
+func TestStrictEncryptionWritesNoPlaintext(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize, StrictEncryption: true}
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	ctx := context.Background()
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	d := distributor.NewDistributor(network, store, metaStore)
+	original := make([]byte, 3*chunker.MinChunkSize+100)
+	rand.New(rand.NewSource(7)).Read(original)
+	size := int64(len(original))
+
+	// Uploads are chunked straight from memory
+	file, err := d.DistributeSourceWithOptions(ctx, chunker.ReaderSource("secret.bin", bytes.NewReader(original), size), distributor.UploadOptions{Password: "pw"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	updated := append([]byte(nil), original...)
+	rand.New(rand.NewSource(8)).Read(updated[:chunker.MinChunkSize])
+	if _, err := chunker.UpdateFileSource(file.ID, chunker.ReaderSource("secret.bin", bytes.NewReader(updated), size), "pw", "edit", metaStore, store); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	if _, err := cache.NewPassthrough(filepath.Join(dir, "plain-cache"), cache.ModePlaintext); !errors.Is(err, encryptor.ErrPlaintextRefused) {
+		t.Errorf("expected a plaintext cache to be refused, got %v", err)
+	}
+	sealed, err := cache.NewPassthrough(filepath.Join(dir, "cache"), cache.ModeEncrypted)
+	if err != nil {
+		t.Fatalf("failed to create encrypted cache: %v", err)
+	}
+	if err := sealed.StoreReader(file.ID, "secret.bin", bytes.NewReader(updated), size, "pw"); err != nil {
+		t.Fatalf("failed to cache original: %v", err)
+	}
+
+	// Downloads stream from chunks, for the current and earlier versions
+	download := func(read func(w io.Writer) (int64, error)) []byte {
+		rec := httptest.NewRecorder()
+		if _, err := api.StreamReassembled(rec, "secret.bin", size, read); err != nil {
+			t.Fatalf("download failed: %v", err)
+		}
+		return rec.Body.Bytes()
+	}
+	current := download(func(w io.Writer) (int64, error) {
+		return chunker.ReadRangeContext(ctx, file.ID, 0, size, "pw", metaStore, store, w)
+	})
+	if !bytes.Equal(current, updated) {
+		t.Errorf("expected the streamed download to match the current content")
+	}
+	first := download(func(w io.Writer) (int64, error) {
+		return chunker.ReadVersionContext(ctx, file.ID, 1, "pw", metaStore, store, w)
+	})
+	if !bytes.Equal(first, original) {
+		t.Errorf("expected the streamed version 1 to match the original content")
+	}
+
+	// Every path that would put plaintext on disk refuses
+	out := filepath.Join(dir, "out")
+	sealedPath := filepath.Join(dir, "sealed.bin")
+	ciphertext, _ := encryptor.NewEncryptor().Encrypt(original, "pw")
+	os.WriteFile(sealedPath, ciphertext, 0600)
+
+	transcodeConfig := DefaultTranscoderConfig()
+	transcodeConfig.Enabled = true
+	transcodeConfig.WorkDir = filepath.Join(dir, "work")
+	transcodeConfig.Rules = []TranscodeRule{{MimeTypes: []string{"application/*"}, Command: []string{"cp", "{input}", "{output}"}, TargetFormat: "bin"}}
+	transcoder, err := NewTranscoder(transcodeConfig, func(string, TranscodeSource) (string, error) { return "", nil }, nil)
+	if err != nil {
+		t.Fatalf("failed to create transcoder: %v", err)
+	}
+	fr := NewFileReassembler(NewDFSCore(nil, network, d, store, metaStore), d, store, metaStore, network)
+
+	refusals := map[string]error{}
+	_, refusals["download scratch"] = api.ServeReassembled(httptest.NewRecorder(), filepath.Join(dir, "scratch"), "secret.bin", func(outputPath string) error {
+		return chunker.ReassembleFile(file.ID, outputPath, "pw", metaStore, store)
+	})
+	refusals["reassembly"] = chunker.ReassembleFile(file.ID, filepath.Join(out, "secret.bin"), "pw", metaStore, store)
+	refusals["version reassembly"] = chunker.ReassembleVersion(file.ID, 1, filepath.Join(out, "v1.bin"), "pw", metaStore, store)
+	_, refusals["reassembly job"] = fr.ReassembleFile(file.ID, filepath.Join(out, "job.bin"), "pw")
+	refusals["decrypt"] = encryptor.DecryptFile(encryptor.NewEncryptor(), sealedPath, filepath.Join(out, "decrypted.bin"), "pw")
+	_, refusals["transcode"] = transcoder.Submit(TranscodeSource{FileID: file.ID, MimeType: "application/octet-stream", Path: sealedPath})
+	refusals["stream"] = streaming.NewStreamProcessor(64*1024, 1).WriteStream("session", filepath.Join(out, "stream.bin"), nil)
+	for what, err := range refusals {
+		if !errors.Is(err, encryptor.ErrPlaintextRefused) {
+			t.Errorf("expected %s to be refused, got %v", what, err)
+		}
+	}
+
+	// Nothing written anywhere holds a recognisable piece of either version
+	probes := [][]byte{original[:64], original[size/2 : size/2+64], updated[:64]}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, probe := range probes {
+			if bytes.Contains(data, probe) {
+				t.Errorf("%s holds plaintext", path)
+				break
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,189 @@
+package dfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
+)
+
+// ErrTieringNotConfigured is returned by a tiering pass before SetTiering
+var ErrTieringNotConfigured = errors.New("access-based tiering needs tiered storage")
+
+// TieringPolicy sets when files move between the hot, warm and cold classes
+type TieringPolicy struct {
+	WarmAfter time.Duration // Time without access before a hot file is demoted to warm, 0 skips warm
+	ColdAfter time.Duration // Time without access before a file is demoted to cold, 0 never demotes to cold
+	Interval  time.Duration // Time between background passes, 0 disables them
+}
+
+// TieringTransition is a file a tiering pass moved to another storage class
+type TieringTransition struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Chunks   int    `json:"chunks"`
+	Error    string `json:"error,omitempty"` // Set when the move failed and the file kept its class
+}
+
+// TieringReport is the result of a tiering pass
+type TieringReport struct {
+	ScannedAt    time.Time           `json:"scanned_at"`
+	Duration     time.Duration       `json:"duration"`
+	FilesScanned int                 `json:"files_scanned"`
+	Promoted     int                 `json:"promoted"`
+	Demoted      int                 `json:"demoted"`
+	Transitions  []TieringTransition `json:"transitions"`
+}
+
+// tierRank orders the classes a tiering pass manages; archive is left to
+// lifecycle rules and manual moves
+var tierRank = map[string]int{
+	storage.ClassHot:  0,
+	storage.ClassWarm: 1,
+	storage.ClassCold: 2,
+}
+
+// SetTiering enables access-based tiering. chunkPaths returns the storage IDs
+// of a file's chunks, which are moved to the tier serving the file's new class.
+// With a non-zero interval passes also run in the background until Close.
+func (os *OptimizedStorage) SetTiering(policy TieringPolicy, tiers *storage.TieredStorage, chunkPaths func(fileID string) []string) {
+	os.tieringMu.Lock()
+	started := os.tiers != nil
+	os.tieringPolicy = policy
+	os.tiers = tiers
+	os.chunkPaths = chunkPaths
+	os.tieringMu.Unlock()
+
+	if !started && tiers != nil && policy.Interval > 0 {
+		os.wg.Add(1)
+		go os.backgroundTiering(policy.Interval)
+	}
+}
+
+// RunTieringPass demotes files left unaccessed past the policy thresholds to
+// a colder class and promotes files accessed since back to hot, moving their
+// chunks to the matching tier. Pinned and deleted files are left alone.
+func (os *OptimizedStorage) RunTieringPass() (*TieringReport, error) {
+	return os.runTieringPass(time.Now())
+}
+
+func (os *OptimizedStorage) runTieringPass(now time.Time) (*TieringReport, error) {
+	os.tieringMu.Lock()
+	defer os.tieringMu.Unlock()
+	if os.tiers == nil {
+		return nil, ErrTieringNotConfigured
+	}
+
+	start := time.Now()
+	files, err := os.enhancedMetadata.ListFileMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FileID < files[j].FileID })
+	report := &TieringReport{
+		ScannedAt:    now,
+		FilesScanned: len(files),
+		Transitions:  make([]TieringTransition, 0),
+	}
+
+	for _, file := range files {
+		if file.IsDeleted || isPinned(file) {
+			continue
+		}
+		from := file.StorageClass
+		if from == "" {
+			from = storage.ClassHot
+		}
+		fromRank, managed := tierRank[from]
+		if !managed {
+			continue
+		}
+		to := os.tieringPolicy.classFor(file, now)
+		if to == from {
+			continue
+		}
+
+		stepStart := time.Now()
+		transition := os.moveFile(file, from, to)
+		throttle.Global().Yield(context.Background(), throttle.JobOptimization, time.Since(stepStart))
+		report.Transitions = append(report.Transitions, transition)
+		if transition.Error != "" {
+			continue
+		}
+		if tierRank[to] < fromRank {
+			report.Promoted++
+		} else {
+			report.Demoted++
+		}
+	}
+
+	report.Duration = time.Since(start)
+	if report.Promoted+report.Demoted > 0 {
+		os.logger.Infof("🌡️ Tiering pass: promoted %d and demoted %d of %d files", report.Promoted, report.Demoted, len(files))
+	}
+	return report, nil
+}
+
+// moveFile migrates a file's chunks to the tier serving class to and records
+// the new class once every chunk is there
+func (os *OptimizedStorage) moveFile(file *metadata.EnhancedFileMetadata, from, to string) TieringTransition {
+	transition := TieringTransition{FileID: file.FileID, FileName: file.FileName, From: from, To: to}
+	var paths []string
+	if os.chunkPaths != nil {
+		paths = os.chunkPaths(file.FileID)
+	}
+	transition.Chunks = len(paths)
+	for _, path := range paths {
+		if err := os.tiers.MigrateToClass(path, to); err != nil {
+			transition.Error = fmt.Sprintf("failed to move chunk %s: %v", path, err)
+			return transition
+		}
+	}
+	file.StorageClass = to
+	if err := os.enhancedMetadata.StoreFileMetadata(file); err != nil {
+		transition.Error = fmt.Sprintf("failed to record storage class: %v", err)
+	}
+	return transition
+}
+
+// classFor returns the class a file belongs in after its time without access
+func (p TieringPolicy) classFor(file *metadata.EnhancedFileMetadata, now time.Time) string {
+	lastUsed := file.AccessedAt
+	if lastUsed.IsZero() {
+		lastUsed = file.CreatedAt
+	}
+	idle := now.Sub(lastUsed)
+	switch {
+	case p.ColdAfter > 0 && idle >= p.ColdAfter:
+		return storage.ClassCold
+	case p.WarmAfter > 0 && idle >= p.WarmAfter:
+		return storage.ClassWarm
+	}
+	return storage.ClassHot
+}
+
+// backgroundTiering runs tiering passes until the storage is closed
+func (os *OptimizedStorage) backgroundTiering(interval time.Duration) {
+	defer os.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := os.RunTieringPass(); err != nil {
+				os.logger.Warnf("⚠️ Tiering pass failed: %v", err)
+			}
+		case <-os.stopChan:
+			return
+		}
+	}
+}
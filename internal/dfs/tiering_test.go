@@ -0,0 +1,212 @@
+package dfs
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/storage/s3fake"
+)
+
+func TestTieringPassMovesFilesByAccess(t *testing.T) {
+	dir := t.TempDir()
+	optimized, err := NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to create optimized storage: %v", err)
+	}
+	t.Cleanup(func() { optimized.Close() })
+
+	if _, err := optimized.RunTieringPass(); !errors.Is(err, ErrTieringNotConfigured) {
+		t.Fatalf("expected a pass before SetTiering to fail, got %v", err)
+	}
+
+	hot, err := storage.NewLocalStorage(filepath.Join(dir, "hot"))
+	if err != nil {
+		t.Fatalf("failed to create hot tier: %v", err)
+	}
+	cold, err := storage.NewLocalStorage(filepath.Join(dir, "cold"))
+	if err != nil {
+		t.Fatalf("failed to create cold tier: %v", err)
+	}
+	tiered, err := storage.NewTieredStorage(
+		storage.Tier{Name: "ssd", Backend: hot, Classes: []string{storage.ClassHot, storage.ClassWarm}},
+		storage.Tier{Name: "archive-disk", Backend: cold, Classes: []string{storage.ClassCold, storage.ClassArchive}},
+	)
+	if err != nil {
+		t.Fatalf("failed to create tiered storage: %v", err)
+	}
+
+	now := time.Now()
+	day := 24 * time.Hour
+	chunks := make(map[string][]string)
+	addFile := func(file *metadata.EnhancedFileMetadata, backend storage.Storage, count int) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			id, err := backend.Put(strings.NewReader(file.FileID + string(rune('a'+i))))
+			if err != nil {
+				t.Fatalf("failed to store chunk of %s: %v", file.FileID, err)
+			}
+			chunks[file.FileID] = append(chunks[file.FileID], id)
+		}
+		file.FileName = file.FileID + ".bin"
+		if file.CreatedAt.IsZero() {
+			file.CreatedAt = now.Add(-60 * day)
+		}
+		if err := optimized.StoreFileMetadata(file); err != nil {
+			t.Fatalf("failed to store %s: %v", file.FileID, err)
+		}
+	}
+	addFile(&metadata.EnhancedFileMetadata{FileID: "fresh", StorageClass: storage.ClassHot, AccessedAt: now.Add(-day)}, hot, 2)
+	addFile(&metadata.EnhancedFileMetadata{FileID: "idle", StorageClass: storage.ClassHot, AccessedAt: now.Add(-10 * day)}, hot, 2)
+	addFile(&metadata.EnhancedFileMetadata{FileID: "stale", AccessedAt: now.Add(-40 * day)}, hot, 3)
+	addFile(&metadata.EnhancedFileMetadata{FileID: "revived", StorageClass: storage.ClassCold, AccessedAt: now.Add(-time.Hour), AccessCount: 12}, cold, 2)
+	addFile(&metadata.EnhancedFileMetadata{FileID: "pinned", StorageClass: storage.ClassHot, AccessedAt: now.Add(-40 * day),
+		CustomMetadata: map[string]interface{}{pinnedKey: true}}, hot, 1)
+	addFile(&metadata.EnhancedFileMetadata{FileID: "archived", StorageClass: storage.ClassArchive}, cold, 1)
+	addFile(&metadata.EnhancedFileMetadata{FileID: "never-read", StorageClass: storage.ClassHot, CreatedAt: now.Add(-45 * day)}, hot, 1)
+	addFile(&metadata.EnhancedFileMetadata{FileID: "broken", StorageClass: storage.ClassHot, AccessedAt: now.Add(-40 * day)}, hot, 0)
+	chunks["broken"] = []string{strings.Repeat("0", 64)}
+
+	optimized.SetTiering(TieringPolicy{WarmAfter: 7 * day, ColdAfter: 30 * day}, tiered, func(fileID string) []string {
+		return chunks[fileID]
+	})
+	report, err := optimized.runTieringPass(now)
+	if err != nil {
+		t.Fatalf("RunTieringPass failed: %v", err)
+	}
+	if report.FilesScanned != 8 || report.Promoted != 1 || report.Demoted != 3 {
+		t.Errorf("expected 1 promotion and 3 demotions of 8 files, got %+v", report)
+	}
+
+	want := map[string]struct{ class, tier string }{
+		"fresh":      {storage.ClassHot, "ssd"},
+		"idle":       {storage.ClassWarm, "ssd"},
+		"stale":      {storage.ClassCold, "archive-disk"},
+		"revived":    {storage.ClassHot, "ssd"},
+		"pinned":     {storage.ClassHot, "ssd"},
+		"archived":   {storage.ClassArchive, "archive-disk"},
+		"never-read": {storage.ClassCold, "archive-disk"},
+		"broken":     {storage.ClassHot, ""},
+	}
+	files, err := optimized.ListFileMetadata()
+	if err != nil {
+		t.Fatalf("ListFileMetadata failed: %v", err)
+	}
+	for _, file := range files {
+		expected := want[file.FileID]
+		if file.StorageClass != expected.class {
+			t.Errorf("expected %s to be %s, got %q", file.FileID, expected.class, file.StorageClass)
+		}
+		if expected.tier == "" {
+			continue
+		}
+		for _, id := range chunks[file.FileID] {
+			if tier, err := tiered.Locate(id); err != nil || tier != expected.tier {
+				t.Errorf("expected chunk %s of %s on tier %s, got %q, %v", id, file.FileID, expected.tier, tier, err)
+			}
+		}
+	}
+	if used, _ := hot.Usage(); used == 0 {
+		t.Errorf("expected the hot tier to keep the hot files' chunks")
+	}
+
+	var failed bool
+	for _, transition := range report.Transitions {
+		if transition.FileID == "broken" {
+			failed = transition.Error != ""
+		}
+	}
+	if !failed {
+		t.Errorf("expected the file with a missing chunk reported as failed, got %+v", report.Transitions)
+	}
+
+	// Nothing is left to move on a second pass
+	again, err := optimized.runTieringPass(now)
+	if err != nil {
+		t.Fatalf("second RunTieringPass failed: %v", err)
+	}
+	if again.Promoted != 0 || again.Demoted != 0 || len(again.Transitions) != 1 {
+		t.Errorf("expected only the broken file retried, got %+v", again)
+	}
+}
+
+func TestTieringPassRelocatesChunksBetweenLocalAndS3(t *testing.T) {
+	dir := t.TempDir()
+	optimized, err := NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to create optimized storage: %v", err)
+	}
+	t.Cleanup(func() { optimized.Close() })
+
+	hot, err := storage.NewLocalStorage(filepath.Join(dir, "hot"))
+	if err != nil {
+		t.Fatalf("failed to create hot tier: %v", err)
+	}
+	fake := s3fake.New(t)
+	cold, err := storage.NewS3StorageWithOptions(storage.S3Options{Endpoint: fake.Endpoint(), Bucket: "cold",
+		AccessKey: s3fake.AccessKey, SecretKey: s3fake.SecretKey})
+	if err != nil {
+		t.Fatalf("failed to create cold tier: %v", err)
+	}
+	tiered, err := storage.NewTieredStorage(
+		storage.Tier{Name: "ssd", Backend: hot, Classes: []string{storage.ClassHot, storage.ClassWarm}},
+		storage.Tier{Name: "bucket", Backend: cold, Classes: []string{storage.ClassCold, storage.ClassArchive}},
+	)
+	if err != nil {
+		t.Fatalf("failed to create tiered storage: %v", err)
+	}
+
+	now := time.Now()
+	chunks := make(map[string][]string)
+	for fileID, backend := range map[string]storage.Storage{"stale": hot, "revived": cold} {
+		for i := 0; i < 2; i++ {
+			id, err := backend.Put(strings.NewReader(fileID + string(rune('a'+i))))
+			if err != nil {
+				t.Fatalf("failed to store chunk of %s: %v", fileID, err)
+			}
+			chunks[fileID] = append(chunks[fileID], id)
+		}
+	}
+	optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: "stale", FileName: "stale.bin",
+		StorageClass: storage.ClassHot, CreatedAt: now.Add(-60 * 24 * time.Hour), AccessedAt: now.Add(-40 * 24 * time.Hour)})
+	optimized.StoreFileMetadata(&metadata.EnhancedFileMetadata{FileID: "revived", FileName: "revived.bin",
+		StorageClass: storage.ClassCold, CreatedAt: now.Add(-60 * 24 * time.Hour), AccessedAt: now.Add(-time.Hour)})
+
+	optimized.SetTiering(TieringPolicy{ColdAfter: 30 * 24 * time.Hour}, tiered, func(fileID string) []string {
+		return chunks[fileID]
+	})
+	report, err := optimized.runTieringPass(now)
+	if err != nil {
+		t.Fatalf("RunTieringPass failed: %v", err)
+	}
+	if report.Promoted != 1 || report.Demoted != 1 {
+		t.Fatalf("expected one promotion and one demotion, got %+v", report)
+	}
+
+	// The demoted chunks now live only in the bucket, the promoted ones only on disk
+	for _, id := range chunks["stale"] {
+		if _, ok := fake.Object("cold", id); !ok {
+			t.Errorf("expected chunk %s of the demoted file uploaded to the bucket", id)
+		}
+		if exists, _ := hot.Exists(id); exists {
+			t.Errorf("expected chunk %s removed from the hot tier", id)
+		}
+	}
+	for _, id := range chunks["revived"] {
+		if exists, _ := hot.Exists(id); !exists {
+			t.Errorf("expected chunk %s of the promoted file on the hot tier", id)
+		}
+		if _, ok := fake.Object("cold", id); ok {
+			t.Errorf("expected chunk %s removed from the bucket", id)
+		}
+	}
+	for fileID, class := range map[string]string{"stale": storage.ClassCold, "revived": storage.ClassHot} {
+		if file, err := optimized.LoadFileMetadata(fileID); err != nil || file.StorageClass != class {
+			t.Errorf("expected %s recorded as %s, got %+v, %v", fileID, class, file, err)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package dfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/sirupsen/logrus"
+)
+
+// RelationDerivative links an original file to a file transcoded from it
+const RelationDerivative = "derivative"
+
+// Transcode job states
+const (
+	TranscodeQueued    = "queued"
+	TranscodeRunning   = "running"
+	TranscodeCompleted = "completed"
+	TranscodeFailed    = "failed"
+)
+
+var (
+	ErrTranscodeInputTooLarge = errors.New("file exceeds transcoder input limit")
+	ErrTranscodeQueueFull     = errors.New("transcoder queue is full")
+)
+
+// TranscodeRule maps source MIME types to an external conversion command
+type TranscodeRule struct {
+	MimeTypes    []string `json:"mime_types"`    // Exact types or wildcards such as "video/*"
+	Command      []string `json:"command"`       // argv; {input}, {output} and {format} are substituted
+	TargetFormat string   `json:"target_format"` // Extension of the derivative, e.g. "mp4"
+	TargetMime   string   `json:"target_mime"`
+}
+
+// TranscoderConfig holds the transcoding hook configuration and resource limits
+type TranscoderConfig struct {
+	Enabled        bool            `json:"enabled"`
+	Rules          []TranscodeRule `json:"rules"`
+	MaxConcurrent  int             `json:"max_concurrent"`   // Commands running at once
+	MaxPending     int             `json:"max_pending"`      // Queued plus running jobs before submissions are refused
+	Timeout        time.Duration   `json:"timeout"`          // Per-command limit
+	MaxInputBytes  int64           `json:"max_input_bytes"`  // Larger originals are skipped
+	MaxOutputBytes int64           `json:"max_output_bytes"` // Larger derivatives are discarded
+	WorkDir        string          `json:"work_dir"`
+}
+
+// DefaultTranscoderConfig returns a disabled configuration with conservative limits
+func DefaultTranscoderConfig() *TranscoderConfig {
+	return &TranscoderConfig{
+		Enabled:        false,
+		MaxConcurrent:  1,
+		MaxPending:     16,
+		Timeout:        10 * time.Minute,
+		MaxInputBytes:  2 * 1024 * 1024 * 1024, // 2GB
+		MaxOutputBytes: 2 * 1024 * 1024 * 1024, // 2GB
+		WorkDir:        "./transcode",
+	}
+}
+
+// TranscoderConfigFromConfig builds a transcoder configuration from the application config
+func TranscoderConfigFromConfig(cfg *config.AppConfig) *TranscoderConfig {
+	tc := DefaultTranscoderConfig()
+	if cfg == nil {
+		return tc
+	}
+
+	tc.Enabled = cfg.TranscodeEnabled
+	for _, rule := range cfg.TranscodeRules {
+		tc.Rules = append(tc.Rules, TranscodeRule{
+			MimeTypes:    rule.MimeTypes,
+			Command:      rule.Command,
+			TargetFormat: rule.Format,
+			TargetMime:   rule.TargetMime,
+		})
+	}
+	if cfg.TranscodeMaxConcurrent > 0 {
+		tc.MaxConcurrent = cfg.TranscodeMaxConcurrent
+	}
+	if cfg.TranscodeMaxPending > 0 {
+		tc.MaxPending = cfg.TranscodeMaxPending
+	}
+	if cfg.TranscodeTimeout > 0 {
+		tc.Timeout = time.Duration(cfg.TranscodeTimeout) * time.Second
+	}
+	if cfg.TranscodeMaxInputBytes > 0 {
+		tc.MaxInputBytes = cfg.TranscodeMaxInputBytes
+	}
+	if cfg.TranscodeMaxOutputBytes > 0 {
+		tc.MaxOutputBytes = cfg.TranscodeMaxOutputBytes
+	}
+	if cfg.TranscodeWorkDir != "" {
+		tc.WorkDir = cfg.TranscodeWorkDir
+	}
+	return tc
+}
+
+// TranscodeSource describes an uploaded original offered to the transcoder
+type TranscodeSource struct {
+	FileID   string
+	FileName string
+	MimeType string
+	OwnerID  string
+	Path     string // Local copy of the original; it is copied before Submit returns
+	Password string // Passed through to the store function for password-encrypted uploads
+}
+
+// DerivativeStoreFunc stores a transcoded file and returns its file ID
+type DerivativeStoreFunc func(path string, source TranscodeSource) (string, error)
+
+// TranscodeJob reports the status of one transcoding run
+type TranscodeJob struct {
+	JobID            string    `json:"job_id"`
+	SourceFileID     string    `json:"source_file_id"`
+	DerivativeFileID string    `json:"derivative_file_id,omitempty"`
+	TargetFormat     string    `json:"target_format"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	OutputSize       int64     `json:"output_size,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	StartedAt        time.Time `json:"started_at,omitempty"`
+	CompletedAt      time.Time `json:"completed_at,omitempty"`
+}
+
+// Transcoder runs configured external converters on uploaded media in the
+// background and records each result as a derivative of the original.
+// Failures are reported on the job and never touch the original file.
+type Transcoder struct {
+	config   *TranscoderConfig
+	store    DerivativeStoreFunc
+	metadata *OptimizedStorage
+
+	jobs    map[string]*TranscodeJob
+	pending int
+	jobsMu  sync.RWMutex
+
+	slots  chan struct{}
+	wg     sync.WaitGroup
+	logger *logrus.Logger
+}
+
+// NewTranscoder creates a transcoder; metadata may be nil, in which case no relationships are recorded
+func NewTranscoder(cfg *TranscoderConfig, store DerivativeStoreFunc, metadataStorage *OptimizedStorage) (*Transcoder, error) {
+	if cfg == nil {
+		cfg = DefaultTranscoderConfig()
+	}
+	if cfg.Enabled && store == nil {
+		return nil, fmt.Errorf("transcoder requires a derivative store")
+	}
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 1
+	}
+	if cfg.Enabled {
+		if err := os.MkdirAll(cfg.WorkDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create transcode work directory: %v", err)
+		}
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+
+	return &Transcoder{
+		config:   cfg,
+		store:    store,
+		metadata: metadataStorage,
+		jobs:     make(map[string]*TranscodeJob),
+		slots:    make(chan struct{}, cfg.MaxConcurrent),
+		logger:   logger,
+	}, nil
+}
+
+// RuleFor returns the rule handling the MIME type, or nil when the type is not configured
+func (t *Transcoder) RuleFor(mimeType string) *TranscodeRule {
+	if !t.config.Enabled {
+		return nil
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	for i := range t.config.Rules {
+		rule := &t.config.Rules[i]
+		for _, pattern := range rule.MimeTypes {
+			pattern = strings.ToLower(pattern)
+			if pattern == mimeType {
+				return rule
+			}
+			if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// Submit queues a transcode of the source when its MIME type has a rule.
+// It returns a nil job when transcoding does not apply, and refuses in
+// strict encryption mode.
+func (t *Transcoder) Submit(source TranscodeSource) (*TranscodeJob, error) {
+	rule := t.RuleFor(source.MimeType)
+	if rule == nil || len(rule.Command) == 0 {
+		return nil, nil
+	}
+	// The command reads and writes plaintext files in the work directory
+	if err := encryptor.RefusePlaintext("transcode of " + source.FileID); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(source.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat transcode input: %v", err)
+	}
+	if t.config.MaxInputBytes > 0 && info.Size() > t.config.MaxInputBytes {
+		return nil, fmt.Errorf("%w: %d > %d bytes", ErrTranscodeInputTooLarge, info.Size(), t.config.MaxInputBytes)
+	}
+
+	t.jobsMu.Lock()
+	if t.config.MaxPending > 0 && t.pending >= t.config.MaxPending {
+		t.jobsMu.Unlock()
+		return nil, ErrTranscodeQueueFull
+	}
+	t.pending++
+	t.jobsMu.Unlock()
+
+	job := &TranscodeJob{
+		JobID:        uuid.New().String(),
+		SourceFileID: source.FileID,
+		TargetFormat: rule.TargetFormat,
+		Status:       TranscodeQueued,
+		CreatedAt:    time.Now(),
+	}
+
+	// Take a private copy so the caller can clean up its temp file immediately
+	inputPath := filepath.Join(t.config.WorkDir, job.JobID+".in"+filepath.Ext(source.FileName))
+	if err := copyLocalFile(source.Path, inputPath); err != nil {
+		t.jobsMu.Lock()
+		t.pending--
+		t.jobsMu.Unlock()
+		return nil, fmt.Errorf("failed to stage transcode input: %v", err)
+	}
+
+	t.jobsMu.Lock()
+	t.jobs[job.JobID] = job
+	t.jobsMu.Unlock()
+
+	t.wg.Add(1)
+	go t.run(job, *rule, inputPath, source)
+
+	t.logger.Infof("🎞️ Queued transcode %s of %s to %s", job.JobID, source.FileID, rule.TargetFormat)
+	return t.snapshot(job), nil
+}
+
+// Job returns a copy of the job's current status
+func (t *Transcoder) Job(jobID string) (*TranscodeJob, bool) {
+	t.jobsMu.RLock()
+	defer t.jobsMu.RUnlock()
+	job, exists := t.jobs[jobID]
+	if !exists {
+		return nil, false
+	}
+	copied := *job
+	return &copied, true
+}
+
+// JobsForFile returns the jobs started for an original file, oldest first
+func (t *Transcoder) JobsForFile(fileID string) []*TranscodeJob {
+	t.jobsMu.RLock()
+	jobs := make([]*TranscodeJob, 0)
+	for _, job := range t.jobs {
+		if fileID == "" || job.SourceFileID == fileID {
+			copied := *job
+			jobs = append(jobs, &copied)
+		}
+	}
+	t.jobsMu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Wait blocks until every submitted job has finished
+func (t *Transcoder) Wait() {
+	t.wg.Wait()
+}
+
+// run executes one job, holding a concurrency slot while the command runs
+func (t *Transcoder) run(job *TranscodeJob, rule TranscodeRule, inputPath string, source TranscodeSource) {
+	defer t.wg.Done()
+	defer os.Remove(inputPath)
+
+	t.slots <- struct{}{}
+	defer func() { <-t.slots }()
+
+	t.update(job, func(j *TranscodeJob) {
+		j.Status = TranscodeRunning
+		j.StartedAt = time.Now()
+	})
+
+	outputPath := filepath.Join(t.config.WorkDir, job.JobID+"."+rule.TargetFormat)
+	defer os.Remove(outputPath)
+
+	derivativeID, size, err := t.transcode(rule, inputPath, outputPath, source)
+	if err != nil {
+		t.logger.Warnf("⚠️ Transcode %s of %s failed: %v", job.JobID, source.FileID, err)
+		t.update(job, func(j *TranscodeJob) {
+			j.Status = TranscodeFailed
+			j.Error = err.Error()
+			j.CompletedAt = time.Now()
+		})
+		return
+	}
+
+	t.update(job, func(j *TranscodeJob) {
+		j.Status = TranscodeCompleted
+		j.DerivativeFileID = derivativeID
+		j.OutputSize = size
+		j.CompletedAt = time.Now()
+	})
+	t.logger.Infof("✅ Transcoded %s to %s derivative %s", source.FileID, rule.TargetFormat, derivativeID)
+}
+
+// transcode runs the command, stores the output and links it to the original
+func (t *Transcoder) transcode(rule TranscodeRule, inputPath, outputPath string, source TranscodeSource) (string, int64, error) {
+	ctx := context.Background()
+	if t.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.config.Timeout)
+		defer cancel()
+	}
+
+	replacer := strings.NewReplacer("{input}", inputPath, "{output}", outputPath, "{format}", rule.TargetFormat)
+	args := make([]string, len(rule.Command))
+	for i, arg := range rule.Command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", 0, fmt.Errorf("transcoder timed out after %v", t.config.Timeout)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("transcoder failed: %v: %s", err, lastLine(output))
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return "", 0, fmt.Errorf("transcoder produced no output: %v", err)
+	}
+	if info.Size() == 0 {
+		return "", 0, fmt.Errorf("transcoder produced an empty output")
+	}
+	if t.config.MaxOutputBytes > 0 && info.Size() > t.config.MaxOutputBytes {
+		return "", 0, fmt.Errorf("derivative exceeds output limit: %d > %d bytes", info.Size(), t.config.MaxOutputBytes)
+	}
+
+	derivativeID, err := t.store(outputPath, source)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to store derivative: %v", err)
+	}
+
+	if t.metadata != nil {
+		baseName := strings.TrimSuffix(source.FileName, filepath.Ext(source.FileName))
+		meta := &metadata.EnhancedFileMetadata{
+			FileID:         derivativeID,
+			FileName:       baseName + "." + rule.TargetFormat,
+			OriginalName:   source.FileName,
+			FileSize:       info.Size(),
+			MimeType:       rule.TargetMime,
+			OwnerID:        source.OwnerID,
+			CreatorID:      source.OwnerID,
+			ParentFileID:   source.FileID,
+			Tags:           []string{RelationDerivative, rule.TargetFormat},
+			Categories:     []string{"transcoded"},
+			Description:    fmt.Sprintf("%s derivative of %s", rule.TargetFormat, source.FileName),
+			HealthStatus:   "healthy",
+			CustomMetadata: map[string]interface{}{"source_file_id": source.FileID},
+		}
+		if err := t.metadata.StoreFileMetadata(meta); err != nil {
+			return "", 0, fmt.Errorf("failed to store derivative metadata: %v", err)
+		}
+		if err := t.metadata.CreateFileRelationship(source.FileID, derivativeID, RelationDerivative, "transcoder"); err != nil {
+			return "", 0, fmt.Errorf("failed to link derivative: %v", err)
+		}
+	}
+
+	return derivativeID, info.Size(), nil
+}
+
+// update applies a change to a job under the lock, releasing its pending slot once it finishes
+func (t *Transcoder) update(job *TranscodeJob, change func(*TranscodeJob)) {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	change(job)
+	if job.Status == TranscodeCompleted || job.Status == TranscodeFailed {
+		t.pending--
+	}
+}
+
+func (t *Transcoder) snapshot(job *TranscodeJob) *TranscodeJob {
+	t.jobsMu.RLock()
+	defer t.jobsMu.RUnlock()
+	copied := *job
+	return &copied
+}
+
+// lastLine returns the final non-empty line of command output, which usually holds the error
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func copyLocalFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
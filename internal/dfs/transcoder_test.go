@@ -0,0 +1,97 @@
+package dfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranscoderProducesLinkedDerivative(t *testing.T) {
+	dir := t.TempDir()
+	optimized, err := NewOptimizedStorage(filepath.Join(dir, "optimized"))
+	if err != nil {
+		t.Fatalf("failed to create optimized storage: %v", err)
+	}
+	defer optimized.Close()
+
+	original := filepath.Join(dir, "clip.avi")
+	if err := os.WriteFile(original, []byte("raw video"), 0644); err != nil {
+		t.Fatalf("failed to write original: %v", err)
+	}
+
+	// The mock transcoder appends a marker so the stored derivative can be told apart
+	var stored []byte
+	store := func(path string, source TranscodeSource) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		stored = data
+		return "derived-" + source.FileID, nil
+	}
+
+	cfg := DefaultTranscoderConfig()
+	cfg.Enabled = true
+	cfg.WorkDir = filepath.Join(dir, "work")
+	cfg.Rules = []TranscodeRule{
+		{
+			MimeTypes:    []string{"video/*"},
+			Command:      []string{"sh", "-c", `cp "$1" "$2" && printf ' as %s' "$3" >> "$2"`, "sh", "{input}", "{output}", "{format}"},
+			TargetFormat: "mp4",
+			TargetMime:   "video/mp4",
+		},
+		{
+			MimeTypes:    []string{"audio/wav"},
+			Command:      []string{"sh", "-c", "echo 'unsupported codec' >&2; exit 1"},
+			TargetFormat: "mp3",
+		},
+	}
+	transcoder, err := NewTranscoder(cfg, store, optimized)
+	if err != nil {
+		t.Fatalf("failed to create transcoder: %v", err)
+	}
+
+	if job, err := transcoder.Submit(TranscodeSource{FileID: "doc", MimeType: "application/pdf", Path: original}); job != nil || err != nil {
+		t.Errorf("expected unconfigured MIME type to be skipped, got %v, %v", job, err)
+	}
+
+	job, err := transcoder.Submit(TranscodeSource{FileID: "orig-1", FileName: "clip.avi", MimeType: "video/x-msvideo", OwnerID: "alice", Path: original})
+	if err != nil || job == nil {
+		t.Fatalf("expected a queued job, got %v, %v", job, err)
+	}
+	failing, err := transcoder.Submit(TranscodeSource{FileID: "orig-2", FileName: "song.wav", MimeType: "audio/wav", Path: original})
+	if err != nil || failing == nil {
+		t.Fatalf("expected a queued job for the failing rule, got %v, %v", failing, err)
+	}
+	transcoder.Wait()
+
+	done, _ := transcoder.Job(job.JobID)
+	if done.Status != TranscodeCompleted || done.DerivativeFileID != "derived-orig-1" {
+		t.Fatalf("expected completed job with derivative, got %+v", done)
+	}
+	if string(stored) != "raw video as mp4" {
+		t.Errorf("unexpected derivative contents: %q", stored)
+	}
+
+	relationships, err := optimized.GetFileRelationships("orig-1")
+	if err != nil {
+		t.Fatalf("GetFileRelationships failed: %v", err)
+	}
+	if len(relationships) != 1 || relationships[0].TargetFileID != "derived-orig-1" || relationships[0].RelationType != RelationDerivative {
+		t.Errorf("expected one derivative relationship, got %+v", relationships)
+	}
+
+	failed, _ := transcoder.Job(failing.JobID)
+	if failed.Status != TranscodeFailed || failed.Error == "" {
+		t.Errorf("expected failed job with error, got %+v", failed)
+	}
+	if rels, _ := optimized.GetFileRelationships("orig-2"); len(rels) != 0 {
+		t.Errorf("failed transcode should not link anything, got %+v", rels)
+	}
+	if _, err := os.Stat(original); err != nil {
+		t.Errorf("original should be untouched: %v", err)
+	}
+	if entries, _ := os.ReadDir(cfg.WorkDir); len(entries) != 0 {
+		t.Errorf("expected work directory to be cleaned up, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,142 @@
+package dfs
+
+import (
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// selectZoneSpreadNodes picks the best-scoring node of each distinct zone
+// first, then of each distinct rack, then fills any remaining replicas by
+// score. With at least count zones no two replicas share a zone; with fewer,
+// every zone still gets a replica before any gets a second. Nodes that
+// announce no zone share one unnamed zone. nodeScores is sorted best first.
+func (cd *ChunkDistributor) selectZoneSpreadNodes(nodeScores []*NodeScore, count int) []*p2p.Node {
+	selected := make([]*p2p.Node, 0, count)
+	taken := make(map[string]bool)
+	pick := func(domain func(*p2p.Node) string) {
+		used := make(map[string]bool)
+		for _, node := range selected {
+			used[domain(node)] = true
+		}
+		for _, nodeScore := range nodeScores {
+			if len(selected) >= count {
+				return
+			}
+			node := nodeScore.Node
+			if taken[node.ID] || used[domain(node)] {
+				continue
+			}
+			selected = append(selected, node)
+			taken[node.ID] = true
+			used[domain(node)] = true
+		}
+	}
+
+	pick(func(node *p2p.Node) string { return node.Zone })
+	pick(func(node *p2p.Node) string { return node.Zone + "/" + node.Rack })
+	pick(func(node *p2p.Node) string { return node.ID })
+	return selected
+}
+
+// nodeZone returns the zone a node announced, empty when it announced none
+// or is unknown
+func (dfs *DFSCore) nodeZone(nodeID string) string {
+	if node := dfs.nodeByID(nodeID); node != nil {
+		return node.Zone
+	}
+	return ""
+}
+
+// replicaZones maps each replica's node to its zone
+func (dfs *DFSCore) replicaZones(nodeIDs []string) map[string]string {
+	zones := make(map[string]string, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		zones[nodeID] = dfs.nodeZone(nodeID)
+	}
+	return zones
+}
+
+// SetZoneSpread makes repairs place each new replica in a zone, then a rack,
+// holding no copy of the chunk before doubling up on one, as the zone_aware
+// strategy does for rebalancing
+func (dfs *DFSCore) SetZoneSpread(enabled bool) {
+	dfs.replicaMu.Lock()
+	defer dfs.replicaMu.Unlock()
+	dfs.zoneSpread = enabled
+}
+
+// nextRepairTarget returns the candidate a chunk's next replica goes to: the
+// first one, or with zone spread the first in the least used zone and rack
+// given the nodes in placed
+func (dfs *DFSCore) nextRepairTarget(candidates []*p2p.Node, placed []*p2p.Node) *p2p.Node {
+	dfs.replicaMu.RLock()
+	zoneSpread := dfs.zoneSpread
+	dfs.replicaMu.RUnlock()
+
+	best := candidates[0]
+	if !zoneSpread {
+		return best
+	}
+	bestRank := spreadRank(best, placed)
+	for _, node := range candidates[1:] {
+		if rank := spreadRank(node, placed); rank < bestRank {
+			best, bestRank = node, rank
+		}
+	}
+	return best
+}
+
+// spreadRank is 0 for a node in a zone none of placed is in, 1 for one in a
+// new rack of a used zone and 2 otherwise
+func spreadRank(node *p2p.Node, placed []*p2p.Node) int {
+	rank := 0
+	for _, other := range placed {
+		if other.Zone != node.Zone {
+			continue
+		}
+		if other.Rack == node.Rack {
+			return 2
+		}
+		rank = 1
+	}
+	return rank
+}
+
+// replicaNodes returns the known nodes holding a replica of the chunk
+func (dfs *DFSCore) replicaNodes(chunkID string) []*p2p.Node {
+	dfs.replicaMu.RLock()
+	var nodeIDs []string
+	if replica, exists := dfs.replicaInfo[chunkID]; exists {
+		nodeIDs = append(nodeIDs, replica.CurrentReplicas...)
+	}
+	dfs.replicaMu.RUnlock()
+
+	nodes := make([]*p2p.Node, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if node := dfs.nodeByID(nodeID); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// nodeByID returns the local node or a known peer, nil when unknown
+func (dfs *DFSCore) nodeByID(nodeID string) *p2p.Node {
+	if dfs.network == nil {
+		return nil
+	}
+	if nodeID == dfs.network.LocalNode.ID {
+		return dfs.network.LocalNode
+	}
+	return dfs.network.GetPeerByID(nodeID)
+}
+
+// removeNode returns nodes without the one with the given ID
+func removeNode(nodes []*p2p.Node, nodeID string) []*p2p.Node {
+	kept := make([]*p2p.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.ID != nodeID {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,96 @@
+package dfs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+func TestZoneAwareStrategySpreadsReplicasAcrossZones(t *testing.T) {
+	network := p2p.NewNetworkWithID("node-a", "node-a.test", 7400)
+	network.SetLocalTopology("zone-1", "rack-1")
+	core := NewDFSCore(nil, network, nil, nil, nil)
+	core.nodeHealth["node-a"] = &NodeHealth{NodeID: "node-a", Status: "healthy", LastHeartbeat: time.Now()}
+
+	// Four of the five nodes share zone-1 and outscore the one in zone-2
+	for i, zone := range []string{"zone-1", "zone-1", "zone-1", "zone-2"} {
+		peerID := fmt.Sprintf("peer-%d", i)
+		network.RegisterPeer(&p2p.Node{ID: peerID, Status: "online", LastSeen: time.Now(), Zone: zone, Rack: fmt.Sprintf("rack-%d", i)})
+		health := &NodeHealth{NodeID: peerID, Status: "healthy", LastHeartbeat: time.Now(),
+			StorageCapacity: 100, StorageUsed: 10, StorageUtilization: 0.1}
+		if zone == "zone-2" {
+			health.StorageUsed, health.StorageUtilization = 90, 0.9
+		}
+		core.nodeHealth[peerID] = health
+	}
+
+	strategy, err := ParseDistributionStrategy("zone_aware")
+	if err != nil || strategy != StrategyZoneAware {
+		t.Fatalf("expected zone_aware to parse, got %q, %v", strategy, err)
+	}
+	cd := NewChunkDistributor(core, strategy)
+	nodes, err := cd.SelectOptimalNodes("chunk-abc", 3, nil)
+	if err != nil {
+		t.Fatalf("SelectOptimalNodes failed: %v", err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(nodes))
+	}
+	nodeIDs := make([]string, len(nodes))
+	perZone := make(map[string]int)
+	for i, node := range nodes {
+		nodeIDs[i] = node.ID
+		perZone[node.Zone]++
+	}
+	if len(perZone) != 2 || perZone["zone-2"] != 1 {
+		t.Fatalf("expected replicas in both zones, got %v across %v", nodeIDs, perZone)
+	}
+
+	// The placement is recorded with the zone of every replica
+	core.RegisterChunk("chunk-abc", "file-1", nodeIDs)
+	zones := core.GetReplicaInfo("chunk-abc").Zones
+	if len(zones) != 3 || zones["peer-3"] != "zone-2" {
+		t.Errorf("expected the replica zones recorded, got %v", zones)
+	}
+
+	// The balanced strategy, blind to zones, keeps every replica in zone-1
+	balanced, err := NewChunkDistributor(core, StrategyBalanced).SelectOptimalNodes("chunk-abc", 3, nil)
+	if err != nil {
+		t.Fatalf("SelectOptimalNodes failed: %v", err)
+	}
+	for _, node := range balanced {
+		if node.ID == "peer-3" {
+			t.Errorf("expected the balanced strategy to skip the loaded zone-2 node, got %v", balanced)
+		}
+	}
+}
+
+func TestRepairsPlaceReplicasInZonesWithoutACopy(t *testing.T) {
+	network := p2p.NewNetworkWithID("node-a", "node-a.test", 7400)
+	network.SetLocalTopology("zone-1", "rack-1")
+	core := NewDFSCore(nil, network, nil, nil, nil)
+	core.SetZoneSpread(true)
+	core.nodeHealth["node-a"] = &NodeHealth{NodeID: "node-a", Status: "healthy", LastHeartbeat: time.Now()}
+
+	// peer-0 shares the surviving replica's rack; peer-3 is alone in zone-2
+	for i, topology := range [][2]string{{"zone-1", "rack-1"}, {"zone-1", "rack-2"}, {"zone-1", "rack-3"}, {"zone-2", "rack-4"}} {
+		peerID := fmt.Sprintf("peer-%d", i)
+		network.RegisterPeer(&p2p.Node{ID: peerID, Status: "online", LastSeen: time.Now(), Zone: topology[0], Rack: topology[1]})
+		core.nodeHealth[peerID] = &NodeHealth{NodeID: peerID, Status: "healthy", LastHeartbeat: time.Now()}
+	}
+	core.RegisterChunk("chunk-abc", "file-1", []string{"node-a"})
+
+	core.runRepairs(core.RepairQueue())
+	replicas := core.GetReplicaInfo("chunk-abc").CurrentReplicas
+	if len(replicas) != 3 {
+		t.Fatalf("expected the chunk repaired to 3 replicas, got %v", replicas)
+	}
+	if replicas[1] != "peer-3" {
+		t.Errorf("expected the first new replica in zone-2, got %v", replicas)
+	}
+	if replicas[2] != "peer-1" && replicas[2] != "peer-2" {
+		t.Errorf("expected the second new replica outside the surviving replica's rack, got %v", replicas)
+	}
+}
@@ -0,0 +1,141 @@
+package distributor
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// MessageFileAvailable announces a distributed file to peers
+const MessageFileAvailable = "file_available"
+
+// FileAnnouncement tells peers about a file and which nodes hold its chunks,
+// so any of them can reassemble it by fetching the chunks it lacks. A file
+// pushed to one peer carries its metadata records for that peer to store.
+type FileAnnouncement struct {
+	File    *FileInfo    `json:"file"`
+	Chunks  []*ChunkInfo `json:"chunks"`
+	Records *FileRecords `json:"records,omitempty"`
+}
+
+// broadcastFileAvailability broadcasts file availability to all peers
+func (d *Distributor) broadcastFileAvailability(file *FileInfo) {
+	d.mu.RLock()
+	announcement := FileAnnouncement{File: file.clone(), Chunks: make([]*ChunkInfo, 0, len(file.Chunks))}
+	for _, chunkID := range file.Chunks {
+		if chunk, ok := d.chunks[chunkID]; ok {
+			announcement.Chunks = append(announcement.Chunks, chunk.clone())
+		}
+	}
+	d.mu.RUnlock()
+	// Replication results only mean something on the uploading node
+	announcement.File.ChunkResults = nil
+
+	msg := &p2p.NetworkMessage{
+		Type:      MessageFileAvailable,
+		From:      d.network.LocalNode.ID,
+		To:        "",
+		Data:      announcement,
+		Timestamp: time.Now(),
+	}
+
+	d.network.BroadcastMessage(msg)
+}
+
+// HandleMessage handles messages broadcast by peers. File announcements are
+// recorded, along with any metadata records they carry, and deletions drop
+// this node's replicas; other message types are accepted and ignored.
+func (d *Distributor) HandleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg struct {
+		Type string          `json:"type"`
+		From string          `json:"from"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if msg.Type == MessageFileDeleted {
+		var deletion FileDeletion
+		if err := json.Unmarshal(msg.Data, &deletion); err != nil || deletion.FileID == "" {
+			http.Error(w, "Invalid file deletion", http.StatusBadRequest)
+			return
+		}
+		d.dropFile(deletion)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if msg.Type != MessageFileAvailable {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var announcement FileAnnouncement
+	if err := json.Unmarshal(msg.Data, &announcement); err != nil || announcement.File == nil || announcement.File.ID == "" {
+		http.Error(w, "Invalid file announcement", http.StatusBadRequest)
+		return
+	}
+	if announcement.Records != nil {
+		if err := d.storeFileRecords(announcement.File.ID, announcement.Records); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	d.learnFile(announcement)
+	w.WriteHeader(http.StatusOK)
+}
+
+// learnFile records an announced file and where its chunks are, keeping what
+// this node already knows about chunks it received itself
+func (d *Distributor) learnFile(announcement FileAnnouncement) {
+	d.mu.Lock()
+	if _, known := d.files[announcement.File.ID]; !known {
+		d.files[announcement.File.ID] = announcement.File.clone()
+	}
+	for _, announced := range announcement.Chunks {
+		chunk, known := d.chunks[announced.ID]
+		if !known {
+			chunk = announced.clone()
+			chunk.Nodes = nil
+			d.chunks[announced.ID] = chunk
+		}
+		for _, nodeID := range announced.Nodes {
+			// Only this node's own records say what it holds
+			if nodeID != d.network.LocalNode.ID && !containsNode(chunk.Nodes, nodeID) {
+				chunk.Nodes = append(chunk.Nodes, nodeID)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	// Downloads look chunks up by holder in the network's view of its peers
+	for _, announced := range announcement.Chunks {
+		holding := make(map[string]bool)
+		for _, node := range d.network.FindNodesWithChunk(announced.ID) {
+			holding[node.ID] = true
+		}
+		for _, nodeID := range announced.Nodes {
+			// The chunk has appeared there, whatever the peer answered before
+			d.negative.forget(announced.ID, nodeID)
+			if nodeID != d.network.LocalNode.ID && !holding[nodeID] {
+				d.network.AddChunkToNode(nodeID, announced.ID)
+			}
+		}
+	}
+}
+
+func containsNode(nodes []string, nodeID string) bool {
+	for _, n := range nodes {
+		if n == nodeID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package distributor
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultAvailabilityTTL is how long an availability result is reused before peers are rechecked
+const DefaultAvailabilityTTL = 10 * time.Second
+
+// Availability reports how much of a file can currently be retrieved
+type Availability struct {
+	FileID            string    `json:"file_id"`
+	TotalChunks       int       `json:"total_chunks"`
+	AvailableChunks   int       `json:"available_chunks"`
+	Fraction          float64   `json:"fraction"` // 0.0 to 1.0
+	UnavailableChunks []string  `json:"unavailable_chunks"`
+	CanReassemble     bool      `json:"can_reassemble"`
+	CheckedAt         time.Time `json:"checked_at"`
+}
+
+type cachedAvailability struct {
+	result  *Availability
+	expires time.Time
+}
+
+// SetAvailabilityTTL sets how long AvailabilityFor results are cached; zero disables caching
+func (d *Distributor) SetAvailabilityTTL(ttl time.Duration) {
+	d.availabilityMu.Lock()
+	defer d.availabilityMu.Unlock()
+	d.availabilityTTL = ttl
+	d.availabilityCache = make(map[string]cachedAvailability)
+}
+
+// AvailabilityFor checks, for every chunk of the file, whether at least one
+// healthy replica is reachable: a local copy present in storage, or a copy on
+// a peer that is currently online. Results are cached briefly.
+func (d *Distributor) AvailabilityFor(fileID string) (*Availability, error) {
+	d.availabilityMu.Lock()
+	if cached, ok := d.availabilityCache[fileID]; ok && time.Now().Before(cached.expires) {
+		d.availabilityMu.Unlock()
+		return cached.result, nil
+	}
+	ttl := d.availabilityTTL
+	d.availabilityMu.Unlock()
+
+	d.mu.RLock()
+	file, exists := d.files[fileID]
+	var chunks []*ChunkInfo
+	if exists {
+		for _, chunkID := range file.Chunks {
+			if chunk, ok := d.chunks[chunkID]; ok {
+				copied := *chunk
+				copied.Nodes = append([]string(nil), chunk.Nodes...)
+				chunks = append(chunks, &copied)
+			} else {
+				chunks = append(chunks, &ChunkInfo{ID: chunkID})
+			}
+		}
+	}
+	d.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("file not found: %s", fileID)
+	}
+
+	localPaths := d.localChunkPaths(fileID)
+	result := &Availability{
+		FileID:            fileID,
+		TotalChunks:       len(chunks),
+		UnavailableChunks: make([]string, 0),
+		CheckedAt:         time.Now(),
+	}
+
+	for _, chunk := range chunks {
+		if d.chunkReachable(chunk, localPaths) {
+			result.AvailableChunks++
+		} else {
+			result.UnavailableChunks = append(result.UnavailableChunks, chunk.ID)
+		}
+	}
+
+	if result.TotalChunks > 0 {
+		result.Fraction = float64(result.AvailableChunks) / float64(result.TotalChunks)
+	}
+	result.CanReassemble = result.TotalChunks > 0 && result.AvailableChunks == result.TotalChunks
+
+	if ttl > 0 {
+		d.availabilityMu.Lock()
+		d.availabilityCache[fileID] = cachedAvailability{result: result, expires: time.Now().Add(ttl)}
+		d.availabilityMu.Unlock()
+	}
+	return result, nil
+}
+
+// localChunkPaths maps chunk index to its storage path, or nil without a metadata store
+func (d *Distributor) localChunkPaths(fileID string) map[int]string {
+	if d.metaStore == nil {
+		return nil
+	}
+	chunkMetas, err := d.metaStore.GetChunksByFileID(fileID)
+	if err != nil {
+		return map[int]string{}
+	}
+	paths := make(map[int]string, len(chunkMetas))
+	for _, meta := range chunkMetas {
+		paths[meta.Index] = meta.Path
+	}
+	return paths
+}
+
+// chunkReachable reports whether a usable replica of the chunk exists
+func (d *Distributor) chunkReachable(chunk *ChunkInfo, localPaths map[int]string) bool {
+	localID := d.network.LocalNode.ID
+
+	candidates := make(map[string]bool)
+	for _, nodeID := range chunk.Nodes {
+		candidates[nodeID] = true
+	}
+	for _, node := range d.network.FindNodesWithChunk(chunk.ID) {
+		candidates[node.ID] = true
+	}
+
+	for nodeID := range candidates {
+		if nodeID == localID {
+			if d.hasLocalCopy(chunk, localPaths) {
+				return true
+			}
+			continue
+		}
+		if peer := d.network.GetPeerByID(nodeID); peer != nil && peer.Status == "online" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLocalCopy checks local storage for the chunk; without metadata the node list is trusted
+func (d *Distributor) hasLocalCopy(chunk *ChunkInfo, localPaths map[int]string) bool {
+	if localPaths == nil {
+		return true
+	}
+	path, ok := localPaths[chunk.Index]
+	if !ok || d.store == nil {
+		return false
+	}
+	exists, err := d.store.Exists(path)
+	return err == nil && exists
+}
@@ -0,0 +1,76 @@
+package distributor
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestAvailabilityForMixedReachability(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	network.RegisterPeer(&p2p.Node{ID: "peer-up", Status: "online", LastSeen: time.Now()})
+	network.RegisterPeer(&p2p.Node{ID: "peer-down", Status: "offline", LastSeen: time.Now()})
+	localID := network.LocalNode.ID
+
+	d := NewDistributor(network, store, metaStore)
+
+	// Chunk 0 is stored locally; chunk 1 claims a local copy whose data is gone
+	path0, _ := store.Put(bytes.NewReader([]byte("chunk zero")))
+	metaStore.PutChunkMetadata(metadata.ChunkMetadata{FileID: "file-1", Index: 0, Hash: "h0", Path: path0})
+	metaStore.PutChunkMetadata(metadata.ChunkMetadata{FileID: "file-1", Index: 1, Hash: "h1", Path: "missing"})
+
+	chunks := []*ChunkInfo{
+		{ID: "c0", FileID: "file-1", Index: 0, Nodes: []string{localID}},
+		{ID: "c1", FileID: "file-1", Index: 1, Nodes: []string{localID}},
+		{ID: "c2", FileID: "file-1", Index: 2, Nodes: []string{"peer-up"}},
+		{ID: "c3", FileID: "file-1", Index: 3, Nodes: []string{"peer-down"}},
+	}
+	file := &FileInfo{ID: "file-1"}
+	for _, chunk := range chunks {
+		d.chunks[chunk.ID] = chunk
+		file.Chunks = append(file.Chunks, chunk.ID)
+	}
+	d.files[file.ID] = file
+
+	availability, err := d.AvailabilityFor("file-1")
+	if err != nil {
+		t.Fatalf("AvailabilityFor failed: %v", err)
+	}
+	if availability.AvailableChunks != 2 || availability.Fraction != 0.5 || availability.CanReassemble {
+		t.Errorf("expected 2 of 4 chunks available, got %+v", availability)
+	}
+	if len(availability.UnavailableChunks) != 2 ||
+		availability.UnavailableChunks[0] != "c1" || availability.UnavailableChunks[1] != "c3" {
+		t.Errorf("expected c1 and c3 unavailable, got %v", availability.UnavailableChunks)
+	}
+
+	// Cached results are reused until the TTL expires
+	network.UpdatePeerStatus("peer-down", "online")
+	if cached, _ := d.AvailabilityFor("file-1"); cached.AvailableChunks != 2 {
+		t.Errorf("expected cached result, got %d available", cached.AvailableChunks)
+	}
+	d.SetAvailabilityTTL(0)
+	if fresh, _ := d.AvailabilityFor("file-1"); fresh.AvailableChunks != 3 {
+		t.Errorf("expected peer coming online to raise availability to 3, got %d", fresh.AvailableChunks)
+	}
+
+	if _, err := d.AvailabilityFor("unknown"); err == nil {
+		t.Errorf("expected error for unknown file")
+	}
+}
@@ -0,0 +1,181 @@
+package distributor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// ErrNodeFull is returned when a write would take a node past its capacity limit
+var ErrNodeFull = errors.New("node full")
+
+const (
+	// fullPeerCooldown is how long a peer that refused a chunk for lack of space is skipped
+	fullPeerCooldown = 5 * time.Minute
+	// usageRefresh is how often the measured store size replaces the running estimate
+	usageRefresh = 30 * time.Second
+)
+
+// NodeCapacity is a node's storage limit and how much of it is used
+type NodeCapacity struct {
+	NodeID     string `json:"node_id"`
+	LimitBytes int64  `json:"limit_bytes"` // 0 when the node has no limit
+	UsedBytes  int64  `json:"used_bytes"`
+	FreeBytes  int64  `json:"free_bytes"` // 0 when full or without a limit
+	Full       bool   `json:"full"`
+}
+
+// capacityState tracks the local limit and the peers that reported being full
+type capacityState struct {
+	limit     int64 // Bytes, 0 for no limit
+	used      int64 // Measured store size plus writes accepted since
+	measured  time.Time
+	fullPeers map[string]time.Time // Peer -> when it last refused a chunk
+}
+
+// CapacityLimitFromConfig reads the node capacity settings from the app
+// config, sizing a percentage limit against the disk holding store's chunks.
+// A percentage needs a backend on a local disk.
+func CapacityLimitFromConfig(cfg *config.AppConfig, store storage.Storage) (int64, error) {
+	if cfg == nil {
+		return 0, nil
+	}
+	local, ok := store.(storage.DiskBacked)
+	if !ok {
+		limit, err := storage.ResolveCapacity(cfg.NodeCapacityBytes, 0, "")
+		if err == nil && cfg.NodeCapacityPercent != 0 {
+			err = fmt.Errorf("capacity percentage needs a storage backend on a local disk")
+		}
+		return limit, err
+	}
+	return storage.ResolveCapacity(cfg.NodeCapacityBytes, cfg.NodeCapacityPercent, local.BasePath())
+}
+
+// SetCapacityLimit caps how many bytes this node stores; writes past it are
+// refused with ErrNodeFull. 0 removes the limit.
+func (d *Distributor) SetCapacityLimit(limitBytes int64) {
+	if limitBytes < 0 {
+		limitBytes = 0
+	}
+	d.capacityMu.Lock()
+	d.capacity.limit = limitBytes
+	d.capacity.measured = time.Time{}
+	d.refreshUsageLocked()
+	used := d.capacity.used
+	d.capacityMu.Unlock()
+
+	d.network.SetLocalCapacity(limitBytes, used)
+}
+
+// Capacity reports this node's limit and usage
+func (d *Distributor) Capacity() NodeCapacity {
+	d.capacityMu.Lock()
+	d.refreshUsageLocked()
+	limit, used := d.capacity.limit, d.capacity.used
+	d.capacityMu.Unlock()
+	return newNodeCapacity(d.network.LocalNode.ID, limit, used, false)
+}
+
+// NodeCapacities reports the local node followed by every peer, using the
+// limits peers announced and marking those that recently refused a chunk
+func (d *Distributor) NodeCapacities() []NodeCapacity {
+	capacities := []NodeCapacity{d.Capacity()}
+	for _, peer := range d.network.GetPeers() {
+		capacities = append(capacities, newNodeCapacity(peer.ID, peer.CapacityBytes, peer.UsedBytes, d.refusedRecently(peer.ID)))
+	}
+	sort.Slice(capacities[1:], func(i, j int) bool { return capacities[i+1].NodeID < capacities[j+1].NodeID })
+	return capacities
+}
+
+func newNodeCapacity(nodeID string, limit, used int64, refused bool) NodeCapacity {
+	c := NodeCapacity{NodeID: nodeID, LimitBytes: limit, UsedBytes: used, Full: refused}
+	if limit > 0 {
+		if used >= limit {
+			c.Full = true
+		} else if !c.Full {
+			c.FreeBytes = limit - used
+		}
+	}
+	return c
+}
+
+// reserveCapacity accounts for size more bytes about to be written here,
+// refusing them when they would not fit under the limit
+func (d *Distributor) reserveCapacity(size int64) error {
+	d.capacityMu.Lock()
+	if d.capacity.limit <= 0 {
+		d.capacityMu.Unlock()
+		return nil
+	}
+	d.refreshUsageLocked()
+	limit, used := d.capacity.limit, d.capacity.used
+	if used+size > limit {
+		d.capacityMu.Unlock()
+		return fmt.Errorf("%w: %d of %d bytes used, %d more requested", ErrNodeFull, used, limit, size)
+	}
+	d.capacity.used += size
+	used = d.capacity.used
+	d.capacityMu.Unlock()
+
+	d.network.SetLocalCapacity(limit, used)
+	return nil
+}
+
+// refreshUsageLocked replaces the running estimate with the store's measured
+// size once it is stale, catching deletions and compression
+func (d *Distributor) refreshUsageLocked() {
+	if d.capacity.limit <= 0 || time.Since(d.capacity.measured) < usageRefresh {
+		return
+	}
+	if used, err := d.store.Usage(); err == nil {
+		d.capacity.used = used
+		d.capacity.measured = time.Now()
+	}
+}
+
+// markPeerFull keeps a peer that refused a chunk out of placements for a while
+func (d *Distributor) markPeerFull(peerID string) {
+	d.capacityMu.Lock()
+	defer d.capacityMu.Unlock()
+	if d.capacity.fullPeers == nil {
+		d.capacity.fullPeers = make(map[string]time.Time)
+	}
+	d.capacity.fullPeers[peerID] = time.Now()
+}
+
+func (d *Distributor) refusedRecently(peerID string) bool {
+	d.capacityMu.Lock()
+	defer d.capacityMu.Unlock()
+	refused, ok := d.capacity.fullPeers[peerID]
+	if ok && time.Since(refused) > fullPeerCooldown {
+		delete(d.capacity.fullPeers, peerID)
+		return false
+	}
+	return ok
+}
+
+// peerFull reports whether a peer announced it is full or recently refused a chunk
+func (d *Distributor) peerFull(peer *p2p.Node) bool {
+	if peer.CapacityBytes > 0 && peer.UsedBytes >= peer.CapacityBytes {
+		return true
+	}
+	return d.refusedRecently(peer.ID)
+}
+
+// byFreeCapacity orders peers with the most free space first; peers without
+// an announced limit count as having the most
+func byFreeCapacity(peers []*p2p.Node) {
+	free := func(peer *p2p.Node) int64 {
+		if peer.CapacityBytes <= 0 {
+			return math.MaxInt64
+		}
+		return peer.CapacityBytes - peer.UsedBytes
+	}
+	sort.SliceStable(peers, func(i, j int) bool { return free(peers[i]) > free(peers[j]) })
+}
@@ -0,0 +1,135 @@
+package distributor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestFullPeerRefusesChunksAndIsSkipped(t *testing.T) {
+	// The full node announces no limit, so it is tried first and has to refuse
+	var fullHits, okHits atomic.Int32
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chunk-transfer" {
+			return
+		}
+		fullHits.Add(1)
+		http.Error(w, "node full", http.StatusInsufficientStorage)
+	})
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunk-transfer" {
+			okHits.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ok.Close()
+	host, portStr, _ := net.SplitHostPort(ok.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	d.network.RegisterPeer(&p2p.Node{ID: "peer-2", Address: host, Port: port, Status: "online", LastSeen: time.Now(),
+		CapacityBytes: 1 << 30, UsedBytes: 1 << 20})
+	d.SetDurability(DurabilityAll, 0)
+
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("the chunk should have been placed on the peer with room: %v", err)
+	}
+	if fullHits.Load() != 1 || okHits.Load() != 1 {
+		t.Fatalf("expected one refusal and one accepted transfer, got %d and %d", fullHits.Load(), okHits.Load())
+	}
+	chunk, _ := d.GetChunkInfo(file.Chunks[0])
+	if len(chunk.Nodes) != 2 || chunk.Nodes[1] != "peer-2" {
+		t.Errorf("expected the chunk on the local node and peer-2, got %v", chunk.Nodes)
+	}
+
+	var reported bool
+	for _, c := range d.NodeCapacities() {
+		if c.NodeID == "peer-1" {
+			reported = c.Full
+		}
+	}
+	if !reported {
+		t.Error("the refusing peer should be reported full")
+	}
+
+	// Later uploads leave the full peer out instead of asking again
+	second := filepath.Join(filepath.Dir(inputPath), "second.txt")
+	os.WriteFile(second, []byte("another payload"), 0644)
+	if _, err := d.DistributeFile(second, "pw"); err != nil {
+		t.Fatalf("second upload failed: %v", err)
+	}
+	if fullHits.Load() != 1 || okHits.Load() != 2 {
+		t.Errorf("expected the full peer skipped, got %d refusals and %d transfers", fullHits.Load(), okHits.Load())
+	}
+}
+
+func TestLocalCapacityLimitRefusesWrites(t *testing.T) {
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	d.SetCapacityLimit(8)
+
+	if _, err := d.DistributeFile(inputPath, "pw"); !errors.Is(err, ErrNodeFull) {
+		t.Fatalf("expected ErrNodeFull for an upload past the limit, got %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"chunk_id": "chunk-1", "file_id": "file-1", "index": 0, "size": 64,
+		"hash": "h", "stored_hash": "h", "from_node": "peer-1",
+	})
+	rec := httptest.NewRecorder()
+	d.HandleChunkTransfer(rec, httptest.NewRequest(http.MethodPost, "/chunk-transfer", bytes.NewReader(body)))
+	if rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("expected 507 for a transfer past the limit, got %d", rec.Code)
+	}
+	if _, err := d.GetChunkInfo("chunk-1"); err == nil {
+		t.Error("a refused chunk should not be recorded")
+	}
+
+	// Peers learn the limit this node announces
+	if d.network.LocalNode.CapacityBytes != 8 {
+		t.Errorf("expected the local node to announce its limit, got %d", d.network.LocalNode.CapacityBytes)
+	}
+	if c := d.Capacity(); c.LimitBytes != 8 || c.Full {
+		t.Errorf("unexpected local capacity %+v", c)
+	}
+}
+
+func TestCapacityLimitIsSizedAgainstTheStore(t *testing.T) {
+	local, err := storage.NewLocalStorage(filepath.Join(t.TempDir(), "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	disk, err := storage.DiskSize(local.BasePath())
+	if err != nil {
+		t.Skipf("disk size unavailable: %v", err)
+	}
+
+	cfg := &config.AppConfig{NodeCapacityBytes: 1 << 40, NodeCapacityPercent: 50}
+	limit, err := CapacityLimitFromConfig(cfg, local)
+	if err != nil || limit != min(disk/2, 1<<40) {
+		t.Errorf("expected half of the store's disk, got %d (%v)", limit, err)
+	}
+
+	// A backend without a local directory keeps the byte limit but cannot size a percentage
+	remote := struct{ storage.Storage }{local}
+	if limit, err := CapacityLimitFromConfig(cfg, remote); err == nil || limit != 1<<40 {
+		t.Errorf("expected the byte limit and an error for a percentage, got %d (%v)", limit, err)
+	}
+	cfg.NodeCapacityPercent = 0
+	if limit, err := CapacityLimitFromConfig(cfg, remote); err != nil || limit != 1<<40 {
+		t.Errorf("expected the byte limit, got %d (%v)", limit, err)
+	}
+}
@@ -0,0 +1,167 @@
+package distributor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// newReceivingPeer serves a second distributor's chunk transfers and records
+// the wire encoding of each transfer it receives
+func newReceivingPeer(t *testing.T) (*Distributor, *httptest.Server, func() []string) {
+	t.Helper()
+	store, err := storage.NewLocalStorage(filepath.Join(t.TempDir(), "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	receiver := NewDistributor(p2p.NewNetwork("127.0.0.1", 0), store, nil)
+
+	var mu sync.Mutex
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chunk-transfer" {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Encoding string `json:"encoding"`
+		}
+		json.Unmarshal(body, &req)
+		mu.Lock()
+		encodings = append(encodings, req.Encoding)
+		mu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		receiver.HandleChunkTransfer(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return receiver, server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), encodings...)
+	}
+}
+
+func registerServer(d *Distributor, id string, server *httptest.Server, capabilities []string) {
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	d.network.RegisterPeer(&p2p.Node{ID: id, Address: host, Port: port, Status: "online", LastSeen: time.Now(),
+		Capabilities: capabilities})
+}
+
+func TestReplicationForwardsStoredBytes(t *testing.T) {
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunk-transfer" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	})
+	d.network.RemovePeer("peer-1")
+	receiver, server, encodings := newReceivingPeer(t)
+	registerServer(d, "peer-2", server, receiver.network.LocalNode.Capabilities)
+	if err := d.network.SetWireCodecs([]string{p2p.WireGzip, p2p.WireIdentity}); err != nil {
+		t.Fatalf("SetWireCodecs failed: %v", err)
+	}
+	d.SetDurability(DurabilityAll, 0)
+
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if got := encodings(); len(got) != len(file.Chunks) || got[0] != p2p.WireGzip {
+		t.Fatalf("expected every chunk sent gzipped, got %v", got)
+	}
+
+	for _, chunkID := range file.Chunks {
+		chunk, _ := d.GetChunkInfo(chunkID)
+		sent, err := d.readStoredChunk(chunk)
+		if err != nil {
+			t.Fatalf("failed to read the sender's chunk: %v", err)
+		}
+		reader, err := receiver.store.Get(chunk.StoredHash)
+		if err != nil {
+			t.Fatalf("receiver does not hold chunk %s under its stored hash: %v", chunkID, err)
+		}
+		kept, _ := io.ReadAll(reader)
+		reader.Close()
+		if !bytes.Equal(sent, kept) {
+			t.Errorf("chunk %s was re-transformed in transit", chunkID)
+		}
+	}
+}
+
+func TestReplicationToLegacyPeerUsesIdentity(t *testing.T) {
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunk-transfer" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	})
+	d.network.RemovePeer("peer-1")
+	_, server, encodings := newReceivingPeer(t)
+	// A peer announcing nothing predates wire codecs
+	registerServer(d, "peer-2", server, nil)
+	d.network.SetWireCodecs([]string{p2p.WireGzip})
+	d.SetDurability(DurabilityAll, 0)
+
+	if _, err := d.DistributeFile(inputPath, "pw"); err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	got := encodings()
+	if len(got) == 0 {
+		t.Fatal("no chunk reached the legacy peer")
+	}
+	for _, encoding := range got {
+		if encoding != p2p.WireIdentity {
+			t.Errorf("expected identity for a legacy peer, got %q", encoding)
+		}
+	}
+}
+
+func TestWireCodecRoundTrip(t *testing.T) {
+	stored := bytes.Repeat([]byte("stored chunk bytes "), 100)
+	for _, codec := range []string{p2p.WireIdentity, p2p.WireGzip} {
+		wire, err := p2p.EncodeWire(codec, stored)
+		if err != nil {
+			t.Fatalf("EncodeWire(%s) failed: %v", codec, err)
+		}
+		back, err := p2p.DecodeWire(codec, wire)
+		if err != nil || !bytes.Equal(back, stored) {
+			t.Errorf("%s did not round-trip: %v", codec, err)
+		}
+	}
+	if _, err := p2p.EncodeWire("brotli", stored); err == nil {
+		t.Error("expected an error for an unsupported codec")
+	}
+}
+
+func TestReadChunkLimitStopsOversizedChunks(t *testing.T) {
+	stored := bytes.Repeat([]byte{0}, 4096)
+	response := func(codec string) *http.Response {
+		wire, _ := p2p.EncodeWire(codec, stored)
+		header := http.Header{}
+		if codec != p2p.WireIdentity {
+			header.Set(p2p.ChunkEncodingHeader, codec)
+		}
+		return &http.Response{Header: header, ContentLength: -1, Body: io.NopCloser(bytes.NewReader(wire))}
+	}
+
+	for _, codec := range []string{p2p.WireIdentity, p2p.WireGzip} {
+		if data, err := p2p.ReadChunkLimit(response(codec), int64(len(stored))); err != nil || !bytes.Equal(data, stored) {
+			t.Errorf("%s chunk within the limit was not read: %v", codec, err)
+		}
+		// A gzip body is small on the wire, so the limit must also hold after unwrapping
+		if _, err := p2p.ReadChunkLimit(response(codec), int64(len(stored))-1); err == nil {
+			t.Errorf("expected a %s chunk over the limit to be refused", codec)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package distributor
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// writeChunkedInput writes a file of the given number of minimum-size chunks
+func writeChunkedInput(t testing.TB, chunks int) string {
+	t.Helper()
+	var content bytes.Buffer
+	for i := 0; content.Len() < chunks*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "line %d of a file replicated in parallel\n", i)
+	}
+	input := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(input, content.Bytes()[:chunks*int(chunker.MinChunkSize)], 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	return input
+}
+
+// slowPeer answers chunk transfers after a delay and tracks how many it
+// handles at once
+func slowPeer(delay time.Duration, inFlight, peak *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chunk-transfer" {
+			http.NotFound(w, r)
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestDistributionStaysWithinConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	d, _ := newTestDistributor(t, slowPeer(20*time.Millisecond, &inFlight, &peak))
+	config.Config.ChunkSize = chunker.MinChunkSize
+	t.Cleanup(func() { config.Config = &config.AppConfig{ParallelismRatio: 2} })
+	d.SetDurability(DurabilityAll, 0)
+	d.SetConcurrency(3)
+
+	file, err := d.DistributeFile(writeChunkedInput(t, 12), "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if got := peak.Load(); got != 3 {
+		t.Errorf("expected 3 chunk transfers at once, peaked at %d", got)
+	}
+	if len(file.ChunkResults) != 12 || file.AchievedReplicas != 2 {
+		t.Errorf("expected 12 chunks with 2 copies each, got %d chunks with %d copies", len(file.ChunkResults), file.AchievedReplicas)
+	}
+}
+
+func TestParallelDistributionReachesReplicaCount(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	_, nodes := newMemoryCluster(t, "node-a", "node-b", "node-c", "node-d")
+	a := nodes["node-a"]
+	a.SetReplicaCount(3)
+	a.SetDurability(DurabilityAll, 0)
+	a.SetConcurrency(8)
+
+	file, err := a.DistributeFile(writeChunkedInput(t, 24), "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if len(file.Chunks) != 24 || file.AchievedReplicas != 3 {
+		t.Fatalf("expected 24 chunks with 3 copies each, got %d chunks with %d copies", len(file.Chunks), file.AchievedReplicas)
+	}
+	for i, chunkID := range file.Chunks {
+		chunk, err := a.GetChunkInfo(chunkID)
+		if err != nil {
+			t.Fatalf("chunk %d missing: %v", i, err)
+		}
+		holders := make(map[string]bool)
+		for _, node := range chunk.Nodes {
+			holders[node] = true
+		}
+		if len(chunk.Nodes) != 3 || len(holders) != 3 {
+			t.Errorf("chunk %d is on %v, expected 3 distinct nodes", i, chunk.Nodes)
+		}
+		if result := file.ChunkResults[i]; !result.Complete() || result.ChunkID != chunkID {
+			t.Errorf("chunk %d result %+v, expected complete", i, result)
+		}
+	}
+}
+
+// BenchmarkDistributeFile replicates a 32-chunk file to a peer that takes a
+// few milliseconds per chunk, one chunk at a time and several at once. Server
+// keys stand in for a password so key derivation doesn't dominate, and
+// repeat uploads reuse the stored chunks, leaving replication to measure.
+func BenchmarkDistributeFile(b *testing.B) {
+	for _, concurrency := range []int{1, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			var inFlight, peak atomic.Int32
+			d, _ := newTestDistributor(b, slowPeer(2*time.Millisecond, &inFlight, &peak))
+			config.Config.ChunkSize = chunker.MinChunkSize
+			defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+			d.SetDurability(DurabilityAll, 0)
+			d.SetConcurrency(concurrency)
+			km, err := encryptor.NewKeyManager(bytes.Repeat([]byte{5}, 32))
+			if err != nil {
+				b.Fatalf("failed to create key manager: %v", err)
+			}
+			d.SetKeyManager(km)
+			metaStore, err := metadata.OpenMetadataStore(filepath.Join(b.TempDir(), "db"))
+			if err != nil {
+				b.Fatalf("failed to open metadata store: %v", err)
+			}
+			defer metaStore.Close()
+			d.metaStore = metaStore
+			input := writeChunkedInput(b, 32)
+
+			b.SetBytes(32 * chunker.MinChunkSize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := d.DistributeFile(input, ""); err != nil {
+					b.Fatalf("DistributeFile failed: %v", err)
+				}
+			}
+		})
+	}
+}
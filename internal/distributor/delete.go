@@ -0,0 +1,107 @@
+package distributor
+
+import (
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// MessageFileDeleted tells peers a file was deleted, so they drop their replicas of it
+const MessageFileDeleted = "file_deleted"
+
+// FileDeletion names a deleted file and the chunks peers should drop
+type FileDeletion struct {
+	FileID string   `json:"file_id"`
+	Chunks []string `json:"chunks"`
+}
+
+// RemoveFile forgets a deleted file and tells peers to remove their replicas
+// of its chunks. The local copies are left to the caller, which knows which
+// of them other files still reference. It returns how many chunks the file had.
+func (d *Distributor) RemoveFile(fileID string) int {
+	deletion := FileDeletion{FileID: fileID, Chunks: d.fileChunkIDs(fileID)}
+	d.ForgetFile(fileID)
+	d.network.RemoveFileFromNodes(fileID, deletion.Chunks)
+	d.forgetAvailability(fileID)
+
+	d.network.BroadcastMessage(&p2p.NetworkMessage{
+		Type:      MessageFileDeleted,
+		From:      d.network.LocalNode.ID,
+		To:        "",
+		Data:      deletion,
+		Timestamp: time.Now(),
+	})
+	return len(deletion.Chunks)
+}
+
+// fileChunkIDs returns the IDs of the chunks recorded for a file
+func (d *Distributor) fileChunkIDs(fileID string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	ids := make([]string, 0)
+	for id, chunk := range d.chunks {
+		if chunk.FileID == fileID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// dropFile forgets a file a peer deleted and removes the replicas this node
+// stored for it. Only this node's own records say which bytes those are, and
+// bytes another chunk record or the metadata store still points at are kept.
+// It returns how many replicas were removed.
+func (d *Distributor) dropFile(deletion FileDeletion) int {
+	d.mu.Lock()
+	var dropped []*ChunkInfo
+	for _, chunk := range d.chunks {
+		if chunk.FileID == deletion.FileID {
+			dropped = append(dropped, chunk)
+		}
+	}
+	delete(d.files, deletion.FileID)
+	chunkIDs := append([]string(nil), deletion.Chunks...)
+	for _, chunk := range dropped {
+		delete(d.chunks, chunk.ID)
+		chunkIDs = append(chunkIDs, chunk.ID)
+	}
+	inUse := make(map[string]bool, len(d.chunks))
+	for _, chunk := range d.chunks {
+		inUse[chunk.StoredHash] = true
+	}
+	d.mu.Unlock()
+
+	d.network.RemoveFileFromNodes(deletion.FileID, chunkIDs)
+	d.forgetAvailability(deletion.FileID)
+
+	deleter, ok := d.store.(storage.Deleter)
+	if !ok {
+		return 0
+	}
+	removed := 0
+	for _, chunk := range dropped {
+		if chunk.StoredHash == "" || inUse[chunk.StoredHash] || !d.storesChunk(chunk.StoredHash) {
+			continue
+		}
+		if d.metaStore != nil {
+			if recorded, err := d.metaStore.GetChunkMetadata(chunk.Hash); err == nil && recorded.Path == chunk.StoredHash {
+				continue
+			}
+		}
+		if err := deleter.Delete(chunk.StoredHash); err != nil {
+			continue
+		}
+		inUse[chunk.StoredHash] = true // Deleted once, even when several records named it
+		removed++
+	}
+	return removed
+}
+
+// forgetAvailability drops the cached availability of a deleted file
+func (d *Distributor) forgetAvailability(fileID string) {
+	d.availabilityMu.Lock()
+	defer d.availabilityMu.Unlock()
+	delete(d.availabilityCache, fileID)
+}
@@ -0,0 +1,87 @@
+package distributor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+)
+
+func TestRemoveFileDropsPeerReplicas(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	_, nodes := newMemoryCluster(t, "node-a", "node-b", "node-c")
+	a := nodes["node-a"]
+	a.SetReplicaCount(3)
+	a.SetDurability(DurabilityAll, 0)
+
+	file, err := a.DistributeFile(writeChunkedInput(t, 3), "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+
+	// The announcement is broadcast in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for _, name := range []string{"node-b", "node-c"} {
+		for {
+			if _, err := nodes[name].GetFileInfo(file.ID); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s never learned about the file", name)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	stored := make(map[string][]string)
+	for name, d := range nodes {
+		for _, chunkID := range file.Chunks {
+			chunk, err := d.GetChunkInfo(chunkID)
+			if err != nil || !d.storesChunk(chunk.StoredHash) {
+				t.Fatalf("%s does not hold chunk %s", name, chunkID)
+			}
+			stored[name] = append(stored[name], chunk.StoredHash)
+		}
+	}
+
+	// The nodes share one metadata store, so drop the chunk records as
+	// node-a's purge would before telling the others
+	for _, chunkID := range file.Chunks {
+		chunk, _ := a.GetChunkInfo(chunkID)
+		if err := a.metaStore.DeleteChunkMetadata(chunk.Hash); err != nil {
+			t.Fatalf("failed to delete chunk record: %v", err)
+		}
+	}
+	if got := a.RemoveFile(file.ID); got != len(file.Chunks) {
+		t.Errorf("expected peers told about %d chunks, got %d", len(file.Chunks), got)
+	}
+	if _, err := a.GetFileInfo(file.ID); err == nil {
+		t.Error("node-a still knows the deleted file")
+	}
+	if !a.storesChunk(stored["node-a"][0]) {
+		t.Error("RemoveFile should leave node-a's own copies to the caller")
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for _, name := range []string{"node-b", "node-c"} {
+		d := nodes[name]
+		for {
+			_, err := d.GetFileInfo(file.ID)
+			held := 0
+			for _, hash := range stored[name] {
+				if d.storesChunk(hash) {
+					held++
+				}
+			}
+			if err != nil && held == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s still holds %d replicas of the deleted file", name, held)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
@@ -2,26 +2,37 @@ package distributor
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/budget"
 	"github.com/jaywantadh/DisktroByte/internal/chunker"
 	"github.com/jaywantadh/DisktroByte/internal/compressor"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
+	"github.com/jaywantadh/DisktroByte/internal/failures"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
 	"github.com/jaywantadh/DisktroByte/internal/p2p"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/telemetry"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
 )
 
 // FileInfo represents information about a distributed file
 type FileInfo struct {
 	ID         string    `json:"id"`
+	Hash       string    `json:"hash"` // SHA-256 of the file's content
 	Name       string    `json:"name"`
 	Size       int64     `json:"size"`
 	Chunks     []string  `json:"chunks"`
@@ -30,22 +41,56 @@ type FileInfo struct {
 	Owner      string    `json:"owner"`
 	Compressed bool      `json:"compressed"`
 	Encrypted  bool      `json:"encrypted"`
-	Nodes      []string  `json:"nodes"` // List of nodes that have this file
+	KeyMode    string    `json:"key_mode"`   // "password" or "server-managed"
+	Redundancy string    `json:"redundancy"` // "replication" or "erasure"
+	Nodes      []string  `json:"nodes"`      // List of nodes that have this file
+	// AchievedReplicas is the lowest copy count across chunks when DistributeFile returned
+	AchievedReplicas int `json:"achieved_replicas"`
+	// PlacementViolations lists replicas the placement constraint left unplaced
+	PlacementViolations []string `json:"placement_violations,omitempty"`
+	// VerifiedAt is when the upload was reconstructed and checked, zero when it was not
+	VerifiedAt time.Time `json:"verified_at,omitempty"`
+	// ChunkResults reports how replicating each chunk went, by index. With
+	// local durability they fill in as replication finishes in the background.
+	ChunkResults []ChunkResult `json:"chunk_results,omitempty"`
+}
+
+// ChunkResult is the outcome of replicating one chunk of a file
+type ChunkResult struct {
+	Index    int      `json:"index"`
+	ChunkID  string   `json:"chunk_id"`
+	Copies   int      `json:"copies"`             // Copies stored, the local one included; 0 while replication runs
+	Wanted   int      `json:"wanted"`             // Copies the file asked for
+	Failures []string `json:"failures,omitempty"` // Why each peer that was tried did not take a replica
+}
+
+// Complete reports whether the chunk reached the copies it asked for
+func (r ChunkResult) Complete() bool {
+	return r.Copies >= r.Wanted
 }
 
 // ChunkInfo represents information about a chunk
 type ChunkInfo struct {
-	ID        string    `json:"id"`
-	FileID    string    `json:"file_id"`
-	Index     int       `json:"index"`
-	Size      int64     `json:"size"`
-	Hash      string    `json:"hash"`
-	Nodes     []string  `json:"nodes"` // List of nodes that have this chunk
-	Replicas  int       `json:"replicas"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string    `json:"id"`
+	FileID     string    `json:"file_id"`
+	Index      int       `json:"index"`
+	Size       int64     `json:"size"`
+	Hash       string    `json:"hash"`
+	StoredHash string    `json:"stored_hash"` // SHA-256 of the encrypted bytes (their storage ID), checked on peer transfers
+	Nodes      []string  `json:"nodes"`       // List of nodes that have this chunk
+	Replicas   int       `json:"replicas"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
-// Distributor manages file distribution across the P2P network
+// Distributor manages file distribution across the P2P network.
+//
+// Locking: mu guards files, chunks and every FileInfo and ChunkInfo they
+// point to, as well as the settings below it. Records are only mutated with
+// mu held and never handed out; readers get copies taken under one read lock,
+// so listings are consistent snapshots. Network and storage calls are made
+// without mu held. availabilityMu guards the availability cache alone, and
+// keyManager and failureLog are set once at startup before any file is
+// distributed.
 type Distributor struct {
 	network      *p2p.Network
 	store        storage.Storage
@@ -54,59 +99,295 @@ type Distributor struct {
 	chunks       map[string]*ChunkInfo
 	mu           sync.RWMutex
 	replicaCount int
+	keyManager   *encryptor.KeyManager
+	failureLog   *failures.Log
+	durability   DurabilityLevel
+	minCopies    int
+	// Largest fraction of a file's chunks replicated to one peer, 0 for no cap
+	maxNodeFraction float64
+	zoneSpread      bool // Place a chunk's replicas in distinct zones, then racks, first
+	redundancy      RedundancyPolicy
+	verifyOnUpload  bool // Round-trip every upload before acknowledging it
+	transportPolicy TransportPolicy
+	tcpTransport    http.RoundTripper // Carries requests to peers that advertise TCP, nil when unavailable
+	peerTransports  map[string]string // Transport the last successful request to each peer used
+
+	availabilityCache map[string]cachedAvailability
+	availabilityTTL   time.Duration
+	availabilityMu    sync.Mutex
+
+	negative *negativeCache // Peers that recently answered they lack a chunk
+
+	corruptTransfers map[string]int // Chunk transfers per peer that failed verification
+	latencyAware     bool           // Rank replica sources by measured round-trip time as well
+
+	replicaChecks     map[string]map[string]replicaCheck // Last verification of each chunk's replica on each node
+	replicaFreshness  time.Duration                      // How long a verification keeps a replica preferred, 0 ignores them
+	verifyStaleOnRead bool                               // Reads verify the stale replicas they pass over
+	verifying         map[string]bool                    // Chunk/node pairs with a background verification running
+
+	draining map[string]bool // Nodes being drained, which take no new chunks
+
+	retry       RetryPolicy    // How failed chunk transfers to peers are retried
+	concurrency int            // Chunks replicated at once, 0 sizes the pool from the parallelism ratio
+	replicating sync.WaitGroup // Replication left running by uploads at local durability
+
+	haveNeed HaveNeedStats // Whether replication negotiates have/need, and what it saved
+
+	capacity   capacityState
+	capacityMu sync.Mutex
 }
 
 // NewDistributor creates a new file distributor
 func NewDistributor(network *p2p.Network, store storage.Storage, metaStore *metadata.MetadataStore) *Distributor {
-	return &Distributor{
+	d := &Distributor{
 		network:      network,
 		store:        store,
 		metaStore:    metaStore,
 		files:        make(map[string]*FileInfo),
 		chunks:       make(map[string]*ChunkInfo),
 		replicaCount: 3, // Default replica count
+		durability:   DurabilityLocal,
+		minCopies:    1,
+
+		maxNodeFraction: DefaultMaxNodeFraction,
+		redundancy:      DefaultRedundancyPolicy(),
+		transportPolicy: DefaultTransportPolicy(),
+		peerTransports:  make(map[string]string),
+
+		availabilityCache: make(map[string]cachedAvailability),
+		availabilityTTL:   DefaultAvailabilityTTL,
+
+		negative: newNegativeCache(DefaultNegativeCacheTTL, DefaultNegativeCacheMaxEntries),
+
+		corruptTransfers: make(map[string]int),
+		replicaChecks:    make(map[string]map[string]replicaCheck),
+		verifying:        make(map[string]bool),
+		draining:         make(map[string]bool),
+		retry:            DefaultRetryPolicy(),
 	}
+	if network != nil {
+		announceChunkFormats(network)
+	}
+	return d
+}
+
+// SetKeyManager enables server-managed keys for files distributed without a password
+func (d *Distributor) SetKeyManager(km *encryptor.KeyManager) {
+	d.keyManager = km
+}
+
+// SetFailureLog records failed uploads, replications and reassemblies in log
+func (d *Distributor) SetFailureLog(log *failures.Log) {
+	d.failureLog = log
+}
+
+// recordFailure adds err to the failure log, if one is set, and returns it
+func (d *Distributor) recordFailure(ctx context.Context, f failures.Failure, err error) error {
+	if recErr := d.failureLog.Record(ctx, f, err); recErr != nil {
+		tracing.Logf(ctx, "⚠️ Failed to record %s failure: %v", f.Operation, recErr)
+	}
+	return err
+}
+
+// HasServerKeys reports whether server-managed keys are configured
+func (d *Distributor) HasServerKeys() bool {
+	return d.keyManager != nil
 }
 
 // SetReplicaCount sets the number of replicas for each chunk
 func (d *Distributor) SetReplicaCount(count int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.replicaCount = count
 }
 
+// SetConcurrency sets how many chunks of a file are replicated at once. Zero
+// or less sizes the pool from the configured parallelism ratio.
+func (d *Distributor) SetConcurrency(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.concurrency = n
+}
+
+// GetConcurrency returns how many chunks of a file are replicated at once
+func (d *Distributor) GetConcurrency() int {
+	d.mu.RLock()
+	n := d.concurrency
+	d.mu.RUnlock()
+	if n > 0 {
+		return n
+	}
+
+	ratio := 2
+	if config.Config != nil && config.Config.ParallelismRatio > 0 {
+		ratio = config.Config.ParallelismRatio
+	}
+	n = runtime.NumCPU() / ratio
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// getReplicaCount returns the configured number of replicas for each chunk
+func (d *Distributor) getReplicaCount() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.replicaCount
+}
+
+// clone returns a copy of the file record that shares no slices with it
+func (f *FileInfo) clone() *FileInfo {
+	copied := *f
+	copied.Chunks = append([]string(nil), f.Chunks...)
+	copied.Nodes = append([]string(nil), f.Nodes...)
+	copied.PlacementViolations = append([]string(nil), f.PlacementViolations...)
+	copied.ChunkResults = append([]ChunkResult(nil), f.ChunkResults...)
+	for i := range copied.ChunkResults {
+		copied.ChunkResults[i].Failures = append([]string(nil), f.ChunkResults[i].Failures...)
+	}
+	return &copied
+}
+
+// clone returns a copy of the chunk record that shares no slices with it
+func (c *ChunkInfo) clone() *ChunkInfo {
+	copied := *c
+	copied.Nodes = append([]string(nil), c.Nodes...)
+	return &copied
+}
+
 // DistributeFile distributes a file across the P2P network
 func (d *Distributor) DistributeFile(filePath, password string) (*FileInfo, error) {
-	fileName := filepath.Base(filePath)
+	return d.DistributeFileContext(context.Background(), filePath, password)
+}
+
+// DistributeFileContext works like DistributeFile. Log lines, errors and chunk
+// transfers to peers carry the context's trace ID.
+func (d *Distributor) DistributeFileContext(ctx context.Context, filePath, password string) (*FileInfo, error) {
+	return d.DistributeFileWithRedundancy(ctx, filePath, password, "")
+}
+
+// DistributeFileWithRedundancy works like DistributeFileContext but overrides
+// the redundancy policy's choice with scheme unless it is empty.
+func (d *Distributor) DistributeFileWithRedundancy(ctx context.Context, filePath, password, scheme string) (*FileInfo, error) {
+	return d.DistributeFileWithOptions(ctx, filePath, UploadOptions{Password: password, Redundancy: scheme})
+}
+
+// UploadOptions override the distributor's defaults for a single upload
+type UploadOptions struct {
+	Password   string
+	Redundancy string // Scheme to use instead of the policy's size-based choice, "" to let it choose
+	Replicas   int    // Copies of each chunk of a replicated file, 0 for the configured count
+	OwnerID    string // Uploading user, whose default placement constraint applies
+	// Placement is stored as the file's own constraint, overriding the owner's default
+	Placement *metadata.PlacementConstraint
+	// Verify reconstructs the file from its stored chunks before returning,
+	// as SetVerifyOnUpload does for every upload
+	Verify bool
+	// StorageClass picks the storage tier the chunks are written to, "" for the primary tier
+	StorageClass string
+}
+
+// DistributeFileWithOptions works like DistributeFileContext with per-upload
+// overrides. Erasure-coded files keep the policy's copy count regardless of
+// opts.Replicas.
+func (d *Distributor) DistributeFileWithOptions(ctx context.Context, filePath string, opts UploadOptions) (*FileInfo, error) {
+	return d.DistributeSourceWithOptions(ctx, chunker.FileSource(filePath), opts)
+}
+
+// DistributeSourceWithOptions works like DistributeFileWithOptions for
+// content that need not be a file on disk, such as an upload held in memory
+func (d *Distributor) DistributeSourceWithOptions(ctx context.Context, src chunker.Source, opts UploadOptions) (info *FileInfo, err error) {
+	ctx, span := telemetry.Start(ctx, telemetry.OpDistribute, telemetry.Attrs{"file.name": src.Name()})
+	defer func() { span.End(err) }()
+
+	fileName := src.Name()
+	password, scheme := opts.Password, opts.Redundancy
 
 	// Calculate file ID as SHA-256 hash of the entire file (consistent with chunker)
-	fileID, err := chunker.CalculateFileHash(filePath)
+	fileID, err := chunker.HashSource(src)
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate file ID: %v", err)
+		return nil, tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "hash"},
+			fmt.Errorf("failed to calculate file ID: %v", err)))
 	}
+	src = chunker.HashedSource(src, fileID)
 
 	// Get file size
-	fileInfo, err := os.Stat(filePath)
+	fileSize, err := src.Size()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file info: %v", err)
+		return nil, tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "hash", FileID: fileID},
+			fmt.Errorf("failed to get file info: %v", err)))
+	}
+
+	// A node past its capacity limit refuses new data rather than filling its disk
+	if err := d.reserveCapacity(fileSize); err != nil {
+		return nil, tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "capacity", FileID: fileID}, err))
+	}
+
+	// Small files are replicated whole, large ones erasure-coded
+	policy := d.GetRedundancyPolicy()
+	scheme = policy.Choose(fileSize, scheme)
+	replicas := d.getReplicaCount()
+	if opts.Replicas > 0 {
+		replicas = opts.Replicas
+	}
+	if scheme == metadata.RedundancyErasure {
+		replicas = policy.ErasureCopies
 	}
 
 	// Create file record
 	file := &FileInfo{
 		ID:         fileID,
+		Hash:       fileID,
 		Name:       fileName,
-		Size:       fileInfo.Size(),
+		Size:       fileSize,
 		Chunks:     make([]string, 0),
-		Replicas:   d.replicaCount,
+		Replicas:   replicas,
 		CreatedAt:  time.Now(),
 		Owner:      d.network.LocalNode.ID,
 		Compressed: false,
 		Encrypted:  true,
+		KeyMode:    metadata.KeyModePassword,
+		Redundancy: scheme,
 		Nodes:      []string{d.network.LocalNode.ID},
 	}
 
-	// Chunk the file
-	chunkMetadata, err := chunker.ChunkAndStore(filePath, password, d.metaStore, d.store)
+	// Replicas only go to nodes the file's placement constraint approves
+	placement, err := d.planPlacement(file, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to chunk file: %v", err)
+		return nil, tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "placement", FileID: fileID}, err))
+	}
+	if placement != nil && !placement.constraint.Allows(d.network.LocalNode.ID, d.network.LocalNode.Labels) {
+		placement.violation(ctx, fmt.Sprintf("the uploading node %s is not approved but keeps the original copy", d.network.LocalNode.ID))
+	}
+
+	// A failed upload puts back the record of an identical file it replaced
+	var prior *metadata.FileMetadata
+	if d.metaStore != nil {
+		if existing, err := d.metaStore.GetFileMetadataByID(fileID); err == nil {
+			prior = &existing
+		}
+	}
+
+	// Chunk the file, falling back to server-managed keys when no password is given
+	store := storage.ForClass(d.store, opts.StorageClass)
+	var chunkMetadata []chunker.ChunkMetadata
+	if password == "" && d.keyManager != nil {
+		file.KeyMode = metadata.KeyModeServerManaged
+		chunkMetadata, err = chunker.ChunkAndStoreSourceWithServerKeyContext(ctx, src, d.keyManager, d.metaStore, store)
+	} else {
+		chunkMetadata, err = chunker.ChunkAndStoreSourceContext(ctx, src, password, d.metaStore, store)
+	}
+	if err != nil {
+		return nil, tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "chunk", FileID: fileID},
+			fmt.Errorf("failed to chunk file: %v", err)))
+	}
+	if scheme == metadata.RedundancyErasure {
+		if _, err := chunker.ProtectWithParity(fileID, policy.DataShards, policy.ParityShards, d.metaStore, store); err != nil {
+			return nil, tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "erasure", FileID: fileID},
+				fmt.Errorf("failed to erasure-code file: %v", err)))
+		}
 	}
 
 	// Check if file should be compressed
@@ -116,32 +397,87 @@ func (d *Distributor) DistributeFile(filePath, password string) (*FileInfo, erro
 		// Note: Compression would be applied during chunking
 	}
 
-	// Process each chunk
+	// Process each chunk; non-local durability levels wait for replica acknowledgments
+	level, minCopies := d.GetDurability()
+	required := requiredCopies(level, minCopies, replicas)
+
+	// Spread the file's replicas so no single peer ends up with most of its chunks
+	d.mu.RLock()
+	planner := newSpreadPlanner(len(chunkMetadata), d.maxNodeFraction, d.zoneSpread)
+	d.mu.RUnlock()
+	holdings := d.newPeerHoldings(fileID, chunkMetadata)
+	file.ChunkResults = make([]ChunkResult, len(chunkMetadata))
+
+	chunks := make([]*ChunkInfo, len(chunkMetadata))
 	for i, chunkMeta := range chunkMetadata {
 		chunkID := uuid.New().String()
 
 		chunk := &ChunkInfo{
-			ID:        chunkID,
-			FileID:    fileID,
-			Index:     i,
-			Size:      chunkMeta.Size,
-			Hash:      chunkMeta.Hash,
-			Nodes:     []string{d.network.LocalNode.ID},
-			Replicas:  d.replicaCount,
-			CreatedAt: time.Now(),
+			ID:         chunkID,
+			FileID:     fileID,
+			Index:      i,
+			Size:       chunkMeta.Size,
+			Hash:       chunkMeta.Hash,
+			StoredHash: chunkMeta.Path,
+			Nodes:      []string{d.network.LocalNode.ID},
+			Replicas:   replicas,
+			CreatedAt:  time.Now(),
 		}
 
 		// Store chunk info
 		d.mu.Lock()
 		d.chunks[chunkID] = chunk
 		file.Chunks = append(file.Chunks, chunkID)
+		file.ChunkResults[i] = ChunkResult{Index: i, ChunkID: chunkID, Wanted: replicas}
 		d.mu.Unlock()
 
 		// Add chunk to local node
 		d.network.AddChunkToNode(d.network.LocalNode.ID, chunkID)
+		chunks[i] = chunk
+	}
+
+	// Distribute chunks to other nodes; with local durability that happens in
+	// the background once the upload has passed its checks below
+	file.AchievedReplicas = 1
+	var replicationErr error
+	if level != DurabilityLocal && len(chunks) > 0 {
+		replicationErr = d.distributeChunks(ctx, file, chunks, chunkMetadata, planner, placement, holdings)
+
+		// A chunk short of replicas is reported rather than failing the others
+		file.AchievedReplicas = replicas
+		for _, result := range file.ChunkResults {
+			if result.Copies < file.AchievedReplicas {
+				file.AchievedReplicas = result.Copies
+			}
+		}
+		if short := shortChunks(file); short > 0 {
+			tracing.Logf(ctx, "⚠️ File '%s' has %d of %d chunks short of %d copies", fileName, short, len(chunkMetadata), replicas)
+		}
+	}
+
+	// A failed upload is neither listed nor announced, so a retry leaves no stale entry behind
+	if file.AchievedReplicas < required {
+		tracing.Logf(ctx, "❌ File '%s' reached %d of %d required copies (durability: %s)",
+			fileName, file.AchievedReplicas, required, level)
+		d.discardUpload(ctx, file, store, prior)
+		return nil, tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "durability", FileID: fileID},
+			errors.Join(fmt.Errorf("durability level %s not met: %d of %d required copies acknowledged", level, file.AchievedReplicas, required),
+				replicationErr)))
+	}
 
-		// Distribute chunk to other nodes
-		go d.distributeChunk(chunk, &chunkMeta)
+	// An upload that cannot be reconstructed fails now rather than at download
+	if opts.Verify || d.VerifyOnUpload() {
+		if err := d.verifyUpload(ctx, file, password); err != nil {
+			d.discardUpload(ctx, file, store, prior)
+			return nil, tracing.Error(ctx, err)
+		}
+	}
+	if level == DurabilityLocal {
+		d.replicating.Add(1)
+		go func() {
+			defer d.replicating.Done()
+			d.distributeChunks(ctx, file, chunks, chunkMetadata, planner, placement, holdings)
+		}()
 	}
 
 	// Store file info
@@ -155,35 +491,157 @@ func (d *Distributor) DistributeFile(filePath, password string) (*FileInfo, erro
 	// Broadcast file availability
 	d.broadcastFileAvailability(file)
 
-	fmt.Printf("📦 File '%s' distributed with %d chunks\n", fileName, len(chunkMetadata))
-	return file, nil
+	// With local durability replication is still running, so spread is only checked once it finished
+	if level != DurabilityLocal {
+		if spread, err := d.FileSpread(fileID); err == nil {
+			for _, violation := range spread.Violations {
+				tracing.Logf(ctx, "⚠️ File '%s' spread: %s", fileName, violation)
+			}
+		}
+	}
+
+	tracing.Logf(ctx, "📦 File '%s' distributed with %d chunks (%d copies, %s, durability: %s)",
+		fileName, len(chunkMetadata), file.AchievedReplicas, scheme, level)
+
+	// Replication may still be recording chunk results, so hand out a copy
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return file.clone(), nil
 }
 
-// distributeChunk distributes a chunk to multiple nodes for redundancy
-func (d *Distributor) distributeChunk(chunk *ChunkInfo, chunkMeta *chunker.ChunkMetadata) {
+// distributeChunks replicates chunks on a pool of at most the configured
+// concurrency of workers, recording each chunk's result in file. It returns
+// why every chunk left short of copies is short, joined into one error.
+func (d *Distributor) distributeChunks(ctx context.Context, file *FileInfo, chunks []*ChunkInfo, chunkMetadata []chunker.ChunkMetadata,
+	planner *spreadPlanner, placement *placementPlan, holdings *peerHoldings) error {
+	workers := d.GetConcurrency()
+	if workers > len(chunks) {
+		workers = len(chunks)
+	}
+
+	next := make(chan int)
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				result := d.distributeChunk(ctx, chunks[i], &chunkMetadata[i], planner, placement, holdings)
+				d.recordChunkResult(file, i, result)
+				if result.Complete() {
+					continue
+				}
+				reason := "no approved peer was available"
+				if len(result.Failures) > 0 {
+					reason = strings.Join(result.Failures, "; ")
+				}
+				errs[i] = fmt.Errorf("chunk %d reached %d of %d copies: %s", i, result.Copies, result.Wanted, reason)
+			}
+		}()
+	}
+	for i := range chunks {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// WaitForReplication blocks until the replication that uploads at local
+// durability left running in the background has finished
+func (d *Distributor) WaitForReplication() {
+	d.replicating.Wait()
+}
+
+// recordChunkResult stores the outcome of replicating chunk index of file
+func (d *Distributor) recordChunkResult(file *FileInfo, index int, result ChunkResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	file.ChunkResults[index] = result
+}
+
+// discardUpload undoes an upload that failed before its file was registered:
+// its chunk records here, and the metadata records and stored chunks the
+// chunker wrote, so it is neither listed nor leaks chunks. An identical file
+// the upload replaced, prior, is put back; chunks other files share stay.
+func (d *Distributor) discardUpload(ctx context.Context, file *FileInfo, store storage.Storage, prior *metadata.FileMetadata) {
+	d.mu.Lock()
+	for _, chunkID := range file.Chunks {
+		delete(d.chunks, chunkID)
+	}
+	d.mu.Unlock()
+	d.network.RemoveFileFromNodes(file.ID, file.Chunks)
+
+	report, err := chunker.DiscardUpload(d.metaStore, store, file.ID, prior)
+	if err != nil {
+		tracing.Logf(ctx, "⚠️ Failed to discard the failed upload of '%s': %v", file.Name, err)
+	}
+	for _, problem := range report.Errors {
+		tracing.Logf(ctx, "⚠️ Discarding the failed upload of '%s': %s", file.Name, problem)
+	}
+}
+
+// shortChunks counts the chunks of file that replication left short of copies
+func shortChunks(file *FileInfo) int {
+	short := 0
+	for _, result := range file.ChunkResults {
+		if !result.Complete() {
+			short++
+		}
+	}
+	return short
+}
+
+// distributeChunk distributes a chunk to multiple nodes for redundancy and
+// reports how many copies it reached and why any peer tried did not take
+// one. The planner picks peers so the file's chunks stay spread out, among
+// those the placement approves, and with zone spread on away from the zones
+// the chunk already has a copy in. Peers that already store the chunk, per
+// holdings, are sent a reference.
+func (d *Distributor) distributeChunk(ctx context.Context, chunk *ChunkInfo, chunkMeta *chunker.ChunkMetadata, planner *spreadPlanner, placement *placementPlan, holdings *peerHoldings) ChunkResult {
 	peers := d.network.GetPeers()
 
 	// Sort peers by reliability (online status, last seen, etc.)
-	reliablePeers := d.getReliablePeers(peers)
+	reliablePeers := d.getReliablePeers(placement.filter(peers))
 
 	// Distribute to reliable peers
 	replicasCreated := 0
-	for _, peer := range reliablePeers {
-		if replicasCreated >= d.replicaCount-1 { // -1 because we already have it locally
+	result := ChunkResult{Index: chunk.Index, ChunkID: chunk.ID, Wanted: chunk.Replicas}
+	tried := make(map[string]bool)
+	placed := []*p2p.Node{d.network.LocalNode}
+	for replicasCreated < chunk.Replicas-1 { // -1 because we already have it locally
+		peer := planner.pick(reliablePeers, tried, placed)
+		if peer == nil {
 			break
 		}
+		tried[peer.ID] = true
 
-		if d.sendChunkToPeer(chunk, chunkMeta, peer) {
+		if err := d.sendChunkToPeer(ctx, chunk, chunkMeta, peer, holdings); err == nil {
 			replicasCreated++
+			placed = append(placed, peer)
+			d.mu.Lock()
 			chunk.Nodes = append(chunk.Nodes, peer.ID)
+			d.mu.Unlock()
 			d.network.AddChunkToNode(peer.ID, chunk.ID)
+		} else {
+			result.Failures = append(result.Failures, fmt.Sprintf("%s: %v", peer.ID, err))
+			planner.release(peer.ID)
 		}
 	}
 
-	fmt.Printf("🔄 Chunk %s distributed to %d nodes\n", chunk.ID, replicasCreated+1)
+	if replicasCreated < chunk.Replicas-1 {
+		placement.violation(ctx, fmt.Sprintf("chunk %d has %d of %d replicas; %d approved peers available",
+			chunk.Index, replicasCreated+1, chunk.Replicas, len(reliablePeers)))
+	}
+
+	tracing.Logf(ctx, "🔄 Chunk %s distributed to %d nodes", chunk.ID, replicasCreated+1)
+	result.Copies = replicasCreated + 1 // +1 for the local copy
+	return result
 }
 
-// getReliablePeers returns peers sorted by reliability
+// getReliablePeers returns the online peers with room for more chunks, the
+// ones with the most free capacity first. Peers being drained take no new chunks.
 func (d *Distributor) getReliablePeers(peers []*p2p.Node) []*p2p.Node {
 	// Simple reliability scoring based on status and last seen time
 	var reliablePeers []*p2p.Node
@@ -191,116 +649,218 @@ func (d *Distributor) getReliablePeers(peers []*p2p.Node) []*p2p.Node {
 	for _, peer := range peers {
 		if peer.Status == "online" {
 			// Check if peer was seen recently (within last 5 minutes)
-			if time.Since(peer.LastSeen) < 5*time.Minute {
+			if time.Since(peer.LastSeen) < 5*time.Minute && !d.peerFull(peer) && !d.Draining(peer.ID) {
 				reliablePeers = append(reliablePeers, peer)
 			}
 		}
 	}
 
+	// Fill the peers with the most room first
+	byFreeCapacity(reliablePeers)
 	return reliablePeers
 }
 
-// sendChunkToPeer sends a chunk to a specific peer
-func (d *Distributor) sendChunkToPeer(chunk *ChunkInfo, chunkMeta *chunker.ChunkMetadata, peer *p2p.Node) bool {
-	client := &http.Client{Timeout: 30 * time.Second}
+// sendChunkToPeer sends a chunk to a specific peer, as a reference when
+// holdings says the peer already stores its bytes, and returns why the peer
+// did not store it. Transient failures are retried per the retry policy. The
+// transfer carries the trace ID but not the context's cancellation, as
+// replication may outlive the request.
+func (d *Distributor) sendChunkToPeer(ctx context.Context, chunk *ChunkInfo, chunkMeta *chunker.ChunkMetadata, peer *p2p.Node, holdings *peerHoldings) (err error) {
+	ctx, span := telemetry.Start(ctx, telemetry.OpTransfer+".send", telemetry.Attrs{"chunk.id": chunk.ID, "peer.id": peer.ID})
+	referenced := false
+	defer func() {
+		if err == nil {
+			if !referenced {
+				span.AddBytes(chunk.Size)
+			}
+			d.recordChunkSent(chunk, referenced)
+		}
+		span.End(err)
+	}()
+	failure := failures.Failure{Operation: failures.OpReplicate, Stage: "send", FileID: chunk.FileID, NodeID: peer.ID}
 
 	// Create chunk transfer request
 	transferReq := map[string]interface{}{
-		"chunk_id":  chunk.ID,
-		"file_id":   chunk.FileID,
-		"index":     chunk.Index,
-		"size":      chunk.Size,
-		"hash":      chunk.Hash,
-		"from_node": d.network.LocalNode.ID,
+		"chunk_id":    chunk.ID,
+		"file_id":     chunk.FileID,
+		"index":       chunk.Index,
+		"size":        chunk.Size,
+		"hash":        chunk.Hash,
+		"stored_hash": chunk.StoredHash,
+		"from_node":   d.network.LocalNode.ID,
 	}
 
-	reqData, err := json.Marshal(transferReq)
-	if err != nil {
-		fmt.Printf("❌ Failed to marshal chunk transfer request: %v\n", err)
-		return false
+	// A peer that already stores the bytes records its own copy; one that
+	// no longer does refuses the reference and is sent them after all
+	if holdings.holds(ctx, d, peer, chunk.StoredHash) {
+		transferReq["referenced"] = true
+		if status, err := d.postChunkTransfer(ctx, peer, transferReq); err == nil && status == http.StatusOK {
+			referenced = true
+			d.negative.forget(chunk.ID, peer.ID)
+			tracing.Logf(ctx, "🔗 Chunk %s referenced on peer %s, which already stores it", chunk.ID, peer.ID)
+			return nil
+		}
+		delete(transferReq, "referenced")
 	}
 
-	// Send chunk data
-	url := fmt.Sprintf("http://%s:%d/chunk-transfer", peer.Address, peer.Port)
+	// The peer gets the stored bytes as they are, wrapped only for the hop
+	stored, err := d.readStoredChunk(chunk)
+	if err != nil {
+		tracing.Logf(ctx, "❌ Failed to read chunk %s for %s: %v", chunk.ID, peer.ID, err)
+		return d.recordFailure(ctx, failures.Failure{Operation: failures.OpReplicate, Stage: "read", FileID: chunk.FileID, NodeID: peer.ID}, err)
+	}
+	encoding, payload, err := d.encodeForPeer(stored, peer)
+	if err != nil {
+		tracing.Logf(ctx, "⚠️ Not sending chunk %s to %s: %v", chunk.ID, peer.ID, err)
+		return d.recordFailure(ctx, failure, err)
+	}
+	transferReq["data"] = payload
+	transferReq["encoding"] = encoding
+	transferReq["digest"] = p2p.ChunkDigest(stored)
 
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(reqData))
+	// Send chunk data, over the other transport if the peer can't be reached
+	// on the first, and again after a backoff if it still fails
+	status, err := d.postChunkTransferWithRetry(ctx, peer, transferReq)
 	if err != nil {
-		fmt.Printf("❌ Failed to send chunk to %s: %v\n", peer.ID, err)
-		return false
+		tracing.Logf(ctx, "❌ Failed to send chunk to %s: %v", peer.ID, err)
+		return d.recordFailure(ctx, failure, err)
+	}
+
+	if status == http.StatusOK {
+		d.negative.forget(chunk.ID, peer.ID)
+		tracing.Logf(ctx, "✅ Chunk %s sent to peer %s", chunk.ID, peer.ID)
+		return nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		fmt.Printf("✅ Chunk %s sent to peer %s\n", chunk.ID, peer.ID)
-		return true
+	if status == http.StatusInsufficientStorage {
+		// The peer is full; leave it out of placements until it has room again
+		d.markPeerFull(peer.ID)
+		tracing.Logf(ctx, "⚠️ Peer %s is full, not sending it chunk %s", peer.ID, chunk.ID)
+		return d.recordFailure(ctx, failure, fmt.Errorf("peer rejected chunk %s: status %d: %w", chunk.ID, status, ErrNodeFull))
 	}
 
-	fmt.Printf("⚠️ Failed to send chunk to %s: status %d\n", peer.ID, resp.StatusCode)
-	return false
+	tracing.Logf(ctx, "⚠️ Failed to send chunk to %s: status %d", peer.ID, status)
+	return d.recordFailure(ctx, failure, fmt.Errorf("peer rejected chunk %s: status %d", chunk.ID, status))
+}
+
+// postChunkTransfer posts a chunk transfer request to peer and returns the
+// status it answered with
+func (d *Distributor) postChunkTransfer(ctx context.Context, peer *p2p.Node, transferReq map[string]interface{}) (int, error) {
+	reqData, err := json.Marshal(transferReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal chunk transfer request: %v", err)
+	}
+
+	resp, err := d.doPeerRequest(ctx, peer, "/chunk-transfer", 30*time.Second, func(url string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(reqData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build chunk transfer to %s: %v", peer.ID, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		tracing.Inject(ctx, req)
+		return req, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
 }
 
 // ReassembleFile reassembles a file from distributed chunks
 func (d *Distributor) ReassembleFile(fileID, outputPath, password string) error {
-	d.mu.RLock()
-	file, exists := d.files[fileID]
-	d.mu.RUnlock()
+	return d.ReassembleFileContext(context.Background(), fileID, outputPath, password)
+}
 
-	if !exists {
-		return fmt.Errorf("file %s not found", fileID)
+// ReassembleFileContext works like ReassembleFile. Log lines, errors and chunk
+// downloads from peers carry the context's trace ID.
+func (d *Distributor) ReassembleFileContext(ctx context.Context, fileID, outputPath, password string) (err error) {
+	ctx, span := telemetry.Start(ctx, telemetry.OpReassemble, telemetry.Attrs{"file.id": fileID})
+	defer func() { span.End(err) }()
+
+	file, err := d.GetFileInfo(fileID)
+	if err != nil {
+		return tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpReassemble, Stage: "lookup", FileID: fileID}, err))
 	}
 
 	// Check if we have all chunks locally
 	missingChunks := d.findMissingChunks(file.Chunks)
 	if len(missingChunks) > 0 {
 		// Download missing chunks from peers
-		if err := d.downloadMissingChunks(missingChunks); err != nil {
-			return fmt.Errorf("failed to download missing chunks: %v", err)
+		if err := d.downloadMissingChunks(ctx, missingChunks); err != nil {
+			return tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpReassemble, Stage: "download", FileID: fileID},
+				fmt.Errorf("failed to download missing chunks: %v", err)))
 		}
 	}
 
 	// Reassemble the file using the file ID (which should be the SHA-256 hash)
-	err := chunker.ReassembleFile(file.ID, outputPath, password, d.metaStore, d.store)
+	if chunker.FileKeyMode(d.metaStore, file.ID) == metadata.KeyModeServerManaged {
+		err = chunker.ReassembleFileWithServerKeyContext(ctx, file.ID, outputPath, d.keyManager, d.metaStore, d.store)
+	} else {
+		err = chunker.ReassembleFileContext(ctx, file.ID, outputPath, password, d.metaStore, d.store)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to reassemble file: %v", err)
+		return tracing.Error(ctx, d.recordFailure(ctx, failures.Failure{Operation: failures.OpReassemble, Stage: "assemble", FileID: fileID},
+			fmt.Errorf("failed to reassemble file: %v", err)))
 	}
 
-	fmt.Printf("🔧 File '%s' reassembled successfully\n", file.Name)
+	tracing.Logf(ctx, "🔧 File '%s' reassembled successfully", file.Name)
 	return nil
 }
 
+// CheckPassword confirms a password decrypts a file without reassembling it,
+// fetching only the file's first chunk from a peer when it is not stored here.
+// A wrong password returns chunker.ErrWrongPassword.
+func (d *Distributor) CheckPassword(ctx context.Context, fileID, password string) error {
+	file, err := d.GetFileInfo(fileID)
+	if err != nil {
+		return err
+	}
+	if len(file.Chunks) == 0 {
+		return fmt.Errorf("file %s has no chunks", fileID)
+	}
+	// A file with a key canary is checked from its metadata, without the chunk
+	if missing := d.findMissingChunks(file.Chunks[:1]); len(missing) > 0 && !chunker.HasKeyCanary(d.metaStore, file.ID) {
+		if err := d.downloadMissingChunks(ctx, missing); err != nil {
+			return fmt.Errorf("failed to download the first chunk: %v", err)
+		}
+	}
+	return chunker.CheckPassword(file.ID, password, d.metaStore, d.store)
+}
+
 // findMissingChunks finds chunks that are not available locally
 func (d *Distributor) findMissingChunks(chunkIDs []string) []string {
 	var missingChunks []string
 
 	for _, chunkID := range chunkIDs {
-		d.mu.RLock()
-		chunk, exists := d.chunks[chunkID]
-		d.mu.RUnlock()
-
-		if !exists {
+		if _, local := d.localChunk(chunkID); !local {
 			missingChunks = append(missingChunks, chunkID)
-			continue
 		}
+	}
 
-		// Check if we have this chunk locally
-		hasChunk := false
-		for _, nodeID := range chunk.Nodes {
-			if nodeID == d.network.LocalNode.ID {
-				hasChunk = true
-				break
-			}
-		}
+	return missingChunks
+}
 
-		if !hasChunk {
-			missingChunks = append(missingChunks, chunkID)
+// localChunk returns a copy of the chunk record and whether this node holds the chunk
+func (d *Distributor) localChunk(chunkID string) (*ChunkInfo, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	chunk, exists := d.chunks[chunkID]
+	if !exists {
+		return nil, false
+	}
+	for _, nodeID := range chunk.Nodes {
+		if nodeID == d.network.LocalNode.ID {
+			return chunk.clone(), true
 		}
 	}
-
-	return missingChunks
+	return chunk.clone(), false
 }
 
-// downloadMissingChunks downloads missing chunks from peers
-func (d *Distributor) downloadMissingChunks(chunkIDs []string) error {
+// downloadMissingChunks downloads missing chunks from peers, moving on to the
+// next replica when a download fails or its data does not verify
+func (d *Distributor) downloadMissingChunks(ctx context.Context, chunkIDs []string) error {
 	for _, chunkID := range chunkIDs {
 		// Find nodes that have this chunk
 		nodes := d.network.FindNodesWithChunk(chunkID)
@@ -308,45 +868,95 @@ func (d *Distributor) downloadMissingChunks(chunkIDs []string) error {
 			return fmt.Errorf("no nodes have chunk %s", chunkID)
 		}
 
-		// Try to download from the first available node
-		if err := d.downloadChunkFromNode(chunkID, nodes[0]); err != nil {
-			fmt.Printf("⚠️ Failed to download chunk %s from %s: %v\n", chunkID, nodes[0].ID, err)
-			// Try next node if available
-			if len(nodes) > 1 {
-				if err := d.downloadChunkFromNode(chunkID, nodes[1]); err != nil {
-					return fmt.Errorf("failed to download chunk %s from any node", chunkID)
-				}
-			} else {
-				return fmt.Errorf("failed to download chunk %s", chunkID)
+		sources, stale := d.orderSources(chunkID, nodes)
+		d.verifyStaleReplicas(ctx, chunkID, stale)
+
+		downloaded, skipped := false, 0
+		for _, node := range sources {
+			if node.ID == d.network.LocalNode.ID {
+				continue
 			}
+			if d.negative.knownAbsent(chunkID, node.ID) {
+				skipped++
+				continue
+			}
+			if err := d.downloadChunkFromNode(ctx, chunkID, node); err != nil {
+				tracing.Logf(ctx, "⚠️ Failed to download chunk %s from %s: %v", chunkID, node.ID, err)
+				continue
+			}
+			downloaded = true
+			break
+		}
+		if !downloaded {
+			if skipped > 0 {
+				return fmt.Errorf("failed to download chunk %s from any node (%d recently reported not having it)", chunkID, skipped)
+			}
+			return fmt.Errorf("failed to download chunk %s from any node", chunkID)
 		}
 	}
 
 	return nil
 }
 
-// downloadChunkFromNode downloads a chunk from a specific node
-func (d *Distributor) downloadChunkFromNode(chunkID string, node *p2p.Node) error {
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	url := fmt.Sprintf("http://%s:%d/chunk/%s", node.Address, node.Port, chunkID)
+// maxChunkTransfer bounds a downloaded chunk: the largest chunk plus room for
+// compression and encryption overhead
+const maxChunkTransfer = chunker.MaxChunkSize + 1<<20
 
-	resp, err := client.Get(url)
+// downloadChunkFromNode downloads a chunk from a specific node
+func (d *Distributor) downloadChunkFromNode(ctx context.Context, chunkID string, node *p2p.Node) (err error) {
+	ctx, span := telemetry.Start(ctx, telemetry.OpTransfer+".fetch", telemetry.Attrs{"chunk.id": chunkID, "peer.id": node.ID})
+	defer func() { span.End(err) }()
+
+	resp, err := d.doPeerRequest(ctx, node, "/chunk?id="+chunkID, 30*time.Second, func(url string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build chunk request: %v", err)
+		}
+		tracing.Inject(ctx, req)
+		p2p.AcceptWireCodecs(req)
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to request chunk: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		d.negative.absent(chunkID, node.ID)
+		return errChunkAbsent
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("failed to download chunk: status %d", resp.StatusCode)
 	}
 
-	// Read chunk data
-	chunkData, err := io.ReadAll(resp.Body)
+	// Wait for room in the memory budget before buffering the chunk. A peer that
+	// streams without a length is held to the largest chunk a node can store.
+	reserve := resp.ContentLength
+	if reserve < 0 {
+		reserve = maxChunkTransfer
+	} else if reserve > maxChunkTransfer {
+		return fmt.Errorf("chunk of %d bytes exceeds the limit of %d bytes", reserve, maxChunkTransfer)
+	}
+	memory := budget.Global()
+	if err := memory.Acquire(ctx, reserve); err != nil {
+		return fmt.Errorf("failed to reserve memory for chunk: %v", err)
+	}
+	defer memory.Release(reserve)
+
+	// Read chunk data, unwrapping the wire codec back to the stored bytes
+	chunkData, err := p2p.ReadChunkLimit(resp, maxChunkTransfer)
 	if err != nil {
 		return fmt.Errorf("failed to read chunk data: %v", err)
 	}
 
+	// Corrupt data is rejected here rather than discovered at reassembly
+	if err := d.verifyTransfer(chunkID, chunkData, resp.Header); err != nil {
+		d.recordCorruptTransfer(node.ID)
+		d.recordReplicaCheck(chunkID, node.ID, false)
+		tracing.Logf(ctx, "🚫 Rejected chunk %s from %s: %v", chunkID, node.ID, err)
+		return err
+	}
+
 	// Store chunk locally using the storage interface
 	chunkReader := bytes.NewReader(chunkData)
 	if _, err := d.store.Put(chunkReader); err != nil {
@@ -355,7 +965,7 @@ func (d *Distributor) downloadChunkFromNode(chunkID string, node *p2p.Node) erro
 
 	// Update chunk info
 	d.mu.Lock()
-	if chunk, exists := d.chunks[chunkID]; exists {
+	if chunk, exists := d.chunks[chunkID]; exists && !containsNode(chunk.Nodes, d.network.LocalNode.ID) {
 		chunk.Nodes = append(chunk.Nodes, d.network.LocalNode.ID)
 	}
 	d.mu.Unlock()
@@ -363,7 +973,10 @@ func (d *Distributor) downloadChunkFromNode(chunkID string, node *p2p.Node) erro
 	// Add chunk to local node
 	d.network.AddChunkToNode(d.network.LocalNode.ID, chunkID)
 
-	fmt.Printf("📥 Downloaded chunk %s from %s\n", chunkID, node.ID)
+	// The bytes just matched the chunk's hash, which verifies this replica
+	d.recordReplicaCheck(chunkID, node.ID, true)
+	span.AddBytes(int64(len(chunkData)))
+	tracing.Logf(ctx, "📥 Downloaded chunk %s from %s", chunkID, node.ID)
 	return nil
 }
 
@@ -377,22 +990,40 @@ func (d *Distributor) GetFileInfo(fileID string) (*FileInfo, error) {
 		return nil, fmt.Errorf("file %s not found", fileID)
 	}
 
-	return file, nil
+	return file.clone(), nil
 }
 
-// GetAllFiles returns all files in the network
+// GetAllFiles returns a snapshot of all files in the network, oldest first
 func (d *Distributor) GetAllFiles() []*FileInfo {
 	d.mu.RLock()
-	defer d.mu.RUnlock()
-
 	files := make([]*FileInfo, 0, len(d.files))
 	for _, file := range d.files {
-		files = append(files, file)
+		files = append(files, file.clone())
 	}
+	d.mu.RUnlock()
 
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].CreatedAt.Equal(files[j].CreatedAt) {
+			return files[i].CreatedAt.Before(files[j].CreatedAt)
+		}
+		return files[i].ID < files[j].ID
+	})
 	return files
 }
 
+// ForgetFile drops a file and its chunks from the distributor's records
+func (d *Distributor) ForgetFile(fileID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.files, fileID)
+	for id, chunk := range d.chunks {
+		if chunk.FileID == fileID {
+			delete(d.chunks, id)
+		}
+	}
+}
+
 // GetChunkInfo returns information about a chunk
 func (d *Distributor) GetChunkInfo(chunkID string) (*ChunkInfo, error) {
 	d.mu.RLock()
@@ -403,20 +1034,7 @@ func (d *Distributor) GetChunkInfo(chunkID string) (*ChunkInfo, error) {
 		return nil, fmt.Errorf("chunk %s not found", chunkID)
 	}
 
-	return chunk, nil
-}
-
-// broadcastFileAvailability broadcasts file availability to all peers
-func (d *Distributor) broadcastFileAvailability(file *FileInfo) {
-	msg := &p2p.NetworkMessage{
-		Type:      "file_available",
-		From:      d.network.LocalNode.ID,
-		To:        "",
-		Data:      file,
-		Timestamp: time.Now(),
-	}
-
-	d.network.BroadcastMessage(msg)
+	return chunk.clone(), nil
 }
 
 // HandleChunkTransfer handles incoming chunk transfer requests
@@ -426,8 +1044,17 @@ func (d *Distributor) HandleChunkTransfer(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	ctx, traceID := tracing.FromRequest(r)
+	if traceID != "" {
+		w.Header().Set(tracing.Header, traceID)
+	}
+	ctx, span := telemetry.Start(ctx, telemetry.OpTransfer+".receive", nil)
+	var failed error
+	defer func() { span.End(failed) }()
+
 	var transferReq map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&transferReq); err != nil {
+		failed = err
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
@@ -438,17 +1065,58 @@ func (d *Distributor) HandleChunkTransfer(w http.ResponseWriter, r *http.Request
 	size := int64(transferReq["size"].(float64))
 	hash := transferReq["hash"].(string)
 	fromNode := transferReq["from_node"].(string)
+	storedHash, _ := transferReq["stored_hash"].(string)
+
+	// Senders that forward bytes include them base64 encoded; older ones send metadata only
+	var payload []byte
+	if data, ok := transferReq["data"].(string); ok {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			failed = err
+			http.Error(w, "Invalid chunk data", http.StatusBadRequest)
+			return
+		}
+		payload = decoded
+	}
+
+	span.SetAttr("chunk.id", chunkID)
+	span.SetAttr("peer.id", fromNode)
+
+	// A reference, after have/need, points at bytes this node already stores
+	if referenced, _ := transferReq["referenced"].(bool); referenced && payload == nil {
+		if !d.storesChunk(storedHash) {
+			failed = fmt.Errorf("referenced chunk %s is not stored", storedHash)
+			http.Error(w, "Referenced chunk not stored", http.StatusConflict)
+			return
+		}
+	} else if err := d.reserveCapacity(size); err != nil {
+		// Refuse chunks past the capacity limit so the sender places them elsewhere
+		failed = err
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+
+	if payload != nil {
+		encoding, _ := transferReq["encoding"].(string)
+		digest, _ := transferReq["digest"].(string)
+		if err := d.storeForwardedChunk(encoding, payload, digest, storedHash); err != nil {
+			failed = err
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Create chunk info
 	chunk := &ChunkInfo{
-		ID:        chunkID,
-		FileID:    fileID,
-		Index:     index,
-		Size:      size,
-		Hash:      hash,
-		Nodes:     []string{d.network.LocalNode.ID, fromNode},
-		Replicas:  d.replicaCount,
-		CreatedAt: time.Now(),
+		ID:         chunkID,
+		FileID:     fileID,
+		Index:      index,
+		Size:       size,
+		Hash:       hash,
+		StoredHash: storedHash,
+		Nodes:      []string{d.network.LocalNode.ID, fromNode},
+		Replicas:   d.getReplicaCount(),
+		CreatedAt:  time.Now(),
 	}
 
 	// Store chunk info
@@ -459,8 +1127,9 @@ func (d *Distributor) HandleChunkTransfer(w http.ResponseWriter, r *http.Request
 	// Add chunk to local node
 	d.network.AddChunkToNode(d.network.LocalNode.ID, chunkID)
 
+	span.AddBytes(int64(len(payload)))
 	w.WriteHeader(http.StatusOK)
-	fmt.Printf("📥 Received chunk %s from %s\n", chunkID, fromNode)
+	tracing.Logf(ctx, "📥 Received chunk %s from %s", chunkID, fromNode)
 }
 
 // HandleChunkRequest handles requests for chunk data
@@ -471,32 +1140,32 @@ func (d *Distributor) HandleChunkRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check if we have this chunk
-	d.mu.RLock()
-	chunk, exists := d.chunks[chunkID]
-	d.mu.RUnlock()
-
-	if !exists {
+	// Check if we have this chunk locally
+	chunk, local := d.localChunk(chunkID)
+	if chunk == nil {
 		http.Error(w, "Chunk not found", http.StatusNotFound)
 		return
 	}
-
-	// Check if we have the chunk locally
-	hasChunk := false
-	for _, nodeID := range chunk.Nodes {
-		if nodeID == d.network.LocalNode.ID {
-			hasChunk = true
-			break
-		}
+	if !local {
+		http.Error(w, "Chunk not available locally", http.StatusNotFound)
+		return
 	}
 
-	if !hasChunk {
-		http.Error(w, "Chunk not available locally", http.StatusNotFound)
+	// Shed the request rather than buffer past the memory budget
+	memory := budget.Global()
+	if !memory.TryAcquire(chunk.Size) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server busy, memory budget exhausted", http.StatusServiceUnavailable)
 		return
 	}
+	defer memory.Release(chunk.Size)
 
-	// Read chunk data
-	chunkReader, err := d.store.Get(chunkID)
+	// Read chunk data; chunks are stored under the hash of their bytes
+	storageID := chunk.StoredHash
+	if storageID == "" {
+		storageID = chunkID
+	}
+	chunkReader, err := d.store.Get(storageID)
 	if err != nil {
 		http.Error(w, "Failed to read chunk", http.StatusInternalServerError)
 		return
@@ -509,7 +1178,9 @@ func (d *Distributor) HandleChunkRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Send chunk data
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Write(chunkData)
+	// Send chunk data with its digest so the receiver can verify it on arrival
+	if _, traceID := tracing.FromRequest(r); traceID != "" {
+		w.Header().Set(tracing.Header, traceID)
+	}
+	p2p.WriteChunk(w, r, chunkData, d.network.WireCodecs())
 }
@@ -0,0 +1,20 @@
+package distributor
+
+// SetDraining marks a node as being drained before it is decommissioned, so
+// uploads place no new chunks on it, or returns it to service
+func (d *Distributor) SetDraining(nodeID string, draining bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if draining {
+		d.draining[nodeID] = true
+	} else {
+		delete(d.draining, nodeID)
+	}
+}
+
+// Draining reports whether a node is being drained
+func (d *Distributor) Draining(nodeID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.draining[nodeID]
+}
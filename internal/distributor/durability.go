@@ -0,0 +1,80 @@
+package distributor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DurabilityLevel controls when DistributeFile reports success
+type DurabilityLevel int
+
+const (
+	// DurabilityLocal acknowledges once chunks are written locally; replication continues in the background
+	DurabilityLocal DurabilityLevel = iota
+	// DurabilityQuorum waits until every chunk has at least the configured minimum number of copies
+	DurabilityQuorum
+	// DurabilityAll waits until every chunk has reached the full replica count
+	DurabilityAll
+)
+
+// String returns the config name of the durability level
+func (l DurabilityLevel) String() string {
+	switch l {
+	case DurabilityQuorum:
+		return "quorum"
+	case DurabilityAll:
+		return "all"
+	default:
+		return "local"
+	}
+}
+
+// ParseDurabilityLevel converts a config value ("local", "quorum", "all") to a DurabilityLevel
+func ParseDurabilityLevel(value string) (DurabilityLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "local":
+		return DurabilityLocal, nil
+	case "quorum", "n":
+		return DurabilityQuorum, nil
+	case "all":
+		return DurabilityAll, nil
+	default:
+		return DurabilityLocal, fmt.Errorf("unknown durability level: %s", value)
+	}
+}
+
+// SetDurability sets the acknowledgment level for DistributeFile. minCopies is the
+// number of copies (including the local one) required by DurabilityQuorum.
+func (d *Distributor) SetDurability(level DurabilityLevel, minCopies int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if minCopies < 1 {
+		minCopies = 1
+	}
+	d.durability = level
+	d.minCopies = minCopies
+}
+
+// GetDurability returns the configured durability level and quorum size
+func (d *Distributor) GetDurability() (DurabilityLevel, int) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.durability, d.minCopies
+}
+
+// requiredCopies returns how many copies of each chunk must exist before
+// success, given the copies the file's redundancy scheme targets
+func requiredCopies(level DurabilityLevel, minCopies, replicas int) int {
+	switch level {
+	case DurabilityQuorum:
+		if minCopies > replicas {
+			return replicas
+		}
+		return minCopies
+	case DurabilityAll:
+		return replicas
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,212 @@
+package distributor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// newTestDistributor builds a distributor with one peer served by handler
+func newTestDistributor(t testing.TB, handler http.HandlerFunc) (*Distributor, string) {
+	t.Helper()
+	config.Config = &config.AppConfig{ParallelismRatio: 2}
+
+	peer := httptest.NewServer(handler)
+	t.Cleanup(peer.Close)
+
+	host, portStr, _ := net.SplitHostPort(peer.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	network.RegisterPeer(&p2p.Node{ID: "peer-1", Address: host, Port: port, Status: "online", LastSeen: time.Now()})
+
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	inputPath := filepath.Join(tempDir, "input.txt")
+	if err := os.WriteFile(inputPath, []byte("durability test payload"), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	d := NewDistributor(network, store, nil)
+	d.SetReplicaCount(2)
+	// The next test rewrites the config background replication reads
+	t.Cleanup(d.WaitForReplication)
+	return d, inputPath
+}
+
+func TestDistributeFileWaitsForReplicaAcks(t *testing.T) {
+	release := make(chan struct{})
+	var acked atomic.Bool
+
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		acked.Store(true)
+		w.WriteHeader(http.StatusOK)
+	})
+	d.SetDurability(DurabilityAll, 0)
+
+	done := make(chan *FileInfo, 1)
+	go func() {
+		file, err := d.DistributeFile(inputPath, "pw")
+		if err != nil {
+			t.Errorf("DistributeFile failed: %v", err)
+		}
+		done <- file
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("DistributeFile returned before the replica acknowledged")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case file := <-done:
+		if !acked.Load() {
+			t.Errorf("success returned without a replica acknowledgment")
+		}
+		if file != nil && file.AchievedReplicas != 2 {
+			t.Errorf("achieved replicas = %d, expected 2", file.AchievedReplicas)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("DistributeFile did not return after acknowledgment")
+	}
+}
+
+func TestDistributeFileFailsWhenQuorumNotMet(t *testing.T) {
+	var announced atomic.Int32
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/message" {
+			announced.Add(1)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	d.SetDurability(DurabilityQuorum, 2)
+
+	if _, err := d.DistributeFile(inputPath, "pw"); err == nil {
+		t.Fatalf("expected durability error when the only peer rejects the replica")
+	}
+
+	// The failed upload is neither listed nor announced, and a retry adds no second entry
+	if files := d.GetAllFiles(); len(files) != 0 {
+		t.Errorf("expected no file listed after a failed upload, got %d", len(files))
+	}
+	if local := d.network.LocalNode; len(local.Files) != 0 || len(local.Chunks) != 0 {
+		t.Errorf("expected nothing recorded on the local node, got files %v chunks %v", local.Files, local.Chunks)
+	}
+	if _, err := d.DistributeFile(inputPath, "pw"); err == nil {
+		t.Fatalf("expected the retry to fail the same way")
+	}
+	if files := d.GetAllFiles(); len(files) != 0 {
+		t.Errorf("expected no file listed after a failed retry, got %d", len(files))
+	}
+	time.Sleep(100 * time.Millisecond) // Announcements are sent in the background
+	if n := announced.Load(); n != 0 {
+		t.Errorf("expected the failed upload not announced to peers, got %d announcements", n)
+	}
+
+	// Local durability succeeds regardless of peers
+	d.SetDurability(DurabilityLocal, 1)
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("local durability should succeed: %v", err)
+	}
+	if file.AchievedReplicas != 1 {
+		t.Errorf("achieved replicas = %d, expected 1", file.AchievedReplicas)
+	}
+	if files := d.GetAllFiles(); len(files) != 1 || files[0].ID != file.ID {
+		t.Errorf("expected only the successful upload listed, got %d files", len(files))
+	}
+}
+
+func TestFailedUploadIsRolledBack(t *testing.T) {
+	d, _ := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	tempDir := t.TempDir()
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() {
+		d.WaitForReplication()
+		metaStore.Close()
+		config.Config = &config.AppConfig{ParallelismRatio: 2}
+	})
+	d.metaStore = metaStore
+	d.SetRetryPolicy(0, time.Millisecond)
+	local := d.store.(*storage.LocalStorage)
+
+	write := func(name string, lines int) string {
+		var content strings.Builder
+		for i := 0; i < lines; i++ {
+			fmt.Fprintf(&content, "%d shared line\n", i)
+		}
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+			t.Fatalf("failed to write input: %v", err)
+		}
+		return path
+	}
+
+	// A kept file whose leading chunks the failed upload shares
+	d.SetDurability(DurabilityLocal, 0)
+	base, err := d.DistributeFile(write("base.txt", 4000), "pw")
+	if err != nil {
+		t.Fatalf("base upload failed: %v", err)
+	}
+	baseMeta, _ := metaStore.GetFileMetadataByID(base.ID)
+	refCounts := make(map[string]int)
+	for _, hash := range baseMeta.ChunkHashes {
+		chunk, _ := metaStore.GetChunkMetadata(hash)
+		refCounts[hash] = max(chunk.RefCount, 1) // An untracked count stands for the owner alone
+	}
+	stored, _ := local.List()
+
+	d.SetDurability(DurabilityAll, 0)
+	extendedPath := write("extended.txt", 8000)
+	if _, err := d.DistributeFile(extendedPath, "pw"); err == nil {
+		t.Fatalf("expected the upload to miss its durability level")
+	}
+
+	extendedID, _ := chunker.CalculateFileHash(extendedPath)
+	if _, err := metaStore.GetFileMetadataByID(extendedID); err == nil {
+		t.Errorf("expected the failed upload's record rolled back")
+	}
+	if _, err := metaStore.GetFileMetadata("extended.txt"); err == nil {
+		t.Errorf("expected the failed upload's name entry rolled back")
+	}
+	for hash, count := range refCounts {
+		if chunk, err := metaStore.GetChunkMetadata(hash); err != nil || max(chunk.RefCount, 1) != count {
+			t.Errorf("chunk %s: expected reference count %d kept, got %d (%v)", hash, count, chunk.RefCount, err)
+		}
+	}
+	if after, _ := local.List(); len(after) != len(stored) {
+		t.Errorf("expected only the base file's %d chunks stored, got %d", len(stored), len(after))
+	}
+
+	output := filepath.Join(tempDir, "base.out")
+	if err := chunker.ReassembleFile(base.ID, output, "pw", metaStore, local); err != nil {
+		t.Fatalf("base file no longer reassembles: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package distributor
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/internal/failures"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+func TestFailuresAreRecorded(t *testing.T) {
+	// The only peer rejects every chunk, so "all" durability cannot be met
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInsufficientStorage)
+	})
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	d.metaStore = metaStore
+	log := failures.NewLog(metaStore)
+	d.SetFailureLog(log)
+	d.SetDurability(DurabilityAll, 0)
+
+	ctx := failures.WithUser(context.Background(), "user-42")
+	if _, err := d.DistributeFileContext(ctx, inputPath, "right password"); err == nil {
+		t.Fatalf("expected the upload to miss its durability level")
+	}
+
+	peerFailures, err := log.Query(failures.Filter{Operation: failures.OpReplicate, NodeID: "peer-1"})
+	if err != nil || len(peerFailures) != 1 {
+		t.Fatalf("expected one replication failure for peer-1, got %+v (%v)", peerFailures, err)
+	}
+	if peerFailures[0].ErrorType != failures.TypePeer || peerFailures[0].UserID != "user-42" {
+		t.Errorf("unexpected replication failure: %+v", peerFailures[0])
+	}
+	uploads, _ := log.Query(failures.Filter{Operation: failures.OpUpload})
+	if len(uploads) != 1 || uploads[0].Stage != "durability" || uploads[0].FileID == "" {
+		t.Fatalf("expected the upload failure at the durability stage, got %+v", uploads)
+	}
+
+	// The chunks are stored locally, so a wrong password fails at decryption
+	d.SetDurability(DurabilityLocal, 0)
+	file, err := d.DistributeFileContext(ctx, inputPath, "right password")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := d.ReassembleFileContext(ctx, file.ID, output, "wrong password"); err == nil {
+		t.Fatalf("expected reassembly with the wrong password to fail")
+	}
+
+	decryption, _ := log.Query(failures.Filter{ErrorType: failures.TypeDecryption, UserID: "user-42"})
+	if len(decryption) != 1 || decryption[0].Operation != failures.OpReassemble || decryption[0].FileID != file.ID {
+		t.Fatalf("expected one decryption failure for %s, got %+v", file.ID, decryption)
+	}
+
+	causes, err := log.TopCauses(failures.Filter{}, 0)
+	if err != nil || len(causes) != 3 {
+		t.Fatalf("expected 3 distinct causes, got %+v (%v)", causes, err)
+	}
+}
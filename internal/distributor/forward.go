@@ -0,0 +1,85 @@
+package distributor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// Replication forwards a chunk's stored bytes: the header, compression and
+// encryption applied at upload stay as they are, and only a wire codec is
+// negotiated for the hop. Plaintext is produced solely by client downloads.
+
+// ErrIncompatibleFormat is returned when a peer cannot store a chunk's at-rest format verbatim
+var ErrIncompatibleFormat = errors.New("peer does not store this chunk format")
+
+// announceChunkFormats tells peers which at-rest chunk formats this node stores
+func announceChunkFormats(network *p2p.Network) {
+	for version := uint8(0); version <= chunker.ChunkFormatVersion; version++ {
+		network.AddCapability(p2p.ChunkFormatCapability(version))
+	}
+}
+
+// readStoredChunk returns a chunk's bytes exactly as they are stored
+func (d *Distributor) readStoredChunk(chunk *ChunkInfo) ([]byte, error) {
+	storageID := chunk.StoredHash
+	if storageID == "" {
+		storageID = chunk.ID
+	}
+	reader, err := d.store.Get(storageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %s: %v", chunk.ID, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// encodeForPeer wraps stored bytes in the wire codec negotiated with peer,
+// refusing peers that could not keep the chunk's format as is
+func (d *Distributor) encodeForPeer(stored []byte, peer *p2p.Node) (string, []byte, error) {
+	format, err := chunker.StoredFormat(stored)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read chunk format: %v", err)
+	}
+	if !peer.AcceptsChunkFormat(format) {
+		return "", nil, fmt.Errorf("%w: format %d to %s", ErrIncompatibleFormat, format, peer.ID)
+	}
+	codec := p2p.NegotiateWireCodec(d.network.WireCodecs(), peer)
+	payload, err := p2p.EncodeWire(codec, stored)
+	if err != nil {
+		return "", nil, err
+	}
+	return codec, payload, nil
+}
+
+// storeForwardedChunk unwraps a forwarded chunk and stores its bytes
+// verbatim, after checking they are the bytes the sender stored
+func (d *Distributor) storeForwardedChunk(codec string, payload []byte, digest, storedHash string) error {
+	stored, err := p2p.DecodeWire(codec, payload)
+	if err != nil {
+		return err
+	}
+	expected := storedHash
+	if expected == "" {
+		expected = digest
+	}
+	if err := p2p.VerifyChunkDigest(stored, expected); err != nil {
+		return err
+	}
+	// Bytes already held under their stored hash are not written again
+	if storedHash != "" && d.storesChunk(storedHash) {
+		return nil
+	}
+	id, err := d.store.Put(bytes.NewReader(stored))
+	if err != nil {
+		return fmt.Errorf("failed to store chunk: %v", err)
+	}
+	if storedHash != "" && id != storedHash {
+		return fmt.Errorf("stored chunk under %s, expected %s", id, storedHash)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package distributor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// DefaultReplicaFreshness is how long a replica counts as freshly verified
+const DefaultReplicaFreshness = time.Hour
+
+// staleReplicaPenalty is what a replica not verified within the freshness
+// window costs in source ranking: more than most latency differences, since
+// a rotted copy costs a failed transfer and a retry, but less than a peer
+// already caught sending corrupt data
+const staleReplicaPenalty = time.Second
+
+// failedReplicaPenalty is what a replica whose last verification failed costs
+const failedReplicaPenalty = 4 * corruptTransferPenalty
+
+// replicaCheck is the outcome of the last verification of one replica
+type replicaCheck struct {
+	at time.Time
+	ok bool
+}
+
+// SourceRanking explains where one replica holder ranks as a read source
+type SourceRanking struct {
+	NodeID           string        `json:"node_id"`
+	Rank             int           `json:"rank"`  // 0 is tried first
+	Local            bool          `json:"local"` // This node's own copy, read without a transfer
+	Cost             time.Duration `json:"cost"`
+	RTT              time.Duration `json:"rtt"`
+	RTTMeasured      bool          `json:"rtt_measured"`
+	CorruptTransfers int           `json:"corrupt_transfers"`
+	LastVerified     time.Time     `json:"last_verified,omitempty"` // Zero when this replica was never verified
+	VerifyFailed     bool          `json:"verify_failed"`           // The last verification found the copy bad
+	Fresh            bool          `json:"fresh"`                   // Verified healthy within the freshness window
+}
+
+// SetReplicaFreshness makes reads prefer replicas verified healthy within
+// window over ones not verified since; 0 ignores verification history
+func (d *Distributor) SetReplicaFreshness(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.replicaFreshness = window
+}
+
+// SetVerifyStaleOnRead makes a read verify, in the background, the stale
+// replicas of the chunk it passed over, so the next read has fresh ones
+func (d *Distributor) SetVerifyStaleOnRead(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.verifyStaleOnRead = enabled
+}
+
+// recordReplicaCheck notes the outcome of verifying nodeID's copy of a chunk
+func (d *Distributor) recordReplicaCheck(chunkID, nodeID string, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	checks := d.replicaChecks[chunkID]
+	if checks == nil {
+		checks = make(map[string]replicaCheck)
+		d.replicaChecks[chunkID] = checks
+	}
+	checks[nodeID] = replicaCheck{at: time.Now(), ok: ok}
+}
+
+// freshnessCostLocked scores one replica by its verification history. Callers hold d.mu.
+func (d *Distributor) freshnessCostLocked(chunkID, nodeID string, now time.Time) (time.Duration, bool) {
+	if d.replicaFreshness <= 0 {
+		return 0, false
+	}
+	check, checked := d.replicaChecks[chunkID][nodeID]
+	switch {
+	case checked && !check.ok:
+		return failedReplicaPenalty, true
+	case checked && now.Sub(check.at) <= d.replicaFreshness:
+		return 0, false
+	default:
+		return staleReplicaPenalty, true
+	}
+}
+
+// orderSources ranks the holders of a chunk for reading it and returns the
+// stale ones behind the first to verify in the background, when enabled; the
+// first is verified by the read itself
+func (d *Distributor) orderSources(chunkID string, nodes []*p2p.Node) ([]*p2p.Node, []string) {
+	ranking := d.rankSources(chunkID, nodes)
+	byID := make(map[string]*p2p.Node, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+
+	ordered := make([]*p2p.Node, len(ranking))
+	var stale []string
+	for i, rank := range ranking {
+		ordered[i] = byID[rank.NodeID]
+		if i > 0 && !rank.Fresh && !rank.VerifyFailed && !rank.Local {
+			stale = append(stale, rank.NodeID)
+		}
+	}
+
+	d.mu.RLock()
+	verify := d.verifyStaleOnRead && d.replicaFreshness > 0
+	d.mu.RUnlock()
+	if !verify {
+		stale = nil
+	}
+	return ordered, stale
+}
+
+// rankSources scores each holder of a chunk as a read source, cheapest first.
+// The cost adds the replica's verification freshness to the peer's transfer
+// record and, with latency-aware selection, its round-trip time.
+func (d *Distributor) rankSources(chunkID string, nodes []*p2p.Node) []SourceRanking {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var latency map[string]time.Duration
+	if d.latencyAware {
+		latency = d.sourceCosts(nodes)
+	}
+	now := time.Now()
+	ranking := make([]SourceRanking, 0, len(nodes))
+	for _, node := range nodes {
+		rank := SourceRanking{NodeID: node.ID, CorruptTransfers: d.corruptTransfers[node.ID]}
+		if node.ID == d.network.LocalNode.ID {
+			rank.Local = true
+			ranking = append(ranking, rank)
+			continue
+		}
+		if measured, ok := d.network.PeerLatency(node.ID); ok {
+			rank.RTT, rank.RTTMeasured = measured.RTT, true
+		}
+		if check, ok := d.replicaChecks[chunkID][node.ID]; ok {
+			rank.LastVerified, rank.VerifyFailed = check.at, !check.ok
+		}
+		freshness, stale := d.freshnessCostLocked(chunkID, node.ID, now)
+		rank.Fresh = !stale && !rank.LastVerified.IsZero()
+		if latency != nil {
+			rank.Cost = latency[node.ID]
+		} else {
+			rank.Cost = time.Duration(rank.CorruptTransfers) * corruptTransferPenalty
+		}
+		rank.Cost += freshness
+		ranking = append(ranking, rank)
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		ri, rj := ranking[i], ranking[j]
+		if ri.Local != rj.Local {
+			return ri.Local
+		}
+		if ri.Cost != rj.Cost {
+			return ri.Cost < rj.Cost
+		}
+		if !ri.LastVerified.Equal(rj.LastVerified) {
+			return ri.LastVerified.After(rj.LastVerified)
+		}
+		return ri.NodeID < rj.NodeID
+	})
+	for i := range ranking {
+		ranking[i].Rank = i
+	}
+	return ranking
+}
+
+// ChunkSources reports the holders of a chunk in the order a read would try
+// them, with the factors behind each one's place
+func (d *Distributor) ChunkSources(chunkID string) []SourceRanking {
+	return d.rankSources(chunkID, d.network.FindNodesWithChunk(chunkID))
+}
+
+// verifyStaleReplicas checks the given replicas of a chunk in the background,
+// skipping any a verification is already running for
+func (d *Distributor) verifyStaleReplicas(ctx context.Context, chunkID string, nodeIDs []string) {
+	for _, nodeID := range nodeIDs {
+		key := chunkID + "/" + nodeID
+		d.mu.Lock()
+		if d.verifying[key] {
+			d.mu.Unlock()
+			continue
+		}
+		d.verifying[key] = true
+		d.mu.Unlock()
+
+		go func(nodeID string) {
+			defer func() {
+				d.mu.Lock()
+				delete(d.verifying, key)
+				d.mu.Unlock()
+			}()
+			// VerifyReplica records the outcome
+			d.VerifyReplica(context.WithoutCancel(ctx), chunkID, nodeID)
+		}(nodeID)
+	}
+}
@@ -0,0 +1,95 @@
+package distributor
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestReadsPreferTheFreshlyVerifiedReplica(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	body := []byte("encrypted chunk bytes")
+	var staleServed, freshServed atomic.Int32
+	// The stale peer sorts first by ID, so only its verification age can put it behind
+	stale := delayedPeer(t, "peer-a", 0, body, &staleServed)
+	fresh := delayedPeer(t, "peer-b", 0, body, &freshServed)
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	for _, peer := range []*p2p.Node{stale, fresh} {
+		network.RegisterPeer(peer)
+		network.AddChunkToNode(peer.ID, "c1")
+	}
+	d := NewDistributor(network, store, metaStore)
+	d.SetReplicaFreshness(time.Hour)
+	d.SetVerifyStaleOnRead(true)
+	d.chunks["c1"] = &ChunkInfo{ID: "c1", StoredHash: p2p.ChunkDigest(body), Nodes: []string{"peer-a", "peer-b"}}
+
+	// peer-a was last verified two hours ago; peer-b is verified now
+	d.replicaChecks["c1"] = map[string]replicaCheck{"peer-a": {at: time.Now().Add(-2 * time.Hour), ok: true}}
+	if err := d.VerifyReplica(context.Background(), "c1", "peer-b"); err != nil {
+		t.Fatalf("failed to verify peer-b: %v", err)
+	}
+	freshServed.Store(0)
+
+	sources := d.ChunkSources("c1")
+	if len(sources) != 2 || sources[0].NodeID != "peer-b" || !sources[0].Fresh || sources[1].Fresh || sources[1].LastVerified.IsZero() {
+		t.Fatalf("expected the freshly verified replica ranked first with its factors reported, got %+v", sources)
+	}
+	if sources[1].Cost-sources[0].Cost != staleReplicaPenalty {
+		t.Errorf("expected the stale replica to cost the staleness penalty more, got %v and %v", sources[0].Cost, sources[1].Cost)
+	}
+
+	if err := d.downloadMissingChunks(context.Background(), []string{"c1"}); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if freshServed.Load() != 1 {
+		t.Errorf("expected the chunk read from the freshly verified replica, got fresh=%d", freshServed.Load())
+	}
+
+	// The read triggers a verification of the stale replica it passed over
+	deadline := time.Now().Add(5 * time.Second)
+	for staleServed.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if staleServed.Load() != 1 {
+		t.Fatalf("expected the stale replica verified in the background, got %d reads", staleServed.Load())
+	}
+	byNode := func() map[string]SourceRanking {
+		ranked := make(map[string]SourceRanking)
+		for _, source := range d.ChunkSources("c1") {
+			ranked[source.NodeID] = source
+		}
+		return ranked
+	}
+	for time.Now().Before(deadline) && !byNode()["peer-a"].Fresh {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ranked := byNode(); !ranked["peer-a"].Fresh || !ranked["peer-b"].Fresh || !ranked[network.LocalNode.ID].Local {
+		t.Errorf("expected both replicas fresh after the background verification and the downloaded copy local, got %+v", ranked)
+	}
+
+	// A replica that failed verification goes behind one that is merely stale
+	d.recordReplicaCheck("c1", "peer-b", false)
+	d.mu.Lock()
+	d.replicaChecks["c1"]["peer-a"] = replicaCheck{at: time.Now().Add(-2 * time.Hour), ok: true}
+	d.mu.Unlock()
+	if ranked := byNode(); ranked["peer-a"].Rank >= ranked["peer-b"].Rank || !ranked["peer-b"].VerifyFailed {
+		t.Errorf("expected the replica that failed verification ranked behind the stale one, got %+v", ranked)
+	}
+}
@@ -0,0 +1,212 @@
+package distributor
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// Have/need: before replicating a file's chunks to a peer, the sender lists
+// their stored hashes and the peer answers with those it already stores,
+// e.g. for another file sharing them. Those chunks are then sent as
+// references, without their bytes, and the peer records its existing copy.
+
+// haveNeedPath is the peer endpoint answering have/need requests
+const haveNeedPath = "/chunk-have"
+
+// haveNeedTimeout bounds a have/need exchange; a peer that does not answer in
+// time is sent every chunk in full
+const haveNeedTimeout = 10 * time.Second
+
+// HaveRequest lists the stored hashes of the chunks a sender intends to send
+type HaveRequest struct {
+	FileID string   `json:"file_id"`
+	Hashes []string `json:"hashes"`
+}
+
+// HaveReply lists the hashes of a HaveRequest the peer already stores
+type HaveReply struct {
+	Have []string `json:"have"`
+}
+
+// HaveNeedStats counts the chunk transfers have/need negotiation avoided
+type HaveNeedStats struct {
+	Enabled          bool  `json:"enabled"`
+	Negotiations     int64 `json:"negotiations"`      // Have/need exchanges answered by peers
+	ChunksSent       int64 `json:"chunks_sent"`       // Chunks sent with their bytes
+	ChunksReferenced int64 `json:"chunks_referenced"` // Chunks the peer already stored, sent without bytes
+	BytesSaved       int64 `json:"bytes_saved"`       // Chunk bytes not transferred thanks to references
+}
+
+// SetHaveNeed makes replication ask each peer which of a file's chunks it
+// already stores before sending them
+func (d *Distributor) SetHaveNeed(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.haveNeed.Enabled = enabled
+}
+
+// HaveNeedStats returns the have/need counters since startup
+func (d *Distributor) HaveNeedStats() HaveNeedStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.haveNeed
+}
+
+// peerHoldings negotiates have/need for one file's chunks, once per peer
+type peerHoldings struct {
+	fileID string
+	hashes []string
+	mu     sync.Mutex
+	peers  map[string]*peerHolding
+}
+
+type peerHolding struct {
+	once sync.Once
+	have map[string]bool
+}
+
+// newPeerHoldings prepares have/need for a file's chunks, nil when it is off
+func (d *Distributor) newPeerHoldings(fileID string, chunks []chunker.ChunkMetadata) *peerHoldings {
+	d.mu.RLock()
+	enabled := d.haveNeed.Enabled
+	d.mu.RUnlock()
+	if !enabled || len(chunks) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.Path != "" {
+			hashes = append(hashes, chunk.Path)
+		}
+	}
+	return &peerHoldings{fileID: fileID, hashes: hashes, peers: make(map[string]*peerHolding)}
+}
+
+// holds reports whether peer already stores the chunk stored under
+// storedHash, asking it about all of the file's chunks the first time
+func (h *peerHoldings) holds(ctx context.Context, d *Distributor, peer *p2p.Node, storedHash string) bool {
+	if h == nil || storedHash == "" {
+		return false
+	}
+	h.mu.Lock()
+	holding, ok := h.peers[peer.ID]
+	if !ok {
+		holding = &peerHolding{}
+		h.peers[peer.ID] = holding
+	}
+	h.mu.Unlock()
+
+	holding.once.Do(func() {
+		holding.have = d.askHave(ctx, peer, h.fileID, h.hashes)
+	})
+	return holding.have[storedHash]
+}
+
+// askHave sends peer the stored hashes and returns those it already stores.
+// Peers that predate have/need or fail to answer hold nothing as far as the
+// sender is concerned, so they are sent every chunk in full.
+func (d *Distributor) askHave(ctx context.Context, peer *p2p.Node, fileID string, hashes []string) map[string]bool {
+	body, err := json.Marshal(HaveRequest{FileID: fileID, Hashes: hashes})
+	if err != nil {
+		return nil
+	}
+	resp, err := d.doPeerRequest(ctx, peer, haveNeedPath, haveNeedTimeout, func(url string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build have/need request to %s: %v", peer.ID, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		tracing.Inject(ctx, req)
+		return req, nil
+	})
+	if err != nil {
+		tracing.Logf(ctx, "⚠️ Have/need with %s failed, sending chunks in full: %v", peer.ID, err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var reply HaveReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		tracing.Logf(ctx, "⚠️ Invalid have/need reply from %s, sending chunks in full: %v", peer.ID, err)
+		return nil
+	}
+	requested := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		requested[hash] = true
+	}
+	have := make(map[string]bool, len(reply.Have))
+	for _, hash := range reply.Have {
+		if requested[hash] {
+			have[hash] = true
+		}
+	}
+
+	d.mu.Lock()
+	d.haveNeed.Negotiations++
+	d.mu.Unlock()
+	tracing.Logf(ctx, "🤝 Peer %s already stores %d of %d chunks of %s", peer.ID, len(have), len(hashes), fileID)
+	return have
+}
+
+// recordChunkSent counts a replicated chunk, sent in full or as a reference
+func (d *Distributor) recordChunkSent(chunk *ChunkInfo, referenced bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if referenced {
+		d.haveNeed.ChunksReferenced++
+		d.haveNeed.BytesSaved += chunk.Size
+	} else {
+		d.haveNeed.ChunksSent++
+	}
+}
+
+// HandleChunkHave answers a have/need request with the listed chunks this
+// node already stores
+func (d *Distributor) HandleChunkHave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req HaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	reply := HaveReply{Have: make([]string, 0, len(req.Hashes))}
+	for _, hash := range req.Hashes {
+		if d.storesChunk(hash) {
+			reply.Have = append(reply.Have, hash)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// storesChunk reports whether the chunk stored under storedHash is held
+// locally. Anything but a SHA-256 hex digest is never a stored chunk.
+func (d *Distributor) storesChunk(storedHash string) bool {
+	if len(storedHash) != 64 {
+		return false
+	}
+	if _, err := hex.DecodeString(storedHash); err != nil {
+		return false
+	}
+	exists, err := d.store.Exists(storedHash)
+	return err == nil && exists
+}
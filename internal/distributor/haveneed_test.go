@@ -0,0 +1,105 @@
+package distributor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+)
+
+func TestHaveNeedSendsOnlyMissingChunks(t *testing.T) {
+	d, inputPath := newTestDistributor(t, nil)
+	d.network.RemovePeer("peer-1")
+	config.Config.ChunkSize = chunker.MinChunkSize
+	content := make([]byte, 6*chunker.MinChunkSize)
+	rand.New(rand.NewSource(3)).Read(content)
+	os.WriteFile(inputPath, content, 0644)
+	d.SetDurability(DurabilityAll, 0)
+	d.SetHaveNeed(true)
+
+	receiver, _, _ := newReceivingPeer(t)
+	var mu sync.Mutex
+	seeded := make(map[string]bool)
+	var sentInFull, referenced []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		switch r.URL.Path {
+		case haveNeedPath:
+			// Every other chunk reached the receiver earlier, as if shared with another file
+			var req HaveRequest
+			json.Unmarshal(body, &req)
+			for i, hash := range req.Hashes {
+				if i%2 == 0 {
+					stored, _ := d.readStoredChunk(&ChunkInfo{StoredHash: hash})
+					receiver.store.Put(bytes.NewReader(stored))
+					mu.Lock()
+					seeded[hash] = true
+					mu.Unlock()
+				}
+			}
+			receiver.HandleChunkHave(w, r)
+		case "/chunk-transfer":
+			var transfer struct {
+				StoredHash string `json:"stored_hash"`
+				Data       []byte `json:"data"`
+			}
+			json.Unmarshal(body, &transfer)
+			mu.Lock()
+			if transfer.Data == nil {
+				referenced = append(referenced, transfer.StoredHash)
+			} else {
+				sentInFull = append(sentInFull, transfer.StoredHash)
+			}
+			mu.Unlock()
+			receiver.HandleChunkTransfer(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	registerServer(d, "peer-2", server, receiver.network.LocalNode.Capabilities)
+
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if file.AchievedReplicas != 2 {
+		t.Fatalf("expected every chunk on the receiver, got %d copies", file.AchievedReplicas)
+	}
+
+	var savedBytes int64
+	for _, hash := range sentInFull {
+		if seeded[hash] {
+			t.Errorf("chunk %s was sent although the receiver stored it", hash)
+		}
+	}
+	for _, chunkID := range file.Chunks {
+		chunk, _ := d.GetChunkInfo(chunkID)
+		if seeded[chunk.StoredHash] {
+			savedBytes += chunk.Size
+		}
+		if !receiver.storesChunk(chunk.StoredHash) {
+			t.Errorf("receiver does not store chunk %s", chunkID)
+		}
+		if _, err := receiver.GetChunkInfo(chunkID); err != nil {
+			t.Errorf("receiver did not record chunk %s: %v", chunkID, err)
+		}
+	}
+	if len(seeded) == 0 || len(referenced) != len(seeded) || len(sentInFull) != len(file.Chunks)-len(seeded) {
+		t.Errorf("expected %d chunks referenced and %d sent, got %d and %d",
+			len(seeded), len(file.Chunks)-len(seeded), len(referenced), len(sentInFull))
+	}
+
+	stats := d.HaveNeedStats()
+	if stats.Negotiations != 1 || stats.ChunksReferenced != int64(len(seeded)) || stats.BytesSaved != savedBytes ||
+		stats.ChunksSent != int64(len(sentInFull)) {
+		t.Errorf("expected one negotiation saving %d bytes, got %+v", savedBytes, stats)
+	}
+}
@@ -0,0 +1,165 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/budget"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// verifyTransfer checks chunk bytes received from a peer before they are
+// stored. The expected digest comes from local chunk records when known, so a
+// relay that rewrites both the bytes and the digest header is still caught.
+func (d *Distributor) verifyTransfer(chunkID string, data []byte, header http.Header) error {
+	expected := ""
+	d.mu.RLock()
+	if chunk, exists := d.chunks[chunkID]; exists {
+		expected = chunk.StoredHash
+	}
+	d.mu.RUnlock()
+
+	sent := header.Get(p2p.ChunkDigestHeader)
+	if expected == "" {
+		expected = sent
+	} else if sent != "" && sent != expected {
+		return fmt.Errorf("%w: peer sent digest %s, expected %s", p2p.ErrChunkDigestMismatch, sent, expected)
+	}
+	return p2p.VerifyChunkDigest(data, expected)
+}
+
+// recordCorruptTransfer counts a transfer from a peer that failed verification
+func (d *Distributor) recordCorruptTransfer(peerID string) {
+	d.mu.Lock()
+	d.corruptTransfers[peerID]++
+	d.mu.Unlock()
+}
+
+// CorruptTransfers returns how many chunk transfers from each peer failed verification
+func (d *Distributor) CorruptTransfers() map[string]int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	counts := make(map[string]int, len(d.corruptTransfers))
+	for peerID, count := range d.corruptTransfers {
+		counts[peerID] = count
+	}
+	return counts
+}
+
+// orderByTransferHealth puts peers that sent corrupt chunks last. With
+// latency-aware selection the two are combined, see sourceCost; rankSources
+// adds a chunk's replica verifications when the chunk is known.
+func (d *Distributor) orderByTransferHealth(nodes []*p2p.Node) []*p2p.Node {
+	ordered, _ := d.orderSources("", nodes)
+	return ordered
+}
+
+// VerifyReplica confirms a node holds an intact copy of a chunk by reading
+// it back and checking it against the chunk's stored hash. Moves must call it
+// on the destination before a source replica is dropped.
+func (d *Distributor) VerifyReplica(ctx context.Context, chunkID, nodeID string) error {
+	err := d.verifyReplica(ctx, chunkID, nodeID)
+	switch {
+	case err == nil:
+		d.recordReplicaCheck(chunkID, nodeID, true)
+	case errors.Is(err, p2p.ErrChunkDigestMismatch), errors.Is(err, storage.ErrChunkCorrupt):
+		d.recordReplicaCheck(chunkID, nodeID, false)
+	}
+	return err
+}
+
+func (d *Distributor) verifyReplica(ctx context.Context, chunkID, nodeID string) error {
+	chunk, _ := d.localChunk(chunkID)
+	if chunk == nil {
+		return fmt.Errorf("chunk %s not found", chunkID)
+	}
+	if chunk.StoredHash == "" {
+		return fmt.Errorf("no stored hash recorded for chunk %s", chunkID)
+	}
+	if nodeID == d.network.LocalNode.ID {
+		return storage.VerifyChunk(d.store, chunk.StoredHash)
+	}
+
+	peer := d.network.GetPeerByID(nodeID)
+	if peer == nil {
+		return fmt.Errorf("unknown node %s", nodeID)
+	}
+	client := d.network.Client(30 * time.Second)
+	url := peer.URL("/chunk?id=" + chunkID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build chunk request: %v", err)
+	}
+	tracing.Inject(ctx, req)
+	p2p.AcceptWireCodecs(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request chunk from node %s: %v", nodeID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("node %s does not serve chunk %s: status %d", nodeID, chunkID, resp.StatusCode)
+	}
+
+	memory := budget.Global()
+	if err := memory.Acquire(ctx, chunk.Size); err != nil {
+		return fmt.Errorf("failed to reserve memory for chunk: %v", err)
+	}
+	defer memory.Release(chunk.Size)
+	data, err := p2p.ReadChunk(resp)
+	if err != nil {
+		return fmt.Errorf("failed to read chunk data from node %s: %v", nodeID, err)
+	}
+	return p2p.VerifyChunkDigest(data, chunk.StoredHash)
+}
+
+// RepairChunk replaces this node's copy of a chunk, found corrupt or missing,
+// with one fetched from another replica and checked against the chunk's
+// stored hash. A damaged copy is removed first so content-addressed storage
+// takes the new one; an intact copy is left alone. It returns the node the
+// copy came from.
+func (d *Distributor) RepairChunk(ctx context.Context, chunkID string) (string, error) {
+	chunk, _ := d.localChunk(chunkID)
+	if chunk == nil {
+		return "", fmt.Errorf("chunk %s not found", chunkID)
+	}
+	var nodes []*p2p.Node
+	for _, nodeID := range chunk.Nodes {
+		if nodeID == d.network.LocalNode.ID {
+			continue
+		}
+		if peer := d.network.GetPeerByID(nodeID); peer != nil {
+			nodes = append(nodes, peer)
+		}
+	}
+	if len(nodes) == 0 {
+		return "", fmt.Errorf("no other replica of chunk %s to repair it from", chunkID)
+	}
+
+	if deleter, ok := d.store.(storage.Deleter); ok && chunk.StoredHash != "" {
+		if err := storage.VerifyChunk(d.store, chunk.StoredHash); errors.Is(err, storage.ErrChunkCorrupt) {
+			if err := deleter.Delete(chunk.StoredHash); err != nil {
+				return "", fmt.Errorf("failed to remove corrupt copy of chunk %s: %v", chunkID, err)
+			}
+		}
+	}
+
+	sources, _ := d.orderSources(chunkID, nodes)
+	var lastErr error
+	for _, node := range sources {
+		if err := d.downloadChunkFromNode(ctx, chunkID, node); err != nil {
+			tracing.Logf(ctx, "⚠️ Failed to repair chunk %s from %s: %v", chunkID, node.ID, err)
+			lastErr = err
+			continue
+		}
+		return node.ID, nil
+	}
+	return "", fmt.Errorf("failed to repair chunk %s from any replica: %v", chunkID, lastErr)
+}
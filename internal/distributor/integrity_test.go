@@ -0,0 +1,175 @@
+package distributor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/budget"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// chunkPeer serves body for any chunk request, advertising digest
+func chunkPeer(t *testing.T, body []byte, digest string) *p2p.Node {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(p2p.ChunkDigestHeader, digest)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return &p2p.Node{Address: host, Port: portNum, Status: "online", LastSeen: time.Now()}
+}
+
+func TestCorruptTransferIsRejectedAndAnotherReplicaUsed(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	d := NewDistributor(network, store, metaStore)
+
+	good := []byte("encrypted chunk bytes")
+	corrupt := append([]byte{}, good...)
+	corrupt[3] ^= 0xFF
+	digest := p2p.ChunkDigest(good)
+
+	// The first replica's bytes are flipped in transit while its digest header still looks right
+	bad := chunkPeer(t, corrupt, digest)
+	bad.ID = "peer-a"
+	healthy := chunkPeer(t, good, digest)
+	healthy.ID = "peer-b"
+	for _, peer := range []*p2p.Node{bad, healthy} {
+		network.RegisterPeer(peer)
+		network.AddChunkToNode(peer.ID, "c1")
+	}
+	d.chunks["c1"] = &ChunkInfo{ID: "c1", StoredHash: digest, Nodes: []string{"peer-a", "peer-b"}}
+
+	// peer-a is tried first, fails verification, and the download moves on to peer-b
+	if err := d.downloadMissingChunks(context.Background(), []string{"c1"}); err != nil {
+		t.Fatalf("download did not fall back to the healthy replica: %v", err)
+	}
+	if _, err := store.Get(p2p.ChunkDigest(corrupt)); err == nil {
+		t.Fatalf("corrupt chunk was stored")
+	}
+	reader, err := store.Get(digest)
+	if err != nil {
+		t.Fatalf("verified chunk was not stored: %v", err)
+	}
+	defer reader.Close()
+	if data, _ := io.ReadAll(reader); !bytes.Equal(data, good) {
+		t.Errorf("stored chunk does not match the original")
+	}
+
+	// The corrupting peer is remembered and tried last from now on
+	if failures := d.CorruptTransfers(); failures["peer-a"] != 1 || failures["peer-b"] != 0 {
+		t.Errorf("expected one corrupt transfer from peer-a, got %v", failures)
+	}
+	if ordered := d.orderByTransferHealth([]*p2p.Node{bad, healthy}); ordered[0].ID != "peer-b" {
+		t.Errorf("expected the healthy peer first, got %s", ordered[0].ID)
+	}
+
+	// A digest header that disagrees with local records is rejected outright
+	liar := chunkPeer(t, corrupt, p2p.ChunkDigest(corrupt))
+	liar.ID = "peer-c"
+	if err := d.downloadChunkFromNode(context.Background(), "c1", liar); !errors.Is(err, p2p.ErrChunkDigestMismatch) {
+		t.Errorf("expected a forged digest to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyReplicaReadsBackTheCopy(t *testing.T) {
+	store, err := storage.NewLocalStorage(filepath.Join(t.TempDir(), "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	d := NewDistributor(network, store, nil)
+
+	good := []byte("replica being moved")
+	digest, _ := store.Put(bytes.NewReader(good))
+	corrupt := append([]byte{}, good...)
+	corrupt[0] ^= 0xFF
+
+	intact := chunkPeer(t, good, digest)
+	intact.ID = "peer-a"
+	damaged := chunkPeer(t, corrupt, digest)
+	damaged.ID = "peer-b"
+	network.RegisterPeer(intact)
+	network.RegisterPeer(damaged)
+	d.chunks["c1"] = &ChunkInfo{ID: "c1", Size: int64(len(good)), StoredHash: digest, Nodes: []string{network.LocalNode.ID}}
+
+	ctx := context.Background()
+	if err := d.VerifyReplica(ctx, "c1", network.LocalNode.ID); err != nil {
+		t.Errorf("local copy should verify: %v", err)
+	}
+	if err := d.VerifyReplica(ctx, "c1", "peer-a"); err != nil {
+		t.Errorf("intact peer copy should verify: %v", err)
+	}
+	if err := d.VerifyReplica(ctx, "c1", "peer-b"); err == nil {
+		t.Errorf("a damaged copy must not be confirmed")
+	}
+	if err := d.VerifyReplica(ctx, "c1", "peer-unknown"); err == nil {
+		t.Errorf("a node that never received the chunk must not be confirmed")
+	}
+}
+
+func TestUnsizedChunkDownloadReservesTheLargestChunk(t *testing.T) {
+	store, err := storage.NewLocalStorage(filepath.Join(t.TempDir(), "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	d := NewDistributor(p2p.NewNetwork("127.0.0.1", 0), store, nil)
+
+	// The peer flushes before writing, so the response carries no Content-Length
+	good := []byte("streamed chunk bytes")
+	digest := p2p.ChunkDigest(good)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(p2p.ChunkDigestHeader, digest)
+		w.(http.Flusher).Flush()
+		w.Write(good)
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	peer := &p2p.Node{ID: "peer-a", Address: host, Port: portNum, Status: "online", LastSeen: time.Now()}
+	d.chunks["c1"] = &ChunkInfo{ID: "c1", StoredHash: digest, Nodes: []string{"peer-a"}}
+
+	// With the budget held elsewhere, the download waits for room and gives up with its context
+	memory := budget.Global()
+	budget.Configure(maxChunkTransfer)
+	defer budget.Configure(0)
+	memory.Acquire(context.Background(), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := d.downloadChunkFromNode(ctx, "c1", peer); err == nil || !strings.Contains(err.Error(), "reserve memory") {
+		memory.Release(1)
+		t.Fatalf("expected the download to wait for the budget, got %v", err)
+	}
+	memory.Release(1)
+
+	if err := d.downloadChunkFromNode(context.Background(), "c1", peer); err != nil {
+		t.Fatalf("expected the download to succeed once the budget is free: %v", err)
+	}
+	if stats := memory.Stats(); stats.InFlight != 0 {
+		t.Errorf("expected the reservation released, %d bytes still held", stats.InFlight)
+	}
+}
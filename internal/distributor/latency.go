@@ -0,0 +1,54 @@
+package distributor
+
+import (
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// corruptTransferPenalty is the round-trip time one corrupt transfer costs a
+// peer in source ranking: a retry after a bad chunk costs far more than a
+// slower link
+const corruptTransferPenalty = 2 * time.Second
+
+// SetLatencyAware makes downloads prefer the replica holders with the lowest
+// measured round-trip time, among those with a similar transfer record
+func (d *Distributor) SetLatencyAware(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latencyAware = enabled
+}
+
+// LatencyAware reports whether replica sources are ranked by round-trip time
+func (d *Distributor) LatencyAware() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.latencyAware
+}
+
+// sourceCosts scores each peer as its smoothed round-trip time plus a penalty
+// per corrupt transfer. Peers not measured yet are scored as the slowest
+// measured candidate, so they are neither favoured nor left for last.
+// Callers hold d.mu.
+func (d *Distributor) sourceCosts(nodes []*p2p.Node) map[string]time.Duration {
+	rtts := make(map[string]time.Duration, len(nodes))
+	var slowest time.Duration
+	for _, node := range nodes {
+		if latency, measured := d.network.PeerLatency(node.ID); measured {
+			rtts[node.ID] = latency.RTT
+			if latency.RTT > slowest {
+				slowest = latency.RTT
+			}
+		}
+	}
+
+	costs := make(map[string]time.Duration, len(nodes))
+	for _, node := range nodes {
+		rtt, measured := rtts[node.ID]
+		if !measured {
+			rtt = slowest
+		}
+		costs[node.ID] = rtt + time.Duration(d.corruptTransfers[node.ID])*corruptTransferPenalty
+	}
+	return costs
+}
@@ -0,0 +1,94 @@
+package distributor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// delayedPeer serves pings and chunks after delay, counting chunk requests
+func delayedPeer(t *testing.T, id string, delay time.Duration, body []byte, served *atomic.Int32) *p2p.Node {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if r.URL.Path == "/chunk" {
+			served.Add(1)
+			w.Header().Set(p2p.ChunkDigestHeader, p2p.ChunkDigest(body))
+			w.Write(body)
+			return
+		}
+		w.Write([]byte("pong"))
+	}))
+	t.Cleanup(server.Close)
+	return &p2p.Node{ID: id, Address: "127.0.0.1", Port: server.Listener.Addr().(*net.TCPAddr).Port, Status: "online", LastSeen: time.Now()}
+}
+
+func TestLatencyAwareSelectionPrefersTheFasterReplica(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	body := []byte("encrypted chunk bytes")
+	var slowServed, fastServed atomic.Int32
+	// The slow peer sorts first by ID, so only latency can put the fast one ahead
+	slow := delayedPeer(t, "peer-a", 150*time.Millisecond, body, &slowServed)
+	fast := delayedPeer(t, "peer-b", 0, body, &fastServed)
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	for _, peer := range []*p2p.Node{slow, fast} {
+		network.RegisterPeer(peer)
+		network.AddChunkToNode(peer.ID, "c1")
+	}
+	d := NewDistributor(network, store, metaStore)
+	d.SetLatencyAware(true)
+	d.chunks["c1"] = &ChunkInfo{ID: "c1", StoredHash: p2p.ChunkDigest(body), Nodes: []string{"peer-a", "peer-b"}}
+
+	// Without measurements the order falls back to peer IDs
+	if ordered := d.orderByTransferHealth([]*p2p.Node{slow, fast}); ordered[0].ID != "peer-a" {
+		t.Fatalf("expected unmeasured peers ordered by ID, got %s first", ordered[0].ID)
+	}
+
+	for _, peer := range []*p2p.Node{slow, fast} {
+		if _, err := network.ProbeLatency(peer); err != nil {
+			t.Fatalf("failed to probe %s: %v", peer.ID, err)
+		}
+	}
+	slowRTT, _ := network.PeerLatency("peer-a")
+	fastRTT, _ := network.PeerLatency("peer-b")
+	if slowRTT.RTT <= fastRTT.RTT {
+		t.Fatalf("expected the delayed peer measured slower, got %v and %v", slowRTT.RTT, fastRTT.RTT)
+	}
+
+	// A second probe within the interval does not ping again
+	if again, _ := network.ProbeLatency(slow); again.Samples != 1 {
+		t.Errorf("expected the probe rate-limited, got %d samples", again.Samples)
+	}
+
+	if err := d.downloadMissingChunks(context.Background(), []string{"c1"}); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if fastServed.Load() != 1 || slowServed.Load() != 0 {
+		t.Errorf("expected the chunk fetched from the fast peer, got fast=%d slow=%d", fastServed.Load(), slowServed.Load())
+	}
+
+	// A corrupt transfer outweighs a faster link
+	d.recordCorruptTransfer("peer-b")
+	if ordered := d.orderByTransferHealth([]*p2p.Node{slow, fast}); ordered[0].ID != "peer-a" {
+		t.Errorf("expected the peer with a clean record first, got %s", ordered[0].ID)
+	}
+}
@@ -0,0 +1,143 @@
+package distributor
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// newMemoryCluster starts distributors wired together through an in-memory
+// transport. They share one metadata store, standing in for a replicated catalog,
+// and each has its own chunk storage.
+func newMemoryCluster(t *testing.T, names ...string) (*p2p.MemoryTransport, map[string]*Distributor) {
+	t.Helper()
+	dir := t.TempDir()
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() { metaStore.Close() })
+
+	transport := p2p.NewMemoryTransport()
+	nodes := make(map[string]*Distributor)
+	for i, name := range names {
+		store, err := storage.NewLocalStorage(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to create storage for %s: %v", name, err)
+		}
+		network := p2p.NewNetworkWithID(name, name+".test", 7000+i)
+		d := NewDistributor(network, store, metaStore)
+
+		mux := http.NewServeMux()
+		mux.Handle("/", network.Handler())
+		mux.HandleFunc("/chunk-transfer", d.HandleChunkTransfer)
+		mux.HandleFunc("/chunk", d.HandleChunkRequest)
+		mux.HandleFunc("/message", d.HandleMessage)
+		transport.Attach(network, mux)
+		nodes[name] = d
+	}
+
+	for _, d := range nodes {
+		for _, other := range nodes {
+			if other == d {
+				continue
+			}
+			local := other.network.LocalNode
+			d.network.RegisterPeer(&p2p.Node{ID: local.ID, Address: local.Address, Port: local.Port, Status: "online",
+				Capabilities: local.Capabilities})
+		}
+	}
+	return transport, nodes
+}
+
+func TestMemoryClusterReassemblesOnAnotherNodeAfterOneFails(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	transport, nodes := newMemoryCluster(t, "node-a", "node-b", "node-c")
+	a, b, c := nodes["node-a"], nodes["node-b"], nodes["node-c"]
+	a.SetReplicaCount(3)
+	a.SetDurability(DurabilityAll, 0)
+
+	var content bytes.Buffer
+	for i := 0; content.Len() < 4*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "line %d of the multi-node payload\n", i)
+	}
+	input := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(input, content.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	file, err := a.DistributeFile(input, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile on node-a failed: %v", err)
+	}
+	if len(file.Chunks) < 2 || file.AchievedReplicas != 3 {
+		t.Fatalf("expected several chunks on all three nodes, got %d chunks with %d copies", len(file.Chunks), file.AchievedReplicas)
+	}
+
+	// The announcement is broadcast in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := b.GetFileInfo(file.ID); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("node-b never learned about the file")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// node-b loses its copies and node-c goes down, so every chunk has to come from node-a
+	for _, chunkID := range file.Chunks {
+		chunk, _ := b.GetChunkInfo(chunkID)
+		if err := b.store.(storage.Deleter).Delete(chunk.StoredHash); err != nil {
+			t.Fatalf("failed to drop node-b's copy of %s: %v", chunkID, err)
+		}
+		b.mu.Lock()
+		b.chunks[chunkID].Nodes = []string{"node-a", "node-c"}
+		b.mu.Unlock()
+	}
+	transport.Detach(c.network)
+
+	output := filepath.Join(t.TempDir(), "reassembled.txt")
+	if err := b.ReassembleFile(file.ID, output, "pw"); err != nil {
+		t.Fatalf("ReassembleFile on node-b failed: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if !bytes.Equal(got, content.Bytes()) {
+		t.Fatal("node-b reassembled different content")
+	}
+}
+
+func TestMemoryTransportReportsDetachedNodes(t *testing.T) {
+	transport, nodes := newMemoryCluster(t, "node-a", "node-b")
+	a, b := nodes["node-a"], nodes["node-b"]
+
+	resp, err := a.network.Client(time.Second).Get(b.network.LocalNode.URL("/ping"))
+	if err != nil {
+		t.Fatalf("ping over the memory transport failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from ping, got %d", resp.StatusCode)
+	}
+
+	transport.Detach(b.network)
+	if _, err := a.network.Client(time.Second).Get(b.network.LocalNode.URL("/ping")); err == nil {
+		t.Fatal("expected a detached node to be unreachable")
+	}
+}
@@ -0,0 +1,127 @@
+package distributor
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Negative lookup cache defaults
+const (
+	DefaultNegativeCacheTTL        = 30 * time.Second
+	DefaultNegativeCacheMaxEntries = 10000
+)
+
+// errChunkAbsent is returned when a peer answers that it does not hold a chunk
+var errChunkAbsent = errors.New("chunk not found on peer")
+
+type negativeKey struct {
+	chunkID, peerID string
+}
+
+type negativeEntry struct {
+	key     negativeKey
+	expires time.Time
+}
+
+// negativeCache remembers peers that recently answered they do not hold a
+// chunk, so retries skip them until the entry expires or the chunk is
+// announced there. Oldest entries are evicted beyond maxEntries.
+type negativeCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration // 0 disables the cache
+	maxEntries int
+	order      *list.List // Front is oldest
+	entries    map[negativeKey]*list.Element
+	now        func() time.Time
+}
+
+func newNegativeCache(ttl time.Duration, maxEntries int) *negativeCache {
+	c := &negativeCache{order: list.New(), entries: make(map[negativeKey]*list.Element), now: time.Now}
+	c.configure(ttl, maxEntries)
+	return c
+}
+
+func (c *negativeCache) configure(ttl time.Duration, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if maxEntries <= 0 {
+		maxEntries = DefaultNegativeCacheMaxEntries
+	}
+	c.ttl, c.maxEntries = ttl, maxEntries
+	if ttl <= 0 {
+		c.order.Init()
+		c.entries = make(map[negativeKey]*list.Element)
+	}
+	c.trim()
+}
+
+// absent records that peerID does not hold chunkID
+func (c *negativeCache) absent(chunkID, peerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	key := negativeKey{chunkID, peerID}
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[key] = c.order.PushBack(&negativeEntry{key: key, expires: c.now().Add(c.ttl)})
+	c.trim()
+}
+
+// knownAbsent reports whether peerID recently answered it lacks chunkID
+func (c *negativeCache) knownAbsent(chunkID, peerID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[negativeKey{chunkID, peerID}]
+	if !ok {
+		return false
+	}
+	if c.now().After(elem.Value.(*negativeEntry).expires) {
+		c.remove(elem)
+		return false
+	}
+	return true
+}
+
+// forget drops the entry for a chunk on a peer, as when the peer announces it
+func (c *negativeCache) forget(chunkID, peerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[negativeKey{chunkID, peerID}]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *negativeCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *negativeCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*negativeEntry).key)
+}
+
+// trim drops expired entries from the front and the oldest beyond the bound.
+// Entries are appended as they are made, so the front expires first.
+func (c *negativeCache) trim() {
+	now := c.now()
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		if len(c.entries) <= c.maxEntries && !now.After(front.Value.(*negativeEntry).expires) {
+			break
+		}
+		c.remove(front)
+	}
+}
+
+// SetNegativeCache sets how long a peer's "chunk not found" answer is trusted
+// before the peer is asked again, and how many such answers are kept. A zero
+// TTL disables the cache; a non-positive bound keeps the default.
+func (d *Distributor) SetNegativeCache(ttl time.Duration, maxEntries int) {
+	d.negative.configure(ttl, maxEntries)
+}
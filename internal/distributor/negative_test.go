@@ -0,0 +1,84 @@
+package distributor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestNegativeCacheSkipsPeersThatLackAChunk(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	// A peer believed to hold the chunk that answers it does not
+	var queries atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		http.Error(w, "Chunk not available locally", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	network.RegisterPeer(&p2p.Node{ID: "peer-1", Address: "127.0.0.1",
+		Port: server.Listener.Addr().(*net.TCPAddr).Port, Status: "online", LastSeen: time.Now()})
+	network.AddChunkToNode("peer-1", "c0")
+
+	d := NewDistributor(network, store, metaStore)
+	now := time.Now()
+	d.negative.now = func() time.Time { return now }
+
+	lookup := func(stage string, wantQueries int32) {
+		t.Helper()
+		if err := d.downloadMissingChunks(t.Context(), []string{"c0"}); err == nil {
+			t.Fatalf("%s: expected the download to fail", stage)
+		}
+		if got := queries.Load(); got != wantQueries {
+			t.Errorf("%s: expected the peer queried %d times in all, got %d", stage, wantQueries, got)
+		}
+	}
+
+	lookup("first lookup", 1)
+	lookup("within the TTL", 1)
+
+	// An announcement that the peer now holds the chunk invalidates the entry
+	d.learnFile(FileAnnouncement{File: &FileInfo{ID: "file-1"}, Chunks: []*ChunkInfo{{ID: "c0", FileID: "file-1", Nodes: []string{"peer-1"}}}})
+	lookup("after an announcement", 2)
+	lookup("cached again", 2)
+
+	// Expiry makes the peer worth asking again
+	now = now.Add(DefaultNegativeCacheTTL + time.Second)
+	lookup("after expiry", 3)
+
+	// A zero TTL disables the cache
+	d.SetNegativeCache(0, 0)
+	lookup("disabled", 4)
+	lookup("disabled again", 5)
+
+	// The cache stays within its bound, dropping the oldest answers
+	d.SetNegativeCache(time.Minute, 2)
+	for _, chunkID := range []string{"x0", "x1", "x2"} {
+		d.negative.absent(chunkID, "peer-1")
+	}
+	if n := d.negative.len(); n != 2 {
+		t.Errorf("expected the cache bounded to 2 entries, got %d", n)
+	}
+	if d.negative.knownAbsent("x0", "peer-1") || !d.negative.knownAbsent("x2", "peer-1") {
+		t.Errorf("expected the oldest entry evicted and the newest kept")
+	}
+}
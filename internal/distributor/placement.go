@@ -0,0 +1,138 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jaywantadh/DisktroByte/internal/failures"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// ErrPlacementUnsatisfied is recorded when too few approved nodes are
+// available for the replicas a file's placement constraint asks for
+var ErrPlacementUnsatisfied = errors.New("placement constraint cannot be satisfied")
+
+// placementPlan applies a file's placement constraint while its chunks are
+// replicated and collects the shortfalls, which are reported instead of
+// falling back to unapproved nodes.
+type placementPlan struct {
+	d          *Distributor
+	file       *FileInfo
+	constraint metadata.PlacementConstraint
+	reportOnce sync.Once
+}
+
+// filter keeps the peers the constraint approves
+func (p *placementPlan) filter(peers []*p2p.Node) []*p2p.Node {
+	if p == nil || p.constraint.IsZero() {
+		return peers
+	}
+	approved := make([]*p2p.Node, 0, len(peers))
+	for _, peer := range peers {
+		if p.constraint.Allows(peer.ID, peer.Labels) {
+			approved = append(approved, peer)
+		}
+	}
+	return approved
+}
+
+// violation records a shortfall on the file; the first one per file also goes
+// to the failure log
+func (p *placementPlan) violation(ctx context.Context, message string) {
+	if p == nil || p.constraint.IsZero() {
+		return
+	}
+	p.d.mu.Lock()
+	p.file.PlacementViolations = append(p.file.PlacementViolations, message)
+	p.d.mu.Unlock()
+
+	tracing.Logf(ctx, "⚠️ File '%s' placement: %s", p.file.Name, message)
+	p.reportOnce.Do(func() {
+		p.d.recordFailure(ctx, failures.Failure{Operation: failures.OpReplicate, Stage: "placement", FileID: p.file.ID},
+			fmt.Errorf("%w: %s", ErrPlacementUnsatisfied, message))
+	})
+}
+
+// planPlacement resolves the constraint for an upload, storing the one given
+// with the upload as the file's own
+func (d *Distributor) planPlacement(file *FileInfo, opts UploadOptions) (*placementPlan, error) {
+	if d.metaStore == nil {
+		return nil, nil
+	}
+	if opts.Placement != nil {
+		if err := d.metaStore.PutFilePlacement(file.ID, *opts.Placement); err != nil {
+			return nil, fmt.Errorf("failed to store placement constraint: %v", err)
+		}
+	}
+	constraint, _, err := d.metaStore.EffectivePlacement(file.ID, opts.OwnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve placement constraint: %v", err)
+	}
+	return &placementPlan{d: d, file: file, constraint: constraint}, nil
+}
+
+// FilePlacement is the placement constraint in effect for a file and how its
+// current replicas measure up to it
+type FilePlacement struct {
+	FileID     string                       `json:"file_id"`
+	Constraint metadata.PlacementConstraint `json:"constraint"`
+	Source     string                       `json:"source"` // "file", "owner" or "" when unconstrained
+	// ApprovedNodes are the known nodes, this one included, the constraint allows
+	ApprovedNodes []string `json:"approved_nodes"`
+	// Violations lists shortfalls recorded during replication and replicas
+	// currently held on nodes the constraint does not allow
+	Violations []string `json:"violations"`
+}
+
+// Placement returns the effective placement constraint of a file owned by
+// ownerID and checks where its chunks are held against it
+func (d *Distributor) Placement(fileID, ownerID string) (*FilePlacement, error) {
+	if d.metaStore == nil {
+		return nil, fmt.Errorf("metadata store not available")
+	}
+	constraint, source, err := d.metaStore.EffectivePlacement(fileID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve placement constraint: %v", err)
+	}
+	placement := &FilePlacement{
+		FileID:        fileID,
+		Constraint:    constraint,
+		Source:        source,
+		ApprovedNodes: make([]string, 0),
+		Violations:    make([]string, 0),
+	}
+
+	labels := make(map[string][]string)
+	nodes := append(d.network.GetPeers(), d.network.LocalNode)
+	for _, node := range nodes {
+		labels[node.ID] = node.Labels
+		if constraint.Allows(node.ID, node.Labels) {
+			placement.ApprovedNodes = append(placement.ApprovedNodes, node.ID)
+		}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	file, ok := d.files[fileID]
+	if !ok {
+		return placement, nil
+	}
+	placement.Violations = append(placement.Violations, file.PlacementViolations...)
+	for _, chunkID := range file.Chunks {
+		chunk, ok := d.chunks[chunkID]
+		if !ok {
+			continue
+		}
+		for _, nodeID := range chunk.Nodes {
+			if !constraint.Allows(nodeID, labels[nodeID]) {
+				placement.Violations = append(placement.Violations,
+					fmt.Sprintf("chunk %d is held on unapproved node %s", chunk.Index, nodeID))
+			}
+		}
+	}
+	return placement, nil
+}
@@ -0,0 +1,127 @@
+package distributor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/failures"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// labelCluster gives nodes their labels and re-announces every node to its peers
+func labelCluster(nodes map[string]*Distributor, labels map[string][]string) {
+	for name, d := range nodes {
+		d.network.SetLocalLabels(labels[name])
+	}
+	for _, d := range nodes {
+		for _, other := range nodes {
+			local := other.network.LocalNode
+			d.network.RegisterPeer(&p2p.Node{ID: local.ID, Address: local.Address, Port: local.Port, Status: "online",
+				Capabilities: local.Capabilities, Labels: local.Labels})
+		}
+	}
+}
+
+func TestPlacementKeepsReplicasOnApprovedNodesAndFlagsShortfall(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	_, nodes := newMemoryCluster(t, "node-a", "node-b", "node-c", "node-d")
+	labelCluster(nodes, map[string][]string{
+		"node-a": {"trusted"},
+		"node-b": {"trusted", "eu"},
+		"node-c": {"trusted"},
+		"node-d": {"eu"},
+	})
+	a := nodes["node-a"]
+	a.SetDurability(DurabilityAll, 0)
+	log := failures.NewLog(a.metaStore)
+	a.SetFailureLog(log)
+
+	if err := a.metaStore.PutOwnerPlacement("alice", metadata.PlacementConstraint{RequiredLabels: []string{"trusted"}}); err != nil {
+		t.Fatalf("PutOwnerPlacement failed: %v", err)
+	}
+
+	input := filepath.Join(t.TempDir(), "ledger.txt")
+	if err := os.WriteFile(input, []byte(strings.Repeat("confidential ledger line\n", 2000)), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	// Three copies fit on the three trusted nodes
+	file, err := a.DistributeFileWithOptions(t.Context(), input, UploadOptions{Password: "pw", Replicas: 3, OwnerID: "alice"})
+	if err != nil {
+		t.Fatalf("upload within approved capacity failed: %v", err)
+	}
+	placement, err := a.Placement(file.ID, "alice")
+	if err != nil {
+		t.Fatalf("Placement failed: %v", err)
+	}
+	if placement.Source != metadata.PlacementSourceOwner || len(placement.Violations) != 0 {
+		t.Fatalf("expected the owner's constraint with no violations, got %+v", placement)
+	}
+	for _, chunkID := range file.Chunks {
+		chunk, _ := a.GetChunkInfo(chunkID)
+		for _, node := range chunk.Nodes {
+			if node == "node-d" {
+				t.Fatalf("chunk %d was placed on the untrusted node", chunk.Index)
+			}
+		}
+		if len(chunk.Nodes) != 3 {
+			t.Errorf("chunk %d has %d copies, want 3", chunk.Index, len(chunk.Nodes))
+		}
+	}
+
+	// A file pinned to node-b needs a second approved node that doesn't exist
+	other := filepath.Join(t.TempDir(), "residency.txt")
+	if err := os.WriteFile(other, []byte(strings.Repeat("eu resident record\n", 2000)), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	a.SetDurability(DurabilityLocal, 0)
+	file, err = a.DistributeFileWithOptions(t.Context(), other, UploadOptions{Password: "pw", Replicas: 3, OwnerID: "alice",
+		Placement: &metadata.PlacementConstraint{AllowedNodes: []string{"node-a", "node-b"}}})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	// With local durability replication finishes in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		placement, err = a.Placement(file.ID, "alice")
+		if err == nil && len(placement.Violations) >= len(file.Chunks) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a shortfall for every chunk, got %+v (%v)", placement, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if placement.Source != metadata.PlacementSourceFile {
+		t.Errorf("expected the file's own constraint to win, got source %q", placement.Source)
+	}
+	for _, chunkID := range file.Chunks {
+		chunk, _ := a.GetChunkInfo(chunkID)
+		for _, node := range chunk.Nodes {
+			if node != "node-a" && node != "node-b" {
+				t.Fatalf("chunk %d was placed on unapproved %s", chunk.Index, node)
+			}
+		}
+	}
+	recorded, err := log.Query(failures.Filter{Operation: failures.OpReplicate})
+	if err != nil {
+		t.Fatalf("failed to list failures: %v", err)
+	}
+	found := false
+	for _, f := range recorded {
+		if f.Stage == "placement" && f.FileID == file.ID && strings.Contains(f.Message, ErrPlacementUnsatisfied.Error()) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the shortfall in the failure log, got %+v", recorded)
+	}
+}
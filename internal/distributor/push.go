@@ -0,0 +1,210 @@
+package distributor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// PeerTransfer reports how pushing a file to one peer went
+type PeerTransfer struct {
+	FileID     string   `json:"file_id"`
+	FileName   string   `json:"file_name"`
+	Peer       string   `json:"peer"`
+	Chunks     int      `json:"chunks"`
+	Accepted   int      `json:"accepted"`   // Chunks the peer stored
+	Registered bool     `json:"registered"` // The peer recorded the file and can reassemble it
+	Failures   []string `json:"failures,omitempty"`
+}
+
+// FileRecords is the metadata a node needs to reassemble a file from its chunks
+type FileRecords struct {
+	File   metadata.FileMetadata    `json:"file"`
+	Chunks []metadata.ChunkMetadata `json:"chunks"`
+}
+
+// DistributeFileToPeer chunks a file on this node and pushes every chunk to
+// the node at peerAddress, given as "host:port" or "http://host:port", then
+// sends it the file's metadata so it can reassemble the file itself.
+func (d *Distributor) DistributeFileToPeer(path, password, peerAddress string) (*PeerTransfer, error) {
+	return d.DistributeSourceToPeer(context.Background(), chunker.FileSource(path), password, peerAddress)
+}
+
+// DistributeSourceToPeer works like DistributeFileToPeer for content that
+// need not be a file on disk. The chunks stay on this node only, apart from
+// what the redundancy policy places elsewhere for erasure-coded files. A file
+// with a server-managed key can only be read by a peer sharing the master key.
+func (d *Distributor) DistributeSourceToPeer(ctx context.Context, src chunker.Source, password, peerAddress string) (*PeerTransfer, error) {
+	peer, err := d.resolvePeerAddress(peerAddress)
+	if err != nil {
+		return nil, err
+	}
+	if d.metaStore == nil {
+		return nil, fmt.Errorf("pushing files to a peer needs a metadata store")
+	}
+
+	file, err := d.DistributeSourceWithOptions(ctx, src, UploadOptions{Password: password, Replicas: 1})
+	if err != nil {
+		return nil, err
+	}
+	transfer := &PeerTransfer{FileID: file.ID, FileName: file.Name, Peer: peer.ID, Chunks: len(file.Chunks)}
+
+	for _, chunkID := range file.Chunks {
+		chunk, err := d.GetChunkInfo(chunkID)
+		if err != nil {
+			transfer.Failures = append(transfer.Failures, err.Error())
+			continue
+		}
+		if err := d.sendChunkToPeer(ctx, chunk, nil, peer, nil); err != nil {
+			transfer.Failures = append(transfer.Failures, fmt.Sprintf("chunk %d: %v", chunk.Index, err))
+			continue
+		}
+		transfer.Accepted++
+		d.recordReplica(chunkID, peer.ID)
+	}
+	if transfer.Accepted == 0 {
+		return transfer, fmt.Errorf("peer %s accepted none of the %d chunks of %s", peer.ID, transfer.Chunks, file.Name)
+	}
+
+	if err := d.sendFileRecords(ctx, peer, file.ID); err != nil {
+		transfer.Failures = append(transfer.Failures, fmt.Sprintf("registration: %v", err))
+		return transfer, fmt.Errorf("peer %s took %d of %d chunks but did not register %s: %v", peer.ID, transfer.Accepted, transfer.Chunks, file.Name, err)
+	}
+	transfer.Registered = true
+	tracing.Logf(ctx, "📤 File '%s' pushed to %s: %d of %d chunks accepted", file.Name, peer.ID, transfer.Accepted, transfer.Chunks)
+	return transfer, nil
+}
+
+// resolvePeerAddress returns the known peer at address, or a node to reach
+// there when no peer is registered at it
+func (d *Distributor) resolvePeerAddress(address string) (*p2p.Node, error) {
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(address), "http://"), "/")
+	if peer := d.network.GetPeerByID(trimmed); peer != nil {
+		return peer, nil
+	}
+	host, portText, err := net.SplitHostPort(trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer address %q, expected host:port", address)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port in peer address %q", address)
+	}
+	host, err = p2p.NormalizeAddress(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer address %q: %v", address, err)
+	}
+
+	hostPort := p2p.HostPort(host, port)
+	for _, peer := range d.network.GetPeers() {
+		if peer.HostPort() == hostPort {
+			return peer, nil
+		}
+	}
+	return &p2p.Node{ID: hostPort, Address: host, Port: port, Status: "online", LastSeen: time.Now()}, nil
+}
+
+// sendFileRecords announces a file straight to peer along with its metadata
+// records, and reports whether the peer stored them
+func (d *Distributor) sendFileRecords(ctx context.Context, peer *p2p.Node, fileID string) error {
+	file, err := d.metaStore.GetFileMetadataByID(fileID)
+	if err != nil {
+		return fmt.Errorf("failed to get file metadata: %v", err)
+	}
+	chunks, err := d.metaStore.GetChunksByFileID(file.CurrentContentID(fileID))
+	if err != nil {
+		return fmt.Errorf("failed to get chunk metadata: %v", err)
+	}
+
+	d.mu.RLock()
+	info, ok := d.files[fileID]
+	if !ok {
+		d.mu.RUnlock()
+		return fmt.Errorf("file %s not found", fileID)
+	}
+	announcement := FileAnnouncement{File: info.clone(), Chunks: make([]*ChunkInfo, 0, len(info.Chunks))}
+	for _, chunkID := range info.Chunks {
+		if chunk, ok := d.chunks[chunkID]; ok {
+			announcement.Chunks = append(announcement.Chunks, chunk.clone())
+		}
+	}
+	d.mu.RUnlock()
+	announcement.File.ChunkResults = nil
+	announcement.Records = &FileRecords{File: file, Chunks: chunks}
+
+	msgData, err := json.Marshal(&p2p.NetworkMessage{
+		Type:      MessageFileAvailable,
+		From:      d.network.LocalNode.ID,
+		To:        peer.ID,
+		Data:      announcement,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal file announcement: %v", err)
+	}
+	resp, err := d.doPeerRequest(ctx, peer, "/message", 30*time.Second, func(url string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(msgData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build file announcement to %s: %v", peer.ID, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		tracing.Inject(ctx, req)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer answered status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// storeFileRecords stores the metadata records a peer sent along with a file
+// it pushed here. A file this node already has keeps its own records, and so
+// does a chunk another file here stored first: the pushed file is counted as
+// one more reference to it. The file is recorded by ID only, so a local file
+// of the same name keeps its name entry.
+func (d *Distributor) storeFileRecords(fileID string, records *FileRecords) error {
+	if d.metaStore == nil {
+		return fmt.Errorf("no metadata store for the records of %s", fileID)
+	}
+	if _, err := d.metaStore.GetFileMetadataByID(fileID); err == nil {
+		return nil
+	}
+	for _, chunk := range records.Chunks {
+		owner, err := d.metaStore.GetChunkMetadata(chunk.Hash)
+		switch {
+		case err != nil:
+			// The sender's count covers its own files, none of which are here
+			chunk.RefCount = 0
+		case owner.FileID == chunk.FileID && owner.Index == chunk.Index:
+			continue
+		default:
+			if _, err := d.metaStore.AddChunkReference(chunk); err != nil {
+				return fmt.Errorf("failed to store chunk reference: %v", err)
+			}
+			continue
+		}
+		if err := d.metaStore.PutChunkMetadata(chunk); err != nil {
+			return fmt.Errorf("failed to store chunk metadata: %v", err)
+		}
+	}
+	// The file record goes last, so the file appears only once it is complete
+	if err := d.metaStore.PutFileMetadataByID(fileID, records.File); err != nil {
+		return fmt.Errorf("failed to store file metadata by ID: %v", err)
+	}
+	return nil
+}
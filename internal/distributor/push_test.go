@@ -0,0 +1,131 @@
+package distributor
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// newPushNode builds a node named name reachable over transport at port
+func newPushNode(t *testing.T, transport *p2p.MemoryTransport, name string, port int) *Distributor {
+	t.Helper()
+	dir := t.TempDir()
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() { metaStore.Close() })
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	network := p2p.NewNetworkWithID(name, name+".test", port)
+	d := NewDistributor(network, store, metaStore)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chunk-transfer", d.HandleChunkTransfer)
+	mux.HandleFunc("/chunk", d.HandleChunkRequest)
+	mux.HandleFunc("/message", d.HandleMessage)
+	transport.Attach(network, mux)
+	return d
+}
+
+func TestDistributeFileToPeerLetsThePeerReassemble(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	// Two nodes that share nothing but the transport and don't know each other as peers
+	transport := p2p.NewMemoryTransport()
+	sender, receiver := newPushNode(t, transport, "sender", 7100), newPushNode(t, transport, "receiver", 7101)
+
+	input := writeChunkedInput(t, 3)
+	if _, err := sender.DistributeFileToPeer(input, "pw", "receiver.test"); err == nil {
+		t.Fatal("expected an address without a port to be rejected")
+	}
+	transfer, err := sender.DistributeFileToPeer(input, "pw", "http://receiver.test:7101")
+	if err != nil {
+		t.Fatalf("DistributeFileToPeer failed: %v", err)
+	}
+	if transfer.Chunks != 3 || transfer.Accepted != 3 || !transfer.Registered || len(transfer.Failures) != 0 {
+		t.Fatalf("expected all 3 chunks accepted and the file registered, got %+v", transfer)
+	}
+
+	if _, err := receiver.metaStore.GetFileMetadataByID(transfer.FileID); err != nil {
+		t.Fatalf("receiver has no metadata for the file: %v", err)
+	}
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := receiver.ReassembleFile(transfer.FileID, output, "pw"); err != nil {
+		t.Fatalf("receiver failed to reassemble the file: %v", err)
+	}
+	want, _ := os.ReadFile(input)
+	got, err := os.ReadFile(output)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("receiver reassembled different content (%v)", err)
+	}
+
+	// The sender records where the chunks went
+	file, _ := sender.GetFileInfo(transfer.FileID)
+	for _, chunkID := range file.Chunks {
+		chunk, _ := sender.GetChunkInfo(chunkID)
+		if !containsNode(chunk.Nodes, "receiver.test:7101") {
+			t.Errorf("chunk %s not recorded on the receiver: %v", chunkID, chunk.Nodes)
+		}
+	}
+}
+
+func TestPushedFileKeepsThePeersOwnRecords(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	transport := p2p.NewMemoryTransport()
+	sender, receiver := newPushNode(t, transport, "sender", 7102), newPushNode(t, transport, "receiver", 7103)
+
+	// The receiver has its own file under the same name, sharing the pushed file's first two chunks
+	input := writeChunkedInput(t, 3)
+	content, _ := os.ReadFile(input)
+	localInput := filepath.Join(t.TempDir(), filepath.Base(input))
+	if err := os.WriteFile(localInput, content[:2*chunker.MinChunkSize], 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	local, err := receiver.DistributeFile(localInput, "pw")
+	if err != nil {
+		t.Fatalf("local upload failed: %v", err)
+	}
+	receiver.WaitForReplication()
+	localMeta, _ := receiver.metaStore.GetFileMetadataByID(local.ID)
+
+	transfer, err := sender.DistributeFileToPeer(input, "pw", "http://receiver.test:7103")
+	if err != nil || !transfer.Registered {
+		t.Fatalf("DistributeFileToPeer failed: %+v (%v)", transfer, err)
+	}
+
+	if byName, err := receiver.metaStore.GetFileMetadata(filepath.Base(input)); err != nil || byName.FileSize != localMeta.FileSize {
+		t.Errorf("expected the receiver's own file kept under its name, got one of %d bytes (%v)", byName.FileSize, err)
+	}
+	for _, hash := range localMeta.ChunkHashes {
+		chunk, err := receiver.metaStore.GetChunkMetadata(hash)
+		if err != nil || chunk.FileID != local.ID {
+			t.Fatalf("expected chunk %s still owned by the receiver's file, got %q (%v)", hash, chunk.FileID, err)
+		}
+		if chunk.RefCount != 2 {
+			t.Errorf("chunk %s: expected the pushed file counted as a second reference, got %d", hash, chunk.RefCount)
+		}
+	}
+
+	for fileID, want := range map[string][]byte{local.ID: content[:2*chunker.MinChunkSize], transfer.FileID: content} {
+		output := filepath.Join(t.TempDir(), "out.txt")
+		if err := receiver.ReassembleFile(fileID, output, "pw"); err != nil {
+			t.Fatalf("receiver failed to reassemble %s: %v", fileID, err)
+		}
+		if got, _ := os.ReadFile(output); !bytes.Equal(got, want) {
+			t.Errorf("receiver reassembled different content for %s", fileID)
+		}
+	}
+}
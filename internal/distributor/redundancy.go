@@ -0,0 +1,101 @@
+package distributor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+// RedundancyPolicy chooses how each distributed file is protected. Small
+// files are cheapest to replicate whole; large files are erasure-coded,
+// which survives the same number of lost shards at a fraction of the space.
+type RedundancyPolicy struct {
+	// ErasureThreshold is the file size from which files are erasure-coded, 0 to always replicate
+	ErasureThreshold int64
+	// DataShards and ParityShards set the Reed-Solomon layout, RS(DataShards,ParityShards)
+	DataShards   int
+	ParityShards int
+	// ErasureCopies is the copies kept of each data chunk of an erasure-coded file, the local one included
+	ErasureCopies int
+}
+
+// DefaultRedundancyPolicy replicates files under 10MB and erasure-codes larger ones with RS(6,3)
+func DefaultRedundancyPolicy() RedundancyPolicy {
+	return RedundancyPolicy{
+		ErasureThreshold: 10 * 1024 * 1024,
+		DataShards:       6,
+		ParityShards:     3,
+		ErasureCopies:    2,
+	}
+}
+
+// RedundancyPolicyFromConfig reads the redundancy settings from the app config
+func RedundancyPolicyFromConfig(cfg *config.AppConfig) RedundancyPolicy {
+	policy := DefaultRedundancyPolicy()
+	if cfg == nil {
+		return policy
+	}
+	if cfg.ErasureThreshold >= 0 {
+		policy.ErasureThreshold = cfg.ErasureThreshold
+	}
+	if cfg.ErasureDataShards > 0 {
+		policy.DataShards = cfg.ErasureDataShards
+	}
+	if cfg.ErasureParityShards > 0 {
+		policy.ParityShards = cfg.ErasureParityShards
+	}
+	if cfg.ErasureCopies > 0 {
+		policy.ErasureCopies = cfg.ErasureCopies
+	}
+	return policy
+}
+
+// ParseRedundancyScheme converts an upload override ("", "auto", "replication",
+// "erasure") to a scheme; "" means the policy decides
+func ParseRedundancyScheme(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "auto":
+		return "", nil
+	case metadata.RedundancyReplication, "replicate":
+		return metadata.RedundancyReplication, nil
+	case metadata.RedundancyErasure, "ec":
+		return metadata.RedundancyErasure, nil
+	default:
+		return "", fmt.Errorf("unknown redundancy scheme: %s", value)
+	}
+}
+
+// Choose returns the scheme for a file of the given size. A non-empty
+// override, as returned by ParseRedundancyScheme, wins over the thresholds.
+func (p RedundancyPolicy) Choose(size int64, override string) string {
+	if override != "" {
+		return override
+	}
+	if p.ErasureThreshold > 0 && size >= p.ErasureThreshold {
+		return metadata.RedundancyErasure
+	}
+	return metadata.RedundancyReplication
+}
+
+// SetRedundancyPolicy sets how files distributed from now on are protected
+func (d *Distributor) SetRedundancyPolicy(policy RedundancyPolicy) error {
+	if policy.DataShards < 1 || policy.ParityShards < 1 {
+		return fmt.Errorf("invalid erasure layout RS(%d,%d)", policy.DataShards, policy.ParityShards)
+	}
+	if policy.ErasureCopies < 1 {
+		policy.ErasureCopies = 1
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.redundancy = policy
+	return nil
+}
+
+// GetRedundancyPolicy returns the configured redundancy policy
+func (d *Distributor) GetRedundancyPolicy() RedundancyPolicy {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.redundancy
+}
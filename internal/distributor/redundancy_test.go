@@ -0,0 +1,99 @@
+package distributor
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+func TestRedundancySchemeFollowsSizeThreshold(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	d := NewDistributor(p2p.NewNetwork("127.0.0.1", 0), store, metaStore)
+	threshold := 3 * chunker.MinChunkSize
+	if err := d.SetRedundancyPolicy(RedundancyPolicy{ErasureThreshold: threshold, DataShards: 2, ParityShards: 1, ErasureCopies: 1}); err != nil {
+		t.Fatalf("SetRedundancyPolicy failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(7))
+	write := func(name string, size int64) (string, []byte) {
+		content := make([]byte, size)
+		rng.Read(content)
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, content, 0644)
+		return path, content
+	}
+	smallPath, _ := write("small.bin", threshold-1)
+	largePath, large := write("large.bin", threshold+chunker.MinChunkSize/2)
+	overridePath, _ := write("override.bin", chunker.MinChunkSize)
+
+	cases := []struct {
+		path     string
+		override string
+		want     string
+	}{
+		{smallPath, "", metadata.RedundancyReplication},
+		{largePath, "", metadata.RedundancyErasure},
+		{overridePath, metadata.RedundancyErasure, metadata.RedundancyErasure},
+	}
+	var largeID string
+	for _, tc := range cases {
+		file, err := d.DistributeFileWithRedundancy(context.Background(), tc.path, "pw", tc.override)
+		if err != nil {
+			t.Fatalf("distributing %s failed: %v", tc.path, err)
+		}
+		meta, err := metaStore.GetFileMetadataByID(file.ID)
+		if err != nil {
+			t.Fatalf("no metadata for %s: %v", file.ID, err)
+		}
+		if file.Redundancy != tc.want || meta.RedundancyScheme() != tc.want {
+			t.Errorf("%s (override %q): expected %s, got %s in file info and %s in metadata",
+				filepath.Base(tc.path), tc.override, tc.want, file.Redundancy, meta.RedundancyScheme())
+		}
+		if tc.path == largePath {
+			largeID = file.ID
+		}
+	}
+
+	usage, err := metaStore.RedundancyDistribution()
+	if err != nil {
+		t.Fatalf("RedundancyDistribution failed: %v", err)
+	}
+	if usage[metadata.RedundancyErasure].Files != 2 || usage[metadata.RedundancyReplication].Files != 1 {
+		t.Errorf("expected two erasure-coded files and one replicated file, got %+v", usage)
+	}
+
+	// Reassembly honors the scheme: a lost chunk is rebuilt from parity
+	chunks, _ := metaStore.GetChunksByFileID(largeID)
+	if err := store.Delete(chunks[1].Path); err != nil {
+		t.Fatalf("failed to delete chunk: %v", err)
+	}
+	out := filepath.Join(dir, "large-out.bin")
+	if err := chunker.ReassembleFile(largeID, out, "pw", metaStore, store); err != nil {
+		t.Fatalf("reassembly with a lost chunk failed: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, large) {
+		t.Errorf("reassembled file does not match the original")
+	}
+}
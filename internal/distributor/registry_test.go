@@ -0,0 +1,119 @@
+package distributor
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// Run with -race: uploads replicate in the background while other
+// goroutines list files and read chunk records
+func TestConcurrentUploadsAndListing(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer peer.Close()
+	host, portStr, _ := net.SplitHostPort(peer.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	network.RegisterPeer(&p2p.Node{ID: "peer-1", Address: host, Port: port, Status: "online", LastSeen: time.Now()})
+	d := NewDistributor(network, store, metaStore)
+	d.SetReplicaCount(2)
+
+	const uploads = 8
+	paths := make([]string, uploads)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("file-%d.bin", i))
+		os.WriteFile(paths[i], bytes.Repeat([]byte(fmt.Sprintf("payload %d ", i)), 2*int(chunker.MinChunkSize)/10), 0644)
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, file := range d.GetAllFiles() {
+					if fi, err := d.GetFileInfo(file.ID); err == nil {
+						_ = len(fi.Nodes)
+					}
+					for _, chunkID := range file.Chunks {
+						if chunk, err := d.GetChunkInfo(chunkID); err == nil {
+							_ = len(chunk.Nodes)
+						}
+						d.findMissingChunks([]string{chunkID})
+					}
+				}
+				d.SpreadStats()
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	errs := make(chan error, uploads)
+	for _, path := range paths {
+		writers.Add(1)
+		go func(path string) {
+			defer writers.Done()
+			if _, err := d.DistributeFile(path, "pw"); err != nil {
+				errs <- err
+			}
+		}(path)
+	}
+	writers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent upload failed: %v", err)
+	}
+
+	// Let background replication finish while readers are still running
+	time.Sleep(200 * time.Millisecond)
+	close(done)
+	readers.Wait()
+
+	files := d.GetAllFiles()
+	if len(files) != uploads {
+		t.Fatalf("expected %d files in the registry, got %d", uploads, len(files))
+	}
+
+	// Listings are snapshots: changing one leaves the registry untouched
+	files[0].Chunks = nil
+	if again, _ := d.GetFileInfo(files[0].ID); len(again.Chunks) == 0 {
+		t.Errorf("modifying a listed file changed the registry")
+	}
+}
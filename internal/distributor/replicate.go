@@ -0,0 +1,48 @@
+package distributor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// ReplicateChunk copies a chunk to the node nodeID and records the new
+// replica. The bytes come from this node's copy; a chunk this node does not
+// hold is first fetched here from a surviving replica. Copying to the local
+// node only fetches it.
+func (d *Distributor) ReplicateChunk(ctx context.Context, chunkID, nodeID string) error {
+	chunk, local := d.localChunk(chunkID)
+	if chunk == nil {
+		return fmt.Errorf("chunk %s not found", chunkID)
+	}
+	if !local {
+		if err := d.downloadMissingChunks(ctx, []string{chunkID}); err != nil {
+			return fmt.Errorf("no surviving replica of chunk %s to copy: %v", chunkID, err)
+		}
+	}
+	if nodeID == d.network.LocalNode.ID {
+		return nil
+	}
+
+	peer := d.network.GetPeerByID(nodeID)
+	if peer == nil {
+		return fmt.Errorf("unknown node %s", nodeID)
+	}
+	if err := d.sendChunkToPeer(ctx, chunk, nil, peer, nil); err != nil {
+		return err
+	}
+	d.recordReplica(chunkID, peer.ID)
+	tracing.Logf(ctx, "📋 Chunk %s replicated to %s", chunkID, peer.ID)
+	return nil
+}
+
+// recordReplica notes that nodeID stores a copy of a chunk
+func (d *Distributor) recordReplica(chunkID, nodeID string) {
+	d.mu.Lock()
+	if stored, ok := d.chunks[chunkID]; ok && !containsNode(stored.Nodes, nodeID) {
+		stored.Nodes = append(stored.Nodes, nodeID)
+	}
+	d.mu.Unlock()
+	d.network.AddChunkToNode(nodeID, chunkID)
+}
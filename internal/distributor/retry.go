@@ -0,0 +1,128 @@
+package distributor
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+const (
+	DefaultTransferRetries     = 3
+	DefaultTransferRetryDelay  = 200 * time.Millisecond
+	DefaultTransferRetryJitter = 0.2
+	// maxTransferRetryDelay caps the doubled wait between attempts
+	maxTransferRetryDelay = 10 * time.Second
+)
+
+// RetryPolicy controls how a chunk transfer to a peer is retried after a
+// transient failure: the peer cannot be reached, or answers with a server
+// error other than being full
+type RetryPolicy struct {
+	MaxRetries int           // Extra attempts after the first, 0 tries once
+	BaseDelay  time.Duration // Wait before the first retry, doubled for each one after
+	Jitter     float64       // Fraction of each wait taken off at random (0-1), so senders don't retry in lockstep
+}
+
+// DefaultRetryPolicy retries a few times over about a second and a half
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxRetries: DefaultTransferRetries, BaseDelay: DefaultTransferRetryDelay, Jitter: DefaultTransferRetryJitter}
+}
+
+// RetryPolicyFromConfig reads the transfer retry policy from the app config
+func RetryPolicyFromConfig(cfg *config.AppConfig) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	if cfg.TransferRetries >= 0 {
+		policy.MaxRetries = cfg.TransferRetries
+	}
+	if cfg.TransferRetryDelayMs > 0 {
+		policy.BaseDelay = time.Duration(cfg.TransferRetryDelayMs) * time.Millisecond
+	}
+	if cfg.TransferRetryJitter >= 0 && cfg.TransferRetryJitter <= 1 {
+		policy.Jitter = cfg.TransferRetryJitter
+	}
+	return policy
+}
+
+// delay returns the wait before retry number attempt, counted from 0
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	wait := p.BaseDelay
+	for i := 0; i < attempt && wait < maxTransferRetryDelay; i++ {
+		wait *= 2
+	}
+	if wait > maxTransferRetryDelay {
+		wait = maxTransferRetryDelay
+	}
+	if p.Jitter > 0 {
+		wait -= time.Duration(rand.Float64() * p.Jitter * float64(wait))
+	}
+	return wait
+}
+
+// SetRetryPolicy sets how many times a failed chunk transfer to a peer is
+// retried and the wait before the first retry, which doubles for each one
+// after. The jitter keeps its configured value.
+func (d *Distributor) SetRetryPolicy(maxRetries int, baseDelay time.Duration) {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.retry.MaxRetries = maxRetries
+	d.retry.BaseDelay = baseDelay
+}
+
+// SetRetryJitter sets the fraction (0-1) of each retry wait taken off at random
+func (d *Distributor) SetRetryJitter(jitter float64) {
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.retry.Jitter = jitter
+}
+
+// GetRetryPolicy returns the current transfer retry policy
+func (d *Distributor) GetRetryPolicy() RetryPolicy {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.retry
+}
+
+// retryableTransfer reports whether a chunk transfer that ended with status
+// and err may succeed if sent again. A full peer or a rejected request will not.
+func retryableTransfer(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return status >= http.StatusInternalServerError && status != http.StatusInsufficientStorage
+}
+
+// postChunkTransferWithRetry posts a chunk transfer to peer, retrying
+// transient failures with exponential backoff, and returns the last status
+func (d *Distributor) postChunkTransferWithRetry(ctx context.Context, peer *p2p.Node, transferReq map[string]interface{}) (int, error) {
+	policy := d.GetRetryPolicy()
+	for attempt := 0; ; attempt++ {
+		status, err := d.postChunkTransfer(ctx, peer, transferReq)
+		if !retryableTransfer(status, err) || attempt >= policy.MaxRetries {
+			if attempt > 0 && err == nil && status == http.StatusOK {
+				tracing.Logf(ctx, "🔁 Chunk %v reached %s on attempt %d", transferReq["chunk_id"], peer.ID, attempt+1)
+			}
+			return status, err
+		}
+
+		wait := policy.delay(attempt)
+		if err != nil {
+			tracing.Logf(ctx, "🔁 Chunk transfer to %s failed (%v), retry %d of %d in %v", peer.ID, err, attempt+1, policy.MaxRetries, wait)
+		} else {
+			tracing.Logf(ctx, "🔁 Chunk transfer to %s answered %d, retry %d of %d in %v", peer.ID, status, attempt+1, policy.MaxRetries, wait)
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,106 @@
+package distributor
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChunkTransferRetriesAFlakyPeer(t *testing.T) {
+	// The peer fails the first two attempts and takes the chunk on the third
+	var attempts atomic.Int32
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chunk-transfer" {
+			http.NotFound(w, r)
+			return
+		}
+		if attempts.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	d.SetDurability(DurabilityAll, 0)
+	d.SetRetryPolicy(3, time.Millisecond)
+
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("expected 3 transfer attempts, got %d", got)
+	}
+	if file.AchievedReplicas != 2 {
+		t.Errorf("expected 2 copies, got %d", file.AchievedReplicas)
+	}
+	if len(file.ChunkResults) != 1 || !file.ChunkResults[0].Complete() || len(file.ChunkResults[0].Failures) != 0 {
+		t.Errorf("expected one complete chunk result, got %+v", file.ChunkResults)
+	}
+}
+
+func TestChunkTransferReportsPartialFailure(t *testing.T) {
+	var attempts atomic.Int32
+	d, inputPath := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chunk-transfer" {
+			http.NotFound(w, r)
+			return
+		}
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	d.SetDurability(DurabilityQuorum, 1)
+	d.SetRetryPolicy(2, time.Millisecond)
+
+	// The local copy meets the quorum, so the upload succeeds with the shortfall reported
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("expected 3 transfer attempts, got %d", got)
+	}
+	if len(file.ChunkResults) != 1 {
+		t.Fatalf("expected one chunk result, got %+v", file.ChunkResults)
+	}
+	result := file.ChunkResults[0]
+	if result.Complete() || result.Copies != 1 || result.Wanted != 2 {
+		t.Errorf("expected the chunk short of its second copy, got %+v", result)
+	}
+	if len(result.Failures) != 1 || !strings.HasPrefix(result.Failures[0], "peer-1: ") {
+		t.Errorf("expected the failure on peer-1 reported, got %v", result.Failures)
+	}
+
+	// A full peer is not retried
+	attempts.Store(0)
+	d2, inputPath2 := newTestDistributor(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chunk-transfer" {
+			http.NotFound(w, r)
+			return
+		}
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInsufficientStorage)
+	})
+	d2.SetRetryPolicy(2, time.Millisecond)
+	d2.SetDurability(DurabilityQuorum, 1)
+	if _, err := d2.DistributeFile(inputPath2, "pw"); err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("expected a full peer tried once, got %d attempts", got)
+	}
+}
+
+func TestRetryDelayBacksOffWithJitter(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 5, BaseDelay: 100 * time.Millisecond, Jitter: 0.5}
+	for attempt, full := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		wait := policy.delay(attempt)
+		if wait > full || wait < full/2 {
+			t.Errorf("retry %d waited %v, expected between %v and %v", attempt, wait, full/2, full)
+		}
+	}
+	if wait := (RetryPolicy{BaseDelay: time.Second}).delay(20); wait != maxTransferRetryDelay {
+		t.Errorf("expected the wait capped at %v, got %v", maxTransferRetryDelay, wait)
+	}
+}
@@ -0,0 +1,231 @@
+package distributor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+)
+
+// DefaultMaxNodeFraction is the largest share of a file's chunks sent to any one peer
+const DefaultMaxNodeFraction = 0.5
+
+// FileSpread describes how a file's chunks are spread across nodes. The
+// origin node keeps a copy of every chunk, so the limit applies to peers.
+type FileSpread struct {
+	FileID           string         `json:"file_id"`
+	FileName         string         `json:"file_name"`
+	Chunks           int            `json:"chunks"`
+	Origin           string         `json:"origin"`
+	NodeChunks       map[string]int `json:"node_chunks"`       // Chunks held per node, origin included
+	MaxPeerFraction  float64        `json:"max_peer_fraction"` // Largest share held by a single peer
+	Limit            float64        `json:"limit"`             // Configured cap, 0 when disabled
+	SurvivesNodeLoss bool           `json:"survives_node_loss"`
+	Violations       []string       `json:"violations,omitempty"`
+}
+
+// SetSpread caps the fraction of a file's chunks placed on any single peer.
+// A fraction <= 0 or >= 1 removes the cap; replicas are still sent to the
+// least loaded peers first.
+func (d *Distributor) SetSpread(maxFraction float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if maxFraction <= 0 || maxFraction >= 1 {
+		maxFraction = 0
+	}
+	d.maxNodeFraction = maxFraction
+}
+
+// SetZoneSpread makes uploads place each chunk's replicas in zones, then
+// racks, that hold no copy of it yet before doubling up on one, as the
+// zone_aware strategy does for rebalancing. Nodes announcing no zone share one.
+func (d *Distributor) SetZoneSpread(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.zoneSpread = enabled
+}
+
+// spreadPlanner assigns a file's chunk replicas to peers so that no peer
+// exceeds its share. Chunks are distributed concurrently, so picks are
+// reserved under a lock and released again when a transfer fails.
+type spreadPlanner struct {
+	limit  int  // Chunks per peer, 0 for no cap
+	zones  bool // Prefer peers outside the zones and racks a chunk is already in
+	counts map[string]int
+	mu     sync.Mutex
+}
+
+func newSpreadPlanner(chunks int, maxFraction float64, zones bool) *spreadPlanner {
+	return &spreadPlanner{
+		limit:  spreadLimit(chunks, maxFraction),
+		zones:  zones,
+		counts: make(map[string]int),
+	}
+}
+
+// spreadLimit converts the fraction into a chunk count; every peer may hold at least one chunk
+func spreadLimit(chunks int, maxFraction float64) int {
+	if maxFraction <= 0 {
+		return 0
+	}
+	limit := int(maxFraction * float64(chunks))
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// pick reserves the least loaded peer not yet tried for this chunk. Peers
+// at the limit are only used when no other peer is left, which shows up as
+// a violation in the file's spread report. With zone spread, peers in a zone
+// none of placed is in come first, then peers in a new rack.
+func (p *spreadPlanner) pick(peers []*p2p.Node, tried map[string]bool, placed []*p2p.Node) *p2p.Node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	better := func(peer, than *p2p.Node) bool {
+		if than == nil {
+			return true
+		}
+		if rank, thanRank := p.domainRank(peer, placed), p.domainRank(than, placed); rank != thanRank {
+			return rank < thanRank
+		}
+		return p.counts[peer.ID] < p.counts[than.ID]
+	}
+
+	var best, fallback *p2p.Node
+	for _, peer := range peers {
+		if tried[peer.ID] {
+			continue
+		}
+		if better(peer, fallback) {
+			fallback = peer
+		}
+		if p.limit > 0 && p.counts[peer.ID] >= p.limit {
+			continue
+		}
+		if better(peer, best) {
+			best = peer
+		}
+	}
+
+	if best == nil {
+		best = fallback
+	}
+	if best != nil {
+		p.counts[best.ID]++
+	}
+	return best
+}
+
+// domainRank is 0 for a peer in a zone none of placed is in, 1 for one in a
+// new rack of a used zone and 2 otherwise; always 0 without zone spread
+func (p *spreadPlanner) domainRank(peer *p2p.Node, placed []*p2p.Node) int {
+	if !p.zones {
+		return 0
+	}
+	rank := 0
+	for _, node := range placed {
+		if node.Zone != peer.Zone {
+			continue
+		}
+		if node.Rack == peer.Rack {
+			return 2
+		}
+		rank = 1
+	}
+	return rank
+}
+
+// release undoes a reservation after a failed transfer
+func (p *spreadPlanner) release(peerID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.counts[peerID] > 0 {
+		p.counts[peerID]--
+	}
+}
+
+// FileSpread reports the current node spread of a distributed file
+func (d *Distributor) FileSpread(fileID string) (*FileSpread, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	file, exists := d.files[fileID]
+	if !exists {
+		return nil, fmt.Errorf("file not found: %s", fileID)
+	}
+	return d.fileSpreadLocked(file), nil
+}
+
+// SpreadStats reports the node spread of every distributed file
+func (d *Distributor) SpreadStats() []*FileSpread {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	spreads := make([]*FileSpread, 0, len(d.files))
+	for _, file := range d.files {
+		spreads = append(spreads, d.fileSpreadLocked(file))
+	}
+	sort.Slice(spreads, func(i, j int) bool {
+		return spreads[i].FileID < spreads[j].FileID
+	})
+	return spreads
+}
+
+func (d *Distributor) fileSpreadLocked(file *FileInfo) *FileSpread {
+	spread := &FileSpread{
+		FileID:           file.ID,
+		FileName:         file.Name,
+		Chunks:           len(file.Chunks),
+		Origin:           file.Owner,
+		NodeChunks:       make(map[string]int),
+		Limit:            d.maxNodeFraction,
+		SurvivesNodeLoss: true,
+	}
+
+	for _, chunkID := range file.Chunks {
+		chunk, ok := d.chunks[chunkID]
+		if !ok {
+			continue
+		}
+		holders := make(map[string]bool)
+		for _, nodeID := range chunk.Nodes {
+			holders[nodeID] = true
+		}
+		for nodeID := range holders {
+			spread.NodeChunks[nodeID]++
+		}
+		// A chunk on a single node is lost with that node
+		if len(holders) < 2 {
+			spread.SurvivesNodeLoss = false
+		}
+	}
+
+	if spread.Chunks == 0 {
+		return spread
+	}
+	peers := make([]string, 0, len(spread.NodeChunks))
+	for nodeID := range spread.NodeChunks {
+		if nodeID != spread.Origin {
+			peers = append(peers, nodeID)
+		}
+	}
+	sort.Strings(peers)
+	limit := spreadLimit(spread.Chunks, spread.Limit)
+	for _, nodeID := range peers {
+		fraction := float64(spread.NodeChunks[nodeID]) / float64(spread.Chunks)
+		if fraction > spread.MaxPeerFraction {
+			spread.MaxPeerFraction = fraction
+		}
+		if limit > 0 && spread.NodeChunks[nodeID] > limit {
+			spread.Violations = append(spread.Violations,
+				fmt.Sprintf("peer %s holds %d of %d chunks", nodeID, spread.NodeChunks[nodeID], spread.Chunks))
+		}
+	}
+	if !spread.SurvivesNodeLoss {
+		spread.Violations = append(spread.Violations, "some chunks have a single copy")
+	}
+	return spread
+}
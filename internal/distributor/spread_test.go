@@ -0,0 +1,135 @@
+package distributor
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// newSpreadTestDistributor builds a distributor with peers that accept every chunk
+// and a 10-chunk input file
+func newSpreadTestDistributor(t *testing.T, peers int) (*Distributor, string) {
+	t.Helper()
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: 4096}
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	for i := 0; i < peers; i++ {
+		peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(peer.Close)
+		host, portStr, _ := net.SplitHostPort(peer.Listener.Addr().String())
+		port, _ := strconv.Atoi(portStr)
+		network.RegisterPeer(&p2p.Node{ID: fmt.Sprintf("peer-%d", i), Address: host, Port: port, Status: "online", LastSeen: time.Now()})
+	}
+
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	data := make([]byte, 10*4096)
+	rand.Read(data)
+	inputPath := filepath.Join(tempDir, "input.bin")
+	if err := os.WriteFile(inputPath, data, 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	d := NewDistributor(network, store, nil)
+	d.SetReplicaCount(2)
+	d.SetDurability(DurabilityAll, 0)
+	return d, inputPath
+}
+
+func TestDistributeFileSpreadsChunksAcrossPeers(t *testing.T) {
+	d, inputPath := newSpreadTestDistributor(t, 4)
+	d.SetSpread(0.3)
+
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	spread, err := d.FileSpread(file.ID)
+	if err != nil {
+		t.Fatalf("FileSpread failed: %v", err)
+	}
+	if spread.Chunks != 10 {
+		t.Fatalf("expected 10 chunks, got %d", spread.Chunks)
+	}
+
+	for nodeID, count := range spread.NodeChunks {
+		if nodeID != spread.Origin && count > 3 {
+			t.Errorf("peer %s holds %d of 10 chunks, limit is 3", nodeID, count)
+		}
+	}
+	if spread.MaxPeerFraction > 0.3 || len(spread.Violations) != 0 {
+		t.Errorf("spread limit not honored: %+v", spread)
+	}
+	if !spread.SurvivesNodeLoss {
+		t.Errorf("losing one node must not make the file unrecoverable: %+v", spread)
+	}
+}
+
+func TestFileSpreadReportsUnavoidableViolations(t *testing.T) {
+	// With a single peer every replica has to land on it
+	d, inputPath := newSpreadTestDistributor(t, 1)
+
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	spread, _ := d.FileSpread(file.ID)
+	if spread.NodeChunks["peer-0"] != 10 || len(spread.Violations) != 1 {
+		t.Errorf("expected the overloaded peer to be reported, got %+v", spread)
+	}
+	if !spread.SurvivesNodeLoss {
+		t.Errorf("every chunk still has two copies: %+v", spread)
+	}
+	if stats := d.SpreadStats(); len(stats) != 1 || stats[0].FileID != file.ID {
+		t.Errorf("expected the file in spread stats, got %+v", stats)
+	}
+}
+
+func TestDistributeFileSpreadsReplicasAcrossZones(t *testing.T) {
+	d, inputPath := newSpreadTestDistributor(t, 4)
+	d.SetSpread(0)
+	d.SetReplicaCount(3)
+	d.SetZoneSpread(true)
+
+	// The origin shares zone-1 and rack-1 with peer-0; peer-3 is alone in zone-2
+	d.network.SetLocalTopology("zone-1", "rack-1")
+	for i, topology := range [][2]string{{"zone-1", "rack-1"}, {"zone-1", "rack-2"}, {"zone-1", "rack-3"}, {"zone-2", "rack-4"}} {
+		peer := d.network.GetPeerByID(fmt.Sprintf("peer-%d", i))
+		peer.Zone, peer.Rack = topology[0], topology[1]
+	}
+
+	file, err := d.DistributeFile(inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFile failed: %v", err)
+	}
+	spread, err := d.FileSpread(file.ID)
+	if err != nil {
+		t.Fatalf("FileSpread failed: %v", err)
+	}
+	// Every chunk gets its zone-2 copy, and its other copy outside the origin's rack
+	if spread.NodeChunks["peer-3"] != spread.Chunks {
+		t.Errorf("expected every chunk replicated to zone-2, got %v", spread.NodeChunks)
+	}
+	if spread.NodeChunks["peer-0"] != 0 {
+		t.Errorf("expected no replica in the origin's rack while other racks are free, got %v", spread.NodeChunks)
+	}
+	if spread.NodeChunks["peer-1"]+spread.NodeChunks["peer-2"] != spread.Chunks {
+		t.Errorf("expected the third copies in zone-1's other racks, got %v", spread.NodeChunks)
+	}
+}
@@ -0,0 +1,108 @@
+package distributor
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+func TestTraceIDFollowsUploadAndReassembly(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	var logs bytes.Buffer
+	previous := tracing.SetOutput(&logs)
+	defer tracing.SetOutput(previous)
+
+	// The peer records the trace ID each chunk transfer arrives with
+	var mu sync.Mutex
+	var peerTraces []string
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunk-transfer" {
+			mu.Lock()
+			peerTraces = append(peerTraces, r.Header.Get(tracing.Header))
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer peer.Close()
+	host, portStr, _ := net.SplitHostPort(peer.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	tempDir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	network := p2p.NewNetwork("127.0.0.1", 0)
+	network.RegisterPeer(&p2p.Node{ID: "peer-1", Address: host, Port: port, Status: "online", LastSeen: time.Now()})
+	d := NewDistributor(network, store, metaStore)
+	d.SetReplicaCount(2)
+	d.SetDurability(DurabilityAll, 0)
+
+	inputPath := filepath.Join(tempDir, "traced.bin")
+	os.WriteFile(inputPath, bytes.Repeat([]byte("trace me "), 3*int(chunker.MinChunkSize)/9), 0644)
+
+	ctx := tracing.WithID(context.Background(), "op-42")
+	file, err := d.DistributeFileContext(ctx, inputPath, "pw")
+	if err != nil {
+		t.Fatalf("DistributeFileContext failed: %v", err)
+	}
+	if err := d.ReassembleFileContext(ctx, file.ID, filepath.Join(tempDir, "out.bin"), "pw"); err != nil {
+		t.Fatalf("ReassembleFileContext failed: %v", err)
+	}
+
+	// Chunking, replication, distribution and reassembly all log under the one ID
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	for _, want := range []string{"Chunked traced.bin", "sent to peer peer-1", "distributed with", "Verified", "reassembled successfully"} {
+		found := false
+		for _, line := range lines {
+			if strings.Contains(line, want) {
+				found = true
+				if !strings.HasPrefix(line, "[trace=op-42] ") {
+					t.Errorf("log line is missing the trace ID: %q", line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no log line for %q in:\n%s", want, logs.String())
+		}
+	}
+
+	mu.Lock()
+	if len(peerTraces) != len(file.Chunks) {
+		t.Errorf("expected %d chunk transfers, peer saw %d", len(file.Chunks), len(peerTraces))
+	}
+	for _, id := range peerTraces {
+		if id != "op-42" {
+			t.Errorf("chunk transfer carried trace ID %q", id)
+		}
+	}
+	mu.Unlock()
+
+	if err := d.ReassembleFileContext(ctx, "missing", filepath.Join(tempDir, "none.bin"), "pw"); err == nil || !strings.Contains(err.Error(), "[trace=op-42]") {
+		t.Errorf("expected the error to carry the trace ID, got %v", err)
+	}
+}
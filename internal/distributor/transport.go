@@ -0,0 +1,143 @@
+package distributor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// TransportPolicy picks the transport requests to peers go over first and
+// whether they move on to the other one a peer advertises when it fails
+type TransportPolicy struct {
+	Preferred string // p2p.TransportHTTP or p2p.TransportTCP
+	Fallback  bool
+}
+
+// DefaultTransportPolicy prefers HTTP and falls back to TCP
+func DefaultTransportPolicy() TransportPolicy {
+	return TransportPolicy{Preferred: p2p.TransportHTTP, Fallback: true}
+}
+
+// ParseTransport converts a config value ("http" or "tcp") to a transport name
+func ParseTransport(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", p2p.TransportHTTP:
+		return p2p.TransportHTTP, nil
+	case p2p.TransportTCP:
+		return p2p.TransportTCP, nil
+	default:
+		return p2p.TransportHTTP, fmt.Errorf("unknown transport: %s", value)
+	}
+}
+
+// TransportPolicyFromConfig reads the transport policy from the app config
+func TransportPolicyFromConfig(cfg *config.AppConfig) (TransportPolicy, error) {
+	preferred, err := ParseTransport(cfg.PreferredTransport)
+	return TransportPolicy{Preferred: preferred, Fallback: cfg.TransportFallback}, err
+}
+
+// SetTCPTransport lets requests to peers go over the TCP network, for peers
+// that advertise it
+func (d *Distributor) SetTCPTransport(tcp http.RoundTripper) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tcpTransport = tcp
+}
+
+// SetTransportPolicy sets the preferred transport and whether to fall back
+func (d *Distributor) SetTransportPolicy(policy TransportPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.transportPolicy = policy
+}
+
+// PeerTransport returns the transport the last successful request to a peer
+// went over, "" before any has succeeded
+func (d *Distributor) PeerTransport(peerID string) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.peerTransports[peerID]
+}
+
+// peerRoute is one way of reaching a peer
+type peerRoute struct {
+	transport string
+	port      int
+	client    *http.Client
+}
+
+// peerRoutes lists the transports to try for a peer in order: the one that
+// last worked, then the preferred one, then the rest, limited to the first
+// when fallback is off
+func (d *Distributor) peerRoutes(peer *p2p.Node, timeout time.Duration) []peerRoute {
+	d.mu.RLock()
+	policy, tcp, last := d.transportPolicy, d.tcpTransport, d.peerTransports[peer.ID]
+	d.mu.RUnlock()
+
+	order := []string{policy.Preferred, p2p.TransportHTTP, p2p.TransportTCP}
+	if last != "" {
+		order = append([]string{last}, order...)
+	}
+	var routes []peerRoute
+	seen := make(map[string]bool)
+	for _, name := range order {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		port, ok := peer.TransportPort(name)
+		if !ok {
+			continue
+		}
+		client := d.network.Client(timeout)
+		if name == p2p.TransportTCP {
+			if tcp == nil {
+				continue
+			}
+			client = &http.Client{Timeout: timeout, Transport: tcp}
+		}
+		routes = append(routes, peerRoute{transport: name, port: port, client: client})
+	}
+	if !policy.Fallback && len(routes) > 1 {
+		routes = routes[:1]
+	}
+	return routes
+}
+
+// doPeerRequest sends a request built for path to a peer over each of its
+// routes in turn until one carries it, and records which did. Only failures
+// to reach the peer move on to the next transport; any HTTP response,
+// whatever its status, is returned.
+func (d *Distributor) doPeerRequest(ctx context.Context, peer *p2p.Node, path string, timeout time.Duration,
+	build func(url string) (*http.Request, error)) (*http.Response, error) {
+	routes := d.peerRoutes(peer, timeout)
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no usable transport to peer %s", peer.ID)
+	}
+
+	var lastErr error
+	for i, route := range routes {
+		req, err := build(p2p.NodeURL(peer.Address, route.port, path))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := route.client.Do(req)
+		if err == nil {
+			d.mu.Lock()
+			d.peerTransports[peer.ID] = route.transport
+			d.mu.Unlock()
+			return resp, nil
+		}
+		lastErr = fmt.Errorf("%s: %v", route.transport, err)
+		if i+1 < len(routes) {
+			tracing.Logf(ctx, "↪️ Peer %s unreachable over %s (%v), trying %s", peer.ID, route.transport, err, routes[i+1].transport)
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,143 @@
+package distributor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// closedPort returns a local port nothing listens on, standing in for one a
+// firewall blocks
+func closedPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+// transportNode is a distributor with both transports running on loopback
+type transportNode struct {
+	d *Distributor
+}
+
+func newTransportNode(t *testing.T, name string) *transportNode {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(filepath.Join(dir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	t.Cleanup(func() { metaStore.Close() })
+
+	network := p2p.NewNetworkWithID(name, "127.0.0.1", 0)
+	d := NewDistributor(network, store, metaStore)
+	mux := http.NewServeMux()
+	mux.Handle("/", network.Handler())
+	mux.HandleFunc("/chunk-transfer", d.HandleChunkTransfer)
+	mux.HandleFunc("/chunk", d.HandleChunkRequest)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	network.LocalNode.Port = server.Listener.Addr().(*net.TCPAddr).Port
+
+	tcp := p2p.NewTCPNetworkWithID(name, "127.0.0.1", 0)
+	tcp.SetRequestHandler(mux)
+	if err := tcp.Start(); err != nil {
+		t.Fatalf("failed to start tcp network: %v", err)
+	}
+	t.Cleanup(func() { tcp.Stop() })
+	d.SetTCPTransport(tcp)
+	network.AdvertiseTransport(p2p.TransportTCP, tcp.LocalNode.Port)
+
+	return &transportNode{d: d}
+}
+
+// register introduces peer to n as it would register, with one of its
+// transports pointing at a closed port as if a firewall blocked it. A closed
+// rather than unroutable port keeps the failed attempt quick.
+func (n *transportNode) register(t *testing.T, peer *transportNode, blocked string) {
+	local := peer.d.network.LocalNode
+	node := &p2p.Node{ID: local.ID, Address: local.Address, Port: local.Port, Status: "online",
+		Capabilities: local.Capabilities, Transports: append([]p2p.TransportEndpoint(nil), local.Transports...)}
+	for i := range node.Transports {
+		if node.Transports[i].Name == blocked && blocked == p2p.TransportTCP {
+			node.Transports[i].Port = closedPort(t)
+		}
+	}
+	if blocked == p2p.TransportHTTP {
+		node.Port = closedPort(t)
+	}
+	n.d.network.RegisterPeer(node)
+}
+
+func TestChunkTransferFallsBackToTheOtherTransport(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	var content strings.Builder
+	for i := 0; content.Len() < 3*int(chunker.MinChunkSize); i++ {
+		fmt.Fprintf(&content, "line %d crossing a restrictive firewall\n", i)
+	}
+	input := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(input, []byte(content.String()), 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	cases := []struct {
+		preferred, blocked, want string
+	}{
+		{p2p.TransportHTTP, p2p.TransportHTTP, p2p.TransportTCP},
+		{p2p.TransportTCP, p2p.TransportTCP, p2p.TransportHTTP},
+	}
+	for _, c := range cases {
+		t.Run("blocked="+c.blocked, func(t *testing.T) {
+			sender, receiver := newTransportNode(t, "sender"), newTransportNode(t, "receiver")
+			sender.register(t, receiver, c.blocked)
+			sender.d.SetReplicaCount(2)
+			sender.d.SetDurability(DurabilityAll, 0)
+
+			// Without fallback the blocked transport is the only one tried
+			sender.d.SetTransportPolicy(TransportPolicy{Preferred: c.preferred})
+			if _, err := sender.d.DistributeFile(input, "pw"); err == nil {
+				t.Fatalf("expected the upload to miss its durability level with %s blocked", c.blocked)
+			}
+
+			sender.d.SetTransportPolicy(TransportPolicy{Preferred: c.preferred, Fallback: true})
+			file, err := sender.d.DistributeFile(input, "pw")
+			if err != nil {
+				t.Fatalf("upload with fallback failed: %v", err)
+			}
+			if file.AchievedReplicas != 2 {
+				t.Errorf("expected 2 copies, got %d", file.AchievedReplicas)
+			}
+			if got := sender.d.PeerTransport("receiver"); got != c.want {
+				t.Errorf("expected transfers to go over %s, recorded %q", c.want, got)
+			}
+			receiver.d.mu.RLock()
+			received := len(receiver.d.chunks)
+			receiver.d.mu.RUnlock()
+			if received < len(file.Chunks) {
+				t.Errorf("receiver holds %d chunks, expected at least %d", received, len(file.Chunks))
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/failures"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// ErrUploadNotVerified is returned when a freshly uploaded file cannot be
+// reconstructed from its stored chunks
+var ErrUploadNotVerified = errors.New("uploaded file failed verification")
+
+// SetVerifyOnUpload makes every upload reconstruct the file from its stored
+// chunks and check its hash before DistributeFile returns. It costs a full
+// read and decrypt of the file, so it is off by default.
+func (d *Distributor) SetVerifyOnUpload(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.verifyOnUpload = enabled
+}
+
+// VerifyOnUpload reports whether every upload is verified
+func (d *Distributor) VerifyOnUpload() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.verifyOnUpload
+}
+
+// verifyUpload round-trips a file just stored here and records the outcome in
+// its metadata: a verified file is marked healthy, one that does not
+// reconstruct is marked corrupted and the upload fails.
+func (d *Distributor) verifyUpload(ctx context.Context, file *FileInfo, password string) error {
+	var err error
+	if file.KeyMode == metadata.KeyModeServerManaged {
+		err = chunker.VerifyFileWithServerKeyContext(ctx, file.ID, d.keyManager, d.metaStore, d.store)
+	} else {
+		err = chunker.VerifyFileContext(ctx, file.ID, password, d.metaStore, d.store)
+	}
+	now := time.Now()
+
+	if fileMeta, getErr := d.metaStore.GetFileMetadataByID(file.ID); getErr == nil {
+		fileMeta.LastVerified = now.Unix()
+		fileMeta.Health = ""
+		if err != nil {
+			fileMeta.Health = metadata.HealthCorrupted
+		}
+		if putErr := d.metaStore.PutFileMetadataByID(file.ID, fileMeta); putErr != nil {
+			tracing.Logf(ctx, "⚠️ Failed to record verification of '%s': %v", file.Name, putErr)
+		}
+	}
+
+	if err != nil {
+		return d.recordFailure(ctx, failures.Failure{Operation: failures.OpUpload, Stage: "verify", FileID: file.ID},
+			fmt.Errorf("%w: %v", ErrUploadNotVerified, err))
+	}
+	d.mu.Lock()
+	file.VerifiedAt = now
+	d.mu.Unlock()
+	tracing.Logf(ctx, "✅ File '%s' verified: its stored chunks reconstruct the upload", file.Name)
+	return nil
+}
@@ -0,0 +1,102 @@
+package distributor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/chunker"
+	"github.com/jaywantadh/DisktroByte/internal/failures"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// corruptingStorage flips a byte of every chunk it stores, like a faulty disk
+type corruptingStorage struct {
+	storage.Storage
+}
+
+func (s corruptingStorage) Put(chunkData io.Reader) (string, error) {
+	data, err := io.ReadAll(chunkData)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > 0 {
+		data[len(data)-1] ^= 0xFF
+	}
+	return s.Storage.Put(bytes.NewReader(data))
+}
+
+func TestVerifyOnUploadDetectsCorruptStorage(t *testing.T) {
+	config.Config = &config.AppConfig{ParallelismRatio: 2, ChunkSize: chunker.MinChunkSize}
+	defer func() { config.Config = &config.AppConfig{ParallelismRatio: 2} }()
+
+	tempDir := t.TempDir()
+	local, err := storage.NewLocalStorage(filepath.Join(tempDir, "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(tempDir, "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+
+	upload := func(store storage.Storage, name, line string) (*FileInfo, *Distributor, error) {
+		d := NewDistributor(p2p.NewNetwork("127.0.0.1", 0), store, metaStore)
+		d.SetFailureLog(failures.NewLog(metaStore))
+		d.SetVerifyOnUpload(true)
+		// Numbered lines, so no two chunks share a hash
+		var content strings.Builder
+		for i := 0; i < 4000; i++ {
+			fmt.Fprintf(&content, "%d %s\n", i, line)
+		}
+		input := filepath.Join(tempDir, name)
+		if err := os.WriteFile(input, []byte(content.String()), 0644); err != nil {
+			t.Fatalf("failed to write input: %v", err)
+		}
+		file, err := d.DistributeFile(input, "pw")
+		return file, d, err
+	}
+
+	// Healthy storage: the upload is verified and marked so
+	file, _, err := upload(local, "healthy.txt", "a line that survives the round trip")
+	if err != nil {
+		t.Fatalf("verified upload failed: %v", err)
+	}
+	if file.VerifiedAt.IsZero() {
+		t.Errorf("expected the upload to carry its verification time")
+	}
+	if meta, _ := metaStore.GetFileMetadataByID(file.ID); meta.LastVerified == 0 || meta.Health != "" {
+		t.Errorf("expected a healthy, verified record, got health %q verified %d", meta.Health, meta.LastVerified)
+	}
+
+	// Corrupting storage: the upload fails instead of succeeding silently
+	_, d, err := upload(corruptingStorage{local}, "doomed.txt", "a line that storage will mangle")
+	if !errors.Is(err, ErrUploadNotVerified) {
+		t.Fatalf("expected ErrUploadNotVerified, got %v", err)
+	}
+	fileID, _ := chunker.CalculateFileHash(filepath.Join(tempDir, "doomed.txt"))
+	if meta, err := metaStore.GetFileMetadataByID(fileID); err == nil {
+		t.Errorf("expected the unverified upload's record rolled back, got health %q", meta.Health)
+	}
+	if _, err := d.GetFileInfo(fileID); err == nil {
+		t.Errorf("expected the unverified upload not listed")
+	}
+	records, err := d.failureLog.Query(failures.Filter{FileID: fileID})
+	if err != nil || len(records) == 0 || records[0].Stage != "verify" {
+		t.Errorf("expected a verify failure in the log, got %+v (%v)", records, err)
+	}
+
+	// Verification stays opt-in
+	if NewDistributor(p2p.NewNetwork("127.0.0.1", 0), local, metaStore).VerifyOnUpload() {
+		t.Errorf("verify-on-upload should be off by default")
+	}
+}
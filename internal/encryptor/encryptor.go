@@ -120,7 +120,11 @@ func EncryptFile(e Encryptor, srcPath, dstPath, password string) error {
 }
 
 // DecryptFile reads a file, decrypts its contents, and writes it to the destination file.
+// It is refused in strict encryption mode.
 func DecryptFile(e Encryptor, srcPath, dstPath, password string) error {
+	if err := RefusePlaintext("decrypted file " + dstPath); err != nil {
+		return err
+	}
 	in, err := os.ReadFile(srcPath)
 	if err != nil {
 		return fmt.Errorf("failed to read encrypted file: %w", err)
@@ -138,3 +142,13 @@ func DecryptFile(e Encryptor, srcPath, dstPath, password string) error {
 
 	return nil
 }
+
+// NonceFromCiphertext returns the nonce embedded in ciphertext produced by Encrypt, or nil if it is too short.
+func NonceFromCiphertext(ciphertext []byte) []byte {
+	if len(ciphertext) < saltSize+nonceSize {
+		return nil
+	}
+	nonce := make([]byte, nonceSize)
+	copy(nonce, ciphertext[saltSize:saltSize+nonceSize])
+	return nonce
+}
@@ -0,0 +1,131 @@
+package encryptor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// KeyManager implements envelope encryption: each file gets a random data key
+// which is stored wrapped (encrypted) under the server master key.
+type KeyManager struct {
+	masterKey []byte
+	keyID     string
+}
+
+// NewKeyManager creates a key manager from a raw 32-byte master key.
+func NewKeyManager(masterKey []byte) (*KeyManager, error) {
+	if len(masterKey) != keySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", keySize, len(masterKey))
+	}
+
+	key := make([]byte, keySize)
+	copy(key, masterKey)
+	fingerprint := sha256.Sum256(key)
+
+	return &KeyManager{
+		masterKey: key,
+		keyID:     hex.EncodeToString(fingerprint[:8]),
+	}, nil
+}
+
+// NewKeyManagerFromString creates a key manager from a hex or base64 encoded master key.
+func NewKeyManagerFromString(encoded string) (*KeyManager, error) {
+	if encoded == "" {
+		return nil, errors.New("master key is empty")
+	}
+	if key, err := hex.DecodeString(encoded); err == nil {
+		return NewKeyManager(key)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("master key must be hex or base64 encoded: %w", err)
+	}
+	return NewKeyManager(key)
+}
+
+// KeyID returns a short fingerprint of the master key, recorded alongside wrapped keys.
+func (km *KeyManager) KeyID() string {
+	return km.keyID
+}
+
+// GenerateDataKey returns a fresh data key along with its wrapped form.
+func (km *KeyManager) GenerateDataKey() ([]byte, []byte, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := km.WrapKey(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataKey, wrapped, nil
+}
+
+// WrapKey encrypts a data key under the master key.
+func (km *KeyManager) WrapKey(dataKey []byte) ([]byte, error) {
+	wrapped, err := EncryptWithKey(dataKey, km.masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return wrapped, nil
+}
+
+// UnwrapKey decrypts a data key previously wrapped by WrapKey.
+func (km *KeyManager) UnwrapKey(wrapped []byte) ([]byte, error) {
+	dataKey, err := DecryptWithKey(wrapped, km.masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// EncryptWithKey encrypts plaintext with a raw 32-byte key using ChaCha20-Poly1305.
+// The returned ciphertext has the nonce prepended.
+func EncryptWithKey(plaintext, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AEAD cipher: %w", err)
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptWithKey decrypts ciphertext produced by EncryptWithKey.
+func DecryptWithKey(ciphertext, key []byte) ([]byte, error) {
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AEAD cipher: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+// NonceFromKeyCiphertext returns the nonce of ciphertext produced by EncryptWithKey.
+func NonceFromKeyCiphertext(ciphertext []byte) []byte {
+	if len(ciphertext) < nonceSize {
+		return nil
+	}
+	nonce := make([]byte, nonceSize)
+	copy(nonce, ciphertext[:nonceSize])
+	return nonce
+}
@@ -0,0 +1,47 @@
+package encryptor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKeyManagerWrapUnwrap(t *testing.T) {
+	km, err := NewKeyManagerFromString(hex.EncodeToString(bytes.Repeat([]byte{0x42}, 32)))
+	if err != nil {
+		t.Fatalf("failed to create key manager: %v", err)
+	}
+
+	dataKey, wrapped, err := km.GenerateDataKey()
+	if err != nil {
+		t.Fatalf("failed to generate data key: %v", err)
+	}
+	if bytes.Contains(wrapped, dataKey) {
+		t.Fatalf("wrapped key contains plaintext data key")
+	}
+
+	unwrapped, err := km.UnwrapKey(wrapped)
+	if err != nil {
+		t.Fatalf("failed to unwrap data key: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("unwrapped key does not match")
+	}
+
+	other, _ := NewKeyManager(bytes.Repeat([]byte{0x24}, 32))
+	if _, err := other.UnwrapKey(wrapped); err == nil {
+		t.Errorf("expected unwrap with a different master key to fail")
+	}
+	if other.KeyID() == km.KeyID() {
+		t.Errorf("different master keys should have different key IDs")
+	}
+}
+
+func TestNewKeyManagerRejectsBadKeys(t *testing.T) {
+	if _, err := NewKeyManager([]byte("short")); err == nil {
+		t.Errorf("expected error for short master key")
+	}
+	if _, err := NewKeyManagerFromString("not a key!"); err == nil {
+		t.Errorf("expected error for undecodable master key")
+	}
+}
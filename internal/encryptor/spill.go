@@ -0,0 +1,113 @@
+package encryptor
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// spillBlockSize is the ChaCha20 block size, the unit its counter counts in
+const spillBlockSize = 64
+
+// Spill is a temporary file for data too large to hold in memory that must
+// not reach disk in plaintext. Its content is encrypted with a key that only
+// ever exists in memory, so a copy left behind by a crash cannot be read.
+// It is written once from the start, then opened for reading any number of times.
+type Spill struct {
+	file  *os.File
+	key   []byte
+	nonce []byte
+	size  int64
+}
+
+// NewSpill creates an empty spill file in dir, or the system temporary
+// directory when dir is empty
+func NewSpill(dir string) (*Spill, error) {
+	key := make([]byte, chacha20.KeySize)
+	nonce := make([]byte, chacha20.NonceSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate spill key: %w", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate spill nonce: %w", err)
+	}
+	file, err := os.CreateTemp(dir, "spill-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	return &Spill{file: file, key: key, nonce: nonce}, nil
+}
+
+// Write appends p to the spill, encrypted
+func (s *Spill) Write(p []byte) (int, error) {
+	sealed := make([]byte, len(p))
+	copy(sealed, p)
+	if err := s.xorAt(sealed, s.size); err != nil {
+		return 0, err
+	}
+	n, err := s.file.Write(sealed)
+	s.size += int64(n)
+	return n, err
+}
+
+// Size returns how many bytes have been written
+func (s *Spill) Size() int64 {
+	return s.size
+}
+
+// Open returns a reader of the decrypted content written so far
+func (s *Spill) Open() (*SpillReader, error) {
+	file, err := os.Open(s.file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spill file: %w", err)
+	}
+	r := &SpillReader{file: file, spill: s}
+	r.SectionReader = io.NewSectionReader(r, 0, s.size)
+	return r, nil
+}
+
+// Remove closes and deletes the spill file
+func (s *Spill) Remove() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// xorAt encrypts or decrypts p in place as the bytes at offset off
+func (s *Spill) xorAt(p []byte, off int64) error {
+	cipher, err := chacha20.NewUnauthenticatedCipher(s.key, s.nonce)
+	if err != nil {
+		return fmt.Errorf("failed to create spill cipher: %w", err)
+	}
+	cipher.SetCounter(uint32(off / spillBlockSize))
+	if skip := off % spillBlockSize; skip > 0 {
+		discard := make([]byte, skip)
+		cipher.XORKeyStream(discard, discard)
+	}
+	cipher.XORKeyStream(p, p)
+	return nil
+}
+
+// SpillReader reads a spill's decrypted content. It satisfies multipart.File.
+type SpillReader struct {
+	*io.SectionReader
+	file  *os.File
+	spill *Spill
+}
+
+// ReadAt reads and decrypts len(p) bytes at offset off; the embedded
+// SectionReader reads through it
+func (r *SpillReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.file.ReadAt(p, off)
+	if xerr := r.spill.xorAt(p[:n], off); xerr != nil {
+		return 0, xerr
+	}
+	return n, err
+}
+
+// Close closes the reader; the spill file stays until Remove
+func (r *SpillReader) Close() error {
+	return r.file.Close()
+}
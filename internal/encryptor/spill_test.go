@@ -0,0 +1,58 @@
+package encryptor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSpillKeepsContentEncryptedOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	spill, err := NewSpill(dir)
+	if err != nil {
+		t.Fatalf("NewSpill failed: %v", err)
+	}
+	defer spill.Remove()
+
+	// Written in uneven pieces so writes start mid keystream block
+	content := bytes.Repeat([]byte("plaintext that must not reach the disk\n"), 1000)
+	for rest := content; len(rest) > 0; {
+		n := min(len(rest), 777)
+		if _, err := spill.Write(rest[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if spill.Size() != int64(len(content)) {
+		t.Fatalf("expected size %d, got %d", len(content), spill.Size())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected one spill file, got %d", len(entries))
+	}
+	onDisk, _ := os.ReadFile(dir + "/" + entries[0].Name())
+	if len(onDisk) != len(content) || bytes.Contains(onDisk, []byte("plaintext")) {
+		t.Fatal("expected the spill file to hold only ciphertext")
+	}
+
+	r, err := spill.Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	read, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(read, content) {
+		t.Fatalf("expected the content read back, err %v", err)
+	}
+	part := make([]byte, 100)
+	if _, err := r.ReadAt(part, 12345); err != nil || !bytes.Equal(part, content[12345:12445]) {
+		t.Fatalf("expected a read at an offset to decrypt, err %v", err)
+	}
+
+	spill.Remove()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected Remove to delete the spill file")
+	}
+}
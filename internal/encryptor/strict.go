@@ -0,0 +1,27 @@
+package encryptor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// ErrPlaintextRefused is returned by code paths that would write user data
+// to disk unencrypted while strict encryption is on
+var ErrPlaintextRefused = errors.New("refused to write plaintext in strict encryption mode")
+
+// Strict reports whether strict encryption is on. Every write of decrypted
+// or not-yet-encrypted user data must then be refused, see RefusePlaintext.
+func Strict() bool {
+	return config.Config != nil && config.Config.StrictEncryption
+}
+
+// RefusePlaintext returns ErrPlaintextRefused naming what would have been
+// written when strict encryption is on, and nil otherwise
+func RefusePlaintext(what string) error {
+	if !Strict() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPlaintextRefused, what)
+}
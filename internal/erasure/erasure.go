@@ -0,0 +1,227 @@
+// Package erasure implements systematic Reed-Solomon coding over GF(2^8).
+// A codec with k data and m parity shards rebuilds every missing shard of a
+// stripe as long as at least k of its k+m shards survive.
+package erasure
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxShards bounds data plus parity shards; GF(2^8) has 256 elements
+const MaxShards = 256
+
+// ErrTooFewShards is returned when fewer shards survive than there are data shards
+var ErrTooFewShards = errors.New("too few shards to reconstruct")
+
+var (
+	expTable [512]byte
+	logTable [256]byte
+)
+
+func init() {
+	// Generator 2 over the polynomial x^8 + x^4 + x^3 + x^2 + 1
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(expTable); i++ {
+		expTable[i] = expTable[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func gfInv(a byte) byte {
+	return expTable[255-int(logTable[a])]
+}
+
+// Codec encodes and reconstructs shards for a fixed data/parity layout
+type Codec struct {
+	dataShards   int
+	parityShards int
+	matrix       [][]byte // (data+parity) x data encoding matrix, identity on top
+}
+
+// NewCodec creates a codec with the given number of data and parity shards
+func NewCodec(dataShards, parityShards int) (*Codec, error) {
+	if dataShards < 1 || parityShards < 1 {
+		return nil, fmt.Errorf("invalid shard counts: %d data, %d parity", dataShards, parityShards)
+	}
+	if dataShards+parityShards > MaxShards {
+		return nil, fmt.Errorf("at most %d shards are supported, got %d", MaxShards, dataShards+parityShards)
+	}
+
+	// Identity over a Cauchy matrix: every square submatrix is invertible,
+	// so any dataShards surviving rows recover the data
+	total := dataShards + parityShards
+	matrix := make([][]byte, total)
+	for r := 0; r < total; r++ {
+		matrix[r] = make([]byte, dataShards)
+		if r < dataShards {
+			matrix[r][r] = 1
+			continue
+		}
+		for c := 0; c < dataShards; c++ {
+			matrix[r][c] = gfInv(byte(r) ^ byte(c))
+		}
+	}
+	return &Codec{dataShards: dataShards, parityShards: parityShards, matrix: matrix}, nil
+}
+
+// DataShards returns the number of data shards per stripe
+func (c *Codec) DataShards() int {
+	return c.dataShards
+}
+
+// ParityShards returns the number of parity shards per stripe
+func (c *Codec) ParityShards() int {
+	return c.parityShards
+}
+
+// Encode fills the parity shards from the data shards. shards holds data
+// shards followed by parity shards, all of the same length; parity entries
+// may be nil and are allocated.
+func (c *Codec) Encode(shards [][]byte) error {
+	size, err := c.checkShards(shards, false)
+	if err != nil {
+		return err
+	}
+	for p := c.dataShards; p < len(shards); p++ {
+		shards[p] = c.combine(c.matrix[p], shards[:c.dataShards], size)
+	}
+	return nil
+}
+
+// Reconstruct rebuilds the nil entries of shards in place. At least
+// DataShards entries must be present and they must share one length.
+func (c *Codec) Reconstruct(shards [][]byte) error {
+	size, err := c.checkShards(shards, true)
+	if err != nil {
+		return err
+	}
+
+	// Pick the first dataShards survivors and invert their rows
+	rows := make([][]byte, 0, c.dataShards)
+	inputs := make([][]byte, 0, c.dataShards)
+	for i, shard := range shards {
+		if shard != nil && len(rows) < c.dataShards {
+			rows = append(rows, c.matrix[i])
+			inputs = append(inputs, shard)
+		}
+	}
+	if len(rows) < c.dataShards {
+		return fmt.Errorf("%w: have %d, need %d", ErrTooFewShards, len(rows), c.dataShards)
+	}
+	decode, err := invert(rows)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < c.dataShards; i++ {
+		if shards[i] == nil {
+			shards[i] = c.combine(decode[i], inputs, size)
+		}
+	}
+	for p := c.dataShards; p < len(shards); p++ {
+		if shards[p] == nil {
+			shards[p] = c.combine(c.matrix[p], shards[:c.dataShards], size)
+		}
+	}
+	return nil
+}
+
+// combine returns the linear combination of inputs weighted by coefficients
+func (c *Codec) combine(coefficients []byte, inputs [][]byte, size int) []byte {
+	out := make([]byte, size)
+	for i, input := range inputs {
+		coef := coefficients[i]
+		if coef == 0 {
+			continue
+		}
+		for j, b := range input {
+			out[j] ^= gfMul(coef, b)
+		}
+	}
+	return out
+}
+
+// checkShards validates the shard layout and returns the common shard length
+func (c *Codec) checkShards(shards [][]byte, allowMissing bool) (int, error) {
+	if len(shards) != c.dataShards+c.parityShards {
+		return 0, fmt.Errorf("expected %d shards, got %d", c.dataShards+c.parityShards, len(shards))
+	}
+	size := -1
+	for i, shard := range shards {
+		if shard == nil {
+			if i < c.dataShards && !allowMissing {
+				return 0, fmt.Errorf("data shard %d is missing", i)
+			}
+			continue
+		}
+		if size == -1 {
+			size = len(shard)
+		} else if len(shard) != size {
+			return 0, fmt.Errorf("shard %d is %d bytes, expected %d", i, len(shard), size)
+		}
+	}
+	if size == -1 {
+		return 0, fmt.Errorf("%w: no shards present", ErrTooFewShards)
+	}
+	return size, nil
+}
+
+// invert returns the inverse of a square matrix by Gauss-Jordan elimination
+func invert(matrix [][]byte) ([][]byte, error) {
+	n := len(matrix)
+	work := make([][]byte, n)
+	for i := range matrix {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], matrix[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if work[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot == -1 {
+			return nil, fmt.Errorf("matrix is singular")
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		scale := gfInv(work[col][col])
+		for j := range work[col] {
+			work[col][j] = gfMul(work[col][j], scale)
+		}
+		for r := 0; r < n; r++ {
+			if r == col || work[r][col] == 0 {
+				continue
+			}
+			factor := work[r][col]
+			for j := range work[r] {
+				work[r][j] ^= gfMul(factor, work[col][j])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+	return inverse, nil
+}
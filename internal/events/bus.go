@@ -0,0 +1,166 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// System event types; filters match a type exactly or by its prefix before the dot
+const (
+	TypePeerOnline  = "peer.online"
+	TypePeerOffline = "peer.offline"
+	TypeClockSkew   = "peer.clock_skew"
+	TypeNodeFailed  = "node.failed"
+	TypeNodeHealthy = "node.healthy"
+	TypeReplication = "replication"
+	TypeRebalance   = "rebalance"
+	TypeScrub       = "scrub"
+	TypeFileExpired = "file.expired"
+	TypeFileDeleted = "file.deleted"
+	TypeLifecycle   = "lifecycle"
+	TypeError       = "error"
+)
+
+// Bus defaults
+const (
+	DefaultBacklog    = 256 // Events kept for late subscribers
+	DefaultSubscriber = 64  // Live events buffered per subscriber before dropping
+)
+
+// Event is a system-level event published by a subsystem
+type Event struct {
+	ID        uint64                 `json:"id"`
+	Type      string                 `json:"type"`
+	Source    string                 `json:"source"` // Publishing subsystem, e.g. "p2p" or "dfs"
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Subscription receives events matching its filter
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	types   []string
+	dropped int64
+	bus     *Bus
+}
+
+// Dropped returns how many events were lost because the subscriber fell behind
+func (s *Subscription) Dropped() int64 {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.dropped
+}
+
+// Close stops delivery and closes the channel
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+func (s *Subscription) matches(eventType string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if eventType == t || strings.HasPrefix(eventType, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus fans events out to subscribers and keeps a bounded backlog. Publishing
+// never blocks: subscribers that fall behind lose events rather than
+// stalling the publisher. A nil *Bus discards everything, so subsystems can
+// publish unconditionally.
+type Bus struct {
+	backlog []Event // Ring buffer, oldest first from start
+	start   int
+	size    int
+	nextID  uint64
+	subs    map[*Subscription]struct{}
+	mu      sync.Mutex
+}
+
+// NewBus creates a bus keeping up to backlog events; <= 0 uses DefaultBacklog
+func NewBus(backlog int) *Bus {
+	if backlog <= 0 {
+		backlog = DefaultBacklog
+	}
+	return &Bus{
+		backlog: make([]Event, backlog),
+		nextID:  1,
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish records an event and delivers it to matching subscribers
+func (b *Bus) Publish(eventType, source, message string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{
+		ID:        b.nextID,
+		Type:      eventType,
+		Source:    source,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+	b.nextID++
+
+	if b.size < len(b.backlog) {
+		b.backlog[(b.start+b.size)%len(b.backlog)] = event
+		b.size++
+	} else {
+		b.backlog[b.start] = event
+		b.start = (b.start + 1) % len(b.backlog)
+	}
+
+	for sub := range b.subs {
+		if !sub.matches(eventType) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// Subscribe returns a subscription for the given types (all when empty).
+// Backlogged events with an ID greater than after are replayed first, so a
+// reconnecting client can resume from the last event it saw.
+func (b *Bus) Subscribe(types []string, after uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{types: types, bus: b}
+	replay := make([]Event, 0, b.size)
+	for i := 0; i < b.size; i++ {
+		event := b.backlog[(b.start+i)%len(b.backlog)]
+		if event.ID > after && sub.matches(event.Type) {
+			replay = append(replay, event)
+		}
+	}
+
+	sub.ch = make(chan Event, len(replay)+DefaultSubscriber)
+	for _, event := range replay {
+		sub.ch <- event
+	}
+	sub.C = sub.ch
+	b.subs[sub] = struct{}{}
+	return sub
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBusFiltersByType(t *testing.T) {
+	bus := NewBus(10)
+	peers := bus.Subscribe([]string{"peer"}, 0)
+	defer peers.Close()
+
+	bus.Publish(TypeReplication, "dfs", "replicating", nil)
+	bus.Publish(TypePeerOffline, "p2p", "gone", nil)
+	bus.Publish(TypeError, "dfs", "boom", nil)
+
+	select {
+	case event := <-peers.C:
+		if event.Type != TypePeerOffline {
+			t.Errorf("expected only peer events, got %s", event.Type)
+		}
+	default:
+		t.Fatalf("expected the peer event to be delivered")
+	}
+	select {
+	case event := <-peers.C:
+		t.Errorf("unexpected event %s for a peer filter", event.Type)
+	default:
+	}
+}
+
+func TestBusBacklogIsBoundedAndResumable(t *testing.T) {
+	bus := NewBus(3)
+	for i := 1; i <= 5; i++ {
+		bus.Publish(TypeScrub, "dfs", fmt.Sprintf("finding %d", i), nil)
+	}
+
+	// A late subscriber only sees the newest events that fit the backlog
+	late := bus.Subscribe(nil, 0)
+	defer late.Close()
+	for _, want := range []uint64{3, 4, 5} {
+		if event := <-late.C; event.ID != want {
+			t.Errorf("expected backlog event %d, got %d", want, event.ID)
+		}
+	}
+
+	// A reconnecting subscriber resumes after the last ID it saw
+	resumed := bus.Subscribe(nil, 4)
+	defer resumed.Close()
+	if event := <-resumed.C; event.ID != 5 {
+		t.Errorf("expected to resume at event 5, got %d", event.ID)
+	}
+	select {
+	case event := <-resumed.C:
+		t.Errorf("unexpected extra event %d", event.ID)
+	default:
+	}
+}
+
+func TestBusDropsForSlowSubscribers(t *testing.T) {
+	bus := NewBus(1)
+	slow := bus.Subscribe(nil, 0)
+	for i := 0; i < DefaultSubscriber+5; i++ {
+		bus.Publish(TypeError, "test", "event", nil)
+	}
+	if dropped := slow.Dropped(); dropped != 5 {
+		t.Errorf("expected 5 dropped events, got %d", dropped)
+	}
+	slow.Close()
+	slow.Close() // Closing twice is harmless
+	var nilBus *Bus
+	nilBus.Publish(TypeError, "test", "discarded", nil)
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeartbeatInterval keeps idle streams alive through proxies
+const HeartbeatInterval = 15 * time.Second
+
+// ParseTypes splits a comma-separated type filter, ignoring blanks
+func ParseTypes(filter string) []string {
+	var types []string
+	for _, t := range strings.Split(filter, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// ServeSSE streams events as Server-Sent Events until the client goes away.
+// The ?types= query parameter filters by type and a Last-Event-ID header (or
+// ?after=) resumes from the backlog.
+func (b *Bus) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = r.URL.Query().Get("after")
+	}
+	afterID, _ := strconv.ParseUint(after, 10, 64)
+
+	sub := b.Subscribe(ParseTypes(r.URL.Query().Get("types")), afterID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	writeHeartbeat(w, "connected")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, data)
+			flusher.Flush()
+		case <-heartbeat.C:
+			writeHeartbeat(w, "alive")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeHeartbeat(w http.ResponseWriter, status string) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      "heartbeat",
+		"timestamp": time.Now(),
+		"data":      map[string]string{"status": status},
+	})
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
@@ -0,0 +1,320 @@
+// Package failures keeps a persistent log of failed uploads, replications and
+// reassemblies so admins can look for recurring causes after the caller that
+// saw the error is gone.
+package failures
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// Operations that record failures
+const (
+	OpUpload     = "upload"
+	OpReplicate  = "replicate"
+	OpReassemble = "reassemble"
+	OpDownload   = "download"
+)
+
+// Error types assigned by Classify
+const (
+	TypeDecryption   = "decryption"    // Wrong password or key, or tampered ciphertext
+	TypeIntegrity    = "integrity"     // Hash or size mismatch
+	TypeMissingChunk = "missing_chunk" // Chunk or file not found
+	TypePeer         = "peer"          // Peer unreachable or rejected a transfer
+	TypeStorage      = "storage"       // Local disk or tier errors
+	TypeTimeout      = "timeout"
+	TypeCanceled     = "canceled"
+	TypeExpired      = "expired"
+	TypeOther        = "other"
+)
+
+// keyPrefix keeps failure records together, ordered by time
+const keyPrefix = "failure:"
+
+// Failure is one recorded failed operation
+type Failure struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	Stage     string    `json:"stage"` // Step of the operation that failed, e.g. "chunk" or "verify"
+	ErrorType string    `json:"error_type"`
+	Message   string    `json:"message"`
+	FileID    string    `json:"file_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	NodeID    string    `json:"node_id,omitempty"` // Peer involved, for replication and downloads
+	TraceID   string    `json:"trace_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Filter selects failures; empty fields match everything
+type Filter struct {
+	ErrorType string
+	Operation string
+	FileID    string
+	UserID    string
+	NodeID    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int // Newest records kept, 0 is unlimited
+}
+
+func (f Filter) matches(rec Failure) bool {
+	return (f.ErrorType == "" || rec.ErrorType == f.ErrorType) &&
+		(f.Operation == "" || rec.Operation == f.Operation) &&
+		(f.FileID == "" || rec.FileID == f.FileID) &&
+		(f.UserID == "" || rec.UserID == f.UserID) &&
+		(f.NodeID == "" || rec.NodeID == f.NodeID) &&
+		(f.Until.IsZero() || !rec.Timestamp.After(f.Until))
+}
+
+// Cause aggregates failures sharing an error type and stage
+type Cause struct {
+	ErrorType   string    `json:"error_type"`
+	Operation   string    `json:"operation"`
+	Stage       string    `json:"stage"`
+	Count       int       `json:"count"`
+	LastSeen    time.Time `json:"last_seen"`
+	LastMessage string    `json:"last_message"`
+}
+
+// Log stores failures in the metadata database. A nil *Log records nothing,
+// so components can hold one unconditionally.
+type Log struct {
+	db *badger.DB
+}
+
+// NewLog creates a failure log backed by the metadata store's database
+func NewLog(metaStore *metadata.MetadataStore) *Log {
+	return &Log{db: metaStore.GetDB()}
+}
+
+type userKey struct{}
+
+// WithUser returns a context whose recorded failures are attributed to userID
+func WithUser(ctx context.Context, userID string) context.Context {
+	if userID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// User returns the user a context's failures are attributed to
+func User(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	userID, _ := ctx.Value(userKey{}).(string)
+	return userID
+}
+
+func recordKey(rec Failure) []byte {
+	return []byte(fmt.Sprintf("%s%020d:%s", keyPrefix, rec.Timestamp.UnixNano(), rec.ID))
+}
+
+// Record stores a failure caused by err. The user and trace ID come from ctx
+// unless set on f, and the error type from Classify unless set.
+func (l *Log) Record(ctx context.Context, f Failure, err error) error {
+	if l == nil || err == nil {
+		return nil
+	}
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	if f.Timestamp.IsZero() {
+		f.Timestamp = time.Now()
+	}
+	if f.UserID == "" {
+		f.UserID = User(ctx)
+	}
+	if f.TraceID == "" {
+		f.TraceID = tracing.ID(ctx)
+	}
+	if f.ErrorType == "" {
+		f.ErrorType = Classify(err)
+	}
+	f.Message = err.Error()
+
+	val, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return l.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(recordKey(f), val)
+	})
+}
+
+// Query returns the failures matching filter, newest first
+func (l *Log) Query(filter Filter) ([]Failure, error) {
+	records := make([]Failure, 0)
+	if l == nil {
+		return records, nil
+	}
+
+	start := []byte(keyPrefix)
+	if !filter.Since.IsZero() {
+		start = []byte(fmt.Sprintf("%s%020d", keyPrefix, filter.Since.UnixNano()))
+	}
+	err := l.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(start); it.ValidForPrefix([]byte(keyPrefix)); it.Next() {
+			var rec Failure
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &rec)
+			}); err != nil {
+				return err
+			}
+			if filter.matches(rec) {
+				records = append(records, rec)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
+
+// TopCauses groups the failures matching filter by operation, stage and
+// error type and returns the n most frequent; n <= 0 returns all
+func (l *Log) TopCauses(filter Filter, n int) ([]Cause, error) {
+	filter.Limit = 0
+	records, err := l.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byCause := make(map[string]*Cause)
+	for _, rec := range records {
+		key := rec.Operation + "\x00" + rec.Stage + "\x00" + rec.ErrorType
+		cause, ok := byCause[key]
+		if !ok {
+			// Records are newest first, so the first one seen is the latest
+			cause = &Cause{ErrorType: rec.ErrorType, Operation: rec.Operation, Stage: rec.Stage, LastSeen: rec.Timestamp, LastMessage: rec.Message}
+			byCause[key] = cause
+		}
+		cause.Count++
+	}
+
+	causes := make([]Cause, 0, len(byCause))
+	for _, cause := range byCause {
+		causes = append(causes, *cause)
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if causes[i].Count != causes[j].Count {
+			return causes[i].Count > causes[j].Count
+		}
+		return causes[i].LastSeen.After(causes[j].LastSeen)
+	})
+	if n > 0 && len(causes) > n {
+		causes = causes[:n]
+	}
+	return causes, nil
+}
+
+// Prune deletes failures recorded before cutoff and returns how many were removed
+func (l *Log) Prune(cutoff time.Time) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	end := []byte(fmt.Sprintf("%s%020d", keyPrefix, cutoff.UnixNano()))
+
+	var keys [][]byte
+	err := l.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek([]byte(keyPrefix)); it.ValidForPrefix([]byte(keyPrefix)); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if string(key) >= string(end) {
+				break
+			}
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	batch := l.db.NewWriteBatch()
+	defer batch.Cancel()
+	for _, key := range keys {
+		if err := batch.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	if err := batch.Flush(); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// Classify maps an error to one of the Type constants. Typed errors are
+// checked first; the rest are matched on the wording the chunker,
+// encryptor, storage and distributor use.
+func Classify(err error) string {
+	if err == nil {
+		return ""
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return TypeTimeout
+	case errors.Is(err, context.Canceled):
+		return TypeCanceled
+	case errors.Is(err, metadata.ErrFileExpired):
+		return TypeExpired
+	case errors.As(err, &netErr):
+		return TypePeer
+	}
+
+	msg := strings.ToLower(err.Error())
+	containsAny := func(words ...string) bool {
+		for _, word := range words {
+			if strings.Contains(msg, word) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case containsAny("decrypt", "authentication failed", "unwrap data key", "key derivation"):
+		return TypeDecryption
+	case containsAny("hash mismatch", "corrupt", "does not match", "failed verification"):
+		return TypeIntegrity
+	case containsAny("from node", "any node", "request chunk", "send chunk", "connection", "durability level", "status "):
+		return TypePeer
+	case containsAny("not found", "does not exist", "no nodes have", "unavailable", "missing chunk", "has no chunks"):
+		return TypeMissingChunk
+	case containsAny("timeout", "deadline"):
+		return TypeTimeout
+	case containsAny("write", "storage", "disk", "tier", "no space", "node full"):
+		return TypeStorage
+	case containsAny("expired"):
+		return TypeExpired
+	}
+	return TypeOther
+}
@@ -0,0 +1,61 @@
+package failures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/metadata"
+)
+
+func TestQueryFiltersByTypeAndTime(t *testing.T) {
+	metaStore, err := metadata.OpenMetadataStore(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer metaStore.Close()
+	log := NewLog(metaStore)
+
+	base := time.Now().Add(-time.Hour)
+	errs := []error{
+		fmt.Errorf("failed to decrypt chunk 0: %v", errors.New("chacha20poly1305: message authentication failed")),
+		fmt.Errorf("chunk 3 of abc does not exist"),
+		fmt.Errorf("failed to decrypt chunk 1: %v", errors.New("chacha20poly1305: message authentication failed")),
+		fmt.Errorf("reassembly aborted: %w", context.DeadlineExceeded),
+	}
+	for i, err := range errs {
+		f := Failure{Operation: OpReassemble, Stage: "assemble", FileID: "file-1", Timestamp: base.Add(time.Duration(i) * time.Minute)}
+		if err := log.Record(context.Background(), f, err); err != nil {
+			t.Fatalf("failed to record failure: %v", err)
+		}
+	}
+
+	all, _ := log.Query(Filter{})
+	if len(all) != 4 || all[0].ErrorType != TypeTimeout || all[3].ErrorType != TypeDecryption {
+		t.Fatalf("expected 4 failures newest first, got %+v", all)
+	}
+	decryption, _ := log.Query(Filter{ErrorType: TypeDecryption})
+	if len(decryption) != 2 {
+		t.Errorf("expected 2 decryption failures, got %d", len(decryption))
+	}
+	window, _ := log.Query(Filter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)})
+	if len(window) != 2 || window[0].ErrorType != TypeDecryption || window[1].ErrorType != TypeMissingChunk {
+		t.Errorf("expected the 2 failures in the window, got %+v", window)
+	}
+
+	causes, _ := log.TopCauses(Filter{}, 1)
+	if len(causes) != 1 || causes[0].ErrorType != TypeDecryption || causes[0].Count != 2 || !causes[0].LastSeen.Equal(all[1].Timestamp) {
+		t.Errorf("expected decryption as the top cause, got %+v", causes)
+	}
+
+	pruned, err := log.Prune(base.Add(2 * time.Minute))
+	if err != nil || pruned != 2 {
+		t.Fatalf("expected 2 failures pruned, got %d (%v)", pruned, err)
+	}
+	if rest, _ := log.Query(Filter{}); len(rest) != 2 {
+		t.Errorf("expected 2 failures left after pruning, got %d", len(rest))
+	}
+}
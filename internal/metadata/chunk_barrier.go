@@ -0,0 +1,160 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrChunkDeleteWait is returned when a new reference waited longer than the
+// configured limit for a deletion of the same chunk to finish
+var ErrChunkDeleteWait = errors.New("timed out waiting for a chunk deletion to finish")
+
+// DefaultChunkReferenceWait bounds how long a new reference waits for a deletion
+const DefaultChunkReferenceWait = 30 * time.Second
+
+// ChunkBarrier keeps deleting a deduplicated chunk from racing a new reference
+// to it. Writers hold the chunk hashes and stored paths they are recording
+// until the file using them is committed; deleters claim chunks, recount their
+// references under the claim and only then delete them. A claim is refused
+// while a chunk is held, and a new hold waits until a claim on it ends, after
+// which the writer stores again whatever was deleted. A nil *ChunkBarrier
+// never blocks.
+type ChunkBarrier struct {
+	held     map[string]int
+	deleting map[string]bool
+	wait     time.Duration
+	changed  chan struct{} // Closed and replaced whenever a hold or claim ends
+	mu       sync.Mutex
+}
+
+// NewChunkBarrier creates a barrier whose holds wait at most DefaultChunkReferenceWait
+func NewChunkBarrier() *ChunkBarrier {
+	return &ChunkBarrier{
+		held:     make(map[string]int),
+		deleting: make(map[string]bool),
+		wait:     DefaultChunkReferenceWait,
+		changed:  make(chan struct{}),
+	}
+}
+
+// ChunkBarrier returns the barrier shared by everything writing or deleting this store's chunks
+func (ms *MetadataStore) ChunkBarrier() *ChunkBarrier {
+	if ms == nil {
+		return nil
+	}
+	return ms.barrier
+}
+
+// SetWait changes how long Hold waits for a deletion; <= 0 waits until the context ends
+func (b *ChunkBarrier) SetWait(wait time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.wait = wait
+}
+
+// Hold marks keys as being referenced until the returned func is called,
+// waiting first for any deletion claiming one of them. It returns the keys it
+// had to wait for, whose chunks may since have been deleted.
+func (b *ChunkBarrier) Hold(ctx context.Context, keys ...string) (func(), []string, error) {
+	if b == nil {
+		return func() {}, nil, nil
+	}
+	b.mu.Lock()
+	wait := b.wait
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var waited []string
+	seen := make(map[string]bool)
+	for {
+		blocked := false
+		for _, key := range keys {
+			if !b.deleting[key] {
+				continue
+			}
+			blocked = true
+			if !seen[key] {
+				seen[key] = true
+				waited = append(waited, key)
+			}
+		}
+		if !blocked {
+			break
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, waited, ctx.Err()
+		case <-timeout:
+			return nil, waited, ErrChunkDeleteWait
+		}
+		b.mu.Lock()
+	}
+	for _, key := range keys {
+		b.held[key]++
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for _, key := range keys {
+				if b.held[key]--; b.held[key] <= 0 {
+					delete(b.held, key)
+				}
+			}
+			b.notifyLocked()
+		})
+	}, waited, nil
+}
+
+// BeginDelete claims the keys no one holds or is already deleting and returns
+// them. References must be recounted after the claim, and only claimed keys
+// deleted; every claim ends with EndDelete.
+func (b *ChunkBarrier) BeginDelete(keys ...string) []string {
+	if b == nil {
+		return keys
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	claimed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if b.held[key] > 0 || b.deleting[key] {
+			continue
+		}
+		b.deleting[key] = true
+		claimed = append(claimed, key)
+	}
+	return claimed
+}
+
+// EndDelete releases claims taken by BeginDelete, letting waiting holds proceed
+func (b *ChunkBarrier) EndDelete(keys ...string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		delete(b.deleting, key)
+	}
+	b.notifyLocked()
+}
+
+func (b *ChunkBarrier) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
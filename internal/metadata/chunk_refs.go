@@ -0,0 +1,125 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The record under a chunk's hash belongs to the first file that stored it.
+// Every other use of the same hash, by another file or at another index of
+// the same file, is kept as a reference record under the using file's content
+// hash and index, so each file still finds all of its chunks.
+func chunkRefPrefix(fileID string) []byte {
+	return []byte("chunkref:" + fileID + ":")
+}
+
+func chunkRefKey(fileID string, index int) []byte {
+	return []byte(fmt.Sprintf("chunkref:%s:%d", fileID, index))
+}
+
+// AddChunkReference records chunk as used by chunk.FileID at chunk.Index
+// while the record under its hash belongs to another file or index. A new
+// reference adds one to the reference count of the record under the hash;
+// recording the same reference again only rewrites it. It reports whether
+// the reference is new.
+func (ms *MetadataStore) AddChunkReference(chunk ChunkMetadata) (bool, error) {
+	val, err := json.Marshal(chunk)
+	if err != nil {
+		return false, err
+	}
+	key := chunkRefKey(chunk.FileID, chunk.Index)
+
+	for {
+		added := false
+		err := ms.db.Update(func(txn *badger.Txn) error {
+			if _, err := txn.Get(key); err == nil {
+				return txn.Set(key, val)
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+			added = true
+			if err := txn.Set(key, val); err != nil {
+				return err
+			}
+
+			item, err := txn.Get([]byte("chunk:" + chunk.Hash))
+			if err == badger.ErrKeyNotFound {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			var owner ChunkMetadata
+			if err := item.Value(func(v []byte) error {
+				return json.Unmarshal(v, &owner)
+			}); err != nil {
+				return err
+			}
+			// An untracked count stands for the owner alone
+			owner.RefCount = max(owner.RefCount, 1) + 1
+			ownerVal, err := json.Marshal(owner)
+			if err != nil {
+				return err
+			}
+			return txn.Set([]byte("chunk:"+chunk.Hash), ownerVal)
+		})
+		if errors.Is(err, badger.ErrConflict) {
+			continue // A concurrent upload counted the same chunk first
+		}
+		return added, err
+	}
+}
+
+// GetChunkReferences returns the reference records of a file's content.
+func (ms *MetadataStore) GetChunkReferences(fileID string) ([]ChunkMetadata, error) {
+	return ms.scanChunkReferences(chunkRefPrefix(fileID))
+}
+
+// ListChunkReferences returns every chunk reference record.
+func (ms *MetadataStore) ListChunkReferences() ([]ChunkMetadata, error) {
+	return ms.scanChunkReferences([]byte("chunkref:"))
+}
+
+// DeleteChunkReferences drops the reference records of a file's content.
+func (ms *MetadataStore) DeleteChunkReferences(fileID string) error {
+	return ms.db.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := chunkRefPrefix(fileID)
+		var keys [][]byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ms *MetadataStore) scanChunkReferences(prefix []byte) ([]ChunkMetadata, error) {
+	var chunks []ChunkMetadata
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var chunk ChunkMetadata
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &chunk)
+			}); err != nil {
+				return err
+			}
+			chunks = append(chunks, chunk)
+		}
+		return nil
+	})
+	return chunks, err
+}
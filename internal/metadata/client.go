@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// ClientManifest describes a file the client chunked and encrypted itself.
+// The server stores the listed ciphertext chunks as opaque blobs and hands
+// the manifest back on download; it never sees the key or the plaintext.
+type ClientManifest struct {
+	FileID    string        `json:"file_id,omitempty"` // Assigned by the server on upload
+	FileName  string        `json:"file_name"`
+	FileSize  int64         `json:"file_size"` // Plaintext size as declared by the client
+	Algorithm string        `json:"algorithm"` // Client cipher, informational only
+	Chunks    []ClientChunk `json:"chunks"`
+}
+
+// ClientChunk is one ciphertext chunk of a client-encrypted file
+type ClientChunk struct {
+	Index int    `json:"index"`
+	Size  int64  `json:"size"` // Ciphertext bytes
+	Hash  string `json:"hash"` // Hex SHA-256 of the ciphertext
+}
+
+// Validate checks that the manifest lists a contiguous run of well-formed chunks.
+func (m ClientManifest) Validate() error {
+	if len(m.Chunks) == 0 {
+		return fmt.Errorf("manifest lists no chunks")
+	}
+	for i, chunk := range m.Chunks {
+		if chunk.Index != i {
+			return fmt.Errorf("manifest chunk %d has index %d; chunks must be listed in order from 0", i, chunk.Index)
+		}
+		if chunk.Size <= 0 {
+			return fmt.Errorf("manifest chunk %d has no data", i)
+		}
+		if raw, err := hex.DecodeString(chunk.Hash); err != nil || len(raw) != 32 {
+			return fmt.Errorf("manifest chunk %d has an invalid SHA-256 hash", i)
+		}
+	}
+	return nil
+}
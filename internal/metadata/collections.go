@@ -0,0 +1,83 @@
+package metadata
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Collection groups files uploaded together with shared settings.
+type Collection struct {
+	ID         string   `json:"id"`
+	Label      string   `json:"label,omitempty"`
+	FileIDs    []string `json:"file_ids"`
+	Tags       []string `json:"tags,omitempty"`
+	Replicas   int      `json:"replicas,omitempty"`   // Requested copies per chunk, 0 for the default
+	Redundancy string   `json:"redundancy,omitempty"` // Requested scheme, "" when chosen per file
+	CreatedBy  string   `json:"created_by,omitempty"`
+	CreatedAt  int64    `json:"created_at"` // Unix timestamp
+}
+
+func collectionKey(id string) []byte {
+	return []byte("collection:" + id)
+}
+
+// PutCollection stores a collection record.
+func (ms *MetadataStore) PutCollection(collection Collection) error {
+	val, err := json.Marshal(collection)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(collectionKey(collection.ID), val)
+	})
+}
+
+// GetCollection retrieves a collection by ID.
+func (ms *MetadataStore) GetCollection(id string) (Collection, error) {
+	var collection Collection
+	err := ms.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(collectionKey(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &collection)
+		})
+	})
+	return collection, err
+}
+
+// ListCollections returns every collection, newest first.
+func (ms *MetadataStore) ListCollections() ([]Collection, error) {
+	collections := make([]Collection, 0)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("collection:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var collection Collection
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &collection)
+			}); err != nil {
+				return err
+			}
+			collections = append(collections, collection)
+		}
+		return nil
+	})
+	sort.Slice(collections, func(i, j int) bool {
+		if collections[i].CreatedAt != collections[j].CreatedAt {
+			return collections[i].CreatedAt > collections[j].CreatedAt
+		}
+		return collections[i].ID < collections[j].ID
+	})
+	return collections, err
+}
+
+// DeleteCollection removes a collection record; its files are left in place.
+func (ms *MetadataStore) DeleteCollection(id string) error {
+	return ms.deleteKey(collectionKey(id))
+}
@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DrainRecord marks a node as being drained before it is decommissioned. It
+// outlives restarts so an interrupted drain resumes.
+type DrainRecord struct {
+	NodeID    string `json:"node_id"`
+	StartedBy string `json:"started_by,omitempty"`
+	StartedAt int64  `json:"started_at"` // Unix timestamp
+}
+
+func drainKey(nodeID string) []byte {
+	return []byte("drain:" + nodeID)
+}
+
+// PutDrain records that a node is being drained.
+func (ms *MetadataStore) PutDrain(record DrainRecord) error {
+	val, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(drainKey(record.NodeID), val)
+	})
+}
+
+// ListDrains returns every node being drained, in node order.
+func (ms *MetadataStore) ListDrains() ([]DrainRecord, error) {
+	records := make([]DrainRecord, 0)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("drain:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var record DrainRecord
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &record)
+			}); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].NodeID < records[j].NodeID
+	})
+	return records, err
+}
+
+// DeleteDrain returns a node to service.
+func (ms *MetadataStore) DeleteDrain(nodeID string) error {
+	return ms.deleteKey(drainKey(nodeID))
+}
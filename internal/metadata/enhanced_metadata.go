@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -28,6 +29,7 @@ type EnhancedFileMetadata struct {
 	ChunkCount      int       `json:"chunk_count"`
 	ChunkSize       int64     `json:"chunk_size"`
 	ChunkHashes     []string  `json:"chunk_hashes"`
+	HashAlgo        string    `json:"hash_algo,omitempty"` // Algorithm of the chunk content hashes, empty for SHA-256
 	
 	// Storage and replication
 	StorageNodes    []string  `json:"storage_nodes"`
@@ -38,6 +40,7 @@ type EnhancedFileMetadata struct {
 	IsEncrypted     bool      `json:"is_encrypted"`
 	EncryptionAlgo  string    `json:"encryption_algo"`
 	KeyID           string    `json:"key_id"`
+	KeyMode         string    `json:"key_mode"`         // "password", "server-managed"
 	AccessLevel     string    `json:"access_level"`     // "public", "private", "restricted"
 	
 	// Compression and optimization
@@ -55,6 +58,7 @@ type EnhancedFileMetadata struct {
 	ModifiedAt      time.Time `json:"modified_at"`
 	AccessedAt      time.Time `json:"accessed_at"`
 	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"` // Set for ephemeral uploads
 	
 	// User and ownership
 	OwnerID         string    `json:"owner_id"`
@@ -83,6 +87,9 @@ type EnhancedFileMetadata struct {
 	LastVerified    time.Time `json:"last_verified"`
 }
 
+// ErrFileMetadataNotFound is returned for a file the enhanced store has no record of
+var ErrFileMetadataNotFound = errors.New("file metadata not found")
+
 // EnhancedChunkMetadata represents comprehensive chunk metadata
 type EnhancedChunkMetadata struct {
 	// Basic chunk information
@@ -174,6 +181,9 @@ type SearchQuery struct {
 	StorageClass    []string  `json:"storage_class"`
 	IsDeleted       *bool     `json:"is_deleted"`
 	
+	// Limits results to these files when set, such as a tenant's namespace
+	FileIDs         map[string]bool `json:"-"`
+	
 	// Sorting and pagination
 	SortBy          string    `json:"sort_by"`          // Field to sort by
 	SortOrder       string    `json:"sort_order"`       // "asc" or "desc"
@@ -204,6 +214,13 @@ type EnhancedMetadataStore struct {
 	// Indices for fast searching
 	indices         map[string]*MetadataIndex
 	indicesMu       sync.RWMutex
+	indexSource     string        // Where the indices came from, see IndexStatus
+	rebuild         *IndexRebuild // Last rebuild from the file records, nil if none ran
+	snapshotCodec   string        // Compression of saved index snapshots
+	
+	// Limits applied to tags and categories on store
+	tagPolicy       TagPolicy
+	policyMu        sync.RWMutex
 	
 	// Background tasks
 	indexUpdateChan chan string
@@ -225,13 +242,16 @@ func NewEnhancedMetadataStore(dbPath string) (*EnhancedMetadataStore, error) {
 		db:              db,
 		logger:          logger,
 		indices:         make(map[string]*MetadataIndex),
+		tagPolicy:       DefaultTagPolicy(),
+		snapshotCodec:   DefaultSnapshotCodec,
 		indexUpdateChan: make(chan string, 1000),
 		stopChan:        make(chan bool),
 	}
 	
-	// Load existing indices
+	// Load existing indices, rebuilding them from the file records if the snapshot is unusable
 	if err := store.loadIndices(); err != nil {
-		store.logger.Warnf("⚠️ Failed to load indices: %v", err)
+		store.logger.Warnf("⚠️ Rebuilding indices: %v", err)
+		store.RebuildIndices(err.Error())
 	}
 	
 	// Start background index updater
@@ -248,6 +268,11 @@ func (ems *EnhancedMetadataStore) Close() error {
 	close(ems.indexUpdateChan)
 	ems.wg.Wait()
 	
+	// Index updates still queued go into the snapshot
+	for fileID := range ems.indexUpdateChan {
+		ems.updateIndicesForFile(fileID)
+	}
+	
 	// Save indices
 	if err := ems.saveIndices(); err != nil {
 		ems.logger.Errorf("❌ Failed to save indices: %v", err)
@@ -264,6 +289,11 @@ func (ems *EnhancedMetadataStore) StoreFileMetadata(meta *EnhancedFileMetadata)
 	}
 	meta.ModifiedAt = time.Now()
 	
+	// Normalize and validate tags and categories
+	if err := ems.normalizeFileTags(meta); err != nil {
+		return err
+	}
+	
 	// Calculate integrity hash
 	meta.IntegrityHash = ems.calculateIntegrityHash(meta)
 	
@@ -292,37 +322,209 @@ func (ems *EnhancedMetadataStore) StoreFileMetadata(meta *EnhancedFileMetadata)
 	return nil
 }
 
-// GetFileMetadata retrieves enhanced file metadata
+// GetFileMetadata retrieves enhanced file metadata and records the access
 func (ems *EnhancedMetadataStore) GetFileMetadata(fileID string) (*EnhancedFileMetadata, error) {
+	meta, err := ems.loadFileMetadata(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update access time
+	meta.AccessedAt = time.Now()
+	meta.AccessCount++
+	go ems.StoreFileMetadata(meta)
+
+	return meta, nil
+}
+
+// LoadFileMetadata retrieves enhanced file metadata without recording an access
+func (ems *EnhancedMetadataStore) LoadFileMetadata(fileID string) (*EnhancedFileMetadata, error) {
+	return ems.loadFileMetadata(fileID)
+}
+
+// RecordAccess stamps a read of the file's content on its record, so tiering
+// and lifecycle rules see the file as in use. The record is read and written
+// in one transaction, so the stamp never undoes a concurrent change to it.
+func (ems *EnhancedMetadataStore) RecordAccess(fileID string) error {
+	key := []byte(fmt.Sprintf("file:%s", fileID))
+	for {
+		err := ems.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+			var meta EnhancedFileMetadata
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &meta)
+			}); err != nil {
+				return err
+			}
+			meta.AccessedAt = time.Now()
+			meta.AccessCount++
+			value, err := json.Marshal(meta)
+			if err != nil {
+				return fmt.Errorf("failed to marshal file metadata: %v", err)
+			}
+			return txn.Set(key, value)
+		})
+		if errors.Is(err, badger.ErrConflict) {
+			continue // The record changed underneath; stamp the new one
+		}
+		return err
+	}
+}
+
+// loadFileMetadata reads file metadata without recording an access; index
+// updates use it so storing a file does not trigger another store
+func (ems *EnhancedMetadataStore) loadFileMetadata(fileID string) (*EnhancedFileMetadata, error) {
 	key := []byte(fmt.Sprintf("file:%s", fileID))
 	var meta EnhancedFileMetadata
-	
+
 	err := ems.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(key)
 		if err != nil {
 			return err
 		}
-		
+
 		return item.Value(func(val []byte) error {
 			return json.Unmarshal(val, &meta)
 		})
 	})
-	
+
 	if err != nil {
 		if err == badger.ErrKeyNotFound {
-			return nil, fmt.Errorf("file metadata not found: %s", fileID)
+			return nil, fmt.Errorf("%w: %s", ErrFileMetadataNotFound, fileID)
 		}
 		return nil, fmt.Errorf("failed to get file metadata: %v", err)
 	}
-	
-	// Update access time
-	meta.AccessedAt = time.Now()
-	meta.AccessCount++
-	go ems.StoreFileMetadata(&meta)
-	
 	return &meta, nil
 }
 
+// FilesByOwner returns the files owned by ownerID, found through the owner
+// index. Deleted files and files that changed owner since they were indexed
+// are left out; access statistics are not touched.
+func (ems *EnhancedMetadataStore) FilesByOwner(ownerID string) ([]*EnhancedFileMetadata, error) {
+	ems.indicesMu.RLock()
+	var fileIDs []string
+	index, ready := ems.indices["owner"], ems.indicesReady()
+	if index != nil {
+		fileIDs = append(fileIDs, index.IndexData[ownerID]...)
+	}
+	ems.indicesMu.RUnlock()
+	if !ready {
+		// The owner index is being rebuilt
+		files := make([]*EnhancedFileMetadata, 0)
+		err := ems.scanFiles(func(meta *EnhancedFileMetadata) {
+			if meta.OwnerID == ownerID && !meta.IsDeleted {
+				files = append(files, meta)
+			}
+		})
+		return files, err
+	}
+
+	files := make([]*EnhancedFileMetadata, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		meta, err := ems.loadFileMetadata(fileID)
+		if err != nil {
+			continue
+		}
+		if meta.OwnerID != ownerID || meta.IsDeleted {
+			continue
+		}
+		files = append(files, meta)
+	}
+	return files, nil
+}
+
+// ListFileMetadata returns every stored file's metadata without touching its access statistics
+func (ems *EnhancedMetadataStore) ListFileMetadata() ([]*EnhancedFileMetadata, error) {
+	var files []*EnhancedFileMetadata
+	err := ems.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("file:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				var meta EnhancedFileMetadata
+				if err := json.Unmarshal(val, &meta); err != nil {
+					return err
+				}
+				files = append(files, &meta)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file metadata: %v", err)
+	}
+	return files, nil
+}
+
+// ListAllFiles returns every file that is neither deleted nor past its TTL,
+// most recently modified first, without touching access statistics
+func (ems *EnhancedMetadataStore) ListAllFiles() ([]*EnhancedFileMetadata, error) {
+	all, err := ems.ListFileMetadata()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	files := make([]*EnhancedFileMetadata, 0, len(all))
+	for _, meta := range all {
+		if meta.IsDeleted || (meta.ExpiresAt != nil && !now.Before(*meta.ExpiresAt)) {
+			continue
+		}
+		files = append(files, meta)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].ModifiedAt.After(files[j].ModifiedAt)
+	})
+	return files, nil
+}
+
+// MarkFileDeleted flags a file deleted as of at without recording an access;
+// a file already flagged keeps its original time
+func (ems *EnhancedMetadataStore) MarkFileDeleted(fileID string, at time.Time) error {
+	meta, err := ems.loadFileMetadata(fileID)
+	if err != nil {
+		return err
+	}
+	if meta.IsDeleted {
+		return nil
+	}
+	meta.IsDeleted = true
+	meta.DeletedAt = &at
+	return ems.StoreFileMetadata(meta)
+}
+
+// DeleteFileMetadata removes a file's enhanced metadata and its index
+// entries, returning the record as it was
+func (ems *EnhancedMetadataStore) DeleteFileMetadata(fileID string) (*EnhancedFileMetadata, error) {
+	meta, err := ems.loadFileMetadata(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := []byte(fmt.Sprintf("file:%s", fileID))
+	if err := ems.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to delete file metadata: %v", err)
+	}
+
+	ems.indicesMu.Lock()
+	for name := range ems.indices {
+		ems.replaceIndexEntries(name, fileID, nil)
+	}
+	ems.indicesMu.Unlock()
+
+	return meta, nil
+}
+
 // StoreChunkMetadata stores enhanced chunk metadata
 func (ems *EnhancedMetadataStore) StoreChunkMetadata(meta *EnhancedChunkMetadata) error {
 	// Set timestamps
@@ -349,35 +551,101 @@ func (ems *EnhancedMetadataStore) StoreChunkMetadata(meta *EnhancedChunkMetadata
 
 // GetChunkMetadata retrieves enhanced chunk metadata
 func (ems *EnhancedMetadataStore) GetChunkMetadata(chunkID string) (*EnhancedChunkMetadata, error) {
+	meta, err := ems.LoadChunkMetadata(chunkID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Update access statistics
+	meta.LastAccessTime = time.Now()
+	meta.AccessCount++
+	go ems.StoreChunkMetadata(meta)
+	
+	return meta, nil
+}
+
+// LoadChunkMetadata reads enhanced chunk metadata without recording an access
+func (ems *EnhancedMetadataStore) LoadChunkMetadata(chunkID string) (*EnhancedChunkMetadata, error) {
 	key := []byte(fmt.Sprintf("chunk:%s", chunkID))
 	var meta EnhancedChunkMetadata
-	
+
 	err := ems.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(key)
 		if err != nil {
 			return err
 		}
-		
+
 		return item.Value(func(val []byte) error {
 			return json.Unmarshal(val, &meta)
 		})
 	})
-	
+
 	if err != nil {
 		if err == badger.ErrKeyNotFound {
 			return nil, fmt.Errorf("chunk metadata not found: %s", chunkID)
 		}
 		return nil, fmt.Errorf("failed to get chunk metadata: %v", err)
 	}
-	
-	// Update access statistics
-	meta.LastAccessTime = time.Now()
-	meta.AccessCount++
-	go ems.StoreChunkMetadata(&meta)
-	
 	return &meta, nil
 }
 
+// DeleteChunkMetadata removes a chunk's enhanced metadata; a missing record is not an error
+func (ems *EnhancedMetadataStore) DeleteChunkMetadata(chunkID string) error {
+	key := []byte(fmt.Sprintf("chunk:%s", chunkID))
+	if err := ems.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	}); err != nil {
+		return fmt.Errorf("failed to delete chunk metadata: %v", err)
+	}
+	return nil
+}
+
+// HasChunkMetadata reports whether enhanced metadata exists for a chunk,
+// without counting it as an access
+func (ems *EnhancedMetadataStore) HasChunkMetadata(chunkID string) (bool, error) {
+	key := []byte(fmt.Sprintf("chunk:%s", chunkID))
+	err := ems.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check chunk metadata: %v", err)
+	}
+	return true, nil
+}
+
+// LinkChunks adds chunk IDs missing from a file's ChunkHashes, in the order
+// given, without recording an access, and reports whether the file changed
+func (ems *EnhancedMetadataStore) LinkChunks(fileID string, chunkIDs []string) (bool, error) {
+	meta, err := ems.loadFileMetadata(fileID)
+	if err != nil {
+		return false, err
+	}
+	
+	linked := make(map[string]bool, len(meta.ChunkHashes))
+	for _, id := range meta.ChunkHashes {
+		linked[id] = true
+	}
+	changed := false
+	for _, id := range chunkIDs {
+		if !linked[id] {
+			meta.ChunkHashes = append(meta.ChunkHashes, id)
+			linked[id] = true
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	if meta.ChunkCount < len(meta.ChunkHashes) {
+		meta.ChunkCount = len(meta.ChunkHashes)
+	}
+	return true, ems.StoreFileMetadata(meta)
+}
+
 // CreateFileVersion creates a new file version
 func (ems *EnhancedMetadataStore) CreateFileVersion(fileID, createdBy, changeLog string) (*FileVersion, error) {
 	// Get current file metadata
@@ -458,47 +726,51 @@ func (ems *EnhancedMetadataStore) CreateFileRelationship(sourceFileID, targetFil
 	return relationship, nil
 }
 
-// SearchFiles performs advanced file search
-func (ems *EnhancedMetadataStore) SearchFiles(query *SearchQuery) (*SearchResult, error) {
-	start := time.Now()
-	
-	result := &SearchResult{
-		Files:   make([]*EnhancedFileMetadata, 0),
-		Facets:  make(map[string]map[string]int64),
+// PutFileRelationship stores a relationship as given, keeping its ID and
+// timestamps, as when restoring one recorded elsewhere
+func (ems *EnhancedMetadataStore) PutFileRelationship(relationship *FileRelationship) error {
+	value, err := json.Marshal(relationship)
+	if err != nil {
+		return fmt.Errorf("failed to marshal relationship: %v", err)
 	}
-	
-	// Use indices for efficient searching
-	candidateFileIDs := ems.findCandidateFiles(query)
-	
-	// Filter and score candidates
-	var matches []*EnhancedFileMetadata
-	for _, fileID := range candidateFileIDs {
-		if fileMeta, err := ems.GetFileMetadata(fileID); err == nil {
-			if ems.matchesQuery(fileMeta, query) {
-				matches = append(matches, fileMeta)
-			}
-		}
+	key := []byte(fmt.Sprintf("relationship:%s", relationship.RelationshipID))
+	err = ems.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store relationship: %v", err)
 	}
+	return nil
+}
+
+// GetFileRelationships returns every relationship in which the file is the source or target
+func (ems *EnhancedMetadataStore) GetFileRelationships(fileID string) ([]*FileRelationship, error) {
+	relationships := make([]*FileRelationship, 0)
 	
-	// Sort results
-	ems.sortResults(matches, query)
-	
-	// Apply pagination
-	result.TotalCount = int64(len(matches))
-	startIdx := query.Offset
-	endIdx := query.Offset + query.Limit
-	if startIdx < len(matches) {
-		if endIdx > len(matches) {
-			endIdx = len(matches)
+	err := ems.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		
+		prefix := []byte("relationship:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var relationship FileRelationship
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &relationship)
+			})
+			if err != nil {
+				return err
+			}
+			if relationship.SourceFileID == fileID || relationship.TargetFileID == fileID {
+				relationships = append(relationships, &relationship)
+			}
 		}
-		result.Files = matches[startIdx:endIdx]
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relationships: %v", err)
 	}
 	
-	// Calculate facets
-	result.Facets = ems.calculateFacets(matches)
-	
-	result.SearchDuration = time.Since(start)
-	return result, nil
+	return relationships, nil
 }
 
 // calculateIntegrityHash calculates an integrity hash for file metadata
@@ -537,7 +809,7 @@ func (ems *EnhancedMetadataStore) indexUpdater() {
 
 // updateIndicesForFile updates all indices for a specific file
 func (ems *EnhancedMetadataStore) updateIndicesForFile(fileID string) {
-	fileMeta, err := ems.GetFileMetadata(fileID)
+	fileMeta, err := ems.loadFileMetadata(fileID)
 	if err != nil {
 		return
 	}
@@ -566,20 +838,7 @@ func (ems *EnhancedMetadataStore) updateIndicesForFile(fileID string) {
 
 // updateTagIndex updates the tag index
 func (ems *EnhancedMetadataStore) updateTagIndex(fileID string, tags []string) {
-	if ems.indices["tag"] == nil {
-		ems.indices["tag"] = &MetadataIndex{
-			IndexType:   "tag",
-			IndexData:   make(map[string][]string),
-			LastUpdated: time.Now(),
-		}
-	}
-	
-	for _, tag := range tags {
-		if !ems.contains(ems.indices["tag"].IndexData[tag], fileID) {
-			ems.indices["tag"].IndexData[tag] = append(ems.indices["tag"].IndexData[tag], fileID)
-		}
-	}
-	ems.indices["tag"].LastUpdated = time.Now()
+	ems.replaceIndexEntries("tag", fileID, normalizeKeys(tags))
 }
 
 // updateOwnerIndex updates the owner index
@@ -645,20 +904,7 @@ func (ems *EnhancedMetadataStore) updateTimeIndex(fileID string, createdAt, modi
 
 // updateCategoryIndex updates the category index
 func (ems *EnhancedMetadataStore) updateCategoryIndex(fileID string, categories []string) {
-	if ems.indices["category"] == nil {
-		ems.indices["category"] = &MetadataIndex{
-			IndexType:   "category",
-			IndexData:   make(map[string][]string),
-			LastUpdated: time.Now(),
-		}
-	}
-	
-	for _, category := range categories {
-		if !ems.contains(ems.indices["category"].IndexData[category], fileID) {
-			ems.indices["category"].IndexData[category] = append(ems.indices["category"].IndexData[category], fileID)
-		}
-	}
-	ems.indices["category"].LastUpdated = time.Now()
+	ems.replaceIndexEntries("category", fileID, normalizeKeys(categories))
 }
 
 // updateHealthStatusIndex updates the health status index
@@ -677,76 +923,12 @@ func (ems *EnhancedMetadataStore) updateHealthStatusIndex(fileID, healthStatus s
 	ems.indices["health"].LastUpdated = time.Now()
 }
 
-// findCandidateFiles finds candidate files based on search query
-func (ems *EnhancedMetadataStore) findCandidateFiles(query *SearchQuery) []string {
-	ems.indicesMu.RLock()
-	defer ems.indicesMu.RUnlock()
-	
-	candidateSet := make(map[string]bool)
-	
-	// Search by tags
-	if len(query.Tags) > 0 && ems.indices["tag"] != nil {
-		for _, tag := range query.Tags {
-			if fileIDs, exists := ems.indices["tag"].IndexData[tag]; exists {
-				for _, fileID := range fileIDs {
-					candidateSet[fileID] = true
-				}
-			}
-		}
-	}
-	
-	// Search by categories
-	if len(query.Categories) > 0 && ems.indices["category"] != nil {
-		for _, category := range query.Categories {
-			if fileIDs, exists := ems.indices["category"].IndexData[category]; exists {
-				for _, fileID := range fileIDs {
-					candidateSet[fileID] = true
-				}
-			}
-		}
-	}
-	
-	// Search by owners
-	if len(query.OwnerIDs) > 0 && ems.indices["owner"] != nil {
-		for _, ownerID := range query.OwnerIDs {
-			if fileIDs, exists := ems.indices["owner"].IndexData[ownerID]; exists {
-				for _, fileID := range fileIDs {
-					candidateSet[fileID] = true
-				}
-			}
-		}
-	}
-	
-	// If no specific filters, get all files
-	if len(candidateSet) == 0 {
-		// Scan all files - this is expensive but necessary for full-text search
-		ems.db.View(func(txn *badger.Txn) error {
-			opts := badger.DefaultIteratorOptions
-			opts.PrefetchValues = false
-			it := txn.NewIterator(opts)
-			defer it.Close()
-			
-			prefix := []byte("file:")
-			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-				key := it.Item().Key()
-				fileID := strings.TrimPrefix(string(key), "file:")
-				candidateSet[fileID] = true
-			}
-			return nil
-		})
-	}
-	
-	// Convert to slice
-	candidates := make([]string, 0, len(candidateSet))
-	for fileID := range candidateSet {
-		candidates = append(candidates, fileID)
-	}
-	
-	return candidates
-}
-
 // matchesQuery checks if a file matches the search query
 func (ems *EnhancedMetadataStore) matchesQuery(fileMeta *EnhancedFileMetadata, query *SearchQuery) bool {
+	if query.FileIDs != nil && !query.FileIDs[fileMeta.FileID] {
+		return false
+	}
+	
 	// Text search
 	if query.Query != "" {
 		if !ems.matchesTextQuery(fileMeta, query.Query, query.Fields) {
@@ -790,9 +972,12 @@ func (ems *EnhancedMetadataStore) matchesQuery(fileMeta *EnhancedFileMetadata, q
 		}
 	}
 	
-	// Deleted filter
-	if query.IsDeleted != nil && fileMeta.IsDeleted != *query.IsDeleted {
-		return false
+	// Deleted filter; files past their TTL count as deleted before the expiry pass marks them
+	if query.IsDeleted != nil {
+		deleted := fileMeta.IsDeleted || (fileMeta.ExpiresAt != nil && !time.Now().Before(*fileMeta.ExpiresAt))
+		if deleted != *query.IsDeleted {
+			return false
+		}
 	}
 	
 	return true
@@ -839,77 +1024,6 @@ func (ems *EnhancedMetadataStore) matchesTextQuery(fileMeta *EnhancedFileMetadat
 	return false
 }
 
-// sortResults sorts search results based on query parameters
-func (ems *EnhancedMetadataStore) sortResults(files []*EnhancedFileMetadata, query *SearchQuery) {
-	if query.SortBy == "" {
-		query.SortBy = "modified_at"
-	}
-	if query.SortOrder == "" {
-		query.SortOrder = "desc"
-	}
-	
-	sort.Slice(files, func(i, j int) bool {
-		var less bool
-		
-		switch query.SortBy {
-		case "file_name":
-			less = files[i].FileName < files[j].FileName
-		case "file_size":
-			less = files[i].FileSize < files[j].FileSize
-		case "created_at":
-			less = files[i].CreatedAt.Before(files[j].CreatedAt)
-		case "modified_at":
-			less = files[i].ModifiedAt.Before(files[j].ModifiedAt)
-		case "accessed_at":
-			less = files[i].AccessedAt.Before(files[j].AccessedAt)
-		case "access_count":
-			less = files[i].AccessCount < files[j].AccessCount
-		default:
-			less = files[i].ModifiedAt.Before(files[j].ModifiedAt)
-		}
-		
-		if query.SortOrder == "desc" {
-			return !less
-		}
-		return less
-	})
-}
-
-// calculateFacets calculates facets for search results
-func (ems *EnhancedMetadataStore) calculateFacets(files []*EnhancedFileMetadata) map[string]map[string]int64 {
-	facets := make(map[string]map[string]int64)
-	
-	// Initialize facet maps
-	facets["tags"] = make(map[string]int64)
-	facets["categories"] = make(map[string]int64)
-	facets["owners"] = make(map[string]int64)
-	facets["health_status"] = make(map[string]int64)
-	facets["storage_class"] = make(map[string]int64)
-	
-	for _, file := range files {
-		// Count tags
-		for _, tag := range file.Tags {
-			facets["tags"][tag]++
-		}
-		
-		// Count categories
-		for _, category := range file.Categories {
-			facets["categories"][category]++
-		}
-		
-		// Count owners
-		facets["owners"][file.OwnerID]++
-		
-		// Count health status
-		facets["health_status"][file.HealthStatus]++
-		
-		// Count storage class
-		facets["storage_class"][file.StorageClass]++
-	}
-	
-	return facets
-}
-
 // Utility functions
 
 func (ems *EnhancedMetadataStore) contains(slice []string, item string) bool {
@@ -934,13 +1048,3 @@ func (ems *EnhancedMetadataStore) getSizeBucket(size int64) string {
 		return "huge"       // >= 100MB
 	}
 }
-
-func (ems *EnhancedMetadataStore) loadIndices() error {
-	// Load indices from database - simplified implementation
-	return nil
-}
-
-func (ems *EnhancedMetadataStore) saveIndices() error {
-	// Save indices to database - simplified implementation
-	return nil
-}
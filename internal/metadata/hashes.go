@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// MerkleHashPrefix marks file hashes that are Merkle roots of the ordered chunk hashes
+// rather than a SHA-256 of the whole file.
+const MerkleHashPrefix = "merkle:"
+
+// ValidFileHash reports whether hash is a SHA-256 hex digest, optionally as a Merkle root.
+func ValidFileHash(hash string) bool {
+	raw, err := hex.DecodeString(strings.TrimPrefix(hash, MerkleHashPrefix))
+	return err == nil && len(raw) == sha256.Size
+}
+
+// MerkleRoot combines hex chunk hashes pairwise with SHA-256 up to a single root.
+// An odd hash at any level is paired with itself; no hashes give "".
+func MerkleRoot(hashes []string) string {
+	if len(hashes) == 0 {
+		return ""
+	}
+	level := make([][]byte, 0, len(hashes))
+	for _, h := range hashes {
+		raw, err := hex.DecodeString(h)
+		if err != nil {
+			raw = []byte(h)
+		}
+		sum := sha256.Sum256(raw)
+		level = append(level, sum[:])
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			sum := sha256.Sum256(append(append([]byte(nil), level[i]...), right...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0])
+}
@@ -0,0 +1,380 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Index snapshot compression codecs
+const (
+	SnapshotCodecNone = "none"
+	SnapshotCodecGzip = "gzip"
+	SnapshotCodecZstd = "zstd"
+)
+
+// DefaultSnapshotCodec compresses index snapshots unless configured otherwise
+const DefaultSnapshotCodec = SnapshotCodecZstd
+
+// indexSnapshotKey holds the search indices saved on Close
+const indexSnapshotKey = "meta:index_snapshot"
+
+// indexRebuildBatch is how many files a rebuild indexes between progress updates
+const indexRebuildBatch = 256
+
+// Snapshot layout: magic, format version, codec, SHA-256 of the uncompressed
+// payload, then the payload compressed with the codec
+const (
+	snapshotMagic   = "DBIX"
+	snapshotVersion = 1
+	snapshotHeader  = len(snapshotMagic) + 2 + sha256.Size
+)
+
+var snapshotCodecIDs = map[string]byte{SnapshotCodecNone: 0, SnapshotCodecGzip: 1, SnapshotCodecZstd: 2}
+
+// ErrSnapshotCorrupt reports an index snapshot that fails its checksum or cannot be decoded
+var ErrSnapshotCorrupt = errors.New("index snapshot corrupt")
+
+// indexSnapshot is the saved form of the search indices
+type indexSnapshot struct {
+	SavedAt time.Time                 `json:"saved_at"`
+	Files   int                       `json:"files"` // File records covered, to catch snapshots older than the catalog
+	Indices map[string]*MetadataIndex `json:"indices"`
+}
+
+// IndexRebuild reports the progress of an index rebuild from the file records
+type IndexRebuild struct {
+	Reason       string    `json:"reason"`
+	Running      bool      `json:"running"`
+	FilesIndexed int       `json:"files_indexed"`
+	TotalFiles   int       `json:"total_files"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// IndexStatus describes where the search indices came from
+type IndexStatus struct {
+	SnapshotCodec string         `json:"snapshot_codec"`
+	Source        string         `json:"source"`  // "snapshot", "rebuild" or "empty"
+	Indices       map[string]int `json:"indices"` // Keys per index
+	LastRebuild   *IndexRebuild  `json:"last_rebuild,omitempty"`
+}
+
+// ParseSnapshotCodec checks an index snapshot codec name; empty means the default
+func ParseSnapshotCodec(codec string) (string, error) {
+	if codec == "" {
+		return DefaultSnapshotCodec, nil
+	}
+	if _, ok := snapshotCodecIDs[codec]; !ok {
+		return "", fmt.Errorf("unknown index snapshot codec %q, expected %q, %q or %q", codec, SnapshotCodecZstd, SnapshotCodecGzip, SnapshotCodecNone)
+	}
+	return codec, nil
+}
+
+// SetIndexSnapshotCodec sets how index snapshots are compressed from the next
+// save on; snapshots in any codec are read back
+func (ems *EnhancedMetadataStore) SetIndexSnapshotCodec(codec string) error {
+	codec, err := ParseSnapshotCodec(codec)
+	if err != nil {
+		return err
+	}
+	ems.indicesMu.Lock()
+	defer ems.indicesMu.Unlock()
+	ems.snapshotCodec = codec
+	return nil
+}
+
+// IndexStatus returns the snapshot codec, the size of each index and the
+// progress of the last rebuild
+func (ems *EnhancedMetadataStore) IndexStatus() IndexStatus {
+	ems.indicesMu.RLock()
+	defer ems.indicesMu.RUnlock()
+
+	status := IndexStatus{SnapshotCodec: ems.snapshotCodec, Source: ems.indexSource, Indices: make(map[string]int, len(ems.indices))}
+	for name, index := range ems.indices {
+		status.Indices[name] = len(index.IndexData)
+	}
+	if ems.rebuild != nil {
+		rebuild := *ems.rebuild
+		status.LastRebuild = &rebuild
+	}
+	return status
+}
+
+// encodeIndexSnapshot serializes a snapshot compressed with codec
+func encodeIndexSnapshot(snapshot *indexSnapshot, codec string) ([]byte, error) {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode index snapshot: %v", err)
+	}
+	sum := sha256.Sum256(payload)
+
+	var out bytes.Buffer
+	out.WriteString(snapshotMagic)
+	out.WriteByte(snapshotVersion)
+	out.WriteByte(snapshotCodecIDs[codec])
+	out.Write(sum[:])
+
+	switch codec {
+	case SnapshotCodecGzip:
+		zw := gzip.NewWriter(&out)
+		if _, err := zw.Write(payload); err != nil {
+			return nil, fmt.Errorf("failed to compress index snapshot: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress index snapshot: %v", err)
+		}
+	case SnapshotCodecZstd:
+		zw, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress index snapshot: %v", err)
+		}
+		defer zw.Close()
+		out.Write(zw.EncodeAll(payload, nil))
+	default:
+		out.Write(payload)
+	}
+	return out.Bytes(), nil
+}
+
+// decodeIndexSnapshot reads a snapshot back, checking its checksum
+func decodeIndexSnapshot(data []byte) (*indexSnapshot, error) {
+	if len(data) < snapshotHeader || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad header", ErrSnapshotCorrupt)
+	}
+	if version := data[len(snapshotMagic)]; version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrSnapshotCorrupt, version)
+	}
+	codec := data[len(snapshotMagic)+1]
+	sum := data[len(snapshotMagic)+2 : snapshotHeader]
+	body := data[snapshotHeader:]
+
+	var payload []byte
+	var err error
+	switch codec {
+	case snapshotCodecIDs[SnapshotCodecNone]:
+		payload = body
+	case snapshotCodecIDs[SnapshotCodecGzip]:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(body)); err == nil {
+			payload, err = io.ReadAll(zr)
+		}
+	case snapshotCodecIDs[SnapshotCodecZstd]:
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(nil); err == nil {
+			payload, err = zr.DecodeAll(body, nil)
+			zr.Close()
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown codec %d", ErrSnapshotCorrupt, codec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if actual := sha256.Sum256(payload); !bytes.Equal(actual[:], sum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+	}
+
+	var snapshot indexSnapshot
+	if err := json.Unmarshal(payload, &snapshot); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	return &snapshot, nil
+}
+
+// loadIndices installs the saved index snapshot. A missing, corrupt or stale
+// snapshot is reported as an error so the caller can rebuild.
+func (ems *EnhancedMetadataStore) loadIndices() error {
+	var data []byte
+	err := ems.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(indexSnapshotKey))
+		if err != nil {
+			return err
+		}
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+	files, countErr := ems.countFileRecords()
+	if countErr != nil {
+		return countErr
+	}
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		if files == 0 {
+			ems.indexSource = "empty"
+			return nil
+		}
+		return fmt.Errorf("no index snapshot for %d files", files)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read index snapshot: %v", err)
+	}
+
+	snapshot, err := decodeIndexSnapshot(data)
+	if err != nil {
+		return err
+	}
+	if snapshot.Files != files {
+		return fmt.Errorf("index snapshot covers %d files, catalog has %d", snapshot.Files, files)
+	}
+
+	ems.indicesMu.Lock()
+	defer ems.indicesMu.Unlock()
+	ems.indices = snapshot.Indices
+	if ems.indices == nil {
+		ems.indices = make(map[string]*MetadataIndex)
+	}
+	ems.indexSource = "snapshot"
+	return nil
+}
+
+// saveIndices writes the current indices as a compressed, checksummed
+// snapshot. Indices from an unfinished rebuild are not saved, so the next
+// start rebuilds again.
+func (ems *EnhancedMetadataStore) saveIndices() error {
+	files, err := ems.countFileRecords()
+	if err != nil {
+		return err
+	}
+
+	ems.indicesMu.RLock()
+	if ems.rebuild != nil && (ems.rebuild.Running || ems.rebuild.Error != "") {
+		ems.indicesMu.RUnlock()
+		return nil
+	}
+	data, err := encodeIndexSnapshot(&indexSnapshot{SavedAt: time.Now(), Files: files, Indices: ems.indices}, ems.snapshotCodec)
+	ems.indicesMu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return ems.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(indexSnapshotKey), data)
+	})
+}
+
+// countFileRecords counts file records without decoding them
+func (ems *EnhancedMetadataStore) countFileRecords() (int, error) {
+	count := 0
+	err := ems.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("file:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count file records: %v", err)
+	}
+	return count, nil
+}
+
+// fileRecordIDs lists the IDs of every file record
+func (ems *EnhancedMetadataStore) fileRecordIDs() ([]string, error) {
+	var ids []string
+	err := ems.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("file:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			ids = append(ids, string(it.Item().Key()[len(prefix):]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file records: %v", err)
+	}
+	return ids, nil
+}
+
+// RebuildIndices starts rebuilding the search indices from the file records
+// in the background. It returns false if a rebuild is already running.
+func (ems *EnhancedMetadataStore) RebuildIndices(reason string) bool {
+	ems.indicesMu.Lock()
+	if ems.rebuild != nil && ems.rebuild.Running {
+		ems.indicesMu.Unlock()
+		return false
+	}
+	// Searches scan the catalog until the rebuild finishes, so none come up short
+	ems.indices = make(map[string]*MetadataIndex)
+	ems.rebuild = &IndexRebuild{Reason: reason, Running: true, StartedAt: time.Now()}
+	ems.indexSource = "rebuild"
+	ems.indicesMu.Unlock()
+
+	ems.wg.Add(1)
+	go ems.rebuildIndices()
+	return true
+}
+
+// rebuildIndices indexes every file record a batch at a time, recording
+// progress between batches, and saves a fresh snapshot when done
+func (ems *EnhancedMetadataStore) rebuildIndices() {
+	defer ems.wg.Done()
+
+	finish := func(err error) {
+		ems.indicesMu.Lock()
+		ems.rebuild.Running = false
+		ems.rebuild.FinishedAt = time.Now()
+		if err != nil {
+			ems.rebuild.Error = err.Error()
+		}
+		rebuild := *ems.rebuild
+		ems.indicesMu.Unlock()
+
+		if err != nil {
+			ems.logger.Errorf("❌ Index rebuild failed after %d of %d files: %v", rebuild.FilesIndexed, rebuild.TotalFiles, err)
+			return
+		}
+		ems.logger.Infof("🗂️ Rebuilt indices for %d files in %v", rebuild.FilesIndexed, rebuild.FinishedAt.Sub(rebuild.StartedAt))
+		if err := ems.saveIndices(); err != nil {
+			ems.logger.Warnf("⚠️ Failed to save rebuilt indices: %v", err)
+		}
+	}
+
+	ids, err := ems.fileRecordIDs()
+	if err != nil {
+		finish(err)
+		return
+	}
+	ems.indicesMu.Lock()
+	ems.rebuild.TotalFiles = len(ids)
+	ems.indicesMu.Unlock()
+
+	for start := 0; start < len(ids); start += indexRebuildBatch {
+		select {
+		case <-ems.stopChan:
+			finish(fmt.Errorf("store closed"))
+			return
+		default:
+		}
+		end := min(start+indexRebuildBatch, len(ids))
+		for _, fileID := range ids[start:end] {
+			ems.updateIndicesForFile(fileID)
+		}
+		ems.indicesMu.Lock()
+		ems.rebuild.FilesIndexed = end
+		ems.indicesMu.Unlock()
+	}
+	finish(nil)
+}
+
+// indicesReady reports whether the indices cover the whole catalog. Callers hold indicesMu.
+func (ems *EnhancedMetadataStore) indicesReady() bool {
+	return ems.rebuild == nil || !ems.rebuild.Running
+}
@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestIndexSnapshotCodecsRoundTripAndCatchCorruption(t *testing.T) {
+	snapshot := &indexSnapshot{Files: 2, Indices: map[string]*MetadataIndex{
+		"owner": {IndexType: "owner", IndexData: map[string][]string{"alice": {"file-1", "file-2"}}},
+	}}
+	for _, codec := range []string{SnapshotCodecNone, SnapshotCodecGzip, SnapshotCodecZstd} {
+		data, err := encodeIndexSnapshot(snapshot, codec)
+		if err != nil {
+			t.Fatalf("%s: encode failed: %v", codec, err)
+		}
+		decoded, err := decodeIndexSnapshot(data)
+		if err != nil {
+			t.Fatalf("%s: decode failed: %v", codec, err)
+		}
+		if got := decoded.Indices["owner"].IndexData["alice"]; len(got) != 2 {
+			t.Errorf("%s: expected the owner index back, got %v", codec, got)
+		}
+
+		data[len(data)-2] ^= 0xFF
+		if _, err := decodeIndexSnapshot(data); !errors.Is(err, ErrSnapshotCorrupt) {
+			t.Errorf("%s: expected a flipped byte reported corrupt, got %v", codec, err)
+		}
+	}
+}
+
+func TestCorruptIndexSnapshotIsRebuiltFromFileRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enhanced")
+	store, err := NewEnhancedMetadataStore(path)
+	if err != nil {
+		t.Fatalf("failed to open enhanced metadata store: %v", err)
+	}
+	seedCatalog(t, store, 600)
+	if err := store.Close(); err != nil {
+		t.Fatalf("failed to close store: %v", err)
+	}
+
+	// Reopening loads the saved snapshot instead of rebuilding
+	store, err = NewEnhancedMetadataStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	if status := store.IndexStatus(); status.Source != "snapshot" || status.Indices["tag"] == 0 {
+		t.Fatalf("expected indices loaded from the snapshot, got %+v", status)
+	}
+	store.Close()
+
+	// Damage the snapshot behind the store's back
+	db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(indexSnapshotKey))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		data[len(data)/2] ^= 0xFF
+		return txn.Set([]byte(indexSnapshotKey), data)
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("failed to corrupt snapshot: %v", err)
+	}
+
+	store, err = NewEnhancedMetadataStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	// Searches stay complete while the rebuild runs, then use the rebuilt indices
+	query := &SearchQuery{Tags: []string{"tag-2"}, Limit: 1000}
+	result, err := store.SearchFiles(query)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	want := result.TotalCount
+	if want != 600/5+600/7-600/35 {
+		t.Fatalf("expected every file tagged tag-2 found, got %d", want)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	var status IndexStatus
+	for {
+		status = store.IndexStatus()
+		if status.LastRebuild != nil && !status.LastRebuild.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("index rebuild did not finish: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	rebuild := status.LastRebuild
+	if rebuild.Error != "" || rebuild.FilesIndexed != 600 || rebuild.TotalFiles != 600 {
+		t.Fatalf("expected all 600 files reindexed, got %+v", rebuild)
+	}
+	if status.Source != "rebuild" || status.Indices["tag"] != 7 {
+		t.Errorf("expected the tag index rebuilt, got %+v", status)
+	}
+
+	result, err = store.SearchFiles(query)
+	if err != nil || result.TotalCount != want {
+		t.Errorf("expected %d files through the rebuilt index, got %v, %v", want, result, err)
+	}
+	if files, _ := store.FilesByOwner("user-3"); len(files) != 60 {
+		t.Errorf("expected 60 files for user-3 through the rebuilt owner index, got %d", len(files))
+	}
+}
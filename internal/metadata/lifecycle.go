@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrFileExpired is returned for files whose TTL has elapsed or that were soft-deleted.
+var ErrFileExpired = errors.New("file has expired")
+
+// SetFileExpiry sets when a file expires under its ID and name; a zero time clears it.
+func (ms *MetadataStore) SetFileExpiry(fileID string, expiresAt time.Time) error {
+	meta, err := ms.GetFileMetadataByID(fileID)
+	if err != nil {
+		return err
+	}
+	meta.ExpiresAt = 0
+	if !expiresAt.IsZero() {
+		meta.ExpiresAt = expiresAt.Unix()
+	}
+	if err := ms.PutFileMetadataByID(fileID, meta); err != nil {
+		return err
+	}
+	return ms.PutFileMetadata(meta)
+}
+
+// DeleteFileMetadataByID removes the file ID entry of a file.
+func (ms *MetadataStore) DeleteFileMetadataByID(fileID string) error {
+	return ms.deleteKey([]byte("fileid:" + fileID))
+}
+
+// DeleteFileMetadata removes the file name entry of a file.
+func (ms *MetadataStore) DeleteFileMetadata(fileName string) error {
+	return ms.deleteKey([]byte("file:" + fileName))
+}
+
+// DeleteChunkMetadata removes a chunk record.
+func (ms *MetadataStore) DeleteChunkMetadata(hash string) error {
+	return ms.deleteKey([]byte("chunk:" + hash))
+}
+
+// DeleteVersions removes every recorded version of a file.
+func (ms *MetadataStore) DeleteVersions(fileID string) error {
+	records, err := ms.ListVersions(fileID)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		for _, record := range records {
+			if err := txn.Delete(versionKey(record.FileID, record.Version)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ms *MetadataStore) deleteKey(key []byte) error {
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
@@ -8,32 +8,120 @@ import (
 	"github.com/dgraph-io/badger/v4"
 )
 
+// Key modes recorded in FileMetadata.KeyMode.
+const (
+	KeyModePassword      = "password"       // Chunks encrypted with a user-supplied password
+	KeyModeServerManaged = "server-managed" // Chunks encrypted with a data key wrapped by the server master key
+	KeyModeClient        = "client"         // Chunks encrypted by the client; the server never holds the key
+)
+
+// File health recorded in FileMetadata.Health; empty means healthy.
+const (
+	HealthDegraded  = "degraded"  // Chunks are not all present in storage
+	HealthCorrupted = "corrupted" // The stored chunks do not reconstruct the file
+)
+
 // FileMetadata represents metadata for a file.
 type FileMetadata struct {
 	FileName    string   `json:"file_name"`
 	FileSize    int64    `json:"file_size"`
 	NumChunks   int      `json:"num_chunks"`
 	ChunkHashes []string `json:"chunk_hashes"`
-	CreatedAt   int64    `json:"created_at"` // Unix timestamp
+	CreatedAt   int64    `json:"created_at"`            // Unix timestamp
+	KeyMode     string   `json:"key_mode,omitempty"`    // Empty means KeyModePassword
+	KeyID       string   `json:"key_id,omitempty"`      // Master key fingerprint for server-managed files
+	WrappedKey  []byte   `json:"wrapped_key,omitempty"` // Data key wrapped by the master key
+	Health      string   `json:"health,omitempty"`      // Empty when healthy, see HealthDegraded and HealthCorrupted
+	Version     int      `json:"version,omitempty"`     // Current version, 0 until the file is first updated
+	ContentID   string   `json:"content_id,omitempty"`  // Content hash of the current version, empty when it is the file ID
+	ExpiresAt   int64    `json:"expires_at,omitempty"`  // Unix timestamp after which the file expires, 0 for never
+	DeletedAt   int64    `json:"deleted_at,omitempty"`  // Unix timestamp of the soft delete, 0 while the file is live
+	Redundancy  string   `json:"redundancy,omitempty"`  // Empty means RedundancyReplication
+	// Unix timestamp the file was last reconstructed and checked against its hash, 0 for never
+	LastVerified int64 `json:"last_verified,omitempty"`
+	// Known plaintext sealed under the file's key, opened to check a key without reading chunks
+	KeyCanary     []byte `json:"key_canary,omitempty"`
+	CanaryVersion int    `json:"canary_version,omitempty"` // Canary scheme, 0 when the file has no canary
+	CanaryAlgo    uint8  `json:"canary_algo,omitempty"`    // Chunk header encryption algorithm the canary was sealed with
+	HashAlgo      string `json:"hash_algo,omitempty"`      // Algorithm of the chunk hashes, empty for SHA-256
+}
+
+// CurrentContentID returns the ID the current version's chunks are stored under.
+func (fm FileMetadata) CurrentContentID(fileID string) string {
+	if fm.ContentID != "" {
+		return fm.ContentID
+	}
+	return fileID
+}
+
+// Expired reports whether the file's TTL has elapsed.
+func (fm FileMetadata) Expired(now time.Time) bool {
+	return fm.ExpiresAt != 0 && now.Unix() >= fm.ExpiresAt
+}
+
+// Available reports whether the file may be listed and downloaded.
+func (fm FileMetadata) Available(now time.Time) bool {
+	return fm.DeletedAt == 0 && !fm.Expired(now)
+}
+
+// Remaining returns the file's remaining lifetime, 0 when it never expires or has expired.
+func (fm FileMetadata) Remaining(now time.Time) time.Duration {
+	if fm.ExpiresAt == 0 || fm.Expired(now) {
+		return 0
+	}
+	return time.Unix(fm.ExpiresAt, 0).Sub(now)
+}
+
+// IsClientEncrypted reports whether the file was encrypted by the client.
+func (fm FileMetadata) IsClientEncrypted() bool {
+	return fm.KeyMode == KeyModeClient
+}
+
+// IsServerManaged reports whether the file was encrypted with a server-managed key.
+func (fm FileMetadata) IsServerManaged() bool {
+	return fm.KeyMode == KeyModeServerManaged
 }
 
 // ChunkMetadata represents metadata for a chunk with linked-list capabilities.
 type ChunkMetadata struct {
-	Index        int    `json:"index"`         // Position of this chunk in the sequence
-	Hash         string `json:"hash"`          // SHA-256 hash of original chunk data
-	Path         string `json:"path"`          // Storage path (hash) of encrypted chunk file
-	Size         int64  `json:"size"`          // Encrypted size of this chunk
-	Offset       int64  `json:"offset"`        // Byte offset in the original file
-	PrevIndex    int    `json:"prev_index"`    // Index of previous chunk (-1 if first)
-	NextIndex    int    `json:"next_index"`    // Index of next chunk (-1 if last)
-	TotalChunks  int    `json:"total_chunks"`  // Total chunks in this file
-	FileID       string `json:"file_id"`       // Unique file identifier (SHA-256 of full file)
-	IsCompressed bool   `json:"is_compressed"` // Whether this chunk was compressed
+	Index        int    `json:"index"`               // Position of this chunk in the sequence
+	Hash         string `json:"hash"`                // Hash of original chunk data, see HashAlgo
+	Path         string `json:"path"`                // Storage path (hash) of encrypted chunk file
+	Size         int64  `json:"size"`                // Encrypted size of this chunk
+	Offset       int64  `json:"offset"`              // Byte offset in the original file
+	PrevIndex    int    `json:"prev_index"`          // Index of previous chunk (-1 if first)
+	NextIndex    int    `json:"next_index"`          // Index of next chunk (-1 if last)
+	TotalChunks  int    `json:"total_chunks"`        // Total chunks in this file
+	FileID       string `json:"file_id"`             // Unique file identifier (SHA-256 of full file)
+	IsCompressed bool   `json:"is_compressed"`       // Whether this chunk was compressed
+	FileName     string `json:"file_name,omitempty"` // Original file name, when known
+	RefCount     int    `json:"ref_count,omitempty"` // Files referencing this chunk, 0 when untracked
+	CRC32C       uint32 `json:"crc32c,omitempty"`    // CRC32C of the stored bytes for fast corruption screening, 0 when not recorded
+	HashAlgo     string `json:"hash_algo,omitempty"` // Algorithm of Hash, empty for SHA-256
+	// Compression of a compressed chunk: the algorithm, its compressed size
+	// and its original size divided by that
+	CompressionAlgo  string  `json:"compression_algo,omitempty"`
+	CompressedSize   int64   `json:"compressed_size,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	// A chunk shared with another file keeps the header it was sealed with:
+	// the content hash and index of the file that stored it
+	SharedFrom  string `json:"shared_from,omitempty"`
+	SharedIndex int    `json:"shared_index,omitempty"`
+}
+
+// SealedAs returns the content hash and index the stored chunk's header
+// names, for a chunk read as part of fileID
+func (c ChunkMetadata) SealedAs(fileID string) (string, int) {
+	if c.SharedFrom != "" {
+		return c.SharedFrom, c.SharedIndex
+	}
+	return fileID, c.Index
 }
 
 // MetadataStore wraps BadgerDB for metadata operations.
 type MetadataStore struct {
-	db *badger.DB
+	db      *badger.DB
+	barrier *ChunkBarrier // Orders chunk deletion against new references
 }
 
 // OpenMetadataStore opens (or creates) a BadgerDB at the given path.
@@ -42,7 +130,7 @@ func OpenMetadataStore(dbPath string) (*MetadataStore, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open BadgerDB: %v", err)
 	}
-	return &MetadataStore{db: db}, nil
+	return &MetadataStore{db: db, barrier: NewChunkBarrier()}, nil
 }
 
 // Close closes the BadgerDB.
@@ -112,6 +200,112 @@ func (ms *MetadataStore) GetFileMetadataByID(fileID string) (FileMetadata, error
 	return meta, err
 }
 
+// CountFiles returns the number of files recorded by file ID.
+func (ms *MetadataStore) CountFiles() (int, error) {
+	count := 0
+	err := ms.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("fileid:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// CountAvailableFiles returns the number of files recorded by file ID that
+// are neither soft-deleted nor expired at now.
+func (ms *MetadataStore) CountAvailableFiles(now time.Time) (int, error) {
+	count := 0
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("fileid:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var file FileMetadata
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &file)
+			}); err != nil {
+				return err
+			}
+			if file.Available(now) {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// ListFiles returns the files recorded by name, in name order, skipping the
+// first offset and returning at most limit of them; a limit of 0 or less
+// returns all the rest.
+func (ms *MetadataStore) ListFiles(limit, offset int) ([]FileMetadata, error) {
+	files := make([]FileMetadata, 0)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("file:")
+		skipped := 0
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if limit > 0 && len(files) == limit {
+				break
+			}
+			err := it.Item().Value(func(val []byte) error {
+				var meta FileMetadata
+				if err := json.Unmarshal(val, &meta); err != nil {
+					return err
+				}
+				files = append(files, meta)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// ListFileMetadataByID returns every file recorded by file ID, keyed by ID.
+func (ms *MetadataStore) ListFileMetadataByID() (map[string]FileMetadata, error) {
+	files := make(map[string]FileMetadata)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("fileid:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			err := item.Value(func(val []byte) error {
+				var meta FileMetadata
+				if err := json.Unmarshal(val, &meta); err != nil {
+					return err
+				}
+				files[string(item.Key()[len(prefix):])] = meta
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
 // PutChunkMetadata stores chunk metadata.
 func (ms *MetadataStore) PutChunkMetadata(meta ChunkMetadata) error {
 	key := []byte("chunk:" + meta.Hash)
@@ -140,7 +334,8 @@ func (ms *MetadataStore) GetChunkMetadata(hash string) (ChunkMetadata, error) {
 	return meta, err
 }
 
-// GetChunksByFileID retrieves all chunks for a specific FileID
+// GetChunksByFileID retrieves all chunks for a specific FileID, including
+// those it shares with other files
 func (ms *MetadataStore) GetChunksByFileID(fileID string) ([]ChunkMetadata, error) {
 	var chunks []ChunkMetadata
 	err := ms.db.View(func(txn *badger.Txn) error {
@@ -168,6 +363,36 @@ func (ms *MetadataStore) GetChunksByFileID(fileID string) ([]ChunkMetadata, erro
 		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ms.GetChunkReferences(fileID)
+	return append(chunks, shared...), err
+}
+
+// ListChunkMetadata returns every chunk metadata record.
+func (ms *MetadataStore) ListChunkMetadata() ([]ChunkMetadata, error) {
+	var chunks []ChunkMetadata
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("chunk:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				var chunk ChunkMetadata
+				if err := json.Unmarshal(val, &chunk); err != nil {
+					return err
+				}
+				chunks = append(chunks, chunk)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	return chunks, err
 }
 
@@ -2,8 +2,9 @@ package metadata
 
 import (
 	"os"
-	"testing"
 	"path/filepath"
+	"strings"
+	"testing"
 )
 
 func TestMetadataStoreCRUD(t *testing.T) {
@@ -51,4 +52,50 @@ func TestMetadataStoreCRUD(t *testing.T) {
 	if gotChunkMeta.Hash != chunkMeta.Hash || gotChunkMeta.Path != chunkMeta.Path {
 		t.Errorf("retrieved chunk metadata does not match")
 	}
-} 
\ No newline at end of file
+}
+
+func TestListFilesPagesByName(t *testing.T) {
+	store, err := OpenMetadataStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer store.Close()
+
+	if files, err := store.ListFiles(10, 0); err != nil || len(files) != 0 {
+		t.Fatalf("expected an empty store to list no files, got %v (%v)", files, err)
+	}
+	for _, name := range []string{"c.txt", "a.txt", "d.txt", "b.txt"} {
+		if err := store.PutFileMetadata(NewFileMetadata(name, 10, []string{"hash-" + name})); err != nil {
+			t.Fatalf("failed to put %s: %v", name, err)
+		}
+	}
+	// Records stored by ID and chunk records are not files by name
+	store.PutFileMetadataByID("some-id", NewFileMetadata("e.txt", 10, nil))
+	store.PutChunkMetadata(ChunkMetadata{Hash: "hash-a.txt", FileName: "a.txt"})
+
+	names := func(files []FileMetadata) []string {
+		out := make([]string, 0, len(files))
+		for _, f := range files {
+			out = append(out, f.FileName)
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		limit, offset int
+		want          string
+	}{
+		{0, 0, "a.txt b.txt c.txt d.txt"},
+		{2, 0, "a.txt b.txt"},
+		{2, 1, "b.txt c.txt"},
+		{10, 3, "d.txt"},
+		{10, 4, ""},
+	} {
+		files, err := store.ListFiles(tc.limit, tc.offset)
+		if err != nil {
+			t.Fatalf("ListFiles(%d, %d) failed: %v", tc.limit, tc.offset, err)
+		}
+		if got := strings.Join(names(files), " "); got != tc.want {
+			t.Errorf("ListFiles(%d, %d) = %q, want %q", tc.limit, tc.offset, got, tc.want)
+		}
+	}
+}
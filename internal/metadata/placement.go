@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"encoding/json"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Where a file's effective placement constraint comes from.
+const (
+	PlacementSourceNone  = ""      // Unconstrained, any node may hold the file
+	PlacementSourceFile  = "file"  // Set on the file itself
+	PlacementSourceOwner = "owner" // Inherited from the file owner's default
+)
+
+// PlacementConstraint restricts which nodes may hold replicas of a file's
+// chunks. A node qualifies when it is in AllowedNodes (if any are listed) and
+// carries every one of RequiredLabels.
+type PlacementConstraint struct {
+	AllowedNodes   []string `json:"allowed_nodes,omitempty"`
+	RequiredLabels []string `json:"required_labels,omitempty"`
+	UpdatedBy      string   `json:"updated_by,omitempty"`
+	UpdatedAt      int64    `json:"updated_at,omitempty"` // Unix timestamp
+}
+
+// IsZero reports whether the constraint allows every node
+func (c PlacementConstraint) IsZero() bool {
+	return len(c.AllowedNodes) == 0 && len(c.RequiredLabels) == 0
+}
+
+// Allows reports whether a node with the given ID and labels may hold replicas
+func (c PlacementConstraint) Allows(nodeID string, labels []string) bool {
+	if len(c.AllowedNodes) > 0 {
+		allowed := false
+		for _, id := range c.AllowedNodes {
+			if id == nodeID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	for _, required := range c.RequiredLabels {
+		found := false
+		for _, label := range labels {
+			if label == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func filePlacementKey(fileID string) []byte {
+	return []byte("placement:file:" + fileID)
+}
+
+func ownerPlacementKey(ownerID string) []byte {
+	return []byte("placement:owner:" + ownerID)
+}
+
+func (ms *MetadataStore) putPlacement(key []byte, constraint PlacementConstraint) error {
+	val, err := json.Marshal(constraint)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, val)
+	})
+}
+
+// getPlacement returns the constraint stored under key; ok is false when none is
+func (ms *MetadataStore) getPlacement(key []byte) (constraint PlacementConstraint, ok bool, err error) {
+	err = ms.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ok = true
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &constraint)
+		})
+	})
+	return constraint, ok, err
+}
+
+// PutFilePlacement constrains where a file's replicas may be placed.
+func (ms *MetadataStore) PutFilePlacement(fileID string, constraint PlacementConstraint) error {
+	return ms.putPlacement(filePlacementKey(fileID), constraint)
+}
+
+// DeleteFilePlacement removes a file's own constraint; the owner's default applies again.
+func (ms *MetadataStore) DeleteFilePlacement(fileID string) error {
+	return ms.deleteKey(filePlacementKey(fileID))
+}
+
+// PutOwnerPlacement sets the default constraint for every file a user owns.
+func (ms *MetadataStore) PutOwnerPlacement(ownerID string, constraint PlacementConstraint) error {
+	return ms.putPlacement(ownerPlacementKey(ownerID), constraint)
+}
+
+// GetOwnerPlacement returns a user's default constraint; ok is false when none is set.
+func (ms *MetadataStore) GetOwnerPlacement(ownerID string) (PlacementConstraint, bool, error) {
+	return ms.getPlacement(ownerPlacementKey(ownerID))
+}
+
+// DeleteOwnerPlacement removes a user's default constraint.
+func (ms *MetadataStore) DeleteOwnerPlacement(ownerID string) error {
+	return ms.deleteKey(ownerPlacementKey(ownerID))
+}
+
+// EffectivePlacement returns the constraint that applies to a file and where
+// it comes from: the file's own constraint wins over its owner's default.
+func (ms *MetadataStore) EffectivePlacement(fileID, ownerID string) (PlacementConstraint, string, error) {
+	if constraint, ok, err := ms.getPlacement(filePlacementKey(fileID)); err != nil || ok {
+		return constraint, PlacementSourceFile, err
+	}
+	if ownerID != "" {
+		if constraint, ok, err := ms.getPlacement(ownerPlacementKey(ownerID)); err != nil || ok {
+			return constraint, PlacementSourceOwner, err
+		}
+	}
+	return PlacementConstraint{}, PlacementSourceNone, nil
+}
@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"encoding/json"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Redundancy schemes recorded in FileMetadata.Redundancy.
+const (
+	RedundancyReplication = "replication" // Whole chunks are copied to several nodes
+	RedundancyErasure     = "erasure"     // Chunks are protected by Reed-Solomon parity shards
+)
+
+// RedundancyScheme returns the file's redundancy scheme; files recorded before schemes existed are replicated.
+func (fm FileMetadata) RedundancyScheme() string {
+	if fm.Redundancy == "" {
+		return RedundancyReplication
+	}
+	return fm.Redundancy
+}
+
+// ParityRecord lists the parity shards protecting one stored content. The
+// content's chunks are grouped in order into stripes of DataShards chunks;
+// Stripes[s] holds the storage paths of stripe s's parity shards.
+type ParityRecord struct {
+	ContentID    string     `json:"content_id"`
+	DataShards   int        `json:"data_shards"`
+	ParityShards int        `json:"parity_shards"`
+	ShardSizes   []int64    `json:"shard_sizes"` // Padded shard length of each stripe
+	Stripes      [][]string `json:"stripes"`
+	CreatedAt    int64      `json:"created_at"` // Unix timestamp
+}
+
+// Paths returns the storage paths of every parity shard in the record.
+func (pr ParityRecord) Paths() []string {
+	var paths []string
+	for _, stripe := range pr.Stripes {
+		paths = append(paths, stripe...)
+	}
+	return paths
+}
+
+// SchemeUsage counts the files and bytes stored under one redundancy scheme.
+type SchemeUsage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+func parityKey(contentID string) []byte {
+	return []byte("parity:" + contentID)
+}
+
+// PutParity stores the parity record of a content.
+func (ms *MetadataStore) PutParity(record ParityRecord) error {
+	val, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(parityKey(record.ContentID), val)
+	})
+}
+
+// GetParity retrieves the parity record of a content.
+func (ms *MetadataStore) GetParity(contentID string) (ParityRecord, error) {
+	var record ParityRecord
+	err := ms.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(parityKey(contentID))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &record)
+		})
+	})
+	return record, err
+}
+
+// DeleteParity removes the parity record of a content.
+func (ms *MetadataStore) DeleteParity(contentID string) error {
+	return ms.deleteKey(parityKey(contentID))
+}
+
+// RedundancyDistribution counts live files and their bytes per redundancy scheme.
+func (ms *MetadataStore) RedundancyDistribution() (map[string]SchemeUsage, error) {
+	files, err := ms.ListFileMetadataByID()
+	if err != nil {
+		return nil, err
+	}
+	usage := map[string]SchemeUsage{
+		RedundancyReplication: {},
+		RedundancyErasure:     {},
+	}
+	for _, meta := range files {
+		if meta.DeletedAt != 0 {
+			continue
+		}
+		scheme := meta.RedundancyScheme()
+		u := usage[scheme]
+		u.Files++
+		u.Bytes += meta.FileSize
+		usage[scheme] = u
+	}
+	return usage, nil
+}
@@ -0,0 +1,180 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Lifecycle rule actions.
+const (
+	LifecycleDelete    = "delete"    // Soft-delete; the expiry pass purges the file later
+	LifecycleTier      = "tier"      // Move the file's chunks to a storage class
+	LifecycleReplicate = "replicate" // Raise the file's replica count
+	LifecyclePin       = "pin"       // Clear the file's TTL and protect it from delete and tier actions
+	LifecycleNotify    = "notify"    // Publish an event for the file
+)
+
+// LifecycleCondition selects the files a rule applies to; every set field must match.
+type LifecycleCondition struct {
+	Tags           []string `json:"tags,omitempty"`             // The file has any of these tags
+	MimePrefix     string   `json:"mime_prefix,omitempty"`      // e.g. "video/"
+	OwnerID        string   `json:"owner_id,omitempty"`         // Owned by this user
+	MinAgeDays     int      `json:"min_age_days,omitempty"`     // Created at least this many days ago
+	IdleDays       int      `json:"idle_days,omitempty"`        // Not accessed for this many days
+	MinSize        int64    `json:"min_size,omitempty"`         // Bytes
+	MaxSize        int64    `json:"max_size,omitempty"`         // Bytes, 0 is unbounded
+	MaxAccessCount *int64   `json:"max_access_count,omitempty"` // Accessed at most this often
+}
+
+// LifecycleAction is one thing a rule does to a matching file.
+type LifecycleAction struct {
+	Type         string `json:"type"`
+	StorageClass string `json:"storage_class,omitempty"` // For tier
+	Replicas     int    `json:"replicas,omitempty"`      // For replicate
+	Message      string `json:"message,omitempty"`       // For notify
+}
+
+// LifecycleRule is an admin-defined policy evaluated against the catalog. A
+// rule runs only once enabled, and it can only be enabled after its current
+// revision has been previewed.
+type LifecycleRule struct {
+	ID                string             `json:"id"`
+	Name              string             `json:"name"`
+	Enabled           bool               `json:"enabled"`
+	Condition         LifecycleCondition `json:"condition"`
+	Actions           []LifecycleAction  `json:"actions"`
+	Revision          int                `json:"revision"`           // Bumped whenever the condition or actions change
+	PreviewedRevision int                `json:"previewed_revision"` // Last revision a dry run was shown for
+	CreatedBy         string             `json:"created_by,omitempty"`
+	CreatedAt         int64              `json:"created_at"` // Unix timestamp
+	UpdatedAt         int64              `json:"updated_at"` // Unix timestamp
+	LastRunAt         int64              `json:"last_run_at,omitempty"`
+}
+
+// LifecycleAuditEntry records a change to a rule or an action a rule applied.
+type LifecycleAuditEntry struct {
+	ID        string    `json:"id"`
+	RuleID    string    `json:"rule_id"`
+	RuleName  string    `json:"rule_name,omitempty"`
+	Action    string    `json:"action"` // A lifecycle action, or "rule.saved", "rule.enabled", "rule.disabled", "rule.deleted", "rule.previewed"
+	FileID    string    `json:"file_id,omitempty"`
+	FileName  string    `json:"file_name,omitempty"`
+	Actor     string    `json:"actor,omitempty"` // User for rule changes, "scheduler" for periodic runs
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func lifecycleRuleKey(id string) []byte {
+	return []byte("lifecyclerule:" + id)
+}
+
+func lifecycleAuditKey(entry LifecycleAuditEntry) []byte {
+	return []byte(fmt.Sprintf("lifecycleaudit:%020d:%s", entry.Timestamp.UnixNano(), entry.ID))
+}
+
+// PutLifecycleRule stores a lifecycle rule.
+func (ms *MetadataStore) PutLifecycleRule(rule LifecycleRule) error {
+	val, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(lifecycleRuleKey(rule.ID), val)
+	})
+}
+
+// GetLifecycleRule retrieves a lifecycle rule by ID.
+func (ms *MetadataStore) GetLifecycleRule(id string) (LifecycleRule, error) {
+	var rule LifecycleRule
+	err := ms.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(lifecycleRuleKey(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &rule)
+		})
+	})
+	return rule, err
+}
+
+// ListLifecycleRules returns every lifecycle rule, oldest first.
+func (ms *MetadataStore) ListLifecycleRules() ([]LifecycleRule, error) {
+	rules := make([]LifecycleRule, 0)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("lifecyclerule:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var rule LifecycleRule
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &rule)
+			}); err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		return nil
+	})
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].CreatedAt != rules[j].CreatedAt {
+			return rules[i].CreatedAt < rules[j].CreatedAt
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, err
+}
+
+// DeleteLifecycleRule removes a lifecycle rule; its audit entries are kept.
+func (ms *MetadataStore) DeleteLifecycleRule(id string) error {
+	return ms.deleteKey(lifecycleRuleKey(id))
+}
+
+// AppendLifecycleAudit stores an audit entry.
+func (ms *MetadataStore) AppendLifecycleAudit(entry LifecycleAuditEntry) error {
+	val, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(lifecycleAuditKey(entry), val)
+	})
+}
+
+// ListLifecycleAudit returns audit entries, newest first; ruleID narrows them
+// to one rule and limit caps how many are returned, 0 for all.
+func (ms *MetadataStore) ListLifecycleAudit(ruleID string, limit int) ([]LifecycleAuditEntry, error) {
+	entries := make([]LifecycleAuditEntry, 0)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// A reverse iterator starts from just past the prefix
+		prefix := []byte("lifecycleaudit:")
+		for it.Seek([]byte("lifecycleaudit;")); it.ValidForPrefix(prefix); it.Next() {
+			var entry LifecycleAuditEntry
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &entry)
+			}); err != nil {
+				return err
+			}
+			if ruleID != "" && entry.RuleID != ruleID {
+				continue
+			}
+			entries = append(entries, entry)
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
@@ -0,0 +1,261 @@
+package metadata
+
+import (
+	"container/heap"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// indexLookup is one of the index-backed filters of a search: the file
+// matches when it carries any of keys
+type indexLookup struct {
+	index string
+	keys  []string
+	has   func(fileMeta *EnhancedFileMetadata, key string) bool
+}
+
+func searchLookups(query *SearchQuery) []indexLookup {
+	candidates := []indexLookup{
+		{"tag", normalizeKeys(query.Tags), func(f *EnhancedFileMetadata, key string) bool { return containsKey(f.Tags, key) }},
+		{"category", normalizeKeys(query.Categories), func(f *EnhancedFileMetadata, key string) bool { return containsKey(f.Categories, key) }},
+		{"owner", query.OwnerIDs, func(f *EnhancedFileMetadata, key string) bool { return f.OwnerID == key }},
+	}
+	var lookups []indexLookup
+	for _, l := range candidates {
+		if len(l.keys) > 0 {
+			lookups = append(lookups, l)
+		}
+	}
+	return lookups
+}
+
+// firstMatch returns the position of the first lookup key the file carries;
+// ok is false when it carries none, as with a stale index entry
+func firstMatch(fileMeta *EnhancedFileMetadata, lookups []indexLookup) (lookup, key int, ok bool) {
+	for i, l := range lookups {
+		for j, k := range l.keys {
+			if l.has(fileMeta, k) {
+				return i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// containsKey reports whether any of tags normalizes to key
+func containsKey(tags []string, key string) bool {
+	for _, tag := range normalizeKeys(tags) {
+		if tag == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchFiles streams the catalog past the query and keeps only the page being
+// asked for. Files reached through the tag, category or owner indices are read
+// one at a time; without such filters every file record is decoded straight
+// from the database. Only the best Offset+Limit matches are held, in a heap
+// ordered by the query's sort, and facets are counted as matches go by, so
+// memory does not grow with the size of the catalog. Reads don't record an
+// access, so a search writes nothing.
+func (ems *EnhancedMetadataStore) SearchFiles(query *SearchQuery) (*SearchResult, error) {
+	start := time.Now()
+
+	lookups := searchLookups(query)
+	page := newTopK(query.Offset+query.Limit, query.SortBy, query.SortOrder)
+	facets := make(map[string]map[string]int64)
+	for _, name := range []string{"tags", "categories", "owners", "health_status", "storage_class"} {
+		facets[name] = make(map[string]int64)
+	}
+	var total int64
+
+	visit := func(fileMeta *EnhancedFileMetadata) {
+		if len(lookups) > 0 {
+			if _, _, ok := firstMatch(fileMeta, lookups); !ok {
+				return
+			}
+		}
+		if !ems.matchesQuery(fileMeta, query) {
+			return
+		}
+		total++
+		countFacets(facets, fileMeta)
+		page.offer(fileMeta)
+	}
+	if err := ems.streamCandidates(lookups, visit); err != nil {
+		return nil, err
+	}
+
+	files := page.sorted()
+	if query.Offset < len(files) {
+		files = files[query.Offset:]
+	} else {
+		files = make([]*EnhancedFileMetadata, 0)
+	}
+	return &SearchResult{
+		Files:          files,
+		TotalCount:     total,
+		SearchDuration: time.Since(start),
+		Facets:         facets,
+	}, nil
+}
+
+// streamCandidates calls visit for every file that may pass the lookups. When
+// each lookup has an index, files are found through it, and a file listed
+// under several requested keys is visited only from the first key it carries.
+// Otherwise, or while the indices are being rebuilt, the whole catalog is scanned.
+func (ems *EnhancedMetadataStore) streamCandidates(lookups []indexLookup, visit func(*EnhancedFileMetadata)) error {
+	ems.indicesMu.RLock()
+	indexed := len(lookups) > 0 && ems.indicesReady()
+	for _, l := range lookups {
+		if ems.indices[l.index] == nil {
+			indexed = false
+		}
+	}
+	ems.indicesMu.RUnlock()
+	if !indexed {
+		return ems.scanFiles(visit)
+	}
+
+	for i, l := range lookups {
+		for j, key := range l.keys {
+			// Copy the posting list so the lock isn't held while files are read
+			ems.indicesMu.RLock()
+			fileIDs := append([]string(nil), ems.indices[l.index].IndexData[key]...)
+			ems.indicesMu.RUnlock()
+
+			for _, fileID := range fileIDs {
+				fileMeta, err := ems.loadFileMetadata(fileID)
+				if err != nil {
+					continue
+				}
+				if fi, fj, ok := firstMatch(fileMeta, lookups); ok && (fi != i || fj != j) {
+					continue
+				}
+				visit(fileMeta)
+			}
+		}
+	}
+	return nil
+}
+
+// scanFiles decodes every file record in turn and hands it to visit
+func (ems *EnhancedMetadataStore) scanFiles(visit func(*EnhancedFileMetadata)) error {
+	return ems.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("file:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var fileMeta EnhancedFileMetadata
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &fileMeta)
+			}); err != nil {
+				continue
+			}
+			visit(&fileMeta)
+		}
+		return nil
+	})
+}
+
+// countFacets adds one matching file to the facet counts
+func countFacets(facets map[string]map[string]int64, file *EnhancedFileMetadata) {
+	for _, tag := range file.Tags {
+		facets["tags"][tag]++
+	}
+	for _, category := range file.Categories {
+		facets["categories"][category]++
+	}
+	facets["owners"][file.OwnerID]++
+	facets["health_status"][file.HealthStatus]++
+	facets["storage_class"][file.StorageClass]++
+}
+
+// fileLess orders files by sortBy ascending, falling back to modified_at for
+// unknown fields; ties are broken by file ID so pages are stable
+func fileLess(a, b *EnhancedFileMetadata, sortBy string) bool {
+	switch sortBy {
+	case "file_name":
+		if a.FileName != b.FileName {
+			return a.FileName < b.FileName
+		}
+	case "file_size":
+		if a.FileSize != b.FileSize {
+			return a.FileSize < b.FileSize
+		}
+	case "created_at":
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	case "accessed_at":
+		if !a.AccessedAt.Equal(b.AccessedAt) {
+			return a.AccessedAt.Before(b.AccessedAt)
+		}
+	case "access_count":
+		if a.AccessCount != b.AccessCount {
+			return a.AccessCount < b.AccessCount
+		}
+	default:
+		if !a.ModifiedAt.Equal(b.ModifiedAt) {
+			return a.ModifiedAt.Before(b.ModifiedAt)
+		}
+	}
+	return a.FileID < b.FileID
+}
+
+// topK keeps the k files that come first under a sort order. The heap's root
+// is the file that would come last, so it is the one evicted.
+type topK struct {
+	k     int
+	files []*EnhancedFileMetadata
+	// before reports whether a comes ahead of b in the requested order
+	before func(a, b *EnhancedFileMetadata) bool
+}
+
+func newTopK(k int, sortBy, sortOrder string) *topK {
+	if sortBy == "" {
+		sortBy = "modified_at"
+	}
+	before := func(a, b *EnhancedFileMetadata) bool { return fileLess(b, a, sortBy) }
+	if sortOrder == "asc" {
+		before = func(a, b *EnhancedFileMetadata) bool { return fileLess(a, b, sortBy) }
+	}
+	return &topK{k: k, before: before}
+}
+
+func (t *topK) Len() int           { return len(t.files) }
+func (t *topK) Less(i, j int) bool { return t.before(t.files[j], t.files[i]) }
+func (t *topK) Swap(i, j int)      { t.files[i], t.files[j] = t.files[j], t.files[i] }
+func (t *topK) Push(x any)         { t.files = append(t.files, x.(*EnhancedFileMetadata)) }
+func (t *topK) Pop() any {
+	last := t.files[len(t.files)-1]
+	t.files = t.files[:len(t.files)-1]
+	return last
+}
+
+// offer adds file if it belongs among the best k seen so far
+func (t *topK) offer(file *EnhancedFileMetadata) {
+	if t.k <= 0 {
+		return
+	}
+	if len(t.files) < t.k {
+		heap.Push(t, file)
+		return
+	}
+	if t.before(file, t.files[0]) {
+		t.files[0] = file
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the kept files in the requested order
+func (t *topK) sorted() []*EnhancedFileMetadata {
+	files := append([]*EnhancedFileMetadata(nil), t.files...)
+	sort.Slice(files, func(i, j int) bool { return t.before(files[i], files[j]) })
+	return files
+}
@@ -0,0 +1,181 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// seedCatalog writes n synthetic files straight to the database and indexes
+// them, skipping the asynchronous index updater
+func seedCatalog(tb testing.TB, store *EnhancedMetadataStore, n int) {
+	tb.Helper()
+	base := time.Now().Add(-time.Duration(n) * time.Minute)
+	batch := store.db.NewWriteBatch()
+	for i := 0; i < n; i++ {
+		meta := EnhancedFileMetadata{
+			FileID:       fmt.Sprintf("file-%06d", i),
+			FileName:     fmt.Sprintf("report-%d.txt", i%97),
+			FileSize:     int64(i%1000) * 1024,
+			OwnerID:      fmt.Sprintf("user-%d", i%10),
+			Tags:         []string{fmt.Sprintf("tag-%d", i%5), fmt.Sprintf("tag-%d", i%7)},
+			Categories:   []string{"synthetic"},
+			HealthStatus: "healthy",
+			StorageClass: "hot",
+			CreatedAt:    base.Add(time.Duration(i) * time.Minute),
+			ModifiedAt:   base.Add(time.Duration((i*7919)%n) * time.Minute),
+		}
+		value, err := json.Marshal(meta)
+		if err != nil {
+			tb.Fatalf("failed to marshal %s: %v", meta.FileID, err)
+		}
+		if err := batch.Set([]byte("file:"+meta.FileID), value); err != nil {
+			tb.Fatalf("failed to write %s: %v", meta.FileID, err)
+		}
+	}
+	if err := batch.Flush(); err != nil {
+		tb.Fatalf("failed to flush catalog: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		store.updateIndicesForFile(fmt.Sprintf("file-%06d", i))
+	}
+}
+
+func TestSearchFilesPagesMatchAFullSort(t *testing.T) {
+	store := newTagStore(t)
+	seedCatalog(t, store, 500)
+	all, err := store.ListFileMetadata()
+	if err != nil {
+		t.Fatalf("ListFileMetadata failed: %v", err)
+	}
+
+	// Files tagged tag-1 or tag-3; some carry both and must appear once
+	var want []*EnhancedFileMetadata
+	for _, f := range all {
+		if containsKey(f.Tags, "tag-1") || containsKey(f.Tags, "tag-3") {
+			want = append(want, f)
+		}
+	}
+	sort.Slice(want, func(i, j int) bool { return fileLess(want[i], want[j], "file_size") })
+
+	before := store.db.MaxVersion()
+	result, err := store.SearchFiles(&SearchQuery{Tags: []string{"TAG-1", "tag-3"}, SortBy: "file_size", SortOrder: "asc", Offset: 20, Limit: 15})
+	if err != nil {
+		t.Fatalf("SearchFiles failed: %v", err)
+	}
+	if result.TotalCount != int64(len(want)) {
+		t.Fatalf("expected %d matches, got %d", len(want), result.TotalCount)
+	}
+	if len(result.Files) != 15 {
+		t.Fatalf("expected a page of 15, got %d", len(result.Files))
+	}
+	for i, f := range result.Files {
+		if f.FileID != want[20+i].FileID {
+			t.Fatalf("position %d: expected %s, got %s", 20+i, want[20+i].FileID, f.FileID)
+		}
+	}
+	if got := result.Facets["categories"]["synthetic"]; got != int64(len(want)) {
+		t.Errorf("expected the category facet to count every match, got %d", got)
+	}
+
+	// A scan without index filters, newest first by default
+	scan, err := store.SearchFiles(&SearchQuery{Query: "report-5", Limit: 3})
+	if err != nil {
+		t.Fatalf("SearchFiles failed: %v", err)
+	}
+	if scan.TotalCount == 0 || len(scan.Files) != 3 || scan.Files[0].ModifiedAt.Before(scan.Files[2].ModifiedAt) {
+		t.Fatalf("unexpected scan result: %d matches, page %d", scan.TotalCount, len(scan.Files))
+	}
+
+	if none, _ := store.SearchFiles(&SearchQuery{Tags: []string{"no-such-tag"}, Limit: 10}); none.TotalCount != 0 {
+		t.Errorf("an unknown tag should match nothing, got %d", none.TotalCount)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if after := store.db.MaxVersion(); after != before {
+		t.Errorf("searching wrote to the database: version %d -> %d", before, after)
+	}
+}
+
+// BenchmarkSearchFiles searches catalogs of growing size for one page. The
+// retained-B metric is the live heap held while the result is in use: a page
+// and the facet counts, kilobytes where the catalog itself is megabytes. The
+// benchmark fails if a search writes.
+func BenchmarkSearchFiles(b *testing.B) {
+	for _, n := range []int{2000, 20000} {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			store := newTagStore(b)
+			seedCatalog(b, store, n)
+			queries := []*SearchQuery{
+				{Query: "report", SortBy: "file_size", Limit: 50},
+				{Tags: []string{"tag-2"}, Limit: 50},
+			}
+
+			var stats runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&stats)
+			baseline := stats.HeapAlloc
+			version := store.db.MaxVersion()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				result, err := store.SearchFiles(queries[i%len(queries)])
+				if err != nil {
+					b.Fatalf("SearchFiles failed: %v", err)
+				}
+				if i == 0 {
+					runtime.GC()
+					runtime.ReadMemStats(&stats)
+					if stats.HeapAlloc > baseline {
+						retained = stats.HeapAlloc - baseline
+					}
+				}
+				runtime.KeepAlive(result)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(retained), "retained-B")
+
+			time.Sleep(50 * time.Millisecond)
+			if after := store.db.MaxVersion(); after != version {
+				b.Fatalf("search wrote to the database: version %d -> %d", version, after)
+			}
+		})
+	}
+}
+
+func TestListAllFilesSkipsDeletedAndExpiredFiles(t *testing.T) {
+	store := newTagStore(t)
+	if files, err := store.ListAllFiles(); err != nil || len(files) != 0 {
+		t.Fatalf("expected an empty store to list no files, got %d (%v)", len(files), err)
+	}
+
+	seedCatalog(t, store, 20)
+	if err := store.MarkFileDeleted("file-000003", time.Now()); err != nil {
+		t.Fatalf("MarkFileDeleted failed: %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	if err := store.StoreFileMetadata(&EnhancedFileMetadata{FileID: "ephemeral", FileName: "gone.txt", ExpiresAt: &expired}); err != nil {
+		t.Fatalf("StoreFileMetadata failed: %v", err)
+	}
+
+	files, err := store.ListAllFiles()
+	if err != nil {
+		t.Fatalf("ListAllFiles failed: %v", err)
+	}
+	if len(files) != 19 {
+		t.Fatalf("expected the 19 live files, got %d", len(files))
+	}
+	for i, f := range files {
+		if f.FileID == "file-000003" || f.FileID == "ephemeral" {
+			t.Errorf("listed %s, which is no longer live", f.FileID)
+		}
+		if i > 0 && f.ModifiedAt.After(files[i-1].ModifiedAt) {
+			t.Errorf("file %d modified after the one before it; expected newest first", i)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"github.com/dgraph-io/badger/v4"
+)
+
+var storageModeKey = []byte("setting:storage_mode")
+
+// StorageMode returns the storage mode recorded for the chunks this store
+// describes, "" before one is recorded.
+func (ms *MetadataStore) StorageMode() (string, error) {
+	var mode string
+	err := ms.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(storageModeKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			mode = string(val)
+			return nil
+		})
+	})
+	return mode, err
+}
+
+// PutStorageMode records the storage mode chunks are kept in.
+func (ms *MetadataStore) PutStorageMode(mode string) error {
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(storageModeKey, []byte(mode))
+	})
+}
+
+// ChunkReferenced reports whether a chunk record or a file version still
+// references the stored chunk at path.
+func (ms *MetadataStore) ChunkReferenced(path string) (bool, error) {
+	chunks, err := ms.ListChunkMetadata()
+	if err != nil {
+		return false, err
+	}
+	for _, chunk := range chunks {
+		if chunk.Path == path {
+			return true, nil
+		}
+	}
+	versions, err := ms.ListAllVersions()
+	if err != nil {
+		return false, err
+	}
+	for _, version := range versions {
+		for _, chunk := range version.Chunks {
+			if chunk.Path == path {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,293 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+var (
+	// ErrInvalidTag is returned for tags and categories that are too long or use disallowed characters
+	ErrInvalidTag = errors.New("invalid tag")
+	// ErrTooManyTags is returned when a file carries more tags or categories than the policy allows
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+// TagPolicy bounds the tags and categories users attach to files.
+type TagPolicy struct {
+	MaxTags    int      // Per file, applied to tags and categories separately; 0 is unlimited
+	MaxLength  int      // Characters per tag after normalization; 0 is unlimited
+	Vocabulary []string // Canonical tags offered first as suggestions
+}
+
+// DefaultTagPolicy returns the limits used when nothing is configured
+func DefaultTagPolicy() TagPolicy {
+	return TagPolicy{MaxTags: 32, MaxLength: 64}
+}
+
+// TagPolicyFromConfig reads the tag limits and vocabulary from the app config
+func TagPolicyFromConfig(cfg *config.AppConfig) TagPolicy {
+	policy := DefaultTagPolicy()
+	if cfg == nil {
+		return policy
+	}
+	if cfg.MaxTagsPerFile >= 0 {
+		policy.MaxTags = cfg.MaxTagsPerFile
+	}
+	if cfg.MaxTagLength >= 0 {
+		policy.MaxLength = cfg.MaxTagLength
+	}
+	policy.Vocabulary = cfg.TagVocabulary
+	return policy
+}
+
+// NormalizeTag trims and lowercases a tag and collapses inner whitespace to
+// single spaces, so "  Quarterly   Reports" and "quarterly reports" are the
+// same tag. Letters, digits, spaces and - _ . / are allowed. An empty result
+// means the tag was blank.
+func NormalizeTag(tag string, maxLength int) (string, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if maxLength > 0 && utf8.RuneCountInString(normalized) > maxLength {
+		return "", fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTag, normalized, maxLength)
+	}
+	for _, r := range normalized {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -_./", r) {
+			return "", fmt.Errorf("%w: %q contains %q", ErrInvalidTag, normalized, r)
+		}
+	}
+	return normalized, nil
+}
+
+// Normalize normalizes every tag, dropping blanks and duplicates while keeping
+// the original order, and enforces the per-file limit.
+func (p TagPolicy) Normalize(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		n, err := NormalizeTag(tag, p.MaxLength)
+		if err != nil {
+			return nil, err
+		}
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+	if p.MaxTags > 0 && len(normalized) > p.MaxTags {
+		return nil, fmt.Errorf("%w: %d given, at most %d allowed", ErrTooManyTags, len(normalized), p.MaxTags)
+	}
+	return normalized, nil
+}
+
+// normalizeKeys maps tags onto their normalized form for indexing and
+// searching. Tags stored before normalization existed may not pass
+// validation; those keep their lowercased form so they can still be found.
+func normalizeKeys(tags []string) []string {
+	keys := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		key, err := NormalizeTag(tag, 0)
+		if err != nil {
+			key = strings.ToLower(strings.TrimSpace(tag))
+		}
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// SetTagPolicy replaces the limits applied when file metadata is stored. The
+// vocabulary is normalized; entries that fail validation are dropped.
+func (ems *EnhancedMetadataStore) SetTagPolicy(policy TagPolicy) {
+	vocabulary := make([]string, 0, len(policy.Vocabulary))
+	for _, tag := range normalizeKeys(policy.Vocabulary) {
+		if _, err := NormalizeTag(tag, policy.MaxLength); err == nil {
+			vocabulary = append(vocabulary, tag)
+		}
+	}
+	policy.Vocabulary = vocabulary
+
+	ems.policyMu.Lock()
+	defer ems.policyMu.Unlock()
+	ems.tagPolicy = policy
+}
+
+// TagPolicy returns the limits applied when file metadata is stored
+func (ems *EnhancedMetadataStore) TagPolicy() TagPolicy {
+	ems.policyMu.RLock()
+	defer ems.policyMu.RUnlock()
+	return ems.tagPolicy
+}
+
+// normalizeFileTags applies the tag policy to a file's tags and categories
+func (ems *EnhancedMetadataStore) normalizeFileTags(meta *EnhancedFileMetadata) error {
+	policy := ems.TagPolicy()
+	tags, err := policy.Normalize(meta.Tags)
+	if err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+	categories, err := policy.Normalize(meta.Categories)
+	if err != nil {
+		return fmt.Errorf("invalid categories: %w", err)
+	}
+	meta.Tags, meta.Categories = tags, categories
+	return nil
+}
+
+// UpdateFileTags replaces a file's tags and categories; nil leaves that list
+// unchanged. The new values go through the same normalization as uploads and
+// the indices are rebuilt for the file, dropping tags it no longer carries.
+func (ems *EnhancedMetadataStore) UpdateFileTags(fileID string, tags, categories []string) (*EnhancedFileMetadata, error) {
+	meta, err := ems.loadFileMetadata(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if tags != nil {
+		meta.Tags = tags
+	}
+	if categories != nil {
+		meta.Categories = categories
+	}
+	if err := ems.StoreFileMetadata(meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// SuggestTags returns known tags close to input: vocabulary entries and tags
+// already in use that start with it or are within a small edit distance of it,
+// closest first, vocabulary before other tags on ties.
+func (ems *EnhancedMetadataStore) SuggestTags(input string, limit int) []string {
+	query := strings.ToLower(strings.Join(strings.Fields(input), " "))
+	if query == "" {
+		return []string{}
+	}
+
+	vocabulary := make(map[string]bool)
+	for _, tag := range ems.TagPolicy().Vocabulary {
+		vocabulary[tag] = true
+	}
+	candidates := make(map[string]bool, len(vocabulary))
+	for tag := range vocabulary {
+		candidates[tag] = true
+	}
+	ems.indicesMu.RLock()
+	for _, name := range []string{"tag", "category"} {
+		if index := ems.indices[name]; index != nil {
+			for tag := range index.IndexData {
+				candidates[tag] = true
+			}
+		}
+	}
+	ems.indicesMu.RUnlock()
+
+	type suggestion struct {
+		tag      string
+		distance int
+	}
+	maxDistance := utf8.RuneCountInString(query) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	var matches []suggestion
+	for tag := range candidates {
+		distance := 0
+		if !strings.HasPrefix(tag, query) {
+			distance = editDistance(query, tag)
+			if distance > maxDistance {
+				continue
+			}
+		}
+		matches = append(matches, suggestion{tag: tag, distance: distance})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		if vocabulary[matches[i].tag] != vocabulary[matches[j].tag] {
+			return vocabulary[matches[i].tag]
+		}
+		return matches[i].tag < matches[j].tag
+	})
+
+	suggestions := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if limit > 0 && len(suggestions) >= limit {
+			break
+		}
+		suggestions = append(suggestions, match.tag)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// replaceIndexEntries points the file at exactly keys in the named index,
+// removing it from keys it no longer carries
+func (ems *EnhancedMetadataStore) replaceIndexEntries(name, fileID string, keys []string) {
+	index := ems.indices[name]
+	if index == nil {
+		index = &MetadataIndex{IndexType: name, IndexData: make(map[string][]string)}
+		ems.indices[name] = index
+	}
+
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[key] = true
+	}
+	for key, fileIDs := range index.IndexData {
+		if wanted[key] {
+			continue
+		}
+		for i, id := range fileIDs {
+			if id == fileID {
+				fileIDs = append(fileIDs[:i], fileIDs[i+1:]...)
+				break
+			}
+		}
+		if len(fileIDs) == 0 {
+			delete(index.IndexData, key)
+		} else {
+			index.IndexData[key] = fileIDs
+		}
+	}
+	for _, key := range keys {
+		if !ems.contains(index.IndexData[key], fileID) {
+			index.IndexData[key] = append(index.IndexData[key], fileID)
+		}
+	}
+	index.LastUpdated = time.Now()
+}
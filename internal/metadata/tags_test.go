@@ -0,0 +1,128 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTagStore(t testing.TB) *EnhancedMetadataStore {
+	t.Helper()
+	store, err := NewEnhancedMetadataStore(filepath.Join(t.TempDir(), "enhanced"))
+	if err != nil {
+		t.Fatalf("failed to open enhanced metadata store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestNormalizeTagTreatsEquivalentInputsAlike(t *testing.T) {
+	for _, input := range []string{"Quarterly Reports", "  quarterly reports ", "QUARTERLY\t\tREPORTS", "quarterly \n reports"} {
+		got, err := NormalizeTag(input, 64)
+		if err != nil {
+			t.Fatalf("NormalizeTag(%q) failed: %v", input, err)
+		}
+		if got != "quarterly reports" {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", input, got, "quarterly reports")
+		}
+	}
+
+	for _, input := range []string{"<script>", "tag;drop", "emoji🙂"} {
+		if _, err := NormalizeTag(input, 64); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("expected %q to be rejected, got %v", input, err)
+		}
+	}
+	if _, err := NormalizeTag("abcdefghij", 5); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected an over-long tag to be rejected, got %v", err)
+	}
+}
+
+func TestStoreFileMetadataNormalizesAndLimitsTags(t *testing.T) {
+	store := newTagStore(t)
+	store.SetTagPolicy(TagPolicy{MaxTags: 3, MaxLength: 20})
+
+	meta := &EnhancedFileMetadata{FileID: "f1", FileName: "a.txt",
+		Tags:       []string{" Finance", "finance ", "Q3  Results", ""},
+		Categories: []string{"Reports"}}
+	if err := store.StoreFileMetadata(meta); err != nil {
+		t.Fatalf("StoreFileMetadata failed: %v", err)
+	}
+	stored, err := store.loadFileMetadata("f1")
+	if err != nil {
+		t.Fatalf("failed to load f1: %v", err)
+	}
+	if !reflect.DeepEqual(stored.Tags, []string{"finance", "q3 results"}) || !reflect.DeepEqual(stored.Categories, []string{"reports"}) {
+		t.Fatalf("unexpected stored tags %v and categories %v", stored.Tags, stored.Categories)
+	}
+
+	tooMany := &EnhancedFileMetadata{FileID: "f2", Tags: []string{"a", "b", "c", "d"}}
+	if err := store.StoreFileMetadata(tooMany); !errors.Is(err, ErrTooManyTags) {
+		t.Fatalf("expected four tags to exceed the limit, got %v", err)
+	}
+	// Duplicates after normalization don't count against the limit
+	if err := store.StoreFileMetadata(&EnhancedFileMetadata{FileID: "f3", Tags: []string{"a", "A", " a ", "b", "c"}}); err != nil {
+		t.Fatalf("expected duplicate tags to collapse, got %v", err)
+	}
+	if err := store.StoreFileMetadata(&EnhancedFileMetadata{FileID: "f4", Categories: []string{"bad|category"}}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected an invalid category to be rejected, got %v", err)
+	}
+}
+
+func TestUpdateFileTagsReindexesFile(t *testing.T) {
+	store := newTagStore(t)
+	if err := store.StoreFileMetadata(&EnhancedFileMetadata{FileID: "f1", Tags: []string{"Draft", "finance"}}); err != nil {
+		t.Fatalf("StoreFileMetadata failed: %v", err)
+	}
+	store.updateIndicesForFile("f1")
+
+	if _, err := store.UpdateFileTags("f1", []string{"  FINAL ", "Finance"}, nil); err != nil {
+		t.Fatalf("UpdateFileTags failed: %v", err)
+	}
+	store.updateIndicesForFile("f1")
+
+	search := func(tag string) int64 {
+		result, err := store.SearchFiles(&SearchQuery{Tags: []string{tag}, Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchFiles failed: %v", err)
+		}
+		return result.TotalCount
+	}
+	if search("Final") != 1 || search("finance") != 1 {
+		t.Error("expected the file under its new tags, whatever their case")
+	}
+	store.indicesMu.RLock()
+	_, stale := store.indices["tag"].IndexData["draft"]
+	store.indicesMu.RUnlock()
+	if stale {
+		t.Error("the removed tag should be dropped from the index")
+	}
+
+	tooMany := make([]string, DefaultTagPolicy().MaxTags+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag-%d", i)
+	}
+	if _, err := store.UpdateFileTags("f1", tooMany, nil); !errors.Is(err, ErrTooManyTags) {
+		t.Fatalf("expected the default limit to apply to updates, got %v", err)
+	}
+}
+
+func TestSuggestTagsOffersCloseMatches(t *testing.T) {
+	store := newTagStore(t)
+	store.SetTagPolicy(TagPolicy{MaxTags: 10, MaxLength: 32, Vocabulary: []string{"Finance", "Marketing", "engineering"}})
+	if err := store.StoreFileMetadata(&EnhancedFileMetadata{FileID: "f1", Tags: []string{"financial"}}); err != nil {
+		t.Fatalf("StoreFileMetadata failed: %v", err)
+	}
+	store.updateIndicesForFile("f1")
+
+	if got := store.SuggestTags("finanse", 5); !reflect.DeepEqual(got, []string{"finance"}) {
+		t.Errorf("expected a typo to suggest the canonical tag, got %v", got)
+	}
+	if got := store.SuggestTags("Fin", 5); !reflect.DeepEqual(got, []string{"finance", "financial"}) {
+		t.Errorf("expected prefix matches, vocabulary first, got %v", got)
+	}
+	if got := store.SuggestTags("unrelated", 5); len(got) != 0 {
+		t.Errorf("expected no suggestions, got %v", got)
+	}
+}
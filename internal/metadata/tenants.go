@@ -0,0 +1,159 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DefaultTenant holds users without a tenant and files stored before
+// multi-tenancy was enabled
+const DefaultTenant = "default"
+
+// Tenant membership is kept apart from file records, which are rewritten on
+// every upload. Files are content-addressed, so tenants that upload the same
+// content share one file ID and each hold a membership key for it.
+func tenantFileKey(tenant, fileID string) []byte {
+	return []byte("tenantfile:" + tenant + ":" + fileID)
+}
+
+func fileTenantKey(fileID, tenant string) []byte {
+	return []byte("filetenant:" + fileID + ":" + tenant)
+}
+
+// AddFileToTenant records that a file belongs to a tenant's namespace.
+func (ms *MetadataStore) AddFileToTenant(tenant, fileID string) error {
+	if tenant == "" || fileID == "" {
+		return fmt.Errorf("tenant and file ID are required")
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(tenantFileKey(tenant, fileID), nil); err != nil {
+			return err
+		}
+		return txn.Set(fileTenantKey(fileID, tenant), nil)
+	})
+}
+
+// RemoveFileFromTenant drops a file from a tenant's namespace.
+func (ms *MetadataStore) RemoveFileFromTenant(tenant, fileID string) error {
+	return ms.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(tenantFileKey(tenant, fileID)); err != nil {
+			return err
+		}
+		return txn.Delete(fileTenantKey(fileID, tenant))
+	})
+}
+
+// FileTenants returns the tenants a file was recorded under, none for files
+// stored before multi-tenancy.
+func (ms *MetadataStore) FileTenants(fileID string) ([]string, error) {
+	var tenants []string
+	prefix := []byte("filetenant:" + fileID + ":")
+	err := ms.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			tenants = append(tenants, string(it.Item().Key()[len(prefix):]))
+		}
+		return nil
+	})
+	return tenants, err
+}
+
+// FileInTenant reports whether a file is in a tenant's namespace. Files
+// without any recorded tenant belong to DefaultTenant.
+func (ms *MetadataStore) FileInTenant(tenant, fileID string) (bool, error) {
+	tenants, err := ms.FileTenants(fileID)
+	if err != nil {
+		return false, err
+	}
+	if len(tenants) == 0 {
+		return tenant == DefaultTenant, nil
+	}
+	for _, t := range tenants {
+		if t == tenant {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TenantFileIDs returns the IDs of every file in a tenant's namespace,
+// including unassigned files when tenant is DefaultTenant.
+func (ms *MetadataStore) TenantFileIDs(tenant string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("tenantfile:" + tenant + ":")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			ids[string(it.Item().Key()[len(prefix):])] = true
+		}
+		if tenant != DefaultTenant {
+			return nil
+		}
+
+		// Files no tenant claims predate multi-tenancy
+		assigned := make(map[string]bool)
+		prefix = []byte("filetenant:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			rest := it.Item().Key()[len(prefix):]
+			if i := bytes.IndexByte(rest, ':'); i > 0 {
+				assigned[string(rest[:i])] = true
+			}
+		}
+		prefix = []byte("fileid:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if id := string(it.Item().Key()[len(prefix):]); !assigned[id] {
+				ids[id] = true
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// TenantUsage returns how many files a tenant holds and their total size.
+func (ms *MetadataStore) TenantUsage(tenant string) (files int, bytes int64, err error) {
+	ids, err := ms.TenantFileIDs(tenant)
+	if err != nil {
+		return 0, 0, err
+	}
+	for id := range ids {
+		if meta, err := ms.GetFileMetadataByID(id); err == nil && meta.DeletedAt == 0 {
+			files++
+			bytes += meta.FileSize
+		}
+	}
+	return files, bytes, nil
+}
+
+// ListTenants returns every tenant holding at least one file.
+func (ms *MetadataStore) ListTenants() ([]string, error) {
+	seen := make(map[string]bool)
+	var tenants []string
+	err := ms.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("tenantfile:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			rest := string(it.Item().Key()[len(prefix):])
+			if i := strings.IndexByte(rest, ':'); i > 0 && !seen[rest[:i]] {
+				seen[rest[:i]] = true
+				tenants = append(tenants, rest[:i])
+			}
+		}
+		return nil
+	})
+	return tenants, err
+}
@@ -0,0 +1,90 @@
+package metadata
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTenantNamespacesKeepFilesApart(t *testing.T) {
+	store, err := OpenMetadataStore(filepath.Join(t.TempDir(), "meta"))
+	if err != nil {
+		t.Fatalf("failed to open metadata store: %v", err)
+	}
+	defer store.Close()
+
+	for id, size := range map[string]int64{"legacy": 10, "alpha-1": 100, "alpha-2": 200, "beta-1": 1000} {
+		if err := store.PutFileMetadataByID(id, FileMetadata{FileName: id, FileSize: size}); err != nil {
+			t.Fatalf("failed to store %s: %v", id, err)
+		}
+	}
+	for tenant, ids := range map[string][]string{"alpha": {"alpha-1", "alpha-2"}, "beta": {"beta-1"}} {
+		for _, id := range ids {
+			if err := store.AddFileToTenant(tenant, id); err != nil {
+				t.Fatalf("failed to add %s to %s: %v", id, tenant, err)
+			}
+		}
+	}
+
+	alpha, err := store.TenantFileIDs("alpha")
+	if err != nil || len(alpha) != 2 || !alpha["alpha-1"] || !alpha["alpha-2"] {
+		t.Fatalf("expected alpha to hold only its two files, got %v, %v", alpha, err)
+	}
+	if in, _ := store.FileInTenant("alpha", "beta-1"); in {
+		t.Errorf("alpha must not see beta's file")
+	}
+	if in, _ := store.FileInTenant("beta", "legacy"); in {
+		t.Errorf("beta must not see a file stored before tenancy")
+	}
+
+	// Files stored before tenancy belong to the default tenant only
+	defaults, err := store.TenantFileIDs(DefaultTenant)
+	if err != nil || len(defaults) != 1 || !defaults["legacy"] {
+		t.Fatalf("expected the default tenant to hold only the legacy file, got %v, %v", defaults, err)
+	}
+	if in, _ := store.FileInTenant(DefaultTenant, "legacy"); !in {
+		t.Errorf("expected the legacy file in the default tenant")
+	}
+
+	// The same content uploaded by another tenant is shared, not moved
+	if err := store.AddFileToTenant("beta", "alpha-1"); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	if in, _ := store.FileInTenant("alpha", "alpha-1"); !in {
+		t.Errorf("sharing content must keep it in the first tenant")
+	}
+	if files, bytes, err := store.TenantUsage("beta"); err != nil || files != 2 || bytes != 1100 {
+		t.Errorf("expected beta to use 2 files and 1100 bytes, got %d, %d, %v", files, bytes, err)
+	}
+
+	if err := store.RemoveFileFromTenant("beta", "alpha-1"); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if in, _ := store.FileInTenant("beta", "alpha-1"); in {
+		t.Errorf("expected the file gone from beta")
+	}
+	if tenants, err := store.ListTenants(); err != nil || len(tenants) != 2 {
+		t.Errorf("expected alpha and beta listed, got %v, %v", tenants, err)
+	}
+}
+
+func TestSearchIsLimitedToTheGivenFiles(t *testing.T) {
+	store := newTagStore(t)
+	seedCatalog(t, store, 50)
+
+	result, err := store.SearchFiles(&SearchQuery{
+		Tags:    []string{"tag-0"},
+		FileIDs: map[string]bool{"file-000000": true, "file-000001": true, "file-000005": true},
+		Limit:   100,
+	})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if result.TotalCount != 2 {
+		t.Fatalf("expected the two tag-0 files of the set, got %d", result.TotalCount)
+	}
+	for _, file := range result.Files {
+		if file.FileID != "file-000000" && file.FileID != "file-000005" {
+			t.Errorf("search returned %s from outside the set", file.FileID)
+		}
+	}
+}
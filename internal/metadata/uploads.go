@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// An upload manifest records the chunks of a file stored so far, keyed by the
+// file's content hash with one key per chunk, so concurrent chunk workers
+// never rewrite each other's progress. It lives until the file is committed.
+func uploadPrefix(contentID string) []byte {
+	return []byte("upload:" + contentID + ":")
+}
+
+func uploadChunkKey(contentID string, index int) []byte {
+	return []byte(fmt.Sprintf("upload:%s:%d", contentID, index))
+}
+
+// PutUploadChunk records that a chunk of an upload in progress is stored.
+func (ms *MetadataStore) PutUploadChunk(contentID string, chunk ChunkMetadata) error {
+	val, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(uploadChunkKey(contentID, chunk.Index), val)
+	})
+}
+
+// GetUploadManifest returns the chunks recorded for an unfinished upload, in
+// index order, and none when no upload of the content was interrupted.
+func (ms *MetadataStore) GetUploadManifest(contentID string) ([]ChunkMetadata, error) {
+	chunks := make([]ChunkMetadata, 0)
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := uploadPrefix(contentID)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var chunk ChunkMetadata
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &chunk)
+			}); err != nil {
+				return err
+			}
+			chunks = append(chunks, chunk)
+		}
+		return nil
+	})
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Index < chunks[j].Index
+	})
+	return chunks, err
+}
+
+// DeleteUploadManifest drops the progress of an upload once it is committed.
+func (ms *MetadataStore) DeleteUploadManifest(contentID string) error {
+	return ms.db.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := uploadPrefix(contentID)
+		var keys [][]byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// VersionRecord is an immutable snapshot of one version of a file. It keeps
+// its own copy of the chunk list, so older versions stay readable after the
+// file's current chunks change.
+type VersionRecord struct {
+	FileID    string          `json:"file_id"`
+	Version   int             `json:"version"`
+	ContentID string          `json:"content_id"` // Content hash the version's chunks are bound to
+	File      FileMetadata    `json:"file"`
+	Chunks    []ChunkMetadata `json:"chunks"`
+	ChangeLog string          `json:"change_log,omitempty"`
+	CreatedAt int64           `json:"created_at"` // Unix timestamp
+}
+
+// versionKey sorts versions numerically under the file's prefix
+func versionKey(fileID string, version int) []byte {
+	return []byte(fmt.Sprintf("fileversion:%s:%08d", fileID, version))
+}
+
+// PutVersion stores a version record.
+func (ms *MetadataStore) PutVersion(record VersionRecord) error {
+	val, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return ms.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(versionKey(record.FileID, record.Version), val)
+	})
+}
+
+// GetVersion retrieves one version of a file.
+func (ms *MetadataStore) GetVersion(fileID string, version int) (VersionRecord, error) {
+	var record VersionRecord
+	err := ms.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(versionKey(fileID, version))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &record)
+		})
+	})
+	return record, err
+}
+
+// ListVersions returns every recorded version of a file, oldest first.
+func (ms *MetadataStore) ListVersions(fileID string) ([]VersionRecord, error) {
+	return ms.listVersions("fileversion:" + fileID + ":")
+}
+
+// ListAllVersions returns the recorded versions of every file.
+func (ms *MetadataStore) ListAllVersions() ([]VersionRecord, error) {
+	return ms.listVersions("fileversion:")
+}
+
+func (ms *MetadataStore) listVersions(keyPrefix string) ([]VersionRecord, error) {
+	var records []VersionRecord
+	err := ms.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(keyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				var record VersionRecord
+				if err := json.Unmarshal(val, &record); err != nil {
+					return err
+				}
+				records = append(records, record)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].FileID != records[j].FileID {
+			return records[i].FileID < records[j].FileID
+		}
+		return records[i].Version < records[j].Version
+	})
+	return records, err
+}
@@ -0,0 +1,133 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Bind settings accepted by ListenAddress besides a specific IP or host name
+const (
+	BindAll  = "all"  // Every interface, IPv4 and IPv6 (dual-stack)
+	BindIPv4 = "ipv4" // Every IPv4 interface
+	BindIPv6 = "ipv6" // Every IPv6 interface only
+)
+
+// NormalizeAddress strips the brackets of an IPv6 literal such as "[::1]"
+// and checks the rest is an IP address or a host name. Addresses are stored
+// without brackets; HostPort adds them back when joining with a port.
+func NormalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
+		address = address[1 : len(address)-1]
+	}
+	if address == "" {
+		return "", fmt.Errorf("address is empty")
+	}
+
+	host, zone := address, ""
+	if i := strings.LastIndex(address, "%"); i >= 0 {
+		host, zone = address[:i], address[i+1:]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if zone != "" && ip.To4() != nil {
+			return "", fmt.Errorf("invalid address %q: only IPv6 addresses take a zone", address)
+		}
+		return address, nil
+	}
+	if zone != "" || strings.ContainsAny(address, ":[]/ ") {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+	return address, nil
+}
+
+// ValidateAdvertiseAddress checks an address other nodes will dial: it must
+// be well formed and name a single host rather than every interface
+func ValidateAdvertiseAddress(address string) (string, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(address); ip != nil && ip.IsUnspecified() {
+		return "", fmt.Errorf("invalid advertised address %q: peers cannot dial an unspecified address", address)
+	}
+	return address, nil
+}
+
+// HostPort joins an address and port, bracketing IPv6 literals
+func HostPort(address string, port int) string {
+	if normalized, err := NormalizeAddress(address); err == nil {
+		address = normalized
+	}
+	return net.JoinHostPort(address, strconv.Itoa(port))
+}
+
+// NodeURL builds an http URL for a path on a node, e.g. "/ping".
+// An IPv6 zone is escaped as URLs require.
+func NodeURL(address string, port int, path string) string {
+	return "http://" + strings.Replace(HostPort(address, port), "%", "%25", 1) + path
+}
+
+// HostPort returns the node's dialable "host:port"
+func (n *Node) HostPort() string {
+	return HostPort(n.Address, n.Port)
+}
+
+// URL builds an http URL for a path on the node
+func (n *Node) URL(path string) string {
+	return NodeURL(n.Address, n.Port, path)
+}
+
+// ListenAddress turns a bind setting and port into the arguments for
+// net.Listen. The setting is empty or BindAll for every interface, BindIPv4
+// or "0.0.0.0" for every IPv4 interface, BindIPv6 or "::" for every IPv6
+// interface, or a specific IP or host name.
+func ListenAddress(bind string, port int) (network, address string, err error) {
+	if port < 0 || port > 65535 {
+		return "", "", fmt.Errorf("invalid port %d", port)
+	}
+	portStr := strconv.Itoa(port)
+
+	switch strings.ToLower(strings.TrimSpace(bind)) {
+	case "", BindAll:
+		return "tcp", ":" + portStr, nil
+	case BindIPv4, "0.0.0.0":
+		return "tcp4", net.JoinHostPort("0.0.0.0", portStr), nil
+	case BindIPv6, "::", "[::]":
+		return "tcp6", net.JoinHostPort("::", portStr), nil
+	}
+
+	host, err := NormalizeAddress(bind)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid bind address: %v", err)
+	}
+	return "tcp", net.JoinHostPort(host, portStr), nil
+}
+
+// Listen opens a TCP listener for a bind setting, see ListenAddress
+func Listen(bind string, port int) (net.Listener, error) {
+	network, address, err := ListenAddress(bind, port)
+	if err != nil {
+		return nil, err
+	}
+	return net.Listen(network, address)
+}
+
+// AddressInUse reports whether a listen error means the port is taken
+func AddressInUse(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "address already in use") || strings.Contains(msg, "Only one usage")
+}
+
+// BrowseHost is the host to show in a local URL for a server bound with bind
+func BrowseHost(bind string) string {
+	switch strings.ToLower(strings.TrimSpace(bind)) {
+	case "", BindAll, BindIPv4, BindIPv6, "0.0.0.0", "::", "[::]":
+		return "localhost"
+	}
+	return bind
+}
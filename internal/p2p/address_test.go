@@ -0,0 +1,139 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddressFormatting(t *testing.T) {
+	cases := []struct {
+		address  string
+		hostPort string
+		url      string
+	}{
+		{"127.0.0.1", "127.0.0.1:9000", "http://127.0.0.1:9000/ping"},
+		{"localhost", "localhost:9000", "http://localhost:9000/ping"},
+		{"::1", "[::1]:9000", "http://[::1]:9000/ping"},
+		{"[2001:db8::7]", "[2001:db8::7]:9000", "http://[2001:db8::7]:9000/ping"},
+		{"fe80::1%eth0", "[fe80::1%eth0]:9000", "http://[fe80::1%25eth0]:9000/ping"},
+	}
+	for _, c := range cases {
+		node := &Node{Address: c.address, Port: 9000}
+		if got := node.HostPort(); got != c.hostPort {
+			t.Errorf("HostPort(%q) = %q, want %q", c.address, got, c.hostPort)
+		}
+		if got := node.URL("/ping"); got != c.url {
+			t.Errorf("URL(%q) = %q, want %q", c.address, got, c.url)
+		}
+	}
+
+	for _, bad := range []string{"", "[]", "::1]", "10.0.0.1%eth0", "host name", "[::1]:9000"} {
+		if _, err := NormalizeAddress(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+	for _, unspecified := range []string{"0.0.0.0", "::", "[::]"} {
+		if _, err := ValidateAdvertiseAddress(unspecified); err == nil {
+			t.Errorf("expected %q to be rejected as an advertised address", unspecified)
+		}
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	cases := []struct {
+		bind    string
+		network string
+		address string
+	}{
+		{"", "tcp", ":8080"},
+		{"all", "tcp", ":8080"},
+		{"ipv4", "tcp4", "0.0.0.0:8080"},
+		{"0.0.0.0", "tcp4", "0.0.0.0:8080"},
+		{"IPv6", "tcp6", "[::]:8080"},
+		{"::", "tcp6", "[::]:8080"},
+		{"10.1.2.3", "tcp", "10.1.2.3:8080"},
+		{"[fd00::5]", "tcp", "[fd00::5]:8080"},
+	}
+	for _, c := range cases {
+		network, address, err := ListenAddress(c.bind, 8080)
+		if err != nil || network != c.network || address != c.address {
+			t.Errorf("ListenAddress(%q) = %q, %q, %v; want %q, %q", c.bind, network, address, err, c.network, c.address)
+		}
+	}
+	if _, _, err := ListenAddress("not a host", 8080); err == nil {
+		t.Error("expected an invalid bind address to be rejected")
+	}
+}
+
+// listenIPv6 skips the test when the host has no IPv6 loopback
+func listenIPv6(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	return listener
+}
+
+func TestRegisterAndDialIPv6Peer(t *testing.T) {
+	listener := listenIPv6(t)
+	peer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	}))
+	peer.Listener.Close()
+	peer.Listener = listener
+	peer.Start()
+	defer peer.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	network := NewNetwork("::1", 0)
+	register := func(address string) int {
+		body, _ := json.Marshal(Node{ID: "peer-v6", Address: address, Port: port})
+		rec := httptest.NewRecorder()
+		network.HandleRegister(rec, httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	// A peer advertising every interface could never be dialed back
+	if code := register("::"); code != http.StatusBadRequest {
+		t.Errorf("expected an unspecified address to be refused, got %d", code)
+	}
+
+	// The bracketed form is stored bare and bracketed again when dialing
+	if code := register("[::1]"); code != http.StatusOK {
+		t.Fatalf("registration failed with status %d", code)
+	}
+	registered := network.GetPeerByID("peer-v6")
+	if registered == nil || registered.Address != "::1" {
+		t.Fatalf("expected the peer stored as ::1, got %+v", registered)
+	}
+
+	registered.Status = "offline"
+	network.pingPeer(registered)
+	if status := network.GetPeerByID("peer-v6").Status; status != "online" {
+		t.Errorf("expected the IPv6 peer to answer a ping, got %q", status)
+	}
+}
+
+func TestNetworkListensOnIPv6Bind(t *testing.T) {
+	listenIPv6(t).Close()
+
+	network := NewNetwork("::1", 0)
+	network.SetBindAddress("::1")
+	if err := network.Start(); err != nil {
+		t.Fatalf("failed to start on ::1: %v", err)
+	}
+	defer network.Stop()
+
+	resp, err := http.Get(network.LocalNode.URL("/ping"))
+	if err != nil {
+		t.Fatalf("failed to reach the node at %s: %v", network.LocalNode.URL("/ping"), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
@@ -1,7 +1,6 @@
 package p2p
 
 import (
-	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -274,10 +273,11 @@ func (bm *BroadcastManager) getTargetPeers(message *BroadcastMessage) []*TCPPeer
 
 // handleIncomingBroadcast handles incoming broadcast messages
 func (bm *BroadcastManager) handleIncomingBroadcast(peer *TCPPeer, msg *TCPMessage) error {
-	var broadcastMsg BroadcastMessage
-	if err := json.Unmarshal(msg.Data, &broadcastMsg); err != nil {
+	payload, err := msg.DecodePayload()
+	if err != nil {
 		return fmt.Errorf("failed to unmarshal broadcast message: %v", err)
 	}
+	broadcastMsg := *payload.(*BroadcastMessage)
 
 	fmt.Printf("📥 Received broadcast message: %s from %s (Type: %s)\n", 
 		broadcastMsg.ID, peer.ID, broadcastMsg.Type)
@@ -423,5 +423,12 @@ func (bm *BroadcastManager) GetStatistics() BroadcastStats {
 	bm.broadcastStats.mu.RLock()
 	defer bm.broadcastStats.mu.RUnlock()
 
-	return *bm.broadcastStats
+	return BroadcastStats{
+		TotalSent:         bm.broadcastStats.TotalSent,
+		TotalReceived:     bm.broadcastStats.TotalReceived,
+		TotalDelivered:    bm.broadcastStats.TotalDelivered,
+		TotalFailed:       bm.broadcastStats.TotalFailed,
+		AverageLatency:    bm.broadcastStats.AverageLatency,
+		LastBroadcastTime: bm.broadcastStats.LastBroadcastTime,
+	}
 }
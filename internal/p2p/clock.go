@@ -0,0 +1,202 @@
+package p2p
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+)
+
+// ClockHeader carries a node's wall clock on ping and register exchanges
+const ClockHeader = "X-Node-Time"
+
+// DefaultMaxClockSkew is the clock offset beyond which a peer is flagged
+const DefaultMaxClockSkew = 30 * time.Second
+
+// ClockSkew is the measured clock offset of a peer
+type ClockSkew struct {
+	Offset     time.Duration `json:"offset"`     // Peer clock minus local clock
+	RoundTrip  time.Duration `json:"round_trip"` // Bounds the error of the offset to half of it
+	Skewed     bool          `json:"skewed"`     // Offset exceeds the configured threshold
+	MeasuredAt time.Time     `json:"measured_at"`
+}
+
+// EstimateClockOffset estimates a peer's clock offset from one timestamp
+// exchange, assuming the peer read its clock halfway through the round trip.
+func EstimateClockOffset(sent, peerTime, received time.Time) (offset, roundTrip time.Duration) {
+	roundTrip = received.Sub(sent)
+	if roundTrip < 0 {
+		roundTrip = 0
+	}
+	return peerTime.Sub(sent.Add(roundTrip / 2)), roundTrip
+}
+
+// clockTracker keeps the latest clock measurement of every peer
+type clockTracker struct {
+	mu      sync.RWMutex
+	maxSkew time.Duration
+	peers   map[string]ClockSkew
+}
+
+func newClockTracker() *clockTracker {
+	return &clockTracker{
+		maxSkew: DefaultMaxClockSkew,
+		peers:   make(map[string]ClockSkew),
+	}
+}
+
+func (c *clockTracker) setMaxSkew(maxSkew time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxSkew = maxSkew
+	for id, skew := range c.peers {
+		skew.Skewed = c.exceeds(skew)
+		c.peers[id] = skew
+	}
+}
+
+// exceeds reports whether a measurement is past the threshold; a zero threshold disables the check
+func (c *clockTracker) exceeds(skew ClockSkew) bool {
+	if c.maxSkew <= 0 {
+		return false
+	}
+	offset := skew.Offset
+	if offset < 0 {
+		offset = -offset
+	}
+	// Only flag offsets that cannot be explained by network delay
+	return offset-skew.RoundTrip/2 > c.maxSkew
+}
+
+// record stores a measurement and reports whether the peer just became skewed
+func (c *clockTracker) record(nodeID string, sent, peerTime, received time.Time) (ClockSkew, bool) {
+	offset, roundTrip := EstimateClockOffset(sent, peerTime, received)
+	skew := ClockSkew{Offset: offset, RoundTrip: roundTrip, MeasuredAt: received}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	skew.Skewed = c.exceeds(skew)
+	previous, known := c.peers[nodeID]
+	c.peers[nodeID] = skew
+	return skew, skew.Skewed && (!known || !previous.Skewed)
+}
+
+func (c *clockTracker) get(nodeID string) (ClockSkew, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	skew, exists := c.peers[nodeID]
+	return skew, exists
+}
+
+func (c *clockTracker) all() map[string]ClockSkew {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	peers := make(map[string]ClockSkew, len(c.peers))
+	for id, skew := range c.peers {
+		peers[id] = skew
+	}
+	return peers
+}
+
+func (c *clockTracker) forget(nodeID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.peers, nodeID)
+}
+
+// toLocal converts a timestamp read from a peer's clock to the local clock
+func (c *clockTracker) toLocal(nodeID string, t time.Time) time.Time {
+	skew, exists := c.get(nodeID)
+	if !exists {
+		return t
+	}
+	return t.Add(-skew.Offset)
+}
+
+// warnClockSkew logs and publishes a peer whose clock is past the threshold
+func warnClockSkew(bus *events.Bus, nodeID string, skew ClockSkew) {
+	fmt.Printf("⏰ Clock of peer %s is off by %v (round trip %v)\n", nodeID, skew.Offset, skew.RoundTrip)
+	bus.Publish(events.TypeClockSkew, "p2p", fmt.Sprintf("Clock of peer %s is off by %v", nodeID, skew.Offset), map[string]interface{}{
+		"node_id":    nodeID,
+		"offset_ms":  skew.Offset.Milliseconds(),
+		"round_trip": skew.RoundTrip.Milliseconds(),
+	})
+}
+
+// writeClockHeader stamps a response with the local clock
+func writeClockHeader(w http.ResponseWriter) {
+	w.Header().Set(ClockHeader, time.Now().UTC().Format(time.RFC3339Nano))
+}
+
+// readClockHeader parses a peer's clock from a response or request header
+func readClockHeader(header http.Header) (time.Time, bool) {
+	value := header.Get(ClockHeader)
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+// SetMaxClockSkew sets the clock offset beyond which peers are flagged; 0 disables the check
+func (n *Network) SetMaxClockSkew(maxSkew time.Duration) {
+	n.clock.setMaxSkew(maxSkew)
+}
+
+// RecordClockSample records one timestamp exchange with a peer: when the
+// request was sent, the peer's clock reading, and when the reply arrived.
+func (n *Network) RecordClockSample(nodeID string, sent, peerTime, received time.Time) ClockSkew {
+	skew, newlySkewed := n.clock.record(nodeID, sent, peerTime, received)
+	if newlySkewed {
+		warnClockSkew(n.events, nodeID, skew)
+	}
+	return skew
+}
+
+// ClockSkew returns the latest clock measurement of a peer
+func (n *Network) ClockSkew(nodeID string) (ClockSkew, bool) {
+	return n.clock.get(nodeID)
+}
+
+// ClockSkews returns the latest clock measurement of every peer
+func (n *Network) ClockSkews() map[string]ClockSkew {
+	return n.clock.all()
+}
+
+// PeerTimeToLocal converts a timestamp reported by a peer to the local clock
+// using its measured offset, so it can be compared with local times.
+func (n *Network) PeerTimeToLocal(nodeID string, t time.Time) time.Time {
+	return n.clock.toLocal(nodeID, t)
+}
+
+// SetMaxClockSkew sets the clock offset beyond which peers are flagged; 0 disables the check
+func (n *TCPNetwork) SetMaxClockSkew(maxSkew time.Duration) {
+	n.clock.setMaxSkew(maxSkew)
+}
+
+// ClockSkew returns the clock offset measured during a peer's handshake
+func (n *TCPNetwork) ClockSkew(nodeID string) (ClockSkew, bool) {
+	return n.clock.get(nodeID)
+}
+
+// ClockSkews returns the clock measurement of every connected peer
+func (n *TCPNetwork) ClockSkews() map[string]ClockSkew {
+	return n.clock.all()
+}
+
+// recordClockSample records a handshake or pong timestamp from a peer
+func (n *TCPNetwork) recordClockSample(nodeID string, sent, peerTime, received time.Time) {
+	if peerTime.IsZero() {
+		return
+	}
+	if skew, newlySkewed := n.clock.record(nodeID, sent, peerTime, received); newlySkewed {
+		warnClockSkew(nil, nodeID, skew)
+	}
+}
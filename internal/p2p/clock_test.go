@@ -0,0 +1,69 @@
+package p2p
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// clockServer answers pings with its clock shifted by skew
+func clockServer(t *testing.T, skew time.Duration) (string, int) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ClockHeader, time.Now().Add(skew).UTC().Format(time.RFC3339Nano))
+		w.Write([]byte("pong"))
+	}))
+	t.Cleanup(server.Close)
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return host, portNum
+}
+
+func TestSkewedPeerIsFlaggedButStaysOnline(t *testing.T) {
+	network := NewNetwork("127.0.0.1", 0)
+	network.SetMaxClockSkew(10 * time.Second)
+
+	skewedHost, skewedPort := clockServer(t, 5*time.Minute)
+	syncedHost, syncedPort := clockServer(t, 0)
+
+	// The skewed peer reports a last-seen time on its own clock, in our future
+	network.RegisterPeer(&Node{ID: "skewed", Address: skewedHost, Port: skewedPort, LastSeen: time.Now().Add(5 * time.Minute)})
+	network.RegisterPeer(&Node{ID: "synced", Address: syncedHost, Port: syncedPort})
+	if lastSeen := network.GetPeerByID("skewed").LastSeen; lastSeen.After(time.Now()) {
+		t.Errorf("last-seen time taken from the peer's clock: %v", lastSeen)
+	}
+
+	network.pingPeer(network.GetPeerByID("skewed"))
+	network.pingPeer(network.GetPeerByID("synced"))
+
+	skew, measured := network.ClockSkew("skewed")
+	if !measured || !skew.Skewed {
+		t.Fatalf("expected the skewed peer to be flagged, got %+v", skew)
+	}
+	if skew.Offset < 4*time.Minute || skew.Offset > 6*time.Minute {
+		t.Errorf("expected an offset of about 5m, got %v", skew.Offset)
+	}
+	if synced, _ := network.ClockSkew("synced"); synced.Skewed || synced.Offset > time.Second || synced.Offset < -time.Second {
+		t.Errorf("in-sync peer should not be flagged, got %+v", synced)
+	}
+
+	// Skew is a warning, not a failure
+	if status := network.GetPeerByID("skewed").Status; status != "online" {
+		t.Errorf("skewed peer should stay online, got %q", status)
+	}
+
+	// Timestamps from the peer's clock are translated back to ours
+	peerNow := time.Now().Add(5 * time.Minute)
+	if drift := time.Since(network.PeerTimeToLocal("skewed", peerNow)); drift > time.Second || drift < -time.Second {
+		t.Errorf("peer timestamp not corrected for skew, off by %v", drift)
+	}
+
+	// Raising the threshold clears the flag
+	network.SetMaxClockSkew(time.Hour)
+	if skew, _ := network.ClockSkew("skewed"); skew.Skewed {
+		t.Errorf("peer still flagged after raising the threshold")
+	}
+}
@@ -0,0 +1,199 @@
+package p2p
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Chunks cross the wire as their stored bytes: the at-rest encoding (header,
+// compression, encryption) is produced once by the chunker and only undone by
+// the client that downloads the file. A wire codec may wrap those bytes for
+// the hop between two peers, and the receiver unwraps them to the identical
+// stored bytes, so replication never decrypts, decompresses or re-encodes.
+
+// Wire codecs for chunk bytes in transit
+const (
+	WireIdentity = "identity" // Stored bytes sent verbatim
+	WireGzip     = "gzip"     // Stored bytes gzipped for the hop; pays off only for chunks stored uncompressed
+)
+
+const (
+	// ChunkEncodingHeader names the wire codec applied to a chunk body
+	ChunkEncodingHeader = "X-Chunk-Encoding"
+	// AcceptChunkEncodingHeader lists the wire codecs a requester can decode
+	AcceptChunkEncodingHeader = "X-Accept-Chunk-Encoding"
+)
+
+// Capability prefixes announced in Node.Capabilities
+const (
+	wireCapabilityPrefix   = "wire/"
+	formatCapabilityPrefix = "chunk-format/"
+)
+
+// DefaultWireCodecs is the sending preference when none is configured
+var DefaultWireCodecs = []string{WireIdentity}
+
+// supportedWireCodecs are the codecs every node can decode
+var supportedWireCodecs = []string{WireIdentity, WireGzip}
+
+// WireCapabilities returns the capabilities announcing the wire codecs this node decodes
+func WireCapabilities() []string {
+	caps := make([]string, 0, len(supportedWireCodecs))
+	for _, codec := range supportedWireCodecs {
+		caps = append(caps, wireCapabilityPrefix+codec)
+	}
+	return caps
+}
+
+// ChunkFormatCapability announces that a node stores chunks of the given at-rest format version
+func ChunkFormatCapability(version uint8) string {
+	return fmt.Sprintf("%s%d", formatCapabilityPrefix, version)
+}
+
+// HasCapability reports whether the node announced capability. Nodes that
+// announce nothing predate capabilities and are treated as storing the
+// original chunk format with identity transfers.
+func (node *Node) HasCapability(capability string) bool {
+	if len(node.Capabilities) == 0 {
+		return capability == wireCapabilityPrefix+WireIdentity || capability == ChunkFormatCapability(0) || capability == ChunkFormatCapability(1)
+	}
+	for _, c := range node.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsChunkFormat reports whether the node can store chunks of the given
+// at-rest format verbatim
+func (node *Node) AcceptsChunkFormat(version uint8) bool {
+	return node.HasCapability(ChunkFormatCapability(version))
+}
+
+// NegotiateWireCodec picks the first codec in preference that the peer
+// decodes, falling back to identity
+func NegotiateWireCodec(preference []string, peer *Node) string {
+	for _, codec := range preference {
+		if peer != nil && peer.HasCapability(wireCapabilityPrefix+codec) {
+			return codec
+		}
+	}
+	return WireIdentity
+}
+
+// EncodeWire wraps stored chunk bytes in a wire codec
+func EncodeWire(codec string, stored []byte) ([]byte, error) {
+	switch codec {
+	case "", WireIdentity:
+		return stored, nil
+	case WireGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(stored); err != nil {
+			return nil, fmt.Errorf("failed to gzip chunk: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to gzip chunk: %v", err)
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported wire codec %q", codec)
+}
+
+// DecodeWire unwraps chunk bytes received in a wire codec back to their stored form
+func DecodeWire(codec string, wire []byte) ([]byte, error) {
+	return decodeWire(codec, wire, -1)
+}
+
+// decodeWire is DecodeWire failing once the stored bytes exceed limit, -1 for no limit
+func decodeWire(codec string, wire []byte, limit int64) ([]byte, error) {
+	switch codec {
+	case "", WireIdentity:
+		return wire, nil
+	case WireGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(wire))
+		if err != nil {
+			return nil, fmt.Errorf("failed to gunzip chunk: %v", err)
+		}
+		defer zr.Close()
+		stored, err := readLimited(zr, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to gunzip chunk: %v", err)
+		}
+		return stored, nil
+	}
+	return nil, fmt.Errorf("unsupported wire codec %q", codec)
+}
+
+// readLimited reads r to the end, failing once it yields more than limit bytes, -1 for no limit
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit < 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("chunk exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// AcceptWireCodecs sets the header telling a chunk server which codecs this node decodes
+func AcceptWireCodecs(req *http.Request) {
+	req.Header.Set(AcceptChunkEncodingHeader, strings.Join(supportedWireCodecs, ", "))
+}
+
+// WriteChunk sends stored chunk bytes in the first codec of preference the
+// requester accepts. The digest header always covers the stored bytes.
+func WriteChunk(w http.ResponseWriter, r *http.Request, stored []byte, preference []string) error {
+	accepted := make(map[string]bool)
+	for _, codec := range strings.Split(r.Header.Get(AcceptChunkEncodingHeader), ",") {
+		accepted[strings.TrimSpace(codec)] = true
+	}
+	codec := WireIdentity
+	for _, candidate := range preference {
+		if accepted[candidate] {
+			codec = candidate
+			break
+		}
+	}
+	body, err := EncodeWire(codec, stored)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(ChunkDigestHeader, ChunkDigest(stored))
+	if codec != WireIdentity {
+		w.Header().Set(ChunkEncodingHeader, codec)
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(body)
+	return err
+}
+
+// ReadChunk reads a chunk response body and unwraps its wire codec, returning the stored bytes
+func ReadChunk(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeWire(resp.Header.Get(ChunkEncodingHeader), body)
+}
+
+// ReadChunkLimit is ReadChunk for responses that may not announce their size:
+// it fails once the body or the stored bytes it unwraps to exceed limit
+func ReadChunkLimit(resp *http.Response, limit int64) ([]byte, error) {
+	body, err := readLimited(resp.Body, limit)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWire(resp.Header.Get(ChunkEncodingHeader), body, limit)
+}
@@ -0,0 +1,33 @@
+package p2p
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ChunkDigestHeader carries the SHA-256 of the chunk bytes in a peer transfer
+const ChunkDigestHeader = "X-Chunk-SHA256"
+
+// ErrChunkDigestMismatch reports chunk bytes altered in transit
+var ErrChunkDigestMismatch = errors.New("chunk digest mismatch")
+
+// ChunkDigest returns the hex SHA-256 of chunk bytes as sent on the wire. For
+// content-addressed storage it equals the chunk's storage ID.
+func ChunkDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyChunkDigest checks received chunk bytes against the expected digest.
+// An empty expectation is rejected: peer transfers are always verified.
+func VerifyChunkDigest(data []byte, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("%w: no digest to verify against", ErrChunkDigestMismatch)
+	}
+	if actual := ChunkDigest(data); actual != expected {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChunkDigestMismatch, expected, actual)
+	}
+	return nil
+}
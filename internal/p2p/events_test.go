@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/events"
+)
+
+func TestPeerOfflineEventIsStreamed(t *testing.T) {
+	bus := events.NewBus(events.DefaultBacklog)
+	network := NewNetwork("127.0.0.1", 0)
+	network.SetEventBus(bus)
+	network.RegisterPeer(&Node{ID: "peer-1", Address: "10.0.0.2", Port: 9000, Status: "online"})
+
+	server := httptest.NewServer(http.HandlerFunc(bus.ServeSSE))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?types=peer.offline")
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+
+	received := make(chan events.Event, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var event events.Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err == nil && event.ID != 0 {
+				received <- event
+				return
+			}
+		}
+	}()
+
+	network.UpdatePeerStatus("peer-1", "offline")
+
+	select {
+	case event := <-received:
+		if event.Type != events.TypePeerOffline || event.Data["node_id"] != "peer-1" {
+			t.Errorf("expected peer-1 offline event, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("peer-offline event was not streamed")
+	}
+}
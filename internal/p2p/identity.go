@@ -0,0 +1,63 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// DefaultIdentityFile is the identity file name used under the storage path
+const DefaultIdentityFile = "node_identity.json"
+
+// Identity is the persisted identity of the local node
+type Identity struct {
+	NodeID    string    `json:"node_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoadOrCreateIdentity reads the node identity at path, generating and
+// saving a new one on first start. The returned bool is true when the
+// identity was created.
+func LoadOrCreateIdentity(path string) (*Identity, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var identity Identity
+		if err := json.Unmarshal(data, &identity); err != nil {
+			return nil, false, fmt.Errorf("failed to parse identity file %s: %v", path, err)
+		}
+		if identity.NodeID == "" {
+			return nil, false, fmt.Errorf("identity file %s has no node ID", path)
+		}
+		return &identity, false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("failed to read identity file: %v", err)
+	}
+
+	identity := &Identity{NodeID: uuid.New().String(), CreatedAt: time.Now()}
+	if err := saveIdentity(path, identity); err != nil {
+		return nil, false, err
+	}
+	return identity, true, nil
+}
+
+// saveIdentity writes the identity atomically so a crash never leaves it half written
+func saveIdentity(path string, identity *Identity) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create identity directory: %v", err)
+	}
+	data, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode identity: %v", err)
+	}
+
+	if err := storage.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save identity file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package p2p
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentitySurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", DefaultIdentityFile)
+
+	first, created, err := LoadOrCreateIdentity(path)
+	if err != nil || !created {
+		t.Fatalf("expected a new identity on first start, got created=%v err=%v", created, err)
+	}
+	nodeA := NewNetworkWithID(first.NodeID, "127.0.0.1", 9001)
+
+	peerB := NewNetwork("127.0.0.1", 9002)
+	peerB.RegisterPeer(&Node{ID: nodeA.LocalNode.ID, Address: "127.0.0.1", Port: 9001, Status: "online"})
+	peerB.AddChunkToNode(nodeA.LocalNode.ID, "chunk-1")
+	nodeA.RegisterPeer(&Node{ID: peerB.LocalNode.ID, Address: "127.0.0.1", Port: 9002, Status: "online"})
+
+	// Restart node A on a different port
+	second, created, err := LoadOrCreateIdentity(path)
+	if err != nil || created {
+		t.Fatalf("expected the saved identity on restart, got created=%v err=%v", created, err)
+	}
+	if second.NodeID != first.NodeID {
+		t.Fatalf("node ID changed across restart: %s -> %s", first.NodeID, second.NodeID)
+	}
+	restarted := NewNetworkWithID(second.NodeID, "127.0.0.1", 9011)
+
+	// B recognizes the returning node rather than adding a second one
+	peerB.RegisterPeer(restarted.LocalNode)
+	if peers := peerB.GetPeers(); len(peers) != 1 {
+		t.Fatalf("expected the returning node to reuse its entry, got %d peers", len(peers))
+	}
+	known := peerB.GetPeerByID(first.NodeID)
+	if known == nil || known.Port != 9011 {
+		t.Fatalf("expected the entry to follow the new address, got %+v", known)
+	}
+	if len(known.Chunks) != 1 || known.Chunks[0] != "chunk-1" {
+		t.Errorf("replica tracking for the returning node was lost: %v", known.Chunks)
+	}
+	if nodes := peerB.FindNodesWithChunk("chunk-1"); len(nodes) != 1 || nodes[0].ID != first.NodeID {
+		t.Errorf("expected chunk-1 to still be found on the returning node, got %v", nodes)
+	}
+}
+
+func TestLoadIdentityRejectsEmptyID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultIdentityFile)
+	if err := saveIdentity(path, &Identity{}); err != nil {
+		t.Fatalf("saveIdentity failed: %v", err)
+	}
+	if _, _, err := LoadOrCreateIdentity(path); err == nil {
+		t.Errorf("expected an error for an identity without a node ID")
+	}
+}
@@ -0,0 +1,186 @@
+package p2p
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyProbeInterval is how often each peer's round-trip time is measured
+const DefaultLatencyProbeInterval = time.Minute
+
+// latencySmoothing weights a new round-trip sample against the running average
+const latencySmoothing = 0.3
+
+// latencyTick is how often the prober looks for peers due a probe
+const latencyTick = 5 * time.Second
+
+// maxConcurrentProbes bounds the pings in flight at once
+const maxConcurrentProbes = 4
+
+// PeerLatency is the measured round-trip time of a peer
+type PeerLatency struct {
+	RTT        time.Duration `json:"rtt"`      // Smoothed over recent probes
+	LastRTT    time.Duration `json:"last_rtt"` // Most recent probe
+	Samples    int           `json:"samples"`
+	MeasuredAt time.Time     `json:"measured_at"`
+}
+
+// latencyTracker keeps a smoothed round-trip time per peer and limits how
+// often each peer is probed
+type latencyTracker struct {
+	mu       sync.RWMutex
+	interval time.Duration
+	peers    map[string]PeerLatency
+	probed   map[string]time.Time // Last probe attempt, successful or not
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		interval: DefaultLatencyProbeInterval,
+		peers:    make(map[string]PeerLatency),
+		probed:   make(map[string]time.Time),
+	}
+}
+
+func (l *latencyTracker) setInterval(interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = interval
+}
+
+// claim reports whether a peer is due a probe and, if so, marks it probed so
+// concurrent callers do not ping it again
+func (l *latencyTracker) claim(nodeID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.interval <= 0 {
+		return false
+	}
+	if last, exists := l.probed[nodeID]; exists && now.Sub(last) < l.interval {
+		return false
+	}
+	l.probed[nodeID] = now
+	return true
+}
+
+func (l *latencyTracker) record(nodeID string, rtt time.Duration, at time.Time) PeerLatency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	latency, known := l.peers[nodeID]
+	if !known || latency.Samples == 0 {
+		latency.RTT = rtt
+	} else {
+		latency.RTT = time.Duration(latencySmoothing*float64(rtt) + (1-latencySmoothing)*float64(latency.RTT))
+	}
+	latency.LastRTT = rtt
+	latency.Samples++
+	latency.MeasuredAt = at
+	l.peers[nodeID] = latency
+	l.probed[nodeID] = at
+	return latency
+}
+
+func (l *latencyTracker) get(nodeID string) (PeerLatency, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	latency, exists := l.peers[nodeID]
+	return latency, exists
+}
+
+func (l *latencyTracker) all() map[string]PeerLatency {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	peers := make(map[string]PeerLatency, len(l.peers))
+	for id, latency := range l.peers {
+		peers[id] = latency
+	}
+	return peers
+}
+
+func (l *latencyTracker) forget(nodeID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.peers, nodeID)
+	delete(l.probed, nodeID)
+}
+
+// SetLatencyProbeInterval sets how often each peer is pinged to measure its
+// round-trip time; 0 stops active probing, leaving heartbeat pings as the only samples
+func (n *Network) SetLatencyProbeInterval(interval time.Duration) {
+	n.latency.setInterval(interval)
+}
+
+// PeerLatency returns the measured round-trip time of a peer
+func (n *Network) PeerLatency(nodeID string) (PeerLatency, bool) {
+	return n.latency.get(nodeID)
+}
+
+// PeerLatencies returns the measured round-trip time of every probed peer
+func (n *Network) PeerLatencies() map[string]PeerLatency {
+	return n.latency.all()
+}
+
+// ProbeLatency pings a peer and records its round-trip time. A peer probed
+// within the probe interval is not pinged again; its last measurement is returned.
+func (n *Network) ProbeLatency(peer *Node) (PeerLatency, error) {
+	if !n.latency.claim(peer.ID, time.Now()) {
+		if latency, exists := n.latency.get(peer.ID); exists {
+			return latency, nil
+		}
+		return PeerLatency{}, fmt.Errorf("peer %s was probed recently without a result", peer.ID)
+	}
+
+	client := n.Client(5 * time.Second)
+	sent := time.Now()
+	resp, err := client.Get(peer.URL("/ping"))
+	if err != nil {
+		return PeerLatency{}, fmt.Errorf("failed to ping peer %s: %v", peer.ID, err)
+	}
+	defer resp.Body.Close()
+	received := time.Now()
+
+	if resp.StatusCode != http.StatusOK {
+		return PeerLatency{}, fmt.Errorf("peer %s answered ping with status %d", peer.ID, resp.StatusCode)
+	}
+	return n.latency.record(peer.ID, received.Sub(sent), received), nil
+}
+
+// latencyProber probes online peers as they come due, a few at a time
+func (n *Network) latencyProber() {
+	ticker := time.NewTicker(latencyTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.probeDuePeers()
+		case <-n.stopChan:
+			return
+		}
+	}
+}
+
+// probeDuePeers pings every online peer not probed within the interval
+func (n *Network) probeDuePeers() {
+	slots := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for _, peer := range n.GetPeers() {
+		if peer.Status != "online" {
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(peer *Node) {
+			defer func() { <-slots; wg.Done() }()
+			n.ProbeLatency(peer)
+		}(peer)
+	}
+	wg.Wait()
+}
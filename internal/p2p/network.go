@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/events"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
 )
 
 // Node represents a peer in the P2P network
@@ -23,6 +26,18 @@ type Node struct {
 	Status   string    `json:"status"` // "online", "offline", "unreachable"
 	Files    []string  `json:"files"`  // List of file IDs this node has
 	Chunks   []string  `json:"chunks"` // List of chunk IDs this node has
+	// Storage limit the node announces and how much of it is used, 0 when unlimited
+	CapacityBytes int64 `json:"capacity_bytes,omitempty"`
+	UsedBytes     int64 `json:"used_bytes,omitempty"`
+	// Wire codecs the node decodes and chunk formats it stores, e.g. "wire/gzip" or "chunk-format/1"
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Operator-assigned labels such as "eu" or "trusted", matched by placement constraints
+	Labels []string `json:"labels,omitempty"`
+	// Failure domain the node runs in, e.g. "eu-west-1a" and "rack-7", spread across by zone-aware placement
+	Zone string `json:"zone,omitempty"`
+	Rack string `json:"rack,omitempty"`
+	// Transports the node accepts and their ports; empty means HTTP on Port only
+	Transports []TransportEndpoint `json:"transports,omitempty"`
 }
 
 // Network represents the P2P network
@@ -32,8 +47,21 @@ type Network struct {
 	mu              sync.RWMutex
 	heartbeatTicker *time.Ticker
 	stopChan        chan bool
-	store           storage.Storage            // Storage backend for serving chunks
-	metaStore       *metadata.MetadataStore    // Metadata store for chunk mapping
+	store           storage.Storage                 // Storage backend for serving chunks
+	metaStore       *metadata.MetadataStore         // Metadata store for chunk mapping
+	events          *events.Bus                     // Receives peer online/offline transitions
+	clock           *clockTracker                   // Measured clock offset of each peer
+	latency         *latencyTracker                 // Measured round-trip time of each peer
+	bind            string                          // Interface the P2P server listens on, see ListenAddress
+	wireCodecs      []string                        // Preferred codecs for chunks sent to peers, see SetWireCodecs
+	transport       http.RoundTripper               // Carries requests to peers, nil for real sockets
+	resolver        *resolver                       // Looks up peer host names when connecting
+	sockets         http.RoundTripper               // Real socket transport, dialing through resolver
+	peerStore       string                          // File known peers are saved to, see SetPeerStore
+	peerMaxAge      time.Duration                   // Saved peers not seen online for this long are dropped
+	origins         map[string]string               // How each peer became known, see PeerOrigin
+	seenOnline      map[string]time.Time            // Last time each peer was seen online
+	newServer       func(http.Handler) *http.Server // Builds the P2P HTTP server, see SetHTTPServer
 }
 
 // NetworkMessage represents messages exchanged between nodes
@@ -47,20 +75,38 @@ type NetworkMessage struct {
 
 // NewNetwork creates a new P2P network
 func NewNetwork(address string, port int) *Network {
-	return &Network{
+	return NewNetworkWithID("", address, port)
+}
+
+// NewNetworkWithID creates a P2P network for a node with a known, persisted ID;
+// an empty ID generates a fresh one
+func NewNetworkWithID(nodeID string, address string, port int) *Network {
+	if nodeID == "" {
+		nodeID = uuid.New().String()
+	}
+	network := &Network{
 		LocalNode: &Node{
-			ID:       uuid.New().String(),
+			ID:       nodeID,
 			Address:  address,
 			Port:     port,
 			LastSeen: time.Now(),
 			Status:   "online",
 			Files:    make([]string, 0),
 			Chunks:   make([]string, 0),
+
+			Capabilities: WireCapabilities(),
 		},
-		Peers:    make(map[string]*Node),
-		stopChan: make(chan bool),
-		store:    nil, // Storage will be set later
+		Peers:      make(map[string]*Node),
+		stopChan:   make(chan bool),
+		store:      nil, // Storage will be set later
+		clock:      newClockTracker(),
+		latency:    newLatencyTracker(),
+		resolver:   newResolver(),
+		origins:    make(map[string]string),
+		seenOnline: make(map[string]time.Time),
 	}
+	network.sockets = network.resolver.httpTransport()
+	return network
 }
 
 // SetStorage sets the storage backend for the network
@@ -73,14 +119,108 @@ func (n *Network) SetMetadataStore(metaStore *metadata.MetadataStore) {
 	n.metaStore = metaStore
 }
 
+// SetBindAddress sets the interface the P2P server listens on; call before Start.
+// LocalNode.Address stays the address advertised to peers.
+func (n *Network) SetBindAddress(bind string) {
+	n.bind = bind
+}
+
+// SetWireCodecs sets the order in which wire codecs are preferred when this
+// node sends chunks; codecs the receiver cannot decode are skipped
+func (n *Network) SetWireCodecs(codecs []string) error {
+	for _, codec := range codecs {
+		if _, err := EncodeWire(codec, nil); err != nil {
+			return err
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.wireCodecs = append([]string(nil), codecs...)
+	return nil
+}
+
+// WireCodecs returns the wire codec preference for sending chunks
+func (n *Network) WireCodecs() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if len(n.wireCodecs) == 0 {
+		return DefaultWireCodecs
+	}
+	return append([]string(nil), n.wireCodecs...)
+}
+
+// AddCapability announces an extra capability of this node to peers
+func (n *Network) AddCapability(capability string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, c := range n.LocalNode.Capabilities {
+		if c == capability {
+			return
+		}
+	}
+	n.LocalNode.Capabilities = append(n.LocalNode.Capabilities, capability)
+}
+
+// SetLocalLabels sets the labels this node announces to peers
+func (n *Network) SetLocalLabels(labels []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.LocalNode.Labels = append([]string(nil), labels...)
+}
+
+// SetLocalTopology sets the zone and rack this node announces to peers
+func (n *Network) SetLocalTopology(zone, rack string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.LocalNode.Zone = zone
+	n.LocalNode.Rack = rack
+}
+
+// SetLocalCapacity sets the storage limit and usage this node announces to
+// peers when it registers with them
+func (n *Network) SetLocalCapacity(limitBytes, usedBytes int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.LocalNode.CapacityBytes = limitBytes
+	n.LocalNode.UsedBytes = usedBytes
+}
+
+// SetEventBus publishes peer transitions to the given bus
+func (n *Network) SetEventBus(bus *events.Bus) {
+	n.events = bus
+}
+
+// publishPeerEvent reports a peer transition on the event bus
+func (n *Network) publishPeerEvent(eventType string, node *Node, reason string) {
+	message := fmt.Sprintf("Peer %s is online", node.ID)
+	if eventType == events.TypePeerOffline {
+		message = fmt.Sprintf("Peer %s is offline", node.ID)
+	}
+	n.events.Publish(eventType, "p2p", message, map[string]interface{}{
+		"node_id": node.ID,
+		"address": node.HostPort(),
+		"reason":  reason,
+	})
+}
+
 // Start initializes the P2P network
 func (n *Network) Start() error {
+	// Listen before returning so a taken port or bad bind address is reported
+	listener, err := Listen(n.bind, n.LocalNode.Port)
+	if err != nil {
+		return fmt.Errorf("failed to start P2P listener: %v", err)
+	}
+	if n.LocalNode.Port == 0 {
+		n.LocalNode.Port = listener.Addr().(*net.TCPAddr).Port
+	}
+
 	// Start heartbeat monitoring
 	n.heartbeatTicker = time.NewTicker(30 * time.Second)
 	go n.heartbeatMonitor()
+	go n.latencyProber()
 
 	// Start HTTP server for P2P communication
-	go n.startHTTPServer()
+	go n.startHTTPServer(listener)
 
 	fmt.Printf("🌐 P2P Network started - Node ID: %s\n", n.LocalNode.ID)
 	return nil
@@ -92,6 +232,9 @@ func (n *Network) Stop() {
 		n.heartbeatTicker.Stop()
 	}
 	close(n.stopChan)
+	if err := n.SavePeers(); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	}
 }
 
 // RegisterPeer registers a new peer in the network
@@ -99,10 +242,55 @@ func (n *Network) RegisterPeer(node *Node) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	if node.ID != n.LocalNode.ID {
-		n.Peers[node.ID] = node
-		fmt.Printf("📝 Registered peer: %s (%s:%d)\n", node.ID, node.Address, node.Port)
+	if node.ID == n.LocalNode.ID {
+		return
+	}
+	// IPv6 literals are kept without brackets and bracketed again when dialing
+	if address, err := NormalizeAddress(node.Address); err == nil {
+		node.Address = address
+	}
+	if node.Status == "" || node.Status == "online" {
+		n.seenOnline[node.ID] = time.Now()
+		defer n.publishPeerEvent(events.TypePeerOnline, node, "registered")
+	}
+	if n.origins[node.ID] == "" {
+		n.origins[node.ID] = PeerDiscovered
+	}
+
+	// A returning node keeps its identity, so refresh the known entry instead of starting over
+	if known, exists := n.Peers[node.ID]; exists {
+		known.Address = node.Address
+		known.Port = node.Port
+		known.Status = node.Status
+		if known.Status == "" {
+			known.Status = "online"
+		}
+		known.LastSeen = time.Now()
+		if len(node.Files) > 0 {
+			known.Files = node.Files
+		}
+		if len(node.Chunks) > 0 {
+			known.Chunks = node.Chunks
+		}
+		known.CapacityBytes = node.CapacityBytes
+		known.UsedBytes = node.UsedBytes
+		if len(node.Capabilities) > 0 {
+			known.Capabilities = node.Capabilities
+		}
+		known.Labels = node.Labels
+		known.Zone = node.Zone
+		known.Rack = node.Rack
+		if len(node.Transports) > 0 {
+			known.Transports = node.Transports
+		}
+		fmt.Printf("🔁 Peer returned: %s (%s)\n", node.ID, node.HostPort())
+		return
 	}
+
+	// The reported last-seen time is on the peer's clock, which may be skewed
+	node.LastSeen = time.Now()
+	n.Peers[node.ID] = node
+	fmt.Printf("📝 Registered peer: %s (%s)\n", node.ID, node.HostPort())
 }
 
 // RemovePeer removes a peer from the network
@@ -112,7 +300,12 @@ func (n *Network) RemovePeer(nodeID string) {
 
 	if peer, exists := n.Peers[nodeID]; exists {
 		delete(n.Peers, nodeID)
-		fmt.Printf("❌ Removed peer: %s (%s:%d)\n", peer.ID, peer.Address, peer.Port)
+		delete(n.origins, nodeID)
+		delete(n.seenOnline, nodeID)
+		n.clock.forget(nodeID)
+		n.latency.forget(nodeID)
+		fmt.Printf("❌ Removed peer: %s (%s)\n", peer.ID, peer.HostPort())
+		n.publishPeerEvent(events.TypePeerOffline, peer, "removed")
 	}
 }
 
@@ -142,8 +335,21 @@ func (n *Network) UpdatePeerStatus(nodeID string, status string) {
 	defer n.mu.Unlock()
 
 	if peer, exists := n.Peers[nodeID]; exists {
+		previous := peer.Status
 		peer.Status = status
 		peer.LastSeen = time.Now()
+		if status == "online" {
+			n.seenOnline[nodeID] = peer.LastSeen
+		}
+
+		if previous != status {
+			switch status {
+			case "online":
+				n.publishPeerEvent(events.TypePeerOnline, peer, "was "+previous)
+			case "offline", "unreachable":
+				n.publishPeerEvent(events.TypePeerOffline, peer, status)
+			}
+		}
 	}
 }
 
@@ -171,6 +377,37 @@ func (n *Network) AddChunkToNode(nodeID string, chunkID string) {
 	}
 }
 
+// RemoveFileFromNodes drops a deleted file and its chunks from every node's lists
+func (n *Network) RemoveFileFromNodes(fileID string, chunkIDs []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	removed := make(map[string]bool, len(chunkIDs))
+	for _, chunkID := range chunkIDs {
+		removed[chunkID] = true
+	}
+	prune := func(node *Node) {
+		files := make([]string, 0, len(node.Files))
+		for _, id := range node.Files {
+			if id != fileID {
+				files = append(files, id)
+			}
+		}
+		node.Files = files
+		chunks := make([]string, 0, len(node.Chunks))
+		for _, id := range node.Chunks {
+			if !removed[id] {
+				chunks = append(chunks, id)
+			}
+		}
+		node.Chunks = chunks
+	}
+	prune(n.LocalNode)
+	for _, peer := range n.Peers {
+		prune(peer)
+	}
+}
+
 // FindNodesWithChunk finds all nodes that have a specific chunk
 func (n *Network) FindNodesWithChunk(chunkID string) []*Node {
 	n.mu.RLock()
@@ -237,6 +474,9 @@ func (n *Network) heartbeatMonitor() {
 		select {
 		case <-n.heartbeatTicker.C:
 			n.checkPeerHealth()
+			if err := n.SavePeers(); err != nil {
+				fmt.Printf("⚠️ %v\n", err)
+			}
 		case <-n.stopChan:
 			return
 		}
@@ -254,10 +494,11 @@ func (n *Network) checkPeerHealth() {
 
 // pingPeer sends a ping to a peer to check its health
 func (n *Network) pingPeer(peer *Node) {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := n.Client(5 * time.Second)
 
-	pingURL := fmt.Sprintf("http://%s:%d/ping", peer.Address, peer.Port)
+	pingURL := peer.URL("/ping")
 
+	sent := time.Now()
 	resp, err := client.Get(pingURL)
 	if err != nil {
 		n.UpdatePeerStatus(peer.ID, "offline")
@@ -265,39 +506,60 @@ func (n *Network) pingPeer(peer *Node) {
 	}
 	defer resp.Body.Close()
 
+	if peerTime, ok := readClockHeader(resp.Header); ok {
+		n.RecordClockSample(peer.ID, sent, peerTime, time.Now())
+	}
+
 	if resp.StatusCode == http.StatusOK {
+		// Heartbeats double as latency samples
+		received := time.Now()
+		n.latency.record(peer.ID, received.Sub(sent), received)
 		n.UpdatePeerStatus(peer.ID, "online")
 	} else {
 		n.UpdatePeerStatus(peer.ID, "unreachable")
 	}
 }
 
-// startHTTPServer starts the HTTP server for P2P communication
-func (n *Network) startHTTPServer() {
-	mux := http.NewServeMux()
+// Timeouts for the P2P HTTP server when SetHTTPServer was not called
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 60 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultMaxBodyBytes      = 10 << 20
+)
 
-	// P2P endpoints
-	mux.HandleFunc("/ping", n.HandlePing)
-	mux.HandleFunc("/pong", n.HandlePong)
-	mux.HandleFunc("/register", n.HandleRegister)
-	mux.HandleFunc("/peers", n.HandleGetPeers)
-	mux.HandleFunc("/file-request", n.HandleFileRequest)
-	mux.HandleFunc("/chunk-request", n.HandleChunkRequest)
-	mux.HandleFunc("/heartbeat", n.HandleHeartbeat)
+// SetHTTPServer sets how the P2P HTTP server is built around the peer handler,
+// so it can share the API server's timeouts and body limits. Call before Start.
+func (n *Network) SetHTTPServer(build func(handler http.Handler) *http.Server) {
+	n.newServer = build
+}
 
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", n.LocalNode.Port),
-		Handler: mux,
+// httpServer builds the P2P HTTP server, with conservative limits by default
+func (n *Network) httpServer() *http.Server {
+	if n.newServer != nil {
+		return n.newServer(n.Handler())
+	}
+	return &http.Server{
+		Handler:           http.MaxBytesHandler(n.Handler(), defaultMaxBodyBytes),
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ReadTimeout:       defaultReadTimeout,
+		IdleTimeout:       defaultIdleTimeout,
 	}
+}
 
-	fmt.Printf("🌐 P2P HTTP server starting on port %d\n", n.LocalNode.Port)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+// startHTTPServer serves P2P communication on the listener opened by Start
+func (n *Network) startHTTPServer(listener net.Listener) {
+	server := n.httpServer()
+
+	fmt.Printf("🌐 P2P HTTP server starting on %s\n", listener.Addr())
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 		fmt.Printf("❌ P2P server failed: %v\n", err)
 	}
 }
 
 // HTTP handlers for P2P communication
 func (n *Network) HandlePing(w http.ResponseWriter, r *http.Request) {
+	writeClockHeader(w)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("pong"))
 }
@@ -317,10 +579,23 @@ func (n *Network) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	address, err := ValidateAdvertiseAddress(newNode.Address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	newNode.Address = address
 
 	n.RegisterPeer(&newNode)
 
+	// A registering node may send its clock; the reading also includes the transit delay
+	if peerTime, ok := readClockHeader(r.Header); ok && newNode.ID != n.LocalNode.ID {
+		now := time.Now()
+		n.RecordClockSample(newNode.ID, now, peerTime, now)
+	}
+
 	// Send back our node info
+	writeClockHeader(w)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(n.LocalNode)
 }
@@ -344,6 +619,12 @@ func (n *Network) HandleChunkRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Peers forward the trace ID of the operation that needs the chunk
+	ctx, traceID := tracing.FromRequest(r)
+	if traceID != "" {
+		w.Header().Set(tracing.Header, traceID)
+	}
+
 	// Get chunk ID from query parameters
 	chunkID := r.URL.Query().Get("id")
 	if chunkID == "" {
@@ -383,37 +664,42 @@ func (n *Network) HandleChunkRequest(w http.ResponseWriter, r *http.Request) {
 				// Try using the path from metadata
 				reader, err = n.store.Get(chunkMeta.Path)
 				if err != nil {
-					fmt.Printf("❌ Failed to retrieve chunk %s from storage path %s: %v\n", chunkID, chunkMeta.Path, err)
+					tracing.Logf(ctx, "❌ Failed to retrieve chunk %s from storage path %s: %v", chunkID, chunkMeta.Path, err)
 					http.Error(w, "Chunk not found in storage", http.StatusNotFound)
 					return
 				}
 			} else {
-				fmt.Printf("❌ Failed to retrieve chunk %s from storage and metadata: %v, %v\n", chunkID, err, metaErr)
+				tracing.Logf(ctx, "❌ Failed to retrieve chunk %s from storage and metadata: %v, %v", chunkID, err, metaErr)
 				http.Error(w, "Chunk not found", http.StatusNotFound)
 				return
 			}
 		} else {
-			fmt.Printf("❌ Failed to retrieve chunk %s from storage: %v\n", chunkID, err)
+			tracing.Logf(ctx, "❌ Failed to retrieve chunk %s from storage: %v", chunkID, err)
 			http.Error(w, "Chunk not found in storage", http.StatusNotFound)
 			return
 		}
 	}
 	defer reader.Close()
 
-	// Set appropriate headers
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.WriteHeader(http.StatusOK)
+	// The digest goes in a header, so the chunk is read before anything is sent
+	chunkData, err := io.ReadAll(reader)
+	if err != nil {
+		tracing.Logf(ctx, "❌ Failed to read chunk %s: %v", chunkID, err)
+		http.Error(w, "Failed to read chunk", http.StatusInternalServerError)
+		return
+	}
 
-	// Stream chunk data to the client
-	if _, err := io.Copy(w, reader); err != nil {
-		fmt.Printf("❌ Failed to stream chunk %s: %v\n", chunkID, err)
+	// Stored bytes go out as they are, wrapped only in a codec the requester asked for
+	if err := WriteChunk(w, r, chunkData, n.WireCodecs()); err != nil {
+		tracing.Logf(ctx, "❌ Failed to stream chunk %s: %v", chunkID, err)
 		return
 	}
 	
-	fmt.Printf("📤 Successfully served chunk %s to remote node\n", chunkID)
+	tracing.Logf(ctx, "📤 Successfully served chunk %s to remote node", chunkID)
 }
 
 func (n *Network) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	writeClockHeader(w)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("alive"))
 }
@@ -431,7 +717,7 @@ func (n *Network) BroadcastMessage(msg *NetworkMessage) {
 
 // sendMessageToPeer sends a message to a specific peer
 func (n *Network) sendMessageToPeer(peer *Node, msg *NetworkMessage) {
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := n.Client(10 * time.Second)
 
 	msgData, err := json.Marshal(msg)
 	if err != nil {
@@ -439,7 +725,7 @@ func (n *Network) sendMessageToPeer(peer *Node, msg *NetworkMessage) {
 		return
 	}
 
-	url := fmt.Sprintf("http://%s:%d/message", peer.Address, peer.Port)
+	url := peer.URL("/message")
 
 	resp, err := client.Post(url, "application/json", bytes.NewBuffer(msgData))
 	if err != nil {
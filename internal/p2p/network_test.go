@@ -0,0 +1,36 @@
+package p2p
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPServerIsBounded(t *testing.T) {
+	network := NewNetworkWithID("a", "a.test", 7010)
+	server := network.httpServer()
+	if server.ReadHeaderTimeout <= 0 || server.ReadTimeout <= 0 || server.IdleTimeout <= 0 {
+		t.Fatalf("expected default timeouts, got %+v", server)
+	}
+
+	// An oversized registration is cut off instead of read to the end
+	body := `{"id":"b","address":"b.test","port":7011,"files":["` + strings.Repeat("x", defaultMaxBodyBytes) + `"]}`
+	w := httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected oversized registration refused, got %d", w.Code)
+	}
+	if len(network.GetPeers()) != 0 {
+		t.Fatal("expected no peer registered from an oversized body")
+	}
+
+	// A configured builder replaces the defaults
+	network.SetHTTPServer(func(handler http.Handler) *http.Server {
+		return &http.Server{Handler: handler, ReadHeaderTimeout: time.Second}
+	})
+	if server := network.httpServer(); server.ReadHeaderTimeout != time.Second || server.Handler == nil {
+		t.Fatalf("expected the configured server, got %+v", server)
+	}
+}
@@ -0,0 +1,235 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/storage"
+)
+
+// DefaultPeersFile is the known-peers file name used under the storage path
+const DefaultPeersFile = "known_peers.json"
+
+// DefaultPeerMaxAge is how long a saved peer is kept without being seen online
+const DefaultPeerMaxAge = 7 * 24 * time.Hour
+
+// How a peer came to be known, see PeerOrigin
+const (
+	PeerDiscovered = "discovered" // Registered with this node, or found since it started
+	PeerPersisted  = "persisted"  // Restored from the known-peers file on startup
+)
+
+// restoreTimeout bounds each reconnection attempt on startup
+const restoreTimeout = 5 * time.Second
+
+// KnownPeer is a peer as saved in the known-peers file
+type KnownPeer struct {
+	ID           string              `json:"id"`
+	Address      string              `json:"address"`
+	Port         int                 `json:"port"`
+	LastSeen     time.Time           `json:"last_seen"` // Last time the peer was seen online
+	Capabilities []string            `json:"capabilities,omitempty"`
+	Labels       []string            `json:"labels,omitempty"`
+	Zone         string              `json:"zone,omitempty"`
+	Rack         string              `json:"rack,omitempty"`
+	Transports   []TransportEndpoint `json:"transports,omitempty"`
+}
+
+// LoadKnownPeers reads the peers saved at path, leaving out any not seen
+// online within maxAge. A missing file is not an error.
+func LoadKnownPeers(path string, maxAge time.Duration) ([]KnownPeer, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known peers: %v", err)
+	}
+	var saved []KnownPeer
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse known peers file %s: %v", path, err)
+	}
+	cutoff := time.Now().Add(-maxAge)
+	fresh := saved[:0]
+	for _, peer := range saved {
+		if peer.ID != "" && (maxAge <= 0 || peer.LastSeen.After(cutoff)) {
+			fresh = append(fresh, peer)
+		}
+	}
+	return fresh, nil
+}
+
+// SetPeerStore saves known peers to path on every heartbeat and on Stop, and
+// lets RestorePeers reconnect to them after a restart. Peers not seen online
+// within maxAge are dropped; 0 keeps them all.
+func (n *Network) SetPeerStore(path string, maxAge time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.peerStore = path
+	n.peerMaxAge = maxAge
+}
+
+// PeerOrigin reports how a peer became known: PeerPersisted or PeerDiscovered
+func (n *Network) PeerOrigin(nodeID string) string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.origins[nodeID]
+}
+
+// SavePeers writes the known peers to the peer store, if one is set
+func (n *Network) SavePeers() error {
+	n.mu.RLock()
+	path, maxAge := n.peerStore, n.peerMaxAge
+	cutoff := time.Now().Add(-maxAge)
+	peers := make([]KnownPeer, 0, len(n.Peers))
+	for _, peer := range n.Peers {
+		seen := n.seenOnline[peer.ID]
+		if seen.IsZero() || (maxAge > 0 && seen.Before(cutoff)) {
+			continue
+		}
+		peers = append(peers, KnownPeer{
+			ID:           peer.ID,
+			Address:      peer.Address,
+			Port:         peer.Port,
+			LastSeen:     seen,
+			Capabilities: peer.Capabilities,
+			Labels:       peer.Labels,
+			Zone:         peer.Zone,
+			Rack:         peer.Rack,
+			Transports:   peer.Transports,
+		})
+	}
+	n.mu.RUnlock()
+	if path == "" {
+		return nil
+	}
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+	data, err := json.MarshalIndent(peers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode known peers: %v", err)
+	}
+	if err := storage.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save known peers: %v", err)
+	}
+	return nil
+}
+
+// RestorePeers reconnects to the peers saved in the peer store, all at once,
+// announcing this node to each. Peers that answer are online straight away;
+// the rest are kept offline for the heartbeat to retry. It returns how many
+// peers answered.
+func (n *Network) RestorePeers() (int, error) {
+	n.mu.RLock()
+	path, maxAge := n.peerStore, n.peerMaxAge
+	n.mu.RUnlock()
+	if path == "" {
+		return 0, nil
+	}
+	known, err := LoadKnownPeers(path, maxAge)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	restored := 0
+	for _, peer := range known {
+		if peer.ID == n.LocalNode.ID {
+			continue
+		}
+		wg.Add(1)
+		go func(peer KnownPeer) {
+			defer wg.Done()
+			if n.restorePeer(peer) {
+				mu.Lock()
+				restored++
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	fmt.Printf("🔁 Reconnected to %d of %d known peers\n", restored, len(known))
+	return restored, nil
+}
+
+// restorePeer re-registers one saved peer and announces this node to it
+func (n *Network) restorePeer(peer KnownPeer) bool {
+	n.mu.Lock()
+	if _, exists := n.Peers[peer.ID]; exists {
+		n.mu.Unlock()
+		return false // Already back by itself
+	}
+	n.origins[peer.ID] = PeerPersisted
+	n.seenOnline[peer.ID] = peer.LastSeen
+	n.mu.Unlock()
+
+	node := &Node{
+		ID:           peer.ID,
+		Address:      peer.Address,
+		Port:         peer.Port,
+		Status:       "offline",
+		Files:        make([]string, 0),
+		Chunks:       make([]string, 0),
+		Capabilities: peer.Capabilities,
+		Labels:       peer.Labels,
+		Zone:         peer.Zone,
+		Rack:         peer.Rack,
+		Transports:   peer.Transports,
+	}
+	reply, err := n.Announce(node)
+	if err != nil || reply.ID != peer.ID {
+		// Another node now answers at the address; it registers as a new peer
+		n.RegisterPeer(node)
+		return false
+	}
+	reply.Status = "online"
+	n.RegisterPeer(reply)
+	return true
+}
+
+// Announce registers this node with peer and returns the peer's own record
+func (n *Network) Announce(peer *Node) (*Node, error) {
+	n.mu.RLock()
+	body, err := json.Marshal(n.LocalNode)
+	n.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode local node: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, peer.URL("/register"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ClockHeader, time.Now().UTC().Format(time.RFC3339Nano))
+	sent := time.Now()
+	resp, err := n.Client(restoreTimeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach peer %s: %v", peer.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer %s refused registration: status %d", peer.ID, resp.StatusCode)
+	}
+
+	var reply Node
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("invalid registration reply from %s: %v", peer.ID, err)
+	}
+	if address, err := ValidateAdvertiseAddress(reply.Address); err == nil {
+		reply.Address = address
+	} else {
+		reply.Address = peer.Address
+	}
+	if peerTime, ok := readClockHeader(resp.Header); ok {
+		n.RecordClockSample(reply.ID, sent, peerTime, time.Now())
+	}
+	return &reply, nil
+}
@@ -0,0 +1,71 @@
+package p2p
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestartReconnectsToSavedPeers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPeersFile)
+	transport := NewMemoryTransport()
+	start := func(id string, port int) *Network {
+		network := NewNetworkWithID(id, id+".test", port)
+		transport.Attach(network, network.Handler())
+		return network
+	}
+
+	nodeA := start("a", 7000)
+	nodeB := start("b", 7001)
+	nodeC := start("c", 7002)
+	nodeA.SetPeerStore(path, time.Hour)
+	for _, peer := range []*Network{nodeB, nodeC} {
+		local := peer.LocalNode
+		nodeA.RegisterPeer(&Node{ID: local.ID, Address: local.Address, Port: local.Port, Status: "online"})
+	}
+	nodeA.Stop()
+	transport.Detach(nodeA)
+	transport.Detach(nodeC) // C goes down while A is away
+
+	// A peer last seen long ago is dropped on load
+	var saved []KnownPeer
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved) != 2 {
+		t.Fatalf("expected B and C saved on stop, got %s (%v)", data, err)
+	}
+	saved = append(saved, KnownPeer{ID: "d", Address: "d.test", Port: 7003, LastSeen: time.Now().Add(-48 * time.Hour)})
+	data, _ = json.Marshal(saved)
+	os.WriteFile(path, data, 0600)
+
+	restarted := start("a", 7000)
+	restarted.SetPeerStore(path, time.Hour)
+	began := time.Now()
+	count, err := restarted.RestorePeers()
+	if err != nil || count != 1 {
+		t.Fatalf("expected to reconnect to B only, got %d (%v)", count, err)
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("expected reconnection without waiting for a heartbeat, took %v", elapsed)
+	}
+
+	if peer := restarted.GetPeerByID("b"); peer == nil || peer.Status != "online" || restarted.PeerOrigin("b") != PeerPersisted {
+		t.Errorf("expected B back online as a persisted peer, got %+v (%s)", peer, restarted.PeerOrigin("b"))
+	}
+	if peer := restarted.GetPeerByID("c"); peer == nil || peer.Status != "offline" || restarted.PeerOrigin("c") != PeerPersisted {
+		t.Errorf("expected C kept offline for the heartbeat to retry, got %+v", peer)
+	}
+	if peer := restarted.GetPeerByID("d"); peer != nil {
+		t.Errorf("expected the stale peer dropped, got %+v", peer)
+	}
+	if peer := nodeB.GetPeerByID("a"); peer == nil || peer.Status != "online" {
+		t.Errorf("expected B to learn of A from the announcement, got %+v", peer)
+	}
+
+	nodeE := start("e", 7004)
+	restarted.RegisterPeer(&Node{ID: "e", Address: nodeE.LocalNode.Address, Port: 7004, Status: "online"})
+	if origin := restarted.PeerOrigin("e"); origin != PeerDiscovered {
+		t.Errorf("expected a newly registered peer to be discovered, got %q", origin)
+	}
+}
@@ -0,0 +1,338 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// ProtocolVersion is the wire version written in every TCP frame
+const ProtocolVersion uint8 = 1
+
+// MaxFrameSize bounds a single TCP frame (excluding the length prefix)
+const MaxFrameSize = 10 * 1024 * 1024
+
+// frameFixedSize covers version, type, the three string lengths, timestamp and signature length
+const frameFixedSize = 1 + 1 + 1 + 1 + 1 + 8 + 1
+
+var (
+	// ErrMalformedFrame is returned when a frame cannot be decoded
+	ErrMalformedFrame = errors.New("malformed frame")
+	// ErrUnsupportedVersion is returned for frames written by a newer protocol version
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+	// ErrUnknownMessageType is returned for message types this node does not understand
+	ErrUnknownMessageType = errors.New("unknown message type")
+)
+
+// PingPayload is carried by ping and pong messages
+type PingPayload struct {
+	NodeID    string    `json:"node_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RegisterPayload describes a node when registering or announcing itself
+type RegisterPayload struct {
+	NodeID  string   `json:"node_id"`
+	Address string   `json:"address"`
+	Port    int      `json:"port"`
+	Files   []string `json:"files,omitempty"`
+	Chunks  []string `json:"chunks,omitempty"`
+}
+
+// PeerInfo is a peer address shared during peer exchange
+type PeerInfo struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+}
+
+// PeerExchangePayload carries known peers; an empty list asks the receiver for theirs
+type PeerExchangePayload struct {
+	Peers []PeerInfo `json:"peers"`
+}
+
+// FileRequestPayload asks a peer for a file's manifest
+type FileRequestPayload struct {
+	FileID string `json:"file_id"`
+}
+
+// FileResponsePayload answers a file request
+type FileResponsePayload struct {
+	FileID string   `json:"file_id"`
+	Found  bool     `json:"found"`
+	Name   string   `json:"name,omitempty"`
+	Size   int64    `json:"size,omitempty"`
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+// ChunkRequestPayload asks a peer for a chunk
+type ChunkRequestPayload struct {
+	ChunkID string `json:"chunk_id"`
+}
+
+// ChunkTransferPayload carries chunk bytes. It is binary encoded to avoid
+// inflating chunk data with JSON base64.
+//
+// Layout (big-endian): chunkIDLen u16 | chunkID | hashLen u8 | hash | dataLen u32 | data
+type ChunkTransferPayload struct {
+	ChunkID string
+	Hash    string
+	Data    []byte
+}
+
+// MarshalBinary encodes the chunk transfer payload
+func (p ChunkTransferPayload) MarshalBinary() ([]byte, error) {
+	if len(p.ChunkID) > 0xFFFF {
+		return nil, fmt.Errorf("chunk ID too long: %d bytes", len(p.ChunkID))
+	}
+	if len(p.Hash) > 0xFF {
+		return nil, fmt.Errorf("chunk hash too long: %d bytes", len(p.Hash))
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 2+len(p.ChunkID)+1+len(p.Hash)+4+len(p.Data)))
+	binary.Write(buf, binary.BigEndian, uint16(len(p.ChunkID)))
+	buf.WriteString(p.ChunkID)
+	buf.WriteByte(uint8(len(p.Hash)))
+	buf.WriteString(p.Hash)
+	binary.Write(buf, binary.BigEndian, uint32(len(p.Data)))
+	buf.Write(p.Data)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a chunk transfer payload, rejecting truncated input
+func (p *ChunkTransferPayload) UnmarshalBinary(data []byte) error {
+	r := &frameReader{data: data}
+	chunkID := r.bytes(int(r.uint16()))
+	hash := r.bytes(int(r.uint8()))
+	body := r.bytes(int(r.uint32()))
+	if r.err != nil {
+		return fmt.Errorf("%w: chunk transfer payload truncated", ErrMalformedFrame)
+	}
+	if r.pos != len(data) {
+		return fmt.Errorf("%w: %d trailing bytes in chunk transfer payload", ErrMalformedFrame, len(data)-r.pos)
+	}
+
+	p.ChunkID = string(chunkID)
+	p.Hash = string(hash)
+	p.Data = append([]byte(nil), body...)
+	return nil
+}
+
+// newPayload returns an empty payload of the type carried by msgType
+func newPayload(msgType MessageType) (interface{}, error) {
+	switch msgType {
+	case MessageTypeHandshake, MessageTypeHandshakeReply:
+		return &HandshakeData{}, nil
+	case MessageTypePing, MessageTypePong:
+		return &PingPayload{}, nil
+	case MessageTypeRegister, MessageTypeNodeAnnouncement:
+		return &RegisterPayload{}, nil
+	case MessageTypePeerExchange, MessageTypeNodeDiscovery:
+		return &PeerExchangePayload{}, nil
+	case MessageTypeFileRequest:
+		return &FileRequestPayload{}, nil
+	case MessageTypeFileResponse:
+		return &FileResponsePayload{}, nil
+	case MessageTypeChunkRequest:
+		return &ChunkRequestPayload{}, nil
+	case MessageTypeChunkTransfer:
+		return &ChunkTransferPayload{}, nil
+	case MessageTypeBroadcast:
+		return &BroadcastMessage{}, nil
+	case MessageTypeRequest:
+		return &TunnelRequestPayload{}, nil
+	case MessageTypeResponse:
+		return &TunnelResponsePayload{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMessageType, msgType)
+	}
+}
+
+// EncodePayload serialises payload for msgType, refusing payloads of the wrong type
+func EncodePayload(msgType MessageType, payload interface{}) ([]byte, error) {
+	expected, err := newPayload(msgType)
+	if err != nil {
+		return nil, err
+	}
+
+	actual := reflect.TypeOf(payload)
+	if actual != nil && actual.Kind() == reflect.Ptr {
+		actual = actual.Elem()
+	}
+	if actual != reflect.TypeOf(expected).Elem() {
+		return nil, fmt.Errorf("message type %d expects %T payload, got %T", msgType, expected, payload)
+	}
+
+	if marshaler, ok := payload.(encoding.BinaryMarshaler); ok {
+		return marshaler.MarshalBinary()
+	}
+	return json.Marshal(payload)
+}
+
+// DecodePayload parses the message data into the payload type for its message type
+func (m *TCPMessage) DecodePayload() (interface{}, error) {
+	payload, err := newPayload(m.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if unmarshaler, ok := payload.(encoding.BinaryUnmarshaler); ok {
+		err = unmarshaler.UnmarshalBinary(m.Data)
+	} else {
+		err = json.Unmarshal(m.Data, payload)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode payload of message type %d: %w", m.Type, err)
+	}
+	return payload, nil
+}
+
+// EncodeFrame serialises a message into a length-prefixed frame.
+//
+// Layout (big-endian):
+//
+//	length u32 | version u8 | type u8 | idLen u8 | id | fromLen u8 | from |
+//	toLen u8 | to | timestamp i64 (unix nanos) | sigLen u8 | signature | payload
+func EncodeFrame(msg *TCPMessage) ([]byte, error) {
+	if _, err := newPayload(msg.Type); err != nil {
+		return nil, err
+	}
+	for name, value := range map[string]string{"id": msg.ID, "from": msg.From, "to": msg.To} {
+		if len(value) > 0xFF {
+			return nil, fmt.Errorf("message %s too long: %d bytes", name, len(value))
+		}
+	}
+	if len(msg.Signature) > 0xFF {
+		return nil, fmt.Errorf("message signature too long: %d bytes", len(msg.Signature))
+	}
+
+	size := frameFixedSize + len(msg.ID) + len(msg.From) + len(msg.To) + len(msg.Signature) + len(msg.Data)
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("message too large: %d bytes", size)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 4+size))
+	binary.Write(buf, binary.BigEndian, uint32(size))
+	buf.WriteByte(ProtocolVersion)
+	buf.WriteByte(byte(msg.Type))
+	for _, value := range []string{msg.ID, msg.From, msg.To} {
+		buf.WriteByte(uint8(len(value)))
+		buf.WriteString(value)
+	}
+	binary.Write(buf, binary.BigEndian, msg.Timestamp.UnixNano())
+	buf.WriteByte(uint8(len(msg.Signature)))
+	buf.Write(msg.Signature)
+	buf.Write(msg.Data)
+
+	return buf.Bytes(), nil
+}
+
+// DecodeFrame parses a frame body (without its length prefix)
+func DecodeFrame(body []byte) (*TCPMessage, error) {
+	if len(body) < frameFixedSize {
+		return nil, fmt.Errorf("%w: %d bytes is shorter than the frame header", ErrMalformedFrame, len(body))
+	}
+
+	r := &frameReader{data: body}
+	version := r.uint8()
+	if version == 0 || version > ProtocolVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	msg := &TCPMessage{Type: MessageType(r.uint8())}
+	if _, err := newPayload(msg.Type); err != nil {
+		return nil, err
+	}
+
+	msg.ID = string(r.bytes(int(r.uint8())))
+	msg.From = string(r.bytes(int(r.uint8())))
+	msg.To = string(r.bytes(int(r.uint8())))
+	msg.Timestamp = time.Unix(0, int64(r.uint64()))
+	if sig := r.bytes(int(r.uint8())); len(sig) > 0 {
+		msg.Signature = append([]byte(nil), sig...)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: header fields overflow frame", ErrMalformedFrame)
+	}
+	msg.Data = append([]byte(nil), body[r.pos:]...)
+
+	return msg, nil
+}
+
+// WriteFrame encodes msg and writes it to w
+func WriteFrame(w io.Writer, msg *TCPMessage) error {
+	frame, err := EncodeFrame(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+// ReadFrame reads one length-prefixed frame from r and decodes it
+func ReadFrame(r io.Reader) (*TCPMessage, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length < frameFixedSize || length > MaxFrameSize {
+		return nil, fmt.Errorf("%w: invalid frame length %d", ErrMalformedFrame, length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return DecodeFrame(body)
+}
+
+// frameReader reads big-endian fields, latching the first out-of-bounds error
+type frameReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *frameReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		r.err = ErrMalformedFrame
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *frameReader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *frameReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *frameReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *frameReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
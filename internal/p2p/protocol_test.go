@@ -0,0 +1,128 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano()).UTC()
+	cases := []struct {
+		msgType MessageType
+		payload interface{}
+	}{
+		{MessageTypePing, PingPayload{NodeID: "node-a", Timestamp: now}},
+		{MessageTypePong, PingPayload{NodeID: "node-b", Timestamp: now}},
+		{MessageTypeRegister, RegisterPayload{NodeID: "node-a", Address: "10.0.0.1", Port: 9000, Files: []string{"f1"}}},
+		{MessageTypePeerExchange, PeerExchangePayload{Peers: []PeerInfo{{ID: "node-c", Address: "::1", Port: 9001}}}},
+		{MessageTypeChunkRequest, ChunkRequestPayload{ChunkID: "chunk-1"}},
+		{MessageTypeChunkTransfer, ChunkTransferPayload{ChunkID: "chunk-1", Hash: "abc", Data: []byte{0, 1, 2, 255}}},
+		{MessageTypeNodeAnnouncement, RegisterPayload{NodeID: "node-a", Chunks: []string{"c1", "c2"}}},
+		{MessageTypeBroadcast, BroadcastMessage{ID: "b1", Type: BroadcastTypeFileAnnouncement, From: "node-a", TTL: 3, Timestamp: now}},
+	}
+
+	for _, tc := range cases {
+		data, err := EncodePayload(tc.msgType, tc.payload)
+		if err != nil {
+			t.Fatalf("type %d: encode payload failed: %v", tc.msgType, err)
+		}
+		msg := &TCPMessage{Type: tc.msgType, ID: "msg-1", From: "node-a", To: "node-b", Timestamp: now, Data: data}
+
+		var buf bytes.Buffer
+		if err := WriteFrame(&buf, msg); err != nil {
+			t.Fatalf("type %d: write frame failed: %v", tc.msgType, err)
+		}
+		decoded, err := ReadFrame(&buf)
+		if err != nil {
+			t.Fatalf("type %d: read frame failed: %v", tc.msgType, err)
+		}
+		if decoded.Type != msg.Type || decoded.ID != msg.ID || decoded.From != msg.From ||
+			decoded.To != msg.To || !decoded.Timestamp.Equal(now) {
+			t.Errorf("type %d: envelope mismatch: %+v", tc.msgType, decoded)
+		}
+
+		payload, err := decoded.DecodePayload()
+		if err != nil {
+			t.Fatalf("type %d: decode payload failed: %v", tc.msgType, err)
+		}
+		if got := reflect.ValueOf(payload).Elem().Interface(); !reflect.DeepEqual(got, tc.payload) {
+			t.Errorf("type %d: payload mismatch:\n got %+v\nwant %+v", tc.msgType, got, tc.payload)
+		}
+	}
+}
+
+func TestEncodePayloadRejectsWrongType(t *testing.T) {
+	if _, err := EncodePayload(MessageTypePing, ChunkRequestPayload{ChunkID: "x"}); err == nil {
+		t.Errorf("expected ping with chunk request payload to be rejected")
+	}
+	if _, err := EncodePayload(MessageType(200), PingPayload{}); !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("expected unknown message type error, got %v", err)
+	}
+}
+
+func TestDecodeMalformedFrames(t *testing.T) {
+	valid, err := EncodeFrame(&TCPMessage{Type: MessageTypePing, ID: "id", From: "a", Timestamp: time.Now(), Data: []byte("{}")})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	body := valid[4:]
+
+	badVersion := append([]byte(nil), body...)
+	badVersion[0] = ProtocolVersion + 1
+	badType := append([]byte(nil), body...)
+	badType[1] = 250
+	badIDLen := append([]byte(nil), body...)
+	badIDLen[2] = 255
+
+	cases := map[string]struct {
+		body []byte
+		want error
+	}{
+		"empty":       {nil, ErrMalformedFrame},
+		"truncated":   {body[:5], ErrMalformedFrame},
+		"bad version": {badVersion, ErrUnsupportedVersion},
+		"bad type":    {badType, ErrUnknownMessageType},
+		"bad id len":  {badIDLen, ErrMalformedFrame},
+	}
+	for name, tc := range cases {
+		if _, err := DecodeFrame(tc.body); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+
+	// Oversized length prefix is rejected before allocating
+	var oversized bytes.Buffer
+	binary.Write(&oversized, binary.BigEndian, uint32(MaxFrameSize+1))
+	if _, err := ReadFrame(&oversized); !errors.Is(err, ErrMalformedFrame) {
+		t.Errorf("expected oversized frame rejection, got %v", err)
+	}
+
+	// Garbage payloads fail to decode instead of panicking
+	for _, msgType := range []MessageType{MessageTypeChunkTransfer, MessageTypePing, MessageTypeBroadcast} {
+		msg := &TCPMessage{Type: msgType, Data: []byte{0xFF, 0xFF, 0x01}}
+		if _, err := msg.DecodePayload(); err == nil {
+			t.Errorf("type %d: expected garbage payload to be rejected", msgType)
+		}
+	}
+}
+
+func TestDecodeRandomBytesDoesNotPanic(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		data := make([]byte, rng.Intn(64))
+		rng.Read(data)
+		if len(data) > 1 {
+			data[0] = ProtocolVersion
+			data[1] = byte(rng.Intn(int(MessageTypePeerExchange) + 1))
+		}
+		if msg, err := DecodeFrame(data); err == nil {
+			msg.DecodePayload()
+		}
+		(&ChunkTransferPayload{}).UnmarshalBinary(data)
+	}
+}
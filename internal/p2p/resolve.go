@@ -0,0 +1,178 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultResolveTTL is how long the addresses a peer's host name resolved to
+// are reused before it is looked up again
+const DefaultResolveTTL = 30 * time.Second
+
+// Resolution is what a peer's host name last resolved to
+type Resolution struct {
+	Host       string    `json:"host"`
+	Addresses  []string  `json:"addresses"`         // Every A and AAAA record of the last lookup
+	Current    string    `json:"current,omitempty"` // Address the last successful connection went to
+	ResolvedAt time.Time `json:"resolved_at"`
+	Error      string    `json:"error,omitempty"` // Why the last lookup failed, the previous addresses stay in use
+}
+
+// resolver looks up peer host names when connecting rather than once at
+// registration, so a peer whose IP changes stays reachable. Lookups are
+// cached for ttl, and a connection that fails on every cached address looks
+// the name up again straight away.
+type resolver struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	hosts  map[string]*Resolution
+	lookup func(ctx context.Context, host string) ([]string, error)
+	dial   func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func newResolver() *resolver {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &resolver{
+		ttl:    DefaultResolveTTL,
+		hosts:  make(map[string]*Resolution),
+		lookup: net.DefaultResolver.LookupHost,
+		dial:   dialer.DialContext,
+	}
+}
+
+// resolve returns the addresses of host, looking it up when the cached ones
+// are older than the ttl or force is set. A failed lookup falls back to the
+// addresses from before, if there are any.
+func (r *resolver) resolve(ctx context.Context, host string, force bool) ([]string, error) {
+	r.mu.Lock()
+	cached, ok := r.hosts[host]
+	if ok && !force && r.ttl > 0 && time.Since(cached.ResolvedAt) < r.ttl && len(cached.Addresses) > 0 {
+		addresses := r.ordered(cached)
+		r.mu.Unlock()
+		return addresses, nil
+	}
+	r.mu.Unlock()
+
+	addresses, err := r.lookup(ctx, host)
+	if err == nil && len(addresses) == 0 {
+		err = fmt.Errorf("no addresses found for %s", host)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.hosts[host]
+	if !ok {
+		entry = &Resolution{Host: host}
+		r.hosts[host] = entry
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		if len(entry.Addresses) > 0 {
+			return r.ordered(entry), nil
+		}
+		return nil, fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	entry.Addresses = addresses
+	entry.ResolvedAt = time.Now()
+	entry.Error = ""
+	return r.ordered(entry), nil
+}
+
+// ordered lists an entry's addresses with the one that last worked first
+func (r *resolver) ordered(entry *Resolution) []string {
+	addresses := make([]string, 0, len(entry.Addresses))
+	for _, address := range entry.Addresses {
+		if address == entry.Current {
+			addresses = append([]string{address}, addresses...)
+		} else {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// DialContext connects to addr, trying each address its host resolves to in
+// turn. IP literals are dialed as they are.
+func (r *resolver) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil || host == "" {
+		return r.dial(ctx, network, addr)
+	}
+
+	tried := make(map[string]bool)
+	var lastErr error
+	for _, force := range []bool{false, true} {
+		addresses, err := r.resolve(ctx, host, force)
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addresses {
+			if tried[address] {
+				continue
+			}
+			tried[address] = true
+			conn, err := r.dial(ctx, network, net.JoinHostPort(address, port))
+			if err == nil {
+				r.mu.Lock()
+				if entry, ok := r.hosts[host]; ok {
+					entry.Current = address
+				}
+				r.mu.Unlock()
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no new addresses for %s", host)
+	}
+	return nil, lastErr
+}
+
+// status returns a copy of host's last resolution
+func (r *resolver) status(host string) (Resolution, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.hosts[host]
+	if !ok {
+		return Resolution{}, false
+	}
+	resolution := *entry
+	resolution.Addresses = append([]string(nil), entry.Addresses...)
+	return resolution, true
+}
+
+// httpTransport is the transport for requests to peers over real sockets,
+// connecting through the resolver
+func (r *resolver) httpTransport() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = r.DialContext
+	return transport
+}
+
+// SetResolveTTL sets how long a peer host name's addresses are reused before
+// it is looked up again; 0 looks it up on every new connection
+func (n *Network) SetResolveTTL(ttl time.Duration) {
+	n.resolver.mu.Lock()
+	defer n.resolver.mu.Unlock()
+	n.resolver.ttl = ttl
+}
+
+// PeerResolution reports what a peer registered by host name currently
+// resolves to; ok is false for peers registered by IP or not yet dialed
+func (n *Network) PeerResolution(node *Node) (Resolution, bool) {
+	if net.ParseIP(node.Address) != nil {
+		return Resolution{}, false
+	}
+	return n.resolver.status(node.Address)
+}
@@ -0,0 +1,86 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// servePing serves a peer's ping endpoint on address
+func servePing(t *testing.T, address string) *httptest.Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", address, err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(NewNetwork("127.0.0.1", 0).HandlePing))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	return server
+}
+
+func TestPeerHostNameFollowsChangingAddresses(t *testing.T) {
+	first := servePing(t, "127.0.0.1:0")
+	port := first.Listener.Addr().(*net.TCPAddr).Port
+
+	// peer.test resolves to whatever records the test sets
+	var mu sync.Mutex
+	records := []string{"127.0.0.1"}
+	lookups := 0
+	n := NewNetwork("127.0.0.1", 0)
+	n.SetResolveTTL(time.Hour)
+	n.resolver.lookup = func(ctx context.Context, host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if host != "peer.test" {
+			t.Errorf("unexpected lookup of %s", host)
+		}
+		lookups++
+		return append([]string(nil), records...), nil
+	}
+	n.RegisterPeer(&Node{ID: "peer", Address: "peer.test", Port: port, Status: "online"})
+	peer := n.GetPeerByID("peer")
+
+	n.pingPeer(peer)
+	if peer.Status != "online" {
+		t.Fatalf("expected the peer online, got %s", peer.Status)
+	}
+	resolution, ok := n.PeerResolution(peer)
+	if !ok || resolution.Current != "127.0.0.1" || resolution.ResolvedAt.IsZero() {
+		t.Fatalf("unexpected resolution %+v", resolution)
+	}
+
+	// The peer moves; its name now has a dead record ahead of the new address
+	first.Close()
+	second := servePing(t, net.JoinHostPort("127.0.0.2", strconv.Itoa(port)))
+	defer second.Close()
+	mu.Lock()
+	records = []string{"127.0.0.3", "127.0.0.2"}
+	mu.Unlock()
+
+	n.pingPeer(peer)
+	if peer.Status != "online" {
+		t.Fatalf("expected the peer reachable at its new address, got %s", peer.Status)
+	}
+	resolution, _ = n.PeerResolution(peer)
+	if resolution.Current != "127.0.0.2" || len(resolution.Addresses) != 2 {
+		t.Fatalf("unexpected resolution after the move %+v", resolution)
+	}
+
+	// The working address is reused from the cache until the ttl runs out
+	before := lookups
+	n.pingPeer(peer)
+	if lookups != before || peer.Status != "online" {
+		t.Errorf("expected a cached dial, got %d new lookups and status %s", lookups-before, peer.Status)
+	}
+
+	if _, ok := n.PeerResolution(&Node{Address: "127.0.0.1"}); ok {
+		t.Errorf("peers registered by IP have no resolution")
+	}
+}
@@ -4,13 +4,12 @@ import (
 	"bufio"
 	"crypto/rand"
 	"crypto/sha256"
-	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,7 +24,13 @@ type TCPNetwork struct {
 	mu              sync.RWMutex
 	stopChan        chan bool
 	messageHandlers map[MessageType]MessageHandler
-	running         bool
+	running         atomic.Bool // Read unlocked by the accept and peer loops
+	clock           *clockTracker // Clock offsets measured during handshakes
+	bind            string        // Interface to listen on, empty listens on LocalNode.Address
+	requestHandler  http.Handler  // Serves HTTP requests tunnelled over TCP, see SetRequestHandler
+	dialed          map[string]*TCPPeer // Connections this node opened, by the host:port dialed
+	pending         map[string]chan *TunnelResponsePayload // Tunnelled requests awaiting a response
+	pendingMu       sync.Mutex
 }
 
 // TCPPeer represents a TCP peer connection
@@ -54,8 +59,15 @@ const (
 	MessageTypeBroadcast
 	MessageTypeNodeDiscovery
 	MessageTypeNodeAnnouncement
+	MessageTypeRegister
+	MessageTypePeerExchange
+	MessageTypeRequest  // An HTTP request carried over TCP, see TCPNetwork.RoundTrip
+	MessageTypeResponse // The response to a MessageTypeRequest
 )
 
+// MessageTypeChunkTransfer carries chunk bytes in reply to a chunk request
+const MessageTypeChunkTransfer = MessageTypeChunkResponse
+
 // TCPMessage represents a message sent over TCP
 type TCPMessage struct {
 	Type      MessageType `json:"type"`
@@ -81,9 +93,18 @@ type MessageHandler func(*TCPPeer, *TCPMessage) error
 
 // NewTCPNetwork creates a new TCP-based P2P network
 func NewTCPNetwork(address string, port int) *TCPNetwork {
+	return NewTCPNetworkWithID("", address, port)
+}
+
+// NewTCPNetworkWithID creates a TCP-based P2P network for a node with a known, persisted ID;
+// an empty ID generates a fresh one
+func NewTCPNetworkWithID(nodeID string, address string, port int) *TCPNetwork {
+	if nodeID == "" {
+		nodeID = uuid.New().String()
+	}
 	return &TCPNetwork{
 		LocalNode: &Node{
-			ID:       uuid.New().String(),
+			ID:       nodeID,
 			Address:  address,
 			Port:     port,
 			LastSeen: time.Now(),
@@ -95,16 +116,24 @@ func NewTCPNetwork(address string, port int) *TCPNetwork {
 		connections:     make(map[string]net.Conn),
 		stopChan:        make(chan bool),
 		messageHandlers: make(map[MessageType]MessageHandler),
-		running:         false,
+		clock:           newClockTracker(),
+		dialed:          make(map[string]*TCPPeer),
+		pending:         make(map[string]chan *TunnelResponsePayload),
 	}
 }
 
+// SetBindAddress sets the interface the listener binds, see ListenAddress;
+// call before Start
+func (n *TCPNetwork) SetBindAddress(bind string) {
+	n.bind = bind
+}
+
 // Start initializes the TCP P2P network
 func (n *TCPNetwork) Start() error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	if n.running {
+	if n.running.Load() {
 		return fmt.Errorf("network already running")
 	}
 
@@ -112,14 +141,20 @@ func (n *TCPNetwork) Start() error {
 	n.registerDefaultHandlers()
 
 	// Start TCP listener
-	addr := fmt.Sprintf("%s:%d", n.LocalNode.Address, n.LocalNode.Port)
-	listener, err := net.Listen("tcp", addr)
+	bind := n.bind
+	if bind == "" {
+		bind = n.LocalNode.Address
+	}
+	listener, err := Listen(bind, n.LocalNode.Port)
 	if err != nil {
 		return fmt.Errorf("failed to start TCP listener: %v", err)
 	}
 
 	n.listener = listener
-	n.running = true
+	n.running.Store(true)
+	if n.LocalNode.Port == 0 {
+		n.LocalNode.Port = listener.Addr().(*net.TCPAddr).Port
+	}
 
 	// Start accepting connections
 	go n.acceptConnections()
@@ -127,7 +162,7 @@ func (n *TCPNetwork) Start() error {
 	// Start connection monitor
 	go n.monitorConnections()
 
-	fmt.Printf("🌐 TCP P2P Network started - Node ID: %s, Address: %s\n", n.LocalNode.ID, addr)
+	fmt.Printf("🌐 TCP P2P Network started - Node ID: %s, Address: %s\n", n.LocalNode.ID, listener.Addr())
 	return nil
 }
 
@@ -136,11 +171,11 @@ func (n *TCPNetwork) Stop() error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	if !n.running {
+	if !n.running.Load() {
 		return nil
 	}
 
-	n.running = false
+	n.running.Store(false)
 	close(n.stopChan)
 
 	// Close listener
@@ -166,7 +201,11 @@ func (n *TCPNetwork) Stop() error {
 
 // ConnectToPeer connects to a remote peer
 func (n *TCPNetwork) ConnectToPeer(address string, port int) (*TCPPeer, error) {
-	addr := fmt.Sprintf("%s:%d", address, port)
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to peer: %v", err)
+	}
+	addr := HostPort(address, port)
 	
 	conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
 	if err != nil {
@@ -209,10 +248,10 @@ func (n *TCPNetwork) ConnectToPeer(address string, port int) (*TCPPeer, error) {
 
 // acceptConnections accepts incoming TCP connections
 func (n *TCPNetwork) acceptConnections() {
-	for n.running {
+	for n.running.Load() {
 		conn, err := n.listener.Accept()
 		if err != nil {
-			if n.running {
+			if n.running.Load() {
 				fmt.Printf("❌ Error accepting connection: %v\n", err)
 			}
 			continue
@@ -271,6 +310,7 @@ func (n *TCPNetwork) performHandshake(peer *TCPPeer) error {
 	}
 
 	// Send handshake
+	sent := time.Now()
 	if err := n.sendMessageToPeer(peer, MessageTypeHandshake, handshakeData); err != nil {
 		return fmt.Errorf("failed to send handshake: %v", err)
 	}
@@ -280,18 +320,20 @@ func (n *TCPNetwork) performHandshake(peer *TCPPeer) error {
 	if err != nil {
 		return fmt.Errorf("failed to read handshake reply: %v", err)
 	}
+	received := time.Now()
 
 	if msg.Type != MessageTypeHandshakeReply {
 		return fmt.Errorf("expected handshake reply, got %d", msg.Type)
 	}
 
-	var replyData HandshakeData
-	if err := json.Unmarshal(msg.Data, &replyData); err != nil {
+	payload, err := msg.DecodePayload()
+	if err != nil {
 		return fmt.Errorf("failed to unmarshal handshake reply: %v", err)
 	}
+	replyData := payload.(*HandshakeData)
 
-	// Verify challenge response
-	expectedResponse := n.generateChallengeResponse(challengeHex)
+	// Verify challenge response, which the peer derives from its own ID
+	expectedResponse := generateChallengeResponse(challengeHex, replyData.NodeID)
 	if replyData.Response != expectedResponse {
 		return fmt.Errorf("invalid challenge response")
 	}
@@ -299,6 +341,7 @@ func (n *TCPNetwork) performHandshake(peer *TCPPeer) error {
 	// Set peer ID
 	peer.ID = replyData.NodeID
 	peer.Status = "online"
+	n.recordClockSample(peer.ID, sent, replyData.Timestamp, received)
 
 	fmt.Printf("🤝 Handshake completed with peer: %s\n", peer.ID)
 	return nil
@@ -316,16 +359,21 @@ func (n *TCPNetwork) handleHandshakeRequest(peer *TCPPeer) error {
 		return fmt.Errorf("expected handshake, got %d", msg.Type)
 	}
 
-	var handshakeData HandshakeData
-	if err := json.Unmarshal(msg.Data, &handshakeData); err != nil {
+	payload, err := msg.DecodePayload()
+	if err != nil {
 		return fmt.Errorf("failed to unmarshal handshake: %v", err)
 	}
+	handshakeData := payload.(*HandshakeData)
 
 	// Set peer ID
 	peer.ID = handshakeData.NodeID
 
+	// A one-way reading: the offset also includes the transit delay
+	now := time.Now()
+	n.recordClockSample(peer.ID, now, handshakeData.Timestamp, now)
+
 	// Generate challenge response
-	response := n.generateChallengeResponse(handshakeData.Challenge)
+	response := generateChallengeResponse(handshakeData.Challenge, n.LocalNode.ID)
 
 	// Send handshake reply
 	replyData := HandshakeData{
@@ -344,16 +392,18 @@ func (n *TCPNetwork) handleHandshakeRequest(peer *TCPPeer) error {
 	return nil
 }
 
-// generateChallengeResponse generates a response to a handshake challenge
-func (n *TCPNetwork) generateChallengeResponse(challenge string) string {
+// generateChallengeResponse generates the response the node nodeID gives to
+// a handshake challenge
+func generateChallengeResponse(challenge, nodeID string) string {
 	hasher := sha256.New()
-	hasher.Write([]byte(challenge + n.LocalNode.ID))
+	hasher.Write([]byte(challenge + nodeID))
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-// sendMessageToPeer sends a message to a specific peer
+// sendMessageToPeer sends a message to a specific peer. data must be the
+// payload type the protocol defines for msgType.
 func (n *TCPNetwork) sendMessageToPeer(peer *TCPPeer, msgType MessageType, data interface{}) error {
-	dataBytes, err := json.Marshal(data)
+	dataBytes, err := EncodePayload(msgType, data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %v", err)
 	}
@@ -375,21 +425,9 @@ func (n *TCPNetwork) writeMessageToPeer(peer *TCPPeer, msg *TCPMessage) error {
 	peer.writeMutex.Lock()
 	defer peer.writeMutex.Unlock()
 
-	// Serialize message
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %v", err)
-	}
-
-	// Write message length first (4 bytes)
-	length := uint32(len(msgBytes))
-	if err := binary.Write(peer.Writer, binary.BigEndian, length); err != nil {
-		return fmt.Errorf("failed to write message length: %v", err)
-	}
-
-	// Write message data
-	if _, err := peer.Writer.Write(msgBytes); err != nil {
-		return fmt.Errorf("failed to write message data: %v", err)
+	// Write the length-prefixed frame
+	if err := WriteFrame(peer.Writer, msg); err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
 	}
 
 	// Flush buffer
@@ -402,30 +440,11 @@ func (n *TCPNetwork) writeMessageToPeer(peer *TCPPeer, msg *TCPMessage) error {
 
 // readMessageFromPeer reads a TCP message from a peer
 func (n *TCPNetwork) readMessageFromPeer(peer *TCPPeer) (*TCPMessage, error) {
-	// Read message length first (4 bytes)
-	var length uint32
-	if err := binary.Read(peer.Reader, binary.BigEndian, &length); err != nil {
-		return nil, fmt.Errorf("failed to read message length: %v", err)
-	}
-
-	// Validate length
-	if length == 0 || length > 10*1024*1024 { // Max 10MB message
-		return nil, fmt.Errorf("invalid message length: %d", length)
-	}
-
-	// Read message data
-	msgBytes := make([]byte, length)
-	if _, err := io.ReadFull(peer.Reader, msgBytes); err != nil {
-		return nil, fmt.Errorf("failed to read message data: %v", err)
-	}
-
-	// Deserialize message
-	var msg TCPMessage
-	if err := json.Unmarshal(msgBytes, &msg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal message: %v", err)
+	msg, err := ReadFrame(peer.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
 	}
-
-	return &msg, nil
+	return msg, nil
 }
 
 // handlePeerConnection handles messages from a connected peer
@@ -437,10 +456,11 @@ func (n *TCPNetwork) handlePeerConnection(peer *TCPPeer) {
 		delete(n.Peers, peer.ID)
 		delete(n.connections, peer.ID)
 		n.mu.Unlock()
+		n.clock.forget(peer.ID)
 		fmt.Printf("🔌 Disconnected from peer: %s\n", peer.ID)
 	}()
 
-	for peer.Connected && n.running {
+	for peer.Connected && n.running.Load() {
 		// Set read timeout
 		peer.Connection.SetReadDeadline(time.Now().Add(60 * time.Second))
 
@@ -506,9 +526,9 @@ func (n *TCPNetwork) checkPeerHealth() {
 
 // pingPeer sends a ping to a peer
 func (n *TCPNetwork) pingPeer(peer *TCPPeer) {
-	pingData := map[string]interface{}{
-		"timestamp": time.Now(),
-		"node_id":   n.LocalNode.ID,
+	pingData := PingPayload{
+		NodeID:    n.LocalNode.ID,
+		Timestamp: time.Now(),
 	}
 
 	if err := n.sendMessageToPeer(peer, MessageTypePing, pingData); err != nil {
@@ -546,46 +566,106 @@ func (n *TCPNetwork) registerDefaultHandlers() {
 	n.messageHandlers[MessageTypeFileRequest] = n.handleFileRequest
 	n.messageHandlers[MessageTypeChunkRequest] = n.handleChunkRequest
 	n.messageHandlers[MessageTypeBroadcast] = n.handleBroadcast
-	n.messageHandlers[MessageTypeNodeDiscovery] = n.handleNodeDiscovery
+	n.messageHandlers[MessageTypeNodeDiscovery] = n.handlePeerExchange
+	n.messageHandlers[MessageTypePeerExchange] = n.handlePeerExchange
+	n.messageHandlers[MessageTypeRegister] = n.handleRegister
+	n.messageHandlers[MessageTypeNodeAnnouncement] = n.handleRegister
+	n.messageHandlers[MessageTypeRequest] = n.handleTunnelRequest
+	n.messageHandlers[MessageTypeResponse] = n.handleTunnelResponse
 }
 
 // Message handlers
 func (n *TCPNetwork) handlePing(peer *TCPPeer, msg *TCPMessage) error {
-	pongData := map[string]interface{}{
-		"timestamp": time.Now(),
-		"node_id":   n.LocalNode.ID,
+	pongData := PingPayload{
+		NodeID:    n.LocalNode.ID,
+		Timestamp: time.Now(),
 	}
 	return n.sendMessageToPeer(peer, MessageTypePong, pongData)
 }
 
 func (n *TCPNetwork) handlePong(peer *TCPPeer, msg *TCPMessage) error {
+	if _, err := msg.DecodePayload(); err != nil {
+		return err
+	}
 	peer.LastPing = time.Now()
 	peer.Status = "online"
 	return nil
 }
 
 func (n *TCPNetwork) handleFileRequest(peer *TCPPeer, msg *TCPMessage) error {
+	payload, err := msg.DecodePayload()
+	if err != nil {
+		return err
+	}
 	// Handle file requests - to be implemented based on file distributor integration
-	fmt.Printf("📁 File request from %s\n", peer.ID)
+	fmt.Printf("📁 File request for %s from %s\n", payload.(*FileRequestPayload).FileID, peer.ID)
 	return nil
 }
 
 func (n *TCPNetwork) handleChunkRequest(peer *TCPPeer, msg *TCPMessage) error {
+	payload, err := msg.DecodePayload()
+	if err != nil {
+		return err
+	}
 	// Handle chunk requests - to be implemented based on chunk distributor integration
-	fmt.Printf("🧩 Chunk request from %s\n", peer.ID)
+	fmt.Printf("🧩 Chunk request for %s from %s\n", payload.(*ChunkRequestPayload).ChunkID, peer.ID)
 	return nil
 }
 
 func (n *TCPNetwork) handleBroadcast(peer *TCPPeer, msg *TCPMessage) error {
+	if _, err := msg.DecodePayload(); err != nil {
+		return err
+	}
 	fmt.Printf("📡 Broadcast message from %s\n", peer.ID)
 	return nil
 }
 
-func (n *TCPNetwork) handleNodeDiscovery(peer *TCPPeer, msg *TCPMessage) error {
-	fmt.Printf("🔍 Node discovery from %s\n", peer.ID)
+// handleRegister records the files and chunks a peer reports when registering or announcing
+func (n *TCPNetwork) handleRegister(peer *TCPPeer, msg *TCPMessage) error {
+	payload, err := msg.DecodePayload()
+	if err != nil {
+		return err
+	}
+	info := payload.(*RegisterPayload)
+
+	n.mu.Lock()
+	if info.Port > 0 {
+		peer.Port = info.Port
+	}
+	peer.Files = info.Files
+	peer.Chunks = info.Chunks
+	peer.LastSeen = time.Now()
+	n.mu.Unlock()
+
+	fmt.Printf("📋 Peer %s registered with %d files and %d chunks\n", peer.ID, len(info.Files), len(info.Chunks))
 	return nil
 }
 
+// handlePeerExchange answers an empty peer list with the peers this node knows about
+func (n *TCPNetwork) handlePeerExchange(peer *TCPPeer, msg *TCPMessage) error {
+	payload, err := msg.DecodePayload()
+	if err != nil {
+		return err
+	}
+	exchange := payload.(*PeerExchangePayload)
+
+	if len(exchange.Peers) > 0 {
+		fmt.Printf("🔍 Peer %s shared %d peers\n", peer.ID, len(exchange.Peers))
+		return nil
+	}
+
+	known := make([]PeerInfo, 0)
+	for _, p := range n.GetConnectedPeers() {
+		if p.ID != peer.ID {
+			known = append(known, PeerInfo{ID: p.ID, Address: p.Address, Port: p.Port})
+		}
+	}
+	if len(known) == 0 {
+		return nil // Replying with an empty list would read as another request
+	}
+	return n.sendMessageToPeer(peer, MessageTypePeerExchange, PeerExchangePayload{Peers: known})
+}
+
 // GetConnectedPeers returns all connected peers
 func (n *TCPNetwork) GetConnectedPeers() []*TCPPeer {
 	n.mu.RLock()
@@ -0,0 +1,151 @@
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNodeUnreachable is returned by MemoryTransport for addresses with no attached node
+var ErrNodeUnreachable = errors.New("node unreachable")
+
+// SetTransport routes this node's requests to peers through transport; nil
+// restores real sockets, dialed through the host name resolver. Set it before
+// the node sends anything.
+func (n *Network) SetTransport(transport http.RoundTripper) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.transport = transport
+}
+
+// Client returns an HTTP client for requests to peers that goes through the
+// network's transport
+func (n *Network) Client(timeout time.Duration) *http.Client {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	transport := n.transport
+	if transport == nil {
+		transport = n.sockets
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Handler returns the node's P2P endpoints, as served by Start
+func (n *Network) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", n.HandlePing)
+	mux.HandleFunc("/pong", n.HandlePong)
+	mux.HandleFunc("/register", n.HandleRegister)
+	mux.HandleFunc("/peers", n.HandleGetPeers)
+	mux.HandleFunc("/file-request", n.HandleFileRequest)
+	mux.HandleFunc("/chunk-request", n.HandleChunkRequest)
+	mux.HandleFunc("/heartbeat", n.HandleHeartbeat)
+	return mux
+}
+
+// MemoryTransport connects nodes inside one process without sockets. Each
+// request runs the target node's handler synchronously on the caller's
+// goroutine, so multi-node scenarios play out deterministically. Detaching a
+// node makes it unreachable, as if it had crashed.
+type MemoryTransport struct {
+	mu    sync.RWMutex
+	nodes map[string]http.Handler // By host:port
+}
+
+// NewMemoryTransport creates an in-memory transport with no nodes attached
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{nodes: make(map[string]http.Handler)}
+}
+
+// Attach serves requests for node's address with handler and routes the
+// node's own requests through the transport
+func (t *MemoryTransport) Attach(network *Network, handler http.Handler) {
+	t.mu.Lock()
+	t.nodes[network.LocalNode.HostPort()] = handler
+	t.mu.Unlock()
+	network.SetTransport(t)
+}
+
+// Detach makes the node unreachable; requests to it fail with ErrNodeUnreachable
+func (t *MemoryTransport) Detach(network *Network) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.nodes, network.LocalNode.HostPort())
+}
+
+// RoundTrip delivers req to the attached node and returns its buffered response
+func (t *MemoryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	handler, ok := t.nodes[req.URL.Host]
+	t.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNodeUnreachable, req.URL.Host)
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	// The handler gets its own copy of the body, as it would over a socket
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	inbound := req.Clone(req.Context())
+	inbound.Body = io.NopCloser(bytes.NewReader(body))
+	inbound.ContentLength = int64(len(body))
+	inbound.RequestURI = req.URL.RequestURI()
+	inbound.RemoteAddr = "memory"
+	inbound.Host = req.URL.Host
+
+	w := &memoryResponse{header: make(http.Header)}
+	handler.ServeHTTP(w, inbound)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(w.status) + " " + http.StatusText(w.status),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          io.NopCloser(bytes.NewReader(w.body.Bytes())),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// memoryResponse buffers a handler's response for MemoryTransport
+type memoryResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *memoryResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *memoryResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *memoryResponse) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(p)
+}
@@ -0,0 +1,291 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Transports a node can be reached over, as advertised in Node.Transports
+const (
+	TransportHTTP = "http"
+	TransportTCP  = "tcp"
+)
+
+// DefaultTunnelTimeout bounds a request carried over the TCP transport when
+// its context has no deadline
+const DefaultTunnelTimeout = 60 * time.Second
+
+// TransportEndpoint is a transport a node accepts connections on
+type TransportEndpoint struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+// TransportPort returns the port a node accepts the named transport on. A
+// node that advertises no transports is reachable over HTTP on Port only.
+func (n *Node) TransportPort(name string) (int, bool) {
+	if len(n.Transports) == 0 {
+		return n.Port, name == TransportHTTP
+	}
+	for _, endpoint := range n.Transports {
+		if endpoint.Name == name {
+			if name == TransportHTTP && endpoint.Port == 0 {
+				return n.Port, true
+			}
+			return endpoint.Port, true
+		}
+	}
+	return 0, false
+}
+
+// AdvertiseTransport announces to peers that this node also accepts the
+// named transport on port. HTTP on LocalNode.Port is always advertised.
+func (n *Network) AdvertiseTransport(name string, port int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	endpoints := []TransportEndpoint{{Name: TransportHTTP}}
+	for _, endpoint := range n.LocalNode.Transports {
+		if endpoint.Name != name && endpoint.Name != TransportHTTP {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if name != TransportHTTP {
+		endpoints = append(endpoints, TransportEndpoint{Name: name, Port: port})
+	}
+	n.LocalNode.Transports = endpoints
+}
+
+// TunnelRequestPayload carries an HTTP request to a peer over the TCP
+// transport, so the peer's HTTP handlers serve it when its HTTP port is
+// unreachable.
+//
+// Layout (big-endian): metaLen u32 | meta (JSON of the other fields) | body
+type TunnelRequestPayload struct {
+	RequestID string      `json:"request_id"`
+	Method    string      `json:"method"`
+	Path      string      `json:"path"` // Path and query
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"-"`
+}
+
+// TunnelResponsePayload answers a tunnelled request; same layout as the request
+type TunnelResponsePayload struct {
+	RequestID string      `json:"request_id"`
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"-"`
+}
+
+// MarshalBinary encodes the request, keeping the body out of JSON
+func (p TunnelRequestPayload) MarshalBinary() ([]byte, error) {
+	return marshalTunnel(p, p.Body)
+}
+
+// UnmarshalBinary decodes a tunnelled request
+func (p *TunnelRequestPayload) UnmarshalBinary(data []byte) error {
+	body, err := unmarshalTunnel(data, p)
+	p.Body = body
+	return err
+}
+
+// MarshalBinary encodes the response, keeping the body out of JSON
+func (p TunnelResponsePayload) MarshalBinary() ([]byte, error) {
+	return marshalTunnel(p, p.Body)
+}
+
+// UnmarshalBinary decodes a tunnelled response
+func (p *TunnelResponsePayload) UnmarshalBinary(data []byte) error {
+	body, err := unmarshalTunnel(data, p)
+	p.Body = body
+	return err
+}
+
+func marshalTunnel(meta interface{}, body []byte) ([]byte, error) {
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 4+len(encoded)+len(body)))
+	binary.Write(buf, binary.BigEndian, uint32(len(encoded)))
+	buf.Write(encoded)
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+func unmarshalTunnel(data []byte, meta interface{}) ([]byte, error) {
+	r := &frameReader{data: data}
+	encoded := r.bytes(int(r.uint32()))
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: tunnel payload truncated", ErrMalformedFrame)
+	}
+	if err := json.Unmarshal(encoded, meta); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), data[r.pos:]...), nil
+}
+
+// SetRequestHandler serves HTTP requests peers send over the TCP transport,
+// usually the same P2P endpoints the HTTP server has
+func (n *TCPNetwork) SetRequestHandler(handler http.Handler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.requestHandler = handler
+}
+
+// handleTunnelRequest runs a tunnelled request through the request handler
+// and sends the response back on the same connection
+func (n *TCPNetwork) handleTunnelRequest(peer *TCPPeer, msg *TCPMessage) error {
+	payload, err := msg.DecodePayload()
+	if err != nil {
+		return err
+	}
+	request := payload.(*TunnelRequestPayload)
+	reply := TunnelResponsePayload{RequestID: request.RequestID, Status: http.StatusNotFound}
+
+	n.mu.RLock()
+	handler := n.requestHandler
+	n.mu.RUnlock()
+	if handler != nil {
+		req, err := http.NewRequest(request.Method, "http://"+HostPort(n.LocalNode.Address, n.LocalNode.Port)+request.Path, bytes.NewReader(request.Body))
+		if err != nil {
+			reply.Status = http.StatusBadRequest
+		} else {
+			req.Header = request.Header
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			req.RemoteAddr = peer.Connection.RemoteAddr().String()
+			w := &memoryResponse{header: make(http.Header)}
+			handler.ServeHTTP(w, req)
+			if w.status == 0 {
+				w.status = http.StatusOK
+			}
+			reply.Status, reply.Header, reply.Body = w.status, w.header, w.body.Bytes()
+		}
+	}
+	return n.sendMessageToPeer(peer, MessageTypeResponse, reply)
+}
+
+// handleTunnelResponse hands a response to the request waiting for it
+func (n *TCPNetwork) handleTunnelResponse(peer *TCPPeer, msg *TCPMessage) error {
+	payload, err := msg.DecodePayload()
+	if err != nil {
+		return err
+	}
+	response := payload.(*TunnelResponsePayload)
+
+	n.pendingMu.Lock()
+	waiting, ok := n.pending[response.RequestID]
+	delete(n.pending, response.RequestID)
+	n.pendingMu.Unlock()
+	if ok {
+		waiting <- response
+	}
+	return nil
+}
+
+// dialPeer returns the connection this node opened to hostPort, connecting
+// when there is none
+func (n *TCPNetwork) dialPeer(hostPort string) (*TCPPeer, error) {
+	n.mu.RLock()
+	peer, ok := n.dialed[hostPort]
+	n.mu.RUnlock()
+	if ok && peer.Connected {
+		return peer, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %s", hostPort)
+	}
+	peer, err = n.ConnectToPeer(host, port)
+	if err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	n.dialed[hostPort] = peer
+	n.mu.Unlock()
+	return peer, nil
+}
+
+// RoundTrip carries an HTTP request to the peer listening for TCP at the
+// request URL's host and returns its response, making the TCP transport a
+// drop-in http.RoundTripper.
+func (n *TCPNetwork) RoundTrip(req *http.Request) (*http.Response, error) {
+	peer, err := n.dialPeer(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	request := TunnelRequestPayload{
+		RequestID: uuid.New().String(),
+		Method:    req.Method,
+		Path:      req.URL.RequestURI(),
+		Header:    req.Header,
+		Body:      body,
+	}
+
+	waiting := make(chan *TunnelResponsePayload, 1)
+	n.pendingMu.Lock()
+	n.pending[request.RequestID] = waiting
+	n.pendingMu.Unlock()
+	defer func() {
+		n.pendingMu.Lock()
+		delete(n.pending, request.RequestID)
+		n.pendingMu.Unlock()
+	}()
+
+	if err := n.sendMessageToPeer(peer, MessageTypeRequest, request); err != nil {
+		// The connection is likely gone; the next request dials again
+		n.mu.Lock()
+		delete(n.dialed, req.URL.Host)
+		n.mu.Unlock()
+		return nil, err
+	}
+
+	timeout := time.NewTimer(DefaultTunnelTimeout)
+	defer timeout.Stop()
+	select {
+	case response := <-waiting:
+		if response.Header == nil {
+			response.Header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        strconv.Itoa(response.Status) + " " + http.StatusText(response.Status),
+			StatusCode:    response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        response.Header,
+			Body:          io.NopCloser(bytes.NewReader(response.Body)),
+			ContentLength: int64(len(response.Body)),
+			Request:       req,
+		}, nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-timeout.C:
+		return nil, fmt.Errorf("no response from %s over tcp within %v", req.URL.Host, DefaultTunnelTimeout)
+	}
+}
@@ -0,0 +1,300 @@
+package parallelism
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// Config bounds the concurrency the controller may choose and how it probes
+type Config struct {
+	Enabled      bool    // When false the limit stays at Max
+	Min          int     // Fewest concurrent fetches
+	Max          int     // Most concurrent fetches
+	Initial      int     // Limit before anything has been measured
+	Window       int     // Fetches measured before each adjustment
+	Tolerance    float64 // Relative throughput change treated as noise
+	MaxErrorRate float64 // Share of failed fetches in a window that halves the limit
+}
+
+// DefaultConfig starts at 4 concurrent fetches and moves between 1 and 16
+func DefaultConfig() Config {
+	return Config{
+		Min:          1,
+		Max:          16,
+		Initial:      4,
+		Window:       16,
+		Tolerance:    0.05,
+		MaxErrorRate: 0.05,
+	}
+}
+
+// ConfigFromConfig reads the concurrency bounds from the app config
+func ConfigFromConfig(cfg *config.AppConfig) Config {
+	c := DefaultConfig()
+	if cfg == nil {
+		return c
+	}
+	c.Enabled = cfg.AdaptiveParallelism
+	if cfg.ParallelismMin > 0 {
+		c.Min = cfg.ParallelismMin
+	}
+	if cfg.ParallelismMax > 0 {
+		c.Max = cfg.ParallelismMax
+	}
+	return c
+}
+
+func (c Config) normalize() Config {
+	def := DefaultConfig()
+	if c.Min < 1 {
+		c.Min = 1
+	}
+	if c.Max < c.Min {
+		c.Max = max(c.Min, def.Max)
+	}
+	if c.Initial <= 0 {
+		c.Initial = def.Initial
+	}
+	c.Initial = min(max(c.Initial, c.Min), c.Max)
+	if c.Window <= 0 {
+		c.Window = def.Window
+	}
+	if c.Tolerance <= 0 {
+		c.Tolerance = def.Tolerance
+	}
+	if c.MaxErrorRate <= 0 {
+		c.MaxErrorRate = def.MaxErrorRate
+	}
+	return c
+}
+
+// Stats reports the chosen concurrency and why it was chosen
+type Stats struct {
+	Enabled     bool            `json:"enabled"`
+	Limit       int             `json:"limit"`
+	Min         int             `json:"min"`
+	Max         int             `json:"max"`
+	InFlight    int             `json:"in_flight"`
+	Throughput  float64         `json:"throughput"` // Bytes per second over the last window
+	ErrorRate   float64         `json:"error_rate"` // Share of fetches that failed in the last window
+	Reason      string          `json:"reason"`
+	Adjustments int64           `json:"adjustments"`
+	ByLevel     map[int]float64 `json:"throughput_by_level"` // Last throughput measured at each limit
+}
+
+// Controller picks how many chunk fetches run at once. It measures the
+// throughput of each window of fetches and climbs toward the limit that moves
+// the most bytes: it keeps stepping while throughput rises, turns back when it
+// falls, drifts down while extra streams add nothing, and halves the limit when
+// fetches start failing. A nil *Controller never blocks.
+type Controller struct {
+	cfg            Config
+	limit          int
+	inFlight       int
+	direction      int // +1 while probing upward, -1 downward
+	windowStart    time.Time
+	windowBytes    int64
+	windowDone     int
+	windowFailed   int
+	lastThroughput float64
+	lastErrorRate  float64
+	reason         string
+	adjustments    int64
+	byLevel        map[int]float64
+	changed        chan struct{} // Closed and replaced whenever a fetch finishes or the limit moves
+	now            func() time.Time
+	mu             sync.Mutex
+}
+
+// New creates a controller; a disabled one holds the limit at cfg.Max
+func New(cfg Config) *Controller {
+	c := &Controller{byLevel: make(map[int]float64), changed: make(chan struct{}), now: time.Now}
+	c.SetConfig(cfg)
+	return c
+}
+
+var global = New(Config{})
+
+// Global returns the process-wide controller shared by chunk fetches
+func Global() *Controller {
+	return global
+}
+
+// Configure sets the configuration of the process-wide controller
+func Configure(cfg Config) {
+	global.SetConfig(cfg)
+}
+
+// SetConfig changes the bounds and restarts measurement from the initial limit
+func (c *Controller) SetConfig(cfg Config) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg.normalize()
+	c.direction = 1
+	c.lastThroughput, c.lastErrorRate = 0, 0
+	c.byLevel = make(map[int]float64)
+	c.resetWindowLocked()
+	if c.cfg.Enabled {
+		c.limit = c.cfg.Initial
+		c.reason = fmt.Sprintf("starting at %d until throughput is measured", c.limit)
+	} else {
+		c.limit = c.cfg.Max
+		c.reason = fmt.Sprintf("adaptive parallelism disabled; fixed at %d", c.limit)
+	}
+	c.notifyLocked()
+}
+
+// Limit returns the number of fetches currently allowed at once
+func (c *Controller) Limit() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// Acquire waits for a fetch slot. The returned func must be called once with
+// the bytes fetched and the fetch's error, which feed the next adjustment.
+func (c *Controller) Acquire(ctx context.Context) (func(bytes int64, err error), error) {
+	if c == nil {
+		return func(int64, error) {}, nil
+	}
+	c.mu.Lock()
+	for c.inFlight >= c.limit {
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	c.inFlight++
+	if c.windowStart.IsZero() {
+		c.windowStart = c.now()
+	}
+	c.mu.Unlock()
+
+	var once sync.Once
+	return func(bytes int64, err error) {
+		once.Do(func() { c.finish(bytes, err) })
+	}, nil
+}
+
+// finish records one fetch and adjusts the limit at the end of a window
+func (c *Controller) finish(bytes int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	c.windowDone++
+	if err != nil {
+		c.windowFailed++
+	} else {
+		c.windowBytes += bytes
+	}
+	if c.windowDone >= c.cfg.Window {
+		c.adjustLocked()
+	}
+	c.notifyLocked()
+}
+
+// adjustLocked moves the limit one step based on the window just finished
+func (c *Controller) adjustLocked() {
+	elapsed := c.now().Sub(c.windowStart).Seconds()
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(c.windowBytes) / elapsed
+	}
+	errorRate := float64(c.windowFailed) / float64(c.windowDone)
+	level := c.limit
+	c.byLevel[level] = throughput
+	c.resetWindowLocked()
+	if !c.cfg.Enabled {
+		c.lastThroughput, c.lastErrorRate = throughput, errorRate
+		return
+	}
+
+	next := level
+	switch {
+	case errorRate > c.cfg.MaxErrorRate:
+		// Failures mean the link or peers are overloaded; back off hard, then probe up again
+		next = max(c.cfg.Min, level/2)
+		c.direction = 1
+		c.reason = fmt.Sprintf("%.0f%% of fetches failed at %d; halved to %d", errorRate*100, level, next)
+	case c.lastThroughput == 0:
+		next = level + c.direction
+		c.reason = fmt.Sprintf("measured %s at %d; probing %d", rate(throughput), level, next)
+	case throughput > c.lastThroughput*(1+c.cfg.Tolerance):
+		next = level + c.direction
+		c.reason = fmt.Sprintf("throughput rose to %s at %d; continuing to %d", rate(throughput), level, next)
+	case throughput < c.lastThroughput*(1-c.cfg.Tolerance):
+		c.direction = -c.direction
+		next = level + c.direction
+		c.reason = fmt.Sprintf("throughput fell to %s at %d; turning back to %d", rate(throughput), level, next)
+	default:
+		// Streams that add nothing only cost memory and peer load
+		c.direction = -1
+		next = level - 1
+		c.reason = fmt.Sprintf("throughput flat at %s at %d; trying %d", rate(throughput), level, next)
+	}
+
+	if bounded := min(max(next, c.cfg.Min), c.cfg.Max); bounded != next {
+		// At a bound; probe the other way next time
+		next = bounded
+		c.direction = -c.direction
+		c.reason = fmt.Sprintf("%s at %d, the configured bound; holding", rate(throughput), level)
+	}
+	if next != level {
+		c.adjustments++
+	}
+	c.limit = next
+	c.lastThroughput, c.lastErrorRate = throughput, errorRate
+}
+
+func (c *Controller) resetWindowLocked() {
+	c.windowStart = time.Time{}
+	c.windowBytes, c.windowDone, c.windowFailed = 0, 0, 0
+}
+
+func (c *Controller) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Stats returns a snapshot of the current limit and the measurements behind it
+func (c *Controller) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := Stats{
+		Enabled:     c.cfg.Enabled,
+		Limit:       c.limit,
+		Min:         c.cfg.Min,
+		Max:         c.cfg.Max,
+		InFlight:    c.inFlight,
+		Throughput:  c.lastThroughput,
+		ErrorRate:   c.lastErrorRate,
+		Reason:      c.reason,
+		Adjustments: c.adjustments,
+		ByLevel:     make(map[int]float64, len(c.byLevel)),
+	}
+	for level, throughput := range c.byLevel {
+		stats.ByLevel[level] = throughput
+	}
+	return stats
+}
+
+func rate(bytesPerSecond float64) string {
+	return fmt.Sprintf("%.2f MB/s", bytesPerSecond/(1024*1024))
+}
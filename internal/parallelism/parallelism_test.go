@@ -0,0 +1,119 @@
+package parallelism
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// simulatedLink moves perStream bytes a second on each stream up to capacity
+// streams, and fails a quarter of fetches once more than overload run at once
+type simulatedLink struct {
+	perStream float64
+	capacity  int
+	overload  int
+}
+
+// runWindows drives the controller through n windows of fetches on a virtual
+// clock and returns the limit chosen after each
+func runWindows(t *testing.T, c *Controller, clock *time.Time, link simulatedLink, n int) []int {
+	t.Helper()
+	const chunk = 1 << 20
+	limits := make([]int, 0, n)
+	for w := 0; w < n; w++ {
+		level := c.Limit()
+		streams := float64(min(level, link.capacity))
+		for i := 0; i < c.cfg.Window; i++ {
+			done, err := c.Acquire(context.Background())
+			if err != nil {
+				t.Fatalf("Acquire failed: %v", err)
+			}
+			*clock = clock.Add(time.Duration(float64(chunk) / (link.perStream * streams) * float64(time.Second)))
+			if link.overload > 0 && level > link.overload && i%4 == 0 {
+				done(0, errors.New("connection reset"))
+			} else {
+				done(chunk, nil)
+			}
+		}
+		limits = append(limits, c.Limit())
+	}
+	return limits
+}
+
+func within(limits []int, low, high int) bool {
+	for _, limit := range limits {
+		if limit < low || limit > high {
+			return false
+		}
+	}
+	return true
+}
+
+func TestControllerAdaptsToChangingLinkConditions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Max = 32
+	c := New(cfg)
+	clock := time.Now()
+	c.now = func() time.Time { return clock }
+
+	// A link that saturates at 8 streams: the controller climbs and settles around
+	// 8, probing a step or two either side since the difference is near the tolerance
+	limits := runWindows(t, c, &clock, simulatedLink{perStream: 1 << 20, capacity: 8}, 40)
+	if settled := limits[len(limits)-10:]; !within(settled, 6, 9) {
+		t.Fatalf("expected the limit to settle around 8 streams, got %v", limits)
+	}
+
+	// More bandwidth appears: it climbs to use it
+	limits = runWindows(t, c, &clock, simulatedLink{perStream: 1 << 20, capacity: 20}, 60)
+	if settled := limits[len(limits)-10:]; !within(settled, 17, 21) {
+		t.Fatalf("expected the limit to follow capacity up to 20, got %v", limits)
+	}
+
+	// Peers start dropping fetches above 12 streams: it backs off below that
+	limits = runWindows(t, c, &clock, simulatedLink{perStream: 1 << 20, capacity: 20, overload: 12}, 40)
+	if settled := limits[len(limits)-10:]; !within(settled, 1, 13) {
+		t.Fatalf("expected failures to keep the limit at or below 13, got %v", limits)
+	}
+
+	stats := c.Stats()
+	if !stats.Enabled || stats.Reason == "" || stats.Adjustments == 0 || len(stats.ByLevel) < 10 {
+		t.Errorf("expected the choice and its rationale reported, got %+v", stats)
+	}
+}
+
+func TestControllerRespectsBoundsAndBlocksAtTheLimit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Min, cfg.Max, cfg.Initial = 2, 3, 2
+	c := New(cfg)
+	clock := time.Now()
+	c.now = func() time.Time { return clock }
+
+	limits := runWindows(t, c, &clock, simulatedLink{perStream: 1 << 20, capacity: 100}, 10)
+	if !within(limits, 2, 3) {
+		t.Errorf("expected limits kept within 2..3, got %v", limits)
+	}
+	limits = runWindows(t, c, &clock, simulatedLink{perStream: 1 << 20, capacity: 100, overload: 1}, 5)
+	if !within(limits, 2, 3) {
+		t.Errorf("expected failures not to push the limit below 2, got %v", limits)
+	}
+
+	// Once every slot is taken the next fetch waits
+	c = New(Config{Max: 2})
+	first, _ := c.Acquire(context.Background())
+	c.Acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a third fetch to wait for a slot, got %v", err)
+	}
+	first(1, nil)
+	if _, err := c.Acquire(context.Background()); err != nil {
+		t.Errorf("expected a freed slot to be reusable, got %v", err)
+	}
+	if stats := c.Stats(); stats.Enabled || stats.Limit != 2 || stats.InFlight != 2 {
+		t.Errorf("expected a disabled controller fixed at its maximum, got %+v", stats)
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix marks files that are still being written; they are never
+// listed or served
+const tempPrefix = ".tmp-"
+
+// isTempFile reports whether a file name belongs to an unfinished write
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, tempPrefix)
+}
+
+// WriteFileAtomic writes data to a temp file next to path, fsyncs it and
+// renames it into place, so readers see either the old file or the complete
+// new one and a crash never leaves a truncated file at path.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomic(path, data, perm, nil)
+}
+
+// writeFileAtomic is WriteFileAtomic with a hook that runs between the
+// synced write and the rename
+func writeFileAtomic(path string, data []byte, perm os.FileMode, beforeRename func(tmpPath string)) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, tempPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	// Cleanup is explicit rather than deferred: a crash must behave like one
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if beforeRename != nil {
+		beforeRename(tmpPath)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename into place: %w", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename durable by syncing the containing directory. It is
+// best effort: some platforms cannot sync directories, and the rename is
+// atomic either way.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,27 @@
+package storage
+
+import "fmt"
+
+// ResolveCapacity turns a configured capacity into bytes for the disk holding
+// dir: maxBytes caps it outright and maxPercent (0-100) as a share of the
+// disk's size. When both are set the smaller wins; neither means no limit (0).
+func ResolveCapacity(maxBytes int64, maxPercent float64, dir string) (int64, error) {
+	if maxPercent < 0 || maxPercent > 100 {
+		return 0, fmt.Errorf("capacity percentage must be between 0 and 100, got %v", maxPercent)
+	}
+	limit := maxBytes
+	if limit < 0 {
+		limit = 0
+	}
+	if maxPercent > 0 {
+		size, err := DiskSize(dir)
+		if err != nil {
+			return limit, fmt.Errorf("failed to apply capacity percentage: %w", err)
+		}
+		byPercent := int64(float64(size) * maxPercent / 100)
+		if limit == 0 || byPercent < limit {
+			limit = byPercent
+		}
+	}
+	return limit, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Storage modes, recorded in metadata when a store is first opened
+const (
+	ModeMutable = "mutable" // Chunks may be rewritten and deleted directly
+	ModeCAS     = "cas"     // Write-once chunks keyed by their content hash, deleted only once unreferenced
+)
+
+// ErrImmutableChunk is returned in CAS mode for a write that would change a
+// stored chunk and for deleting a chunk files still reference
+var ErrImmutableChunk = errors.New("chunk is immutable")
+
+// References reports whether any file or version still references a stored chunk
+type References func(id string) (bool, error)
+
+// ContentAddressable is implemented by backends that support CAS mode
+type ContentAddressable interface {
+	// SetContentAddressed makes chunks write-once. With refs set, Delete
+	// refuses chunks refs reports as still referenced.
+	SetContentAddressed(enabled bool, refs References)
+}
+
+// ValidateMode checks a configured storage mode; empty means ModeMutable
+func ValidateMode(mode string) error {
+	switch mode {
+	case "", ModeMutable, ModeCAS:
+		return nil
+	}
+	return fmt.Errorf("unknown storage mode %q, expected %q or %q", mode, ModeMutable, ModeCAS)
+}
+
+// checkUnreferenced refuses to delete a chunk refs reports as referenced
+func checkUnreferenced(refs References, id string) error {
+	if refs == nil {
+		return nil
+	}
+	referenced, err := refs(id)
+	if err != nil {
+		return fmt.Errorf("failed to count references to chunk %s: %w", id, err)
+	}
+	if referenced {
+		return fmt.Errorf("%w: %s is still referenced", ErrImmutableChunk, id)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// screenChunkSize matches the chunker's default chunk size
+const screenChunkSize = 4 << 20
+
+func screenFixture(tb testing.TB) ([]byte, string, uint32) {
+	tb.Helper()
+	data := make([]byte, screenChunkSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	store, err := NewLocalStorage(tb.TempDir())
+	if err != nil {
+		tb.Fatalf("failed to create storage: %v", err)
+	}
+	id, err := store.Put(bytes.NewReader(data))
+	if err != nil {
+		tb.Fatalf("failed to store chunk: %v", err)
+	}
+	return data, id, ChunkCRC(data)
+}
+
+func TestScreenChunkCatchesBitRot(t *testing.T) {
+	data, id, crc := screenFixture(t)
+
+	if result, err := ScreenChunkData(data, id, crc, false); err != nil || result != ScreenPassed {
+		t.Fatalf("expected an intact chunk to pass the CRC screen, got %q, %v", result, err)
+	}
+	if result, err := ScreenChunkData(data, id, crc, true); err != nil || result != ScreenVerified {
+		t.Errorf("expected a full check to hash the chunk, got %q, %v", result, err)
+	}
+	if result, err := ScreenChunkData(data, id, 0, false); err != nil || result != ScreenVerified {
+		t.Errorf("expected a chunk without a CRC to be hashed, got %q, %v", result, err)
+	}
+
+	// A single flipped bit fails the CRC and the hash confirms the corruption
+	rotted := append([]byte(nil), data...)
+	rotted[len(rotted)/2] ^= 0x10
+	if ChunkCRC(rotted) == crc {
+		t.Fatalf("expected the CRC to change with a flipped bit")
+	}
+	if _, err := ScreenChunkData(rotted, id, crc, false); !errors.Is(err, ErrChunkCorrupt) {
+		t.Errorf("expected the flipped bit reported as corruption, got %v", err)
+	}
+}
+
+// The CRC screen is the routine path; the full hash is what it replaces
+func BenchmarkScreenChunkCRC(b *testing.B) {
+	data, id, crc := screenFixture(b)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ScreenChunkData(data, id, crc, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScreenChunkSHA256(b *testing.B) {
+	data, id, crc := screenFixture(b)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ScreenChunkData(data, id, crc, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
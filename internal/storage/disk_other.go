@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || windows)
+
+package storage
+
+import "errors"
+
+// DiskSize is not available on this platform; capacity can only be set in bytes
+func DiskSize(path string) (int64, error) {
+	return 0, errors.New("disk size is not available on this platform")
+}
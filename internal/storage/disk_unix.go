@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+
+package storage
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// DiskSize returns the total size in bytes of the filesystem holding path
+func DiskSize(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, fmt.Errorf("failed to stat filesystem of %s: %w", path, err)
+	}
+	return int64(uint64(st.Blocks) * uint64(st.Bsize)), nil
+}
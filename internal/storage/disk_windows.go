@@ -0,0 +1,26 @@
+//go:build windows
+
+package storage
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// DiskSize returns the total size in bytes of the volume holding path
+func DiskSize(path string) (int64, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	ok, _, callErr := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(name)),
+		uintptr(unsafe.Pointer(&free)), uintptr(unsafe.Pointer(&total)), uintptr(unsafe.Pointer(&totalFree)))
+	if ok == 0 {
+		return 0, fmt.Errorf("failed to stat volume of %s: %w", path, callErr)
+	}
+	return int64(total), nil
+}
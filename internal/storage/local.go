@@ -7,11 +7,47 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
 )
 
+// WriteOptions controls how LocalStorage makes chunk writes durable
+type WriteOptions struct {
+	Verify     bool          // Re-read each chunk after writing and check its hash
+	Retries    int           // Extra attempts after a failed write or verification
+	RetryDelay time.Duration // Wait between attempts, doubled each time
+}
+
+// DefaultWriteOptions retries transient failures without re-reading every chunk
+func DefaultWriteOptions() WriteOptions {
+	return WriteOptions{Retries: 2, RetryDelay: 50 * time.Millisecond}
+}
+
+// WriteOptionsFromConfig builds write options from the application config
+func WriteOptionsFromConfig(cfg *config.AppConfig) WriteOptions {
+	opts := DefaultWriteOptions()
+	if cfg == nil {
+		return opts
+	}
+	opts.Verify = cfg.StorageVerifyWrites
+	if cfg.StorageWriteRetries >= 0 {
+		opts.Retries = cfg.StorageWriteRetries
+	}
+	if cfg.StorageRetryDelayMs > 0 {
+		opts.RetryDelay = time.Duration(cfg.StorageRetryDelayMs) * time.Millisecond
+	}
+	return opts
+}
+
 // LocalStorage implements the Storage interface for the local filesystem.
 type LocalStorage struct {
-	basePath string
+	basePath     string
+	writes       WriteOptions
+	beforeRename func(tmpPath string) // Test hook run between the synced write and the rename
+
+	cas  bool       // Chunks are write-once, see SetContentAddressed
+	refs References // Guards deletes in CAS mode, nil when the caller does
 }
 
 // NewLocalStorage creates a new LocalStorage instance.
@@ -19,10 +55,37 @@ func NewLocalStorage(basePath string) (*LocalStorage, error) {
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
-	return &LocalStorage{basePath: basePath}, nil
+	return &LocalStorage{basePath: basePath, writes: DefaultWriteOptions()}, nil
+}
+
+// BasePath returns the directory chunks are stored under
+func (s *LocalStorage) BasePath() string {
+	return s.basePath
+}
+
+// SetWriteOptions changes verification and retry behaviour for later writes
+func (s *LocalStorage) SetWriteOptions(opts WriteOptions) {
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	s.writes = opts
+}
+
+// SetContentAddressed switches CAS mode on or off. In CAS mode a chunk that
+// is already stored is never rewritten: putting identical content is a no-op
+// and putting content that differs from what is stored under its key fails
+// with ErrImmutableChunk.
+func (s *LocalStorage) SetContentAddressed(enabled bool, refs References) {
+	s.cas = enabled
+	s.refs = nil
+	if enabled {
+		s.refs = refs
+	}
 }
 
 // Put stores a chunk on the local filesystem. The filename is the SHA-256 hash of the content.
+// The chunk is written to a temp file, synced and renamed into place, so a
+// crash never leaves a partial chunk under its final name.
 func (s *LocalStorage) Put(chunkData io.Reader) (string, error) {
 	// Read the data into memory to calculate the hash
 	data, err := io.ReadAll(chunkData)
@@ -34,15 +97,60 @@ func (s *LocalStorage) Put(chunkData io.Reader) (string, error) {
 	hashStr := hex.EncodeToString(hash[:])
 	filePath := filepath.Join(s.basePath, hashStr)
 
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return "", fmt.Errorf("failed to write chunk to file: %w", err)
+	if s.cas {
+		stored, err := os.ReadFile(filePath)
+		if err == nil {
+			sum := sha256.Sum256(stored)
+			if hex.EncodeToString(sum[:]) != hashStr {
+				return "", fmt.Errorf("%w: %s is stored with different content", ErrImmutableChunk, hashStr)
+			}
+			return hashStr, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to check stored chunk %s: %w", hashStr, err)
+		}
 	}
 
-	return hashStr, nil
+	delay := s.writes.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err = s.writeChunk(filePath, hashStr, data)
+		if err == nil {
+			return hashStr, nil
+		}
+		if attempt >= s.writes.Retries {
+			return "", fmt.Errorf("failed to write chunk to file after %d attempts: %w", attempt+1, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// writeChunk makes one durable write attempt, verifying the result when configured
+func (s *LocalStorage) writeChunk(filePath, hashStr string, data []byte) error {
+	if err := writeFileAtomic(filePath, data, 0644, s.beforeRename); err != nil {
+		return err
+	}
+	if !s.writes.Verify {
+		return nil
+	}
+
+	written, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to re-read chunk for verification: %w", err)
+	}
+	sum := sha256.Sum256(written)
+	if hex.EncodeToString(sum[:]) != hashStr {
+		os.Remove(filePath)
+		return fmt.Errorf("chunk %s failed verification after write", hashStr)
+	}
+	return nil
 }
 
 // Get retrieves a chunk from the local filesystem.
 func (s *LocalStorage) Get(id string) (io.ReadCloser, error) {
+	if isTempFile(id) {
+		return nil, fmt.Errorf("chunk not found: %s", id)
+	}
 	filePath := filepath.Join(s.basePath, id)
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -54,7 +162,83 @@ func (s *LocalStorage) Get(id string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// Exists reports whether a chunk file is present under the base path.
+func (s *LocalStorage) Exists(id string) (bool, error) {
+	if isTempFile(id) {
+		return false, nil
+	}
+	info, err := os.Stat(filepath.Join(s.basePath, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check chunk %s: %w", id, err)
+	}
+	return info.Mode().IsRegular(), nil
+}
+
 // GetPath returns the file path for a given chunk identifier.
 func (s *LocalStorage) GetPath(id string) (string, error) {
 	return filepath.Join(s.basePath, id), nil
 }
+
+// ModTime returns when a chunk file was last written.
+func (s *LocalStorage) ModTime(id string) (time.Time, error) {
+	info, err := os.Stat(filepath.Join(s.basePath, id))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to stat chunk %s: %w", id, err)
+	}
+	return info.ModTime(), nil
+}
+
+// Usage returns the total size of all chunk files under the base path.
+func (s *LocalStorage) Usage() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(s.basePath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isTempFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute storage usage: %w", err)
+	}
+	return total, nil
+}
+
+// Delete removes a chunk from the local filesystem. Missing chunks are not an
+// error. In CAS mode chunks files still reference are refused.
+func (s *LocalStorage) Delete(id string) error {
+	if s.cas {
+		if err := checkUnreferenced(s.refs, id); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(filepath.Join(s.basePath, id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete chunk %s: %w", id, err)
+	}
+	return nil
+}
+
+// List returns the ids of all chunks stored under the base path.
+func (s *LocalStorage) List() ([]string, error) {
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !isTempFile(entry.Name()) {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageCrashBeforeRenameLeavesNoPartialChunk(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	data := []byte("chunk that never makes it")
+
+	// Crash after the data is written and synced but before the rename
+	var tmpPath string
+	store.beforeRename = func(path string) {
+		tmpPath = path
+		panic("simulated crash")
+	}
+	func() {
+		defer func() { recover() }()
+		store.Put(bytes.NewReader(data))
+	}()
+
+	if _, err := os.Stat(tmpPath); err != nil {
+		t.Fatalf("expected the crash to leave its temp file behind: %v", err)
+	}
+
+	// A restarted store must not see the unfinished write
+	restarted, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	ids, err := restarted.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("partial chunk is visible after crash: %v", ids)
+	}
+	if used, _ := restarted.Usage(); used != 0 {
+		t.Errorf("partial chunk counted in usage: %d bytes", used)
+	}
+	if _, err := restarted.Get(filepath.Base(tmpPath)); err == nil {
+		t.Errorf("temp file must not be served as a chunk")
+	}
+	if exists, err := restarted.Exists(filepath.Base(tmpPath)); exists || err != nil {
+		t.Errorf("temp file must not exist as a chunk, got %v, %v", exists, err)
+	}
+
+	id, err := restarted.Put(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Put after restart failed: %v", err)
+	}
+	if got := readAll(t, restarted, id); !bytes.Equal(got, data) {
+		t.Errorf("expected complete chunk after rewrite, got %q", got)
+	}
+	if exists, err := restarted.Exists(id); !exists || err != nil {
+		t.Errorf("expected the rewritten chunk to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestLocalStorageVerifyRetriesCorruptWrite(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	store.SetWriteOptions(WriteOptions{Verify: true, Retries: 1})
+
+	// The first write is torn; verification must catch it and the retry must fix it
+	attempts := 0
+	store.beforeRename = func(path string) {
+		attempts++
+		if attempts == 1 {
+			os.Truncate(path, 3)
+		}
+	}
+	data := []byte("chunk torn on its first write")
+	id, err := store.Put(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Put failed despite a retry: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected one retry, got %d attempts", attempts)
+	}
+	if got := readAll(t, store, id); !bytes.Equal(got, data) {
+		t.Errorf("stored chunk is corrupt: %q", got)
+	}
+
+	// Without retries the failed verification is reported
+	store.SetWriteOptions(WriteOptions{Verify: true})
+	store.beforeRename = func(path string) { os.Truncate(path, 3) }
+	if _, err := store.Put(bytes.NewReader([]byte("another torn chunk"))); err == nil || !strings.Contains(err.Error(), "verification") {
+		t.Errorf("expected a verification error, got %v", err)
+	}
+	if ids, _ := store.List(); len(ids) != 1 {
+		t.Errorf("corrupt chunk should not be kept, got %v", ids)
+	}
+}
+
+func TestLocalStorageCASRewritesAreIdempotentAndImmutable(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	referenced := map[string]bool{}
+	store.SetContentAddressed(true, func(id string) (bool, error) { return referenced[id], nil })
+
+	data := []byte("audited chunk")
+	id, err := store.Put(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	path, _ := store.GetPath(id)
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("chunk not stored: %v", err)
+	}
+
+	// Writing the same content again leaves the stored chunk untouched
+	again, err := store.Put(bytes.NewReader(data))
+	if err != nil || again != id {
+		t.Fatalf("expected an identical rewrite to return %s, got %s (%v)", id, again, err)
+	}
+	if after, _ := os.Stat(path); !after.ModTime().Equal(before.ModTime()) || !os.SameFile(before, after) {
+		t.Errorf("expected an identical rewrite not to touch the stored chunk")
+	}
+
+	// Content under the key that does not match it is never overwritten
+	if err := os.WriteFile(path, []byte("tampered chunk"), 0644); err != nil {
+		t.Fatalf("failed to tamper with chunk: %v", err)
+	}
+	if _, err := store.Put(bytes.NewReader(data)); !errors.Is(err, ErrImmutableChunk) {
+		t.Fatalf("expected a write over different content to be refused, got %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "tampered chunk" {
+		t.Errorf("expected the stored content kept for inspection, got %q", got)
+	}
+
+	// Only chunks nothing references may be deleted
+	referenced[id] = true
+	if err := store.Delete(id); !errors.Is(err, ErrImmutableChunk) {
+		t.Fatalf("expected deleting a referenced chunk to be refused, got %v", err)
+	}
+	referenced[id] = false
+	if err := store.Delete(id); err != nil {
+		t.Fatalf("expected an unreferenced chunk deleted: %v", err)
+	}
+
+	// Outside CAS mode the chunk is simply rewritten
+	store.SetContentAddressed(false, nil)
+	if err := os.WriteFile(path, []byte("tampered chunk"), 0644); err != nil {
+		t.Fatalf("failed to tamper with chunk: %v", err)
+	}
+	if _, err := store.Put(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := readAll(t, store, id); !bytes.Equal(got, data) {
+		t.Errorf("expected the mutable store to rewrite the chunk, got %q", got)
+	}
+}
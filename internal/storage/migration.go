@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// VerifyChunk re-reads a chunk from s and checks its bytes still hash to id,
+// the SHA-256 every backend stores chunks under.
+func VerifyChunk(s Storage, id string) error {
+	reader, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return fmt.Errorf("failed to read chunk %s: %w", id, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != id {
+		return fmt.Errorf("%w: %s does not match its hash: got %s", ErrChunkCorrupt, id, got)
+	}
+	return nil
+}
+
+// PendingMigration is a chunk move that started but has not removed its source copies yet
+type PendingMigration struct {
+	ID     string `json:"id"`
+	Target string `json:"target"` // Tier name
+}
+
+// MigrationJournal records chunk moves in progress in a file, so moves
+// interrupted by a crash can be finished after a restart.
+type MigrationJournal struct {
+	path    string
+	mu      sync.Mutex
+	pending map[string]string // chunk id -> target tier
+}
+
+// OpenMigrationJournal loads the journal at path, creating it on first write
+func OpenMigrationJournal(path string) (*MigrationJournal, error) {
+	j := &MigrationJournal{path: path, pending: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration journal: %w", err)
+	}
+	var entries []PendingMigration
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse migration journal: %w", err)
+	}
+	for _, entry := range entries {
+		j.pending[entry.ID] = entry.Target
+	}
+	return j, nil
+}
+
+// Pending returns the moves that have not finished, ordered by chunk id
+func (j *MigrationJournal) Pending() []PendingMigration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.entriesLocked()
+}
+
+func (j *MigrationJournal) entriesLocked() []PendingMigration {
+	entries := make([]PendingMigration, 0, len(j.pending))
+	for id, target := range j.pending {
+		entries = append(entries, PendingMigration{ID: id, Target: target})
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].ID < entries[b].ID })
+	return entries
+}
+
+// begin durably records a move before any copy is made
+func (j *MigrationJournal) begin(id, target string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.pending[id] = target
+	return j.saveLocked()
+}
+
+// finish removes a move once its source copies are gone or it was abandoned
+func (j *MigrationJournal) finish(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.pending[id]; !ok {
+		return nil
+	}
+	delete(j.pending, id)
+	return j.saveLocked()
+}
+
+func (j *MigrationJournal) saveLocked() error {
+	data, err := json.Marshal(j.entriesLocked())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	return WriteFileAtomic(j.path, data, 0644)
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/jaywantadh/DisktroByte/internal/compressor"
+	"github.com/jaywantadh/DisktroByte/internal/throttle"
 	"github.com/sirupsen/logrus"
 )
 
@@ -235,11 +237,7 @@ func (oe *OptimizationEngine) OptimizedPut(chunkData io.Reader) (string, *ChunkI
 	
 	// Check for deduplication
 	if oe.config.EnableDeduplication {
-		if existing := oe.findDuplicateChunk(hashStr, data); existing != nil {
-			existing.ReferenceCount++
-			existing.LastAccessedAt = time.Now()
-			existing.AccessCount++
-			
+		if existing := oe.addReference(hashStr); existing != nil {
 			oe.updateAnalytics("deduplication", len(data), time.Since(start))
 			oe.logger.Debugf("🔄 Deduplicated chunk %s (refs: %d)", hashStr, existing.ReferenceCount)
 			return hashStr, existing, nil
@@ -273,15 +271,24 @@ func (oe *OptimizationEngine) OptimizedPut(chunkData io.Reader) (string, *ChunkI
 	
 	// Store to filesystem
 	storagePath := filepath.Join(oe.basePath, hashStr)
-	if err := os.WriteFile(storagePath, finalData, 0644); err != nil {
+	if err := WriteFileAtomic(storagePath, finalData, 0644); err != nil {
 		return "", nil, fmt.Errorf("failed to write chunk: %v", err)
 	}
 	
 	chunkInfo.StoragePath = storagePath
 	
-	// Update indices
+	// Update indices; a concurrent put of the same content shares its entry
 	oe.chunksMu.Lock()
-	oe.chunks[hashStr] = chunkInfo
+	if existing, stored := oe.chunks[hashStr]; stored && oe.config.EnableDeduplication {
+		existing.ReferenceCount++
+		existing.IsDeduplicated = true
+		snapshot := *existing
+		chunkInfo = &snapshot
+	} else {
+		oe.chunks[hashStr] = chunkInfo
+		snapshot := *chunkInfo
+		chunkInfo = &snapshot
+	}
 	oe.chunksMu.Unlock()
 	
 	// Update deduplication index
@@ -352,29 +359,71 @@ func (oe *OptimizationEngine) OptimizedGet(hash string) (io.ReadCloser, *ChunkIn
 	return io.NopCloser(bytes.NewReader(data)), chunkInfo, nil
 }
 
-// findDuplicateChunk checks if a chunk already exists
-func (oe *OptimizationEngine) findDuplicateChunk(hash string, data []byte) *ChunkInfo {
-	oe.chunksMu.RLock()
-	defer oe.chunksMu.RUnlock()
-	
-	if chunk, exists := oe.chunks[hash]; exists {
-		return chunk
+// addReference takes another reference to the stored chunk with the given
+// hash and returns a snapshot of it, or nil when no such chunk is stored. The
+// lookup and the count happen under one lock, so a concurrent release cannot
+// remove the chunk in between.
+func (oe *OptimizationEngine) addReference(hash string) *ChunkInfo {
+	oe.chunksMu.Lock()
+	defer oe.chunksMu.Unlock()
+
+	chunk, exists := oe.chunks[hash]
+	if !exists {
+		return nil
 	}
-	
-	// Advanced content-based deduplication
-	contentHash := oe.calculateContentHash(data)
-	oe.dedupMu.RLock()
-	if dedupEntry, exists := oe.deduplicationIndex[contentHash]; exists {
-		for _, chunkHash := range dedupEntry.ChunkHashes {
-			if chunk, exists := oe.chunks[chunkHash]; exists {
-				oe.dedupMu.RUnlock()
-				return chunk
+	chunk.ReferenceCount++
+	chunk.IsDeduplicated = true
+	chunk.LastAccessedAt = time.Now()
+	chunk.AccessCount++
+	snapshot := *chunk
+	return &snapshot
+}
+
+// ReleaseChunk drops one reference to a stored chunk. The last reference
+// removes the chunk from disk, the cache and the deduplication index. It
+// returns the references left and whether the engine stored the chunk at all.
+func (oe *OptimizationEngine) ReleaseChunk(hash string) (int, bool, error) {
+	oe.chunksMu.Lock()
+	defer oe.chunksMu.Unlock()
+
+	chunk, exists := oe.chunks[hash]
+	if !exists {
+		return 0, false, nil
+	}
+	chunk.ReferenceCount--
+	if chunk.ReferenceCount > 0 {
+		return chunk.ReferenceCount, true, nil
+	}
+
+	if err := os.Remove(chunk.StoragePath); err != nil && !os.IsNotExist(err) {
+		chunk.ReferenceCount++
+		return 0, true, fmt.Errorf("failed to remove chunk %s: %v", hash, err)
+	}
+	delete(oe.chunks, hash)
+
+	oe.dedupMu.Lock()
+	for contentHash, entry := range oe.deduplicationIndex {
+		for i, chunkHash := range entry.ChunkHashes {
+			if chunkHash == hash {
+				entry.ChunkHashes = append(entry.ChunkHashes[:i], entry.ChunkHashes[i+1:]...)
+				break
 			}
 		}
+		if len(entry.ChunkHashes) == 0 {
+			delete(oe.deduplicationIndex, contentHash)
+		}
 	}
-	oe.dedupMu.RUnlock()
-	
-	return nil
+	oe.dedupMu.Unlock()
+
+	oe.cacheMu.Lock()
+	if entry, cached := oe.cache[hash]; cached {
+		oe.cacheSize -= entry.Size
+		delete(oe.cache, hash)
+	}
+	oe.cacheMu.Unlock()
+
+	oe.logger.Debugf("🗑️ Removed chunk %s, no references left", hash)
+	return 0, true, nil
 }
 
 // calculateContentHash calculates a content-based hash for deduplication
@@ -584,17 +633,24 @@ func (oe *OptimizationEngine) performOptimization() {
 	oe.logger.Info("🔧 Performing storage optimization...")
 	start := time.Now()
 	
+	// Each step yields to foreground load before the next one starts
+	step := func(run func()) {
+		stepStart := time.Now()
+		run()
+		throttle.Global().Yield(context.Background(), throttle.JobOptimization, time.Since(stepStart))
+	}
+	
 	// Clean up old analytics data
-	oe.cleanupAnalytics()
+	step(oe.cleanupAnalytics)
 	
 	// Optimize cache
-	oe.optimizeCache()
+	step(oe.optimizeCache)
 	
 	// Cleanup orphaned chunks
-	oe.cleanupOrphanedChunks()
+	step(oe.cleanupOrphanedChunks)
 	
 	// Update comprehensive analytics
-	oe.updateComprehensiveAnalytics()
+	step(oe.updateComprehensiveAnalytics)
 	
 	oe.analyticsMu.Lock()
 	oe.analytics.LastOptimization = time.Now()
@@ -783,7 +839,7 @@ func (oe *OptimizationEngine) saveChunkIndex() error {
 		return err
 	}
 	
-	return os.WriteFile(indexPath, data, 0644)
+	return WriteFileAtomic(indexPath, data, 0644)
 }
 
 // loadDeduplicationIndex loads the deduplication index from disk
@@ -815,7 +871,7 @@ func (oe *OptimizationEngine) saveDeduplicationIndex() error {
 		return err
 	}
 	
-	return os.WriteFile(indexPath, data, 0644)
+	return WriteFileAtomic(indexPath, data, 0644)
 }
 
 // GetAnalytics returns current storage analytics
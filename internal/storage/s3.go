@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// DefaultS3Region is signed into requests when none is configured; MinIO accepts it
+const DefaultS3Region = "us-east-1"
+
+// S3Options configures an S3Storage
+type S3Options struct {
+	Endpoint  string // host[:port] of the S3 API, e.g. s3.amazonaws.com or minio:9000
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Region    string // Empty uses DefaultS3Region
+	KeyPrefix string // Prepended to object keys so several nodes can share a bucket
+}
+
+// S3OptionsFromConfig builds S3 options from the application config
+func S3OptionsFromConfig(cfg *config.AppConfig) S3Options {
+	if cfg == nil {
+		return S3Options{}
+	}
+	return S3Options{
+		Endpoint:  cfg.S3Endpoint,
+		Bucket:    cfg.S3Bucket,
+		AccessKey: cfg.S3AccessKey,
+		SecretKey: cfg.S3SecretKey,
+		UseSSL:    cfg.S3UseSSL,
+		Region:    cfg.S3Region,
+		KeyPrefix: cfg.S3KeyPrefix,
+	}
+}
+
+// S3OptionsForTier builds the S3 options of a storage tier, taking the
+// settings the tier leaves empty from the application config
+func S3OptionsForTier(tier config.StorageTierConfig, cfg *config.AppConfig) S3Options {
+	opts := S3OptionsFromConfig(cfg)
+	overrides := []struct {
+		value  string
+		target *string
+	}{
+		{tier.S3Endpoint, &opts.Endpoint},
+		{tier.S3Bucket, &opts.Bucket},
+		{tier.S3AccessKey, &opts.AccessKey},
+		{tier.S3SecretKey, &opts.SecretKey},
+		{tier.S3Region, &opts.Region},
+		{tier.S3KeyPrefix, &opts.KeyPrefix},
+	}
+	for _, override := range overrides {
+		if override.value != "" {
+			*override.target = override.value
+		}
+	}
+	if tier.S3UseSSL != nil {
+		opts.UseSSL = *tier.S3UseSSL
+	}
+	return opts
+}
+
+// S3Storage implements the Storage interface on an S3-compatible object
+// store such as AWS S3 or MinIO. Chunks are objects named by the SHA-256 of
+// their content under the key prefix, addressed path-style.
+type S3Storage struct {
+	opts   S3Options
+	scheme string
+	client *http.Client
+	now    func() time.Time // Signing clock, replaced in tests
+}
+
+// NewS3Storage connects to an S3-compatible endpoint and creates the bucket
+// when it does not exist yet.
+func NewS3Storage(endpoint, bucket, accessKey, secretKey string, useSSL bool) (*S3Storage, error) {
+	return NewS3StorageWithOptions(S3Options{
+		Endpoint:  endpoint,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		UseSSL:    useSSL,
+	})
+}
+
+// NewS3StorageWithOptions works like NewS3Storage with a region and key prefix
+func NewS3StorageWithOptions(opts S3Options) (*S3Storage, error) {
+	opts.Endpoint = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(opts.Endpoint, "https://"), "http://"), "/")
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage needs an endpoint and a bucket")
+	}
+	if opts.Region == "" {
+		opts.Region = DefaultS3Region
+	}
+	opts.KeyPrefix = strings.TrimPrefix(opts.KeyPrefix, "/")
+	if opts.KeyPrefix != "" && !strings.HasSuffix(opts.KeyPrefix, "/") {
+		opts.KeyPrefix += "/"
+	}
+
+	s := &S3Storage{
+		opts:   opts,
+		scheme: "http",
+		client: &http.Client{Timeout: 60 * time.Second},
+		now:    time.Now,
+	}
+	if opts.UseSSL {
+		s.scheme = "https"
+	}
+	if err := s.ensureBucket(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// String names the bucket and prefix chunks are stored under
+func (s *S3Storage) String() string {
+	return fmt.Sprintf("s3 bucket %s/%s at %s", s.opts.Bucket, s.opts.KeyPrefix, s.opts.Endpoint)
+}
+
+// ensureBucket creates the bucket unless it already exists
+func (s *S3Storage) ensureBucket() error {
+	resp, err := s.do(http.MethodHead, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket %s: %w", s.opts.Bucket, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+	default:
+		return fmt.Errorf("failed to check bucket %s: status %d", s.opts.Bucket, resp.StatusCode)
+	}
+
+	// Outside us-east-1 the bucket's region has to be named
+	var body []byte
+	if s.opts.Region != DefaultS3Region {
+		body = []byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LocationConstraint>` +
+			s.opts.Region + `</LocationConstraint></CreateBucketConfiguration>`)
+	}
+	resp, err = s.do(http.MethodPut, "", nil, body)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", s.opts.Bucket, err)
+	}
+	defer resp.Body.Close()
+	// Another node sharing the bucket may have created it first
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return s3Error("create bucket "+s.opts.Bucket, resp)
+	}
+	return nil
+}
+
+// Put stores a chunk as an object named by the SHA-256 hash of its content.
+func (s *S3Storage) Put(chunkData io.Reader) (string, error) {
+	data, err := io.ReadAll(chunkData)
+	if err != nil {
+		return "", fmt.Errorf("failed to read chunk data: %w", err)
+	}
+	hash := sha256.Sum256(data)
+	hashStr := hex.EncodeToString(hash[:])
+
+	resp, err := s.do(http.MethodPut, s.opts.KeyPrefix+hashStr, nil, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload chunk %s: %w", hashStr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", s3Error("upload of chunk "+hashStr, resp)
+	}
+	return hashStr, nil
+}
+
+// Get retrieves a chunk object.
+func (s *S3Storage) Get(id string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, s.opts.KeyPrefix+id, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download chunk %s: %w", id, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("chunk not found: %s", id)
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error("download of chunk "+id, resp)
+	}
+}
+
+// GetPath fails: objects have no path on the local filesystem.
+func (s *S3Storage) GetPath(id string) (string, error) {
+	return "", fmt.Errorf("chunk %s is stored in s3 bucket %s and has no local path", id, s.opts.Bucket)
+}
+
+// Exists reports whether a chunk object is stored, with a HEAD request.
+func (s *S3Storage) Exists(id string) (bool, error) {
+	resp, err := s.do(http.MethodHead, s.opts.KeyPrefix+id, nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check chunk %s: %w", id, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check chunk %s: status %d", id, resp.StatusCode)
+	}
+}
+
+// ModTime returns when a chunk object was written, from the Last-Modified
+// header of a HEAD request.
+func (s *S3Storage) ModTime(id string) (time.Time, error) {
+	resp, err := s.do(http.MethodHead, s.opts.KeyPrefix+id, nil, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to check chunk %s: %w", id, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return time.Time{}, fmt.Errorf("chunk not found: %s", id)
+	default:
+		return time.Time{}, fmt.Errorf("failed to check chunk %s: status %d", id, resp.StatusCode)
+	}
+	modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("chunk %s has no valid Last-Modified header: %w", id, err)
+	}
+	return modified, nil
+}
+
+// Usage returns the total size of the chunk objects under the key prefix.
+func (s *S3Storage) Usage() (int64, error) {
+	var total int64
+	err := s.listObjects(func(_ string, size int64) {
+		total += size
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute storage usage: %w", err)
+	}
+	return total, nil
+}
+
+// Delete removes a chunk object. Missing chunks are not an error.
+func (s *S3Storage) Delete(id string) error {
+	resp, err := s.do(http.MethodDelete, s.opts.KeyPrefix+id, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete chunk %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error("delete of chunk "+id, resp)
+	}
+}
+
+// List returns the ids of all chunks under the key prefix.
+func (s *S3Storage) List() ([]string, error) {
+	ids := make([]string, 0)
+	err := s.listObjects(func(id string, _ int64) {
+		ids = append(ids, id)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	return ids, nil
+}
+
+// listObjectsResult is the part of a ListObjectsV2 response that is used
+type listObjectsResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// listObjects pages through the objects under the key prefix. Keys nested
+// further down belong to nodes with a longer prefix and are skipped.
+func (s *S3Storage) listObjects(visit func(id string, size int64)) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.opts.KeyPrefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error("listing of bucket "+s.opts.Bucket, resp)
+			resp.Body.Close()
+			return err
+		}
+		var page listObjectsResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse bucket listing: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			id := strings.TrimPrefix(object.Key, s.opts.KeyPrefix)
+			if id == "" || strings.Contains(id, "/") {
+				continue
+			}
+			visit(id, object.Size)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// do sends a signed path-style request for the bucket, or for key within it
+func (s *S3Storage) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := "/" + s.opts.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	target := &url.URL{Scheme: s.scheme, Host: s.opts.Endpoint, Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.ContentLength = int64(len(body))
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signV4(req, s.opts.AccessKey, s.opts.SecretKey, s.opts.Region, "s3", payloadHash, s.now())
+	return s.client.Do(req)
+}
+
+// s3ErrorResponse is the XML error body S3 returns
+type s3ErrorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// s3Error describes a failed request from its status and error body
+func s3Error(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var parsed s3ErrorResponse
+	if xml.Unmarshal(body, &parsed) == nil && parsed.Code != "" {
+		return fmt.Errorf("s3 %s failed with status %d: %s: %s", op, resp.StatusCode, parsed.Code, parsed.Message)
+	}
+	return fmt.Errorf("s3 %s failed with status %d", op, resp.StatusCode)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/storage/s3fake"
+)
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	fake := s3fake.New(t)
+	endpoint := fake.Endpoint()
+
+	store, err := NewS3StorageWithOptions(S3Options{Endpoint: endpoint, Bucket: "chunks", AccessKey: "access", SecretKey: "secret", KeyPrefix: "node-a"})
+	if err != nil {
+		t.Fatalf("NewS3Storage failed: %v", err)
+	}
+	if !fake.HasBucket("chunks") {
+		t.Fatalf("expected the missing bucket created")
+	}
+
+	var ids []string
+	var total int64
+	for i := 0; i < 5; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, 100+i)
+		id, err := store.Put(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if sum := sha256.Sum256(data); id != hex.EncodeToString(sum[:]) {
+			t.Errorf("expected the chunk keyed by its hash, got %s", id)
+		}
+		if _, ok := fake.Object("chunks", "node-a/"+id); !ok {
+			t.Errorf("expected chunk %s under the key prefix", id)
+		}
+		if got := readAll(t, store, id); !bytes.Equal(got, data) {
+			t.Errorf("chunk %s did not round-trip", id)
+		}
+		ids = append(ids, id)
+		total += int64(len(data))
+	}
+
+	// Another node sharing the bucket sees only its own chunks
+	other, err := NewS3Storage(endpoint, "chunks", "access", "secret", false)
+	if err != nil {
+		t.Fatalf("second store on an existing bucket failed: %v", err)
+	}
+	other.Put(strings.NewReader("unprefixed"))
+	listed, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	sort.Strings(ids)
+	sort.Strings(listed)
+	if strings.Join(listed, ",") != strings.Join(ids, ",") {
+		t.Errorf("expected the 5 prefixed chunks listed across pages, got %v", listed)
+	}
+	if used, err := store.Usage(); err != nil || used != total {
+		t.Errorf("expected usage %d, got %d, %v", total, used, err)
+	}
+	if otherIDs, _ := other.List(); len(otherIDs) != 1 {
+		t.Errorf("expected the unprefixed store to list only its own chunk, got %v", otherIDs)
+	}
+
+	if exists, err := store.Exists(ids[0]); !exists || err != nil {
+		t.Errorf("expected chunk %s to exist, got %v, %v", ids[0], exists, err)
+	}
+	if modified, err := store.ModTime(ids[0]); err != nil || time.Since(modified) > time.Minute {
+		t.Errorf("expected a fresh modification time, got %v, %v", modified, err)
+	}
+	written := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fake.SetModified("chunks", "node-a/"+ids[0], written)
+	if modified, err := store.ModTime(ids[0]); err != nil || !modified.Equal(written) {
+		t.Errorf("expected the Last-Modified time %v, got %v, %v", written, modified, err)
+	}
+	if err := store.Delete(ids[0]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ids[0]); err != nil {
+		t.Errorf("deleting a missing chunk should succeed, got %v", err)
+	}
+	if exists, err := store.Exists(ids[0]); exists || err != nil {
+		t.Errorf("expected deleted chunk to be gone, got %v, %v", exists, err)
+	}
+	if _, err := store.ModTime(ids[0]); err == nil {
+		t.Errorf("expected no modification time for a deleted chunk")
+	}
+	if _, err := store.Get(ids[0]); err == nil || !strings.Contains(err.Error(), "chunk not found") {
+		t.Errorf("expected a missing chunk reported as not found, got %v", err)
+	}
+	if _, err := store.GetPath(ids[1]); err == nil {
+		t.Errorf("expected objects to have no local path")
+	}
+
+	if _, err := NewS3Storage(endpoint, "chunks", "wrong", "secret", false); err == nil {
+		t.Errorf("expected rejected credentials to fail")
+	}
+}
+
+func TestSignV4MatchesReferenceSignature(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signV4(req, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", emptyPayloadHash,
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("unexpected signature:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestNewFromConfigSelectsBackend(t *testing.T) {
+	fake := s3fake.New(t)
+	cfg := &config.AppConfig{
+		StorageBackend: BackendS3,
+		S3Endpoint:     fake.URL(),
+		S3Bucket:       "chunks",
+		S3AccessKey:    "access",
+		S3SecretKey:    "secret",
+	}
+	store, err := NewFromConfig(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFromConfig failed: %v", err)
+	}
+	if _, ok := store.(*S3Storage); !ok {
+		t.Errorf("expected an S3Storage, got %T", store)
+	}
+
+	cfg.StorageBackend = ""
+	if store, err := NewFromConfig(cfg, t.TempDir()); err != nil {
+		t.Errorf("expected the local backend by default, got %v", err)
+	} else if _, ok := store.(*LocalStorage); !ok {
+		t.Errorf("expected a LocalStorage, got %T", store)
+	}
+
+	cfg.StorageBackend = "tape"
+	if _, err := NewFromConfig(cfg, t.TempDir()); err == nil {
+		t.Errorf("expected an unknown backend to fail")
+	}
+}
+
+func TestNewTierFromConfigSelectsBackend(t *testing.T) {
+	fake := s3fake.New(t)
+	cfg := &config.AppConfig{S3Endpoint: fake.Endpoint(), S3AccessKey: "access", S3SecretKey: "secret", S3Bucket: "shared", S3UseSSL: true}
+	useSSL := false
+
+	// Tier settings override the top-level ones, which fill the rest
+	backend, err := NewTierFromConfig(config.StorageTierConfig{Name: "archive", Backend: BackendS3,
+		S3Bucket: "archive", S3KeyPrefix: "cold", S3UseSSL: &useSSL}, cfg)
+	if err != nil {
+		t.Fatalf("NewTierFromConfig failed: %v", err)
+	}
+	s3, ok := backend.(*S3Storage)
+	if !ok {
+		t.Fatalf("expected an S3Storage, got %T", backend)
+	}
+	id, err := s3.Put(strings.NewReader("archived"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, ok := fake.Object("archive", "cold/"+id); !ok {
+		t.Errorf("expected the chunk in the tier's bucket under its prefix, got %v", fake.Keys("archive"))
+	}
+
+	if backend, err := NewTierFromConfig(config.StorageTierConfig{Name: "ssd", Path: t.TempDir()}, cfg); err != nil {
+		t.Errorf("expected a local tier by default, got %v", err)
+	} else if _, ok := backend.(*LocalStorage); !ok {
+		t.Errorf("expected a LocalStorage, got %T", backend)
+	}
+	if _, err := NewTierFromConfig(config.StorageTierConfig{Name: "ssd"}, cfg); err == nil {
+		t.Errorf("expected a local tier without a path to fail")
+	}
+	if _, err := NewTierFromConfig(config.StorageTierConfig{Name: "tape", Backend: "tape"}, cfg); err == nil {
+		t.Errorf("expected an unknown tier backend to fail")
+	}
+}
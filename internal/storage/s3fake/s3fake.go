@@ -0,0 +1,177 @@
+// Package s3fake serves the path-style subset of the S3 API that
+// storage.S3Storage uses, so tests in any package can run against S3
+package s3fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// AccessKey and SecretKey are the credentials the server accepts
+const (
+	AccessKey = "access"
+	SecretKey = "secret"
+)
+
+type object struct {
+	data     []byte
+	modified time.Time
+}
+
+// Server is an in-memory S3 endpoint. It checks that requests are signed
+// with AccessKey and carry the payload hash, and pages listings two keys
+// at a time.
+type Server struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]*object
+	server  *httptest.Server
+}
+
+// New starts a server that is closed when the test ends
+func New(t testing.TB) *Server {
+	s := &Server{buckets: make(map[string]map[string]*object)}
+	s.server = httptest.NewServer(s)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// URL returns the server's base URL
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Endpoint returns the server's host and port, as S3Options.Endpoint takes it
+func (s *Server) Endpoint() string {
+	return strings.TrimPrefix(s.server.URL, "http://")
+}
+
+// HasBucket reports whether a bucket was created
+func (s *Server) HasBucket(bucket string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.buckets[bucket]
+	return ok
+}
+
+// Object returns the content stored under key in bucket
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+	return obj.data, true
+}
+
+// Keys returns the sorted keys stored in bucket
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SetModified backdates an object, as if it had been written at modified
+func (s *Server) SetModified(bucket, key string, modified time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if obj, ok := s.buckets[bucket][key]; ok {
+		obj.modified = modified
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+AccessKey+"/") {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if sum := sha256.Sum256(body); r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "<Error><Code>XAmzContentSHA256Mismatch</Code></Error>", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	bucket, exists := s.buckets[bucketName]
+	if key == "" {
+		switch {
+		case r.Method == http.MethodPut:
+			if !exists {
+				s.buckets[bucketName] = make(map[string]*object)
+			}
+		case !exists:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			list(w, r, bucket)
+		}
+		return
+	}
+	if !exists {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		bucket[key] = &object{data: body, modified: time.Now()}
+	case http.MethodGet, http.MethodHead:
+		obj, ok := bucket[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func list(w http.ResponseWriter, r *http.Request, bucket map[string]*object) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for key := range bucket {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+	end := min(start+2, len(keys))
+
+	type entry struct {
+		Key  string
+		Size int
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []entry
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{IsTruncated: end < len(keys)}
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, entry{key, len(bucket[key].data)})
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	xml.NewEncoder(w).Encode(result)
+}
@@ -1,7 +1,16 @@
 package storage
 
 import (
+	"fmt"
 	"io"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// Backends selectable with the storage_backend setting
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
 )
 
 // Storage defines the interface for storing and retrieving file chunks.
@@ -12,4 +21,50 @@ type Storage interface {
 	Get(id string) (io.ReadCloser, error)
 	// GetPath returns the file path for a given chunk identifier.
 	GetPath(id string) (string, error)
+	// Exists reports whether a chunk is stored, without reading it.
+	Exists(id string) (bool, error)
+	// Usage returns the total number of bytes currently stored.
+	Usage() (int64, error)
+}
+
+// NewFromConfig opens the configured storage backend, keeping chunks under
+// localPath when it is the local disk
+func NewFromConfig(cfg *config.AppConfig, localPath string) (Storage, error) {
+	backend := BackendLocal
+	if cfg != nil && cfg.StorageBackend != "" {
+		backend = cfg.StorageBackend
+	}
+	switch backend {
+	case BackendLocal:
+		local, err := NewLocalStorage(localPath)
+		if err != nil {
+			return nil, err
+		}
+		local.SetWriteOptions(WriteOptionsFromConfig(cfg))
+		return local, nil
+	case BackendS3:
+		return NewS3StorageWithOptions(S3OptionsFromConfig(cfg))
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, expected %q or %q", backend, BackendLocal, BackendS3)
+	}
+}
+
+// NewTierFromConfig opens the backend of one configured storage tier
+func NewTierFromConfig(tier config.StorageTierConfig, cfg *config.AppConfig) (Storage, error) {
+	switch tier.Backend {
+	case "", BackendLocal:
+		if tier.Path == "" {
+			return nil, fmt.Errorf("local tier %s needs a path", tier.Name)
+		}
+		local, err := NewLocalStorage(tier.Path)
+		if err != nil {
+			return nil, err
+		}
+		local.SetWriteOptions(WriteOptionsFromConfig(cfg))
+		return local, nil
+	case BackendS3:
+		return NewS3StorageWithOptions(S3OptionsForTier(tier, cfg))
+	default:
+		return nil, fmt.Errorf("unknown backend %q for tier %s, expected %q or %q", tier.Backend, tier.Name, BackendLocal, BackendS3)
+	}
 }
@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Storage classes, matching metadata.EnhancedFileMetadata.StorageClass
+const (
+	ClassHot     = "hot"
+	ClassWarm    = "warm"
+	ClassCold    = "cold"
+	ClassArchive = "archive"
+)
+
+// Deleter is implemented by backends that can remove chunks
+type Deleter interface {
+	Delete(id string) error
+}
+
+// Lister is implemented by backends that can enumerate their chunks
+type Lister interface {
+	List() ([]string, error)
+}
+
+// DiskBacked is implemented by backends that keep chunks in a local directory
+type DiskBacked interface {
+	BasePath() string
+}
+
+// ModTimer is implemented by backends that can tell when a chunk was written
+type ModTimer interface {
+	ModTime(id string) (time.Time, error)
+}
+
+// Tier is one backend in a TieredStorage chain
+type Tier struct {
+	Name    string
+	Backend Storage
+	Classes []string // Storage classes placed on this tier; empty accepts none explicitly
+}
+
+// TierUsage reports usage and traffic for a single tier
+type TierUsage struct {
+	Name      string `json:"name"`
+	UsedBytes int64  `json:"used_bytes"`
+	Reads     int64  `json:"reads"`
+	Writes    int64  `json:"writes"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TieredStorage chains several backends in priority order. Writes go to the
+// tier that serves the chunk's storage class (the first tier by default) and
+// reads fall through the tiers until one has the chunk.
+type TieredStorage struct {
+	tiers   []Tier
+	reads   map[string]int64
+	writes  map[string]int64
+	mu      sync.RWMutex
+	journal *MigrationJournal // Optional record of moves in progress
+	refs    References        // Guards Delete in CAS mode, nil otherwise
+
+	// Test hook run after a migrated copy is verified, before sources are removed
+	beforeSourceDelete func(id string)
+}
+
+// NewTieredStorage creates a tiered store; the first tier is the primary
+func NewTieredStorage(tiers ...Tier) (*TieredStorage, error) {
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("tiered storage needs at least one tier")
+	}
+	seen := make(map[string]bool)
+	for i, tier := range tiers {
+		if tier.Backend == nil {
+			return nil, fmt.Errorf("tier %d (%s) has no backend", i, tier.Name)
+		}
+		if tier.Name == "" || seen[tier.Name] {
+			return nil, fmt.Errorf("tier %d needs a unique name, got %q", i, tier.Name)
+		}
+		seen[tier.Name] = true
+	}
+
+	return &TieredStorage{
+		tiers:  tiers,
+		reads:  make(map[string]int64),
+		writes: make(map[string]int64),
+	}, nil
+}
+
+// Put stores a chunk on the primary tier.
+func (t *TieredStorage) Put(chunkData io.Reader) (string, error) {
+	return t.putOn(t.tiers[0], chunkData)
+}
+
+// PutWithClass stores a chunk on the first tier serving the storage class,
+// falling back to the primary tier when no tier claims it.
+func (t *TieredStorage) PutWithClass(chunkData io.Reader, class string) (string, error) {
+	return t.putOn(t.tierForClass(class), chunkData)
+}
+
+// classStore is a TieredStorage whose writes go to the tier serving one class
+type classStore struct {
+	*TieredStorage
+	class string
+}
+
+// Put stores a chunk on the tier serving the view's storage class.
+func (c classStore) Put(chunkData io.Reader) (string, error) {
+	return c.PutWithClass(chunkData, c.class)
+}
+
+// ForClass returns a view of store whose writes go to the tier serving the
+// storage class. Other backends, and an empty class, return store unchanged.
+func ForClass(store Storage, class string) Storage {
+	tiered, ok := store.(*TieredStorage)
+	if !ok || class == "" {
+		return store
+	}
+	return classStore{TieredStorage: tiered, class: class}
+}
+
+// IsStorageClass reports whether class names one of the storage classes
+func IsStorageClass(class string) bool {
+	switch class {
+	case ClassHot, ClassWarm, ClassCold, ClassArchive:
+		return true
+	}
+	return false
+}
+
+// Get retrieves a chunk from the first tier that has it.
+func (t *TieredStorage) Get(id string) (io.ReadCloser, error) {
+	var lastErr error
+	for _, tier := range t.tiers {
+		reader, err := tier.Backend.Get(id)
+		if err == nil {
+			t.count(t.reads, tier.Name)
+			return reader, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("chunk not found in any tier: %s: %w", id, lastErr)
+}
+
+// Exists reports whether any tier holds the chunk.
+func (t *TieredStorage) Exists(id string) (bool, error) {
+	for _, tier := range t.tiers {
+		exists, err := tier.Backend.Exists(id)
+		if err != nil {
+			return false, fmt.Errorf("failed to check tier %s: %w", tier.Name, err)
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetPath returns the path of the chunk on the tier that holds it, or on the
+// primary tier when no tier has it yet.
+func (t *TieredStorage) GetPath(id string) (string, error) {
+	if tier, err := t.locate(id); err == nil {
+		return tier.Backend.GetPath(id)
+	}
+	return t.tiers[0].Backend.GetPath(id)
+}
+
+// ModTime returns when the chunk was written to the tier that holds it.
+func (t *TieredStorage) ModTime(id string) (time.Time, error) {
+	tier, err := t.locate(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	modTimer, ok := tier.Backend.(ModTimer)
+	if !ok {
+		return time.Time{}, fmt.Errorf("tier %s cannot report modification times", tier.Name)
+	}
+	return modTimer.ModTime(id)
+}
+
+// Usage returns the total bytes stored across all tiers.
+func (t *TieredStorage) Usage() (int64, error) {
+	var total int64
+	for _, tier := range t.tiers {
+		used, err := tier.Backend.Usage()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get usage of tier %s: %w", tier.Name, err)
+		}
+		total += used
+	}
+	return total, nil
+}
+
+// List returns the distinct chunk ids held by any tier that supports listing.
+func (t *TieredStorage) List() ([]string, error) {
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, tier := range t.tiers {
+		lister, ok := tier.Backend.(Lister)
+		if !ok {
+			continue
+		}
+		tierIDs, err := lister.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tier %s: %w", tier.Name, err)
+		}
+		for _, id := range tierIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// SetContentAddressed switches every tier that supports it to CAS mode.
+// Migrations still move chunks between tiers; only Delete checks refs.
+func (t *TieredStorage) SetContentAddressed(enabled bool, refs References) {
+	for _, tier := range t.tiers {
+		if cas, ok := tier.Backend.(ContentAddressable); ok {
+			cas.SetContentAddressed(enabled, nil)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refs = nil
+	if enabled {
+		t.refs = refs
+	}
+}
+
+// Delete removes a chunk from every tier that supports deletion.
+func (t *TieredStorage) Delete(id string) error {
+	t.mu.RLock()
+	refs := t.refs
+	t.mu.RUnlock()
+	if err := checkUnreferenced(refs, id); err != nil {
+		return err
+	}
+	for _, tier := range t.tiers {
+		if deleter, ok := tier.Backend.(Deleter); ok {
+			if err := deleter.Delete(id); err != nil {
+				return fmt.Errorf("failed to remove chunk %s from tier %s: %w", id, tier.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// TierUsage returns per-tier usage and read/write counts, in tier order.
+func (t *TieredStorage) TierUsage() []TierUsage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	usage := make([]TierUsage, 0, len(t.tiers))
+	for _, tier := range t.tiers {
+		entry := TierUsage{
+			Name:   tier.Name,
+			Reads:  t.reads[tier.Name],
+			Writes: t.writes[tier.Name],
+		}
+		if used, err := tier.Backend.Usage(); err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.UsedBytes = used
+		}
+		usage = append(usage, entry)
+	}
+	return usage
+}
+
+// Locate returns the name of the first tier holding the chunk.
+func (t *TieredStorage) Locate(id string) (string, error) {
+	tier, err := t.locate(id)
+	if err != nil {
+		return "", err
+	}
+	return tier.Name, nil
+}
+
+// MigrateToClass moves a chunk to the tier serving the storage class.
+func (t *TieredStorage) MigrateToClass(id, class string) error {
+	return t.Migrate(id, t.tierForClass(class).Name)
+}
+
+// SetMigrationJournal records moves in journal so ResumeMigrations can finish
+// the ones a crash interrupted.
+func (t *TieredStorage) SetMigrationJournal(journal *MigrationJournal) {
+	t.journal = journal
+}
+
+// Migrate moves a chunk to the named tier. The copy is re-read from the
+// target and must hash to id before the chunk is removed from any other tier,
+// so a failed or corrupted copy never costs the source. Migrate is safe to
+// repeat: a target that already holds a verified copy is not written again.
+func (t *TieredStorage) Migrate(id, tierName string) error {
+	target, ok := t.tierByName(tierName)
+	if !ok {
+		return fmt.Errorf("unknown tier: %s", tierName)
+	}
+
+	if err := VerifyChunk(target.Backend, id); err != nil {
+		source, err := t.locateOutside(id, target.Name)
+		if err != nil {
+			return err
+		}
+		if t.journal != nil {
+			if err := t.journal.begin(id, target.Name); err != nil {
+				return fmt.Errorf("failed to journal migration of chunk %s: %w", id, err)
+			}
+		}
+		if err := t.copyVerified(id, source, target); err != nil {
+			if t.journal != nil {
+				t.journal.finish(id)
+			}
+			return err
+		}
+	}
+
+	if t.beforeSourceDelete != nil {
+		t.beforeSourceDelete(id)
+	}
+	for _, tier := range t.tiers {
+		if tier.Name == target.Name {
+			continue
+		}
+		if deleter, ok := tier.Backend.(Deleter); ok {
+			if err := deleter.Delete(id); err != nil {
+				return fmt.Errorf("failed to remove chunk %s from tier %s: %w", id, tier.Name, err)
+			}
+		}
+	}
+	if t.journal != nil {
+		if err := t.journal.finish(id); err != nil {
+			return fmt.Errorf("failed to journal migration of chunk %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// copyVerified copies a chunk from source to target and checks the target copy
+func (t *TieredStorage) copyVerified(id string, source, target Tier) error {
+	reader, err := source.Backend.Get(id)
+	if err != nil {
+		return fmt.Errorf("failed to read chunk %s from tier %s: %w", id, source.Name, err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read chunk %s from tier %s: %w", id, source.Name, err)
+	}
+
+	newID, err := t.putOn(target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if newID != id {
+		return fmt.Errorf("tier %s stored chunk %s under a different id %s", target.Name, id, newID)
+	}
+	if err := VerifyChunk(target.Backend, id); err != nil {
+		// The source is untouched; drop the bad copy so reads do not find it first
+		if deleter, ok := target.Backend.(Deleter); ok {
+			deleter.Delete(id)
+		}
+		return fmt.Errorf("copy of chunk %s on tier %s failed verification: %w", id, target.Name, err)
+	}
+	return nil
+}
+
+// ResumeMigrations finishes the journaled moves a crash interrupted and
+// returns how many completed. Moves whose chunk no longer exists anywhere
+// are dropped from the journal.
+func (t *TieredStorage) ResumeMigrations() (int, error) {
+	if t.journal == nil {
+		return 0, nil
+	}
+	resumed := 0
+	var firstErr error
+	for _, move := range t.journal.Pending() {
+		if _, err := t.locate(move.ID); err != nil {
+			t.journal.finish(move.ID)
+			continue
+		}
+		if err := t.Migrate(move.ID, move.Target); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		resumed++
+	}
+	return resumed, firstErr
+}
+
+func (t *TieredStorage) putOn(tier Tier, chunkData io.Reader) (string, error) {
+	id, err := tier.Backend.Put(chunkData)
+	if err != nil {
+		return "", fmt.Errorf("failed to write chunk to tier %s: %w", tier.Name, err)
+	}
+	t.count(t.writes, tier.Name)
+	return id, nil
+}
+
+func (t *TieredStorage) locate(id string) (Tier, error) {
+	for _, tier := range t.tiers {
+		if exists, err := tier.Backend.Exists(id); err == nil && exists {
+			return tier, nil
+		}
+	}
+	return Tier{}, fmt.Errorf("chunk not found in any tier: %s", id)
+}
+
+// locateOutside returns the first tier other than exclude holding an intact copy of the chunk
+func (t *TieredStorage) locateOutside(id, exclude string) (Tier, error) {
+	for _, tier := range t.tiers {
+		if tier.Name != exclude && VerifyChunk(tier.Backend, id) == nil {
+			return tier, nil
+		}
+	}
+	return Tier{}, fmt.Errorf("chunk not found in any tier: %s", id)
+}
+
+func (t *TieredStorage) tierForClass(class string) Tier {
+	for _, tier := range t.tiers {
+		for _, c := range tier.Classes {
+			if c == class {
+				return tier
+			}
+		}
+	}
+	return t.tiers[0]
+}
+
+func (t *TieredStorage) tierByName(name string) (Tier, bool) {
+	for _, tier := range t.tiers {
+		if tier.Name == name {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+func (t *TieredStorage) count(counter map[string]int64, name string) {
+	t.mu.Lock()
+	counter[name]++
+	t.mu.Unlock()
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestTiers(t *testing.T) (*TieredStorage, *LocalStorage, *LocalStorage) {
+	dir := t.TempDir()
+	hot, err := NewLocalStorage(filepath.Join(dir, "hot"))
+	if err != nil {
+		t.Fatalf("failed to create hot tier: %v", err)
+	}
+	cold, err := NewLocalStorage(filepath.Join(dir, "cold"))
+	if err != nil {
+		t.Fatalf("failed to create cold tier: %v", err)
+	}
+	tiered, err := NewTieredStorage(
+		Tier{Name: "local", Backend: hot, Classes: []string{ClassHot, ClassWarm}},
+		Tier{Name: "cold", Backend: cold, Classes: []string{ClassCold, ClassArchive}},
+	)
+	if err != nil {
+		t.Fatalf("failed to create tiered storage: %v", err)
+	}
+	return tiered, hot, cold
+}
+
+func readAll(t *testing.T, s Storage, id string) []byte {
+	reader, err := s.Get(id)
+	if err != nil {
+		t.Fatalf("Get(%s) failed: %v", id, err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	return data
+}
+
+func TestTieredStorageWriteRouting(t *testing.T) {
+	tiered, hot, cold := newTestTiers(t)
+
+	hotID, err := tiered.Put(bytes.NewReader([]byte("hot chunk")))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := hot.Get(hotID); err != nil {
+		t.Errorf("default writes should land on the primary tier: %v", err)
+	}
+
+	coldID, err := tiered.PutWithClass(bytes.NewReader([]byte("cold chunk")), ClassArchive)
+	if err != nil {
+		t.Fatalf("PutWithClass failed: %v", err)
+	}
+	if _, err := cold.Get(coldID); err != nil {
+		t.Errorf("archive class should land on the cold tier: %v", err)
+	}
+	if _, err := hot.Get(coldID); err == nil {
+		t.Errorf("archive chunk should not be written to the hot tier")
+	}
+
+	// Unclaimed classes fall back to the primary tier
+	otherID, _ := tiered.PutWithClass(bytes.NewReader([]byte("other")), "unknown")
+	if tier, _ := tiered.Locate(otherID); tier != "local" {
+		t.Errorf("expected unclaimed class on primary tier, got %q", tier)
+	}
+
+	// A class view writes with plain Put, as the chunker does
+	viewID, err := ForClass(tiered, ClassCold).Put(bytes.NewReader([]byte("via view")))
+	if err != nil {
+		t.Fatalf("Put through the class view failed: %v", err)
+	}
+	if tier, _ := tiered.Locate(viewID); tier != "cold" {
+		t.Errorf("expected the cold class view to write to the cold tier, got %q", tier)
+	}
+	if ForClass(hot, ClassCold) != Storage(hot) || ForClass(tiered, "") != Storage(tiered) {
+		t.Errorf("expected stores without tiers, and an empty class, left unchanged")
+	}
+}
+
+func TestTieredStorageReadFallthrough(t *testing.T) {
+	tiered, _, cold := newTestTiers(t)
+
+	id, err := cold.Put(bytes.NewReader([]byte("only on cold")))
+	if err != nil {
+		t.Fatalf("cold Put failed: %v", err)
+	}
+	if got := readAll(t, tiered, id); string(got) != "only on cold" {
+		t.Errorf("unexpected data from fallthrough read: %q", got)
+	}
+	if _, err := tiered.Get("missing"); err == nil {
+		t.Errorf("expected error for chunk missing from all tiers")
+	}
+
+	usage := tiered.TierUsage()
+	if len(usage) != 2 || usage[1].Reads != 1 || usage[1].UsedBytes == 0 {
+		t.Errorf("expected one read recorded against the cold tier, got %+v", usage)
+	}
+}
+
+func TestTieredStorageMigrate(t *testing.T) {
+	tiered, hot, cold := newTestTiers(t)
+
+	id, _ := tiered.Put(bytes.NewReader([]byte("cooling down")))
+	if err := tiered.MigrateToClass(id, ClassCold); err != nil {
+		t.Fatalf("MigrateToClass failed: %v", err)
+	}
+	if _, err := hot.Get(id); err == nil {
+		t.Errorf("chunk should be removed from the hot tier after migration")
+	}
+	if got := readAll(t, cold, id); string(got) != "cooling down" {
+		t.Errorf("unexpected migrated data: %q", got)
+	}
+	if err := tiered.Migrate(id, "nowhere"); err == nil {
+		t.Errorf("expected error for unknown tier")
+	}
+}
+
+func TestTieredStorageMigrateCrashKeepsSource(t *testing.T) {
+	tiered, hot, cold := newTestTiers(t)
+	journalPath := filepath.Join(t.TempDir(), "migrations.json")
+	journal, err := OpenMigrationJournal(journalPath)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	tiered.SetMigrationJournal(journal)
+	data := []byte("chunk on the move")
+	id, _ := tiered.Put(bytes.NewReader(data))
+
+	// Crash after the copy is verified but before the source is removed
+	tiered.beforeSourceDelete = func(string) { panic("simulated crash") }
+	func() {
+		defer func() { recover() }()
+		tiered.Migrate(id, "cold")
+	}()
+
+	if got := readAll(t, hot, id); !bytes.Equal(got, data) {
+		t.Fatalf("source copy lost in the crash: %q", got)
+	}
+	if got := readAll(t, cold, id); !bytes.Equal(got, data) {
+		t.Fatalf("expected the verified copy on the target: %q", got)
+	}
+
+	// A restarted store finishes the move from its journal
+	reopened, err := OpenMigrationJournal(journalPath)
+	if err != nil {
+		t.Fatalf("failed to reopen journal: %v", err)
+	}
+	if pending := reopened.Pending(); len(pending) != 1 || pending[0].ID != id || pending[0].Target != "cold" {
+		t.Fatalf("expected the interrupted move in the journal, got %+v", pending)
+	}
+	restarted, _ := NewTieredStorage(
+		Tier{Name: "local", Backend: hot},
+		Tier{Name: "cold", Backend: cold},
+	)
+	restarted.SetMigrationJournal(reopened)
+	if resumed, err := restarted.ResumeMigrations(); err != nil || resumed != 1 {
+		t.Fatalf("expected 1 migration resumed, got %d (%v)", resumed, err)
+	}
+	if _, err := hot.Get(id); err == nil {
+		t.Errorf("source copy should be removed once the move completes")
+	}
+	if err := VerifyChunk(cold, id); err != nil {
+		t.Errorf("migrated chunk is not intact: %v", err)
+	}
+	if pending := reopened.Pending(); len(pending) != 0 {
+		t.Errorf("expected an empty journal, got %+v", pending)
+	}
+}
+
+// corruptingStorage stores chunks under the right id but damages their bytes
+type corruptingStorage struct {
+	*LocalStorage
+}
+
+func (c corruptingStorage) Put(chunkData io.Reader) (string, error) {
+	data, _ := io.ReadAll(chunkData)
+	id, err := c.LocalStorage.Put(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	path, _ := c.GetPath(id)
+	data[0] ^= 0xff
+	return id, os.WriteFile(path, data, 0644)
+}
+
+func TestTieredStorageMigrateRejectsBadCopy(t *testing.T) {
+	dir := t.TempDir()
+	hot, _ := NewLocalStorage(filepath.Join(dir, "hot"))
+	cold, _ := NewLocalStorage(filepath.Join(dir, "cold"))
+	tiered, _ := NewTieredStorage(Tier{Name: "local", Backend: hot}, Tier{Name: "cold", Backend: corruptingStorage{cold}})
+
+	data := []byte("chunk that must survive")
+	id, _ := tiered.Put(bytes.NewReader(data))
+	if err := tiered.Migrate(id, "cold"); err == nil {
+		t.Fatalf("expected the corrupted copy to fail verification")
+	}
+	if got := readAll(t, hot, id); !bytes.Equal(got, data) {
+		t.Errorf("source copy must be kept when the copy is bad, got %q", got)
+	}
+	if _, err := cold.Get(id); err == nil {
+		t.Errorf("the corrupted copy should be removed from the target")
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// UsageCache is a Storage whose Usage is measured at most once per TTL, so
+// callers that report usage often don't walk the whole store every time
+type UsageCache struct {
+	Storage
+	ttl time.Duration
+
+	mu       sync.Mutex
+	used     int64
+	measured time.Time
+}
+
+// NewUsageCache wraps store, remeasuring its usage once the last measurement is older than ttl
+func NewUsageCache(store Storage, ttl time.Duration) *UsageCache {
+	return &UsageCache{Storage: store, ttl: ttl}
+}
+
+// Usage returns the last measured size, measuring again once it is stale.
+// A failed measurement is not cached.
+func (c *UsageCache) Usage() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.measured.IsZero() && time.Since(c.measured) < c.ttl {
+		return c.used, nil
+	}
+	used, err := c.Storage.Usage()
+	if err != nil {
+		return 0, err
+	}
+	c.used, c.measured = used, time.Now()
+	return used, nil
+}
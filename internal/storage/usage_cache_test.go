@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageCacheMeasuresOncePerTTL(t *testing.T) {
+	local, err := NewLocalStorage(filepath.Join(t.TempDir(), "chunks"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	cache := NewUsageCache(local, time.Hour)
+
+	local.Put(bytes.NewReader(make([]byte, 100)))
+	first, err := cache.Usage()
+	if err != nil || first == 0 {
+		t.Fatalf("expected the stored chunk measured, got %d (%v)", first, err)
+	}
+
+	// A write inside the TTL is not measured again
+	local.Put(bytes.NewReader(bytes.Repeat([]byte{1}, 100)))
+	if used, _ := cache.Usage(); used != first {
+		t.Errorf("expected the cached %d bytes within the TTL, got %d", first, used)
+	}
+
+	stale := NewUsageCache(local, 0)
+	if used, _ := stale.Usage(); used <= first {
+		t.Errorf("expected a stale cache to measure the second chunk, got %d", used)
+	}
+}
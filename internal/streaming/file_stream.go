@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/encryptor"
 )
 
 // StreamProcessor handles large file streaming operations
@@ -165,6 +166,7 @@ func (sp *StreamProcessor) ReadStream(sessionID string) (<-chan StreamChunk, err
 		chunkIndex := 0
 		hasher := md5.New()
 
+	readLoop:
 		for {
 			select {
 			case <-session.Context.Done():
@@ -235,7 +237,7 @@ func (sp *StreamProcessor) ReadStream(sessionID string) (<-chan StreamChunk, err
 				}
 
 				if err == io.EOF {
-					break
+					break readLoop
 				} else if err != nil {
 					session.ErrorChan <- fmt.Errorf("read error: %v", err)
 					return
@@ -250,8 +252,13 @@ func (sp *StreamProcessor) ReadStream(sessionID string) (<-chan StreamChunk, err
 	return chunkChan, nil
 }
 
-// WriteStream writes streaming data to a file
+// WriteStream writes streaming data to a file. The data is plaintext, so
+// strict encryption refuses it.
 func (sp *StreamProcessor) WriteStream(sessionID string, outputPath string, chunkChan <-chan StreamChunk) error {
+	if err := encryptor.RefusePlaintext("stream output " + outputPath); err != nil {
+		return err
+	}
+
 	sp.mu.RLock()
 	session, exists := sp.activeStreams[sessionID]
 	sp.mu.RUnlock()
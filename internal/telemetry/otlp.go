@@ -0,0 +1,350 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLP/HTTP JSON encoding; see opentelemetry-proto's JSON mapping. Trace and
+// span IDs are hex strings and 64-bit integers are decimal strings.
+
+const (
+	spanKindInternal      = 1
+	statusOK              = 1
+	statusError           = 2
+	temporalityCumulative = 2
+)
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            otlpStatus `json:"status"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type tracesRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type numberPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsInt             string     `json:"asInt"`
+}
+
+type histogramPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	Count             string     `json:"count"`
+	Sum               float64    `json:"sum"`
+	BucketCounts      []string   `json:"bucketCounts"`
+	ExplicitBounds    []float64  `json:"explicitBounds"`
+}
+
+type otlpSum struct {
+	DataPoints             []numberPoint `json:"dataPoints"`
+	AggregationTemporality int           `json:"aggregationTemporality"`
+	IsMonotonic            bool          `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []histogramPoint `json:"dataPoints"`
+	AggregationTemporality int              `json:"aggregationTemporality"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Unit        string         `json:"unit,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type scopeMetrics struct {
+	Scope   scope        `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type metricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpLog struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope     `json:"scope"`
+	LogRecords []otlpLog `json:"logRecords"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type logsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+const scopeName = "github.com/jaywantadh/DisktroByte"
+
+func serviceResource(cfg Config) resource {
+	return resource{Attributes: []keyValue{stringAttr("service.name", cfg.ServiceName)}}
+}
+
+func tracesPayload(cfg Config, spans []spanRecord) *tracesRequest {
+	ss := scopeSpans{Scope: scope{Name: scopeName}}
+	for _, span := range spans {
+		out := otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(span.start),
+			EndTimeUnixNano:   unixNano(span.end),
+			Attributes:        attributes(span.attrs, span.correlationID),
+			Status:            otlpStatus{Code: statusOK},
+		}
+		if span.err != nil {
+			out.Status = otlpStatus{Code: statusError, Message: span.err.Error()}
+		}
+		ss.Spans = append(ss.Spans, out)
+	}
+	return &tracesRequest{ResourceSpans: []resourceSpans{{Resource: serviceResource(cfg), ScopeSpans: []scopeSpans{ss}}}}
+}
+
+// metricsPayloadLocked reports cumulative operation counts, durations and
+// bytes since the exporter was created, or nil before anything was recorded
+func (e *Exporter) metricsPayloadLocked(cfg Config, now time.Time) *metricsRequest {
+	if len(e.operations) == 0 {
+		return nil
+	}
+	start, at := unixNano(e.started), unixNano(now)
+
+	counts := &otlpSum{AggregationTemporality: temporalityCumulative, IsMonotonic: true}
+	keys := make([]opKey, 0, len(e.operations))
+	for key := range e.operations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, key := range keys {
+		counts.DataPoints = append(counts.DataPoints, numberPoint{
+			Attributes:        []keyValue{stringAttr("operation", key.operation), stringAttr("status", key.status)},
+			StartTimeUnixNano: start,
+			TimeUnixNano:      at,
+			AsInt:             strconv.FormatInt(e.operations[key], 10),
+		})
+	}
+
+	durations := &otlpHistogram{AggregationTemporality: temporalityCumulative}
+	for _, operation := range sortedKeys(e.durations) {
+		h := e.durations[operation]
+		point := histogramPoint{
+			Attributes:        []keyValue{stringAttr("operation", operation)},
+			StartTimeUnixNano: start,
+			TimeUnixNano:      at,
+			Count:             strconv.FormatUint(h.count, 10),
+			Sum:               h.sum,
+			ExplicitBounds:    durationBounds,
+		}
+		for _, n := range h.buckets {
+			point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(n, 10))
+		}
+		durations.DataPoints = append(durations.DataPoints, point)
+	}
+
+	metrics := []otlpMetric{
+		{Name: "disktrobyte.operations", Description: "Operations finished, by outcome", Unit: "{operation}", Sum: counts},
+		{Name: "disktrobyte.operation.duration", Description: "Time operations took", Unit: "s", Histogram: durations},
+	}
+	if len(e.bytes) > 0 {
+		moved := &otlpSum{AggregationTemporality: temporalityCumulative, IsMonotonic: true}
+		for _, operation := range sortedKeys(e.bytes) {
+			moved.DataPoints = append(moved.DataPoints, numberPoint{
+				Attributes:        []keyValue{stringAttr("operation", operation)},
+				StartTimeUnixNano: start,
+				TimeUnixNano:      at,
+				AsInt:             strconv.FormatInt(e.bytes[operation], 10),
+			})
+		}
+		metrics = append(metrics, otlpMetric{Name: "disktrobyte.operation.bytes", Description: "Bytes moved by operations", Unit: "By", Sum: moved})
+	}
+
+	return &metricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     serviceResource(cfg),
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: metrics}},
+	}}}
+}
+
+func logsPayload(cfg Config, logs []logRecord) *logsRequest {
+	sl := scopeLogs{Scope: scope{Name: scopeName}}
+	for _, record := range logs {
+		number, text := severity(record.line)
+		out := otlpLog{
+			TimeUnixNano:         unixNano(record.at),
+			ObservedTimeUnixNano: unixNano(record.at),
+			SeverityNumber:       number,
+			SeverityText:         text,
+			Body:                 anyValue{StringValue: &record.line},
+			TraceID:              record.traceID,
+			SpanID:               record.spanID,
+		}
+		if record.correlationID != "" {
+			out.Attributes = []keyValue{stringAttr("disktrobyte.trace_id", record.correlationID)}
+		}
+		sl.LogRecords = append(sl.LogRecords, out)
+	}
+	return &logsRequest{ResourceLogs: []resourceLogs{{Resource: serviceResource(cfg), ScopeLogs: []scopeLogs{sl}}}}
+}
+
+// severity reads a log line's level from the emoji the codebase leads failures and warnings with
+func severity(line string) (int, string) {
+	switch {
+	case strings.Contains(line, "❌"):
+		return 17, "ERROR"
+	case strings.Contains(line, "⚠️"):
+		return 13, "WARN"
+	default:
+		return 9, "INFO"
+	}
+}
+
+// attributes converts span attributes, adding the trace ID the rest of the codebase logs
+func attributes(attrs Attrs, correlationID string) []keyValue {
+	out := make([]keyValue, 0, len(attrs)+1)
+	if correlationID != "" {
+		out = append(out, stringAttr("disktrobyte.trace_id", correlationID))
+	}
+	for _, key := range sortedKeys(attrs) {
+		kv := keyValue{Key: key}
+		switch v := attrs[key].(type) {
+		case string:
+			kv.Value.StringValue = &v
+		case bool:
+			kv.Value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			kv.Value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			kv.Value.IntValue = &s
+		case float64:
+			kv.Value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			kv.Value.StringValue = &s
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+func stringAttr(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// post sends one OTLP/HTTP JSON request to the collector
+func (e *Exporter) post(ctx context.Context, cfg Config, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry: %v", err)
+	}
+	url := cfg.Endpoint + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build telemetry export to %s: %v", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export telemetry to %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector rejected telemetry sent to %s: status %d", url, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,596 @@
+// Package telemetry exports spans, metrics and log lines to an OpenTelemetry
+// collector over OTLP/HTTP. Spans take their trace from the tracing package's
+// trace ID, so a trace in the collector lines up with the log lines and errors
+// tagged with the same ID, on this node and on the peers it talked to.
+// Export runs in the background: recording never blocks, and when the
+// collector is down records are queued up to a bound and then dropped.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// Operations covered by spans
+const (
+	OpUpload     = "upload"
+	OpDistribute = "distribute"
+	OpReassemble = "reassemble"
+	OpTransfer   = "transfer"
+)
+
+// maxBackoff bounds how long export pauses after repeated collector failures
+const maxBackoff = 5 * time.Minute
+
+// durationBounds are the histogram bucket bounds, in seconds, for operation durations
+var durationBounds = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}
+
+// Config says where telemetry goes and how often
+type Config struct {
+	Enabled     bool
+	Endpoint    string        // Collector base URL; signals are posted to /v1/traces, /v1/metrics and /v1/logs
+	ServiceName string        // Reported as the service.name resource attribute
+	Interval    time.Duration // How often queued spans and logs and the current metrics are sent
+	Timeout     time.Duration // Limit on each export request
+	QueueSize   int           // Spans, and separately log records, held for the next export before new ones are dropped
+	Logs        bool          // Also export traced log lines
+}
+
+// DefaultConfig exports to a collector on localhost every 10 seconds
+func DefaultConfig() Config {
+	return Config{
+		Endpoint:    "http://localhost:4318",
+		ServiceName: "disktrobyte",
+		Interval:    10 * time.Second,
+		Timeout:     5 * time.Second,
+		QueueSize:   2048,
+		Logs:        true,
+	}
+}
+
+// ConfigFromConfig reads the export settings from the app config
+func ConfigFromConfig(cfg *config.AppConfig) Config {
+	c := DefaultConfig()
+	if cfg == nil {
+		return c
+	}
+	c.Enabled = cfg.OTelEnabled
+	if cfg.OTelEndpoint != "" {
+		c.Endpoint = cfg.OTelEndpoint
+	}
+	if cfg.OTelServiceName != "" {
+		c.ServiceName = cfg.OTelServiceName
+	}
+	if cfg.OTelExportInterval > 0 {
+		c.Interval = time.Duration(cfg.OTelExportInterval) * time.Second
+	}
+	c.Logs = cfg.OTelExportLogs
+	return c
+}
+
+func (c Config) normalize() Config {
+	def := DefaultConfig()
+	if c.Endpoint == "" {
+		c.Endpoint = def.Endpoint
+	}
+	c.Endpoint = strings.TrimRight(c.Endpoint, "/")
+	if c.ServiceName == "" {
+		c.ServiceName = def.ServiceName
+	}
+	if c.Interval <= 0 {
+		c.Interval = def.Interval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = def.Timeout
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = def.QueueSize
+	}
+	return c
+}
+
+// Attrs are attributes recorded on a span
+type Attrs map[string]interface{}
+
+// Stats reports how export is going
+type Stats struct {
+	Enabled       bool      `json:"enabled"`
+	Endpoint      string    `json:"endpoint,omitempty"`
+	QueuedSpans   int       `json:"queued_spans"`
+	QueuedLogs    int       `json:"queued_logs"`
+	SpansExported int64     `json:"spans_exported"`
+	LogsExported  int64     `json:"logs_exported"`
+	MetricExports int64     `json:"metric_exports"`
+	Dropped       int64     `json:"dropped"` // Spans and log records lost to a full queue or a failed export
+	Failures      int64     `json:"failures"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastExport    time.Time `json:"last_export,omitempty"`
+}
+
+type spanRecord struct {
+	name          string
+	traceID       string
+	spanID        string
+	parentID      string
+	correlationID string
+	nested        bool // Inside a span of the same operation, which alone is counted and timed
+	start, end    time.Time
+	attrs         Attrs
+	err           error
+}
+
+type logRecord struct {
+	at            time.Time
+	line          string
+	traceID       string
+	spanID        string
+	correlationID string
+}
+
+type opKey struct {
+	operation, status string
+}
+
+type histogram struct {
+	count   uint64
+	sum     float64
+	buckets []uint64 // One more than durationBounds; the last counts everything above them
+}
+
+// Exporter queues spans and logs, aggregates operation metrics and sends
+// them to the collector in the background. A nil *Exporter records nothing.
+type Exporter struct {
+	cfg        Config
+	client     *http.Client
+	started    time.Time
+	spans      []spanRecord
+	logs       []logRecord
+	operations map[opKey]int64
+	durations  map[string]*histogram
+	bytes      map[string]int64
+	stats      Stats
+	failing    int       // Consecutive failed exports
+	retryAt    time.Time // Background export pauses until then after failures
+	wake       chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+	configMu   sync.Mutex // Serialises SetConfig so loops are started and stopped in order
+	exportMu   sync.Mutex // Serialises exports
+	mu         sync.Mutex
+}
+
+// New creates an exporter; an enabled one starts sending at once
+func New(cfg Config) *Exporter {
+	e := &Exporter{
+		client:     &http.Client{},
+		started:    time.Now(),
+		operations: make(map[opKey]int64),
+		durations:  make(map[string]*histogram),
+		bytes:      make(map[string]int64),
+		wake:       make(chan struct{}, 1),
+	}
+	e.SetConfig(cfg)
+	return e
+}
+
+var global = New(Config{})
+
+// Global returns the process-wide exporter behind Start
+func Global() *Exporter {
+	return global
+}
+
+// Configure sets the configuration of the process-wide exporter and routes
+// traced log lines to it when log export is on
+func Configure(cfg Config) {
+	global.SetConfig(cfg)
+	if cfg.Enabled && cfg.Logs {
+		tracing.SetLogHook(global.Log)
+	} else {
+		tracing.SetLogHook(nil)
+	}
+}
+
+// SetConfig changes where and how often telemetry is sent, restarting the background export
+func (e *Exporter) SetConfig(cfg Config) {
+	if e == nil {
+		return
+	}
+	e.configMu.Lock()
+	defer e.configMu.Unlock()
+
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop, e.done = nil, nil
+	e.cfg = cfg.normalize()
+	e.client.Timeout = e.cfg.Timeout
+	e.stats.Enabled = e.cfg.Enabled
+	e.stats.Endpoint = ""
+	if e.cfg.Enabled {
+		e.stats.Endpoint = e.cfg.Endpoint
+	}
+	e.failing, e.retryAt = 0, time.Time{}
+	e.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	if cfg.Enabled {
+		e.mu.Lock()
+		e.stop, e.done = make(chan struct{}), make(chan struct{})
+		go e.run(e.cfg.Interval, e.stop, e.done)
+		e.mu.Unlock()
+	}
+}
+
+// Shutdown sends whatever is queued and stops the background export
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	err := e.Flush(ctx)
+	e.SetConfig(Config{})
+	return err
+}
+
+func (e *Exporter) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-e.wake:
+		}
+		e.mu.Lock()
+		backingOff := time.Now().Before(e.retryAt)
+		e.mu.Unlock()
+		if backingOff {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 3*e.cfgTimeout())
+		e.Flush(ctx)
+		cancel()
+	}
+}
+
+func (e *Exporter) cfgTimeout() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cfg.Timeout
+}
+
+func (e *Exporter) enabled() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cfg.Enabled
+}
+
+// wakeLocked asks for an early export once a queue is half full
+func (e *Exporter) wakeLocked(queued int) {
+	if queued < e.cfg.QueueSize/2 {
+		return
+	}
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+type spanKey struct{}
+
+// Span times one operation. A nil *Span, returned while export is off, accepts every call.
+type Span struct {
+	exporter      *Exporter
+	name          string
+	traceID       string
+	spanID        string
+	parentID      string
+	correlationID string
+	nested        bool
+	start         time.Time
+	attrs         Attrs
+	ended         bool
+	mu            sync.Mutex
+}
+
+// Start begins a span on the process-wide exporter; see Exporter.Start
+func Start(ctx context.Context, name string, attrs Attrs) (context.Context, *Span) {
+	return global.Start(ctx, name, attrs)
+}
+
+// Start begins a span named after an operation, such as "upload" or
+// "transfer.send", and returns a context carrying it. The span belongs to the
+// trace derived from the context's trace ID, issuing one when tracing is on
+// and the context has none, and is the child of the context's span. The
+// operation for metrics is the part of the name before the first dot; a span
+// inside another of the same operation adds its bytes but is not counted again.
+func (e *Exporter) Start(ctx context.Context, name string, attrs Attrs) (context.Context, *Span) {
+	if e == nil || !e.enabled() {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	correlationID := tracing.ID(ctx)
+	if correlationID == "" && tracing.Enabled() {
+		correlationID = tracing.NewID()
+		ctx = tracing.WithID(ctx, correlationID)
+	}
+	span := &Span{
+		exporter:      e,
+		name:          name,
+		traceID:       otlpTraceID(correlationID),
+		spanID:        randomHex(8),
+		correlationID: correlationID,
+		start:         time.Now(),
+		attrs:         make(Attrs, len(attrs)),
+	}
+	for key, value := range attrs {
+		span.attrs[key] = value
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent.traceID == span.traceID {
+		span.parentID = parent.spanID
+		span.nested = operationOf(parent.name) == operationOf(name)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttr records an attribute on the span
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+// AddBytes counts bytes moved by the span's operation
+func (s *Span) AddBytes(n int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total, _ := s.attrs["bytes"].(int64)
+	s.attrs["bytes"] = total + n
+}
+
+// End finishes the span, marking it failed when err is not nil. Only the first call counts.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	record := spanRecord{
+		name:          s.name,
+		traceID:       s.traceID,
+		spanID:        s.spanID,
+		parentID:      s.parentID,
+		correlationID: s.correlationID,
+		nested:        s.nested,
+		start:         s.start,
+		end:           time.Now(),
+		attrs:         s.attrs,
+		err:           err,
+	}
+	s.mu.Unlock()
+	s.exporter.record(record)
+}
+
+// record aggregates a finished span into the metrics and queues it for export
+func (e *Exporter) record(span spanRecord) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.cfg.Enabled {
+		return
+	}
+
+	operation := operationOf(span.name)
+	if !span.nested {
+		status := "ok"
+		if span.err != nil {
+			status = "error"
+		}
+		e.operations[opKey{operation, status}]++
+		h := e.durations[operation]
+		if h == nil {
+			h = &histogram{buckets: make([]uint64, len(durationBounds)+1)}
+			e.durations[operation] = h
+		}
+		seconds := span.end.Sub(span.start).Seconds()
+		h.count++
+		h.sum += seconds
+		bucket := len(durationBounds)
+		for i, bound := range durationBounds {
+			if seconds <= bound {
+				bucket = i
+				break
+			}
+		}
+		h.buckets[bucket]++
+	}
+	if n, ok := span.attrs["bytes"].(int64); ok {
+		e.bytes[operation] += n
+	}
+
+	if len(e.spans) >= e.cfg.QueueSize {
+		e.stats.Dropped++
+		return
+	}
+	e.spans = append(e.spans, span)
+	e.wakeLocked(len(e.spans))
+}
+
+// Log queues a log line for export under the context's trace and span
+func (e *Exporter) Log(ctx context.Context, line string) {
+	if e == nil {
+		return
+	}
+	record := logRecord{at: time.Now(), line: strings.TrimRight(line, "\n"), correlationID: tracing.ID(ctx)}
+	if record.correlationID != "" {
+		record.traceID = otlpTraceID(record.correlationID)
+	}
+	if ctx != nil {
+		if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+			record.traceID, record.spanID = span.traceID, span.spanID
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.cfg.Enabled {
+		return
+	}
+	if len(e.logs) >= e.cfg.QueueSize {
+		e.stats.Dropped++
+		return
+	}
+	e.logs = append(e.logs, record)
+	e.wakeLocked(len(e.logs))
+}
+
+// Flush sends everything queued and the current metrics now. Records a failed
+// export could not deliver are dropped rather than retried, so a collector
+// that is down never holds more than one queue's worth in memory.
+func (e *Exporter) Flush(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	e.exportMu.Lock()
+	defer e.exportMu.Unlock()
+
+	e.mu.Lock()
+	if !e.cfg.Enabled {
+		e.mu.Unlock()
+		return nil
+	}
+	cfg := e.cfg
+	spans, logs := e.spans, e.logs
+	e.spans, e.logs = nil, nil
+	metrics := e.metricsPayloadLocked(cfg, time.Now())
+	e.mu.Unlock()
+
+	var firstErr error
+	note := func(err error, lost int) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if err == nil {
+			return
+		}
+		e.stats.Dropped += int64(lost)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(spans) > 0 {
+		err := e.post(ctx, cfg, "/v1/traces", tracesPayload(cfg, spans))
+		note(err, len(spans))
+		if err == nil {
+			e.mu.Lock()
+			e.stats.SpansExported += int64(len(spans))
+			e.mu.Unlock()
+		}
+	}
+	if metrics != nil {
+		err := e.post(ctx, cfg, "/v1/metrics", metrics)
+		note(err, 0)
+		if err == nil {
+			e.mu.Lock()
+			e.stats.MetricExports++
+			e.mu.Unlock()
+		}
+	}
+	if len(logs) > 0 {
+		err := e.post(ctx, cfg, "/v1/logs", logsPayload(cfg, logs))
+		note(err, len(logs))
+		if err == nil {
+			e.mu.Lock()
+			e.stats.LogsExported += int64(len(logs))
+			e.mu.Unlock()
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if firstErr != nil {
+		// Back off so a collector that is down is not hit every interval
+		e.failing++
+		e.stats.Failures++
+		e.stats.LastError = firstErr.Error()
+		backoff := cfg.Interval << min(e.failing, 10)
+		e.retryAt = time.Now().Add(min(backoff, maxBackoff))
+		return firstErr
+	}
+	e.failing, e.retryAt = 0, time.Time{}
+	e.stats.LastExport = time.Now()
+	return nil
+}
+
+// Stats returns a snapshot of export progress
+func (e *Exporter) Stats() Stats {
+	if e == nil {
+		return Stats{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := e.stats
+	stats.QueuedSpans = len(e.spans)
+	stats.QueuedLogs = len(e.logs)
+	return stats
+}
+
+func operationOf(name string) string {
+	operation, _, _ := strings.Cut(name, ".")
+	return operation
+}
+
+// otlpTraceID maps a trace ID to the 32 hex characters OTLP expects. IDs this
+// node issues are 16 hex characters and are zero-padded so they stay
+// recognisable; other client-supplied IDs are hashed.
+func otlpTraceID(id string) string {
+	if id == "" {
+		return randomHex(16)
+	}
+	if isHex(id) && (len(id) == 32 || len(id) == 16) && strings.Trim(id, "0") != "" {
+		return strings.Repeat("0", 32-len(id)) + strings.ToLower(id)
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
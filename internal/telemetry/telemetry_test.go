@@ -0,0 +1,175 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/internal/tracing"
+)
+
+// mockCollector records the OTLP/HTTP JSON requests it receives by path
+type mockCollector struct {
+	mu       sync.Mutex
+	received map[string][]map[string]interface{}
+}
+
+func newMockCollector(t *testing.T) (*mockCollector, *httptest.Server) {
+	c := &mockCollector{received: make(map[string][]map[string]interface{})}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&body) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		c.received[r.URL.Path] = append(c.received[r.URL.Path], body)
+		c.mu.Unlock()
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+// items digs the records out of every request received on path, e.g.
+// resourceSpans > scopeSpans > spans
+func (c *mockCollector) items(path string, keys ...string) []map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var level []interface{}
+	for _, body := range c.received[path] {
+		level = append(level, body)
+	}
+	for _, key := range keys {
+		var next []interface{}
+		for _, item := range level {
+			list, _ := item.(map[string]interface{})[key].([]interface{})
+			next = append(next, list...)
+		}
+		level = next
+	}
+	out := make([]map[string]interface{}, len(level))
+	for i, item := range level {
+		out[i] = item.(map[string]interface{})
+	}
+	return out
+}
+
+func attr(item map[string]interface{}, key string) map[string]interface{} {
+	list, _ := item["attributes"].([]interface{})
+	for _, a := range list {
+		kv := a.(map[string]interface{})
+		if kv["key"] == key {
+			return kv["value"].(map[string]interface{})
+		}
+	}
+	return nil
+}
+
+func TestSpansMetricsAndLogsReachTheCollector(t *testing.T) {
+	collector, server := newMockCollector(t)
+	e := New(Config{Enabled: true, Endpoint: server.URL + "/", Interval: time.Hour, Logs: true})
+	defer e.Shutdown(context.Background())
+
+	ctx := tracing.WithID(context.Background(), "0123456789abcdef")
+	ctx, upload := e.Start(ctx, OpUpload, Attrs{"file.name": "a.bin"})
+	_, transfer := e.Start(ctx, OpTransfer+".send", Attrs{"peer.id": "node-2"})
+	transfer.AddBytes(4096)
+	transfer.End(errors.New("peer full"))
+	e.Log(ctx, "✂️ Chunked a.bin into 1 chunks\n")
+	upload.End(nil)
+
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	spans := collector.items("/v1/traces", "resourceSpans", "scopeSpans", "spans")
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans exported, got %d", len(spans))
+	}
+	byName := map[string]map[string]interface{}{}
+	for _, span := range spans {
+		byName[span["name"].(string)] = span
+	}
+	root, child := byName["upload"], byName["transfer.send"]
+	if root == nil || child == nil {
+		t.Fatalf("expected upload and transfer.send spans, got %v", byName)
+	}
+	// The trace pairs with the correlation ID the log lines carry
+	if root["traceId"] != "00000000000000000123456789abcdef" || child["traceId"] != root["traceId"] {
+		t.Errorf("expected both spans in the trace derived from the trace ID, got %v and %v", root["traceId"], child["traceId"])
+	}
+	if child["parentSpanId"] != root["spanId"] {
+		t.Errorf("expected the transfer span to be the upload's child")
+	}
+	if v := attr(root, "disktrobyte.trace_id"); v == nil || v["stringValue"] != "0123456789abcdef" {
+		t.Errorf("expected the trace ID recorded on the span, got %v", v)
+	}
+	if code := child["status"].(map[string]interface{})["code"]; code != float64(statusError) {
+		t.Errorf("expected the failed transfer marked as an error, got status %v", code)
+	}
+
+	metrics := collector.items("/v1/metrics", "resourceMetrics", "scopeMetrics", "metrics")
+	counts := map[string]string{}
+	for _, metric := range metrics {
+		if metric["name"] != "disktrobyte.operations" {
+			continue
+		}
+		for _, p := range metric["sum"].(map[string]interface{})["dataPoints"].([]interface{}) {
+			point := p.(map[string]interface{})
+			counts[attr(point, "operation")["stringValue"].(string)+"/"+attr(point, "status")["stringValue"].(string)] = point["asInt"].(string)
+		}
+	}
+	if counts["upload/ok"] != "1" || counts["transfer/error"] != "1" || len(metrics) != 3 {
+		t.Errorf("expected operation counts, durations and bytes exported, got %v from %d metrics", counts, len(metrics))
+	}
+
+	logs := collector.items("/v1/logs", "resourceLogs", "scopeLogs", "logRecords")
+	if len(logs) != 1 || logs[0]["traceId"] != root["traceId"] || logs[0]["spanId"] != root["spanId"] {
+		t.Fatalf("expected the log line exported under the upload span, got %v", logs)
+	}
+
+	if stats := e.Stats(); stats.SpansExported != 2 || stats.LogsExported != 1 || stats.MetricExports != 1 || stats.Failures != 0 {
+		t.Errorf("unexpected export stats: %+v", stats)
+	}
+}
+
+func TestExportDegradesWhenTheCollectorIsDown(t *testing.T) {
+	_, server := newMockCollector(t)
+	endpoint := server.URL
+	server.Close()
+
+	e := New(Config{Enabled: true, Endpoint: endpoint, Interval: time.Hour, QueueSize: 4})
+	defer e.Shutdown(context.Background())
+
+	// Recording never waits on the collector; beyond the queue spans are dropped
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		_, span := e.Start(context.Background(), OpReassemble, nil)
+		span.End(nil)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("recording spans took %v with the collector down", elapsed)
+	}
+	if err := e.Flush(context.Background()); err == nil {
+		t.Fatalf("expected the export to fail")
+	}
+	stats := e.Stats()
+	if stats.Dropped != 10 || stats.QueuedSpans != 0 || stats.Failures != 1 || stats.LastError == "" {
+		t.Errorf("expected the queue's overflow and the failed batch dropped, got %+v", stats)
+	}
+
+	// Disabled export records nothing and hands out nil spans that accept every call
+	e.SetConfig(Config{})
+	ctx, span := e.Start(context.Background(), OpUpload, nil)
+	span.SetAttr("k", "v")
+	span.End(nil)
+	if span != nil || ctx == nil {
+		t.Errorf("expected no span while export is off")
+	}
+}
@@ -0,0 +1,337 @@
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaywantadh/DisktroByte/config"
+)
+
+// Background jobs that yield to foreground work
+const (
+	JobScrub        = "scrub"
+	JobRepair       = "repair" // Re-replication and rebalancing
+	JobLifecycle    = "lifecycle"
+	JobOptimization = "optimization"
+	JobBackfill     = "backfill"
+)
+
+// maxPause bounds a single yield so a stale level cannot stall a job for long
+const maxPause = 10 * time.Second
+
+// Config sets how strongly background work backs off under foreground load
+type Config struct {
+	Enabled         bool
+	Sensitivity     float64       // Multiplies measured load; above 1 backs off sooner, below 1 later
+	BusyTransfers   int           // Active uploads and downloads that count as fully busy, 0 ignores them
+	BusyRequestRate float64       // Requests per second that count as fully busy, 0 ignores them
+	BusyCPULoad     float64       // Load average per CPU that counts as fully busy, 0 ignores it
+	MinLevel        float64       // Share of time background work keeps when fully busy, so it still progresses
+	RampUp          float64       // Most the level rises per sample once load drops; it falls at once
+	SampleInterval  time.Duration // How often load is measured
+}
+
+// DefaultConfig backs off fully at 8 transfers, 50 requests a second or a load of 1 per CPU
+func DefaultConfig() Config {
+	return Config{
+		Sensitivity:     1,
+		BusyTransfers:   8,
+		BusyRequestRate: 50,
+		BusyCPULoad:     1,
+		MinLevel:        0.1,
+		RampUp:          0.1,
+		SampleInterval:  time.Second,
+	}
+}
+
+// ConfigFromConfig reads the throttle settings from the app config, keeping
+// the defaults for any left unset
+func ConfigFromConfig(cfg *config.AppConfig) Config {
+	throttleCfg := DefaultConfig()
+	if cfg == nil {
+		return throttleCfg
+	}
+	throttleCfg.Enabled = cfg.BackgroundThrottle
+	if cfg.ThrottleSensitivity > 0 {
+		throttleCfg.Sensitivity = cfg.ThrottleSensitivity
+	}
+	if cfg.ThrottleBusyTransfers >= 0 {
+		throttleCfg.BusyTransfers = cfg.ThrottleBusyTransfers
+	}
+	if cfg.ThrottleBusyRequestRate >= 0 {
+		throttleCfg.BusyRequestRate = cfg.ThrottleBusyRequestRate
+	}
+	if cfg.ThrottleBusyCPULoad >= 0 {
+		throttleCfg.BusyCPULoad = cfg.ThrottleBusyCPULoad
+	}
+	if cfg.ThrottleMinLevel > 0 && cfg.ThrottleMinLevel <= 1 {
+		throttleCfg.MinLevel = cfg.ThrottleMinLevel
+	}
+	return throttleCfg
+}
+
+// JobStats reports how one background job has been throttled
+type JobStats struct {
+	Units     int64     `json:"units"`  // Units of work that yielded
+	Paused    float64   `json:"paused"` // Seconds spent backing off
+	LastYield time.Time `json:"last_yield"`
+}
+
+// Stats reports the throttle's view of foreground load and background levels
+type Stats struct {
+	Enabled         bool                `json:"enabled"`
+	Level           float64             `json:"level"` // Share of time background jobs may run, 1 when unthrottled
+	Load            float64             `json:"load"`  // Foreground load after sensitivity, 0 idle to 1 busy
+	ActiveTransfers int                 `json:"active_transfers"`
+	RequestRate     float64             `json:"request_rate"`       // Requests per second over the last sample
+	CPULoad         float64             `json:"cpu_load,omitempty"` // Load average per CPU, when available
+	Jobs            map[string]JobStats `json:"jobs"`
+}
+
+// Throttle scales background jobs back while foreground transfers and
+// requests keep the node busy, and lets them ramp up again when it is idle.
+// Jobs report each unit of work they finish to Yield, which pauses them long
+// enough that they run for only the current level's share of the time. A nil
+// *Throttle never pauses.
+type Throttle struct {
+	cfg         Config
+	level       float64
+	load        float64
+	transfers   int
+	requests    int64 // Requests since windowStart
+	windowStart time.Time
+	rate        float64
+	cpu         float64
+	sampledAt   time.Time
+	jobs        map[string]*JobStats
+	cpuLoad     func() (float64, bool) // Load average per CPU; replaced in tests
+	now         func() time.Time
+	sleep       func(ctx context.Context, d time.Duration) error
+	mu          sync.Mutex
+}
+
+// New creates a throttle; a disabled one only counts work
+func New(cfg Config) *Throttle {
+	return &Throttle{
+		cfg:         cfg,
+		level:       1,
+		windowStart: time.Now(),
+		jobs:        make(map[string]*JobStats),
+		cpuLoad:     loadAverage,
+		now:         time.Now,
+		sleep:       sleepContext,
+	}
+}
+
+var global = New(Config{})
+
+// Global returns the process-wide throttle shared by every background job
+func Global() *Throttle {
+	return global
+}
+
+// Configure sets the configuration of the process-wide throttle
+func Configure(cfg Config) {
+	global.SetConfig(cfg)
+}
+
+// SetConfig changes the configuration; the level adapts from the next sample
+func (t *Throttle) SetConfig(cfg Config) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+	if !cfg.Enabled {
+		t.level = 1
+	}
+}
+
+// TransferStarted counts a foreground upload or download until the returned func is called
+func (t *Throttle) TransferStarted() func() {
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	t.transfers++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			t.transfers--
+			t.mu.Unlock()
+		})
+	}
+}
+
+// RecordRequest counts a foreground request toward the request rate
+func (t *Throttle) RecordRequest() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.requests++
+	t.mu.Unlock()
+}
+
+// Foreground wraps a transfer handler so it counts as foreground load while it runs
+func (t *Throttle) Foreground(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		done := t.TransferStarted()
+		defer done()
+		next(w, r)
+	}
+}
+
+// Level returns the share of time background jobs may currently run
+func (t *Throttle) Level() float64 {
+	if t == nil {
+		return 1
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sampleLocked()
+	return t.level
+}
+
+// Yield is called by a background job after a unit of work that took worked.
+// It pauses the job so that, at level l, work fills only l of the time. It
+// returns early with ctx's error if ctx is done.
+func (t *Throttle) Yield(ctx context.Context, job string, worked time.Duration) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	t.sampleLocked()
+	stats := t.jobs[job]
+	if stats == nil {
+		stats = &JobStats{}
+		t.jobs[job] = stats
+	}
+	stats.Units++
+	stats.LastYield = t.now()
+	var pause time.Duration
+	if t.cfg.Enabled && t.level < 1 && worked > 0 {
+		pause = time.Duration(float64(worked) * (1 - t.level) / t.level)
+		pause = min(pause, maxPause)
+		stats.Paused += pause.Seconds()
+	}
+	t.mu.Unlock()
+
+	if pause <= 0 {
+		return nil
+	}
+	return t.sleep(ctx, pause)
+}
+
+// Stats returns a snapshot of load, level and per-job throttling
+func (t *Throttle) Stats() Stats {
+	if t == nil {
+		return Stats{Level: 1}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sampleLocked()
+	stats := Stats{
+		Enabled:         t.cfg.Enabled,
+		Level:           t.level,
+		Load:            t.load,
+		ActiveTransfers: t.transfers,
+		RequestRate:     t.rate,
+		CPULoad:         t.cpu,
+		Jobs:            make(map[string]JobStats, len(t.jobs)),
+	}
+	for job, jobStats := range t.jobs {
+		stats.Jobs[job] = *jobStats
+	}
+	return stats
+}
+
+// sampleLocked measures load and moves the level once per sample interval
+func (t *Throttle) sampleLocked() {
+	now := t.now()
+	interval := t.cfg.SampleInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if !t.sampledAt.IsZero() && now.Sub(t.sampledAt) < interval {
+		return
+	}
+	t.sampledAt = now
+
+	if elapsed := now.Sub(t.windowStart).Seconds(); elapsed > 0 {
+		t.rate = float64(t.requests) / elapsed
+	}
+	t.requests = 0
+	t.windowStart = now
+
+	load := 0.0
+	if t.cfg.BusyTransfers > 0 {
+		load = max(load, float64(t.transfers)/float64(t.cfg.BusyTransfers))
+	}
+	if t.cfg.BusyRequestRate > 0 {
+		load = max(load, t.rate/t.cfg.BusyRequestRate)
+	}
+	t.cpu = 0
+	if t.cfg.BusyCPULoad > 0 && t.cpuLoad != nil {
+		if cpu, ok := t.cpuLoad(); ok {
+			t.cpu = cpu
+			load = max(load, cpu/t.cfg.BusyCPULoad)
+		}
+	}
+	sensitivity := t.cfg.Sensitivity
+	if sensitivity <= 0 {
+		sensitivity = 1
+	}
+	t.load = min(load*sensitivity, 1)
+
+	if !t.cfg.Enabled {
+		t.level = 1
+		return
+	}
+	floor := min(max(t.cfg.MinLevel, 0.01), 1)
+	target := 1 - t.load*(1-floor)
+	if target < t.level || t.cfg.RampUp <= 0 {
+		t.level = target
+	} else {
+		t.level = min(target, t.level+t.cfg.RampUp)
+	}
+}
+
+// loadAverage reads the one-minute load average per CPU, which counts tasks
+// waiting on IO as well as on the CPU; it is only available on Linux
+func loadAverage() (float64, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	avg, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	return avg / float64(runtime.NumCPU()), true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
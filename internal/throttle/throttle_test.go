@@ -0,0 +1,97 @@
+package throttle
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackgroundWorkBacksOffUnderForegroundLoad(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.BusyCPULoad = 0
+	th := New(cfg)
+	clock := time.Now()
+	th.now = func() time.Time { return clock }
+	var paused []time.Duration
+	th.sleep = func(ctx context.Context, d time.Duration) error {
+		paused = append(paused, d)
+		return nil
+	}
+	tick := func() { clock = clock.Add(cfg.SampleInterval) }
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	// An idle node runs background work flat out
+	tick()
+	th.Yield(context.Background(), JobScrub, 100*time.Millisecond)
+	if level := th.Level(); level != 1 || len(paused) != 0 {
+		t.Fatalf("expected no throttling while idle, level %v, pauses %v", level, paused)
+	}
+
+	// Half the busy transfer count halves the load
+	var done []func()
+	for i := 0; i < cfg.BusyTransfers/2; i++ {
+		done = append(done, th.TransferStarted())
+	}
+	tick()
+	if level := th.Level(); !near(level, 1-0.5*(1-cfg.MinLevel)) {
+		t.Errorf("expected the level cut by half the headroom, got %v", level)
+	}
+
+	// A busy node leaves background work its minimum share
+	for i := 0; i < cfg.BusyTransfers/2; i++ {
+		done = append(done, th.TransferStarted())
+	}
+	tick()
+	th.Yield(context.Background(), JobRepair, 100*time.Millisecond)
+	if len(paused) != 1 || paused[0] != 900*time.Millisecond {
+		t.Fatalf("expected a 100ms unit followed by a 900ms pause, got %v", paused)
+	}
+
+	// A burst of requests alone is foreground load too
+	for _, d := range done {
+		d()
+	}
+	for i := 0; i < int(cfg.BusyRequestRate*2); i++ {
+		th.RecordRequest()
+	}
+	tick()
+	if stats := th.Stats(); stats.ActiveTransfers != 0 || stats.Load != 1 || !near(stats.Level, cfg.MinLevel) {
+		t.Errorf("expected the request rate to keep the node busy, got %+v", stats)
+	}
+
+	// Once idle again the level ramps up gradually rather than at once
+	tick()
+	if level := th.Level(); !near(level, cfg.MinLevel+cfg.RampUp) {
+		t.Errorf("expected the level to rise by one step, got %v", level)
+	}
+	for i := 0; i < 20; i++ {
+		tick()
+		th.Level()
+	}
+	if level := th.Level(); level != 1 {
+		t.Errorf("expected full speed after a quiet period, got %v", level)
+	}
+
+	stats := th.Stats()
+	if stats.Jobs[JobRepair].Units != 1 || stats.Jobs[JobRepair].Paused != 0.9 || stats.Jobs[JobScrub].Paused != 0 {
+		t.Errorf("expected per-job throttling recorded, got %+v", stats.Jobs)
+	}
+}
+
+func TestCPULoadAndSensitivityScaleTheBackOff(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Sensitivity = 2
+	th := New(cfg)
+	th.cpuLoad = func() (float64, bool) { return 0.25, true }
+	if level, want := th.Level(), 1-0.5*(1-cfg.MinLevel); math.Abs(level-want) > 1e-9 {
+		t.Errorf("expected a quarter CPU load doubled to half, level %v, got %v", want, level)
+	}
+
+	th.SetConfig(Config{})
+	if err := th.Yield(context.Background(), JobScrub, time.Hour); err != nil || th.Level() != 1 {
+		t.Errorf("expected a disabled throttle never to pause")
+	}
+}
@@ -0,0 +1,156 @@
+// Package tracing carries a per-operation trace ID through context so log
+// lines and errors from the API, distributor, chunker and peer transfers
+// can be correlated.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Header carries the trace ID on API requests, API responses and peer transfers
+const Header = "X-Trace-ID"
+
+// maxIDLength bounds IDs accepted from clients and peers
+const maxIDLength = 64
+
+type contextKey struct{}
+
+var (
+	enabled atomic.Bool
+	outMu   sync.Mutex
+	out     io.Writer = os.Stdout
+	logHook atomic.Pointer[func(ctx context.Context, line string)]
+)
+
+func init() {
+	enabled.Store(true)
+}
+
+// SetEnabled turns trace IDs on or off; when off no IDs are issued and log lines are unprefixed
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// Enabled reports whether trace IDs are issued
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// SetOutput redirects traced log lines, returning the previous writer
+func SetOutput(w io.Writer) io.Writer {
+	outMu.Lock()
+	defer outMu.Unlock()
+	previous := out
+	out = w
+	return previous
+}
+
+// SetLogHook passes every traced log line, unprefixed, to hook as well; nil removes it
+func SetLogHook(hook func(ctx context.Context, line string)) {
+	if hook == nil {
+		logHook.Store(nil)
+		return
+	}
+	logHook.Store(&hook)
+}
+
+// NewID returns a random 16 character trace ID
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "untraced"
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidID reports whether an ID supplied by a client or peer is safe to log and echo
+func ValidID(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithID returns a context carrying the trace ID
+func WithID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// ID returns the context's trace ID, or "" when it has none
+func ID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// FromRequest returns the request's context carrying the trace ID from its
+// header, issuing a new ID when the header is missing or invalid
+func FromRequest(r *http.Request) (context.Context, string) {
+	ctx := r.Context()
+	if !Enabled() {
+		return ctx, ""
+	}
+	if id := ID(ctx); id != "" {
+		return ctx, id
+	}
+	id := r.Header.Get(Header)
+	if !ValidID(id) {
+		id = NewID()
+	}
+	return WithID(ctx, id), id
+}
+
+// Inject copies the context's trace ID onto an outgoing request
+func Inject(ctx context.Context, req *http.Request) {
+	if id := ID(ctx); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
+
+// Logf writes a log line prefixed with the context's trace ID
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	if hook := logHook.Load(); hook != nil {
+		(*hook)(ctx, line)
+	}
+	if id := ID(ctx); id != "" {
+		line = "[trace=" + id + "] " + line
+	}
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+
+	outMu.Lock()
+	defer outMu.Unlock()
+	io.WriteString(out, line)
+}
+
+// Error annotates err with the context's trace ID; errors.Is and errors.As still see err
+func Error(ctx context.Context, err error) error {
+	id := ID(ctx)
+	if err == nil || id == "" || strings.Contains(err.Error(), "[trace="+id+"]") {
+		return err
+	}
+	return fmt.Errorf("%w [trace=%s]", err, id)
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFromRequestAcceptsValidHeadersOnly(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/files", nil)
+	r.Header.Set(Header, "client-req.7")
+	if _, id := FromRequest(r); id != "client-req.7" {
+		t.Errorf("expected the client's trace ID to be kept, got %q", id)
+	}
+
+	// IDs that would corrupt log lines are replaced
+	r.Header.Set(Header, "bad id\nforged line")
+	ctx, id := FromRequest(r)
+	if id == "bad id\nforged line" || !ValidID(id) || ID(ctx) != id {
+		t.Errorf("expected a fresh ID for an invalid header, got %q", id)
+	}
+}
+
+func TestErrorKeepsCauseAndTagsOnce(t *testing.T) {
+	cause := errors.New("chunk missing")
+	ctx := WithID(context.Background(), "abc")
+
+	err := Error(ctx, Error(ctx, cause))
+	if !errors.Is(err, cause) {
+		t.Errorf("traced error no longer matches its cause")
+	}
+	if strings.Count(err.Error(), "[trace=abc]") != 1 {
+		t.Errorf("expected the trace ID exactly once, got %q", err.Error())
+	}
+	if Error(context.Background(), cause) != cause {
+		t.Errorf("errors without a trace ID should be returned unchanged")
+	}
+}
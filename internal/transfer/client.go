@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
 )
 
@@ -143,6 +144,7 @@ func (c *Client) sendChunk(transferID string, chunkMeta metadata.ChunkMetadata,
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("X-Chunk-Hash", chunkMeta.Hash)
 	req.Header.Set("X-Chunk-Size", strconv.FormatInt(chunkMeta.Size, 10))
+	req.Header.Set(p2p.ChunkDigestHeader, p2p.ChunkDigest(chunkData))
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jaywantadh/DisktroByte/internal/budget"
 	"github.com/jaywantadh/DisktroByte/internal/metadata"
+	"github.com/jaywantadh/DisktroByte/internal/p2p"
 	"github.com/jaywantadh/DisktroByte/internal/storage"
 )
 
@@ -174,6 +176,15 @@ func (s *Server) handleChunkUpload(w http.ResponseWriter, r *http.Request, trans
 		return
 	}
 
+	// Shed the upload rather than buffer past the memory budget
+	memory := budget.Global()
+	if !memory.TryAcquire(r.ContentLength) {
+		w.Header().Set("Retry-After", "1")
+		WriteErrorResponse(w, http.StatusServiceUnavailable, "Server busy, memory budget exhausted")
+		return
+	}
+	defer memory.Release(r.ContentLength)
+
 	// Read chunk data
 	chunkData, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -201,6 +212,10 @@ func (s *Server) handleChunkUpload(w http.ResponseWriter, r *http.Request, trans
 		WriteErrorResponse(w, http.StatusBadRequest, "Chunk size mismatch")
 		return
 	}
+	if err := p2p.VerifyChunkDigest(chunkData, r.Header.Get(p2p.ChunkDigestHeader)); err != nil {
+		WriteErrorResponse(w, http.StatusUnprocessableEntity, "Chunk failed verification: "+err.Error())
+		return
+	}
 
 	// Store chunk
 	chunkPath, err := s.store.Put(bytes.NewReader(chunkData))
@@ -1,7 +1,7 @@
 package utils
 
 type NodeInfo struct{
-	ID			string `json: "id"`
-	Address		string `json: "address"`
-	JoinTime	int64  `json: "join_time"`
+	ID			string `json:"id"`
+	Address		string `json:"address"`
+	JoinTime	int64  `json:"join_time"`
 }
\ No newline at end of file